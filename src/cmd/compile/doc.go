@@ -102,6 +102,10 @@ Flags:
 		and diagnose imports that would cause a circular dependency.
 	-pack
 		Write a package (archive) file rather than an object file
+	-pgoprofile file
+		Read the CPU profile in file and use it to guide optimization:
+		hot call sites are inlined with a larger budget, hot interface
+		calls are devirtualized, and blocks are laid out for hot paths.
 	-race
 		Compile with race detector enabled.
 	-s
@@ -25,32 +25,37 @@ var Debug DebugFlags
 // The -d option takes a comma-separated list of settings.
 // Each setting is name=value; for ints, name is short for name=1.
 type DebugFlags struct {
-	Append               int    `help:"print information about append compilation"`
-	Checkptr             int    `help:"instrument unsafe pointer conversions"`
-	Closure              int    `help:"print information about closure compilation"`
-	DclStack             int    `help:"run internal dclstack check"`
-	Defer                int    `help:"print information about defer compilation"`
-	DisableNil           int    `help:"disable nil checks"`
-	DumpPtrs             int    `help:"show Node pointers values in dump output"`
-	DwarfInl             int    `help:"print information about DWARF inlined function creation"`
-	Export               int    `help:"print export data"`
-	GCProg               int    `help:"print dump of GC programs"`
-	InlFuncsWithClosures int    `help:"allow functions with closures to be inlined"`
-	Libfuzzer            int    `help:"enable coverage instrumentation for libfuzzer"`
-	LocationLists        int    `help:"print information about DWARF location list creation"`
-	Nil                  int    `help:"print information about nil checks"`
-	NoOpenDefer          int    `help:"disable open-coded defers"`
-	PCTab                string `help:"print named pc-value table"`
-	Panic                int    `help:"show all compiler panics"`
-	Slice                int    `help:"print information about slice compilation"`
-	SoftFloat            int    `help:"force compiler to emit soft-float code"`
-	SyncFrames           int    `help:"how many writer stack frames to include at sync points in unified export data"`
-	TypeAssert           int    `help:"print information about type assertion inlining"`
-	TypecheckInl         int    `help:"eager typechecking of inline function bodies"`
-	Unified              int    `help:"enable unified IR construction"`
-	UnifiedQuirks        int    `help:"enable unified IR construction's quirks mode"`
-	WB                   int    `help:"print information about write barriers"`
-	ABIWrap              int    `help:"print information about ABI wrapper generation"`
+	Append                int    `help:"print information about append compilation"`
+	Checkptr              int    `help:"instrument unsafe pointer conversions"`
+	Closure               int    `help:"print information about closure compilation"`
+	DclStack              int    `help:"run internal dclstack check"`
+	Defer                 int    `help:"print information about defer compilation"`
+	DisableNil            int    `help:"disable nil checks"`
+	DumpPtrs              int    `help:"show Node pointers values in dump output"`
+	DwarfInl              int    `help:"print information about DWARF inlined function creation"`
+	Export                int    `help:"print export data"`
+	GCProg                int    `help:"print dump of GC programs"`
+	InlFuncsWithClosures  int    `help:"allow functions with closures to be inlined"`
+	Libfuzzer             int    `help:"enable coverage instrumentation for libfuzzer"`
+	LocationLists         int    `help:"print information about DWARF location list creation"`
+	Nil                   int    `help:"print information about nil checks"`
+	NoOpenDefer           int    `help:"disable open-coded defers"`
+	PCTab                 string `help:"print named pc-value table"`
+	PGOInline             int    `help:"enable profile-guided inlining; >1 for debug output"`
+	PGOInlineBudget       int    `help:"inline budget for hot call sites"`
+	PGOInlineCDFThreshold int    `help:"cumulative weight percentage for hot call sites"`
+	PGODevirtualize       int    `help:"enable profile-guided devirtualization"`
+	PGOLayout             int    `help:"enable profile-guided basic block layout"`
+	Panic                 int    `help:"show all compiler panics"`
+	Slice                 int    `help:"print information about slice compilation"`
+	SoftFloat             int    `help:"force compiler to emit soft-float code"`
+	SyncFrames            int    `help:"how many writer stack frames to include at sync points in unified export data"`
+	TypeAssert            int    `help:"print information about type assertion inlining"`
+	TypecheckInl          int    `help:"eager typechecking of inline function bodies"`
+	Unified               int    `help:"enable unified IR construction"`
+	UnifiedQuirks         int    `help:"enable unified IR construction's quirks mode"`
+	WB                    int    `help:"print information about write barriers"`
+	ABIWrap               int    `help:"print information about ABI wrapper generation"`
 
 	any bool // set when any of the values have been set
 }
@@ -0,0 +1,229 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package devirtualize
+
+import (
+	"strings"
+
+	"cmd/compile/internal/base"
+	"cmd/compile/internal/ir"
+	"cmd/compile/internal/pgo"
+	"cmd/compile/internal/typecheck"
+	"cmd/compile/internal/types"
+	"cmd/internal/objabi"
+	"cmd/internal/src"
+)
+
+// ProfileGuided performs call devirtualization of indirect calls based on
+// profile information.
+//
+// Specifically, it performs conditional devirtualization of interface calls
+// for the hottest callee. That is, it performs a transformation like:
+//
+//	type Iface interface {
+//		Foo()
+//	}
+//
+//	type Concrete struct{}
+//
+//	func (Concrete) Foo() {}
+//
+//	func foo(i Iface) {
+//		i.Foo()
+//	}
+//
+// to:
+//
+//	func foo(i Iface) {
+//		if c, ok := i.(Concrete); ok {
+//			c.Foo()
+//		} else {
+//			i.Foo()
+//		}
+//	}
+//
+// The primary benefit of this transformation is enabling inlining of the
+// direct call.
+func ProfileGuided(fn *ir.Func, p *pgo.Profile) {
+	savefn := ir.CurFunc
+	ir.CurFunc = fn
+
+	var edit func(n ir.Node) ir.Node
+	edit = func(n ir.Node) ir.Node {
+		switch n.Op() {
+		case ir.ODEFER, ir.OGO:
+			// The call must remain a direct child of the
+			// go or defer statement.
+			return n
+		case ir.OCLOSURE:
+			// Closures are handled as functions of their own.
+			return n
+		}
+
+		ir.EditChildren(n, edit)
+
+		call, ok := n.(*ir.CallExpr)
+		if !ok || call.Op() != ir.OCALLINTER {
+			return n
+		}
+		typ := hotConcreteType(fn, call, p)
+		if typ == nil {
+			return n
+		}
+		if base.Flag.LowerM != 0 {
+			base.WarnfAt(call.Pos(), "PGO devirtualizing %v to %v", call.X, typ)
+		}
+		return rewriteCondCall(call, fn, typ)
+	}
+	ir.EditChildren(fn, edit)
+
+	ir.CurFunc = savefn
+}
+
+// hotConcreteType returns the concrete type that receives the hottest
+// call edge at the interface call site call within fn, or nil if
+// there is no such edge or its callee is not a method of a concrete
+// type implementing the called interface.
+func hotConcreteType(fn *ir.Func, call *ir.CallExpr, p *pgo.Profile) *types.Type {
+	sel := call.X.(*ir.SelectorExpr)
+	iface := sel.X.Type()
+
+	for _, callee := range p.HotCallees(pgo.PosOf(fn, call.Pos())) {
+		typ, method := lookupMethod(callee)
+		if typ == nil || method != sel.Sel.Name {
+			continue
+		}
+		if op, _ := typecheck.Assignop(typ, iface); op != ir.OCONVIFACE {
+			continue
+		}
+		return typ
+	}
+	return nil
+}
+
+// lookupMethod resolves a method's linker symbol name, such as
+// "example.com/pkg.(*T).M" or "example.com/pkg.T.M", to the type T (or
+// *T) and the method name M. It returns a nil type if name is not a
+// method of a named type known to the compiler. Methods of generic
+// types and closures within methods are not supported.
+func lookupMethod(name string) (*types.Type, string) {
+	if strings.Contains(name, "[") {
+		return nil, ""
+	}
+
+	// Dots in the final element of the package path are escaped in
+	// symbol names, so the first dot after the last slash ends the
+	// package prefix.
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return nil, ""
+	}
+	prefix, rest := name[:slash+1+dot], name[slash+1+dot+1:]
+
+	dot = strings.LastIndex(rest, ".")
+	if dot < 0 {
+		return nil, "" // not a method
+	}
+	recv, method := rest[:dot], rest[dot+1:]
+	ptr := false
+	if strings.HasPrefix(recv, "(*") && strings.HasSuffix(recv, ")") {
+		ptr = true
+		recv = recv[len("(*") : len(recv)-len(")")]
+	}
+	if recv == "" || strings.ContainsAny(recv, ".()*") {
+		return nil, ""
+	}
+
+	var pkg *types.Pkg
+	if prefix == objabi.PathToPrefix(base.Ctxt.Pkgpath) {
+		pkg = types.LocalPkg
+	} else {
+		pkg = types.PkgByPrefix(prefix)
+	}
+	if pkg == nil {
+		return nil, ""
+	}
+	sym := pkg.Syms[recv]
+	if sym == nil {
+		return nil, ""
+	}
+	n := typecheck.Resolve(ir.NewIdent(base.Pos, sym))
+	if n.Op() != ir.OTYPE || n.Type() == nil {
+		return nil, ""
+	}
+	typ := n.Type()
+	if typ.IsInterface() || typ.HasTParam() {
+		return nil, ""
+	}
+	if ptr {
+		typ = types.NewPtr(typ)
+	}
+	return typ, method
+}
+
+// rewriteCondCall rewrites the interface call call within curfn into a
+// conditional call to the method of the concrete type typ, falling back
+// to the original interface call when the dynamic type of the receiver
+// is not typ.
+//
+// The result is an InlinedCallExpr. This isn't really an inlined call,
+// but InlinedCallExpr lets the rewritten call appear anywhere a call may
+// appear, and the inliner and later passes already know how to handle
+// it.
+func rewriteCondCall(call *ir.CallExpr, curfn *ir.Func, typ *types.Type) ir.Node {
+	pos := call.Pos()
+	init := ir.TakeInit(call)
+	sel := call.X.(*ir.SelectorExpr)
+
+	// Evaluate the receiver and arguments exactly once.
+	recv := typecheck.TempAt(pos, curfn, sel.X.Type())
+	init.Append(typecheck.Stmt(ir.NewAssignStmt(pos, recv, sel.X)))
+	sel.X = recv
+
+	args := call.Args.Take()
+	for i, arg := range args {
+		tmp := typecheck.TempAt(pos, curfn, arg.Type())
+		init.Append(typecheck.Stmt(ir.NewAssignStmt(pos, tmp, arg)))
+		args[i] = tmp
+	}
+	call.Args = args
+
+	// c, ok := recv.(typ)
+	c := typecheck.TempAt(pos, curfn, typ)
+	ok := typecheck.TempAt(pos, curfn, types.Types[types.TBOOL])
+	assert := ir.NewTypeAssertExpr(pos, recv, ir.TypeNode(typ))
+	init.Append(typecheck.Stmt(ir.NewAssignListStmt(pos, ir.OAS2, []ir.Node{c, ok}, []ir.Node{assert})))
+
+	concreteArgs := append([]ir.Node(nil), args...)
+	concrete := typecheck.Call(pos, ir.NewSelectorExpr(pos, ir.OXDOT, c, sel.Sel), concreteArgs, call.IsDDD)
+
+	var retvars []ir.Node
+	for _, r := range call.X.Type().Results().FieldSlice() {
+		retvars = append(retvars, typecheck.TempAt(pos, curfn, r.Type))
+	}
+
+	cond := ir.NewIfStmt(pos, ok, []ir.Node{assignResults(pos, retvars, concrete)}, []ir.Node{assignResults(pos, retvars, call)})
+	cond.Likely = true
+
+	res := ir.NewInlinedCallExpr(pos, []ir.Node{typecheck.Stmt(cond)}, retvars)
+	res.SetInit(init)
+	res.SetType(call.Type())
+	res.SetTypecheck(1)
+	return res
+}
+
+// assignResults returns a statement that evaluates call and assigns its
+// results to retvars.
+func assignResults(pos src.XPos, retvars []ir.Node, call ir.Node) ir.Node {
+	switch len(retvars) {
+	case 0:
+		return call
+	case 1:
+		return typecheck.Stmt(ir.NewAssignStmt(pos, retvars[0], call))
+	default:
+		return typecheck.Stmt(ir.NewAssignListStmt(pos, ir.OAS2, retvars, []ir.Node{call}))
+	}
+}
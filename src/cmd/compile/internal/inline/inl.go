@@ -32,8 +32,10 @@ import (
 	"strings"
 
 	"cmd/compile/internal/base"
+	"cmd/compile/internal/devirtualize"
 	"cmd/compile/internal/ir"
 	"cmd/compile/internal/logopt"
+	"cmd/compile/internal/pgo"
 	"cmd/compile/internal/typecheck"
 	"cmd/compile/internal/types"
 	"cmd/internal/obj"
@@ -53,8 +55,14 @@ const (
 	inlineBigFunctionMaxCost = 20   // Max cost of inlinee when inlining into a "big" function.
 )
 
+// pgoProfile is the profile that guides inlining, if any.
+var pgoProfile *pgo.Profile
+
 // InlinePackage finds functions that can be inlined and clones them before walk expands them.
-func InlinePackage() {
+// If profile is non-nil, functions and call sites that are hot in the profile
+// are given a larger inlining budget.
+func InlinePackage(profile *pgo.Profile) {
+	pgoProfile = profile
 	ir.VisitFuncsBottomUp(typecheck.Target.Decls, func(list []*ir.Func, recursive bool) {
 		numfns := numNonClosures(list)
 		for _, n := range list {
@@ -68,6 +76,12 @@ func InlinePackage() {
 					fmt.Printf("%v: cannot inline %v: recursive\n", ir.Line(n), n.Nname)
 				}
 			}
+			if profile != nil && base.Debug.PGODevirtualize != 0 {
+				// Devirtualize hot interface calls after n's own
+				// inlinable body has been saved, so that the direct
+				// calls can be inlined into n.
+				devirtualize.ProfileGuided(n, profile)
+			}
 			InlineCalls(n)
 		}
 	})
@@ -166,8 +180,14 @@ func CanInline(fn *ir.Func) {
 	// locals, and we use this map to produce a pruned Inline.Dcl
 	// list. See issue 25249 for more context.
 
+	budget := int32(inlineMaxBudget)
+	if isHotCallee(fn) {
+		budget = int32(base.Debug.PGOInlineBudget)
+	}
+
 	visitor := hairyVisitor{
-		budget:        inlineMaxBudget,
+		budget:        budget,
+		maxBudget:     budget,
 		extraCallCost: cc,
 	}
 	if visitor.tooHairy(fn) {
@@ -176,7 +196,7 @@ func CanInline(fn *ir.Func) {
 	}
 
 	n.Func.Inl = &ir.Inline{
-		Cost: inlineMaxBudget - visitor.budget,
+		Cost: budget - visitor.budget,
 		Dcl:  pruneUnusedAutos(n.Defn.(*ir.Func).Dcl, &visitor),
 		Body: inlcopylist(fn.Body),
 
@@ -184,15 +204,33 @@ func CanInline(fn *ir.Func) {
 	}
 
 	if base.Flag.LowerM > 1 {
-		fmt.Printf("%v: can inline %v with cost %d as: %v { %v }\n", ir.Line(fn), n, inlineMaxBudget-visitor.budget, fn.Type(), ir.Nodes(n.Func.Inl.Body))
+		fmt.Printf("%v: can inline %v with cost %d as: %v { %v }\n", ir.Line(fn), n, budget-visitor.budget, fn.Type(), ir.Nodes(n.Func.Inl.Body))
 	} else if base.Flag.LowerM != 0 {
 		fmt.Printf("%v: can inline %v\n", ir.Line(fn), n)
 	}
 	if logopt.Enabled() {
-		logopt.LogOpt(fn.Pos(), "canInlineFunction", "inline", ir.FuncName(fn), fmt.Sprintf("cost: %d", inlineMaxBudget-visitor.budget))
+		logopt.LogOpt(fn.Pos(), "canInlineFunction", "inline", ir.FuncName(fn), fmt.Sprintf("cost: %d", budget-visitor.budget))
 	}
 }
 
+// isHotCallee reports whether fn is the callee of a hot call edge
+// in the profile guiding inlining.
+func isHotCallee(fn *ir.Func) bool {
+	if pgoProfile == nil || base.Debug.PGOInline == 0 || base.Flag.CompilingRuntime {
+		return false
+	}
+	return pgoProfile.IsHotCallee(pgo.FuncName(fn))
+}
+
+// isHotCallSite reports whether the call n in caller to callee is a hot
+// call edge in the profile guiding inlining.
+func isHotCallSite(caller *ir.Func, n *ir.CallExpr, callee *ir.Func) bool {
+	if pgoProfile == nil || base.Debug.PGOInline == 0 || base.Flag.CompilingRuntime {
+		return false
+	}
+	return pgoProfile.IsHotEdge(pgo.PosOf(caller, n.Pos()), pgo.FuncName(callee))
+}
+
 // canDelayResults reports whether inlined calls to fn can delay
 // declaring the result parameter until the "return" statement.
 func canDelayResults(fn *ir.Func) bool {
@@ -229,6 +267,7 @@ func canDelayResults(fn *ir.Func) bool {
 // hairiness and whether or not it can be inlined.
 type hairyVisitor struct {
 	budget        int32
+	maxBudget     int32
 	reason        string
 	extraCallCost int32
 	usedLocals    ir.NameSet
@@ -241,7 +280,7 @@ func (v *hairyVisitor) tooHairy(fn *ir.Func) bool {
 		return true
 	}
 	if v.budget < 0 {
-		v.reason = fmt.Sprintf("function too complex: cost %d exceeds budget %d", inlineMaxBudget-v.budget, inlineMaxBudget)
+		v.reason = fmt.Sprintf("function too complex: cost %d exceeds budget %d", v.maxBudget-v.budget, v.maxBudget)
 		return true
 	}
 	return false
@@ -293,6 +332,13 @@ func (v *hairyVisitor) doNode(n ir.Node) bool {
 		}
 
 		if fn := inlCallee(n.X); fn != nil && fn.Inl != nil {
+			if fn.Inl.Cost > inlineMaxBudget {
+				// fn is inlinable only because it is hot;
+				// it will be inlined only at hot call sites,
+				// so charge for an ordinary call.
+				v.budget -= v.extraCallCost
+				break
+			}
 			v.budget -= fn.Inl.Cost
 			break
 		}
@@ -650,6 +696,23 @@ var SSADumpInline = func(*ir.Func) {}
 // instead.
 var NewInline = func(call *ir.CallExpr, fn *ir.Func, inlIndex int) *ir.InlinedCallExpr { return nil }
 
+// hotCallOverBudget reports whether the call n to fn, whose inlining
+// cost exceeds maxCost, should be inlined anyway because the profile
+// guiding inlining shows the call site is hot. Calls from big
+// functions are never inlined over budget.
+func hotCallOverBudget(n *ir.CallExpr, fn *ir.Func, maxCost int32) bool {
+	if maxCost < inlineMaxBudget || fn.Inl.Cost > int32(base.Debug.PGOInlineBudget) {
+		return false
+	}
+	if !isHotCallSite(ir.CurFunc, n, fn) {
+		return false
+	}
+	if base.Debug.PGOInline > 1 {
+		fmt.Printf("%v: hot call to %v with cost %d inlined over budget %d\n", ir.Line(n), fn, fn.Inl.Cost, maxCost)
+	}
+	return true
+}
+
 // If n is a OCALLFUNC node, and fn is an ONAME node for a
 // function with an inlinable body, return an OINLCALL node that can replace n.
 // The returned node's Ninit has the parameter assignments, the Nbody is the
@@ -665,7 +728,7 @@ func mkinlcall(n *ir.CallExpr, fn *ir.Func, maxCost int32, inlMap map[*ir.Func]b
 		}
 		return n
 	}
-	if fn.Inl.Cost > maxCost {
+	if fn.Inl.Cost > maxCost && !hotCallOverBudget(n, fn, maxCost) {
 		// The inlined function body is too big. Typically we use this check to restrict
 		// inlining into very big functions.  See issue 26546 and 17566.
 		if logopt.Enabled() {
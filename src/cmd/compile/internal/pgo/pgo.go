@@ -0,0 +1,276 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pgo implements the compiler's support for profile-guided
+// optimization (PGO).
+//
+// A profile is a CPU profile in the pprof format, as written by
+// runtime/pprof or served by net/http/pprof. The compiler reduces it to
+// two summaries keyed by source position: the weight of every call edge
+// observed in the profile, and the cumulative weight of every source
+// line. The inliner and devirtualizer consult the call edges, and the
+// SSA back end consults the line weights when laying out blocks.
+//
+// Functions are identified by their linker symbol names, which are the
+// names the runtime records in profiles, and lines by their absolute
+// line numbers. A profile collected from an older version of the
+// source therefore still applies to functions whose lines have not
+// moved.
+package pgo
+
+import (
+	"errors"
+	"fmt"
+	"internal/profile"
+	"os"
+	"sort"
+	"strings"
+
+	"cmd/compile/internal/base"
+	"cmd/compile/internal/ir"
+	"cmd/internal/objabi"
+	"cmd/internal/src"
+)
+
+// A Pos identifies a source line within a function.
+type Pos struct {
+	Func string // linker symbol name of the function
+	Line int    // line number within the file
+}
+
+func (p Pos) String() string { return fmt.Sprintf("%s:%d", p.Func, p.Line) }
+
+// A Profile is the compiler's summary of a CPU profile.
+type Profile struct {
+	// TotalWeight is the sum of the weights of all samples.
+	TotalWeight int64
+
+	// edges maps each call site to the callees observed there,
+	// and the callees to the weight of the call edge.
+	edges map[Pos]map[string]int64
+
+	// lines maps each source line to the sum of the weights of the
+	// samples whose stacks include that line.
+	lines map[Pos]int64
+
+	// hotEdgeWeight is the minimum weight of a hot call edge.
+	hotEdgeWeight int64
+
+	// hotCallees is the set of functions that are the callee of at
+	// least one hot call edge.
+	hotCallees map[string]bool
+}
+
+// New reads the CPU profile in file and summarizes it.
+func New(file string) (*Profile, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := profile.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("parsing profile: %v", err)
+	}
+	return FromProfile(p, base.Debug.PGOInlineCDFThreshold)
+}
+
+// FromProfile summarizes the parsed profile p.
+//
+// The hot call edges are the heaviest edges that together account for
+// cdfThreshold percent of the total call edge weight.
+func FromProfile(p *profile.Profile, cdfThreshold int) (*Profile, error) {
+	idx, err := sampleIndex(p)
+	if err != nil {
+		return nil, err
+	}
+	if cdfThreshold < 0 || cdfThreshold > 100 {
+		return nil, fmt.Errorf("invalid hot call site threshold %d%%", cdfThreshold)
+	}
+
+	prof := &Profile{
+		edges:      make(map[Pos]map[string]int64),
+		lines:      make(map[Pos]int64),
+		hotCallees: make(map[string]bool),
+	}
+
+	type edge struct {
+		caller Pos
+		callee string
+	}
+	var frames []Pos
+	seenLine := make(map[Pos]bool)
+	seenEdge := make(map[edge]bool)
+	for _, s := range p.Sample {
+		w := s.Value[idx]
+		if w <= 0 {
+			continue
+		}
+		prof.TotalWeight += w
+
+		// Flatten the stack, innermost frame first. Each Location
+		// lists its inlined frames innermost first too.
+		frames = frames[:0]
+		for _, loc := range s.Location {
+			for _, l := range loc.Line {
+				if l.Function == nil {
+					continue
+				}
+				frames = append(frames, Pos{Func: l.Function.Name, Line: int(l.Line)})
+			}
+		}
+
+		// Count each line and each edge once per sample, so that
+		// recursion does not inflate the weights.
+		for k := range seenLine {
+			delete(seenLine, k)
+		}
+		for k := range seenEdge {
+			delete(seenEdge, k)
+		}
+		for i, f := range frames {
+			if !seenLine[f] {
+				seenLine[f] = true
+				prof.lines[f] += w
+			}
+			if i == 0 {
+				continue
+			}
+			e := edge{caller: f, callee: frames[i-1].Func}
+			if seenEdge[e] {
+				continue
+			}
+			seenEdge[e] = true
+			callees := prof.edges[f]
+			if callees == nil {
+				callees = make(map[string]int64)
+				prof.edges[f] = callees
+			}
+			callees[e.callee] += w
+		}
+	}
+
+	prof.hotEdgeWeight = prof.computeHotEdgeWeight(cdfThreshold)
+	for _, callees := range prof.edges {
+		for callee, w := range callees {
+			if w >= prof.hotEdgeWeight {
+				prof.hotCallees[callee] = true
+			}
+		}
+	}
+	return prof, nil
+}
+
+// sampleIndex returns the index of the sample value that holds CPU
+// usage in p.
+func sampleIndex(p *profile.Profile) (int, error) {
+	if len(p.Sample) == 0 {
+		return 0, errors.New("profile contains no samples")
+	}
+	for _, want := range []profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}, {Type: "samples", Unit: "count"}} {
+		for i, t := range p.SampleType {
+			if t.Type == want.Type && t.Unit == want.Unit {
+				return i, nil
+			}
+		}
+	}
+	return 0, errors.New("profile is not a CPU profile")
+}
+
+// computeHotEdgeWeight returns the weight of the lightest call edge
+// among the heaviest edges that together account for cdfThreshold
+// percent of the total edge weight.
+func (p *Profile) computeHotEdgeWeight(cdfThreshold int) int64 {
+	var weights []int64
+	var total int64
+	for _, callees := range p.edges {
+		for _, w := range callees {
+			weights = append(weights, w)
+			total += w
+		}
+	}
+	if len(weights) == 0 || cdfThreshold == 0 {
+		return 1<<63 - 1 // nothing is hot
+	}
+	sort.Slice(weights, func(i, j int) bool { return weights[i] > weights[j] })
+	var cum int64
+	for _, w := range weights {
+		cum += w
+		if cum*100 >= total*int64(cdfThreshold) {
+			return w
+		}
+	}
+	return weights[len(weights)-1]
+}
+
+// EdgeWeight returns the weight of the call edge from the call site
+// caller to the function callee.
+func (p *Profile) EdgeWeight(caller Pos, callee string) int64 {
+	return p.edges[caller][callee]
+}
+
+// IsHotEdge reports whether the call edge from the call site caller to
+// the function callee is hot.
+func (p *Profile) IsHotEdge(caller Pos, callee string) bool {
+	w := p.edges[caller][callee]
+	return w > 0 && w >= p.hotEdgeWeight
+}
+
+// IsHotCallee reports whether fn is the callee of a hot call edge.
+func (p *Profile) IsHotCallee(fn string) bool {
+	return p.hotCallees[fn]
+}
+
+// HotCallees returns the callees of the hot call edges at the call site
+// caller, heaviest first.
+func (p *Profile) HotCallees(caller Pos) []string {
+	callees := p.edges[caller]
+	var hot []string
+	for callee, w := range callees {
+		if w >= p.hotEdgeWeight {
+			hot = append(hot, callee)
+		}
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		wi, wj := callees[hot[i]], callees[hot[j]]
+		if wi != wj {
+			return wi > wj
+		}
+		return hot[i] < hot[j]
+	})
+	return hot
+}
+
+// LineWeight returns the cumulative weight of the source line pos.
+func (p *Profile) LineWeight(pos Pos) int64 {
+	return p.lines[pos]
+}
+
+// FuncName returns the linker symbol name of fn, which is the name
+// the runtime uses for fn in profiles.
+func FuncName(fn *ir.Func) string {
+	return linkName(fn.Linksym().Name)
+}
+
+// linkName rewrites a symbol name that refers to the package being
+// compiled using its full package path.
+func linkName(name string) string {
+	if strings.HasPrefix(name, `"".`) {
+		return objabi.PathToPrefix(base.Ctxt.Pkgpath) + name[len(`""`):]
+	}
+	return name
+}
+
+// PosOf returns the profile position of pos, a position within the body
+// of fn. If pos is within a function that was inlined into fn, the
+// result refers to the inlined function, as the runtime does when it
+// expands inlined frames.
+func PosOf(fn *ir.Func, pos src.XPos) Pos {
+	p := base.Ctxt.PosTable.Pos(pos)
+	if ix := p.Base().InliningIndex(); ix >= 0 {
+		name := linkName(base.Ctxt.InlTree.InlinedFunction(ix).Name)
+		return Pos{Func: name, Line: int(base.Ctxt.InnermostPos(pos).RelLine())}
+	}
+	return Pos{Func: FuncName(fn), Line: int(p.RelLine())}
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pgo
+
+import (
+	"internal/profile"
+	"reflect"
+	"testing"
+)
+
+// testProfile returns a CPU profile with one sample for each stack in
+// stacks, with the given weights. Each stack lists its frames innermost
+// first.
+func testProfile(stacks [][]Pos, weights []int64) *profile.Profile {
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}},
+	}
+	funcs := make(map[string]*profile.Function)
+	for i, stack := range stacks {
+		s := &profile.Sample{Value: []int64{1, weights[i]}}
+		for _, f := range stack {
+			fn := funcs[f.Func]
+			if fn == nil {
+				fn = &profile.Function{ID: uint64(len(p.Function) + 1), Name: f.Func}
+				funcs[f.Func] = fn
+				p.Function = append(p.Function, fn)
+			}
+			loc := &profile.Location{
+				ID:   uint64(len(p.Location) + 1),
+				Line: []profile.Line{{Function: fn, Line: int64(f.Line)}},
+			}
+			p.Location = append(p.Location, loc)
+			s.Location = append(s.Location, loc)
+		}
+		p.Sample = append(p.Sample, s)
+	}
+	return p
+}
+
+func TestFromProfile(t *testing.T) {
+	var (
+		mainA = Pos{"main.main", 10}
+		mainB = Pos{"main.main", 11}
+		a     = Pos{"main.a", 20}
+		b     = Pos{"main.b", 30}
+		rec   = Pos{"main.rec", 40}
+	)
+	p := testProfile([][]Pos{
+		{a, mainA},
+		{b, mainB},
+		{rec, rec, rec, mainB},
+	}, []int64{90, 5, 5})
+
+	prof, err := FromProfile(p, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prof.TotalWeight != 100 {
+		t.Errorf("TotalWeight = %d, want 100", prof.TotalWeight)
+	}
+	if w := prof.EdgeWeight(mainA, "main.a"); w != 90 {
+		t.Errorf("EdgeWeight(%v, main.a) = %d, want 90", mainA, w)
+	}
+	// Recursive frames are counted once per sample.
+	if w := prof.EdgeWeight(rec, "main.rec"); w != 5 {
+		t.Errorf("EdgeWeight(%v, main.rec) = %d, want 5", rec, w)
+	}
+	if w := prof.LineWeight(rec); w != 5 {
+		t.Errorf("LineWeight(%v) = %d, want 5", rec, w)
+	}
+	if w := prof.LineWeight(mainB); w != 10 {
+		t.Errorf("LineWeight(%v) = %d, want 10", mainB, w)
+	}
+
+	if !prof.IsHotEdge(mainA, "main.a") {
+		t.Errorf("edge %v -> main.a is not hot", mainA)
+	}
+	if prof.IsHotEdge(mainB, "main.b") {
+		t.Errorf("edge %v -> main.b is hot", mainB)
+	}
+	if !prof.IsHotCallee("main.a") || prof.IsHotCallee("main.b") {
+		t.Errorf("IsHotCallee(main.a), IsHotCallee(main.b) = %v, %v, want true, false",
+			prof.IsHotCallee("main.a"), prof.IsHotCallee("main.b"))
+	}
+	if got, want := prof.HotCallees(mainA), []string{"main.a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("HotCallees(%v) = %v, want %v", mainA, got, want)
+	}
+	if got := prof.HotCallees(mainB); got != nil {
+		t.Errorf("HotCallees(%v) = %v, want none", mainB, got)
+	}
+}
+
+func TestFromProfileErrors(t *testing.T) {
+	p := testProfile([][]Pos{{{"main.main", 1}}}, []int64{1})
+	p.SampleType = []*profile.ValueType{{Type: "alloc_space", Unit: "bytes"}}
+	if _, err := FromProfile(p, 99); err == nil {
+		t.Errorf("FromProfile of heap profile succeeded")
+	}
+
+	p = testProfile(nil, nil)
+	if _, err := FromProfile(p, 99); err == nil {
+		t.Errorf("FromProfile of empty profile succeeded")
+	}
+}
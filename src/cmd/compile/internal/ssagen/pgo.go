@@ -0,0 +1,65 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssagen
+
+import (
+	"cmd/compile/internal/ir"
+	"cmd/compile/internal/pgo"
+	"cmd/compile/internal/ssa"
+	"cmd/internal/src"
+)
+
+// PGOProfile is the profile guiding optimization, if any.
+var PGOProfile *pgo.Profile
+
+// pgoHotRatio is how many times heavier one successor of a conditional
+// block must be in the profile than the other for the branch to be
+// marked likely.
+const pgoHotRatio = 2
+
+// setProfileBranchLikely marks the likely successor of the conditional
+// blocks in f whose direction is not otherwise known, using the weights
+// of the source lines executed by each successor. The block layout pass
+// places the likely successor directly after the conditional block.
+func setProfileBranchLikely(f *ssa.Func, fn *ir.Func, p *pgo.Profile) {
+	for _, b := range f.Blocks {
+		if b.Kind != ssa.BlockIf || b.Likely != ssa.BranchUnknown {
+			continue
+		}
+		w0 := blockWeight(b.Succs[0].Block(), fn, p)
+		w1 := blockWeight(b.Succs[1].Block(), fn, p)
+		switch {
+		case w0 > pgoHotRatio*w1:
+			b.Likely = ssa.BranchLikely
+		case w1 > pgoHotRatio*w0:
+			b.Likely = ssa.BranchUnlikely
+		}
+	}
+}
+
+// blockWeight returns the heaviest weight in p of the source lines of
+// the values in b. If b has no values with positions, blockWeight
+// follows b's sole successor instead, to see past empty blocks.
+func blockWeight(b *ssa.Block, fn *ir.Func, p *pgo.Profile) int64 {
+	const maxPlain = 4
+	for i := 0; i < maxPlain; i++ {
+		var w int64
+		found := false
+		for _, v := range b.Values {
+			if v.Pos == src.NoXPos {
+				continue
+			}
+			found = true
+			if vw := p.LineWeight(pgo.PosOf(fn, v.Pos)); vw > w {
+				w = vw
+			}
+		}
+		if found || b.Kind != ssa.BlockPlain {
+			return w
+		}
+		b = b.Succs[0].Block()
+	}
+	return 0
+}
@@ -580,6 +580,10 @@ func buildssa(fn *ir.Func, worker int) *ssa.Func {
 
 	s.insertPhis()
 
+	if PGOProfile != nil && base.Debug.PGOLayout != 0 {
+		setProfileBranchLikely(s.f, fn, PGOProfile)
+	}
+
 	// Main call to ssa package to compile function
 	ssa.Compile(s.f)
 
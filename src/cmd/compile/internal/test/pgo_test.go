@@ -0,0 +1,171 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"internal/profile"
+	"internal/testenv"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const pgoSrc = `
+package main
+
+type Shape interface{ Area() int }
+
+type Rect struct{ w, h int }
+
+func (r *Rect) Area() int {
+	return r.w * r.h // AREA
+}
+
+type Square struct{ s int }
+
+func (s Square) Area() int { return s.s * s.s }
+
+//go:noinline
+func sum(shapes []Shape) int {
+	t := 0
+	for _, s := range shapes {
+		t += s.Area() // CALLAREA
+	}
+	return t
+}
+
+func big(x int) int {
+	y := 0
+	for i := 0; i < x; i++ {
+		y += x*i ^ (y >> 1) // BIG
+		y += x*i ^ (y >> 2)
+		y += x*i ^ (y >> 3)
+		y += x*i ^ (y >> 4)
+		y += x*i ^ (y >> 5)
+		y += x*i ^ (y >> 6)
+		y += x*i ^ (y >> 7)
+		y += x*i ^ (y >> 8)
+		y += x*i ^ (y >> 9)
+		y += x*i ^ (y >> 10)
+	}
+	return y
+}
+
+func main() {
+	shapes := []Shape{&Rect{1, 2}, Square{3}}
+	println(sum(shapes)) // CALLSUM
+	println(big(10))     // CALLBIG
+}
+`
+
+// lineOf returns the line number of the line of src that ends with
+// the comment marker.
+func lineOf(t *testing.T, src, marker string) int64 {
+	for i, line := range strings.Split(src, "\n") {
+		if strings.HasSuffix(line, "// "+marker) {
+			return int64(i + 1)
+		}
+	}
+	t.Fatalf("marker %s not found", marker)
+	return 0
+}
+
+// writePGOProfile writes a synthetic CPU profile for pgoSrc in which
+// the call to (*Rect).Area in sum and the call to big in main are hot.
+func writePGOProfile(t *testing.T, file string) {
+	var funcs []*profile.Function
+	fn := func(name string) *profile.Function {
+		f := &profile.Function{ID: uint64(len(funcs) + 1), Name: name, Filename: "x.go"}
+		funcs = append(funcs, f)
+		return f
+	}
+	area, sum, big, main := fn("main.(*Rect).Area"), fn("main.sum"), fn("main.big"), fn("main.main")
+
+	var locs []*profile.Location
+	loc := func(f *profile.Function, marker string) *profile.Location {
+		l := &profile.Location{
+			ID:   uint64(len(locs) + 1),
+			Line: []profile.Line{{Function: f, Line: lineOf(t, pgoSrc, marker)}},
+		}
+		locs = append(locs, l)
+		return l
+	}
+	locArea, locCallArea := loc(area, "AREA"), loc(sum, "CALLAREA")
+	locBig, locCallBig := loc(big, "BIG"), loc(main, "CALLBIG")
+	locCallSum := loc(main, "CALLSUM")
+
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:     10000000,
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{locArea, locCallArea, locCallSum}, Value: []int64{100, 1000000000}},
+			{Location: []*profile.Location{locBig, locCallBig}, Value: []int64{100, 1000000000}},
+		},
+		Location: locs,
+		Function: funcs,
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := p.Write(f); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPGO checks that a profile makes the compiler devirtualize a hot
+// interface call and inline a hot call to a function whose cost is
+// over the ordinary inlining budget.
+func TestPGO(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+	t.Parallel()
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "x.go")
+	if err := os.WriteFile(src, []byte(pgoSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prof := filepath.Join(dir, "cpu.pprof")
+	writePGOProfile(t, prof)
+
+	compile := func(args ...string) string {
+		args = append([]string{"tool", "compile", "-p=main", "-m", "-o", filepath.Join(dir, "x.o")}, args...)
+		cmd := exec.Command(testenv.GoToolPath(t), append(args, src)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", cmd, err, out)
+		}
+		return string(out)
+	}
+
+	wants := []string{
+		"PGO devirtualizing s.Area to *Rect",
+		"inlining call to (*Rect).Area",
+		"hot call to big with cost",
+		"inlining call to big",
+	}
+
+	out := compile()
+	for _, want := range wants {
+		if strings.Contains(out, want) {
+			t.Errorf("without profile, output contains %q:\n%s", want, out)
+		}
+	}
+
+	out = compile("-pgoprofile="+prof, "-d=pgoinline=2")
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("with profile, output does not contain %q:\n%s", want, out)
+		}
+	}
+}
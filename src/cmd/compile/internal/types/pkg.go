@@ -68,6 +68,17 @@ func ImportedPkgList() []*Pkg {
 	return list
 }
 
+// PkgByPrefix returns the known package whose symbol prefix is prefix,
+// or nil if there is no such package.
+func PkgByPrefix(prefix string) *Pkg {
+	for _, p := range pkgMap {
+		if p.Prefix == prefix {
+			return p
+		}
+	}
+	return nil
+}
+
 type byPath []*Pkg
 
 func (a byPath) Len() int           { return len(a) }
@@ -0,0 +1,35 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Covdata is a program for manipulating the coverage data files written
+by programs built with 'go build -cover'.
+
+An instrumented program writes a coverage data file to the directory
+named by the GOCOVERDIR environment variable each time it runs.
+Covdata reads the data files in one or more such directories and
+combines them.
+
+Usage:
+
+	go tool covdata <mode> -i=<dir1,dir2,...> [flags]
+
+The modes are:
+
+	merge      merge the data files in the input directories into a
+	           single data file in the directory named by -o
+	subtract   write the data in the first input directory, less the
+	           blocks executed in the others, to the directory named by -o
+	intersect  write the data in the first input directory, keeping only
+	           the blocks executed in all the others, to the directory
+	           named by -o
+	textfmt    write the merged data in the input directories as a
+	           coverage profile to the file named by -o, in the format
+	           written by 'go test -coverprofile'
+	percent    print the percentage of statements covered for each
+	           package named by -pkg, or for all code if -pkg is not set
+
+The profile written by textfmt can be viewed with 'go tool cover'.
+*/
+package main
@@ -0,0 +1,143 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"internal/coverage"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	inputFlag  = flag.String("i", "", "comma-separated list of input `directories`")
+	outputFlag = flag.String("o", "", "output directory, or output file for textfmt")
+	pkgFlag    = flag.String("pkg", "", "report only package `path` (percent)")
+)
+
+var modes = map[string]func(){
+	"merge":     merge,
+	"subtract":  subtract,
+	"intersect": intersect,
+	"textfmt":   textfmt,
+	"percent":   percent,
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: go tool covdata <mode> -i=<dir1,dir2,...> [flags]\n")
+	fmt.Fprintf(os.Stderr, "modes: merge, subtract, intersect, textfmt, percent\n")
+	fmt.Fprintf(os.Stderr, "run 'go doc cmd/covdata' for details\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("covdata: ")
+
+	flag.Usage = usage
+	if len(os.Args) < 2 {
+		usage()
+	}
+	run, ok := modes[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "covdata: unknown mode %q\n", os.Args[1])
+		usage()
+	}
+	flag.CommandLine.Parse(os.Args[2:])
+	if flag.NArg() != 0 || *inputFlag == "" {
+		usage()
+	}
+	run()
+}
+
+// inputs reads the coverage data in each of the input directories.
+func inputs() []*coverage.Profile {
+	var profs []*coverage.Profile
+	for _, dir := range strings.Split(*inputFlag, ",") {
+		p, err := coverage.ReadDir(dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		profs = append(profs, p)
+	}
+	return profs
+}
+
+// merged returns the merged coverage data in the input directories.
+func merged() *coverage.Profile {
+	profs := inputs()
+	for _, q := range profs[1:] {
+		if err := profs[0].Merge(q); err != nil {
+			log.Fatal(err)
+		}
+	}
+	return profs[0]
+}
+
+// writeOutput writes p as a single data file in the output directory.
+func writeOutput(p *coverage.Profile) {
+	if *outputFlag == "" {
+		log.Fatal("missing -o output directory")
+	}
+	if err := os.MkdirAll(*outputFlag, 0777); err != nil {
+		log.Fatal(err)
+	}
+	suffix := strconv.Itoa(os.Getpid()) + "." + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := coverage.WriteFile(*outputFlag, suffix, p); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func merge() {
+	writeOutput(merged())
+}
+
+func subtract() {
+	profs := inputs()
+	for _, q := range profs[1:] {
+		profs[0].Subtract(q)
+	}
+	writeOutput(profs[0])
+}
+
+func intersect() {
+	profs := inputs()
+	for _, q := range profs[1:] {
+		profs[0].Intersect(q)
+	}
+	writeOutput(profs[0])
+}
+
+func textfmt() {
+	if *outputFlag == "" {
+		log.Fatal("missing -o output file")
+	}
+	f, err := os.Create(*outputFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := merged().Write(f); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func percent() {
+	p := merged()
+	pkgs := p.Packages()
+	if *pkgFlag != "" {
+		pkgs = []string{*pkgFlag}
+	}
+	for _, pkg := range pkgs {
+		fmt.Printf("%s\tcoverage: %.1f%% of statements\n", pkg, p.Percent(pkg))
+	}
+}
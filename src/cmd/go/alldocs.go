@@ -30,6 +30,7 @@
 // 	tool        run specified go tool
 // 	version     print Go version
 // 	vet         report likely mistakes in packages
+// 	work        workspace maintenance
 //
 // Use "go help <command>" for more information about a command.
 //
@@ -101,6 +102,24 @@
 // The -i flag installs the packages that are dependencies of the target.
 // The -i flag is deprecated. Compiled packages are cached automatically.
 //
+// The -cover flag, also accepted by install and run, builds a program
+// instrumented for coverage analysis. Each time the program exits, it
+// writes a coverage data file to the directory named by the GOCOVERDIR
+// environment variable. Use 'go tool covdata' to merge the data files
+// and convert them into the profile format written by
+// 'go test -coverprofile'. By default, only the packages named on the
+// command line, or in the main modules when building a module-aware
+// program, are instrumented. The related flags are:
+//
+// 	-covermode set,count,atomic
+// 		set the mode for coverage analysis, as for 'go test'.
+// 		The default is "set" unless -race is enabled,
+// 		in which case it is "atomic".
+// 		Setting -covermode implies -cover.
+// 	-coverpkg pattern1,pattern2,pattern3
+// 		instrument the packages matching the patterns
+// 		instead of the default set. Setting -coverpkg implies -cover.
+//
 // The build flags are shared by the build, clean, get, install, list, run,
 // and test commands:
 //
@@ -178,6 +197,11 @@
 // 		include path must be in the same directory as the Go package they are
 // 		included from, and overlays will not appear when binaries and tests are
 // 		run through go run and go test respectively.
+// 	-pgo file
+// 		specify the file path of a profile for profile-guided optimization (PGO).
+// 		The profile must be a CPU profile in the pprof format, such as
+// 		one collected by runtime/pprof or net/http/pprof.
+// 		The special name "off" turns off PGO, which is the default.
 // 	-pkgdir dir
 // 		install and load all packages from dir instead of the usual locations.
 // 		For example, when building with a non-standard configuration,
@@ -284,6 +308,13 @@
 // download cache, including unpacked source code of versioned
 // dependencies.
 //
+// The -fuzzcache flag causes clean to remove files stored in the Go build
+// cache for fuzz testing. The fuzzing engine caches files that expand
+// code coverage, so removing them may make fuzzing less effective until
+// new inputs are found that provide the same coverage. These files are
+// distinct from those stored in testdata directory; clean does not remove
+// those files.
+//
 // For more about build flags, see 'go help build'.
 //
 // For more about specifying packages, see 'go help packages'.
@@ -1569,6 +1600,206 @@
 // See also: go fmt, go fix.
 //
 //
+// Workspace maintenance
+//
+// Go work provides access to operations on workspaces.
+//
+// Note that support for workspaces is built into many other commands,
+// not just 'go work'. See 'go help modules' for information about Go's
+// module system, of which workspaces are a part.
+//
+// A workspace is described by a go.work file, which lists a set of module
+// directories with "use" directives. The go command treats all of those
+// modules as main modules: packages in any of them may be named on the
+// command line, and imports of their packages are resolved to the local
+// directories rather than to versions listed in go.mod files. This makes
+// it possible to change several modules together without adding and later
+// removing replace directives.
+//
+// go.work files are line-oriented, and use the same syntax as go.mod files.
+// Each line holds a single directive, made up of a keyword followed by
+// arguments. For example:
+//
+// 	go 1.18
+//
+// 	use ./hello
+// 	use ../example/util
+//
+// 	replace golang.org/x/net v1.2.3 => ./net
+//
+// As in go.mod files, the leading keyword can be factored out of adjacent
+// lines to create a block:
+//
+// 	use (
+// 		./hello
+// 		../example/util
+// 	)
+//
+// The use directive adds the module whose go.mod file is in the given
+// directory to the workspace. Relative directories are interpreted
+// relative to the directory containing the go.work file.
+//
+// The go directive records the Go version the file was written for.
+//
+// The replace directive has the same syntax as in a go.mod file. Replacements
+// in the go.work file take precedence over those in the go.mod files of the
+// workspace modules, and must be used to resolve conflicting replacements
+// of the same module by different workspace modules.
+//
+// The go command looks for a go.work file in the current directory and
+// its parent directories, unless the GOWORK environment variable names the
+// file to use or is set to "off" to disable workspace mode. Use
+// 'go env GOWORK' to see which go.work file, if any, is in use.
+//
+// In workspace mode, the go.mod files of the workspace modules are never
+// modified, and commands that exist to update a single go.mod file
+// ('go get', 'go mod tidy' and 'go mod vendor') are not available.
+// Checksums needed by the workspace that are not present in the go.sum
+// files of its modules are recorded in a go.work.sum file next to go.work.
+//
+// Usage:
+//
+// 	go work <command> [arguments]
+//
+// The commands are:
+//
+// 	edit        edit go.work from tools or scripts
+// 	init        initialize workspace file
+// 	sync        sync workspace build list to modules
+// 	use         add modules to workspace file
+//
+// Use "go help work <command>" for more information about a command.
+//
+// Edit go.work from tools or scripts
+//
+// Usage:
+//
+// 	go work edit [editing flags] [go.work]
+//
+// Edit provides a command-line interface for editing go.work,
+// for use primarily by tools or scripts. It only reads go.work;
+// it does not look up information about the modules involved.
+// If no file is specified, Edit looks for a go.work file in the current
+// directory and its parent directories.
+//
+// The editing flags specify a sequence of editing operations.
+//
+// The -fmt flag reformats the go.work file without making other changes.
+// This reformatting is also implied by any other modifications that use or
+// rewrite the go.work file. The only time this flag is needed is if no other
+// flags are specified, as in 'go work edit -fmt'.
+//
+// The -use=path and -dropuse=path flags
+// add and drop a use directive for the given module directory.
+//
+// The -replace=old[@v]=new[@v] flag adds a replacement of the given
+// module path and version pair. If the @v in old@v is omitted, a
+// replacement without a version on the left side is added, which applies
+// to all versions of the old module path. If the @v in new@v is omitted,
+// the new path should be a local module root directory, not a module
+// path. Note that -replace overrides any redundant replacements for old[@v],
+// so omitting @v will drop existing replacements for specific versions.
+//
+// The -dropreplace=old[@v] flag drops a replacement of the given
+// module path and version pair. If the @v is omitted, a replacement without
+// a version on the left side is dropped.
+//
+// The -use, -dropuse, -replace, and -dropreplace editing flags may be
+// repeated, and the changes are applied in the order given.
+//
+// The -go=version flag sets the expected Go language version.
+//
+// The -print flag prints the final go.work in its text format instead of
+// writing it back to go.work.
+//
+// The -json flag prints the final go.work file in JSON format instead of
+// writing it back to go.work. The JSON output corresponds to these Go types:
+//
+// 	type Module struct {
+// 		Path    string
+// 		Version string
+// 	}
+//
+// 	type GoWork struct {
+// 		Go      string
+// 		Use     []Use
+// 		Replace []Replace
+// 	}
+//
+// 	type Use struct {
+// 		DiskPath string
+// 	}
+//
+// 	type Replace struct {
+// 		Old Module
+// 		New Module
+// 	}
+//
+// See 'go help work' for more about workspaces.
+//
+//
+// Initialize workspace file
+//
+// Usage:
+//
+// 	go work init [moddirs]
+//
+// Init initializes and writes a new go.work file in the current directory,
+// in effect creating a new workspace rooted at the current directory.
+//
+// Init optionally accepts the directories of the workspace modules as
+// arguments, and adds a use directive for each of them. If no arguments
+// are given, an empty workspace with no modules is created. The go
+// directive of the new file is set to the current Go version.
+//
+// See 'go help work' for more about workspaces.
+//
+//
+// Sync workspace build list to modules
+//
+// Usage:
+//
+// 	go work sync
+//
+// Sync syncs the workspace's build list back to the workspace's modules.
+//
+// The workspace's build list is the set of versions of all the (transitive)
+// dependency modules used to do builds in the workspace. It is computed
+// using minimal version selection over the requirements of all the
+// workspace modules together, so a module may be built in the workspace
+// with a higher version of a dependency than its own go.mod file requires.
+//
+// Sync upgrades each requirement in the go.mod file of each workspace module
+// to the version selected for the workspace, if that version is higher,
+// updating the module's go.mod and go.sum files as 'go get' would. Sync never
+// downgrades requirements, and does not add or remove requirements on the
+// other workspace modules.
+//
+// See 'go help work' for more about workspaces.
+//
+//
+// Add modules to workspace file
+//
+// Usage:
+//
+// 	go work use [-r] moddirs
+//
+// Use provides a command-line interface for adding directories,
+// optionally recursively, to a go.work file.
+//
+// A use directive is added to the go.work file for each argument directory
+// that contains a go.mod file, and any use directive for an argument
+// directory that no longer contains a go.mod file is removed.
+//
+// The -r flag searches recursively for modules in the argument directories,
+// and the use command operates as if each of the directories found had been
+// specified as an argument: use directives are added for the directories that
+// contain a go.mod file, and removed for listed directories within the
+// argument directories that no longer contain one.
+//
+// See 'go help work' for more about workspaces.
+//
+//
 // Build constraints
 //
 // A build constraint, also known as a build tag, is a line comment that begins
@@ -1792,6 +2023,9 @@
 // 	GOCACHE
 // 		The directory where the go command will store cached
 // 		information for reuse in future builds.
+// 	GOCOVERDIR
+// 		The directory into which programs built with 'go build -cover'
+// 		write their coverage data files.
 // 	GOMODCACHE
 // 		The directory where the go command will store downloaded modules.
 // 	GODEBUG
@@ -1837,6 +2071,14 @@
 // 	GOVCS
 // 		Lists version control commands that may be used with matching servers.
 // 		See 'go help vcs'.
+// 	GOWORK
+// 		In module aware mode, use the given go.work file as a workspace file.
+// 		By default or when GOWORK is "auto", the go command searches for a
+// 		file named go.work in the current directory and then containing
+// 		directories until one is found. If a valid go.work file is found,
+// 		the modules it lists are collectively used as the main modules.
+// 		If GOWORK is "off", or no go.work file is found in "auto" mode,
+// 		workspace mode is disabled. See 'go help work'.
 //
 // Environment variables for use with cgo:
 //
@@ -1941,6 +2183,8 @@
 // 		If module-aware mode is enabled, but there is no go.mod, GOMOD will be
 // 		os.DevNull ("/dev/null" on Unix-like systems, "NUL" on Windows).
 // 		If module-aware mode is disabled, GOMOD will be the empty string.
+// 		In workspace mode, GOMOD will be os.DevNull and GOWORK will be the
+// 		absolute path to the go.work file.
 // 	GOTOOLDIR
 // 		The directory where the go tools (compile, cover, doc, etc...) are installed.
 // 	GOVERSION
@@ -2659,6 +2903,28 @@
 // 	-failfast
 // 	    Do not start new tests after the first test failure.
 //
+// 	-fuzz regexp
+// 	    Run the fuzz target matching the regular expression. When specified,
+// 	    the command line argument must match exactly one package, and
+// 	    regexp must match exactly one fuzz target within that package. After tests, benchmarks, seed corpora of other fuzz
+// 	    targets, and examples have completed, the matching target will be
+// 	    fuzzed. See the Fuzzing section of the testing package
+// 	    documentation for details.
+//
+// 	-fuzztime t
+// 	    Run enough iterations of the fuzz target to take t, specified as a
+// 	    time.Duration (for example, -fuzztime 1h30s). The default is to run
+// 	    forever.
+// 	    The special syntax Nx means to run the fuzz target N times
+// 	    (for example, -fuzztime 100x).
+//
+// 	-fuzzminimizetime t
+// 	    Run enough iterations of the fuzz target during each minimization
+// 	    attempt to take t, as specified as a time.Duration (for example,
+// 	    -fuzzminimizetime 30s). The default is 60s.
+// 	    The special syntax Nx means to run the fuzz target N times
+// 	    (for example, -fuzzminimizetime 100x).
+//
 // 	-json
 // 	    Log verbose output and test results in JSON. This presents the
 // 	    same information as the -v flag in a machine-readable format.
@@ -2678,7 +2944,8 @@
 // 	    (see 'go help build').
 //
 // 	-run regexp
-// 	    Run only those tests and examples matching the regular expression.
+// 	    Run only those tests, examples, and fuzz targets matching the
+// 	    regular expression.
 // 	    For tests, the regular expression is split by unbracketed slash (/)
 // 	    characters into a sequence of regular expressions, and each part
 // 	    of a test's identifier must match the corresponding element in
@@ -2686,6 +2953,8 @@
 // 	    run too, so that -run=X/Y matches and runs and reports the result
 // 	    of all tests matching X, even those without sub-tests matching Y,
 // 	    because it must run them to look for those sub-tests.
+// 	    For fuzz targets, -run only runs the seed corpus; use -fuzz to
+// 	    generate and mutate new inputs.
 //
 // 	-short
 // 	    Tell long-running tests to shorten their run time.
@@ -2836,8 +3105,8 @@
 //
 // Testing functions
 //
-// The 'go test' command expects to find test, benchmark, and example functions
-// in the "*_test.go" files corresponding to the package under test.
+// The 'go test' command expects to find test, benchmark, fuzz, and example
+// functions in the "*_test.go" files corresponding to the package under test.
 //
 // A test function is one named TestXxx (where Xxx does not start with a
 // lower case letter) and should have the signature,
@@ -2848,6 +3117,10 @@
 //
 // 	func BenchmarkXxx(b *testing.B) { ... }
 //
+// A fuzz target is one named FuzzXxx and should have the signature,
+//
+// 	func FuzzXxx(f *testing.F) { ... }
+//
 // An example function is similar to a test function but, instead of using
 // *testing.T to report success or failure, prints output to os.Stdout.
 // If the last comment in the function starts with "Output:" then the output
@@ -252,6 +252,16 @@ func (c *Cache) OutputFile(out OutputID) string {
 	return file
 }
 
+// FuzzDir returns a subdirectory within the cache for storing fuzzing data.
+// The subdirectory may not exist.
+//
+// This directory is managed by the internal/fuzz package. Files in this
+// directory aren't removed by the 'go clean -cache' command or by Trim.
+// They may be removed with 'go clean -fuzzcache'.
+func (c *Cache) FuzzDir() string {
+	return filepath.Join(c.dir, "fuzz")
+}
+
 // Time constants for cache expiration.
 //
 // We set the mtime on a cache file on each use, but at most one per mtimeInterval (1 hour),
@@ -498,6 +498,9 @@ General-purpose environment variables:
 	GOCACHE
 		The directory where the go command will store cached
 		information for reuse in future builds.
+	GOCOVERDIR
+		The directory into which programs built with 'go build -cover'
+		write their coverage data files.
 	GOMODCACHE
 		The directory where the go command will store downloaded modules.
 	GODEBUG
@@ -543,6 +546,14 @@ General-purpose environment variables:
 	GOVCS
 		Lists version control commands that may be used with matching servers.
 		See 'go help vcs'.
+	GOWORK
+		In module aware mode, use the given go.work file as a workspace file.
+		By default or when GOWORK is "auto", the go command searches for a
+		file named go.work in the current directory and then containing
+		directories until one is found. If a valid go.work file is found,
+		the modules it lists are collectively used as the main modules.
+		If GOWORK is "off", or no go.work file is found in "auto" mode,
+		workspace mode is disabled. See 'go help work'.
 
 Environment variables for use with cgo:
 
@@ -647,6 +658,8 @@ Additional information available from 'go env' but not read from the environment
 		If module-aware mode is enabled, but there is no go.mod, GOMOD will be
 		os.DevNull ("/dev/null" on Unix-like systems, "NUL" on Windows).
 		If module-aware mode is disabled, GOMOD will be the empty string.
+		In workspace mode, GOMOD will be os.DevNull and GOWORK will be the
+		absolute path to the go.work file.
 	GOTOOLDIR
 		The directory where the go tools (compile, cover, doc, etc...) are installed.
 	GOVERSION
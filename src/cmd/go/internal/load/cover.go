@@ -0,0 +1,135 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file contains support for "go build -cover".
+
+package load
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/cfg"
+)
+
+// CoverRuntimePkg is the package that collects the coverage counters
+// of a program built with "go build -cover" and writes them out when
+// the program exits.
+const CoverRuntimePkg = "internal/coverage/cfile"
+
+// DeclareCoverVars attaches the required cover variables names
+// to the files, to be used when annotating the files.
+func DeclareCoverVars(p *Package, files ...string) map[string]*CoverVar {
+	coverVars := make(map[string]*CoverVar)
+	coverIndex := 0
+	// We create the cover counters as new top-level variables in the package.
+	// We need to avoid collisions with user variables (GoCover_0 is unlikely but still)
+	// and more importantly with dot imports of other covered packages,
+	// so we append 12 hex digits from the SHA-256 of the import path.
+	// The point is only to avoid accidents, not to defeat users determined to
+	// break things.
+	sum := sha256.Sum256([]byte(p.ImportPath))
+	h := fmt.Sprintf("%x", sum[:6])
+	for _, file := range files {
+		if base.IsTestFile(file) {
+			continue
+		}
+		// For a package that is "local" (imported via ./ import or command line, outside GOPATH),
+		// we record the full path to the file name.
+		// Otherwise we record the import path, then a forward slash, then the file name.
+		// This makes profiles within GOPATH file system-independent.
+		// These names appear in the cmd/cover HTML interface.
+		var longFile string
+		if p.Internal.Local {
+			longFile = filepath.Join(p.Dir, file)
+		} else {
+			longFile = path.Join(p.ImportPath, file)
+		}
+		coverVars[file] = &CoverVar{
+			File: longFile,
+			Var:  fmt.Sprintf("GoCover_%d_%x", coverIndex, h),
+		}
+		coverIndex++
+	}
+	return coverVars
+}
+
+// PrepareForCoverageBuild marks the packages among pkgs and their
+// dependencies that "go build -cover" should instrument, and makes
+// each of them import the coverage runtime.
+//
+// If -coverpkg was given, the packages matching its patterns are
+// instrumented. Otherwise, the packages named on the command line and
+// the packages in the main modules are.
+func PrepareForCoverageBuild(pkgs []*Package) {
+	var match []func(*Package) bool
+	if len(cfg.BuildCoverPkg) != 0 {
+		match = make([]func(*Package) bool, len(cfg.BuildCoverPkg))
+		for i, pattern := range cfg.BuildCoverPkg {
+			match[i] = MatchPackage(pattern, base.Cwd())
+		}
+	} else {
+		match = []func(*Package) bool{func(p *Package) bool {
+			return p.Internal.CmdlinePkg || p.Internal.CmdlineFiles || p.Module != nil && p.Module.Main
+		}}
+	}
+
+	rt := LoadImportWithFlags(CoverRuntimePkg, cfg.GOROOTsrc, nil, &ImportStack{}, nil, 0)
+	if rt.Error != nil {
+		base.Fatalf("load %s: %v", CoverRuntimePkg, rt.Error)
+	}
+
+	// Instrumenting a package that the coverage runtime depends on
+	// would create an import cycle, so those packages are never covered.
+	// This also excludes sync/atomic, which the atomic mode relies on.
+	isRuntimeDep := make(map[*Package]bool)
+	for _, p := range PackageList([]*Package{rt}) {
+		isRuntimeDep[p] = true
+	}
+
+	for _, p := range PackageList(pkgs) {
+		if isRuntimeDep[p] || p.ImportPath == "unsafe" || len(p.GoFiles)+len(p.CgoFiles) == 0 {
+			continue
+		}
+		matched := false
+		for _, m := range match {
+			if m(p) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		p.Internal.CoverMode = cfg.BuildCoverMode
+		var coverFiles []string
+		coverFiles = append(coverFiles, p.GoFiles...)
+		coverFiles = append(coverFiles, p.CgoFiles...)
+		p.Internal.CoverVars = DeclareCoverVars(p, coverFiles...)
+
+		addCoverImport(p, rt)
+		if cfg.BuildCoverMode == "atomic" {
+			atomic := LoadImportWithFlags("sync/atomic", cfg.GOROOTsrc, nil, &ImportStack{}, nil, 0)
+			if atomic.Error != nil {
+				base.Fatalf("load sync/atomic: %v", atomic.Error)
+			}
+			addCoverImport(p, atomic)
+		}
+	}
+}
+
+// addCoverImport adds an import of dep, which the instrumented source
+// files of p refer to, to p.
+func addCoverImport(p, dep *Package) {
+	for _, d := range p.Internal.Imports {
+		if d == dep {
+			return
+		}
+	}
+	p.Internal.Imports = append(p.Internal.Imports, dep)
+}
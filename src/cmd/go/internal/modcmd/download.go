@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"os"
 	"runtime"
+	"strings"
 
 	"cmd/go/internal/base"
 	"cmd/go/internal/cfg"
@@ -91,12 +92,13 @@ func runDownload(ctx context.Context, cmd *base.Command, args []string) {
 		args = []string{"all"}
 	}
 	if modload.HasModRoot() {
-		modload.LoadModFile(ctx) // to fill Target
-		targetAtUpgrade := modload.Target.Path + "@upgrade"
-		targetAtPatch := modload.Target.Path + "@patch"
+		modload.LoadModFile(ctx) // to fill MainModules
 		for _, arg := range args {
-			switch arg {
-			case modload.Target.Path, targetAtUpgrade, targetAtPatch:
+			path := arg
+			if i := strings.Index(arg, "@"); i >= 0 && (arg[i+1:] == "upgrade" || arg[i+1:] == "patch") {
+				path = arg[:i]
+			}
+			if modload.MainModules.Contains(path) {
 				os.Stderr.WriteString("go mod download: skipping argument " + arg + " that resolves to the main module\n")
 			}
 		}
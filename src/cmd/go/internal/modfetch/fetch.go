@@ -386,6 +386,11 @@ func RemoveAll(dir string) error {
 
 var GoSumFile string // path to go.sum; set by package modload
 
+// WorkspaceGoSumFiles lists the go.sum files of the modules in a workspace,
+// set by package modload in workspace mode. Their checksums are trusted, but
+// they are never written: new checksums are recorded in GoSumFile instead.
+var WorkspaceGoSumFiles []string
+
 type modSum struct {
 	mod module.Version
 	sum string
@@ -394,6 +399,7 @@ type modSum struct {
 var goSum struct {
 	mu        sync.Mutex
 	m         map[module.Version][]string // content of go.sum file
+	w         map[module.Version][]string // content of WorkspaceGoSumFiles
 	status    map[modSum]modSumStatus     // state of sums in m
 	overwrite bool                        // if true, overwrite go.sum without incorporating its contents
 	enabled   bool                        // whether to use go.sum at all
@@ -435,6 +441,15 @@ func initGoSum() (bool, error) {
 	goSum.enabled = true
 	readGoSum(goSum.m, GoSumFile, data)
 
+	goSum.w = make(map[module.Version][]string)
+	for _, f := range WorkspaceGoSumFiles {
+		data, err := lockedfile.Read(f)
+		if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		readGoSum(goSum.w, f, data)
+	}
+
 	return true, nil
 }
 
@@ -493,6 +508,11 @@ func HaveSum(mod module.Version) bool {
 			return true
 		}
 	}
+	for _, h := range goSum.w[mod] {
+		if strings.HasPrefix(h, "h1:") {
+			return true
+		}
+	}
 	return false
 }
 
@@ -610,6 +630,14 @@ func haveModSumLocked(mod module.Version, h string) bool {
 			base.Fatalf("verifying %s@%s: checksum mismatch\n\tdownloaded: %v\n\tgo.sum:     %v"+goSumMismatch, mod.Path, mod.Version, h, vh)
 		}
 	}
+	for _, vh := range goSum.w[mod] {
+		if h == vh {
+			return true
+		}
+		if strings.HasPrefix(vh, "h1:") {
+			base.Fatalf("verifying %s@%s: checksum mismatch\n\tdownloaded: %v\n\tgo.sum:     %v"+goSumMismatch, mod.Path, mod.Version, h, vh)
+		}
+	}
 	return false
 }
 
@@ -781,6 +809,20 @@ Outer:
 	goSum.overwrite = false
 }
 
+// ResetGoSum discards the checksums read from GoSumFile and
+// WorkspaceGoSumFiles, so that they are read again when next needed.
+// Any checksums not yet written by WriteGoSum are lost.
+func ResetGoSum() {
+	goSum.mu.Lock()
+	defer goSum.mu.Unlock()
+
+	goSum.m = nil
+	goSum.w = nil
+	goSum.status = nil
+	goSum.overwrite = false
+	goSum.enabled = false
+}
+
 // TrimGoSum trims go.sum to contain only the modules needed for reproducible
 // builds.
 //
@@ -212,20 +212,20 @@ func addDeprecation(ctx context.Context, m *modinfo.ModulePublic) {
 // in rs (which may be nil to indicate that m was not loaded from a requirement
 // graph).
 func moduleInfo(ctx context.Context, rs *Requirements, m module.Version, mode ListMode) *modinfo.ModulePublic {
-	if m == Target {
+	if m.Version == "" && MainModules.Contains(m.Path) {
 		info := &modinfo.ModulePublic{
 			Path:    m.Path,
 			Version: m.Version,
 			Main:    true,
 		}
-		if v, ok := rawGoVersion.Load(Target); ok {
+		if v, ok := rawGoVersion.Load(m); ok {
 			info.GoVersion = v.(string)
 		} else {
 			panic("internal error: GoVersion not set for main module")
 		}
-		if HasModRoot() {
-			info.Dir = ModRoot()
-			info.GoMod = ModFilePath()
+		if root := MainModules.ModRoot(m); root != "" {
+			info.Dir = root
+			info.GoMod = modFilePath(root)
 		}
 		return info
 	}
@@ -397,7 +397,7 @@ func mustFindModule(ld *loader, target, path string) module.Version {
 	}
 
 	if path == "command-line-arguments" {
-		return Target
+		return commandLineArgumentsModule()
 	}
 
 	base.Fatalf("build %v: cannot find module for path %v", target, path)
@@ -412,11 +412,22 @@ func findModule(ld *loader, path string) (module.Version, bool) {
 		return pkg.mod, pkg.mod != module.Version{}
 	}
 	if path == "command-line-arguments" {
-		return Target, true
+		return commandLineArgumentsModule(), true
 	}
 	return module.Version{}, false
 }
 
+// commandLineArgumentsModule returns the main module to which the synthesized
+// "command-line-arguments" package is attributed. In workspace mode, that is
+// the main module containing the current directory, or else the first module
+// listed in the go.work file.
+func commandLineArgumentsModule() module.Version {
+	if m, _, ok := MainModules.mainModuleForDir(base.Cwd()); ok {
+		return m
+	}
+	return MainModules.Versions()[0]
+}
+
 func ModInfoProg(info string, isgccgo bool) []byte {
 	// Inject a variable with the debug information as runtime.modinfo,
 	// but compile it in package main so that it is specific to the binary.
@@ -42,6 +42,9 @@ type Requirements struct {
 	// rootModules is the set of module versions explicitly required by the main
 	// module, sorted and capped to length. It may contain duplicates, and may
 	// contain multiple versions for a given module path.
+	//
+	// In workspace mode, rootModules is the union of the requirements of all
+	// of the main modules.
 	rootModules    []module.Version
 	maxRootVersion map[string]string
 
@@ -99,8 +102,8 @@ var requirements *Requirements
 // *Requirements before any other method.
 func newRequirements(depth modDepth, rootModules []module.Version, direct map[string]bool) *Requirements {
 	for i, m := range rootModules {
-		if m == Target {
-			panic(fmt.Sprintf("newRequirements called with untrimmed build list: rootModules[%v] is Target", i))
+		if m.Version == "" && MainModules.Contains(m.Path) {
+			panic(fmt.Sprintf("newRequirements called with untrimmed build list: rootModules[%v] is a main module", i))
 		}
 		if m.Path == "" || m.Version == "" {
 			panic(fmt.Sprintf("bad requirement: rootModules[%v] = %v", i, m))
@@ -134,8 +137,9 @@ func newRequirements(depth modDepth, rootModules []module.Version, direct map[st
 // requirements.
 func (rs *Requirements) initVendor(vendorList []module.Version) {
 	rs.graphOnce.Do(func() {
+		mainModule := MainModules.MustGetSingleMainModule()
 		mg := &ModuleGraph{
-			g: mvs.NewGraph(cmpVersion, []module.Version{Target}),
+			g: mvs.NewGraph(cmpVersion, []module.Version{mainModule}),
 		}
 
 		if rs.depth == lazy {
@@ -158,7 +162,7 @@ func (rs *Requirements) initVendor(vendorList []module.Version) {
 			// Now we can treat the rest of the module graph as effectively “pruned
 			// out”, like a more aggressive version of lazy loading: in vendor mode,
 			// the root requirements *are* the complete module graph.
-			mg.g.Require(Target, rs.rootModules)
+			mg.g.Require(mainModule, rs.rootModules)
 		} else {
 			// The transitive requirements of the main module are not in general available
 			// from the vendor directory, and we don't actually know how we got from
@@ -170,7 +174,7 @@ func (rs *Requirements) initVendor(vendorList []module.Version) {
 			// graph, but still distinguishes between direct and indirect
 			// dependencies.
 			vendorMod := module.Version{Path: "vendor/modules.txt", Version: ""}
-			mg.g.Require(Target, append(rs.rootModules, vendorMod))
+			mg.g.Require(mainModule, append(rs.rootModules, vendorMod))
 			mg.g.Require(vendorMod, vendorList)
 		}
 
@@ -182,8 +186,8 @@ func (rs *Requirements) initVendor(vendorList []module.Version) {
 // path, or the zero module.Version and ok=false if the module is not a root
 // dependency.
 func (rs *Requirements) rootSelected(path string) (version string, ok bool) {
-	if path == Target.Path {
-		return Target.Version, true
+	if MainModules.Contains(path) {
+		return "", true
 	}
 	if v, ok := rs.maxRootVersion[path]; ok {
 		return v, true
@@ -197,7 +201,7 @@ func (rs *Requirements) rootSelected(path string) (version string, ok bool) {
 // selection.
 func (rs *Requirements) hasRedundantRoot() bool {
 	for i, m := range rs.rootModules {
-		if m.Path == Target.Path || (i > 0 && m.Path == rs.rootModules[i-1].Path) {
+		if MainModules.Contains(m.Path) || (i > 0 && m.Path == rs.rootModules[i-1].Path) {
 			return true
 		}
 	}
@@ -274,10 +278,18 @@ func readModGraph(ctx context.Context, depth modDepth, roots []module.Version) (
 		mu       sync.Mutex // guards mg.g and hasError during loading
 		hasError bool
 		mg       = &ModuleGraph{
-			g: mvs.NewGraph(cmpVersion, []module.Version{Target}),
+			g: mvs.NewGraph(cmpVersion, graphRoots()),
 		}
 	)
-	mg.g.Require(Target, roots)
+	if depth == workspace {
+		// Each main module requires only the modules listed in its own go.mod
+		// file; roots is the union of those requirements.
+		for _, mm := range MainModules.Versions() {
+			mg.g.Require(mm, MainModules.requirements[mm])
+		}
+	} else {
+		mg.g.Require(graphRoots()[0], roots)
+	}
 
 	var (
 		loadQueue    = par.NewQueue(runtime.GOMAXPROCS(0))
@@ -339,8 +351,16 @@ func readModGraph(ctx context.Context, depth modDepth, roots []module.Version) (
 		})
 	}
 
-	for _, m := range roots {
-		enqueue(m, depth)
+	if depth == workspace {
+		for _, mm := range MainModules.Versions() {
+			for _, m := range MainModules.requirements[mm] {
+				enqueue(m, MainModules.depth[mm])
+			}
+		}
+	} else {
+		for _, m := range roots {
+			enqueue(m, depth)
+		}
 	}
 	<-loadQueue.Idle()
 
@@ -375,7 +395,7 @@ func (mg *ModuleGraph) WalkBreadthFirst(f func(m module.Version)) {
 }
 
 // BuildList returns the selected versions of all modules present in the graph,
-// beginning with Target.
+// beginning with the main modules.
 //
 // The order of the remaining elements in the list is deterministic
 // but arbitrary.
@@ -403,11 +423,23 @@ func (mg *ModuleGraph) findError() error {
 	return nil
 }
 
+// graphRoots returns the roots of the module graph: the main modules or, if
+// they have not been loaded (as in tests that construct Requirements
+// directly), the zero module.Version, which stands in for the main module.
+func graphRoots() []module.Version {
+	if MainModules.Len() == 0 {
+		return []module.Version{{}}
+	}
+	return MainModules.Versions()
+}
+
 func (mg *ModuleGraph) allRootsSelected() bool {
-	roots, _ := mg.g.RequiredBy(Target)
-	for _, m := range roots {
-		if mg.Selected(m.Path) != m.Version {
-			return false
+	for _, mm := range graphRoots() {
+		roots, _ := mg.g.RequiredBy(mm)
+		for _, m := range roots {
+			if mg.Selected(m.Path) != m.Version {
+				return false
+			}
 		}
 	}
 	return true
@@ -428,7 +460,7 @@ func LoadModGraph(ctx context.Context, goVersion string) *ModuleGraph {
 
 	if goVersion != "" {
 		depth := modDepthFromGoVersion(goVersion)
-		if depth == eager && rs.depth != eager {
+		if depth == eager && rs.depth == lazy {
 			// Use newRequirements instead of convertDepth because convertDepth
 			// also updates roots; here, we want to report the unmodified roots
 			// even though they may seem inconsistent.
@@ -553,12 +585,27 @@ func tidyRoots(ctx context.Context, rs *Requirements, pkgs []*loadPkg) (*Require
 }
 
 func updateRoots(ctx context.Context, direct map[string]bool, rs *Requirements, pkgs []*loadPkg, add []module.Version, rootsImported bool) (*Requirements, error) {
-	if rs.depth == eager {
+	switch rs.depth {
+	case eager:
 		return updateEagerRoots(ctx, direct, rs, add)
+	case workspace:
+		return updateWorkspaceRoots(rs, add)
 	}
 	return updateLazyRoots(ctx, direct, rs, pkgs, add, rootsImported)
 }
 
+// updateWorkspaceRoots returns rs unchanged: in workspace mode the roots are
+// the requirements of the main modules' go.mod files, which are never
+// modified.
+func updateWorkspaceRoots(rs *Requirements, add []module.Version) (*Requirements, error) {
+	if len(add) > 0 {
+		// Adding requirements would require updating the go.mod file of some
+		// main module, but we don't know which one.
+		return rs, fmt.Errorf("cannot add requirements in workspace mode; add them to the go.mod file of a workspace module instead: %v", add)
+	}
+	return rs, nil
+}
+
 // tidyLazyRoots returns a minimal set of root requirements that maintains the
 // "lazy loading" invariants of the go.mod file for the given packages:
 //
@@ -577,7 +624,7 @@ func updateRoots(ctx context.Context, direct map[string]bool, rs *Requirements,
 func tidyLazyRoots(ctx context.Context, direct map[string]bool, pkgs []*loadPkg) (*Requirements, error) {
 	var (
 		roots        []module.Version
-		pathIncluded = map[string]bool{Target.Path: true}
+		pathIncluded = map[string]bool{MainModules.MustGetSingleMainModule().Path: true}
 	)
 	// We start by adding roots for every package in "all".
 	//
@@ -855,7 +902,7 @@ func updateLazyRoots(ctx context.Context, direct map[string]bool, rs *Requiremen
 		roots = make([]module.Version, 0, len(rs.rootModules))
 		rootsUpgraded = false
 		inRootPaths := make(map[string]bool, len(rs.rootModules)+1)
-		inRootPaths[Target.Path] = true
+		inRootPaths[MainModules.MustGetSingleMainModule().Path] = true
 		for _, m := range rs.rootModules {
 			if inRootPaths[m.Path] {
 				// This root specifies a redundant path. We already retained the
@@ -981,7 +1028,7 @@ func tidyEagerRoots(ctx context.Context, direct map[string]bool, pkgs []*loadPkg
 		}
 	}
 
-	min, err := mvs.Req(Target, rootPaths, &mvsReqs{roots: keep})
+	min, err := mvs.Req(MainModules.MustGetSingleMainModule(), rootPaths, &mvsReqs{roots: keep})
 	if err != nil {
 		return nil, err
 	}
@@ -1070,7 +1117,7 @@ func updateEagerRoots(ctx context.Context, direct map[string]bool, rs *Requireme
 	// This is only for convenience and clarity for end users: in an eager module,
 	// the choice of explicit vs. implicit dependency has no impact on MVS
 	// selection (for itself or any other module).
-	keep := append(mg.BuildList()[1:], add...)
+	keep := append(mg.BuildList()[MainModules.Len():], add...)
 	for _, m := range keep {
 		if direct[m.Path] && !inRootPaths[m.Path] {
 			rootPaths = append(rootPaths, m.Path)
@@ -1078,7 +1125,7 @@ func updateEagerRoots(ctx context.Context, direct map[string]bool, rs *Requireme
 		}
 	}
 
-	min, err := mvs.Req(Target, rootPaths, &mvsReqs{roots: keep})
+	min, err := mvs.Req(MainModules.MustGetSingleMainModule(), rootPaths, &mvsReqs{roots: keep})
 	if err != nil {
 		return rs, err
 	}
@@ -1093,7 +1140,9 @@ func updateEagerRoots(ctx context.Context, direct map[string]bool, rs *Requireme
 // convertDepth returns a version of rs with the given depth.
 // If rs already has the given depth, convertDepth returns rs unmodified.
 func convertDepth(ctx context.Context, rs *Requirements, depth modDepth) (*Requirements, error) {
-	if rs.depth == depth {
+	if rs.depth == depth || rs.depth == workspace {
+		// The depth of each module in a workspace is fixed by its own go.mod
+		// file, so the workspace requirements are never converted.
 		return rs, nil
 	}
 
@@ -1117,5 +1166,5 @@ func convertDepth(ctx context.Context, rs *Requirements, depth modDepth) (*Requi
 	if err != nil {
 		return rs, err
 	}
-	return newRequirements(lazy, mg.BuildList()[1:], rs.direct), nil
+	return newRequirements(lazy, mg.BuildList()[MainModules.Len():], rs.direct), nil
 }
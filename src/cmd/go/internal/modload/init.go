@@ -48,24 +48,141 @@ var (
 // Variables set in Init.
 var (
 	initialized bool
-	modRoot     string
-	gopath      string
-)
 
-// Variables set in initTarget (during {Load,Create}ModFile).
-var (
-	Target module.Version
+	// modRoots are the root directories of the main modules. In workspace mode
+	// there is one entry for each module listed in the go.work file; otherwise
+	// there is at most one. They are used to initialize MainModules.
+	modRoots []string
+	gopath   string
 
-	// targetPrefix is the path prefix for packages in Target, without a trailing
-	// slash. For most modules, targetPrefix is just Target.Path, but the
-	// standard-library module "std" has an empty prefix.
-	targetPrefix string
+	// workFilePath is the path to the go.work file in use, or "" if workspace
+	// mode is disabled.
+	workFilePath string
 
-	// targetInGorootSrc caches whether modRoot is within GOROOT/src.
-	// The "std" module is special within GOROOT/src, but not otherwise.
-	targetInGorootSrc bool
+	// workFile is the parsed go.work file, if workFilePath is set.
+	workFile *WorkFile
 )
 
+// MainModules is the set of main modules, set in {Load,Create}ModFile.
+//
+// In workspace mode it contains each module listed in the go.work file.
+// Otherwise it contains only the module rooted at (or above) the current
+// directory, or the "command-line-arguments" pseudo-module if there is none.
+var MainModules *MainModuleSet
+
+// A MainModuleSet is the set of main modules of a build.
+type MainModuleSet struct {
+	// versions are the module.Version values of the main modules, in the order
+	// they were listed. The Version fields are always empty.
+	versions []module.Version
+
+	// modRoot maps each main module to its root directory.
+	modRoot map[module.Version]string
+
+	// pathPrefix is the path prefix for packages in each main module, without a
+	// trailing slash. For most modules, the prefix is just the module path, but
+	// the standard-library module "std" has an empty prefix.
+	pathPrefix map[module.Version]string
+
+	// inGorootSrc caches whether each module's root is within GOROOT/src.
+	// The "std" module is special within GOROOT/src, but not otherwise.
+	inGorootSrc map[module.Version]bool
+
+	// In workspace mode, requirements holds the requirements listed in the
+	// go.mod file of each main module, and depth holds the depth at which the
+	// dependencies of each main module are loaded.
+	requirements map[module.Version][]module.Version
+	depth        map[module.Version]modDepth
+}
+
+// Versions returns the module.Version values of the main modules.
+// The caller must not modify the returned slice.
+func (mms *MainModuleSet) Versions() []module.Version {
+	if mms == nil {
+		return nil
+	}
+	return mms.versions
+}
+
+// Contains reports whether path is the path of a main module.
+func (mms *MainModuleSet) Contains(path string) bool {
+	if mms == nil {
+		return false
+	}
+	for _, v := range mms.versions {
+		if v.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// ModRoot returns the root directory of the main module m,
+// or "" if m is not a main module or has no root directory.
+func (mms *MainModuleSet) ModRoot(m module.Version) string {
+	if mms == nil {
+		return ""
+	}
+	return mms.modRoot[m]
+}
+
+// PathPrefix returns the import-path prefix of the packages in main module m.
+func (mms *MainModuleSet) PathPrefix(m module.Version) string {
+	if mms == nil {
+		return ""
+	}
+	return mms.pathPrefix[m]
+}
+
+// InGorootSrc reports whether the root of main module m is within GOROOT/src.
+func (mms *MainModuleSet) InGorootSrc(m module.Version) bool {
+	if mms == nil {
+		return false
+	}
+	return mms.inGorootSrc[m]
+}
+
+// Len returns the number of main modules.
+func (mms *MainModuleSet) Len() int {
+	if mms == nil {
+		return 0
+	}
+	return len(mms.versions)
+}
+
+// MustGetSingleMainModule returns the only main module.
+// It must not be called in workspace mode.
+func (mms *MainModuleSet) MustGetSingleMainModule() module.Version {
+	if mms == nil || len(mms.versions) == 0 {
+		panic("internal error: MustGetSingleMainModule called before the main modules were loaded")
+	}
+	if len(mms.versions) > 1 {
+		panic("internal error: MustGetSingleMainModule called in workspace mode")
+	}
+	return mms.versions[0]
+}
+
+// mainModuleForDir returns the main module with the longest root directory
+// containing the clean, absolute directory dir. The returned suffix is the
+// slash-separated path of dir within that root, with a leading slash, or the
+// empty string if dir is the root itself. It returns ok=false if dir is not
+// within any main module.
+func (mms *MainModuleSet) mainModuleForDir(dir string) (m module.Version, suffix string, ok bool) {
+	longest := ""
+	for _, v := range mms.Versions() {
+		root := mms.modRoot[v]
+		if root == "" || len(root) <= len(longest) {
+			continue
+		}
+		if dir == root {
+			m, suffix, longest, ok = v, "", root, true
+		} else if strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			m, suffix, longest, ok = v, filepath.ToSlash(dir[len(root):]), root, true
+		}
+	}
+	return m, suffix, ok
+}
+
 type Root int
 
 const (
@@ -92,8 +209,14 @@ const (
 // will be lost at the next call to WriteGoMod.
 // To make permanent changes to the require statements
 // in go.mod, edit it before loading.
+//
+// ModFile calls base.Fatalf in workspace mode, in which there is no single
+// go.mod file for the main modules.
 func ModFile() *modfile.File {
 	Init()
+	if inWorkspaceMode() {
+		base.Fatalf("go: %s", errWorkspaceModFile)
+	}
 	if modFile == nil {
 		die()
 	}
@@ -170,16 +293,28 @@ func Init() {
 		os.Setenv("GCM_INTERACTIVE", "never")
 	}
 
-	if modRoot != "" {
-		// modRoot set before Init was called ("go mod init" does this).
-		// No need to search for go.mod.
+	if len(modRoots) > 0 {
+		// modRoots set before Init was called ("go mod init" does this).
+		// No need to search for go.mod or go.work.
 	} else if RootMode == NoRoot {
 		if cfg.ModFile != "" && !base.InGOFLAGS("-modfile") {
 			base.Fatalf("go: -modfile cannot be used with commands that ignore the current module")
 		}
-		modRoot = ""
+		modRoots = nil
+	} else if workFilePath = FindGoWork(base.Cwd()); workFilePath != "" {
+		if cfg.ModFile != "" {
+			base.Fatalf("go: -modfile cannot be used in workspace mode")
+		}
+		wf, err := ReadWorkFile(workFilePath)
+		if err != nil {
+			base.Fatalf("go: %v", err)
+		}
+		if modRoots, err = workModRoots(workFilePath, wf); err != nil {
+			base.Fatalf("go: %v", err)
+		}
+		workFile = wf
 	} else {
-		modRoot = findModuleRoot(base.Cwd())
+		modRoot := findModuleRoot(base.Cwd())
 		if modRoot == "" {
 			if cfg.ModFile != "" {
 				base.Fatalf("go: cannot find main module, but -modfile was set.\n\t-modfile cannot be used to set the module root directory.")
@@ -198,11 +333,12 @@ func Init() {
 			// will find it and get modules when they're not expecting them.
 			// It's a bit of a peculiar thing to disallow but quite mysterious
 			// when it happens. See golang.org/issue/26708.
-			modRoot = ""
 			fmt.Fprintf(os.Stderr, "go: warning: ignoring go.mod in system temp root %v\n", os.TempDir())
 			if !mustUseModules {
 				return
 			}
+		} else {
+			modRoots = []string{modRoot}
 		}
 	}
 	if cfg.ModFile != "" && !strings.HasSuffix(cfg.ModFile, ".mod") {
@@ -221,7 +357,7 @@ func Init() {
 		base.Fatalf("$GOPATH/go.mod exists but should not")
 	}
 
-	if modRoot == "" {
+	if len(modRoots) == 0 {
 		// We're in module mode, but not inside a module.
 		//
 		// Commands like 'go build', 'go run', 'go list' have no go.mod file to
@@ -239,9 +375,17 @@ func Init() {
 		// For example, 'go get' does this, since it is expected to resolve paths.
 		//
 		// See golang.org/issue/32027.
+	} else if inWorkspaceMode() {
+		// The go.work.sum file records checksums needed by the workspace that
+		// are not already listed in the go.sum files of its modules.
+		modfetch.GoSumFile = workFilePath + ".sum"
+		for _, root := range modRoots {
+			modfetch.WorkspaceGoSumFiles = append(modfetch.WorkspaceGoSumFiles, filepath.Join(root, "go.sum"))
+		}
+		search.SetModRoots(modRoots)
 	} else {
-		modfetch.GoSumFile = strings.TrimSuffix(ModFilePath(), ".mod") + ".sum"
-		search.SetModRoot(modRoot)
+		modfetch.GoSumFile = strings.TrimSuffix(modFilePath(modRoots[0]), ".mod") + ".sum"
+		search.SetModRoots(modRoots)
 	}
 }
 
@@ -255,7 +399,7 @@ func Init() {
 // be called until the command is installed and flags are parsed. Instead of
 // calling Init and Enabled, the main package can call this function.
 func WillBeEnabled() bool {
-	if modRoot != "" || cfg.ModulesEnabled {
+	if len(modRoots) > 0 || cfg.ModulesEnabled {
 		// Already enabled.
 		return true
 	}
@@ -276,6 +420,11 @@ func WillBeEnabled() bool {
 		return false
 	}
 
+	if FindGoWork(base.Cwd()) != "" {
+		// A go.work file enables module mode, even without a module root.
+		return true
+	}
+
 	if modRoot := findModuleRoot(base.Cwd()); modRoot == "" {
 		// GO111MODULE is 'auto', and we can't find a module root.
 		// Stay in GOPATH mode.
@@ -297,24 +446,30 @@ func WillBeEnabled() bool {
 // (usually through MustModRoot).
 func Enabled() bool {
 	Init()
-	return modRoot != "" || cfg.ModulesEnabled
+	return len(modRoots) > 0 || cfg.ModulesEnabled
 }
 
 // ModRoot returns the root of the main module.
-// It calls base.Fatalf if there is no main module.
+// It calls base.Fatalf if there is no main module, or if in workspace mode.
 func ModRoot() string {
 	if !HasModRoot() {
 		die()
 	}
-	return modRoot
+	if inWorkspaceMode() {
+		base.Fatalf("go: %s", errWorkspaceModFile)
+	}
+	return modRoots[0]
 }
 
 // HasModRoot reports whether a main module is present.
 // HasModRoot may return false even if Enabled returns true: for example, 'get'
 // does not require a main module.
+//
+// In workspace mode, HasModRoot reports true if the go.work file lists any
+// modules.
 func HasModRoot() bool {
 	Init()
-	return modRoot != ""
+	return len(modRoots) > 0
 }
 
 // ModFilePath returns the effective path of the go.mod file. Normally, this
@@ -322,15 +477,20 @@ func HasModRoot() bool {
 // change its location. ModFilePath calls base.Fatalf if there is no main
 // module, even if -modfile is set.
 func ModFilePath() string {
-	if !HasModRoot() {
-		die()
-	}
+	return modFilePath(ModRoot())
+}
+
+// modFilePath returns the effective path of the go.mod file of the main
+// module rooted at modRoot.
+func modFilePath(modRoot string) string {
 	if cfg.ModFile != "" {
 		return cfg.ModFile
 	}
 	return filepath.Join(modRoot, "go.mod")
 }
 
+var errWorkspaceModFile = errors.New("there is no single main module in workspace mode; to use the module containing the current directory, set GOWORK=off")
+
 func die() {
 	if cfg.Getenv("GO111MODULE") == "off" {
 		base.Fatalf("go: modules disabled by GO111MODULE=off; see 'go help modules'")
@@ -365,8 +525,9 @@ func (goModDirtyError) Error() string {
 
 var errGoModDirty error = goModDirtyError{}
 
-// LoadModFile sets Target and, if there is a main module, parses the initial
-// build list from its go.mod file.
+// LoadModFile sets MainModules and, if there is a main module, parses the
+// initial build list from its go.mod file (or, in workspace mode, from the
+// go.mod files of the modules listed in the go.work file).
 //
 // LoadModFile may make changes in memory, like adding a go directive and
 // ensuring requirements are consistent, and will write those changes back to
@@ -402,26 +563,23 @@ func loadModFile(ctx context.Context) (rs *Requirements, needCommit bool) {
 	}
 
 	Init()
-	if modRoot == "" {
-		Target = module.Version{Path: "command-line-arguments"}
-		targetPrefix = "command-line-arguments"
+	if len(modRoots) == 0 {
+		mainModule := module.Version{Path: "command-line-arguments"}
+		MainModules = makeMainModules([]module.Version{mainModule}, []string{""})
 		goVersion := LatestGoVersion()
-		rawGoVersion.Store(Target, goVersion)
+		rawGoVersion.Store(mainModule, goVersion)
 		requirements = newRequirements(modDepthFromGoVersion(goVersion), nil, nil)
 		return requirements, false
 	}
 
-	gomod := ModFilePath()
-	var data []byte
-	var err error
-	if gomodActual, ok := fsys.OverlayPath(gomod); ok {
-		// Don't lock go.mod if it's part of the overlay.
-		// On Plan 9, locking requires chmod, and we don't want to modify any file
-		// in the overlay. See #44700.
-		data, err = os.ReadFile(gomodActual)
-	} else {
-		data, err = lockedfile.Read(gomodActual)
+	if inWorkspaceMode() {
+		setDefaultBuildMod()
+		requirements = loadWorkspace(ctx)
+		return requirements, false
 	}
+
+	gomod := ModFilePath()
+	data, err := readModFile(gomod)
 	if err != nil {
 		base.Fatalf("go: %v", err)
 	}
@@ -438,8 +596,9 @@ func loadModFile(ctx context.Context) (rs *Requirements, needCommit bool) {
 	}
 
 	modFile = f
-	initTarget(f.Module.Mod)
-	index = indexModFile(data, f, fixed)
+	mainModule := f.Module.Mod
+	MainModules = makeMainModules([]module.Version{mainModule}, modRoots)
+	index = indexModFile(data, f, mainModule, fixed)
 
 	if err := module.CheckImportPath(f.Module.Mod.Path); err != nil {
 		if pathErr, ok := err.(*module.InvalidPathError); ok {
@@ -482,7 +641,7 @@ func loadModFile(ctx context.Context) (rs *Requirements, needCommit bool) {
 				}
 			}
 		} else {
-			rawGoVersion.Store(Target, modFileGoVersion())
+			rawGoVersion.Store(mainModule, modFileGoVersion())
 		}
 	}
 
@@ -490,6 +649,228 @@ func loadModFile(ctx context.Context) (rs *Requirements, needCommit bool) {
 	return requirements, true
 }
 
+// readModFile returns the contents of the go.mod file at gomod.
+func readModFile(gomod string) ([]byte, error) {
+	if gomodActual, ok := fsys.OverlayPath(gomod); ok {
+		// Don't lock go.mod if it's part of the overlay.
+		// On Plan 9, locking requires chmod, and we don't want to modify any file
+		// in the overlay. See #44700.
+		return os.ReadFile(gomodActual)
+	}
+	return lockedfile.Read(gomod)
+}
+
+// loadWorkspace sets MainModules to the modules listed in the go.work file
+// and returns the combined requirements of their go.mod files.
+//
+// The replacements of the go.work file take precedence over those of the
+// go.mod files, and exclusions from any of the go.mod files apply to the
+// whole workspace.
+func loadWorkspace(ctx context.Context) *Requirements {
+	var (
+		mainModules []module.Version
+		mainReqs    = make(map[module.Version][]module.Version)
+		mainDepth   = make(map[module.Version]modDepth)
+		roots       []module.Version
+		direct      = make(map[string]bool)
+	)
+	ws := &modFileIndex{
+		require:         make(map[module.Version]requireMeta),
+		replace:         make(map[module.Version]module.Version),
+		highestReplaced: make(map[string]string),
+		exclude:         make(map[module.Version]bool),
+	}
+	if workFile.Go != nil {
+		ws.goVersionV = "v" + workFile.Go.Version
+	}
+
+	// modReplaces lists the replacements in the go.mod files of the
+	// workspace modules, with the go.mod file each came from.
+	type modReplace struct {
+		old, new module.Version
+		gomod    string
+	}
+	var modReplaces []modReplace
+	for _, root := range modRoots {
+		gomod := filepath.Join(root, "go.mod")
+		data, err := readModFile(gomod)
+		if err != nil {
+			base.Fatalf("go: cannot load module listed in %s: %v", base.ShortPath(workFilePath), err)
+		}
+		var fixed bool
+		f, err := modfile.Parse(gomod, data, fixVersion(ctx, &fixed))
+		if err != nil {
+			// Errors returned by modfile.Parse begin with file:line.
+			base.Fatalf("go: errors parsing %s:\n%s\n", base.ShortPath(gomod), err)
+		}
+		if f.Module == nil {
+			base.Fatalf("go: no module declaration in %s", base.ShortPath(gomod))
+		}
+		if err := module.CheckImportPath(f.Module.Mod.Path); err != nil {
+			if pathErr, ok := err.(*module.InvalidPathError); ok {
+				pathErr.Kind = "module"
+			}
+			base.Fatalf("go: %v", err)
+		}
+		m := f.Module.Mod
+		for _, mm := range mainModules {
+			if mm.Path == m.Path {
+				base.Fatalf("go: module %s appears multiple times in workspace", m.Path)
+			}
+		}
+		mainModules = append(mainModules, m)
+
+		i := indexModFile(data, f, m, fixed)
+		for x := range i.exclude {
+			ws.exclude[x] = true
+		}
+		for old, r := range i.replace {
+			if r.Version == "" && !filepath.IsAbs(r.Path) {
+				r.Path = filepath.Join(root, r.Path)
+			}
+			modReplaces = append(modReplaces, modReplace{old, r, base.ShortPath(gomod)})
+		}
+
+		depth := modDepthFromGoVersion(modFileGoVersionOf(f))
+		reqs, dir := rootsFromModFile(f, i.exclude)
+		mainReqs[m] = reqs
+		mainDepth[m] = depth
+		roots = append(roots, reqs...)
+		for path := range dir {
+			direct[path] = true
+		}
+	}
+
+	MainModules = makeMainModules(mainModules, modRoots)
+	MainModules.requirements = mainReqs
+	MainModules.depth = mainDepth
+
+	// Every version of a workspace module is provided by the workspace module
+	// itself, as if replaced by its directory: the requirements of other
+	// workspace modules on it need not refer to a published version.
+	// Replacements of workspace modules in go.mod files are ignored.
+	//
+	// replacedBy records which go.mod file each replacement came from, to
+	// report conflicting replacements.
+	replacedBy := make(map[module.Version]string)
+	for _, mr := range modReplaces {
+		if MainModules.Contains(mr.old.Path) {
+			continue
+		}
+		if prev, dup := ws.replace[mr.old]; dup && prev != mr.new {
+			if _, ok := workReplace(mr.old); !ok {
+				base.Fatalf("go: conflicting replacements for %v:\n\t%v (in %s)\n\t%v (in %s)\n\tuse a replace directive in %s to resolve the conflict", mr.old, prev, replacedBy[mr.old], mr.new, mr.gomod, base.ShortPath(workFilePath))
+			}
+		}
+		ws.replace[mr.old] = mr.new
+		replacedBy[mr.old] = mr.gomod
+	}
+	for i, m := range mainModules {
+		ws.replace[module.Version{Path: m.Path}] = module.Version{Path: modRoots[i]}
+	}
+
+	workDir := filepath.Dir(workFilePath)
+	for _, r := range workFile.Replace {
+		n := r.New
+		if n.Version == "" && !filepath.IsAbs(n.Path) {
+			n.Path = filepath.Join(workDir, n.Path)
+		}
+		ws.replace[r.Old] = n
+	}
+	for old := range ws.replace {
+		v, ok := ws.highestReplaced[old.Path]
+		if !ok || semver.Compare(old.Version, v) > 0 {
+			ws.highestReplaced[old.Path] = old.Version
+		}
+	}
+	for _, r := range roots {
+		ws.require[r] = requireMeta{indirect: !direct[r.Path]}
+	}
+	index = ws
+
+	module.Sort(roots)
+	// Drop exact duplicates, which occur when several workspace modules
+	// require the same version of a module.
+	j := 0
+	for i, r := range roots {
+		if i == 0 || r != roots[j-1] {
+			roots[j] = r
+			j++
+		}
+	}
+	roots = roots[:j]
+	return newRequirements(workspace, roots, direct)
+}
+
+// workReplace returns the replacement for mod given by a replace directive
+// in the go.work file, if any.
+func workReplace(mod module.Version) (module.Version, bool) {
+	for _, r := range workFile.Replace {
+		if r.Old == mod {
+			return r.New, true
+		}
+	}
+	return module.Version{}, false
+}
+
+// makeMainModules returns a MainModuleSet containing the given modules,
+// rooted at the corresponding directories in rootDirs.
+func makeMainModules(ms []module.Version, rootDirs []string) *MainModuleSet {
+	mms := &MainModuleSet{
+		versions:    ms[:len(ms):len(ms)],
+		modRoot:     make(map[module.Version]string, len(ms)),
+		pathPrefix:  make(map[module.Version]string, len(ms)),
+		inGorootSrc: make(map[module.Version]bool, len(ms)),
+	}
+	for i, m := range ms {
+		mms.modRoot[m] = rootDirs[i]
+		mms.pathPrefix[m] = m.Path
+		if rootDirs[i] == "" {
+			continue
+		}
+		if rel := search.InDir(rootDirs[i], cfg.GOROOTsrc); rel != "" {
+			mms.inGorootSrc[m] = true
+			if m.Path == "std" {
+				// The "std" module in GOROOT/src is the Go standard library. Unlike other
+				// modules, the packages in the "std" module have no import-path prefix.
+				//
+				// Modules named "std" outside of GOROOT/src do not receive this special
+				// treatment, so it is possible to run 'go test .' in other GOROOTs to
+				// test individual packages using a combination of the modified package
+				// and the ordinary standard library.
+				// (See https://golang.org/issue/30756.)
+				mms.pathPrefix[m] = ""
+			}
+		}
+	}
+	return mms
+}
+
+// EnterModule leaves workspace mode and makes the module rooted at modRoot
+// the only main module, as if the go command had been run in that directory
+// with GOWORK=off. 'go work sync' uses it to update the go.mod file of each
+// module in the workspace in turn.
+//
+// Any checksums needed by the workspace must already have been written
+// (for example, by WriteGoMod) before EnterModule is called.
+func EnterModule(ctx context.Context, modRoot string) {
+	MainModules = nil
+	requirements = nil
+	index = nil
+	modFile = nil
+	loaded = nil
+	workFilePath = ""
+	workFile = nil
+
+	modRoots = []string{modRoot}
+	modfetch.GoSumFile = strings.TrimSuffix(modFilePath(modRoot), ".mod") + ".sum"
+	modfetch.WorkspaceGoSumFiles = nil
+	modfetch.ResetGoSum()
+	search.SetModRoots(modRoots)
+
+	LoadModFile(ctx)
+}
+
 // CreateModFile initializes a new module by creating a go.mod file.
 //
 // If modPath is empty, CreateModFile will attempt to infer the path from the
@@ -500,7 +881,8 @@ func loadModFile(ctx context.Context) (rs *Requirements, needCommit bool) {
 // exactly the same as in the legacy configuration (for example, we can't get
 // packages at multiple versions from the same module).
 func CreateModFile(ctx context.Context, modPath string) {
-	modRoot = base.Cwd()
+	modRoot := base.Cwd()
+	modRoots = []string{modRoot}
 	Init()
 	modFilePath := ModFilePath()
 	if _, err := fsys.Stat(modFilePath); err == nil {
@@ -535,10 +917,10 @@ func CreateModFile(ctx context.Context, modPath string) {
 	fmt.Fprintf(os.Stderr, "go: creating new go.mod: module %s\n", modPath)
 	modFile = new(modfile.File)
 	modFile.AddModuleStmt(modPath)
-	initTarget(modFile.Module.Mod)
+	MainModules = makeMainModules([]module.Version{modFile.Module.Mod}, []string{modRoot})
 	addGoStmt(LatestGoVersion()) // Add the go directive before converted module requirements.
 
-	convertedFrom, err := convertLegacyConfig(modPath)
+	convertedFrom, err := convertLegacyConfig(modRoot, modPath)
 	if convertedFrom != "" {
 		fmt.Fprintf(os.Stderr, "go: copying requirements from %s\n", base.ShortPath(convertedFrom))
 	}
@@ -639,34 +1021,24 @@ func AllowMissingModuleImports() {
 	allowMissingModuleImports = true
 }
 
-// initTarget sets Target and associated variables according to modFile,
-func initTarget(m module.Version) {
-	Target = m
-	targetPrefix = m.Path
-
-	if rel := search.InDir(base.Cwd(), cfg.GOROOTsrc); rel != "" {
-		targetInGorootSrc = true
-		if m.Path == "std" {
-			// The "std" module in GOROOT/src is the Go standard library. Unlike other
-			// modules, the packages in the "std" module have no import-path prefix.
-			//
-			// Modules named "std" outside of GOROOT/src do not receive this special
-			// treatment, so it is possible to run 'go test .' in other GOROOTs to
-			// test individual packages using a combination of the modified package
-			// and the ordinary standard library.
-			// (See https://golang.org/issue/30756.)
-			targetPrefix = ""
-		}
-	}
-}
-
 // requirementsFromModFile returns the set of non-excluded requirements from
 // the global modFile.
 func requirementsFromModFile() *Requirements {
-	roots := make([]module.Version, 0, len(modFile.Require))
-	direct := map[string]bool{}
-	for _, r := range modFile.Require {
-		if index != nil && index.exclude[r.Mod] {
+	var exclude map[module.Version]bool
+	if index != nil {
+		exclude = index.exclude
+	}
+	roots, direct := rootsFromModFile(modFile, exclude)
+	return newRequirements(modDepthFromGoVersion(modFileGoVersion()), roots, direct)
+}
+
+// rootsFromModFile returns the sorted requirements of f that are not
+// excluded, and the set of module paths that f requires directly.
+func rootsFromModFile(f *modfile.File, exclude map[module.Version]bool) (roots []module.Version, direct map[string]bool) {
+	roots = make([]module.Version, 0, len(f.Require))
+	direct = map[string]bool{}
+	for _, r := range f.Require {
+		if exclude[r.Mod] {
 			if cfg.BuildMod == "mod" {
 				fmt.Fprintf(os.Stderr, "go: dropping requirement on excluded version %s %s\n", r.Mod.Path, r.Mod.Version)
 			} else {
@@ -681,13 +1053,17 @@ func requirementsFromModFile() *Requirements {
 		}
 	}
 	module.Sort(roots)
-	rs := newRequirements(modDepthFromGoVersion(modFileGoVersion()), roots, direct)
-	return rs
+	return roots, direct
 }
 
 // setDefaultBuildMod sets a default value for cfg.BuildMod if the -mod flag
 // wasn't provided. setDefaultBuildMod may be called multiple times.
 func setDefaultBuildMod() {
+	if inWorkspaceMode() {
+		setWorkspaceBuildMod()
+		return
+	}
+
 	if cfg.BuildModExplicit {
 		// Don't override an explicit '-mod=' argument.
 		return
@@ -697,7 +1073,7 @@ func setDefaultBuildMod() {
 	// to modload functions instead of relying on an implicit setting
 	// based on command name.
 	switch cfg.CmdName {
-	case "get", "mod download", "mod init", "mod tidy":
+	case "get", "mod download", "mod init", "mod tidy", "work sync":
 		// These commands are intended to update go.mod and go.sum.
 		cfg.BuildMod = "mod"
 		return
@@ -713,7 +1089,7 @@ func setDefaultBuildMod() {
 		cfg.BuildMod = "readonly"
 		return
 	}
-	if modRoot == "" {
+	if len(modRoots) == 0 {
 		if allowMissingModuleImports {
 			cfg.BuildMod = "mod"
 		} else {
@@ -722,7 +1098,7 @@ func setDefaultBuildMod() {
 		return
 	}
 
-	if fi, err := fsys.Stat(filepath.Join(modRoot, "vendor")); err == nil && fi.IsDir() {
+	if fi, err := fsys.Stat(filepath.Join(modRoots[0], "vendor")); err == nil && fi.IsDir() {
 		modGo := "unspecified"
 		if index != nil && index.goVersionV != "" {
 			if semver.Compare(index.goVersionV, "v1.14") >= 0 {
@@ -744,9 +1120,35 @@ func setDefaultBuildMod() {
 	cfg.BuildMod = "readonly"
 }
 
+// setWorkspaceBuildMod sets cfg.BuildMod in workspace mode.
+//
+// The go.mod files of the workspace modules are never written in workspace
+// mode, so the commands whose purpose is to update a go.mod file are
+// rejected, and -mod may only be set to readonly.
+func setWorkspaceBuildMod() {
+	switch cfg.CmdName {
+	case "get", "mod tidy", "mod vendor":
+		base.Fatalf("go: 'go %s' cannot be run in workspace mode; to run it in the module containing the current directory, set GOWORK=off", cfg.CmdName)
+	case "mod download", "mod graph", "mod verify", "mod why":
+		// These commands need to be able to fetch modules that are not yet
+		// listed in any go.sum file, but they never add requirements.
+		cfg.BuildMod = "mod"
+		return
+	case "work sync":
+		// 'go work sync' updates the go.mod and go.sum files of the workspace
+		// modules, one module at a time (see EnterModule).
+		cfg.BuildMod = "mod"
+		return
+	}
+	if cfg.BuildModExplicit && cfg.BuildMod != "readonly" {
+		base.Fatalf("go: -mod may only be set to readonly in workspace mode, but it is set to %q", cfg.BuildMod)
+	}
+	cfg.BuildMod = "readonly"
+}
+
 // convertLegacyConfig imports module requirements from a legacy vendoring
 // configuration file, if one is present.
-func convertLegacyConfig(modPath string) (from string, err error) {
+func convertLegacyConfig(modRoot, modPath string) (from string, err error) {
 	noneSelected := func(path string) (version string) { return "none" }
 	queryPackage := func(path, rev string) (module.Version, error) {
 		pkgMods, modOnly, err := QueryPattern(context.Background(), path, rev, noneSelected, nil)
@@ -784,7 +1186,7 @@ func addGoStmt(v string) {
 	if err := modFile.AddGoStmt(v); err != nil {
 		base.Fatalf("go: internal error: %v", err)
 	}
-	rawGoVersion.Store(Target, v)
+	rawGoVersion.Store(MainModules.MustGetSingleMainModule(), v)
 }
 
 // LatestGoVersion returns the latest version of the Go language supported by
@@ -1013,11 +1415,18 @@ func commitRequirements(ctx context.Context, goVersion string, rs *Requirements)
 		return
 	}
 
-	if modRoot == "" {
+	if len(modRoots) == 0 {
 		// We aren't in a module, so we don't have anywhere to write a go.mod file.
 		return
 	}
 
+	if inWorkspaceMode() {
+		// The go.mod files of the workspace modules are not modified in
+		// workspace mode, but checksums may need to be added to go.work.sum.
+		modfetch.WriteGoSum(keepSums(ctx, loaded, rs, addBuildListZipSums))
+		return
+	}
+
 	var list []*modfile.Require
 	for _, m := range rs.rootModules {
 		list = append(list, &modfile.Require{
@@ -1066,7 +1475,7 @@ func commitRequirements(ctx context.Context, goVersion string, rs *Requirements)
 	}
 	defer func() {
 		// At this point we have determined to make the go.mod file on disk equal to new.
-		index = indexModFile(new, modFile, false)
+		index = indexModFile(new, modFile, MainModules.MustGetSingleMainModule(), false)
 
 		// Update go.sum after releasing the side lock and refreshing the index.
 		// 'go mod init' shouldn't write go.sum, since it will be incomplete.
@@ -274,7 +274,9 @@ func LoadPackages(ctx context.Context, opts PackageOpts, patterns ...string) (ma
 
 						// If we're outside of a module, ensure that the failure mode
 						// indicates that.
-						ModRoot()
+						if !HasModRoot() {
+							die()
+						}
 
 						if ld != nil {
 							m.AddError(err)
@@ -306,7 +308,7 @@ func LoadPackages(ctx context.Context, opts PackageOpts, patterns ...string) (ma
 					// The initial roots are the packages in the main module.
 					// loadFromRoots will expand that to "all".
 					m.Errs = m.Errs[:0]
-					matchPackages(ctx, m, opts.Tags, omitStd, []module.Version{Target})
+					matchPackages(ctx, m, opts.Tags, omitStd, MainModules.Versions())
 				} else {
 					// Starting with the packages in the main module,
 					// enumerate the full list of "all".
@@ -436,7 +438,7 @@ func matchLocalDirs(ctx context.Context, m *search.Match, rs *Requirements) {
 		if !filepath.IsAbs(dir) {
 			absDir = filepath.Join(base.Cwd(), dir)
 		}
-		if search.InDir(absDir, cfg.GOROOTsrc) == "" && search.InDir(absDir, ModRoot()) == "" && pathInModuleCache(ctx, absDir, rs) == "" {
+		if search.InDir(absDir, cfg.GOROOTsrc) == "" && !inModRoots(absDir) && pathInModuleCache(ctx, absDir, rs) == "" {
 			m.Dirs = []string{}
 			m.AddError(fmt.Errorf("directory prefix %s outside available modules", base.ShortPath(absDir)))
 			return
@@ -446,6 +448,20 @@ func matchLocalDirs(ctx context.Context, m *search.Match, rs *Requirements) {
 	m.MatchDirs()
 }
 
+// inModRoots reports whether dir is within the root directory of any main
+// module. If there is no main module, it calls die.
+func inModRoots(dir string) bool {
+	if !HasModRoot() {
+		die()
+	}
+	for _, root := range modRoots {
+		if search.InDir(dir, root) != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // resolveLocalPackage resolves a filesystem path to a package path.
 func resolveLocalPackage(ctx context.Context, dir string, rs *Requirements) (string, error) {
 	var absDir string
@@ -485,18 +501,18 @@ func resolveLocalPackage(ctx context.Context, dir string, rs *Requirements) (str
 		}
 	}
 
-	if modRoot != "" && absDir == modRoot {
+	mainModule, suffix, inMain := MainModules.mainModuleForDir(absDir)
+	if inMain && suffix == "" {
 		if absDir == cfg.GOROOTsrc {
 			return "", errPkgIsGorootSrc
 		}
-		return targetPrefix, nil
+		return MainModules.PathPrefix(mainModule), nil
 	}
 
 	// Note: The checks for @ here are just to avoid misinterpreting
 	// the module cache directories (formerly GOPATH/src/mod/foo@v1.5.2/bar).
 	// It's not strictly necessary but helpful to keep the checks.
-	if modRoot != "" && strings.HasPrefix(absDir, modRoot+string(filepath.Separator)) && !strings.Contains(absDir[len(modRoot):], "@") {
-		suffix := filepath.ToSlash(absDir[len(modRoot):])
+	if inMain && !strings.Contains(suffix, "@") {
 		if strings.HasPrefix(suffix, "/vendor/") {
 			if cfg.BuildMod != "vendor" {
 				return "", fmt.Errorf("without -mod=vendor, directory %s has no package path", absDir)
@@ -510,7 +526,8 @@ func resolveLocalPackage(ctx context.Context, dir string, rs *Requirements) (str
 			return pkg, nil
 		}
 
-		if targetPrefix == "" {
+		mainModulePrefix := MainModules.PathPrefix(mainModule)
+		if mainModulePrefix == "" {
 			pkg := strings.TrimPrefix(suffix, "/")
 			if pkg == "builtin" {
 				// "builtin" is a pseudo-package with a real source file.
@@ -521,11 +538,11 @@ func resolveLocalPackage(ctx context.Context, dir string, rs *Requirements) (str
 			return pkg, nil
 		}
 
-		pkg := targetPrefix + suffix
-		if _, ok, err := dirInModule(pkg, targetPrefix, modRoot, true); err != nil {
+		pkg := mainModulePrefix + suffix
+		if _, ok, err := dirInModule(pkg, mainModulePrefix, MainModules.ModRoot(mainModule), true); err != nil {
 			return "", err
 		} else if !ok {
-			return "", &PackageNotInModuleError{Mod: Target, Pattern: pkg}
+			return "", &PackageNotInModuleError{Mod: mainModule, Pattern: pkg}
 		}
 		return pkg, nil
 	}
@@ -654,7 +671,7 @@ func DirImportPath(ctx context.Context, dir string) string {
 	if !HasModRoot() {
 		return "."
 	}
-	LoadModFile(ctx) // Sets targetPrefix.
+	LoadModFile(ctx) // Sets MainModules.
 
 	if !filepath.IsAbs(dir) {
 		dir = filepath.Join(base.Cwd(), dir)
@@ -662,17 +679,14 @@ func DirImportPath(ctx context.Context, dir string) string {
 		dir = filepath.Clean(dir)
 	}
 
-	if dir == modRoot {
-		return targetPrefix
+	mainModule, suffix, ok := MainModules.mainModuleForDir(dir)
+	if !ok {
+		return "."
 	}
-	if strings.HasPrefix(dir, modRoot+string(filepath.Separator)) {
-		suffix := filepath.ToSlash(dir[len(modRoot):])
-		if strings.HasPrefix(suffix, "/vendor/") {
-			return strings.TrimPrefix(suffix, "/vendor/")
-		}
-		return targetPrefix + suffix
+	if strings.HasPrefix(suffix, "/vendor/") {
+		return strings.TrimPrefix(suffix, "/vendor/")
 	}
-	return "."
+	return MainModules.PathPrefix(mainModule) + suffix
 }
 
 // ImportMap returns the actual package import path
@@ -894,8 +908,8 @@ func (pkg *loadPkg) fromExternalModule() bool {
 	if pkg.mod.Path == "" {
 		return false // loaded from the standard library, not a module
 	}
-	if pkg.mod.Path == Target.Path {
-		return false // loaded from the main module.
+	if MainModules.Contains(pkg.mod.Path) {
+		return false // loaded from a main module.
 	}
 	return true
 }
@@ -1168,7 +1182,7 @@ func (ld *loader) updateRequirements(ctx context.Context) (changed bool, err err
 	}
 
 	for _, pkg := range ld.pkgs {
-		if pkg.mod != Target {
+		if !MainModules.Contains(pkg.mod.Path) {
 			continue
 		}
 		for _, dep := range pkg.imports {
@@ -1425,7 +1439,7 @@ func (ld *loader) applyPkgFlags(ctx context.Context, pkg *loadPkg, flags loadPkg
 		// so it's ok if we call it more than is strictly necessary.
 		wantTest := false
 		switch {
-		case ld.allPatternIsRoot && pkg.mod == Target:
+		case ld.allPatternIsRoot && MainModules.Contains(pkg.mod.Path):
 			// We are loading the "all" pattern, which includes packages imported by
 			// tests in the main module. This package is in the main module, so we
 			// need to identify the imports of its test even if LoadTests is not set.
@@ -1446,7 +1460,7 @@ func (ld *loader) applyPkgFlags(ctx context.Context, pkg *loadPkg, flags loadPkg
 
 		if wantTest {
 			var testFlags loadPkgFlags
-			if pkg.mod == Target || (ld.allClosesOverTests && new.has(pkgInAll)) {
+			if MainModules.Contains(pkg.mod.Path) || (ld.allClosesOverTests && new.has(pkgInAll)) {
 				// Tests of packages in the main module are in "all", in the sense that
 				// they cause the packages they import to also be in "all". So are tests
 				// of packages in "all" if "all" closes over test dependencies.
@@ -1573,7 +1587,7 @@ func (ld *loader) load(ctx context.Context, pkg *loadPkg) {
 	}
 
 	var mg *ModuleGraph
-	if ld.requirements.depth == eager {
+	if ld.requirements.depth != lazy {
 		var err error
 		mg, err = ld.requirements.Graph(ctx)
 		if err != nil {
@@ -1593,7 +1607,7 @@ func (ld *loader) load(ctx context.Context, pkg *loadPkg) {
 	if pkg.dir == "" {
 		return
 	}
-	if pkg.mod == Target {
+	if MainModules.Contains(pkg.mod.Path) {
 		// Go ahead and mark pkg as in "all". This provides the invariant that a
 		// package that is *only* imported by other packages in "all" is always
 		// marked as such before loading its imports.
@@ -1698,13 +1712,13 @@ func (ld *loader) stdVendor(parentPath, path string) string {
 	}
 
 	if str.HasPathPrefix(parentPath, "cmd") {
-		if !ld.VendorModulesInGOROOTSrc || Target.Path != "cmd" {
+		if !ld.VendorModulesInGOROOTSrc || !MainModules.Contains("cmd") {
 			vendorPath := pathpkg.Join("cmd", "vendor", path)
 			if _, err := os.Stat(filepath.Join(cfg.GOROOTsrc, filepath.FromSlash(vendorPath))); err == nil {
 				return vendorPath
 			}
 		}
-	} else if !ld.VendorModulesInGOROOTSrc || Target.Path != "std" || str.HasPathPrefix(parentPath, "vendor") {
+	} else if !ld.VendorModulesInGOROOTSrc || !MainModules.Contains("std") || str.HasPathPrefix(parentPath, "vendor") {
 		// If we are outside of the 'std' module, resolve imports from within 'std'
 		// to the vendor directory.
 		//
@@ -60,11 +60,26 @@ var modFile *modfile.File
 
 // modFileGoVersion returns the (non-empty) Go version at which the requirements
 // in modFile are intepreted, or the latest Go version if modFile is nil.
+//
+// In workspace mode, modFileGoVersion returns the Go version of the go.work
+// file, or the latest Go version if it has no go directive.
 func modFileGoVersion() string {
+	if inWorkspaceMode() {
+		if workFile.Go != nil {
+			return workFile.Go.Version
+		}
+		return LatestGoVersion()
+	}
 	if modFile == nil {
 		return LatestGoVersion()
 	}
-	if modFile.Go == nil || modFile.Go.Version == "" {
+	return modFileGoVersionOf(modFile)
+}
+
+// modFileGoVersionOf returns the (non-empty) Go version at which the
+// requirements in the go.mod file f are interpreted.
+func modFileGoVersionOf(f *modfile.File) string {
+	if f.Go == nil || f.Go.Version == "" {
 		// The main module necessarily has a go.mod file, and that file lacks a
 		// 'go' directive. The 'go' command has been adding that directive
 		// automatically since Go 1.12, so this module either dates to Go 1.11 or
@@ -76,7 +91,7 @@ func modFileGoVersion() string {
 		// scripts may assume that it ends up as a Go 1.16 module.
 		return "1.16"
 	}
-	return modFile.Go.Version
+	return f.Go.Version
 }
 
 // A modFileIndex is an index of data corresponding to a modFile
@@ -93,6 +108,9 @@ type modFileIndex struct {
 }
 
 // index is the index of the go.mod file as of when it was last read or written.
+//
+// In workspace mode, index instead combines the requirements, replacements,
+// and exclusions of all of the workspace modules and the go.work file.
 var index *modFileIndex
 
 type requireMeta struct {
@@ -103,8 +121,9 @@ type requireMeta struct {
 type modDepth uint8
 
 const (
-	lazy  modDepth = iota // load dependencies only as needed
-	eager                 // load all transitive dependencies eagerly
+	lazy      modDepth = iota // load dependencies only as needed
+	eager                     // load all transitive dependencies eagerly
+	workspace                 // load the dependencies of each main module at the depth of its own go.mod file
 )
 
 func modDepthFromGoVersion(goVersion string) modDepth {
@@ -306,10 +325,16 @@ func CheckDeprecation(ctx context.Context, m module.Version) (deprecation string
 	return summary.deprecated, nil
 }
 
-// Replacement returns the replacement for mod, if any, from go.mod.
-// If there is no replacement for mod, Replacement returns
+// Replacement returns the replacement for mod, if any, from go.mod
+// (or, in workspace mode, from the go.work file and the go.mod files of the
+// workspace modules). If there is no replacement for mod, Replacement returns
 // a module.Version with Path == "".
+//
+// The main modules themselves are never replaced.
 func Replacement(mod module.Version) module.Version {
+	if mod.Version == "" && MainModules.Contains(mod.Path) {
+		return module.Version{}
+	}
 	if index != nil {
 		if r, ok := index.replace[mod]; ok {
 			return r
@@ -330,10 +355,11 @@ func resolveReplacement(m module.Version) module.Version {
 	return m
 }
 
-// indexModFile rebuilds the index of modFile.
+// indexModFile rebuilds the index of modFile, which is the go.mod file of the
+// main module mod.
 // If modFile has been changed since it was first read,
 // modFile.Cleanup must be called before indexModFile.
-func indexModFile(data []byte, modFile *modfile.File, needsFix bool) *modFileIndex {
+func indexModFile(data []byte, modFile *modfile.File, mod module.Version, needsFix bool) *modFileIndex {
 	i := new(modFileIndex)
 	i.data = data
 	i.dataNeedsFix = needsFix
@@ -345,12 +371,12 @@ func indexModFile(data []byte, modFile *modfile.File, needsFix bool) *modFileInd
 
 	i.goVersionV = ""
 	if modFile.Go == nil {
-		rawGoVersion.Store(Target, "")
+		rawGoVersion.Store(mod, "")
 	} else {
 		// We're going to use the semver package to compare Go versions, so go ahead
 		// and add the "v" prefix it expects once instead of every time.
 		i.goVersionV = "v" + modFile.Go.Version
-		rawGoVersion.Store(Target, modFile.Go.Version)
+		rawGoVersion.Store(mod, modFile.Go.Version)
 	}
 
 	i.require = make(map[module.Version]requireMeta, len(modFile.Require))
@@ -482,16 +508,16 @@ type retraction struct {
 // taking into account any replacements for m, exclusions of its dependencies,
 // and/or vendoring.
 //
-// m must be a version in the module graph, reachable from the Target module.
+// m must be a version in the module graph, reachable from the main modules.
 // In readonly mode, the go.sum file must contain an entry for m's go.mod file
-// (or its replacement). goModSummary must not be called for the Target module
+// (or its replacement). goModSummary must not be called for a main module
 // itself, as its requirements may change. Use rawGoModSummary for other
 // module versions.
 //
 // The caller must not modify the returned summary.
 func goModSummary(m module.Version) (*modFileSummary, error) {
-	if m == Target {
-		panic("internal error: goModSummary called on the Target module")
+	if m.Version == "" && MainModules.Contains(m.Path) {
+		panic("internal error: goModSummary called on a main module")
 	}
 
 	if cfg.BuildMod == "vendor" {
@@ -583,10 +609,10 @@ func goModSummary(m module.Version) (*modFileSummary, error) {
 // ignoring all replacements that may apply to m and excludes that may apply to
 // its dependencies.
 //
-// rawGoModSummary cannot be used on the Target module.
+// rawGoModSummary cannot be used on a main module.
 func rawGoModSummary(m module.Version) (*modFileSummary, error) {
-	if m == Target {
-		panic("internal error: rawGoModSummary called on the Target module")
+	if m.Version == "" && MainModules.Contains(m.Path) {
+		panic("internal error: rawGoModSummary called on a main module")
 	}
 
 	type cached struct {
@@ -641,7 +667,7 @@ var rawGoModSummaryCache par.Cache // module.Version → rawGoModSummary result
 // rawGoModData returns the content of the go.mod file for module m, ignoring
 // all replacements that may apply to m.
 //
-// rawGoModData cannot be used on the Target module.
+// rawGoModData cannot be used on a main module.
 //
 // Unlike rawGoModSummary, rawGoModData does not cache its results in memory.
 // Use rawGoModSummary instead unless you specifically need these bytes.
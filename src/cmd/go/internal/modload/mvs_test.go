@@ -16,7 +16,7 @@ func TestReqsMax(t *testing.T) {
 	for _, tc := range []testCase{
 		{a: "v0.1.0", b: "v0.2.0", want: "v0.2.0"},
 		{a: "v0.2.0", b: "v0.1.0", want: "v0.2.0"},
-		{a: "", b: "v0.1.0", want: ""}, // "" is Target.Version
+		{a: "", b: "v0.1.0", want: ""}, // "" is the version of a main module
 		{a: "v0.1.0", b: "", want: ""},
 		{a: "none", b: "v0.1.0", want: "v0.1.0"},
 		{a: "v0.1.0", b: "none", want: "v0.1.0"},
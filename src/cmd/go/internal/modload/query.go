@@ -110,11 +110,12 @@ func queryProxy(ctx context.Context, proxy, path, query, current string, allowed
 		allowed = func(context.Context, module.Version) error { return nil }
 	}
 
-	if path == Target.Path && (query == "upgrade" || query == "patch") {
-		if err := allowed(ctx, Target); err != nil {
+	if MainModules.Contains(path) && (query == "upgrade" || query == "patch") {
+		m := module.Version{Path: path}
+		if err := allowed(ctx, m); err != nil {
 			return nil, fmt.Errorf("internal error: main module version is not allowed: %w", err)
 		}
-		return &modfetch.RevInfo{Version: Target.Version}, nil
+		return &modfetch.RevInfo{Version: m.Version}, nil
 	}
 
 	if path == "std" || path == "cmd" {
@@ -568,8 +569,8 @@ func QueryPattern(ctx context.Context, pattern, query string, current func(strin
 		match = func(mod module.Version, root string, isLocal bool) *search.Match {
 			m := search.NewMatch(pattern)
 			prefix := mod.Path
-			if mod == Target {
-				prefix = targetPrefix
+			if mod.Version == "" && MainModules.Contains(mod.Path) {
+				prefix = MainModules.PathPrefix(mod)
 			}
 			if _, ok, err := dirInModule(pattern, prefix, root, isLocal); err != nil {
 				m.AddError(err)
@@ -581,8 +582,12 @@ func QueryPattern(ctx context.Context, pattern, query string, current func(strin
 	}
 
 	var queryMatchesMainModule bool
-	if HasModRoot() {
-		m := match(Target, modRoot, true)
+	for _, mainModule := range MainModules.Versions() {
+		root := MainModules.ModRoot(mainModule)
+		if root == "" {
+			continue
+		}
+		m := match(mainModule, root, true)
 		if len(m.Pkgs) > 0 {
 			if query != "upgrade" && query != "patch" {
 				return nil, nil, &QueryMatchesPackagesInMainModuleError{
@@ -591,12 +596,12 @@ func QueryPattern(ctx context.Context, pattern, query string, current func(strin
 					Packages: m.Pkgs,
 				}
 			}
-			if err := allowed(ctx, Target); err != nil {
-				return nil, nil, fmt.Errorf("internal error: package %s is in the main module (%s), but version is not allowed: %w", pattern, Target.Path, err)
+			if err := allowed(ctx, mainModule); err != nil {
+				return nil, nil, fmt.Errorf("internal error: package %s is in the main module (%s), but version is not allowed: %w", pattern, mainModule.Path, err)
 			}
 			return []QueryResult{{
-				Mod:      Target,
-				Rev:      &modfetch.RevInfo{Version: Target.Version},
+				Mod:      mainModule,
+				Rev:      &modfetch.RevInfo{Version: mainModule.Version},
 				Packages: m.Pkgs,
 			}}, nil, nil
 		}
@@ -604,15 +609,14 @@ func QueryPattern(ctx context.Context, pattern, query string, current func(strin
 			return nil, nil, err
 		}
 
-		if matchPattern(Target.Path) {
+		if matchPattern(mainModule.Path) {
 			queryMatchesMainModule = true
-		}
-
-		if (query == "upgrade" || query == "patch") && queryMatchesMainModule {
-			if err := allowed(ctx, Target); err == nil {
-				modOnly = &QueryResult{
-					Mod: Target,
-					Rev: &modfetch.RevInfo{Version: Target.Version},
+			if query == "upgrade" || query == "patch" {
+				if err := allowed(ctx, mainModule); err == nil {
+					modOnly = &QueryResult{
+						Mod: mainModule,
+						Rev: &modfetch.RevInfo{Version: mainModule.Version},
+					}
 				}
 			}
 		}
@@ -631,8 +635,12 @@ func QueryPattern(ctx context.Context, pattern, query string, current func(strin
 				Query:   query,
 			}
 		} else {
+			mainModule := mainModuleWithPrefix(base)
+			if mainModule == (module.Version{}) && MainModules.Len() > 0 {
+				mainModule = MainModules.Versions()[0]
+			}
 			return nil, nil, &PackageNotInModuleError{
-				Mod:     Target,
+				Mod:     mainModule,
 				Query:   query,
 				Pattern: pattern,
 			}
@@ -694,7 +702,8 @@ func QueryPattern(ctx context.Context, pattern, query string, current func(strin
 }
 
 // modulePrefixesExcludingTarget returns all prefixes of path that may plausibly
-// exist as a module, excluding targetPrefix but otherwise including path
+// exist as a module, excluding the path prefixes of the main modules but
+// otherwise including path
 // itself, sorted by descending length. Prefixes that are not valid module paths
 // but are valid package paths (like "m" or "example.com/.gen") are included,
 // since they might be replaced.
@@ -702,7 +711,7 @@ func modulePrefixesExcludingTarget(path string) []string {
 	prefixes := make([]string, 0, strings.Count(path, "/")+1)
 
 	for {
-		if path != targetPrefix {
+		if mainModuleWithPrefix(path) == (module.Version{}) {
 			if _, _, ok := module.SplitPathVersion(path); ok {
 				prefixes = append(prefixes, path)
 			}
@@ -718,6 +727,17 @@ func modulePrefixesExcludingTarget(path string) []string {
 	return prefixes
 }
 
+// mainModuleWithPrefix returns the main module whose packages have the import
+// path prefix prefix, or the zero module.Version if there is none.
+func mainModuleWithPrefix(prefix string) module.Version {
+	for _, m := range MainModules.Versions() {
+		if MainModules.PathPrefix(m) == prefix {
+			return m
+		}
+	}
+	return module.Version{}
+}
+
 func queryPrefixModules(ctx context.Context, candidateModules []string, queryModule func(ctx context.Context, path string) (QueryResult, error)) (found []QueryResult, err error) {
 	ctx, span := trace.StartSpan(ctx, "modload.queryPrefixModules")
 	defer span.Done()
@@ -759,7 +779,7 @@ func queryPrefixModules(ctx context.Context, candidateModules []string, queryMod
 		case *PackageNotInModuleError:
 			// Given the option, prefer to attribute “package not in module”
 			// to modules other than the main one.
-			if noPackage == nil || noPackage.Mod == Target {
+			if noPackage == nil || MainModules.Contains(noPackage.Mod.Path) {
 				noPackage = rErr
 			}
 		case *NoMatchingVersionError:
@@ -885,11 +905,11 @@ type PackageNotInModuleError struct {
 }
 
 func (e *PackageNotInModuleError) Error() string {
-	if e.Mod == Target {
+	if e.Mod.Version == "" && MainModules.Contains(e.Mod.Path) {
 		if strings.Contains(e.Pattern, "...") {
-			return fmt.Sprintf("main module (%s) does not contain packages matching %s", Target.Path, e.Pattern)
+			return fmt.Sprintf("main module (%s) does not contain packages matching %s", e.Mod.Path, e.Pattern)
 		}
-		return fmt.Sprintf("main module (%s) does not contain package %s", Target.Path, e.Pattern)
+		return fmt.Sprintf("main module (%s) does not contain package %s", e.Mod.Path, e.Pattern)
 	}
 
 	found := ""
@@ -1117,11 +1137,18 @@ type QueryMatchesMainModuleError struct {
 }
 
 func (e *QueryMatchesMainModuleError) Error() string {
-	if e.Pattern == Target.Path {
+	if MainModules.Contains(e.Pattern) {
 		return fmt.Sprintf("can't request version %q of the main module (%s)", e.Query, e.Pattern)
 	}
 
-	return fmt.Sprintf("can't request version %q of pattern %q that includes the main module (%s)", e.Query, e.Pattern, Target.Path)
+	var paths []string
+	match := search.MatchPattern(e.Pattern)
+	for _, m := range MainModules.Versions() {
+		if match(m.Path) {
+			paths = append(paths, m.Path)
+		}
+	}
+	return fmt.Sprintf("can't request version %q of pattern %q that includes the main module (%s)", e.Query, e.Pattern, strings.Join(paths, ", "))
 }
 
 // A QueryMatchesPackagesInMainModuleError indicates that a query cannot be
@@ -0,0 +1,422 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package modload
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/cfg"
+	"cmd/go/internal/fsys"
+	"cmd/go/internal/lockedfile"
+	"cmd/go/internal/search"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// A WorkFile is the parsed, interpreted form of a go.work file.
+//
+// A go.work file uses the same syntax as a go.mod file, but accepts only
+// the go, use, and replace directives.
+type WorkFile struct {
+	Go      *modfile.Go
+	Use     []*WorkUse
+	Replace []*modfile.Replace
+
+	Syntax *modfile.FileSyntax
+}
+
+// A WorkUse is a single directory listed by a use directive.
+type WorkUse struct {
+	Path   string // directory path, as written in the go.work file
+	Syntax *modfile.Line
+}
+
+// ParseWorkFile parses and returns a go.work file.
+//
+// file is the name of the file, used in positions and errors.
+//
+// data is the content of the file.
+func ParseWorkFile(file string, data []byte) (*WorkFile, error) {
+	// The go.mod parser retains the syntax of statements it does not
+	// understand, so use it for lexing and interpret the statements here.
+	mf, err := modfile.ParseLax(file, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	f := &WorkFile{Syntax: mf.Syntax}
+	if err := f.interpret(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// interpret sets f.Go, f.Use and f.Replace from f.Syntax.
+func (f *WorkFile) interpret() error {
+	f.Go = nil
+	f.Use = nil
+	f.Replace = nil
+
+	var errs modfile.ErrorList
+	add := func(line *modfile.Line, verb string, args []string) {
+		wrapError := func(err error) {
+			errs = append(errs, modfile.Error{
+				Filename: f.Syntax.Name,
+				Pos:      line.Start,
+				Verb:     verb,
+				Err:      err,
+			})
+		}
+		switch verb {
+		default:
+			wrapError(fmt.Errorf("unknown directive: %s", verb))
+
+		case "go":
+			if f.Go != nil {
+				wrapError(errors.New("repeated go statement"))
+				return
+			}
+			if len(args) != 1 {
+				wrapError(errors.New("go directive expects exactly one argument"))
+				return
+			}
+			if !modfile.GoVersionRE.MatchString(args[0]) {
+				wrapError(fmt.Errorf("invalid go version '%s': must match format 1.23", args[0]))
+				return
+			}
+			f.Go = &modfile.Go{Version: args[0], Syntax: line}
+
+		case "use":
+			if len(args) != 1 {
+				wrapError(errors.New("usage: use local/dir"))
+				return
+			}
+			s, err := parseWorkString(args[0])
+			if err != nil {
+				wrapError(fmt.Errorf("invalid quoted string: %v", err))
+				return
+			}
+			f.Use = append(f.Use, &WorkUse{Path: s, Syntax: line})
+
+		case "replace":
+			r, err := parseWorkReplace(args)
+			if err != nil {
+				wrapError(err)
+				return
+			}
+			r.Syntax = line
+			f.Replace = append(f.Replace, r)
+		}
+	}
+
+	for _, x := range f.Syntax.Stmt {
+		switch x := x.(type) {
+		case *modfile.Line:
+			add(x, x.Token[0], x.Token[1:])
+
+		case *modfile.LineBlock:
+			if len(x.Token) > 1 {
+				errs = append(errs, modfile.Error{
+					Filename: f.Syntax.Name,
+					Pos:      x.Start,
+					Err:      fmt.Errorf("unknown block type: %s", strings.Join(x.Token, " ")),
+				})
+				continue
+			}
+			switch x.Token[0] {
+			default:
+				errs = append(errs, modfile.Error{
+					Filename: f.Syntax.Name,
+					Pos:      x.Start,
+					Err:      fmt.Errorf("unknown block type: %s", strings.Join(x.Token, " ")),
+				})
+			case "use", "replace":
+				for _, l := range x.Line {
+					add(l, x.Token[0], l.Token)
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// parseWorkString is like the go.mod parser's handling of quoted strings:
+// it unquotes s if it is a double-quoted string, and otherwise rejects
+// quotation marks.
+func parseWorkString(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) {
+		return strconv.Unquote(s)
+	}
+	if strings.ContainsAny(s, "\"'`") {
+		return "", errors.New("unquoted string cannot contain quote")
+	}
+	return s, nil
+}
+
+// parseWorkReplace parses the arguments of a replace directive,
+// which have the same form as in a go.mod file.
+func parseWorkReplace(args []string) (*modfile.Replace, error) {
+	const usage = "usage: replace module/path [v1.2.3] => other/module v1.4\n\t or replace module/path [v1.2.3] => ../local/directory"
+
+	arrow := 2
+	if len(args) >= 2 && args[1] == "=>" {
+		arrow = 1
+	}
+	if len(args) < arrow+2 || len(args) > arrow+3 || args[arrow] != "=>" {
+		return nil, errors.New(usage)
+	}
+
+	var r modfile.Replace
+	s, err := parseWorkString(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid quoted string: %v", err)
+	}
+	if err := module.CheckImportPath(s); err != nil {
+		return nil, fmt.Errorf("invalid module path: %v", err)
+	}
+	r.Old.Path = s
+	if arrow == 2 {
+		v, err := parseWorkString(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string: %v", err)
+		}
+		if module.CanonicalVersion(v) != v {
+			return nil, fmt.Errorf("invalid module version %q: must be of the form v1.2.3", v)
+		}
+		r.Old.Version = v
+	}
+
+	ns, err := parseWorkString(args[arrow+1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid quoted string: %v", err)
+	}
+	r.New.Path = ns
+	if len(args) == arrow+2 {
+		if !modfile.IsDirectoryPath(ns) {
+			return nil, errors.New("replacement module without version must be directory path (rooted or starting with ./ or ../)")
+		}
+		return &r, nil
+	}
+	if modfile.IsDirectoryPath(ns) {
+		return nil, fmt.Errorf("replacement module directory path %q cannot have version", ns)
+	}
+	if err := module.CheckImportPath(ns); err != nil {
+		return nil, fmt.Errorf("invalid module path: %v", err)
+	}
+	nv, err := parseWorkString(args[arrow+2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid quoted string: %v", err)
+	}
+	if module.CanonicalVersion(nv) != nv {
+		return nil, fmt.Errorf("invalid module version %q: must be of the form v1.2.3", nv)
+	}
+	r.New.Version = nv
+	return &r, nil
+}
+
+// modFile returns a modfile.File that shares f's syntax tree, so that the
+// go.mod editing methods can be used for the directives the two formats have
+// in common. The caller must call f.Cleanup after editing.
+func (f *WorkFile) modFile() *modfile.File {
+	return &modfile.File{Go: f.Go, Replace: f.Replace, Syntax: f.Syntax}
+}
+
+// AddGoStmt sets the go directive of f to version.
+func (f *WorkFile) AddGoStmt(version string) error {
+	mf := f.modFile()
+	if err := mf.AddGoStmt(version); err != nil {
+		return err
+	}
+	f.Go = mf.Go
+	return nil
+}
+
+// AddUse adds a use directive for the directory path, if f does not already
+// have one.
+func (f *WorkFile) AddUse(path string) {
+	for _, u := range f.Use {
+		if u.Path == path {
+			return
+		}
+	}
+	tok := modfile.AutoQuote(path)
+
+	// Add the directory to the last use block, converting a single use
+	// directive to a block if needed, as the go.mod editor does for require.
+	for i := len(f.Syntax.Stmt) - 1; i >= 0; i-- {
+		switch stmt := f.Syntax.Stmt[i].(type) {
+		case *modfile.Line:
+			if stmt.Token != nil && stmt.Token[0] == "use" {
+				stmt.InBlock = true
+				block := &modfile.LineBlock{Token: stmt.Token[:1], Line: []*modfile.Line{stmt}}
+				stmt.Token = stmt.Token[1:]
+				f.Syntax.Stmt[i] = block
+				line := &modfile.Line{Token: []string{tok}, InBlock: true}
+				block.Line = append(block.Line, line)
+				f.Use = append(f.Use, &WorkUse{Path: path, Syntax: line})
+				return
+			}
+		case *modfile.LineBlock:
+			if stmt.Token[0] == "use" {
+				line := &modfile.Line{Token: []string{tok}, InBlock: true}
+				stmt.Line = append(stmt.Line, line)
+				f.Use = append(f.Use, &WorkUse{Path: path, Syntax: line})
+				return
+			}
+		}
+	}
+	line := &modfile.Line{Token: []string{"use", tok}}
+	f.Syntax.Stmt = append(f.Syntax.Stmt, line)
+	f.Use = append(f.Use, &WorkUse{Path: path, Syntax: line})
+}
+
+// DropUse removes the use directive for the directory path, if any.
+func (f *WorkFile) DropUse(path string) {
+	for _, u := range f.Use {
+		if u.Path == path {
+			u.Syntax.Token = nil
+			u.Syntax.Comments.Suffix = nil
+		}
+	}
+}
+
+// AddReplace adds a replace directive to f, replacing any existing
+// replacement for oldPath@oldVers.
+func (f *WorkFile) AddReplace(oldPath, oldVers, newPath, newVers string) error {
+	mf := f.modFile()
+	if err := mf.AddReplace(oldPath, oldVers, newPath, newVers); err != nil {
+		return err
+	}
+	f.Replace = mf.Replace
+	return nil
+}
+
+// DropReplace removes the replace directive for oldPath@oldVers, if any.
+func (f *WorkFile) DropReplace(oldPath, oldVers string) error {
+	return f.modFile().DropReplace(oldPath, oldVers)
+}
+
+// Cleanup removes the directives dropped by edits from f's syntax tree
+// and refreshes f's interpreted fields.
+func (f *WorkFile) Cleanup() {
+	f.Syntax.Cleanup()
+	if err := f.interpret(); err != nil {
+		base.Fatalf("go: internal error: %v", err)
+	}
+}
+
+// Format returns the formatted contents of f.
+func (f *WorkFile) Format() []byte {
+	return modfile.Format(f.Syntax)
+}
+
+// ReadWorkFile reads and parses the go.work file at path.
+func ReadWorkFile(path string) (*WorkFile, error) {
+	data, err := lockedfile.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseWorkFile(path, data)
+}
+
+// WriteWorkFile cleans up and formats wf, and writes it to path.
+func WriteWorkFile(path string, wf *WorkFile) error {
+	wf.Cleanup()
+	return lockedfile.Write(path, strings.NewReader(string(wf.Format())), 0666)
+}
+
+// WorkFilePath returns the path of the go.work file in use,
+// or "" if workspace mode is disabled.
+func WorkFilePath() string {
+	Init()
+	return workFilePath
+}
+
+// inWorkspaceMode reports whether the go command is running in workspace
+// mode, in which the main modules are the modules listed in a go.work file.
+func inWorkspaceMode() bool {
+	Init()
+	return workFilePath != ""
+}
+
+// FindGoWork returns the path of the go.work file that applies to the
+// directory wd: the file named by GOWORK, or the first go.work file found in
+// wd or one of its parents. It returns "" if GOWORK=off or no file is found.
+func FindGoWork(wd string) string {
+	gowork := cfg.Getenv("GOWORK")
+	switch gowork {
+	case "off":
+		return ""
+	case "", "auto":
+		return findWorkspaceFile(wd)
+	}
+	if !filepath.IsAbs(gowork) {
+		base.Fatalf("go: invalid GOWORK: %q is not an absolute path", gowork)
+	}
+	return gowork
+}
+
+// findWorkspaceFile returns the path of the go.work file in dir or its
+// closest parent directory, or "" if there is none.
+func findWorkspaceFile(dir string) string {
+	if dir == "" {
+		panic("dir not set")
+	}
+	dir = filepath.Clean(dir)
+
+	for {
+		f := filepath.Join(dir, "go.work")
+		if fi, err := fsys.Stat(f); err == nil && !fi.IsDir() {
+			if search.InDir(dir, os.TempDir()) == "." {
+				// Like go.mod, ignore a go.work file in the system temp root.
+				// See golang.org/issue/26708.
+				return ""
+			}
+			return f
+		}
+		d := filepath.Dir(dir)
+		if d == dir {
+			break
+		}
+		if d == cfg.GOROOT {
+			// Don't look for a go.work file above GOROOT: the standard library
+			// and commands must not be built with an unrelated workspace.
+			return ""
+		}
+		dir = d
+	}
+	return ""
+}
+
+// workModRoots returns the absolute root directories of the modules listed
+// in the use directives of wf, which was read from the file at path.
+func workModRoots(path string, wf *WorkFile) ([]string, error) {
+	workDir := filepath.Dir(path)
+	seen := make(map[string]bool)
+	var roots []string
+	for _, u := range wf.Use {
+		dir := filepath.FromSlash(u.Path)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workDir, dir)
+		}
+		dir = filepath.Clean(dir)
+		if seen[dir] {
+			return nil, fmt.Errorf("%s:%d: path %s appears multiple times in workspace", base.ShortPath(path), u.Syntax.Start.Line, u.Path)
+		}
+		seen[dir] = true
+		roots = append(roots, dir)
+	}
+	return roots, nil
+}
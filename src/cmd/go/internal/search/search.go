@@ -202,10 +202,12 @@ func (m *Match) MatchPackages() {
 	}
 }
 
-var modRoot string
+var modRoots []string
 
-func SetModRoot(dir string) {
-	modRoot = dir
+// SetModRoots sets the root directories of the main modules.
+// MatchDirs reports an error for patterns outside of all of them.
+func SetModRoots(dirs []string) {
+	modRoots = dirs
 }
 
 // MatchDirs sets m.Dirs to a non-nil slice containing all directories that
@@ -253,14 +255,25 @@ func (m *Match) MatchDirs() {
 	// We need to preserve the ./ for pattern matching
 	// and in the returned import paths.
 
-	if modRoot != "" {
+	if len(modRoots) > 0 {
 		abs, err := filepath.Abs(dir)
 		if err != nil {
 			m.AddError(err)
 			return
 		}
-		if !hasFilepathPrefix(abs, modRoot) {
-			m.AddError(fmt.Errorf("directory %s is outside module root (%s)", abs, modRoot))
+		found := false
+		for _, modRoot := range modRoots {
+			if hasFilepathPrefix(abs, modRoot) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			if len(modRoots) == 1 {
+				m.AddError(fmt.Errorf("directory %s is outside module root (%s)", abs, modRoots[0]))
+			} else {
+				m.AddError(fmt.Errorf("directory %s is outside the modules listed in go.work", abs))
+			}
 			return
 		}
 	}
@@ -7,6 +7,7 @@ package work
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"go/build"
 	exec "internal/execabs"
@@ -55,6 +56,24 @@ will be written to that directory.
 The -i flag installs the packages that are dependencies of the target.
 The -i flag is deprecated. Compiled packages are cached automatically.
 
+The -cover flag, also accepted by install and run, builds a program
+instrumented for coverage analysis. Each time the program exits, it
+writes a coverage data file to the directory named by the GOCOVERDIR
+environment variable. Use 'go tool covdata' to merge the data files
+and convert them into the profile format written by
+'go test -coverprofile'. By default, only the packages named on the
+command line, or in the main modules when building a module-aware
+program, are instrumented. The related flags are:
+
+	-covermode set,count,atomic
+		set the mode for coverage analysis, as for 'go test'.
+		The default is "set" unless -race is enabled,
+		in which case it is "atomic".
+		Setting -covermode implies -cover.
+	-coverpkg pattern1,pattern2,pattern3
+		instrument the packages matching the patterns
+		instead of the default set. Setting -coverpkg implies -cover.
+
 The build flags are shared by the build, clean, get, install, list, run,
 and test commands:
 
@@ -132,6 +151,11 @@ and test commands:
 		include path must be in the same directory as the Go package they are
 		included from, and overlays will not appear when binaries and tests are
 		run through go run and go test respectively.
+	-pgo file
+		specify the file path of a profile for profile-guided optimization (PGO).
+		The profile must be a CPU profile in the pprof format, such as
+		one collected by runtime/pprof or net/http/pprof.
+		The special name "off" turns off PGO, which is the default.
 	-pkgdir dir
 		install and load all packages from dir instead of the usual locations.
 		For example, when building with a non-standard configuration,
@@ -201,6 +225,8 @@ func init() {
 
 	AddBuildFlags(CmdBuild, DefaultBuildFlags)
 	AddBuildFlags(CmdInstall, DefaultBuildFlags)
+	AddCoverFlags(CmdBuild)
+	AddCoverFlags(CmdInstall)
 }
 
 // Note that flags consulted by other parts of the code
@@ -286,6 +312,7 @@ func AddBuildFlags(cmd *base.Command, mask BuildFlagMask) {
 	cmd.Flag.StringVar(&cfg.BuildContext.InstallSuffix, "installsuffix", "", "")
 	cmd.Flag.Var(&load.BuildLdflags, "ldflags", "")
 	cmd.Flag.BoolVar(&cfg.BuildLinkshared, "linkshared", false, "")
+	cmd.Flag.StringVar(&cfg.BuildPGO, "pgo", "", "")
 	cmd.Flag.StringVar(&cfg.BuildPkgdir, "pkgdir", "", "")
 	cmd.Flag.BoolVar(&cfg.BuildRace, "race", false, "")
 	cmd.Flag.BoolVar(&cfg.BuildMSan, "msan", false, "")
@@ -299,6 +326,55 @@ func AddBuildFlags(cmd *base.Command, mask BuildFlagMask) {
 	cmd.Flag.StringVar(&cfg.DebugTrace, "debug-trace", "", "")
 }
 
+// AddCoverFlags adds the coverage flags to the build, install, and
+// run commands. The test command has its own coverage flags.
+func AddCoverFlags(cmd *base.Command) {
+	cmd.Flag.BoolVar(&cfg.BuildCover, "cover", false, "")
+	cmd.Flag.Var(coverFlag{(*coverModeFlag)(&cfg.BuildCoverMode)}, "covermode", "")
+	cmd.Flag.Var(coverFlag{(*commaListFlag)(&cfg.BuildCoverPkg)}, "coverpkg", "")
+}
+
+// coverFlag is a flag.Value that also implies -cover.
+type coverFlag struct{ v flag.Value }
+
+func (f coverFlag) String() string { return f.v.String() }
+
+func (f coverFlag) Set(value string) error {
+	if err := f.v.Set(value); err != nil {
+		return err
+	}
+	cfg.BuildCover = true
+	return nil
+}
+
+// coverModeFlag is the implementation of the -covermode flag.
+type coverModeFlag string
+
+func (f *coverModeFlag) String() string { return string(*f) }
+func (f *coverModeFlag) Set(value string) error {
+	switch value {
+	case "", "set", "count", "atomic":
+		*f = coverModeFlag(value)
+		return nil
+	default:
+		return errors.New(`valid modes are "set", "count", or "atomic"`)
+	}
+}
+
+// commaListFlag is a flag.Value for a comma-separated list.
+type commaListFlag []string
+
+func (f *commaListFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *commaListFlag) Set(value string) error {
+	if value == "" {
+		*f = nil
+	} else {
+		*f = strings.Split(value, ",")
+	}
+	return nil
+}
+
 // tagsFlag is the implementation of the -tags flag.
 type tagsFlag []string
 
@@ -371,6 +447,10 @@ func runBuild(ctx context.Context, cmd *base.Command, args []string) {
 	pkgs := load.PackagesAndErrors(ctx, load.PackageOpts{}, args)
 	load.CheckPackageErrors(pkgs)
 
+	if cfg.BuildCover {
+		load.PrepareForCoverageBuild(pkgs)
+	}
+
 	explicitO := len(cfg.BuildO) > 0
 
 	if len(pkgs) == 1 && pkgs[0].Name == "main" && cfg.BuildO == "" {
@@ -654,6 +734,9 @@ func InstallPackages(ctx context.Context, patterns []string, pkgs []*load.Packag
 	}
 
 	pkgs = omitTestOnly(pkgsFilter(pkgs))
+	if cfg.BuildCover {
+		load.PrepareForCoverageBuild(pkgs)
+	}
 	for _, p := range pkgs {
 		if p.Target == "" {
 			switch {
@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -280,6 +281,12 @@ func (b *Builder) buildActionID(a *Action) cache.ActionID {
 	}
 	if p.Internal.CoverMode != "" {
 		fmt.Fprintf(h, "cover %q %q\n", p.Internal.CoverMode, b.toolID("cover"))
+		if cfg.BuildCover {
+			fmt.Fprintf(h, "coverregister\n")
+		}
+	}
+	if p.Internal.FuzzInstrument {
+		fmt.Fprintf(h, "fuzz\n")
 	}
 	fmt.Fprintf(h, "modinfo %q\n", p.Internal.BuildInfo)
 
@@ -289,6 +296,9 @@ func (b *Builder) buildActionID(a *Action) cache.ActionID {
 		base.Fatalf("buildActionID: unknown build toolchain %q", cfg.BuildToolchainName)
 	case "gc":
 		fmt.Fprintf(h, "compile %s %q %q\n", b.toolID("compile"), forcedGcflags, p.Internal.Gcflags)
+		if cfg.BuildPGO != "" {
+			fmt.Fprintf(h, "pgofile %s\n", b.fileHash(cfg.BuildPGO))
+		}
 		if len(p.SFiles) > 0 {
 			fmt.Fprintf(h, "asm %q %q %q\n", b.toolID("asm"), forcedAsmflags, p.Internal.Asmflags)
 		}
@@ -635,6 +645,17 @@ OverlayLoop:
 				cgofiles[i-len(gofiles)] = coverFile
 			}
 		}
+
+		// With go build -cover, there is no test main to report the
+		// counters, so register them with the coverage runtime,
+		// which writes them out when the program exits.
+		if cfg.BuildCover {
+			regFile := objdir + "_covinit_.go"
+			if err := b.writeFile(regFile, coverRegistration(a.Package)); err != nil {
+				return err
+			}
+			gofiles = append(gofiles, regFile)
+		}
 	}
 
 	// Run cgo.
@@ -1867,6 +1888,30 @@ func (b *Builder) cover(a *Action, dst, src string, varName string) error {
 		src)
 }
 
+// coverRegistration returns the source of a file that registers the
+// coverage counters of p with the coverage runtime during
+// initialization.
+func coverRegistration(p *load.Package) []byte {
+	var files []string
+	for file := range p.Internal.CoverVars {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by go build -cover. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", p.Name)
+	fmt.Fprintf(&buf, "import _cover_ %q\n\n", load.CoverRuntimePkg)
+	fmt.Fprintf(&buf, "func init() {\n")
+	for _, file := range files {
+		cv := p.Internal.CoverVars[file]
+		fmt.Fprintf(&buf, "\t_cover_.RegisterFile(%q, %q, %s.Count[:], %s.Pos[:], %s.NumStmt[:])\n",
+			p.Internal.CoverMode, cv.File, cv.Var, cv.Var, cv.Var)
+	}
+	fmt.Fprintf(&buf, "}\n")
+	return buf.Bytes()
+}
+
 var objectMagic = [][]byte{
 	{'!', '<', 'a', 'r', 'c', 'h', '>', '\n'}, // Package archive
 	{'<', 'b', 'i', 'g', 'a', 'f', '>', '\n'}, // Package AIX big archive
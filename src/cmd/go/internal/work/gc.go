@@ -116,7 +116,7 @@ func (gcToolchain) gc(b *Builder, a *Action, archive string, importcfg, embedcfg
 	extFiles := len(p.CgoFiles) + len(p.CFiles) + len(p.CXXFiles) + len(p.MFiles) + len(p.FFiles) + len(p.SFiles) + len(p.SysoFiles) + len(p.SwigFiles) + len(p.SwigCXXFiles)
 	if p.Standard {
 		switch p.ImportPath {
-		case "bytes", "internal/poll", "net", "os":
+		case "bytes", "internal/coverage/cfile", "internal/poll", "net", "os":
 			fallthrough
 		case "runtime/metrics", "runtime/pprof", "runtime/trace":
 			fallthrough
@@ -142,6 +142,12 @@ func (gcToolchain) gc(b *Builder, a *Action, archive string, importcfg, embedcfg
 	if symabis != "" {
 		gcargs = append(gcargs, "-symabis", symabis)
 	}
+	if p.Internal.FuzzInstrument {
+		gcargs = append(gcargs, "-d=libfuzzer")
+	}
+	if cfg.BuildPGO != "" {
+		gcargs = append(gcargs, "-pgoprofile", cfg.BuildPGO)
+	}
 
 	gcflags := str.StringList(forcedGcflags, p.Internal.Gcflags)
 	if compilingRuntime {
@@ -40,6 +40,37 @@ func BuildInit() {
 		cfg.BuildPkgdir = p
 	}
 
+	// Make sure -pgo is absolute, for the same reason,
+	// and that the profile exists.
+	if cfg.BuildPGO == "off" {
+		cfg.BuildPGO = ""
+	}
+	if cfg.BuildPGO != "" {
+		p, err := filepath.Abs(cfg.BuildPGO)
+		if err == nil {
+			_, err = os.Stat(p)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "go %s: -pgo: %v\n", flag.Args()[0], err)
+			base.SetExitStatus(2)
+			base.Exit()
+		}
+		cfg.BuildPGO = p
+	}
+
+	if cfg.BuildCover {
+		if cfg.BuildCoverMode == "" {
+			cfg.BuildCoverMode = "set"
+			if cfg.BuildRace {
+				// Default coverage mode is atomic when -race is set.
+				cfg.BuildCoverMode = "atomic"
+			}
+		}
+		if cfg.BuildRace && cfg.BuildCoverMode != "atomic" {
+			base.Fatalf(`go %s: -covermode must be "atomic", not %q, when -race is enabled`, flag.Args()[0], cfg.BuildCoverMode)
+		}
+	}
+
 	if cfg.BuildP <= 0 {
 		base.Fatalf("go: -p must be a positive integer: %v\n", cfg.BuildP)
 	}
@@ -0,0 +1,62 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// go work init
+
+package workcmd
+
+import (
+	"context"
+	"path/filepath"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/fsys"
+	"cmd/go/internal/modload"
+)
+
+var cmdInit = &base.Command{
+	UsageLine: "go work init [moddirs]",
+	Short:     "initialize workspace file",
+	Long: `
+Init initializes and writes a new go.work file in the current directory,
+in effect creating a new workspace rooted at the current directory.
+
+Init optionally accepts the directories of the workspace modules as
+arguments, and adds a use directive for each of them. If no arguments
+are given, an empty workspace with no modules is created. The go
+directive of the new file is set to the current Go version.
+
+See 'go help work' for more about workspaces.
+	`,
+	Run: runInit,
+}
+
+func runInit(ctx context.Context, cmd *base.Command, args []string) {
+	modload.ForceUseModules = true
+
+	gowork := filepath.Join(base.Cwd(), "go.work")
+	if _, err := fsys.Stat(gowork); err == nil {
+		base.Fatalf("go: %s already exists", base.ShortPath(gowork))
+	}
+
+	wf, err := modload.ParseWorkFile(gowork, nil)
+	if err != nil {
+		base.Fatalf("go: internal error: %v", err)
+	}
+	if err := wf.AddGoStmt(modload.LatestGoVersion()); err != nil {
+		base.Fatalf("go: internal error: %v", err)
+	}
+	for _, dir := range args {
+		if !hasGoMod(absDir(dir)) {
+			base.Errorf("go: directory %s does not contain a go.mod file", base.ShortPath(dir))
+			continue
+		}
+		wf.AddUse(usePath(filepath.Dir(gowork), dir))
+	}
+	base.ExitIfErrors()
+
+	if err := modload.WriteWorkFile(gowork, wf); err != nil {
+		base.Fatalf("go: %v", err)
+	}
+}
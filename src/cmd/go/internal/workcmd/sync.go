@@ -0,0 +1,91 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// go work sync
+
+package workcmd
+
+import (
+	"context"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/modload"
+
+	"golang.org/x/mod/module"
+)
+
+var cmdSync = &base.Command{
+	UsageLine: "go work sync",
+	Short:     "sync workspace build list to modules",
+	Long: `
+Sync syncs the workspace's build list back to the workspace's modules.
+
+The workspace's build list is the set of versions of all the (transitive)
+dependency modules used to do builds in the workspace. It is computed
+using minimal version selection over the requirements of all the
+workspace modules together, so a module may be built in the workspace
+with a higher version of a dependency than its own go.mod file requires.
+
+Sync upgrades each requirement in the go.mod file of each workspace module
+to the version selected for the workspace, if that version is higher,
+updating the module's go.mod and go.sum files as 'go get' would. Sync never
+downgrades requirements, and does not add or remove requirements on the
+other workspace modules.
+
+See 'go help work' for more about workspaces.
+	`,
+	Run: runSync,
+}
+
+func init() {
+	base.AddModCommonFlags(&cmdSync.Flag)
+}
+
+func runSync(ctx context.Context, cmd *base.Command, args []string) {
+	if len(args) > 0 {
+		base.Fatalf("go work sync: sync takes no arguments")
+	}
+	modload.ForceUseModules = true
+	if modload.WorkFilePath() == "" {
+		base.Fatalf("go: no go.work file found\n\t(run 'go work init' first or specify path using GOWORK environment variable)")
+	}
+
+	mg := modload.LoadModGraph(ctx, "")
+	mms := modload.MainModules
+
+	// For each workspace module, find the requirements of its go.mod file
+	// that the workspace selects at a higher version.
+	type target struct {
+		root       string
+		mustSelect []module.Version
+	}
+	var targets []target
+	for _, m := range mms.Versions() {
+		t := target{root: mms.ModRoot(m)}
+		reqs, _ := mg.RequiredBy(m)
+		for _, r := range reqs {
+			if mms.Contains(r.Path) {
+				continue
+			}
+			if v := mg.Selected(r.Path); v != r.Version {
+				t.mustSelect = append(t.mustSelect, module.Version{Path: r.Path, Version: v})
+			}
+		}
+		targets = append(targets, t)
+	}
+
+	// Record any checksums the workspace needed before leaving workspace mode.
+	modload.WriteGoMod(ctx)
+
+	for _, t := range targets {
+		modload.EnterModule(ctx, t.root)
+		if len(t.mustSelect) == 0 {
+			continue
+		}
+		if _, err := modload.EditBuildList(ctx, nil, t.mustSelect); err != nil {
+			base.Errorf("go: %s: %v", base.ShortPath(t.root), err)
+		}
+	}
+	base.ExitIfErrors()
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// go work use
+
+package workcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/fsys"
+	"cmd/go/internal/modload"
+	"cmd/go/internal/search"
+)
+
+var cmdUse = &base.Command{
+	UsageLine: "go work use [-r] moddirs",
+	Short:     "add modules to workspace file",
+	Long: `
+Use provides a command-line interface for adding directories,
+optionally recursively, to a go.work file.
+
+A use directive is added to the go.work file for each argument directory
+that contains a go.mod file, and any use directive for an argument
+directory that no longer contains a go.mod file is removed.
+
+The -r flag searches recursively for modules in the argument directories,
+and the use command operates as if each of the directories found had been
+specified as an argument: use directives are added for the directories that
+contain a go.mod file, and removed for listed directories within the
+argument directories that no longer contain one.
+
+See 'go help work' for more about workspaces.
+	`,
+}
+
+var useR = cmdUse.Flag.Bool("r", false, "")
+
+func init() {
+	cmdUse.Run = runUse // break init cycle
+}
+
+func runUse(ctx context.Context, cmd *base.Command, args []string) {
+	modload.ForceUseModules = true
+
+	if len(args) == 0 {
+		base.Fatalf("go: 'go work use' requires one or more directory arguments")
+	}
+	gowork := modload.WorkFilePath()
+	if gowork == "" {
+		base.Fatalf("go: no go.work file found\n\t(run 'go work init' first or specify path using GOWORK environment variable)")
+	}
+	wf, err := modload.ReadWorkFile(gowork)
+	if err != nil {
+		base.Fatalf("go: %v", err)
+	}
+	workDir := filepath.Dir(gowork)
+
+	// haveDirs maps the absolute directory of each existing use directive to
+	// the paths used to name it in the go.work file.
+	haveDirs := make(map[string][]string)
+	for _, u := range wf.Use {
+		dir := filepath.FromSlash(u.Path)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workDir, dir)
+		}
+		dir = filepath.Clean(dir)
+		haveDirs[dir] = append(haveDirs[dir], u.Path)
+	}
+
+	// addDirs maps each absolute directory to add to the path by which it
+	// was named on the command line.
+	addDirs := make(map[string]string)
+	removeDirs := make(map[string]bool)
+	lookDir := func(dir string) {
+		abs := absDir(dir)
+		if hasGoMod(abs) {
+			addDirs[abs] = dir
+		} else {
+			removeDirs[abs] = true
+		}
+	}
+
+	for _, useDir := range args {
+		if !*useR {
+			lookDir(useDir)
+			continue
+		}
+
+		// Add or remove entries for any subdirectories that still exist.
+		err := fsys.Walk(useDir, func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				if info.Mode()&fs.ModeSymlink != 0 {
+					if target, err := fsys.Stat(path); err == nil && target.IsDir() {
+						fmt.Fprintf(os.Stderr, "go: warning: ignoring symlink %s\n", path)
+					}
+				}
+				return nil
+			}
+			lookDir(path)
+			return nil
+		})
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			base.Errorf("go: %v", err)
+		}
+
+		// Remove entries for subdirectories that no longer exist.
+		abs := absDir(useDir)
+		for dir := range haveDirs {
+			if search.InDir(dir, abs) != "" && !hasGoMod(dir) {
+				removeDirs[dir] = true
+			}
+		}
+	}
+	base.ExitIfErrors()
+
+	for dir := range removeDirs {
+		for _, path := range haveDirs[dir] {
+			wf.DropUse(path)
+		}
+	}
+	var dirs []string
+	for dir := range addDirs {
+		if len(haveDirs[dir]) == 0 {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		wf.AddUse(usePath(workDir, addDirs[dir]))
+	}
+
+	if err := modload.WriteWorkFile(gowork, wf); err != nil {
+		base.Fatalf("go: %v", err)
+	}
+}
+
+// absDir returns the clean absolute path of dir,
+// interpreting a relative dir relative to the current directory.
+func absDir(dir string) string {
+	if filepath.IsAbs(dir) {
+		return filepath.Clean(dir)
+	}
+	return filepath.Join(base.Cwd(), dir)
+}
+
+// hasGoMod reports whether the directory dir contains a go.mod file.
+func hasGoMod(dir string) bool {
+	fi, err := fsys.Stat(filepath.Join(dir, "go.mod"))
+	return err == nil && !fi.IsDir()
+}
+
+// usePath returns the path to record in a use directive of the go.work file
+// in workDir for the module directory dir, as named on the command line.
+// An absolute dir is kept as is. A relative dir is rewritten to be relative to
+// workDir, and begins with "./" or "../" unless it is "." or "..".
+func usePath(workDir, dir string) string {
+	if filepath.IsAbs(dir) {
+		return filepath.ToSlash(filepath.Clean(dir))
+	}
+	rel, err := filepath.Rel(workDir, absDir(dir))
+	if err != nil {
+		return filepath.ToSlash(absDir(dir))
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return rel
+	}
+	return "./" + rel
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package workcmd implements the ``go work'' command.
+package workcmd
+
+import (
+	"cmd/go/internal/base"
+)
+
+var CmdWork = &base.Command{
+	UsageLine: "go work",
+	Short:     "workspace maintenance",
+	Long: `Go work provides access to operations on workspaces.
+
+Note that support for workspaces is built into many other commands,
+not just 'go work'. See 'go help modules' for information about Go's
+module system, of which workspaces are a part.
+
+A workspace is described by a go.work file, which lists a set of module
+directories with "use" directives. The go command treats all of those
+modules as main modules: packages in any of them may be named on the
+command line, and imports of their packages are resolved to the local
+directories rather than to versions listed in go.mod files. This makes
+it possible to change several modules together without adding and later
+removing replace directives.
+
+go.work files are line-oriented, and use the same syntax as go.mod files.
+Each line holds a single directive, made up of a keyword followed by
+arguments. For example:
+
+	go 1.18
+
+	use ./hello
+	use ../example/util
+
+	replace golang.org/x/net v1.2.3 => ./net
+
+As in go.mod files, the leading keyword can be factored out of adjacent
+lines to create a block:
+
+	use (
+		./hello
+		../example/util
+	)
+
+The use directive adds the module whose go.mod file is in the given
+directory to the workspace. Relative directories are interpreted
+relative to the directory containing the go.work file.
+
+The go directive records the Go version the file was written for.
+
+The replace directive has the same syntax as in a go.mod file. Replacements
+in the go.work file take precedence over those in the go.mod files of the
+workspace modules, and must be used to resolve conflicting replacements
+of the same module by different workspace modules.
+
+The go command looks for a go.work file in the current directory and
+its parent directories, unless the GOWORK environment variable names the
+file to use or is set to "off" to disable workspace mode. Use
+'go env GOWORK' to see which go.work file, if any, is in use.
+
+In workspace mode, the go.mod files of the workspace modules are never
+modified, and commands that exist to update a single go.mod file
+('go get', 'go mod tidy' and 'go mod vendor') are not available.
+Checksums needed by the workspace that are not present in the go.sum
+files of its modules are recorded in a go.work.sum file next to go.work.
+	`,
+
+	Commands: []*base.Command{
+		cmdEdit,
+		cmdInit,
+		cmdSync,
+		cmdUse,
+	},
+}
@@ -0,0 +1,344 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdh_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// Check that PublicKey and PrivateKey implement the interfaces documented in
+// crypto.PublicKey and crypto.PrivateKey.
+var _ interface {
+	Equal(x crypto.PublicKey) bool
+} = &ecdh.PublicKey{}
+var _ interface {
+	Public() crypto.PublicKey
+	Equal(x crypto.PrivateKey) bool
+} = &ecdh.PrivateKey{}
+
+var curves = []ecdh.Curve{ecdh.P256(), ecdh.P384(), ecdh.P521(), ecdh.X25519()}
+
+func TestECDH(t *testing.T) {
+	for _, curve := range curves {
+		t.Run(fmt.Sprint(curve), func(t *testing.T) {
+			aliceKey, err := curve.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bobKey, err := curve.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			alicePubKey, err := curve.NewPublicKey(aliceKey.PublicKey().Bytes())
+			if err != nil {
+				t.Error(err)
+			}
+			if !alicePubKey.Equal(aliceKey.PublicKey()) {
+				t.Error("encoded and decoded public keys are different")
+			}
+			if !alicePubKey.Equal(aliceKey.Public()) {
+				t.Error("encoded and decoded public keys are different")
+			}
+
+			alicePrivKey, err := curve.NewPrivateKey(aliceKey.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !alicePrivKey.Equal(aliceKey) {
+				t.Error("encoded and decoded private keys are different")
+			}
+			if alicePrivKey.Equal(bobKey) {
+				t.Error("different private keys are equal")
+			}
+
+			bobSecret, err := bobKey.ECDH(aliceKey.PublicKey())
+			if err != nil {
+				t.Fatal(err)
+			}
+			aliceSecret, err := aliceKey.ECDH(bobKey.PublicKey())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(bobSecret, aliceSecret) {
+				t.Error("two ECDH computations came out different")
+			}
+		})
+	}
+}
+
+func TestMismatchedCurves(t *testing.T) {
+	for _, c1 := range curves {
+		for _, c2 := range curves {
+			if c1 == c2 {
+				continue
+			}
+			k1, err := c1.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			k2, err := c2.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := k1.ECDH(k2.PublicKey()); err == nil {
+				t.Errorf("%v ECDH with %v public key succeeded", c1, c2)
+			}
+		}
+	}
+}
+
+func TestNISTAgainstElliptic(t *testing.T) {
+	for _, c := range []struct {
+		curve    ecdh.Curve
+		elliptic elliptic.Curve
+	}{
+		{ecdh.P256(), elliptic.P256()},
+		{ecdh.P384(), elliptic.P384()},
+		{ecdh.P521(), elliptic.P521()},
+	} {
+		t.Run(c.elliptic.Params().Name, func(t *testing.T) {
+			priv, x, y, err := elliptic.GenerateKey(c.elliptic, rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			k, err := c.curve.NewPrivateKey(priv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := k.PublicKey().Bytes(), elliptic.Marshal(c.elliptic, x, y); !bytes.Equal(got, want) {
+				t.Errorf("public key = %x, want %x", got, want)
+			}
+
+			peer, err := c.curve.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			secret, err := k.ECDH(peer.PublicKey())
+			if err != nil {
+				t.Fatal(err)
+			}
+			px, py := elliptic.Unmarshal(c.elliptic, peer.PublicKey().Bytes())
+			sx, _ := c.elliptic.ScalarMult(px, py, priv)
+			want := sx.FillBytes(make([]byte, (c.elliptic.Params().BitSize+7)/8))
+			if !bytes.Equal(secret, want) {
+				t.Errorf("shared secret = %x, want %x", secret, want)
+			}
+		})
+	}
+}
+
+func TestGenerateKeyMatchesElliptic(t *testing.T) {
+	// With the same fixed random stream, GenerateKey should produce the same
+	// keys as crypto/elliptic.GenerateKey.
+	for _, c := range []struct {
+		curve    ecdh.Curve
+		elliptic elliptic.Curve
+	}{
+		{ecdh.P256(), elliptic.P256()},
+		{ecdh.P384(), elliptic.P384()},
+		{ecdh.P521(), elliptic.P521()},
+	} {
+		k, err := c.curve.GenerateKey(zeroReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, _, _, err := elliptic.GenerateKey(c.elliptic, zeroReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(k.Bytes(), priv) {
+			t.Errorf("%v: GenerateKey = %x, want %x", c.curve, k.Bytes(), priv)
+		}
+	}
+}
+
+type zr struct{}
+
+func (zr) Read(dst []byte) (int, error) {
+	for i := range dst {
+		dst[i] = 0
+	}
+	return len(dst), nil
+}
+
+var zeroReader io.Reader = zr{}
+
+func TestX25519(t *testing.T) {
+	// Test vectors from RFC 7748, Section 6.1.
+	alicePriv := hexDecode(t, "77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a")
+	alicePub := hexDecode(t, "8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a")
+	bobPriv := hexDecode(t, "5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb")
+	bobPub := hexDecode(t, "de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f")
+	shared := hexDecode(t, "4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742")
+
+	alice, err := ecdh.X25519().NewPrivateKey(alicePriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := alice.PublicKey().Bytes(); !bytes.Equal(got, alicePub) {
+		t.Errorf("alice public key = %x, want %x", got, alicePub)
+	}
+	bob, err := ecdh.X25519().NewPrivateKey(bobPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bob.PublicKey().Bytes(); !bytes.Equal(got, bobPub) {
+		t.Errorf("bob public key = %x, want %x", got, bobPub)
+	}
+	secret, err := alice.ECDH(bob.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret, shared) {
+		t.Errorf("shared secret = %x, want %x", secret, shared)
+	}
+}
+
+func TestX25519AgainstXCrypto(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		k, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Use random, possibly non-canonical u-coordinates as peer keys.
+		peer := make([]byte, 32)
+		if _, err := rand.Read(peer); err != nil {
+			t.Fatal(err)
+		}
+		pub, err := ecdh.X25519().NewPublicKey(peer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := k.ECDH(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := curve25519.X25519(k.Bytes(), peer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("X25519(%x, %x) = %x, want %x", k.Bytes(), peer, got, want)
+		}
+	}
+}
+
+func TestX25519LowOrderPoint(t *testing.T) {
+	k, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"e0eb7a7c3b41b8ae1656e3faf19fc46ada098deb9c32b1fd866205165f49b800",
+	} {
+		pub, err := ecdh.X25519().NewPublicKey(hexDecode(t, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := k.ECDH(pub); err == nil {
+			t.Errorf("ECDH with low order point %s succeeded", p)
+		}
+	}
+}
+
+func TestInvalidKeys(t *testing.T) {
+	for _, c := range []struct {
+		curve ecdh.Curve
+		order *big.Int
+		size  int
+	}{
+		{ecdh.P256(), elliptic.P256().Params().N, 32},
+		{ecdh.P384(), elliptic.P384().Params().N, 48},
+		{ecdh.P521(), elliptic.P521().Params().N, 66},
+	} {
+		t.Run(fmt.Sprint(c.curve), func(t *testing.T) {
+			nMinus1 := new(big.Int).Sub(c.order, big.NewInt(1))
+			nPlus1 := new(big.Int).Add(c.order, big.NewInt(1))
+			if _, err := c.curve.NewPrivateKey(nMinus1.FillBytes(make([]byte, c.size))); err != nil {
+				t.Errorf("NewPrivateKey(N-1) = %v", err)
+			}
+			for _, k := range [][]byte{
+				make([]byte, c.size),
+				c.order.FillBytes(make([]byte, c.size)),
+				nPlus1.FillBytes(make([]byte, c.size)),
+				bytes.Repeat([]byte{0xff}, c.size),
+				nMinus1.Bytes()[1:],
+			} {
+				if _, err := c.curve.NewPrivateKey(k); err == nil {
+					t.Errorf("NewPrivateKey(%x) succeeded, want error", k)
+				}
+			}
+
+			k, err := c.curve.GenerateKey(rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub := k.PublicKey().Bytes()
+			offCurve := append([]byte{}, pub...)
+			offCurve[len(offCurve)-1] ^= 1
+			for _, p := range [][]byte{
+				nil,
+				{0},
+				pub[:len(pub)-1],
+				offCurve,
+				append([]byte{2}, pub[1:1+c.size]...),
+			} {
+				if _, err := c.curve.NewPublicKey(p); err == nil {
+					t.Errorf("NewPublicKey(%x) succeeded, want error", p)
+				}
+			}
+		})
+	}
+
+	if _, err := ecdh.X25519().NewPrivateKey(make([]byte, 31)); err == nil {
+		t.Error("X25519 NewPrivateKey with short key succeeded")
+	}
+	if _, err := ecdh.X25519().NewPublicKey(make([]byte, 33)); err == nil {
+		t.Error("X25519 NewPublicKey with long key succeeded")
+	}
+}
+
+func hexDecode(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal("invalid hex string:", s)
+	}
+	return b
+}
+
+func BenchmarkECDH(b *testing.B) {
+	for _, curve := range curves {
+		b.Run(fmt.Sprint(curve), func(b *testing.B) {
+			peer, err := curve.GenerateKey(rand.Reader)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				k, err := curve.GenerateKey(rand.Reader)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := k.ECDH(peer.PublicKey()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
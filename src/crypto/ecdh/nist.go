@@ -0,0 +1,238 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ecdh
+
+import (
+	"crypto/internal/nistec"
+	"crypto/internal/randutil"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// nistCurve is a NIST curve backed by a crypto/internal/nistec point type.
+type nistCurve struct {
+	name        string
+	scalarOrder []byte
+
+	// scalarBaseMult returns the uncompressed encoding of scalar * G.
+	scalarBaseMult func(scalar []byte) []byte
+	// scalarMultX decodes point and returns the encoding of the
+	// x-coordinate of scalar * point.
+	scalarMultX func(point, scalar []byte) ([]byte, error)
+	// checkPoint returns an error if point is not a valid encoding.
+	checkPoint func(point []byte) error
+}
+
+func (c *nistCurve) String() string {
+	return c.name
+}
+
+var errInvalidPrivateKey = errors.New("crypto/ecdh: invalid private key")
+
+func (c *nistCurve) GenerateKey(rand io.Reader) (*PrivateKey, error) {
+	key := make([]byte, len(c.scalarOrder))
+	randutil.MaybeReadByte(rand)
+	for {
+		if _, err := io.ReadFull(rand, key); err != nil {
+			return nil, err
+		}
+
+		// Mask off any excess bits if the size of the underlying field is not a
+		// whole number of bytes, which is only the case for P-521.
+		key[0] &= byte(1<<bits.Len8(c.scalarOrder[0]) - 1)
+
+		// In tests, rand will return all zeros and NewPrivateKey will reject
+		// the zero key as it generates the identity as a public key. This also
+		// makes this function consistent with crypto/elliptic.GenerateKey.
+		key[1] ^= 0x42
+
+		k, err := c.NewPrivateKey(key)
+		if err == errInvalidPrivateKey {
+			continue
+		}
+		return k, err
+	}
+}
+
+func (c *nistCurve) NewPrivateKey(key []byte) (*PrivateKey, error) {
+	if len(key) != len(c.scalarOrder) {
+		return nil, errors.New("crypto/ecdh: invalid private key size")
+	}
+	if isZero(key) || !isLess(key, c.scalarOrder) {
+		return nil, errInvalidPrivateKey
+	}
+	return &PrivateKey{
+		curve:      c,
+		privateKey: append([]byte{}, key...),
+	}, nil
+}
+
+func (c *nistCurve) privateKeyToPublicKey(key *PrivateKey) *PublicKey {
+	if key.curve != c {
+		panic("crypto/ecdh: internal error: converting the wrong key type")
+	}
+	publicKey := c.scalarBaseMult(key.privateKey)
+	if len(publicKey) == 1 {
+		// The encoding of the identity is a single 0x00 byte. This should not
+		// be possible, as NewPrivateKey rejects the zero scalar.
+		panic("crypto/ecdh: internal error: nistec ScalarBaseMult returned the identity")
+	}
+	return &PublicKey{
+		curve:     key.curve,
+		publicKey: publicKey,
+	}
+}
+
+// isZero returns whether a is all zeroes in constant time.
+func isZero(a []byte) bool {
+	var acc byte
+	for _, b := range a {
+		acc |= b
+	}
+	return acc == 0
+}
+
+// isLess returns whether a < b, where a and b are big-endian buffers of the
+// same length, in constant time.
+func isLess(a, b []byte) bool {
+	if len(a) != len(b) {
+		panic("crypto/ecdh: internal error: mismatched isLess inputs")
+	}
+	// Subtract b from a from the least significant byte up, and look at the
+	// final borrow.
+	var borrow uint64
+	for i := len(a) - 1; i >= 0; i-- {
+		diff := uint64(a[i]) - uint64(b[i]) - borrow
+		borrow = diff >> 63
+	}
+	return borrow == 1
+}
+
+func (c *nistCurve) NewPublicKey(key []byte) (*PublicKey, error) {
+	// Reject the point at infinity and compressed encodings.
+	if len(key) == 0 || key[0] != 4 {
+		return nil, errors.New("crypto/ecdh: invalid public key")
+	}
+	if err := c.checkPoint(key); err != nil {
+		return nil, err
+	}
+	return &PublicKey{
+		curve:     c,
+		publicKey: append([]byte{}, key...),
+	}, nil
+}
+
+func (c *nistCurve) ecdh(local *PrivateKey, remote *PublicKey) ([]byte, error) {
+	// Note that this function can't return an error, as NewPublicKey rejects
+	// invalid points and the point at infinity, and NewPrivateKey rejects
+	// invalid scalars and the zero value. BytesX returns an error for the point
+	// at infinity, but in a prime order group such as the NIST curves that can
+	// only be the result of a scalar multiplication if one of the inputs is the
+	// zero scalar or the point at infinity.
+	return c.scalarMultX(remote.publicKey, local.privateKey)
+}
+
+// P256 returns a Curve which implements NIST P-256 (FIPS 186-3, section D.2.3),
+// also known as secp256r1 or prime256v1.
+//
+// Multiple invocations of this function will return the same value, which can
+// be used for equality checks and switch statements.
+func P256() Curve { return p256 }
+
+var p256 = &nistCurve{
+	name:        "P-256",
+	scalarOrder: p256Order,
+	scalarBaseMult: func(scalar []byte) []byte {
+		return nistec.NewP256Point().ScalarBaseMult(scalar).Bytes()
+	},
+	scalarMultX: func(point, scalar []byte) ([]byte, error) {
+		p, err := nistec.NewP256Point().SetBytes(point)
+		if err != nil {
+			return nil, err
+		}
+		return p.ScalarMult(p, scalar).BytesX()
+	},
+	checkPoint: func(point []byte) error {
+		_, err := nistec.NewP256Point().SetBytes(point)
+		return err
+	},
+}
+
+var p256Order = []byte{
+	0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xbc, 0xe6, 0xfa, 0xad, 0xa7, 0x17, 0x9e, 0x84,
+	0xf3, 0xb9, 0xca, 0xc2, 0xfc, 0x63, 0x25, 0x51}
+
+// P384 returns a Curve which implements NIST P-384 (FIPS 186-3, section D.2.4),
+// also known as secp384r1.
+//
+// Multiple invocations of this function will return the same value, which can
+// be used for equality checks and switch statements.
+func P384() Curve { return p384 }
+
+var p384 = &nistCurve{
+	name:        "P-384",
+	scalarOrder: p384Order,
+	scalarBaseMult: func(scalar []byte) []byte {
+		return nistec.NewP384Point().ScalarBaseMult(scalar).Bytes()
+	},
+	scalarMultX: func(point, scalar []byte) ([]byte, error) {
+		p, err := nistec.NewP384Point().SetBytes(point)
+		if err != nil {
+			return nil, err
+		}
+		return p.ScalarMult(p, scalar).BytesX()
+	},
+	checkPoint: func(point []byte) error {
+		_, err := nistec.NewP384Point().SetBytes(point)
+		return err
+	},
+}
+
+var p384Order = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xc7, 0x63, 0x4d, 0x81, 0xf4, 0x37, 0x2d, 0xdf,
+	0x58, 0x1a, 0x0d, 0xb2, 0x48, 0xb0, 0xa7, 0x7a,
+	0xec, 0xec, 0x19, 0x6a, 0xcc, 0xc5, 0x29, 0x73}
+
+// P521 returns a Curve which implements NIST P-521 (FIPS 186-3, section D.2.5),
+// also known as secp521r1.
+//
+// Multiple invocations of this function will return the same value, which can
+// be used for equality checks and switch statements.
+func P521() Curve { return p521 }
+
+var p521 = &nistCurve{
+	name:        "P-521",
+	scalarOrder: p521Order,
+	scalarBaseMult: func(scalar []byte) []byte {
+		return nistec.NewP521Point().ScalarBaseMult(scalar).Bytes()
+	},
+	scalarMultX: func(point, scalar []byte) ([]byte, error) {
+		p, err := nistec.NewP521Point().SetBytes(point)
+		if err != nil {
+			return nil, err
+		}
+		return p.ScalarMult(p, scalar).BytesX()
+	},
+	checkPoint: func(point []byte) error {
+		_, err := nistec.NewP521Point().SetBytes(point)
+		return err
+	},
+}
+
+var p521Order = []byte{0x01, 0xff,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfa,
+	0x51, 0x86, 0x87, 0x83, 0xbf, 0x2f, 0x96, 0x6b,
+	0x7f, 0xcc, 0x01, 0x48, 0xf7, 0x09, 0xa5, 0xd0,
+	0x3b, 0xb5, 0xc9, 0xb8, 0x89, 0x9c, 0x47, 0xae,
+	0xbb, 0x6f, 0xb7, 0x1e, 0x91, 0x38, 0x64, 0x09}
@@ -6,6 +6,7 @@ package ecdsa
 
 import (
 	"bufio"
+	"bytes"
 	"compress/bzip2"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -57,6 +58,56 @@ func testKeyGeneration(t *testing.T, c elliptic.Curve) {
 	}
 }
 
+func TestECDH(t *testing.T) {
+	testAllCurves(t, testECDH)
+}
+
+func testECDH(t *testing.T, c elliptic.Curve) {
+	priv, err := GenerateKey(c, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := GenerateKey(c, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := priv.ECDH()
+	if c == elliptic.P224() {
+		if err == nil {
+			t.Error("P-224 PrivateKey.ECDH succeeded, want error")
+		}
+		if _, err := priv.PublicKey.ECDH(); err == nil {
+			t.Error("P-224 PublicKey.ECDH succeeded, want error")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := k.PublicKey().Bytes(), elliptic.Marshal(c, priv.X, priv.Y); !bytes.Equal(got, want) {
+		t.Errorf("public key = %x, want %x", got, want)
+	}
+
+	peerPub, err := peer.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := k.ECDH(peerPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, _ := c.ScalarMult(peer.X, peer.Y, priv.D.Bytes())
+	if want := x.FillBytes(make([]byte, len(secret))); !bytes.Equal(secret, want) {
+		t.Errorf("shared secret = %x, want %x", secret, want)
+	}
+
+	invalid := &PublicKey{Curve: c, X: priv.X, Y: new(big.Int).Add(priv.Y, big.NewInt(1))}
+	if _, err := invalid.ECDH(); err == nil {
+		t.Error("ECDH of off-curve public key succeeded")
+	}
+}
+
 func TestSignAndVerify(t *testing.T) {
 	testAllCurves(t, testSignAndVerify)
 }
@@ -5,7 +5,7 @@
 package elliptic
 
 import (
-	"crypto/elliptic/internal/fiat"
+	"crypto/internal/nistec/fiat"
 	"math/big"
 )
 
@@ -0,0 +1,171 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func mustDecodeHex(t *testing.T, in string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestRFC9180Vector checks the base mode test vector for DHKEM(X25519,
+// HKDF-SHA256), HKDF-SHA256, AES-128-GCM from RFC 9180, Appendix A.1.1.
+func TestRFC9180Vector(t *testing.T) {
+	info := mustDecodeHex(t, "4f6465206f6e2061204772656369616e2055726e")
+	skEm := mustDecodeHex(t, "52c4a758a802cd8b936eceea314432798d5baf2d7e9235dc084ab1b9cfa2f736")
+	pkEm := mustDecodeHex(t, "37fda3567bdbd628e88668c3c8d7e97d1d1253b6d4ea6d44c150f741f1bf4431")
+	skRm := mustDecodeHex(t, "4612c550263fc8ad58375df3f557aac531d26850903e55a9f23f21d8534e8ac8")
+	sharedSecret := mustDecodeHex(t, "fe0e18c9f024ce43799ae393c7e8fe8fce9d218875e8227b0187c04e7d2ea1fc")
+	key := mustDecodeHex(t, "4531685d41d65f03dc48f6b8302c05b0")
+	baseNonce := mustDecodeHex(t, "56d890e5accaaf011cff4b7d")
+	exporterSecret := mustDecodeHex(t, "45ff1c2e220db587171952c0592d5f5ebe103f1561a2614e38f2ffd47e99e3f8")
+	pt := mustDecodeHex(t, "4265617574792069732074727574682c20747275746820626561757479")
+	aad := mustDecodeHex(t, "436f756e742d30")
+	ct := mustDecodeHex(t, "f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc53a52ae8218a355a96d8770ac83d07bea87e13c512a")
+
+	ephemeral, err := ecdh.X25519().NewPrivateKey(skEm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testingOnlyGenerateKey = func() (*ecdh.PrivateKey, error) { return ephemeral, nil }
+	defer func() { testingOnlyGenerateKey = nil }()
+
+	recipient, err := ParseHPKEPrivateKey(0x0020, skRm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc, sender, err := SetupSender(0x0020, 0x0001, 0x0001, recipient.PublicKey(), info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, pkEm) {
+		t.Errorf("enc = %x, want %x", enc, pkEm)
+	}
+	for name, got := range map[string][]byte{
+		"shared secret":   sender.sharedSecret,
+		"key":             sender.key,
+		"base nonce":      sender.baseNonce,
+		"exporter secret": sender.exporterSecret,
+	} {
+		want := map[string][]byte{
+			"shared secret":   sharedSecret,
+			"key":             key,
+			"base nonce":      baseNonce,
+			"exporter secret": exporterSecret,
+		}[name]
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s = %x, want %x", name, got, want)
+		}
+	}
+
+	sealed, err := sender.Seal(aad, pt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sealed, ct) {
+		t.Errorf("Seal = %x, want %x", sealed, ct)
+	}
+
+	r, err := SetupRecipient(0x0020, 0x0001, 0x0001, recipient, info, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := r.Open(aad, ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, pt) {
+		t.Errorf("Open = %x, want %x", opened, pt)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for kemID, kem := range SupportedKEMs {
+		for kdfID := range SupportedKDFs {
+			for aeadID := range SupportedAEADs {
+				name := fmt.Sprintf("KEM %04x KDF %04x AEAD %04x", kemID, kdfID, aeadID)
+				t.Run(name, func(t *testing.T) {
+					priv, err := kem.curve.GenerateKey(rand.Reader)
+					if err != nil {
+						t.Fatal(err)
+					}
+					pub, err := ParseHPKEPublicKey(kemID, priv.PublicKey().Bytes())
+					if err != nil {
+						t.Fatal(err)
+					}
+					info := []byte("info")
+					enc, sender, err := SetupSender(kemID, kdfID, aeadID, pub, info)
+					if err != nil {
+						t.Fatal(err)
+					}
+					recipient, err := SetupRecipient(kemID, kdfID, aeadID, priv, info, enc)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					for i := 0; i < 3; i++ {
+						aad := []byte(fmt.Sprintf("aad %d", i))
+						msg := []byte(fmt.Sprintf("message %d", i))
+						ct, err := sender.Seal(aad, msg)
+						if err != nil {
+							t.Fatal(err)
+						}
+						if _, err := recipient.Open([]byte("wrong aad"), ct); err == nil {
+							t.Fatal("Open with the wrong additional data succeeded")
+						}
+						pt, err := recipient.Open(aad, ct)
+						if err != nil {
+							t.Fatal(err)
+						}
+						if !bytes.Equal(pt, msg) {
+							t.Errorf("Open = %q, want %q", pt, msg)
+						}
+					}
+
+					other, err := SetupRecipient(kemID, kdfID, aeadID, priv, []byte("other info"), enc)
+					if err != nil {
+						t.Fatal(err)
+					}
+					ct, err := sender.Seal(nil, []byte("message"))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if _, err := other.Open(nil, ct); err == nil {
+						t.Error("Open with a different info succeeded")
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestUnsupportedSuites(t *testing.T) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ids := range [][3]uint16{
+		{0x0011, 0x0001, 0x0001},
+		{0x0020, 0x0004, 0x0001},
+		{0x0020, 0x0001, 0xffff},
+	} {
+		if _, _, err := SetupSender(ids[0], ids[1], ids[2], priv.PublicKey(), nil); err == nil {
+			t.Errorf("SetupSender(%04x, %04x, %04x) succeeded, want error", ids[0], ids[1], ids[2])
+		}
+	}
+}
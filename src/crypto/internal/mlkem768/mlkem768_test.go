@@ -0,0 +1,225 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mlkem768
+
+import (
+	"bytes"
+	"crypto/internal/sha3"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+func TestFieldReduce(t *testing.T) {
+	for a := uint32(0); a < 2*q*q; a++ {
+		got := fieldReduce(a)
+		exp := fieldElement(a % q)
+		if got != exp {
+			t.Fatalf("reduce(%d) = %d, expected %d", a, got, exp)
+		}
+	}
+}
+
+func TestCompressDecompress(t *testing.T) {
+	for _, bits := range []uint8{1, 4, 10} {
+		for a := uint16(0); a < q; a++ {
+			// Compress_d(x) = ⌈(2ᵈ/q) * x⌋ mod 2ᵈ, computed with integers.
+			exp := uint16((uint32(a)<<bits + q/2) / q % (1 << bits))
+			if got := compress(fieldElement(a), bits); got != exp {
+				t.Fatalf("compress(%d, %d) = %d, expected %d", a, bits, got, exp)
+			}
+		}
+		for b := uint16(0); b < 1<<bits; b++ {
+			// Decompress_d(y) = ⌈(q/2ᵈ) * y⌋.
+			exp := fieldElement((uint32(b)*q + 1<<(bits-1)) >> bits)
+			if got := decompress(b, bits); got != exp {
+				t.Fatalf("decompress(%d, %d) = %d, expected %d", b, bits, got, exp)
+			}
+		}
+	}
+}
+
+func TestNTTRoundTrip(t *testing.T) {
+	var f ringElement
+	for i := range f {
+		f[i] = fieldElement(i * 13 % q)
+	}
+	if got := inverseNTT(ntt(f)); got != f {
+		t.Errorf("inverseNTT(ntt(f)) != f")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	dk, err := GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, Ke, err := Encapsulate(rand.Reader, dk.EncapsulationKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	Kd, err := Decapsulate(dk, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ke, Kd) {
+		t.Fail()
+	}
+
+	dk1, err := GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(dk.EncapsulationKey(), dk1.EncapsulationKey()) {
+		t.Fail()
+	}
+	if bytes.Equal(dk.Bytes(), dk1.Bytes()) {
+		t.Fail()
+	}
+
+	dk2, err := NewKeyFromSeed(dk.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dk.EncapsulationKey(), dk2.EncapsulationKey()) {
+		t.Fail()
+	}
+
+	c1, Ke1, err := Encapsulate(rand.Reader, dk.EncapsulationKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(c, c1) {
+		t.Fail()
+	}
+	if bytes.Equal(Ke, Ke1) {
+		t.Fail()
+	}
+}
+
+func TestBadLengths(t *testing.T) {
+	dk, err := GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+
+	for i := 0; i < len(ek)-1; i += 97 {
+		if _, _, err := Encapsulate(rand.Reader, ek[:i]); err == nil {
+			t.Errorf("expected error for ek length %d", i)
+		}
+	}
+	if _, _, err := Encapsulate(rand.Reader, append(ek, 0)); err == nil {
+		t.Errorf("expected error for ek length %d", len(ek)+1)
+	}
+
+	c, _, err := Encapsulate(rand.Reader, ek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(c)-1; i += 97 {
+		if _, err := Decapsulate(dk, c[:i]); err == nil {
+			t.Errorf("expected error for c length %d", i)
+		}
+	}
+	if _, err := Decapsulate(dk, append(c, 0)); err == nil {
+		t.Errorf("expected error for c length %d", len(c)+1)
+	}
+
+	if _, err := NewKeyFromSeed(dk.Bytes()[:SeedSize-1]); err == nil {
+		t.Errorf("expected error for short seed")
+	}
+}
+
+func TestUnreducedEncapsulationKey(t *testing.T) {
+	dk, err := GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+	// Set the first coefficient of t to q, which is not reduced.
+	ek[0] = byte(q & 0xff)
+	ek[1] = ek[1]&0xf0 | byte(q>>8)
+	if _, _, err := Encapsulate(rand.Reader, ek); err == nil {
+		t.Error("expected error for unreduced encapsulation key")
+	}
+}
+
+func TestVector(t *testing.T) {
+	seed := make([]byte, SeedSize)
+	m := make([]byte, messageSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	for i := range m {
+		m[i] = byte(SeedSize + i)
+	}
+
+	dk, err := NewKeyFromSeed(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+	if got := sha3.Sum256(ek); hex.EncodeToString(got[:]) != "a24e16d8f8f9383a95b77050f4d9fd2f5733eec1d63ef3c23ebf9918173669a7" {
+		t.Errorf("H(ek) = %x", got)
+	}
+
+	c, K, err := kemEncaps(ek, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sha3.Sum256(c); hex.EncodeToString(got[:]) != "b4cfbd24cef67afd3764276c6980e0f88f8e9ca57f59b7f12fe1a9c1e72f4710" {
+		t.Errorf("H(c) = %x", got)
+	}
+	if got := hex.EncodeToString(K); got != "9cddd089ffe70e3996e76f7c8d06746df34d07e8657bc0fcf2bb0e1c3084aea1" {
+		t.Errorf("K = %s", got)
+	}
+	if got, _ := Decapsulate(dk, c); !bytes.Equal(got, K) {
+		t.Errorf("Decapsulate = %x, expected %x", got, K)
+	}
+
+	// A modified ciphertext produces the implicit rejection key J(z || c).
+	c[0] ^= 1
+	got, err := Decapsulate(dk, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(got) != "dcfc80c6db46ff7028e3a4398651c063ae7a42c107a6dc8cb07141861698ab92" {
+		t.Errorf("implicit rejection K = %x", got)
+	}
+}
+
+func BenchmarkKeyGen(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		GenerateKey(rand.Reader)
+	}
+}
+
+func BenchmarkEncaps(b *testing.B) {
+	dk, err := GenerateKey(rand.Reader)
+	if err != nil {
+		b.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Encapsulate(rand.Reader, ek)
+	}
+}
+
+func BenchmarkDecaps(b *testing.B) {
+	dk, err := GenerateKey(rand.Reader)
+	if err != nil {
+		b.Fatal(err)
+	}
+	c, _, err := Encapsulate(rand.Reader, dk.EncapsulationKey())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Decapsulate(dk, c)
+	}
+}
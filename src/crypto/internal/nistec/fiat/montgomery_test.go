@@ -0,0 +1,170 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fiat_test
+
+import (
+	"bytes"
+	"crypto/internal/nistec/fiat"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// montgomeryElement is the interface shared by the generated
+// Montgomery field implementations, for testing.
+type montgomeryElement interface {
+	Bytes() []byte
+	IsZero() int
+}
+
+type montgomeryField struct {
+	name string
+	p    *big.Int
+	// set returns a new element from a little-endian encoding.
+	set func(v []byte) (montgomeryElement, error)
+	// op applies the named operation to a and b.
+	op func(op string, a, b montgomeryElement) montgomeryElement
+}
+
+func p256Op(op string, a, b montgomeryElement) montgomeryElement {
+	x, y := a.(*fiat.P256Element), b.(*fiat.P256Element)
+	e := new(fiat.P256Element)
+	switch op {
+	case "add":
+		return e.Add(x, y)
+	case "sub":
+		return e.Sub(x, y)
+	case "mul":
+		return e.Mul(x, y)
+	case "square":
+		return e.Square(x)
+	case "invert":
+		return e.Invert(x)
+	}
+	panic("unknown op " + op)
+}
+
+func p384Op(op string, a, b montgomeryElement) montgomeryElement {
+	x, y := a.(*fiat.P384Element), b.(*fiat.P384Element)
+	e := new(fiat.P384Element)
+	switch op {
+	case "add":
+		return e.Add(x, y)
+	case "sub":
+		return e.Sub(x, y)
+	case "mul":
+		return e.Mul(x, y)
+	case "square":
+		return e.Square(x)
+	case "invert":
+		return e.Invert(x)
+	}
+	panic("unknown op " + op)
+}
+
+var montgomeryFields = []montgomeryField{
+	{
+		name: "P-256",
+		p:    bigFromHex("ffffffff00000001000000000000000000000000ffffffffffffffffffffffff"),
+		set: func(v []byte) (montgomeryElement, error) {
+			return new(fiat.P256Element).SetBytes(v)
+		},
+		op: p256Op,
+	},
+	{
+		name: "P-384",
+		p:    bigFromHex("fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffeffffffff0000000000000000ffffffff"),
+		set: func(v []byte) (montgomeryElement, error) {
+			return new(fiat.P384Element).SetBytes(v)
+		},
+		op: p384Op,
+	},
+}
+
+func bigFromHex(s string) *big.Int {
+	b, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("invalid hex: " + s)
+	}
+	return b
+}
+
+func reverse(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[len(b)-1-i] = b[i]
+	}
+	return out
+}
+
+func leBytes(x *big.Int, size int) []byte {
+	return reverse(x.FillBytes(make([]byte, size)))
+}
+
+func TestMontgomeryFields(t *testing.T) {
+	for _, f := range montgomeryFields {
+		t.Run(f.name, func(t *testing.T) {
+			size := (f.p.BitLen() + 7) / 8
+			pMinus1 := new(big.Int).Sub(f.p, big.NewInt(1))
+			values := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2), pMinus1}
+			for i := 0; i < 20; i++ {
+				v, err := rand.Int(rand.Reader, f.p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				values = append(values, v)
+			}
+
+			for _, a := range values {
+				ea, err := f.set(leBytes(a, size))
+				if err != nil {
+					t.Fatalf("SetBytes(%x): %v", a, err)
+				}
+				if got := ea.Bytes(); !bytes.Equal(got, leBytes(a, size)) {
+					t.Errorf("round trip of %x = %x", a, reverse(got))
+				}
+				if got, want := ea.IsZero(), a.Sign() == 0; (got == 1) != want {
+					t.Errorf("IsZero(%x) = %d", a, got)
+				}
+				for _, b := range values {
+					eb, _ := f.set(leBytes(b, size))
+					check := func(op string, want *big.Int) {
+						t.Helper()
+						want.Mod(want, f.p)
+						got := f.op(op, ea, eb).Bytes()
+						if !bytes.Equal(got, leBytes(want, size)) {
+							t.Errorf("%s(%x, %x) = %x, want %x", op, a, b, reverse(got), want)
+						}
+					}
+					check("add", new(big.Int).Add(a, b))
+					check("sub", new(big.Int).Sub(a, b))
+					check("mul", new(big.Int).Mul(a, b))
+				}
+				sq := new(big.Int).Mul(a, a)
+				if got := f.op("square", ea, ea).Bytes(); !bytes.Equal(got, leBytes(sq.Mod(sq, f.p), size)) {
+					t.Errorf("square(%x) = %x", a, reverse(got))
+				}
+				inv := new(big.Int).ModInverse(a, f.p)
+				if inv == nil {
+					inv = new(big.Int)
+				}
+				if got := f.op("invert", ea, ea).Bytes(); !bytes.Equal(got, leBytes(inv, size)) {
+					t.Errorf("invert(%x) = %x, want %x", a, reverse(got), inv)
+				}
+			}
+
+			for _, v := range [][]byte{
+				leBytes(f.p, size),
+				bytes.Repeat([]byte{0xff}, size),
+				make([]byte, size-1),
+				make([]byte, size+1),
+			} {
+				if _, err := f.set(v); err == nil {
+					t.Errorf("SetBytes(%x) succeeded, want error", reverse(v))
+				}
+			}
+		})
+	}
+}
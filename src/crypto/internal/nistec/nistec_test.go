@@ -0,0 +1,228 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nistec_test
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/internal/nistec"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+type nistPoint interface {
+	Bytes() []byte
+	BytesX() ([]byte, error)
+}
+
+type nistCurve struct {
+	curve elliptic.Curve
+	// scalarMult returns scalar * p, or scalar * G if p is nil.
+	scalarMult func(p []byte, scalar []byte) (nistPoint, error)
+	// add returns p + q and p + p.
+	add func(p, q []byte) (sum, double nistPoint, err error)
+}
+
+var nistCurves = []nistCurve{
+	{
+		curve: elliptic.P256(),
+		scalarMult: func(p []byte, scalar []byte) (nistPoint, error) {
+			if p == nil {
+				return nistec.NewP256Point().ScalarBaseMult(scalar), nil
+			}
+			q, err := nistec.NewP256Point().SetBytes(p)
+			if err != nil {
+				return nil, err
+			}
+			return nistec.NewP256Point().ScalarMult(q, scalar), nil
+		},
+		add: func(p, q []byte) (nistPoint, nistPoint, error) {
+			pp, err := nistec.NewP256Point().SetBytes(p)
+			if err != nil {
+				return nil, nil, err
+			}
+			qq, err := nistec.NewP256Point().SetBytes(q)
+			if err != nil {
+				return nil, nil, err
+			}
+			return nistec.NewP256Point().Add(pp, qq), nistec.NewP256Point().Double(pp), nil
+		},
+	},
+	{
+		curve: elliptic.P384(),
+		scalarMult: func(p []byte, scalar []byte) (nistPoint, error) {
+			if p == nil {
+				return nistec.NewP384Point().ScalarBaseMult(scalar), nil
+			}
+			q, err := nistec.NewP384Point().SetBytes(p)
+			if err != nil {
+				return nil, err
+			}
+			return nistec.NewP384Point().ScalarMult(q, scalar), nil
+		},
+		add: func(p, q []byte) (nistPoint, nistPoint, error) {
+			pp, err := nistec.NewP384Point().SetBytes(p)
+			if err != nil {
+				return nil, nil, err
+			}
+			qq, err := nistec.NewP384Point().SetBytes(q)
+			if err != nil {
+				return nil, nil, err
+			}
+			return nistec.NewP384Point().Add(pp, qq), nistec.NewP384Point().Double(pp), nil
+		},
+	},
+	{
+		curve: elliptic.P521(),
+		scalarMult: func(p []byte, scalar []byte) (nistPoint, error) {
+			if p == nil {
+				return nistec.NewP521Point().ScalarBaseMult(scalar), nil
+			}
+			q, err := nistec.NewP521Point().SetBytes(p)
+			if err != nil {
+				return nil, err
+			}
+			return nistec.NewP521Point().ScalarMult(q, scalar), nil
+		},
+		add: func(p, q []byte) (nistPoint, nistPoint, error) {
+			pp, err := nistec.NewP521Point().SetBytes(p)
+			if err != nil {
+				return nil, nil, err
+			}
+			qq, err := nistec.NewP521Point().SetBytes(q)
+			if err != nil {
+				return nil, nil, err
+			}
+			return nistec.NewP521Point().Add(pp, qq), nistec.NewP521Point().Double(pp), nil
+		},
+	},
+}
+
+func TestScalarMult(t *testing.T) {
+	for _, c := range nistCurves {
+		t.Run(c.curve.Params().Name, func(t *testing.T) {
+			params := c.curve.Params()
+			nMinus1 := new(big.Int).Sub(params.N, big.NewInt(1))
+			scalars := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(15), big.NewInt(16), nMinus1}
+			for i := 0; i < 5; i++ {
+				k, err := rand.Int(rand.Reader, params.N)
+				if err != nil {
+					t.Fatal(err)
+				}
+				scalars = append(scalars, k)
+			}
+
+			for _, k := range scalars {
+				x, y := c.curve.ScalarBaseMult(k.Bytes())
+				want := elliptic.Marshal(c.curve, x, y)
+				p, err := c.scalarMult(nil, k.Bytes())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := p.Bytes(); !bytes.Equal(got, want) {
+					t.Errorf("ScalarBaseMult(%x) = %x, want %x", k, got, want)
+				}
+				gotX, err := p.BytesX()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if wantX := want[1 : 1+len(want)/2]; !bytes.Equal(gotX, wantX) {
+					t.Errorf("BytesX(%x) = %x, want %x", k, gotX, wantX)
+				}
+
+				// Multiply the result again by a different scalar.
+				x2, y2 := c.curve.ScalarMult(x, y, scalars[len(scalars)-1].Bytes())
+				want2 := elliptic.Marshal(c.curve, x2, y2)
+				p2, err := c.scalarMult(want, scalars[len(scalars)-1].Bytes())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := p2.Bytes(); !bytes.Equal(got, want2) {
+					t.Errorf("ScalarMult(%x) = %x, want %x", k, got, want2)
+				}
+
+				sum, double, err := c.add(want, want2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sx, sy := c.curve.Add(x, y, x2, y2)
+				if got, want := sum.Bytes(), elliptic.Marshal(c.curve, sx, sy); !bytes.Equal(got, want) {
+					t.Errorf("Add = %x, want %x", got, want)
+				}
+				dx, dy := c.curve.Double(x, y)
+				if got, want := double.Bytes(), elliptic.Marshal(c.curve, dx, dy); !bytes.Equal(got, want) {
+					t.Errorf("Double = %x, want %x", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestInfinity(t *testing.T) {
+	for _, c := range nistCurves {
+		t.Run(c.curve.Params().Name, func(t *testing.T) {
+			params := c.curve.Params()
+			for _, k := range [][]byte{{0}, params.N.Bytes()} {
+				p, err := c.scalarMult(nil, k)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := p.Bytes(); !bytes.Equal(got, []byte{0}) {
+					t.Errorf("ScalarBaseMult(%x) = %x, want infinity", k, got)
+				}
+				if _, err := p.BytesX(); err == nil {
+					t.Errorf("BytesX of infinity succeeded")
+				}
+			}
+
+			g := elliptic.Marshal(c.curve, params.Gx, params.Gy)
+			sum, double, err := c.add([]byte{0}, g)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sum.Bytes(); !bytes.Equal(got, g) {
+				t.Errorf("∞ + G = %x, want %x", got, g)
+			}
+			if got := double.Bytes(); !bytes.Equal(got, []byte{0}) {
+				t.Errorf("2∞ = %x, want infinity", got)
+			}
+		})
+	}
+}
+
+func TestInvalidPoints(t *testing.T) {
+	for _, c := range nistCurves {
+		t.Run(c.curve.Params().Name, func(t *testing.T) {
+			params := c.curve.Params()
+			byteLen := (params.BitSize + 7) / 8
+			g := elliptic.Marshal(c.curve, params.Gx, params.Gy)
+
+			offCurve := append([]byte{}, g...)
+			offCurve[len(offCurve)-1] ^= 1
+
+			for _, p := range [][]byte{
+				{},
+				{4},
+				g[:len(g)-1],
+				offCurve,
+				append([]byte{2}, g[1:]...),
+				elliptic.MarshalCompressed(c.curve, params.Gx, params.Gy),
+			} {
+				if _, err := c.scalarMult(p, []byte{1}); err == nil {
+					t.Errorf("SetBytes(%x) succeeded, want error", p)
+				}
+			}
+			// Gx + p is only representable if it doesn't overflow the encoding.
+			if gxp := new(big.Int).Add(params.Gx, params.P); gxp.BitLen() <= byteLen*8 {
+				nonCanonical := append([]byte{4}, gxp.FillBytes(make([]byte, byteLen))...)
+				nonCanonical = append(nonCanonical, params.Gy.FillBytes(make([]byte, byteLen))...)
+				if _, err := c.scalarMult(nonCanonical, []byte{1}); err == nil {
+					t.Errorf("SetBytes(%x) succeeded, want error", nonCanonical)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sha3 implements the SHA-3 hash functions and the SHAKE extendable
+// output functions, as specified in FIPS 202.
+//
+// Only the functions needed by crypto/internal/mlkem768 are provided.
+package sha3
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const (
+	// stateSize is the size in bytes of the Keccak-f[1600] state.
+	stateSize = 200
+
+	dsbyteSHA3  = 0x06
+	dsbyteSHAKE = 0x1f
+
+	rate256      = stateSize - 2*32
+	rate512      = stateSize - 2*64
+	rateShake128 = stateSize - 2*16
+	rateShake256 = stateSize - 2*32
+)
+
+// state is a sponge over the Keccak-f[1600] permutation.
+type state struct {
+	a [stateSize]byte
+
+	rate      int  // number of bytes of a absorbed into or squeezed from per permutation
+	dsbyte    byte // domain separation bits and the first bit of the padding
+	outputLen int  // for hash functions, the size of the digest
+
+	n         int  // position in a of the next byte to absorb or squeeze
+	squeezing bool // whether the padding has been applied
+}
+
+// New256 returns a new hash.Hash computing the SHA3-256 checksum.
+func New256() hash.Hash {
+	return &state{rate: rate256, dsbyte: dsbyteSHA3, outputLen: 32}
+}
+
+// New512 returns a new hash.Hash computing the SHA3-512 checksum.
+func New512() hash.Hash {
+	return &state{rate: rate512, dsbyte: dsbyteSHA3, outputLen: 64}
+}
+
+// Sum256 returns the SHA3-256 digest of the data.
+func Sum256(data []byte) [32]byte {
+	var out [32]byte
+	h := state{rate: rate256, dsbyte: dsbyteSHA3, outputLen: 32}
+	h.Write(data)
+	h.read(out[:])
+	return out
+}
+
+// Sum512 returns the SHA3-512 digest of the data.
+func Sum512(data []byte) [64]byte {
+	var out [64]byte
+	h := state{rate: rate512, dsbyte: dsbyteSHA3, outputLen: 64}
+	h.Write(data)
+	h.read(out[:])
+	return out
+}
+
+func (s *state) Size() int      { return s.outputLen }
+func (s *state) BlockSize() int { return s.rate }
+
+func (s *state) Reset() {
+	s.a = [stateSize]byte{}
+	s.n = 0
+	s.squeezing = false
+}
+
+func (s *state) Write(p []byte) (int, error) {
+	if s.squeezing {
+		panic("sha3: Write after Read")
+	}
+	n := len(p)
+	for len(p) > 0 {
+		m := s.rate - s.n
+		if m > len(p) {
+			m = len(p)
+		}
+		for i, b := range p[:m] {
+			s.a[s.n+i] ^= b
+		}
+		s.n += m
+		p = p[m:]
+		if s.n == s.rate {
+			keccakF1600(&s.a)
+			s.n = 0
+		}
+	}
+	return n, nil
+}
+
+// Sum appends the digest of the data written so far to b, without changing
+// the underlying state.
+func (s *state) Sum(b []byte) []byte {
+	dup := *s
+	out := make([]byte, s.outputLen)
+	dup.read(out)
+	return append(b, out...)
+}
+
+// padAndPermute applies the domain separation bits and the pad10*1 padding,
+// and switches the sponge to the squeezing phase.
+func (s *state) padAndPermute() {
+	s.a[s.n] ^= s.dsbyte
+	s.a[s.rate-1] ^= 0x80
+	keccakF1600(&s.a)
+	s.n = 0
+	s.squeezing = true
+}
+
+func (s *state) read(out []byte) {
+	if !s.squeezing {
+		s.padAndPermute()
+	}
+	for len(out) > 0 {
+		if s.n == s.rate {
+			keccakF1600(&s.a)
+			s.n = 0
+		}
+		m := copy(out, s.a[s.n:s.rate])
+		s.n += m
+		out = out[m:]
+	}
+}
+
+// SHAKE is an instance of a SHAKE extendable output function.
+//
+// Data is absorbed with Write, and an arbitrary amount of output can then be
+// squeezed with Read. Write must not be called after Read.
+type SHAKE struct {
+	s state
+}
+
+// NewShake128 returns a new SHAKE128 instance.
+func NewShake128() *SHAKE {
+	return &SHAKE{state{rate: rateShake128, dsbyte: dsbyteSHAKE}}
+}
+
+// NewShake256 returns a new SHAKE256 instance.
+func NewShake256() *SHAKE {
+	return &SHAKE{state{rate: rateShake256, dsbyte: dsbyteSHAKE}}
+}
+
+// Write absorbs more data. It panics if called after Read.
+func (h *SHAKE) Write(p []byte) (int, error) { return h.s.Write(p) }
+
+// Read squeezes len(out) bytes of output. It never returns an error.
+func (h *SHAKE) Read(out []byte) (int, error) {
+	h.s.read(out)
+	return len(out), nil
+}
+
+// Reset resets the SHAKE instance to its initial state.
+func (h *SHAKE) Reset() { h.s.Reset() }
+
+// roundConstants are the Keccak-f[1600] iota step constants.
+var roundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// rotations are the Keccak-f[1600] rho step offsets, indexed by x+5y.
+var rotations = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+// keccakF1600 applies the Keccak-f[1600] permutation to the state.
+func keccakF1600(state *[stateSize]byte) {
+	var a, b [25]uint64
+	var c, d [5]uint64
+	for i := range a {
+		a[i] = binary.LittleEndian.Uint64(state[i*8:])
+	}
+
+	for _, rc := range roundConstants {
+		// θ step
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d[x] = c[(x+4)%5] ^ bits.RotateLeft64(c[(x+1)%5], 1)
+		}
+		for i := range a {
+			a[i] ^= d[i%5]
+		}
+
+		// ρ and π steps
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				b[y+5*((2*x+3*y)%5)] = bits.RotateLeft64(a[x+5*y], rotations[x+5*y])
+			}
+		}
+
+		// χ step
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				a[y+x] = b[y+x] ^ (^b[y+(x+1)%5] & b[y+(x+2)%5])
+			}
+		}
+
+		// ι step
+		a[0] ^= rc
+	}
+
+	for i := range a {
+		binary.LittleEndian.PutUint64(state[i*8:], a[i])
+	}
+}
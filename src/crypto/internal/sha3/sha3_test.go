@@ -0,0 +1,154 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sha3
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// testMessage returns a message of length n that spans block boundaries
+// differently for each rate.
+func testMessage(n int) []byte {
+	m := make([]byte, n)
+	for i := range m {
+		m[i] = byte(i % 251)
+	}
+	return m
+}
+
+var sha3Tests = []struct {
+	length             int
+	sha256, sha512     string
+	shake128, shake256 string // 32 bytes of output
+}{
+	{
+		0,
+		"a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a",
+		"a69f73cca23a9ac5c8b567dc185a756e97c982164fe25859e0d1dcc1475c80a615b2123af1f5f94c11e3e9402c3ac558f500199d95b6d3e301758586281dcd26",
+		"7f9c2ba4e88f827d616045507605853ed73b8093f6efbc88eb1a6eacfa66ef26",
+		"46b9dd2b0ba88d13233b3feb743eeb243fcd52ea62b81b82b50c27646ed5762f",
+	},
+	{
+		3,
+		"1186d49a4ad620618f760f29da2c593b2ec2cc2ced69dc16817390d861e62253",
+		"123119ad1d6e168e0f20a3af1fb2e29c76bc3f83711cf3ee3122ae37ef6a1c2e094bd4bc53b7f9a45c9db1f900f87a3759327a659de341ef1a7b1787afbe9ebc",
+		"203d4b7543731ad58bce7697b39a48eafc4fee548891d1cf94bffd231022a896",
+		"714501167ead924ea87e422993eea1e67df0ead7b93140c1109470fb66d50aaa",
+	},
+	{
+		135,
+		"fded8fd9d6551c601eeb3b7c6bc5e5cfd8aad1d015b7e9aaa9c9b9475231d5e2",
+		"d942df0df09ac042cd3b641144c98d8fda0980bb037fc5c0e7f2e9a073b073dc4bb8a8c1f4cb5b45f5805c6523741ed0571d6779b15829b2faa280fc60b50645",
+		"d11fafa27f42a8162b8ae013535771de81722c0abc8aa2bca01825462e2f8971",
+		"c45dae624ad8a2f5aa7bac9d7557737fd91c96eedb70a6be5574d57a844eade0",
+	},
+	{
+		136,
+		"cf3ccff92480a29160c2d38317c430e14749bfee1788106957dfe73f8c4930e5",
+		"ad8edff4f1b7aa1c63bbe49728ab9b165f7245b3d7102e6f99c261fc15d2d0bf6afef6a491720454a1349fbf5d848854875ac83a1156fd7f6e2a37af26c07fb2",
+		"30bdfd69382cab028173fba7c6d53878ec18081358e52c955dc6f5d52b60b029",
+		"b7ff4073b3f5a8eabd6e17705ca7f6761a31058f9df781a6a47e3a3063b9d67a",
+	},
+	{
+		137,
+		"ce9d7dc90913ee5d92745019479a5352c6d6279bef18ed07dc0a83ee8084daca",
+		"3f827e5d7ddbd54ea1dba28cae0154eb5ff8d8d973770865861b7cdf5f091040889d55c0e74b672cead274fac1d4a559fd9185be898ab8969b5e78681527660d",
+		"047a94427406b3ac81270fe1c3aafe1594f121bdca236dcb2c01cd977b41ee02",
+		"01d90952c642a5eb2a8fc9d713f843a45d7ac05132dddcb2efc9bebc27e37bcb",
+	},
+	{
+		200,
+		"5f728f63bf5ee48c77f453c0490398fa645b8d4c4e56be9a41cfec344d6ca899",
+		"ea5d05f19348dd589793354793a15f37a73b4c0bb4e750b9a00757dfce2f8b65a64191bb9b137de00feef6474cfd47abf7880efbc51614a5715df12cfe0caee3",
+		"0c4234ca1e31801ae606f8b8d8e0665c66f42a21d601c2681858a92c79ad5d69",
+		"4ee1ca03272b05d3bfb1e1c79a967f823b9fc5e4bb3987b1ba9e9cb5afb07a5e",
+	},
+	{
+		500,
+		"495689a003b0b1a4ec4572335ed2d96510cac163d6cc7e83daa73d9b555a2fd5",
+		"f7aca9a50e9bd1207509d43bf9f9dfc980988f2e073b2756b17f003567182174330f2f8d04bd0527fb7f7312c8769362dbcba91c35f87f25fe0ce5b528a38e4d",
+		"ed9a5f1ed895f8f7cbad5bf512be2d884ffc10ee917ab8d4188b846b8063f533",
+		"20a1c001eb6aee7535706c02dbe70f2a39d87d3fda665f89706bea6211025657",
+	},
+}
+
+func TestVectors(t *testing.T) {
+	for _, tt := range sha3Tests {
+		m := testMessage(tt.length)
+
+		if got := Sum256(m); hex.EncodeToString(got[:]) != tt.sha256 {
+			t.Errorf("Sum256(%d bytes) = %x, want %s", tt.length, got, tt.sha256)
+		}
+		if got := Sum512(m); hex.EncodeToString(got[:]) != tt.sha512 {
+			t.Errorf("Sum512(%d bytes) = %x, want %s", tt.length, got, tt.sha512)
+		}
+
+		// Write one byte at a time to exercise partial blocks.
+		h := New256()
+		for i := range m {
+			h.Write(m[i : i+1])
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != tt.sha256 {
+			t.Errorf("New256(%d bytes) = %s, want %s", tt.length, got, tt.sha256)
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != tt.sha256 {
+			t.Errorf("New256(%d bytes) second Sum = %s, want %s", tt.length, got, tt.sha256)
+		}
+
+		out := make([]byte, 32)
+		s := NewShake128()
+		s.Write(m)
+		s.Read(out)
+		if got := hex.EncodeToString(out); got != tt.shake128 {
+			t.Errorf("SHAKE128(%d bytes) = %s, want %s", tt.length, got, tt.shake128)
+		}
+		s = NewShake256()
+		s.Write(m)
+		s.Read(out)
+		if got := hex.EncodeToString(out); got != tt.shake256 {
+			t.Errorf("SHAKE256(%d bytes) = %s, want %s", tt.length, got, tt.shake256)
+		}
+	}
+}
+
+func TestShakeLongOutput(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func() *SHAKE
+		want string // SHA-256 of 1000 bytes of output
+	}{
+		{"SHAKE128", NewShake128, "3f5a50fd8d371a660730d2eb3ff5f6746a58c9b981ac3b562786bf43b1a0f982"},
+		{"SHAKE256", NewShake256, "a203fc95e65d7ccae7b1d0cc794642019b05b4954f79cc40dff33c8e4ae5d1e6"},
+	}
+	for _, tt := range tests {
+		s := tt.new()
+		s.Write(testMessage(10))
+		out := make([]byte, 1000)
+		s.Read(out)
+		if got := sha256.Sum256(out); hex.EncodeToString(got[:]) != tt.want {
+			t.Errorf("%s: got output hash %x, want %s", tt.name, got, tt.want)
+		}
+
+		// Reading in odd-sized chunks must produce the same stream.
+		s.Reset()
+		s.Write(testMessage(10))
+		chunked := make([]byte, 0, 1000)
+		for len(chunked) < 1000 {
+			n := 1000 - len(chunked)
+			if n > 37 {
+				n = 37
+			}
+			buf := make([]byte, n)
+			s.Read(buf)
+			chunked = append(chunked, buf...)
+		}
+		if !bytes.Equal(chunked, out) {
+			t.Errorf("%s: chunked reads differ from a single read", tt.name)
+		}
+	}
+}
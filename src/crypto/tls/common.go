@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -99,6 +100,9 @@ const (
 	extensionCertificateAuthorities  uint16 = 47
 	extensionSignatureAlgorithmsCert uint16 = 50
 	extensionKeyShare                uint16 = 51
+	extensionQUICTransportParameters uint16 = 57
+	extensionECHOuterExtensions      uint16 = 0xfd00
+	extensionEncryptedClientHello    uint16 = 0xfe0d
 	extensionRenegotiationInfo       uint16 = 0xff01
 )
 
@@ -110,8 +114,9 @@ const (
 // CurveID is the type of a TLS identifier for an elliptic curve. See
 // https://www.iana.org/assignments/tls-parameters/tls-parameters.xml#tls-parameters-8.
 //
-// In TLS 1.3, this type is called NamedGroup, but at this time this library
-// only supports Elliptic Curve based groups. See RFC 8446, Section 4.2.7.
+// In TLS 1.3, this type is called NamedGroup, and this library supports
+// Elliptic Curve based groups as well as the X25519MLKEM768 hybrid key
+// exchange. See RFC 8446, Section 4.2.7.
 type CurveID uint16
 
 const (
@@ -119,6 +124,11 @@ const (
 	CurveP384 CurveID = 24
 	CurveP521 CurveID = 25
 	X25519    CurveID = 29
+
+	// X25519MLKEM768 is the hybrid post-quantum key exchange combining
+	// ML-KEM-768 and X25519, as specified in
+	// draft-kwiatkowski-tls-ecdhe-mlkem-02. It is only supported in TLS 1.3.
+	X25519MLKEM768 CurveID = 4588
 )
 
 // TLS 1.3 Key Share. See RFC 8446, Section 4.2.8.
@@ -227,6 +237,11 @@ type ConnectionState struct {
 	// TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, TLS_AES_128_GCM_SHA256).
 	CipherSuite uint16
 
+	// CurveID is the key exchange group used by the connection (e.g. X25519,
+	// X25519MLKEM768). It is zero if the handshake did not use an ECDHE or
+	// hybrid key exchange, such as with the RSA key exchange in TLS 1.2.
+	CurveID CurveID
+
 	// NegotiatedProtocol is the application protocol negotiated with ALPN.
 	NegotiatedProtocol string
 
@@ -274,6 +289,11 @@ type ConnectionState struct {
 	// RFC 7627, and https://mitls.org/pages/attacks/3SHAKE#channelbindings.
 	TLSUnique []byte
 
+	// ECHAccepted indicates if Encrypted Client Hello was offered by the client
+	// and accepted by the server. On the server side, the other fields
+	// describe the inner ClientHello.
+	ECHAccepted bool
+
 	// ekm is a closure exposed via ExportKeyingMaterial.
 	ekm func(label string, context []byte, length int) ([]byte, error)
 }
@@ -339,9 +359,11 @@ type ClientSessionState struct {
 	scts               [][]byte              // SCTs presented by the server
 
 	// TLS 1.3 fields.
-	nonce  []byte    // Ticket nonce sent by the server, to derive PSK
-	useBy  time.Time // Expiration of the ticket lifetime as set by the server
-	ageAdd uint32    // Random obfuscation factor for sending the ticket age
+	nonce        []byte    // Ticket nonce sent by the server, to derive PSK
+	useBy        time.Time // Expiration of the ticket lifetime as set by the server
+	ageAdd       uint32    // Random obfuscation factor for sending the ticket age
+	earlyData    bool      // The ticket allows 0-RTT, only for QUIC connections
+	alpnProtocol string    // ALPN protocol of the session, which 0-RTT must match
 }
 
 // ClientSessionCache is a cache of ClientSessionState objects that can be used
@@ -609,6 +631,19 @@ type Config struct {
 	// regardless of InsecureSkipVerify or ClientAuth settings.
 	VerifyConnection func(ConnectionState) error
 
+	// CheckRevocation, if not nil, is used during normal certificate
+	// verification to check whether the peer certificates were revoked, as
+	// x509.VerifyOptions.CheckRevocation. It's called for each certificate of
+	// a candidate chain except the root, along with its issuer. ocspResponse
+	// is the OCSP response stapled by the peer for its leaf certificate, also
+	// reported as ConnectionState.OCSPResponse, and is nil for the other
+	// certificates. It can be parsed with the crypto/x509/ocsp package.
+	//
+	// If CheckRevocation returns an error for every candidate chain, the
+	// handshake is aborted. It is not called if normal verification is
+	// disabled, see VerifyPeerCertificate.
+	CheckRevocation func(cert, issuer *x509.Certificate, ocspResponse []byte) error
+
 	// RootCAs defines the set of root certificate authorities
 	// that clients use when verifying server certificates.
 	// If RootCAs is nil, TLS uses the host's root CA set.
@@ -690,10 +725,19 @@ type Config struct {
 	// which is currently TLS 1.3.
 	MaxVersion uint16
 
-	// CurvePreferences contains the elliptic curves that will be used in
-	// an ECDHE handshake, in preference order. If empty, the default will
-	// be used. The client will use the first preference as the type for
-	// its key share in TLS 1.3. This may change in the future.
+	// CurvePreferences contains the elliptic curves and hybrid key exchanges
+	// that will be used in an ECDHE handshake, in preference order. If empty,
+	// the default will be used, which prefers X25519MLKEM768 in TLS 1.3. The
+	// client will use the first preference as the type for its key share in
+	// TLS 1.3, and if that is X25519MLKEM768 and X25519 is also supported, it
+	// will send an X25519 key share as well. This may change in the future.
+	//
+	// An X25519MLKEM768 key share adds about 1.2 KB to the ClientHello, which
+	// some middleboxes and servers fail to handle. Setting the GODEBUG
+	// environment variable to tlsmlkem=0 removes X25519MLKEM768 from the
+	// default. It does not affect a non-empty CurvePreferences.
+	//
+	// X25519MLKEM768 is ignored in TLS 1.2 and earlier.
 	CurvePreferences []CurveID
 
 	// DynamicRecordSizingDisabled disables adaptive sizing of TLS records.
@@ -714,6 +758,50 @@ type Config struct {
 	// used for debugging.
 	KeyLogWriter io.Writer
 
+	// EncryptedClientHelloConfigList is a serialized ECHConfigList. If it's
+	// set, clients will attempt to connect using Encrypted Client Hello (ECH)
+	// using one of the supported ECHConfigs.
+	//
+	// If the server rejects ECH, the handshake fails with an
+	// *ECHRejectionError, which may carry a new ECHConfigList to retry with.
+	// Rejection is also reported to the server with the ech_required alert.
+	//
+	// When EncryptedClientHelloConfigList is set, only TLS 1.3 is offered,
+	// and MinVersion, if set, must be VersionTLS13.
+	//
+	// See the Encrypted Client Hello specification (draft-ietf-tls-esni-18)
+	// for more details.
+	EncryptedClientHelloConfigList []byte
+
+	// EncryptedClientHelloRejectionVerify, if not nil, is called when ECH is
+	// rejected by the server, in order to verify the ECH provider certificate
+	// in the outer ClientHello. If it returns a non-nil error, the handshake
+	// is aborted and that error results.
+	//
+	// On the server side this field is not used.
+	//
+	// Unlike VerifyPeerCertificate and VerifyConnection, normal certificate
+	// verification will not be performed before calling
+	// EncryptedClientHelloRejectionVerify.
+	//
+	// If EncryptedClientHelloRejectionVerify is nil and ECH is rejected, the
+	// certificate is verified against the public name of the ECHConfig, and
+	// InsecureSkipVerify is ignored.
+	EncryptedClientHelloRejectionVerify func(ConnectionState) error
+
+	// EncryptedClientHelloKeys are the ECH keys to use when a client
+	// attempts ECH.
+	//
+	// If a client attempts ECH, but it is rejected by the server, the server
+	// will send a list of configs to retry based on the set of
+	// EncryptedClientHelloKeys which have the SendAsRetry field set.
+	//
+	// Keys are tried in order, so new keys can be added ahead of old ones
+	// to rotate them without rejecting clients that still use the old ones.
+	//
+	// On the client side, this field is ignored.
+	EncryptedClientHelloKeys []EncryptedClientHelloKey
+
 	// mutex protects sessionTicketKeys and autoSessionTicketKeys.
 	mutex sync.RWMutex
 	// sessionTicketKeys contains zero or more ticket keys. If set, it means the
@@ -764,6 +852,21 @@ func (c *Config) ticketKeyFromBytes(b [32]byte) (key ticketKey) {
 	return key
 }
 
+// revocationCheck returns the x509.VerifyOptions.CheckRevocation hook that
+// calls c.CheckRevocation for the chains of leaf, passing ocspResponse for leaf
+// itself. It returns nil if c.CheckRevocation is nil.
+func (c *Config) revocationCheck(leaf *x509.Certificate, ocspResponse []byte) func(cert, issuer *x509.Certificate) error {
+	if c.CheckRevocation == nil {
+		return nil
+	}
+	return func(cert, issuer *x509.Certificate) error {
+		if cert == leaf {
+			return c.CheckRevocation(cert, issuer, ocspResponse)
+		}
+		return c.CheckRevocation(cert, issuer, nil)
+	}
+}
+
 // maxSessionTicketLifetime is the maximum allowed lifetime of a TLS 1.3 session
 // ticket, and the lifetime we set for tickets we send.
 const maxSessionTicketLifetime = 7 * 24 * time.Hour
@@ -777,34 +880,38 @@ func (c *Config) Clone() *Config {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 	return &Config{
-		Rand:                        c.Rand,
-		Time:                        c.Time,
-		Certificates:                c.Certificates,
-		NameToCertificate:           c.NameToCertificate,
-		GetCertificate:              c.GetCertificate,
-		GetClientCertificate:        c.GetClientCertificate,
-		GetConfigForClient:          c.GetConfigForClient,
-		VerifyPeerCertificate:       c.VerifyPeerCertificate,
-		VerifyConnection:            c.VerifyConnection,
-		RootCAs:                     c.RootCAs,
-		NextProtos:                  c.NextProtos,
-		ServerName:                  c.ServerName,
-		ClientAuth:                  c.ClientAuth,
-		ClientCAs:                   c.ClientCAs,
-		InsecureSkipVerify:          c.InsecureSkipVerify,
-		CipherSuites:                c.CipherSuites,
-		PreferServerCipherSuites:    c.PreferServerCipherSuites,
-		SessionTicketsDisabled:      c.SessionTicketsDisabled,
-		SessionTicketKey:            c.SessionTicketKey,
-		ClientSessionCache:          c.ClientSessionCache,
-		MinVersion:                  c.MinVersion,
-		MaxVersion:                  c.MaxVersion,
-		CurvePreferences:            c.CurvePreferences,
-		DynamicRecordSizingDisabled: c.DynamicRecordSizingDisabled,
-		Renegotiation:               c.Renegotiation,
-		KeyLogWriter:                c.KeyLogWriter,
-		sessionTicketKeys:           c.sessionTicketKeys,
-		autoSessionTicketKeys:       c.autoSessionTicketKeys,
+		Rand:                                c.Rand,
+		Time:                                c.Time,
+		Certificates:                        c.Certificates,
+		NameToCertificate:                   c.NameToCertificate,
+		GetCertificate:                      c.GetCertificate,
+		GetClientCertificate:                c.GetClientCertificate,
+		GetConfigForClient:                  c.GetConfigForClient,
+		VerifyPeerCertificate:               c.VerifyPeerCertificate,
+		VerifyConnection:                    c.VerifyConnection,
+		CheckRevocation:                     c.CheckRevocation,
+		RootCAs:                             c.RootCAs,
+		NextProtos:                          c.NextProtos,
+		ServerName:                          c.ServerName,
+		ClientAuth:                          c.ClientAuth,
+		ClientCAs:                           c.ClientCAs,
+		InsecureSkipVerify:                  c.InsecureSkipVerify,
+		CipherSuites:                        c.CipherSuites,
+		PreferServerCipherSuites:            c.PreferServerCipherSuites,
+		SessionTicketsDisabled:              c.SessionTicketsDisabled,
+		SessionTicketKey:                    c.SessionTicketKey,
+		ClientSessionCache:                  c.ClientSessionCache,
+		MinVersion:                          c.MinVersion,
+		MaxVersion:                          c.MaxVersion,
+		CurvePreferences:                    c.CurvePreferences,
+		DynamicRecordSizingDisabled:         c.DynamicRecordSizingDisabled,
+		Renegotiation:                       c.Renegotiation,
+		KeyLogWriter:                        c.KeyLogWriter,
+		EncryptedClientHelloConfigList:      c.EncryptedClientHelloConfigList,
+		EncryptedClientHelloRejectionVerify: c.EncryptedClientHelloRejectionVerify,
+		EncryptedClientHelloKeys:            c.EncryptedClientHelloKeys,
+		sessionTicketKeys:                   c.sessionTicketKeys,
+		autoSessionTicketKeys:               c.autoSessionTicketKeys,
 	}
 }
 
@@ -1000,17 +1107,40 @@ func supportedVersionsFromMax(maxVersion uint16) []uint16 {
 	return versions
 }
 
-var defaultCurvePreferences = []CurveID{X25519, CurveP256, CurveP384, CurveP521}
+var defaultCurvePreferences = []CurveID{X25519MLKEM768, X25519, CurveP256, CurveP384, CurveP521}
+
+// defaultCurvePreferencesWithoutMLKEM is used instead of
+// defaultCurvePreferences when GODEBUG=tlsmlkem=0 is set.
+var defaultCurvePreferencesWithoutMLKEM = []CurveID{X25519, CurveP256, CurveP384, CurveP521}
 
-func (c *Config) curvePreferences() []CurveID {
-	if c == nil || len(c.CurvePreferences) == 0 {
-		return defaultCurvePreferences
+// disableMLKEM reports whether GODEBUG=tlsmlkem=0 is set. It is a variable
+// for testing.
+var disableMLKEM = strings.Contains(os.Getenv("GODEBUG"), "tlsmlkem=0")
+
+// curvePreferences returns the key exchanges that can be used at the given
+// protocol version, in preference order.
+func (c *Config) curvePreferences(version uint16) []CurveID {
+	curvePreferences := defaultCurvePreferences
+	if disableMLKEM {
+		curvePreferences = defaultCurvePreferencesWithoutMLKEM
+	}
+	if c != nil && len(c.CurvePreferences) != 0 {
+		curvePreferences = c.CurvePreferences
+	}
+	if version >= VersionTLS13 {
+		return curvePreferences
+	}
+	filtered := make([]CurveID, 0, len(curvePreferences))
+	for _, curve := range curvePreferences {
+		if !isTLS13OnlyKeyExchange(curve) {
+			filtered = append(filtered, curve)
+		}
 	}
-	return c.CurvePreferences
+	return filtered
 }
 
-func (c *Config) supportsCurve(curve CurveID) bool {
-	for _, cc := range c.curvePreferences() {
+func (c *Config) supportsCurve(version uint16, curve CurveID) bool {
+	for _, cc := range c.curvePreferences(version) {
 		if cc == curve {
 			return true
 		}
@@ -1169,7 +1299,7 @@ func (chi *ClientHelloInfo) SupportsCertificate(c *Certificate) error {
 	}
 
 	// The only signed key exchange we support is ECDHE.
-	if !supportsECDHE(config, chi.SupportedCurves, chi.SupportedPoints) {
+	if !supportsECDHE(config, vers, chi.SupportedCurves, chi.SupportedPoints) {
 		return supportsRSAFallback(errors.New("client doesn't support ECDHE, can only use legacy RSA key exchange"))
 	}
 
@@ -1190,7 +1320,7 @@ func (chi *ClientHelloInfo) SupportsCertificate(c *Certificate) error {
 			}
 			var curveOk bool
 			for _, c := range chi.SupportedCurves {
-				if c == curve && config.supportsCurve(c) {
+				if c == curve && config.supportsCurve(vers, c) {
 					curveOk = true
 					break
 				}
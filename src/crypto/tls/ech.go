@@ -0,0 +1,658 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/internal/hpke"
+	"errors"
+	"hash"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// This file implements Encrypted Client Hello, as specified in
+// draft-ietf-tls-esni-18.
+
+// EncryptedClientHelloKey holds a private key that is associated with a
+// specific ECH config known to a client.
+type EncryptedClientHelloKey struct {
+	// Config is a serialized ECHConfig associated with PrivateKey. It must
+	// match the config provided to clients byte-for-byte. Its KEM must be
+	// DHKEM(X25519, HKDF-SHA256) (0x0020) or DHKEM(P-256, HKDF-SHA256)
+	// (0x0010), with any of the HKDF-SHA256 (0x0001), HKDF-SHA384 (0x0002)
+	// or HKDF-SHA512 (0x0003) KDFs and any of the AES-128-GCM (0x0001),
+	// AES-256-GCM (0x0002) or ChaCha20Poly1305 (0x0003) AEADs.
+	Config []byte
+
+	// PrivateKey is the private key matching the public key in Config,
+	// encoded as by crypto/ecdh.PrivateKey.Bytes.
+	PrivateKey []byte
+
+	// SendAsRetry indicates whether Config should be sent to clients in the
+	// list of retry configs when they attempt ECH and the server rejects it.
+	SendAsRetry bool
+}
+
+// ECHRejectionError is the error returned by a client handshake when the
+// server rejects Encrypted Client Hello. If the server sent a list of configs
+// to retry with, RetryConfigList holds it as a serialized ECHConfigList,
+// suitable for Config.EncryptedClientHelloConfigList.
+//
+// An empty RetryConfigList is an authenticated signal from the server that
+// it does not support ECH, and the client may retry without it.
+type ECHRejectionError struct {
+	RetryConfigList []byte
+}
+
+func (e *ECHRejectionError) Error() string {
+	return "tls: server rejected ECH"
+}
+
+const (
+	echClientHelloOuterType uint8 = 0
+	echClientHelloInnerType uint8 = 1
+)
+
+const (
+	echAcceptConfirmationLabel    = "ech accept confirmation"
+	hrrECHAcceptConfirmationLabel = "hrr ech accept confirmation"
+)
+
+var errMalformedECHConfig = errors.New("tls: malformed ECHConfigList")
+
+type echCipher struct {
+	kdfID  uint16
+	aeadID uint16
+}
+
+type echExtension struct {
+	extType uint16
+	data    []byte
+}
+
+type echConfig struct {
+	raw []byte
+
+	version uint16
+
+	configID             uint8
+	kemID                uint16
+	publicKey            []byte
+	symmetricCipherSuite []echCipher
+
+	maxNameLength uint8
+	publicName    []byte
+	extensions    []echExtension
+}
+
+// parseECHConfig parses a single serialized ECHConfig. It returns skip true
+// if the config has an unsupported version and should be ignored.
+func parseECHConfig(enc []byte) (skip bool, ec echConfig, err error) {
+	s := cryptobyte.String(enc)
+	ec.raw = enc
+	var length uint16
+	if !s.ReadUint16(&ec.version) || !s.ReadUint16(&length) ||
+		int(length) != len(s) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	if ec.version != extensionEncryptedClientHello {
+		return true, echConfig{}, nil
+	}
+
+	var cipherSuites cryptobyte.String
+	if !s.ReadUint8(&ec.configID) ||
+		!s.ReadUint16(&ec.kemID) ||
+		!readUint16LengthPrefixed(&s, &ec.publicKey) ||
+		len(ec.publicKey) == 0 ||
+		!s.ReadUint16LengthPrefixed(&cipherSuites) ||
+		cipherSuites.Empty() {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	for !cipherSuites.Empty() {
+		var c echCipher
+		if !cipherSuites.ReadUint16(&c.kdfID) || !cipherSuites.ReadUint16(&c.aeadID) {
+			return false, echConfig{}, errMalformedECHConfig
+		}
+		ec.symmetricCipherSuite = append(ec.symmetricCipherSuite, c)
+	}
+
+	var extensions cryptobyte.String
+	if !s.ReadUint8(&ec.maxNameLength) ||
+		!readUint8LengthPrefixed(&s, &ec.publicName) ||
+		len(ec.publicName) == 0 ||
+		!s.ReadUint16LengthPrefixed(&extensions) ||
+		!s.Empty() {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	for !extensions.Empty() {
+		var e echExtension
+		if !extensions.ReadUint16(&e.extType) ||
+			!readUint16LengthPrefixed(&extensions, &e.data) {
+			return false, echConfig{}, errMalformedECHConfig
+		}
+		ec.extensions = append(ec.extensions, e)
+	}
+
+	return false, ec, nil
+}
+
+// parseECHConfigList parses a serialized ECHConfigList, returning the configs
+// with a supported version.
+func parseECHConfigList(data []byte) ([]echConfig, error) {
+	s := cryptobyte.String(data)
+	var list cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&list) || !s.Empty() || list.Empty() {
+		return nil, errMalformedECHConfig
+	}
+	var configs []echConfig
+	for !list.Empty() {
+		// Peek at the version and length to extract the whole ECHConfig.
+		header := list
+		var version, length uint16
+		if !header.ReadUint16(&version) || !header.ReadUint16(&length) {
+			return nil, errMalformedECHConfig
+		}
+		var raw []byte
+		if !list.ReadBytes(&raw, 4+int(length)) {
+			return nil, errMalformedECHConfig
+		}
+		skip, ec, err := parseECHConfig(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !skip {
+			configs = append(configs, ec)
+		}
+	}
+	return configs, nil
+}
+
+// pickECHConfig returns the first config in list that uses a supported KEM
+// and cipher suite, has a valid public name, and has no mandatory
+// extensions, or nil if there is none.
+func pickECHConfig(list []echConfig) *echConfig {
+	for i := range list {
+		ec := &list[i]
+		if _, ok := hpke.SupportedKEMs[ec.kemID]; !ok {
+			continue
+		}
+		if _, err := pickECHCipherSuite(ec.symmetricCipherSuite); err != nil {
+			continue
+		}
+		if !validECHPublicName(string(ec.publicName)) {
+			continue
+		}
+		mandatoryExtension := false
+		for _, ext := range ec.extensions {
+			// We don't support any extensions, so any config with a
+			// mandatory one, marked by the high bit, must be skipped.
+			if ext.extType&0x8000 != 0 {
+				mandatoryExtension = true
+				break
+			}
+		}
+		if mandatoryExtension {
+			continue
+		}
+		return ec
+	}
+	return nil
+}
+
+// pickECHCipherSuite returns the first supported cipher suite in suites.
+func pickECHCipherSuite(suites []echCipher) (echCipher, error) {
+	for _, s := range suites {
+		if _, ok := hpke.SupportedKDFs[s.kdfID]; !ok {
+			continue
+		}
+		if _, ok := hpke.SupportedAEADs[s.aeadID]; !ok {
+			continue
+		}
+		return s, nil
+	}
+	return echCipher{}, errors.New("tls: no supported symmetric cipher suites for ECH")
+}
+
+// validECHPublicName reports whether name is acceptable as the public name of
+// an ECHConfig, that is a DNS name which is not an IP address.
+func validECHPublicName(name string) bool {
+	if len(name) > 253 || net.ParseIP(name) != nil {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+	}
+	return true
+}
+
+// echInfo returns the HPKE info parameter for the given serialized ECHConfig.
+func echInfo(config []byte) []byte {
+	info := make([]byte, 0, len("tls ech\x00")+len(config))
+	info = append(info, "tls ech\x00"...)
+	return append(info, config...)
+}
+
+func marshalEncryptedClientHelloOuter(cipher echCipher, configID uint8, enc, payload []byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint8(echClientHelloOuterType)
+	b.AddUint16(cipher.kdfID)
+	b.AddUint16(cipher.aeadID)
+	b.AddUint8(configID)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(enc)
+	})
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(payload)
+	})
+	return b.BytesOrPanic()
+}
+
+// parseEncryptedClientHello parses the contents of an encrypted_client_hello
+// extension in a ClientHello. Only the type is set for inner extensions.
+func parseEncryptedClientHello(ext []byte) (echType uint8, cipher echCipher, configID uint8, enc, payload []byte, err error) {
+	s := cryptobyte.String(ext)
+	if !s.ReadUint8(&echType) {
+		return 0, echCipher{}, 0, nil, nil, errors.New("tls: malformed encrypted_client_hello extension")
+	}
+	if echType == echClientHelloInnerType {
+		if !s.Empty() {
+			return 0, echCipher{}, 0, nil, nil, errors.New("tls: malformed encrypted_client_hello extension")
+		}
+		return echType, echCipher{}, 0, nil, nil, nil
+	}
+	if echType != echClientHelloOuterType ||
+		!s.ReadUint16(&cipher.kdfID) ||
+		!s.ReadUint16(&cipher.aeadID) ||
+		!s.ReadUint8(&configID) ||
+		!readUint16LengthPrefixed(&s, &enc) ||
+		!readUint16LengthPrefixed(&s, &payload) ||
+		len(payload) == 0 ||
+		!s.Empty() {
+		return 0, echCipher{}, 0, nil, nil, errors.New("tls: malformed encrypted_client_hello extension")
+	}
+	return echType, cipher, configID, enc, payload, nil
+}
+
+// echClientContext is the client side state of an Encrypted Client Hello
+// handshake.
+type echClientContext struct {
+	config          *echConfig
+	cipher          echCipher
+	hpkeContext     *hpke.Sender
+	encapsulatedKey []byte
+
+	innerHello *clientHelloMsg
+	// innerTranscript is the transcript of the handshake using innerHello.
+	// It's set to nil if the server rejects ECH in a HelloRetryRequest.
+	innerTranscript hash.Hash
+
+	// retryConfigs is the ECHConfigList sent by the server on rejection.
+	retryConfigs []byte
+}
+
+// newECHClientContext parses the configured ECHConfigList and sets up the
+// HPKE context for the first supported config.
+func newECHClientContext(configList []byte) (*echClientContext, error) {
+	configs, err := parseECHConfigList(configList)
+	if err != nil {
+		return nil, err
+	}
+	config := pickECHConfig(configs)
+	if config == nil {
+		return nil, errors.New("tls: EncryptedClientHelloConfigList contains no valid configs")
+	}
+	cipher, err := pickECHCipherSuite(config.symmetricCipherSuite)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := hpke.ParseHPKEPublicKey(config.kemID, config.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	enc, sender, err := hpke.SetupSender(config.kemID, cipher.kdfID, cipher.aeadID, pub, echInfo(config.raw))
+	if err != nil {
+		return nil, err
+	}
+	return &echClientContext{
+		config:          config,
+		cipher:          cipher,
+		hpkeContext:     sender,
+		encapsulatedKey: enc,
+	}, nil
+}
+
+// encodeInnerClientHello returns the EncodedClientHelloInner for inner, with
+// the padding recommended by draft-ietf-tls-esni-18, Section 6.1.3.
+func encodeInnerClientHello(inner *clientHelloMsg, maxNameLength int) []byte {
+	h := *inner
+	h.raw = nil
+	h.sessionId = nil
+	encoded := h.marshal()[4:] // drop the handshake message header
+
+	var paddingLen int
+	if inner.serverName != "" {
+		paddingLen = maxNameLength - len(inner.serverName)
+		if paddingLen < 0 {
+			paddingLen = 0
+		}
+	} else {
+		paddingLen = maxNameLength + 9
+	}
+	paddingLen += 31 - ((len(encoded) + paddingLen - 1) % 32)
+
+	return append(encoded, make([]byte, paddingLen)...)
+}
+
+// updateOuterECHExtension encrypts inner into the encrypted_client_hello
+// extension of outer. The encapsulated key is only sent in the first
+// ClientHello, and is omitted after a HelloRetryRequest.
+func (ech *echClientContext) updateOuterECHExtension(outer, inner *clientHelloMsg, sendKey bool) error {
+	var enc []byte
+	if sendKey {
+		enc = ech.encapsulatedKey
+	}
+	encodedInner := encodeInnerClientHello(inner, int(ech.config.maxNameLength))
+
+	// All supported AEADs have a 16 byte tag. The additional data is the
+	// outer ClientHello with the payload set to zeroes.
+	payloadLen := len(encodedInner) + 16
+	outer.encryptedClientHello = marshalEncryptedClientHelloOuter(ech.cipher,
+		ech.config.configID, enc, make([]byte, payloadLen))
+	outer.raw = nil
+	aad := outer.marshal()[4:]
+
+	payload, err := ech.hpkeContext.Seal(aad, encodedInner)
+	if err != nil {
+		return err
+	}
+	if len(payload) != payloadLen {
+		return errors.New("tls: internal error: unexpected ECH payload length")
+	}
+	outer.encryptedClientHello = marshalEncryptedClientHelloOuter(ech.cipher,
+		ech.config.configID, enc, payload)
+	outer.raw = nil
+	return nil
+}
+
+// echAcceptConfirmation computes the value that signals acceptance of ECH,
+// given the random of the inner ClientHello and a transcript that already
+// includes the ServerHello or HelloRetryRequest with the confirmation set to
+// zeroes. See draft-ietf-tls-esni-18, Section 7.2.
+func (c *cipherSuiteTLS13) echAcceptConfirmation(innerRandom []byte, label string, transcript hash.Hash) []byte {
+	return c.expandLabel(c.extract(innerRandom, nil), label, transcript.Sum(nil), 8)
+}
+
+// echServerContext is the server side state of an Encrypted Client Hello
+// handshake.
+type echServerContext struct {
+	hpkeContext *hpke.Recipient
+	configID    uint8
+	cipher      echCipher
+	// inner is true if ECH was accepted and the handshake is using the
+	// decrypted inner ClientHello. Otherwise, the client offered ECH but it
+	// could not be decrypted with any of the configured keys.
+	inner bool
+}
+
+// processECHClientHello attempts to decrypt the inner ClientHello of outer
+// with the configured keys, in order. If none of them succeeds, ECH is
+// rejected and the handshake proceeds with outer.
+func (c *Conn) processECHClientHello(outer *clientHelloMsg) (*clientHelloMsg, *echServerContext, error) {
+	echType, cipher, configID, enc, payload, err := parseEncryptedClientHello(outer.encryptedClientHello)
+	if err != nil {
+		c.sendAlert(alertDecodeError)
+		return nil, nil, err
+	}
+	if echType == echClientHelloInnerType {
+		// We don't operate as the backend server of a split mode deployment,
+		// so an inner extension in the outermost ClientHello is ignored.
+		return outer, nil, nil
+	}
+
+	for _, key := range c.config.EncryptedClientHelloKeys {
+		skip, config, err := parseECHConfig(key.Config)
+		if err != nil || skip {
+			c.sendAlert(alertInternalError)
+			return nil, nil, errors.New("tls: invalid EncryptedClientHelloKeys Config")
+		}
+		if config.configID != configID || !hasECHCipher(config.symmetricCipherSuite, cipher) {
+			continue
+		}
+		priv, err := hpke.ParseHPKEPrivateKey(config.kemID, key.PrivateKey)
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return nil, nil, errors.New("tls: invalid EncryptedClientHelloKeys PrivateKey: " + err.Error())
+		}
+		recipient, err := hpke.SetupRecipient(config.kemID, cipher.kdfID, cipher.aeadID, priv, echInfo(key.Config), enc)
+		if err != nil {
+			continue
+		}
+		encodedInner, err := decryptECHPayload(recipient, outer.raw, payload)
+		if err != nil {
+			continue
+		}
+
+		inner, err := decodeInnerClientHello(outer, encodedInner)
+		if err != nil {
+			c.sendAlert(alertIllegalParameter)
+			return nil, nil, err
+		}
+		c.echAccepted = true
+		return inner, &echServerContext{
+			hpkeContext: recipient,
+			configID:    configID,
+			cipher:      cipher,
+			inner:       true,
+		}, nil
+	}
+
+	return outer, &echServerContext{}, nil
+}
+
+// processSecondECHClientHello decrypts the inner ClientHello of the outer
+// ClientHello sent in response to a HelloRetryRequest, using the HPKE context
+// established by the first one.
+func (c *Conn) processSecondECHClientHello(outer *clientHelloMsg, ech *echServerContext) (*clientHelloMsg, error) {
+	if len(outer.encryptedClientHello) == 0 {
+		c.sendAlert(alertMissingExtension)
+		return nil, errors.New("tls: second ClientHello is missing the encrypted_client_hello extension")
+	}
+	echType, cipher, configID, enc, payload, err := parseEncryptedClientHello(outer.encryptedClientHello)
+	if err != nil {
+		c.sendAlert(alertDecodeError)
+		return nil, err
+	}
+	if echType != echClientHelloOuterType || cipher != ech.cipher ||
+		configID != ech.configID || len(enc) != 0 {
+		c.sendAlert(alertIllegalParameter)
+		return nil, errors.New("tls: client changed the encrypted_client_hello extension in second ClientHello")
+	}
+	encodedInner, err := decryptECHPayload(ech.hpkeContext, outer.raw, payload)
+	if err != nil {
+		c.sendAlert(alertDecryptError)
+		return nil, errors.New("tls: failed to decrypt second inner ClientHello")
+	}
+	inner, err := decodeInnerClientHello(outer, encodedInner)
+	if err != nil {
+		c.sendAlert(alertIllegalParameter)
+		return nil, err
+	}
+	return inner, nil
+}
+
+func hasECHCipher(suites []echCipher, cipher echCipher) bool {
+	for _, s := range suites {
+		if s == cipher {
+			return true
+		}
+	}
+	return false
+}
+
+// decryptECHPayload opens payload using as additional data the serialized
+// outer ClientHello with the payload replaced by zeroes.
+func decryptECHPayload(context *hpke.Recipient, outerHello, payload []byte) ([]byte, error) {
+	aad := bytes.Replace(outerHello[4:], payload, make([]byte, len(payload)), 1)
+	return context.Open(aad, payload)
+}
+
+var errInvalidInnerClientHello = errors.New("tls: client sent invalid inner ClientHello")
+
+// decodeInnerClientHello reconstructs the inner ClientHello from its encoding,
+// copying the legacy session ID and any referenced ech_outer_extensions from
+// outer. See draft-ietf-tls-esni-18, Section 5.1.
+func decodeInnerClientHello(outer *clientHelloMsg, encoded []byte) (*clientHelloMsg, error) {
+	s := cryptobyte.String(encoded)
+	var vers uint16
+	var random []byte
+	var sessionID, cipherSuites, compressionMethods, extensions cryptobyte.String
+	if !s.ReadUint16(&vers) ||
+		!s.ReadBytes(&random, 32) ||
+		!s.ReadUint8LengthPrefixed(&sessionID) ||
+		!sessionID.Empty() ||
+		!s.ReadUint16LengthPrefixed(&cipherSuites) ||
+		!s.ReadUint8LengthPrefixed(&compressionMethods) ||
+		!s.ReadUint16LengthPrefixed(&extensions) {
+		return nil, errInvalidInnerClientHello
+	}
+	for _, p := range s {
+		if p != 0 {
+			return nil, errInvalidInnerClientHello
+		}
+	}
+
+	outerExtensions, ok := clientHelloExtensions(outer.raw)
+	if !ok {
+		return nil, errInvalidInnerClientHello
+	}
+
+	var b cryptobyte.Builder
+	b.AddUint8(typeClientHello)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint16(vers)
+		b.AddBytes(random)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(outer.sessionId)
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(cipherSuites)
+		})
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(compressionMethods)
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for !extensions.Empty() {
+				var extension uint16
+				var extData cryptobyte.String
+				if !extensions.ReadUint16(&extension) ||
+					!extensions.ReadUint16LengthPrefixed(&extData) {
+					b.SetError(errInvalidInnerClientHello)
+					return
+				}
+				if extension != extensionECHOuterExtensions {
+					b.AddUint16(extension)
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddBytes(extData)
+					})
+					continue
+				}
+
+				var refs cryptobyte.String
+				if !extData.ReadUint8LengthPrefixed(&refs) || refs.Empty() || !extData.Empty() {
+					b.SetError(errInvalidInnerClientHello)
+					return
+				}
+				for !refs.Empty() {
+					var ref uint16
+					if !refs.ReadUint16(&ref) || ref == extensionEncryptedClientHello {
+						b.SetError(errInvalidInnerClientHello)
+						return
+					}
+					// Referenced extensions must appear in the outer
+					// ClientHello in the same relative order.
+					for len(outerExtensions) > 0 && outerExtensions[0].extType != ref {
+						outerExtensions = outerExtensions[1:]
+					}
+					if len(outerExtensions) == 0 {
+						b.SetError(errInvalidInnerClientHello)
+						return
+					}
+					b.AddUint16(ref)
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddBytes(outerExtensions[0].data)
+					})
+					outerExtensions = outerExtensions[1:]
+				}
+			}
+		})
+	})
+	raw, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	inner := new(clientHelloMsg)
+	if !inner.unmarshal(raw) {
+		return nil, errInvalidInnerClientHello
+	}
+	if !bytes.Equal(inner.encryptedClientHello, []byte{echClientHelloInnerType}) {
+		return nil, errors.New("tls: client sent inner ClientHello without an inner encrypted_client_hello extension")
+	}
+	return inner, nil
+}
+
+// clientHelloExtensions returns the extensions of a serialized ClientHello
+// message, in order.
+func clientHelloExtensions(raw []byte) ([]echExtension, bool) {
+	s := cryptobyte.String(raw)
+	var ignored cryptobyte.String
+	var extensions cryptobyte.String
+	if !s.Skip(4+2+32) || // message header, version and random
+		!s.ReadUint8LengthPrefixed(&ignored) || // session ID
+		!s.ReadUint16LengthPrefixed(&ignored) || // cipher suites
+		!s.ReadUint8LengthPrefixed(&ignored) { // compression methods
+		return nil, false
+	}
+	if s.Empty() {
+		return nil, true
+	}
+	if !s.ReadUint16LengthPrefixed(&extensions) || !s.Empty() {
+		return nil, false
+	}
+	var exts []echExtension
+	for !extensions.Empty() {
+		var e echExtension
+		if !extensions.ReadUint16(&e.extType) ||
+			!readUint16LengthPrefixed(&extensions, &e.data) {
+			return nil, false
+		}
+		exts = append(exts, e)
+	}
+	return exts, true
+}
+
+// buildRetryConfigList returns the ECHConfigList of the keys marked with
+// SendAsRetry, or nil if there are none.
+func buildRetryConfigList(keys []EncryptedClientHelloKey) ([]byte, error) {
+	var atLeastOne bool
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, key := range keys {
+			if key.SendAsRetry {
+				atLeastOne = true
+				b.AddBytes(key.Config)
+			}
+		}
+	})
+	if !atLeastOne {
+		return nil, nil
+	}
+	return b.Bytes()
+}
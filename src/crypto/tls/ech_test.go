@@ -0,0 +1,341 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// marshalTestECHConfig returns a serialized ECHConfig for an X25519 public
+// key, supporting HKDF-SHA256 with AES-128-GCM and ChaCha20Poly1305.
+func marshalTestECHConfig(id uint8, pub []byte, publicName string, maxNameLength uint8) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16(extensionEncryptedClientHello)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(id)
+		b.AddUint16(0x0020) // DHKEM(X25519, HKDF-SHA256)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(pub)
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, aeadID := range []uint16{0x0001, 0x0003} {
+				b.AddUint16(0x0001) // HKDF-SHA256
+				b.AddUint16(aeadID)
+			}
+		})
+		b.AddUint8(maxNameLength)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes([]byte(publicName))
+		})
+		b.AddUint16(0) // extensions
+	})
+	return b.BytesOrPanic()
+}
+
+func marshalTestECHConfigList(configs ...[]byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, c := range configs {
+			b.AddBytes(c)
+		}
+	})
+	return b.BytesOrPanic()
+}
+
+func newTestECHKey(t *testing.T, id uint8, publicName string, sendAsRetry bool) EncryptedClientHelloKey {
+	t.Helper()
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return EncryptedClientHelloKey{
+		Config:      marshalTestECHConfig(id, priv.PublicKey().Bytes(), publicName, 32),
+		PrivateKey:  priv.Bytes(),
+		SendAsRetry: sendAsRetry,
+	}
+}
+
+// testECHHandshake runs a handshake between clientConfig and serverConfig,
+// after which the client sends a byte for the server to read, so that the
+// server observes any alert sent by the client at the end of the handshake.
+func testECHHandshake(t *testing.T, clientConfig, serverConfig *Config) (clientState, serverState ConnectionState, clientErr, serverErr error) {
+	c, s := localPipe(t)
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		server := Server(s, serverConfig)
+		defer server.Close()
+		if serverErr = server.Handshake(); serverErr != nil {
+			return
+		}
+		serverState = server.ConnectionState()
+		_, serverErr = server.Read(make([]byte, 1))
+	}()
+	client := Client(c, clientConfig)
+	if clientErr = client.Handshake(); clientErr == nil {
+		clientState = client.ConnectionState()
+		_, clientErr = client.Write([]byte{0})
+	}
+	client.Close()
+	<-done
+	return
+}
+
+func testECHConfigs(t *testing.T) (clientConfig, serverConfig *Config) {
+	issuer, err := x509.ParseCertificate(testRSACertificateIssuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(issuer)
+	now := func() time.Time { return time.Unix(1476984729, 0) }
+
+	clientConfig = &Config{
+		ServerName:         "secret.example",
+		InsecureSkipVerify: true,
+		RootCAs:            rootCAs,
+		Time:               now,
+	}
+	serverConfig = &Config{
+		Certificates: []Certificate{testConfig.Certificates[0]},
+		MinVersion:   VersionTLS13,
+		Time:         now,
+	}
+	return clientConfig, serverConfig
+}
+
+func TestECHAccepted(t *testing.T) {
+	for _, curves := range [][]CurveID{nil, {CurveP256}} {
+		clientConfig, serverConfig := testECHConfigs(t)
+		key := newTestECHKey(t, 1, "example.golang", true)
+		clientConfig.EncryptedClientHelloConfigList = marshalTestECHConfigList(key.Config)
+		serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{key}
+		// Preferring a curve the client doesn't send a key share for forces
+		// a HelloRetryRequest.
+		serverConfig.CurvePreferences = curves
+
+		var outerName string
+		serverConfig.GetConfigForClient = func(chi *ClientHelloInfo) (*Config, error) {
+			outerName = chi.ServerName
+			return nil, nil
+		}
+
+		clientState, serverState, clientErr, serverErr := testECHHandshake(t, clientConfig, serverConfig)
+		if clientErr != nil || serverErr != nil {
+			t.Fatalf("curves %v: handshake failed: client: %v, server: %v", curves, clientErr, serverErr)
+		}
+		if !clientState.ECHAccepted || !serverState.ECHAccepted {
+			t.Errorf("curves %v: ECHAccepted = %v (client), %v (server), want true", curves,
+				clientState.ECHAccepted, serverState.ECHAccepted)
+		}
+		if clientState.ServerName != "secret.example" || serverState.ServerName != "secret.example" {
+			t.Errorf("curves %v: ServerName = %q (client), %q (server), want secret.example", curves,
+				clientState.ServerName, serverState.ServerName)
+		}
+		if outerName != "secret.example" {
+			t.Errorf("curves %v: GetConfigForClient saw %q, want the inner name", curves, outerName)
+		}
+	}
+}
+
+func TestECHRejected(t *testing.T) {
+	clientConfig, serverConfig := testECHConfigs(t)
+	clientKey := newTestECHKey(t, 1, "example.golang", false)
+	retryKey := newTestECHKey(t, 2, "example.golang", true)
+	otherKey := newTestECHKey(t, 3, "example.golang", false)
+	clientConfig.EncryptedClientHelloConfigList = marshalTestECHConfigList(clientKey.Config)
+	serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{retryKey, otherKey}
+
+	var outerName string
+	serverConfig.GetConfigForClient = func(chi *ClientHelloInfo) (*Config, error) {
+		outerName = chi.ServerName
+		return nil, nil
+	}
+
+	_, _, clientErr, serverErr := testECHHandshake(t, clientConfig, serverConfig)
+	var rejection *ECHRejectionError
+	if !errors.As(clientErr, &rejection) {
+		t.Fatalf("client error = %v, want ECHRejectionError", clientErr)
+	}
+	if want := marshalTestECHConfigList(retryKey.Config); !bytes.Equal(rejection.RetryConfigList, want) {
+		t.Errorf("RetryConfigList = %x, want %x", rejection.RetryConfigList, want)
+	}
+	if serverErr == nil || !strings.Contains(serverErr.Error(), "encrypted client hello required") {
+		t.Errorf("server error = %v, want ech_required alert", serverErr)
+	}
+	if outerName != "example.golang" {
+		t.Errorf("server saw ServerName %q, want the public name", outerName)
+	}
+
+	// The retry configs are usable by the client.
+	clientConfig.EncryptedClientHelloConfigList = rejection.RetryConfigList
+	clientState, _, clientErr, serverErr := testECHHandshake(t, clientConfig, serverConfig)
+	if clientErr != nil || serverErr != nil {
+		t.Fatalf("retry failed: client: %v, server: %v", clientErr, serverErr)
+	}
+	if !clientState.ECHAccepted {
+		t.Error("retry with RetryConfigList did not use ECH")
+	}
+}
+
+func TestECHRejectedWithoutServerSupport(t *testing.T) {
+	clientConfig, serverConfig := testECHConfigs(t)
+	key := newTestECHKey(t, 1, "example.golang", true)
+	clientConfig.EncryptedClientHelloConfigList = marshalTestECHConfigList(key.Config)
+
+	_, _, clientErr, _ := testECHHandshake(t, clientConfig, serverConfig)
+	var rejection *ECHRejectionError
+	if !errors.As(clientErr, &rejection) {
+		t.Fatalf("client error = %v, want ECHRejectionError", clientErr)
+	}
+	if rejection.RetryConfigList != nil {
+		t.Errorf("RetryConfigList = %x, want nil", rejection.RetryConfigList)
+	}
+}
+
+func TestECHRejectionVerify(t *testing.T) {
+	// On rejection the certificate is verified for the public name, even
+	// with InsecureSkipVerify.
+	clientConfig, serverConfig := testECHConfigs(t)
+	key := newTestECHKey(t, 1, "public.example", true)
+	clientConfig.EncryptedClientHelloConfigList = marshalTestECHConfigList(key.Config)
+
+	_, _, clientErr, _ := testECHHandshake(t, clientConfig, serverConfig)
+	var hostnameErr x509.HostnameError
+	if !errors.As(clientErr, &hostnameErr) {
+		t.Errorf("client error = %v, want a certificate error for the public name", clientErr)
+	}
+
+	// EncryptedClientHelloRejectionVerify replaces the default verification.
+	sentinel := errors.New("rejection verified")
+	var verifiedName string
+	clientConfig.EncryptedClientHelloRejectionVerify = func(cs ConnectionState) error {
+		verifiedName = cs.ServerName
+		if len(cs.PeerCertificates) == 0 {
+			t.Error("EncryptedClientHelloRejectionVerify called without peer certificates")
+		}
+		return sentinel
+	}
+	_, _, clientErr, _ = testECHHandshake(t, clientConfig, serverConfig)
+	if clientErr != sentinel {
+		t.Errorf("client error = %v, want %v", clientErr, sentinel)
+	}
+	if verifiedName != "public.example" {
+		t.Errorf("EncryptedClientHelloRejectionVerify saw ServerName %q, want public.example", verifiedName)
+	}
+}
+
+func TestECHKeyRotation(t *testing.T) {
+	clientConfig, serverConfig := testECHConfigs(t)
+	oldKey := newTestECHKey(t, 1, "example.golang", false)
+	newKey := newTestECHKey(t, 2, "example.golang", true)
+	// Both keys share the same config ID, so the server has to try both.
+	sameIDKey := newTestECHKey(t, 2, "example.golang", false)
+	serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{newKey, sameIDKey, oldKey}
+
+	for name, key := range map[string]EncryptedClientHelloKey{
+		"old": oldKey, "new": newKey, "same ID": sameIDKey,
+	} {
+		clientConfig.EncryptedClientHelloConfigList = marshalTestECHConfigList(key.Config)
+		clientState, serverState, clientErr, serverErr := testECHHandshake(t, clientConfig, serverConfig)
+		if clientErr != nil || serverErr != nil {
+			t.Errorf("%s key: handshake failed: client: %v, server: %v", name, clientErr, serverErr)
+			continue
+		}
+		if !clientState.ECHAccepted || !serverState.ECHAccepted {
+			t.Errorf("%s key: ECH was not accepted", name)
+		}
+	}
+
+	// Once the old key is retired, its clients are sent the new config.
+	serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{newKey}
+	clientConfig.EncryptedClientHelloConfigList = marshalTestECHConfigList(oldKey.Config)
+	_, _, clientErr, _ := testECHHandshake(t, clientConfig, serverConfig)
+	var rejection *ECHRejectionError
+	if !errors.As(clientErr, &rejection) {
+		t.Fatalf("client error = %v, want ECHRejectionError", clientErr)
+	}
+	if want := marshalTestECHConfigList(newKey.Config); !bytes.Equal(rejection.RetryConfigList, want) {
+		t.Errorf("RetryConfigList = %x, want %x", rejection.RetryConfigList, want)
+	}
+}
+
+func TestECHClientConfigErrors(t *testing.T) {
+	key := newTestECHKey(t, 1, "example.golang", true)
+	unsupportedKEM := append([]byte(nil), key.Config...)
+	unsupportedKEM[5] = 0xff // kem_id
+	unsupportedVersion := append([]byte(nil), key.Config...)
+	unsupportedVersion[1] = 0x0c
+
+	tests := []struct {
+		name       string
+		configList []byte
+		minVersion uint16
+		maxVersion uint16
+	}{
+		{"malformed", []byte{0, 1, 2}, 0, 0},
+		{"empty", marshalTestECHConfigList(), 0, 0},
+		{"unsupported KEM", marshalTestECHConfigList(unsupportedKEM), 0, 0},
+		{"unsupported version", marshalTestECHConfigList(unsupportedVersion), 0, 0},
+		{"MinVersion TLS 1.2", marshalTestECHConfigList(key.Config), VersionTLS12, 0},
+		{"MaxVersion TLS 1.2", marshalTestECHConfigList(key.Config), 0, VersionTLS12},
+	}
+	for _, test := range tests {
+		clientConfig, serverConfig := testECHConfigs(t)
+		serverConfig.MinVersion = 0
+		clientConfig.EncryptedClientHelloConfigList = test.configList
+		clientConfig.MinVersion = test.minVersion
+		clientConfig.MaxVersion = test.maxVersion
+		_, _, clientErr, _ := testECHHandshake(t, clientConfig, serverConfig)
+		if clientErr == nil {
+			t.Errorf("%s: handshake succeeded, want error", test.name)
+		}
+	}
+}
+
+func TestECHInnerClientHelloPadding(t *testing.T) {
+	for _, serverName := range []string{"", "a.example", "a-much-longer-name.subdomain.example"} {
+		hello := &clientHelloMsg{
+			vers:                 VersionTLS12,
+			random:               make([]byte, 32),
+			sessionId:            make([]byte, 32),
+			cipherSuites:         []uint16{TLS_AES_128_GCM_SHA256},
+			compressionMethods:   []uint8{compressionNone},
+			serverName:           serverName,
+			supportedVersions:    []uint16{VersionTLS13},
+			encryptedClientHello: []byte{echClientHelloInnerType},
+		}
+		encoded := encodeInnerClientHello(hello, 32)
+		if len(encoded)%32 != 0 {
+			t.Errorf("%q: encoded length %d is not a multiple of 32", serverName, len(encoded))
+		}
+
+		outer := &clientHelloMsg{
+			vers:               VersionTLS12,
+			random:             make([]byte, 32),
+			sessionId:          hello.sessionId,
+			cipherSuites:       hello.cipherSuites,
+			compressionMethods: hello.compressionMethods,
+		}
+		outer.marshal()
+		decoded, err := decodeInnerClientHello(outer, encoded)
+		if err != nil {
+			t.Errorf("%q: %v", serverName, err)
+			continue
+		}
+		if !bytes.Equal(decoded.marshal(), hello.marshal()) {
+			t.Errorf("%q: decoded inner ClientHello does not match", serverName)
+		}
+	}
+}
@@ -34,27 +34,27 @@ type clientHandshakeState struct {
 	session      *ClientSessionState
 }
 
-func (c *Conn) makeClientHello() (*clientHelloMsg, ecdheParameters, error) {
+func (c *Conn) makeClientHello() (*clientHelloMsg, ecdheParameters, *echClientContext, error) {
 	config := c.config
 	if len(config.ServerName) == 0 && !config.InsecureSkipVerify {
-		return nil, nil, errors.New("tls: either ServerName or InsecureSkipVerify must be specified in the tls.Config")
+		return nil, nil, nil, errors.New("tls: either ServerName or InsecureSkipVerify must be specified in the tls.Config")
 	}
 
 	nextProtosLength := 0
 	for _, proto := range config.NextProtos {
 		if l := len(proto); l == 0 || l > 255 {
-			return nil, nil, errors.New("tls: invalid NextProtos value")
+			return nil, nil, nil, errors.New("tls: invalid NextProtos value")
 		} else {
 			nextProtosLength += 1 + l
 		}
 	}
 	if nextProtosLength > 0xffff {
-		return nil, nil, errors.New("tls: NextProtos values too large")
+		return nil, nil, nil, errors.New("tls: NextProtos values too large")
 	}
 
 	supportedVersions := config.supportedVersions()
 	if len(supportedVersions) == 0 {
-		return nil, nil, errors.New("tls: no supported versions satisfy MinVersion and MaxVersion")
+		return nil, nil, nil, errors.New("tls: no supported versions satisfy MinVersion and MaxVersion")
 	}
 
 	clientHelloVersion := config.maxSupportedVersion()
@@ -69,11 +69,10 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, ecdheParameters, error) {
 		vers:                         clientHelloVersion,
 		compressionMethods:           []uint8{compressionNone},
 		random:                       make([]byte, 32),
-		sessionId:                    make([]byte, 32),
 		ocspStapling:                 true,
 		scts:                         true,
 		serverName:                   hostnameInSNI(config.ServerName),
-		supportedCurves:              config.curvePreferences(),
+		supportedCurves:              config.curvePreferences(config.maxSupportedVersion()),
 		supportedPoints:              []uint8{pointFormatUncompressed},
 		secureRenegotiationSupported: true,
 		alpnProtocols:                config.NextProtos,
@@ -106,14 +105,19 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, ecdheParameters, error) {
 
 	_, err := io.ReadFull(config.rand(), hello.random)
 	if err != nil {
-		return nil, nil, errors.New("tls: short read from Rand: " + err.Error())
+		return nil, nil, nil, errors.New("tls: short read from Rand: " + err.Error())
 	}
 
 	// A random session ID is used to detect when the server accepted a ticket
 	// and is resuming a session (see RFC 5077). In TLS 1.3, it's always set as
 	// a compatibility measure (see RFC 8446, Section 4.1.2).
-	if _, err := io.ReadFull(config.rand(), hello.sessionId); err != nil {
-		return nil, nil, errors.New("tls: short read from Rand: " + err.Error())
+	//
+	// The session ID is not set for QUIC connections (see RFC 9001, Section 8.4).
+	if c.quic == nil {
+		hello.sessionId = make([]byte, 32)
+		if _, err := io.ReadFull(config.rand(), hello.sessionId); err != nil {
+			return nil, nil, nil, errors.New("tls: short read from Rand: " + err.Error())
+		}
 	}
 
 	if hello.vers >= VersionTLS12 {
@@ -128,18 +132,52 @@ func (c *Conn) makeClientHello() (*clientHelloMsg, ecdheParameters, error) {
 			hello.cipherSuites = append(hello.cipherSuites, defaultCipherSuitesTLS13NoAES...)
 		}
 
-		curveID := config.curvePreferences()[0]
-		if _, ok := curveForCurveID(curveID); curveID != X25519 && !ok {
-			return nil, nil, errors.New("tls: CurvePreferences includes unsupported curve")
+		curveID := config.curvePreferences(VersionTLS13)[0]
+		if _, ok := curveForCurveID(curveID); !ok && !isTLS13OnlyKeyExchange(curveID) {
+			return nil, nil, nil, errors.New("tls: CurvePreferences includes unsupported curve")
 		}
 		params, err = generateECDHEParameters(config.rand(), curveID)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 		hello.keyShares = []keyShare{{group: curveID, data: params.PublicKey()}}
+		// A hybrid key share is large and might not be supported by the
+		// server, so also send an X25519 key share reusing the same X25519
+		// key, to avoid a HelloRetryRequest if the server picks X25519.
+		if hybrid, ok := params.(*x25519MLKEM768Parameters); ok && config.supportsCurve(VersionTLS13, X25519) {
+			hello.keyShares = append(hello.keyShares, keyShare{group: X25519, data: hybrid.x25519.PublicKey()})
+		}
+	}
+
+	if c.quic != nil {
+		p, err := c.quicGetTransportParameters()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if p == nil {
+			p = []byte{}
+		}
+		hello.quicTransportParameters = p
+	}
+
+	var ech *echClientContext
+	if config.EncryptedClientHelloConfigList != nil {
+		if config.MinVersion != 0 && config.MinVersion < VersionTLS13 {
+			return nil, nil, nil, errors.New("tls: MinVersion must be at least VersionTLS13 if EncryptedClientHelloConfigList is set")
+		}
+		if hello.supportedVersions[0] != VersionTLS13 {
+			return nil, nil, nil, errors.New("tls: MaxVersion must be at least VersionTLS13 if EncryptedClientHelloConfigList is set")
+		}
+		ech, err = newECHClientContext(config.EncryptedClientHelloConfigList)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		// The inner ClientHello must only offer TLS 1.3.
+		hello.supportedVersions = []uint16{VersionTLS13}
+		hello.encryptedClientHello = []byte{echClientHelloInnerType}
 	}
 
-	return hello, params, nil
+	return hello, params, ech, nil
 }
 
 func (c *Conn) clientHandshake(ctx context.Context) (err error) {
@@ -151,11 +189,10 @@ func (c *Conn) clientHandshake(ctx context.Context) (err error) {
 	// need to be reset.
 	c.didResume = false
 
-	hello, ecdheParams, err := c.makeClientHello()
+	hello, ecdheParams, ech, err := c.makeClientHello()
 	if err != nil {
 		return err
 	}
-	c.serverName = hello.serverName
 
 	cacheKey, session, earlySecret, binderKey := c.loadSession(hello)
 	if cacheKey != "" && session != nil {
@@ -172,10 +209,40 @@ func (c *Conn) clientHandshake(ctx context.Context) (err error) {
 		}()
 	}
 
+	if ech != nil {
+		// The outer ClientHello is a copy of the inner one with a different
+		// random and the public name of the ECH config as the server name.
+		// It doesn't offer the session, which is only for the inner one.
+		ech.innerHello = hello
+		outer := *hello
+		outer.raw = nil
+		outer.serverName = string(ech.config.publicName)
+		outer.pskIdentities = nil
+		outer.pskBinders = nil
+		outer.earlyData = false
+		outer.random = make([]byte, 32)
+		if _, err := io.ReadFull(c.config.rand(), outer.random); err != nil {
+			return errors.New("tls: short read from Rand: " + err.Error())
+		}
+		if err := ech.updateOuterECHExtension(&outer, ech.innerHello, true); err != nil {
+			return err
+		}
+		hello = &outer
+	}
+	c.serverName = hello.serverName
+
 	if _, err := c.writeRecord(recordTypeHandshake, hello.marshal()); err != nil {
 		return err
 	}
 
+	if hello.earlyData {
+		suite := cipherSuiteTLS13ByID(session.cipherSuite)
+		transcript := suite.hash.New()
+		transcript.Write(hello.marshal())
+		earlyTrafficSecret := suite.deriveSecret(earlySecret, clientEarlyTrafficLabel, transcript)
+		c.quicSetWriteSecret(QUICEncryptionLevelEarly, suite.id, earlyTrafficSecret)
+	}
+
 	msg, err := c.readHandshake()
 	if err != nil {
 		return err
@@ -213,6 +280,7 @@ func (c *Conn) clientHandshake(ctx context.Context) (err error) {
 			session:     session,
 			earlySecret: earlySecret,
 			binderKey:   binderKey,
+			echContext:  ech,
 		}
 
 		// In TLS 1.3, session tickets are delivered after the handshake.
@@ -262,7 +330,10 @@ func (c *Conn) loadSession(hello *clientHelloMsg) (cacheKey string,
 	}
 
 	// Try to resume a previously negotiated TLS session, if available.
-	cacheKey = clientSessionCacheKey(c.conn.RemoteAddr(), c.config)
+	cacheKey = c.clientSessionCacheKey()
+	if cacheKey == "" {
+		return "", nil, nil, nil
+	}
 	session, ok := c.config.ClientSessionCache.Get(cacheKey)
 	if !ok || session == nil {
 		return cacheKey, nil, nil, nil
@@ -334,6 +405,21 @@ func (c *Conn) loadSession(hello *clientHelloMsg) (cacheKey string,
 		return cacheKey, nil, nil, nil
 	}
 
+	// Offer 0-RTT if the ticket allows it. The cipher suite and the ALPN
+	// protocol have to match those of the original connection. 0-RTT is only
+	// supported for QUIC, and not combined with Encrypted Client Hello.
+	if c.quic != nil && session.earlyData && len(hello.encryptedClientHello) == 0 &&
+		mutualCipherSuiteTLS13(hello.cipherSuites, session.cipherSuite) != nil {
+		alpnOK := session.alpnProtocol == "" && len(hello.alpnProtocols) == 0
+		for _, proto := range hello.alpnProtocols {
+			if proto == session.alpnProtocol {
+				alpnOK = true
+				break
+			}
+		}
+		hello.earlyData = alpnOK
+	}
+
 	// Set the pre_shared_key extension. See RFC 8446, Section 4.2.11.1.
 	ticketAge := uint32(c.config.time().Sub(session.receivedAt) / time.Millisecond)
 	identity := pskIdentity{
@@ -536,6 +622,9 @@ func (hs *clientHandshakeState) doFullHandshake() error {
 			c.sendAlert(alertUnexpectedMessage)
 			return err
 		}
+		if ka, ok := keyAgreement.(*ecdheKeyAgreement); ok {
+			c.curveID = ka.params.CurveID()
+		}
 
 		msg, err = c.readHandshake()
 		if err != nil {
@@ -854,12 +943,24 @@ func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
 		certs[i] = cert
 	}
 
-	if !c.config.InsecureSkipVerify {
+	// If the server rejected ECH, the certificate is for the public name of
+	// the ECH config, and it's verified even with InsecureSkipVerify, since
+	// it authenticates the retry configs. See draft-ietf-tls-esni-18,
+	// Section 6.1.7.
+	echRejected := c.config.EncryptedClientHelloConfigList != nil && !c.echAccepted
+	if echRejected && c.config.EncryptedClientHelloRejectionVerify != nil {
+		// Verified below with EncryptedClientHelloRejectionVerify.
+	} else if !c.config.InsecureSkipVerify || echRejected {
+		dnsName := c.config.ServerName
+		if echRejected {
+			dnsName = c.serverName
+		}
 		opts := x509.VerifyOptions{
-			Roots:         c.config.RootCAs,
-			CurrentTime:   c.config.time(),
-			DNSName:       c.config.ServerName,
-			Intermediates: x509.NewCertPool(),
+			Roots:           c.config.RootCAs,
+			CurrentTime:     c.config.time(),
+			DNSName:         dnsName,
+			Intermediates:   x509.NewCertPool(),
+			CheckRevocation: c.config.revocationCheck(certs[0], c.ocspResponse),
 		}
 		for _, cert := range certs[1:] {
 			opts.Intermediates.AddCert(cert)
@@ -882,6 +983,16 @@ func (c *Conn) verifyServerCertificate(certificates [][]byte) error {
 
 	c.peerCertificates = certs
 
+	if echRejected {
+		if c.config.EncryptedClientHelloRejectionVerify != nil {
+			if err := c.config.EncryptedClientHelloRejectionVerify(c.connectionStateLocked()); err != nil {
+				c.sendAlert(alertBadCertificate)
+				return err
+			}
+		}
+		return nil
+	}
+
 	if c.config.VerifyPeerCertificate != nil {
 		if err := c.config.VerifyPeerCertificate(certificates, c.verifiedChains); err != nil {
 			c.sendAlert(alertBadCertificate)
@@ -982,12 +1093,17 @@ func (c *Conn) getClientCertificate(cri *CertificateRequestInfo) (*Certificate,
 }
 
 // clientSessionCacheKey returns a key used to cache sessionTickets that could
-// be used to resume previously negotiated TLS sessions with a server.
-func clientSessionCacheKey(serverAddr net.Addr, config *Config) string {
-	if len(config.ServerName) > 0 {
-		return config.ServerName
+// be used to resume previously negotiated TLS sessions with a server. It
+// returns an empty string if there is no suitable key, which is the case for
+// QUIC connections without a ServerName.
+func (c *Conn) clientSessionCacheKey() string {
+	if len(c.config.ServerName) > 0 {
+		return c.config.ServerName
+	}
+	if c.conn != nil {
+		return c.conn.RemoteAddr().String()
 	}
-	return serverAddr.String()
+	return ""
 }
 
 // hostnameInSNI converts name into an appropriate hostname for SNI.
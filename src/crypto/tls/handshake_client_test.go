@@ -1523,6 +1523,10 @@ func TestVerifyConnection(t *testing.T) {
 }
 
 func testVerifyConnection(t *testing.T, version uint16) {
+	// testRSACertificate expired on 2025-01-01, so use a fixed time
+	// within its validity period.
+	now := func() time.Time { return time.Unix(1476984729, 0) }
+
 	checkFields := func(c ConnectionState, called *int, errorType string) error {
 		if c.Version != version {
 			return fmt.Errorf("%s: got Version %v, want %v", errorType, c.Version, version)
@@ -1700,6 +1704,7 @@ func testVerifyConnection(t *testing.T, version uint16) {
 			Certificates: []Certificate{testConfig.Certificates[0]},
 			ClientCAs:    rootCAs,
 			NextProtos:   []string{"protocol1"},
+			Time:         now,
 		}
 		serverConfig.Certificates[0].SignedCertificateTimestamps = [][]byte{[]byte("dummy sct 1"), []byte("dummy sct 2")}
 		serverConfig.Certificates[0].OCSPStaple = []byte("dummy ocsp")
@@ -1712,6 +1717,7 @@ func testVerifyConnection(t *testing.T, version uint16) {
 			ServerName:         "example.golang",
 			Certificates:       []Certificate{testConfig.Certificates[0]},
 			NextProtos:         []string{"protocol1"},
+			Time:               now,
 		}
 		test.configureClient(clientConfig, &clientCalled)
 
@@ -2028,6 +2034,110 @@ func testVerifyPeerCertificate(t *testing.T, version uint16) {
 	}
 }
 
+func TestCheckRevocation(t *testing.T) {
+	t.Run("TLSv12", func(t *testing.T) { testCheckRevocation(t, VersionTLS12) })
+	t.Run("TLSv13", func(t *testing.T) { testCheckRevocation(t, VersionTLS13) })
+}
+
+func testCheckRevocation(t *testing.T, version uint16) {
+	issuer, err := x509.ParseCertificate(testRSACertificateIssuer)
+	if err != nil {
+		panic(err)
+	}
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(issuer)
+
+	now := func() time.Time { return time.Unix(1476984729, 0) }
+	sentinelErr := errors.New("TestCheckRevocation")
+
+	tests := []struct {
+		serverErr, clientErr error
+	}{
+		{nil, nil},
+		{nil, sentinelErr},
+		{sentinelErr, nil},
+	}
+	for i, test := range tests {
+		c, s := localPipe(t)
+		done := make(chan error)
+
+		var clientCalled, serverCalled bool
+
+		go func() {
+			config := testConfig.Clone()
+			config.ClientAuth = RequireAndVerifyClientCert
+			config.ClientCAs = rootCAs
+			config.Time = now
+			config.MaxVersion = version
+			config.Certificates = make([]Certificate, 1)
+			config.Certificates[0].Certificate = [][]byte{testRSACertificate}
+			config.Certificates[0].PrivateKey = testRSAPrivateKey
+			config.Certificates[0].OCSPStaple = []byte("dummy ocsp")
+			config.CheckRevocation = func(cert, certIssuer *x509.Certificate, ocspResponse []byte) error {
+				serverCalled = true
+				if !certIssuer.Equal(issuer) {
+					return fmt.Errorf("server: got issuer %v, want %v", certIssuer.Subject, issuer.Subject)
+				}
+				if ocspResponse != nil {
+					return fmt.Errorf("server: got OCSP response %q, want none", ocspResponse)
+				}
+				return test.serverErr
+			}
+
+			err := Server(s, config).Handshake()
+			s.Close()
+			done <- err
+		}()
+
+		config := testConfig.Clone()
+		config.ServerName = "example.golang"
+		config.InsecureSkipVerify = false
+		config.RootCAs = rootCAs
+		config.Time = now
+		config.MaxVersion = version
+		config.CheckRevocation = func(cert, certIssuer *x509.Certificate, ocspResponse []byte) error {
+			clientCalled = true
+			if !bytes.Equal(cert.Raw, testRSACertificate) {
+				return errors.New("client: got unexpected certificate")
+			}
+			if !certIssuer.Equal(issuer) {
+				return fmt.Errorf("client: got issuer %v, want %v", certIssuer.Subject, issuer.Subject)
+			}
+			if string(ocspResponse) != "dummy ocsp" {
+				return fmt.Errorf("client: got OCSP response %q, want %q", ocspResponse, "dummy ocsp")
+			}
+			return test.clientErr
+		}
+		clientErr := Client(c, config).Handshake()
+		c.Close()
+		serverErr := <-done
+
+		if !clientCalled {
+			t.Errorf("test[%d]: client did not call CheckRevocation", i)
+		}
+		switch {
+		case test.clientErr != nil:
+			if !errors.Is(clientErr, test.clientErr) {
+				t.Errorf("test[%d]: client error = %v, want %v", i, clientErr, test.clientErr)
+			}
+		case test.serverErr != nil:
+			if !serverCalled {
+				t.Errorf("test[%d]: server did not call CheckRevocation", i)
+			}
+			if serverErr == nil || !strings.Contains(serverErr.Error(), test.serverErr.Error()) {
+				t.Errorf("test[%d]: server error = %v, want %v", i, serverErr, test.serverErr)
+			}
+		default:
+			if !serverCalled {
+				t.Errorf("test[%d]: server did not call CheckRevocation", i)
+			}
+			if clientErr != nil || serverErr != nil {
+				t.Errorf("test[%d]: handshake failed: client %v, server %v", i, clientErr, serverErr)
+			}
+		}
+	}
+}
+
 // brokenConn wraps a net.Conn and causes all Writes after a certain number to
 // fail with brokenConnErr.
 type brokenConn struct {
@@ -2496,14 +2606,19 @@ func testResumptionKeepsOCSPAndSCT(t *testing.T, ver uint16) {
 	}
 	roots := x509.NewCertPool()
 	roots.AddCert(issuer)
+	// testRSACertificate expired on 2025-01-01, so use a fixed time
+	// within its validity period.
+	now := func() time.Time { return time.Unix(1476984729, 0) }
 	clientConfig := &Config{
 		MaxVersion:         ver,
 		ClientSessionCache: NewLRUClientSessionCache(32),
 		ServerName:         "example.golang",
 		RootCAs:            roots,
+		Time:               now,
 	}
 	serverConfig := testConfig.Clone()
 	serverConfig.MaxVersion = ver
+	serverConfig.Time = now
 	serverConfig.Certificates[0].OCSPStaple = []byte{1, 2, 3}
 	serverConfig.Certificates[0].SignedCertificateTimestamps = [][]byte{{4, 5, 6}}
 
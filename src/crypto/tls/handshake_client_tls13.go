@@ -10,6 +10,7 @@ import (
 	"crypto"
 	"crypto/hmac"
 	"crypto/rsa"
+	"crypto/subtle"
 	"errors"
 	"hash"
 	"sync/atomic"
@@ -27,6 +28,10 @@ type clientHandshakeStateTLS13 struct {
 	earlySecret []byte
 	binderKey   []byte
 
+	// echContext is set if the client offered Encrypted Client Hello, in
+	// which case hello is the outer ClientHello until the server accepts it.
+	echContext *echClientContext
+
 	certReq       *certificateRequestMsgTLS13
 	usingPSK      bool
 	sentDummyCCS  bool
@@ -37,7 +42,8 @@ type clientHandshakeStateTLS13 struct {
 }
 
 // handshake requires hs.c, hs.hello, hs.serverHello, hs.ecdheParams, and,
-// optionally, hs.session, hs.earlySecret and hs.binderKey to be set.
+// optionally, hs.session, hs.earlySecret, hs.binderKey and hs.echContext to
+// be set.
 func (hs *clientHandshakeStateTLS13) handshake() error {
 	c := hs.c
 
@@ -48,8 +54,13 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 		return errors.New("tls: server selected TLS 1.3 in a renegotiation")
 	}
 
-	// Consistency check on the presence of a keyShare and its parameters.
-	if hs.ecdheParams == nil || len(hs.hello.keyShares) != 1 {
+	// Consistency check on the presence of a keyShare and its parameters. A
+	// hybrid key share may be followed by an X25519 key share.
+	maxKeyShares := 1
+	if hs.ecdheParams != nil && hs.ecdheParams.CurveID() == X25519MLKEM768 {
+		maxKeyShares = 2
+	}
+	if hs.ecdheParams == nil || len(hs.hello.keyShares) == 0 || len(hs.hello.keyShares) > maxKeyShares {
 		return c.sendAlert(alertInternalError)
 	}
 
@@ -59,6 +70,10 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 
 	hs.transcript = hs.suite.hash.New()
 	hs.transcript.Write(hs.hello.marshal())
+	if hs.echContext != nil {
+		hs.echContext.innerTranscript = hs.suite.hash.New()
+		hs.echContext.innerTranscript.Write(hs.echContext.innerHello.marshal())
+	}
 
 	if bytes.Equal(hs.serverHello.random, helloRetryRequestRandom) {
 		if err := hs.sendDummyChangeCipherSpec(); err != nil {
@@ -69,6 +84,10 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 		}
 	}
 
+	if err := hs.checkECHAcceptance(); err != nil {
+		return err
+	}
+
 	hs.transcript.Write(hs.serverHello.marshal())
 
 	c.buffering = true
@@ -100,6 +119,11 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 		return err
 	}
 
+	if hs.echContext != nil && !c.echAccepted {
+		c.sendAlert(alertECHRequired)
+		return &ECHRejectionError{RetryConfigList: hs.echContext.retryConfigs}
+	}
+
 	atomic.StoreUint32(&c.handshakeStatus, 1)
 
 	return nil
@@ -160,12 +184,53 @@ func (hs *clientHandshakeStateTLS13) checkServerHelloOrHRR() error {
 	return nil
 }
 
+// checkECHAcceptance checks whether the server accepted Encrypted Client
+// Hello in hs.serverHello, in which case it switches the handshake to the
+// inner ClientHello and its transcript. See draft-ietf-tls-esni-18,
+// Section 6.1.4.
+func (hs *clientHandshakeStateTLS13) checkECHAcceptance() error {
+	c := hs.c
+
+	if hs.echContext == nil || hs.echContext.innerTranscript == nil {
+		return nil
+	}
+
+	confTranscript := cloneHash(hs.echContext.innerTranscript, hs.suite.hash)
+	if confTranscript == nil {
+		c.sendAlert(alertInternalError)
+		return errors.New("tls: internal error: failed to clone hash")
+	}
+	serverHello := hs.serverHello.marshal()
+	confTranscript.Write(serverHello[:30])
+	confTranscript.Write(make([]byte, 8))
+	confTranscript.Write(serverHello[38:])
+	confirmation := hs.suite.echAcceptConfirmation(hs.echContext.innerHello.random,
+		echAcceptConfirmationLabel, confTranscript)
+
+	if subtle.ConstantTimeCompare(confirmation, hs.serverHello.random[24:]) != 1 {
+		if c.echAccepted {
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: server accepted ECH in HelloRetryRequest but not in ServerHello")
+		}
+		return nil
+	}
+
+	hs.hello = hs.echContext.innerHello
+	hs.transcript = hs.echContext.innerTranscript
+	c.serverName = hs.hello.serverName
+	c.echAccepted = true
+	return nil
+}
+
 // sendDummyChangeCipherSpec sends a ChangeCipherSpec record for compatibility
 // with middleboxes that didn't implement TLS correctly. See RFC 8446, Appendix D.4.
 func (hs *clientHandshakeStateTLS13) sendDummyChangeCipherSpec() error {
 	if hs.sentDummyCCS {
 		return nil
 	}
+	if hs.c.quic != nil {
+		return nil
+	}
 	hs.sentDummyCCS = true
 
 	_, err := hs.c.writeRecord(recordTypeChangeCipherSpec, []byte{1})
@@ -186,6 +251,20 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 	hs.transcript.Write(chHash)
 	hs.transcript.Write(hs.serverHello.marshal())
 
+	// hello is the ClientHello to update in response to the HRR, which is the
+	// inner one if the server accepted Encrypted Client Hello.
+	hello := hs.hello
+	if hs.echContext != nil {
+		accepted, innerCHHash, err := hs.processECHHelloRetryRequest()
+		if err != nil {
+			return err
+		}
+		if accepted {
+			hello = hs.echContext.innerHello
+			chHash = innerCHHash
+		}
+	}
+
 	// The only HelloRetryRequest extensions we support are key_share and
 	// cookie, and clients must abort the handshake if the HRR would not result
 	// in any change in the ClientHello.
@@ -195,7 +274,7 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 	}
 
 	if hs.serverHello.cookie != nil {
-		hs.hello.cookie = hs.serverHello.cookie
+		hello.cookie = hs.serverHello.cookie
 	}
 
 	if hs.serverHello.serverShare.group != 0 {
@@ -208,7 +287,7 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 	// share for it this time.
 	if curveID := hs.serverHello.selectedGroup; curveID != 0 {
 		curveOK := false
-		for _, id := range hs.hello.supportedCurves {
+		for _, id := range hello.supportedCurves {
 			if id == curveID {
 				curveOK = true
 				break
@@ -218,11 +297,13 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 			c.sendAlert(alertIllegalParameter)
 			return errors.New("tls: server selected unsupported group")
 		}
-		if hs.ecdheParams.CurveID() == curveID {
-			c.sendAlert(alertIllegalParameter)
-			return errors.New("tls: server sent an unnecessary HelloRetryRequest key_share")
+		for _, ks := range hello.keyShares {
+			if ks.group == curveID {
+				c.sendAlert(alertIllegalParameter)
+				return errors.New("tls: server sent an unnecessary HelloRetryRequest key_share")
+			}
 		}
-		if _, ok := curveForCurveID(curveID); curveID != X25519 && !ok {
+		if _, ok := curveForCurveID(curveID); !ok && !isTLS13OnlyKeyExchange(curveID) {
 			c.sendAlert(alertInternalError)
 			return errors.New("tls: CurvePreferences includes unsupported curve")
 		}
@@ -232,11 +313,18 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 			return err
 		}
 		hs.ecdheParams = params
-		hs.hello.keyShares = []keyShare{{group: curveID, data: params.PublicKey()}}
+		hello.keyShares = []keyShare{{group: curveID, data: params.PublicKey()}}
+	}
+
+	if hello.earlyData {
+		// Early data is implicitly rejected by a HelloRetryRequest, and the
+		// second ClientHello must not offer it. See RFC 8446, Section 4.2.10.
+		hello.earlyData = false
+		c.quicRejectedEarlyData()
 	}
 
-	hs.hello.raw = nil
-	if len(hs.hello.pskIdentities) > 0 {
+	hello.raw = nil
+	if len(hello.pskIdentities) > 0 {
 		pskSuite := cipherSuiteTLS13ByID(hs.session.cipherSuite)
 		if pskSuite == nil {
 			return c.sendAlert(alertInternalError)
@@ -244,26 +332,39 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 		if pskSuite.hash == hs.suite.hash {
 			// Update binders and obfuscated_ticket_age.
 			ticketAge := uint32(c.config.time().Sub(hs.session.receivedAt) / time.Millisecond)
-			hs.hello.pskIdentities[0].obfuscatedTicketAge = ticketAge + hs.session.ageAdd
+			hello.pskIdentities[0].obfuscatedTicketAge = ticketAge + hs.session.ageAdd
 
 			transcript := hs.suite.hash.New()
 			transcript.Write([]byte{typeMessageHash, 0, 0, uint8(len(chHash))})
 			transcript.Write(chHash)
 			transcript.Write(hs.serverHello.marshal())
-			transcript.Write(hs.hello.marshalWithoutBinders())
+			transcript.Write(hello.marshalWithoutBinders())
 			pskBinders := [][]byte{hs.suite.finishedHash(hs.binderKey, transcript)}
-			hs.hello.updateBinders(pskBinders)
+			hello.updateBinders(pskBinders)
 		} else {
 			// Server selected a cipher suite incompatible with the PSK.
-			hs.hello.pskIdentities = nil
-			hs.hello.pskBinders = nil
+			hello.pskIdentities = nil
+			hello.pskBinders = nil
 		}
 	}
 
-	hs.transcript.Write(hs.hello.marshal())
+	if hello != hs.hello {
+		// The server accepted ECH, so the handshake continues with the inner
+		// ClientHello, sent encrypted in an updated outer one.
+		hs.hello.cookie = hello.cookie
+		hs.hello.keyShares = hello.keyShares
+		if err := hs.echContext.updateOuterECHExtension(hs.hello, hello, false); err != nil {
+			c.sendAlert(alertInternalError)
+			return err
+		}
+		hs.transcript = hs.echContext.innerTranscript
+	}
+
+	hs.transcript.Write(hello.marshal())
 	if _, err := c.writeRecord(recordTypeHandshake, hs.hello.marshal()); err != nil {
 		return err
 	}
+	hs.hello = hello
 
 	msg, err := c.readHandshake()
 	if err != nil {
@@ -284,6 +385,46 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 	return nil
 }
 
+// processECHHelloRetryRequest updates the inner transcript with the HRR in
+// hs.serverHello, and reports whether it signals acceptance of Encrypted
+// Client Hello, along with the hash of the inner ClientHello. If ECH was
+// rejected, the handshake continues with the outer ClientHello only.
+func (hs *clientHandshakeStateTLS13) processECHHelloRetryRequest() (accepted bool, innerCHHash []byte, err error) {
+	c := hs.c
+
+	innerTranscript := hs.echContext.innerTranscript
+	innerCHHash = innerTranscript.Sum(nil)
+	innerTranscript.Reset()
+	innerTranscript.Write([]byte{typeMessageHash, 0, 0, uint8(len(innerCHHash))})
+	innerTranscript.Write(innerCHHash)
+
+	if confirmation := hs.serverHello.encryptedClientHello; confirmation != nil {
+		confTranscript := cloneHash(innerTranscript, hs.suite.hash)
+		if confTranscript == nil {
+			c.sendAlert(alertInternalError)
+			return false, nil, errors.New("tls: internal error: failed to clone hash")
+		}
+		// The confirmation is computed over the HRR with the value of the
+		// encrypted_client_hello extension, which comes last, set to zeroes.
+		hrr := append([]byte(nil), hs.serverHello.marshal()...)
+		i := bytes.LastIndex(hrr, confirmation)
+		copy(hrr[i:i+len(confirmation)], make([]byte, len(confirmation)))
+		confTranscript.Write(hrr)
+		expected := hs.suite.echAcceptConfirmation(hs.echContext.innerHello.random,
+			hrrECHAcceptConfirmationLabel, confTranscript)
+		accepted = subtle.ConstantTimeCompare(expected, confirmation) == 1
+	}
+
+	if !accepted {
+		hs.echContext.innerTranscript = nil
+		return false, nil, nil
+	}
+	innerTranscript.Write(hs.serverHello.marshal())
+	c.serverName = hs.echContext.innerHello.serverName
+	c.echAccepted = true
+	return true, innerCHHash, nil
+}
+
 func (hs *clientHandshakeStateTLS13) processServerHello() error {
 	c := hs.c
 
@@ -302,14 +443,31 @@ func (hs *clientHandshakeStateTLS13) processServerHello() error {
 		return errors.New("tls: malformed key_share extension")
 	}
 
+	if len(hs.serverHello.encryptedClientHello) != 0 {
+		c.sendAlert(alertUnsupportedExtension)
+		return errors.New("tls: server sent an encrypted_client_hello extension in a normal ServerHello")
+	}
+
 	if hs.serverHello.serverShare.group == 0 {
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: server did not send a key share")
 	}
-	if hs.serverHello.serverShare.group != hs.ecdheParams.CurveID() {
+	sentKeyShare := false
+	for _, ks := range hs.hello.keyShares {
+		if ks.group == hs.serverHello.serverShare.group {
+			sentKeyShare = true
+			break
+		}
+	}
+	if !sentKeyShare {
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: server selected unsupported group")
 	}
+	// If the server picked the X25519 key share sent alongside the hybrid
+	// one, complete the key exchange with the X25519 key alone.
+	if hybrid, ok := hs.ecdheParams.(*x25519MLKEM768Parameters); ok && hs.serverHello.serverShare.group == X25519 {
+		hs.ecdheParams = hybrid.x25519
+	}
 
 	if !hs.serverHello.selectedIdentityPresent {
 		return nil
@@ -349,6 +507,7 @@ func (hs *clientHandshakeStateTLS13) establishHandshakeKeys() error {
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: invalid server key share")
 	}
+	c.curveID = hs.ecdheParams.CurveID()
 
 	earlySecret := hs.earlySecret
 	if !hs.usingPSK {
@@ -359,10 +518,18 @@ func (hs *clientHandshakeStateTLS13) establishHandshakeKeys() error {
 
 	clientSecret := hs.suite.deriveSecret(handshakeSecret,
 		clientHandshakeTrafficLabel, hs.transcript)
-	c.out.setTrafficSecret(hs.suite, clientSecret)
+	c.out.setTrafficSecret(hs.suite, QUICEncryptionLevelHandshake, clientSecret)
 	serverSecret := hs.suite.deriveSecret(handshakeSecret,
 		serverHandshakeTrafficLabel, hs.transcript)
-	c.in.setTrafficSecret(hs.suite, serverSecret)
+	c.in.setTrafficSecret(hs.suite, QUICEncryptionLevelHandshake, serverSecret)
+
+	if c.quic != nil {
+		if c.hand.Len() != 0 {
+			return c.sendAlert(alertUnexpectedMessage)
+		}
+		c.quicSetWriteSecret(QUICEncryptionLevelHandshake, hs.suite.id, clientSecret)
+		c.quicSetReadSecret(QUICEncryptionLevelHandshake, hs.suite.id, serverSecret)
+	}
 
 	err := c.config.writeKeyLog(keyLogLabelClientHandshake, hs.hello.random, clientSecret)
 	if err != nil {
@@ -402,6 +569,48 @@ func (hs *clientHandshakeStateTLS13) readServerParameters() error {
 	}
 	c.clientProtocol = encryptedExtensions.alpnProtocol
 
+	if len(encryptedExtensions.echRetryConfigs) != 0 {
+		if hs.echContext == nil {
+			c.sendAlert(alertUnsupportedExtension)
+			return errors.New("tls: server sent ECH retry configs without the client offering ECH")
+		}
+		if !c.echAccepted {
+			hs.echContext.retryConfigs = encryptedExtensions.echRetryConfigs
+		}
+	}
+
+	if c.quic != nil {
+		if encryptedExtensions.quicTransportParameters == nil {
+			// RFC 9001 Section 8.2.
+			c.sendAlert(alertMissingExtension)
+			return errors.New("tls: server did not send a quic_transport_parameters extension")
+		}
+		c.quicSetTransportParameters(encryptedExtensions.quicTransportParameters)
+	} else {
+		if encryptedExtensions.quicTransportParameters != nil {
+			c.sendAlert(alertUnsupportedExtension)
+			return errors.New("tls: server sent an unexpected quic_transport_parameters extension")
+		}
+	}
+
+	if !hs.hello.earlyData && encryptedExtensions.earlyData {
+		c.sendAlert(alertUnsupportedExtension)
+		return errors.New("tls: server sent an unexpected early_data extension")
+	}
+	if hs.hello.earlyData && !encryptedExtensions.earlyData {
+		c.quicRejectedEarlyData()
+	}
+	if encryptedExtensions.earlyData {
+		if !hs.usingPSK || hs.session.cipherSuite != c.cipherSuite {
+			c.sendAlert(alertHandshakeFailure)
+			return errors.New("tls: server accepted 0-RTT with the wrong cipher suite")
+		}
+		if hs.session.alpnProtocol != c.clientProtocol {
+			c.sendAlert(alertHandshakeFailure)
+			return errors.New("tls: server accepted 0-RTT with the wrong ALPN")
+		}
+	}
+
 	return nil
 }
 
@@ -522,7 +731,7 @@ func (hs *clientHandshakeStateTLS13) readServerFinished() error {
 		clientApplicationTrafficLabel, hs.transcript)
 	serverSecret := hs.suite.deriveSecret(hs.masterSecret,
 		serverApplicationTrafficLabel, hs.transcript)
-	c.in.setTrafficSecret(hs.suite, serverSecret)
+	c.in.setTrafficSecret(hs.suite, QUICEncryptionLevelApplication, serverSecret)
 
 	err = c.config.writeKeyLog(keyLogLabelClientTraffic, hs.hello.random, hs.trafficSecret)
 	if err != nil {
@@ -547,14 +756,22 @@ func (hs *clientHandshakeStateTLS13) sendClientCertificate() error {
 		return nil
 	}
 
-	cert, err := c.getClientCertificate(&CertificateRequestInfo{
-		AcceptableCAs:    hs.certReq.certificateAuthorities,
-		SignatureSchemes: hs.certReq.supportedSignatureAlgorithms,
-		Version:          c.vers,
-		ctx:              hs.ctx,
-	})
-	if err != nil {
-		return err
+	var cert *Certificate
+	var err error
+	if hs.echContext != nil && !c.echAccepted {
+		// Don't authenticate to the client-facing server after an ECH
+		// rejection. See draft-ietf-tls-esni-18, Section 6.1.7.
+		cert = new(Certificate)
+	} else {
+		cert, err = c.getClientCertificate(&CertificateRequestInfo{
+			AcceptableCAs:    hs.certReq.certificateAuthorities,
+			SignatureSchemes: hs.certReq.supportedSignatureAlgorithms,
+			Version:          c.vers,
+			ctx:              hs.ctx,
+		})
+		if err != nil {
+			return err
+		}
 	}
 
 	certMsg := new(certificateMsgTLS13)
@@ -621,7 +838,11 @@ func (hs *clientHandshakeStateTLS13) sendClientFinished() error {
 		return err
 	}
 
-	c.out.setTrafficSecret(hs.suite, hs.trafficSecret)
+	c.out.setTrafficSecret(hs.suite, QUICEncryptionLevelApplication, hs.trafficSecret)
+
+	if c.quic != nil {
+		c.quicSetWriteSecret(QUICEncryptionLevelApplication, hs.suite.id, hs.trafficSecret)
+	}
 
 	if !c.config.SessionTicketsDisabled && c.config.ClientSessionCache != nil {
 		c.resumptionSecret = hs.suite.deriveSecret(hs.masterSecret,
@@ -637,6 +858,12 @@ func (c *Conn) handleNewSessionTicket(msg *newSessionTicketMsgTLS13) error {
 		return errors.New("tls: received new session ticket from a client")
 	}
 
+	if c.quic != nil && msg.maxEarlyData != 0 && msg.maxEarlyData != 0xffffffff {
+		// RFC 9001, Section 4.6.1
+		c.sendAlert(alertUnexpectedMessage)
+		return errors.New("tls: invalid early data for QUIC connection")
+	}
+
 	if c.config.SessionTicketsDisabled || c.config.ClientSessionCache == nil {
 		return nil
 	}
@@ -673,9 +900,14 @@ func (c *Conn) handleNewSessionTicket(msg *newSessionTicketMsgTLS13) error {
 		ageAdd:             msg.ageAdd,
 		ocspResponse:       c.ocspResponse,
 		scts:               c.scts,
+		earlyData:          c.quic != nil && msg.maxEarlyData == 0xffffffff,
+		alpnProtocol:       c.clientProtocol,
 	}
 
-	cacheKey := clientSessionCacheKey(c.conn.RemoteAddr(), c.config)
+	cacheKey := c.clientSessionCacheKey()
+	if cacheKey == "" {
+		return nil
+	}
 	c.config.ClientSessionCache.Put(cacheKey, session)
 
 	return nil
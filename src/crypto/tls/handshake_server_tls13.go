@@ -39,6 +39,8 @@ type serverHandshakeStateTLS13 struct {
 	trafficSecret   []byte // client_application_traffic_secret_0
 	transcript      hash.Hash
 	clientFinished  []byte
+	echContext      *echServerContext
+	earlyData       bool // the server accepted 0-RTT data
 }
 
 func (hs *serverHandshakeStateTLS13) handshake() error {
@@ -135,7 +137,14 @@ func (hs *serverHandshakeStateTLS13) processClientHello() error {
 		return errors.New("tls: initial handshake had non-empty renegotiation extension")
 	}
 
-	if hs.clientHello.earlyData {
+	if hs.clientHello.earlyData && c.quic != nil {
+		// QUIC connections may accept 0-RTT data, which is decided in
+		// checkForResumption based on the session ticket.
+		if len(hs.clientHello.pskIdentities) == 0 {
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: early_data without pre_shared_key")
+		}
+	} else if hs.clientHello.earlyData {
 		// See RFC 8446, Section 4.2.10 for the complicated behavior required
 		// here. The scenario is that a different server at our address offered
 		// to accept early data in the past, which we can't handle. For now, all
@@ -146,6 +155,25 @@ func (hs *serverHandshakeStateTLS13) processClientHello() error {
 		return errors.New("tls: client sent unexpected early data")
 	}
 
+	if c.quic != nil {
+		if hs.clientHello.quicTransportParameters == nil {
+			// RFC 9001 Section 8.2.
+			c.sendAlert(alertMissingExtension)
+			return errors.New("tls: client did not send a quic_transport_parameters extension")
+		}
+		c.quicSetTransportParameters(hs.clientHello.quicTransportParameters)
+		if len(hs.clientHello.sessionId) > 0 {
+			// RFC 9001 Section 8.4.
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: client sent unexpected session ID")
+		}
+	} else {
+		if hs.clientHello.quicTransportParameters != nil {
+			c.sendAlert(alertUnsupportedExtension)
+			return errors.New("tls: client sent an unexpected quic_transport_parameters extension")
+		}
+	}
+
 	hs.hello.sessionId = hs.clientHello.sessionId
 	hs.hello.compressionMethod = compressionNone
 
@@ -172,7 +200,7 @@ func (hs *serverHandshakeStateTLS13) processClientHello() error {
 	var selectedGroup CurveID
 	var clientKeyShare *keyShare
 GroupSelection:
-	for _, preferredGroup := range c.config.curvePreferences() {
+	for _, preferredGroup := range c.config.curvePreferences(VersionTLS13) {
 		for _, ks := range hs.clientHello.keyShares {
 			if ks.group == preferredGroup {
 				selectedGroup = ks.group
@@ -201,21 +229,32 @@ GroupSelection:
 		clientKeyShare = &hs.clientHello.keyShares[0]
 	}
 
-	if _, ok := curveForCurveID(selectedGroup); selectedGroup != X25519 && !ok {
-		c.sendAlert(alertInternalError)
-		return errors.New("tls: CurvePreferences includes unsupported curve")
-	}
-	params, err := generateECDHEParameters(c.config.rand(), selectedGroup)
-	if err != nil {
-		c.sendAlert(alertInternalError)
-		return err
+	if selectedGroup == X25519MLKEM768 {
+		serverShare, sharedKey, err := x25519MLKEM768ServerShare(c.config.rand(), clientKeyShare.data)
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return err
+		}
+		hs.hello.serverShare = keyShare{group: selectedGroup, data: serverShare}
+		hs.sharedKey = sharedKey
+	} else {
+		if _, ok := curveForCurveID(selectedGroup); !ok {
+			c.sendAlert(alertInternalError)
+			return errors.New("tls: CurvePreferences includes unsupported curve")
+		}
+		params, err := generateECDHEParameters(c.config.rand(), selectedGroup)
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return err
+		}
+		hs.hello.serverShare = keyShare{group: selectedGroup, data: params.PublicKey()}
+		hs.sharedKey = params.SharedKey(clientKeyShare.data)
 	}
-	hs.hello.serverShare = keyShare{group: selectedGroup, data: params.PublicKey()}
-	hs.sharedKey = params.SharedKey(clientKeyShare.data)
 	if hs.sharedKey == nil {
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: invalid client key share")
 	}
+	c.curveID = selectedGroup
 
 	c.serverName = hs.clientHello.serverName
 	return nil
@@ -268,7 +307,9 @@ func (hs *serverHandshakeStateTLS13) checkForResumption() error {
 
 		// We don't check the obfuscated ticket age because it's affected by
 		// clock skew and it's only a freshness signal useful for shrinking the
-		// window for replay attacks, which don't affect us as we don't do 0-RTT.
+		// window for replay attacks. We only do 0-RTT for QUIC, where replay
+		// protection of early data is left to the application (RFC 9001,
+		// Section 9.2).
 
 		pskSuite := cipherSuiteTLS13ByID(sessionState.cipherSuite)
 		if pskSuite == nil || pskSuite.hash != hs.suite.hash {
@@ -309,6 +350,25 @@ func (hs *serverHandshakeStateTLS13) checkForResumption() error {
 			return err
 		}
 
+		// Early data is accepted only with the first PSK, and only if the
+		// connection parameters match those of the original connection.
+		// See RFC 8446, Section 4.2.10, and RFC 9001, Section 4.6.1.
+		if hs.clientHello.earlyData && i == 0 && sessionState.earlyData &&
+			sessionState.cipherSuite == hs.suite.id {
+			accept, err := hs.earlyDataParametersMatch(sessionState)
+			if err != nil {
+				return err
+			}
+			if accept {
+				hs.earlyData = true
+
+				transcript := hs.suite.hash.New()
+				transcript.Write(hs.clientHello.marshal())
+				earlyTrafficSecret := hs.suite.deriveSecret(hs.earlySecret, clientEarlyTrafficLabel, transcript)
+				c.quicSetReadSecret(QUICEncryptionLevelEarly, hs.suite.id, earlyTrafficSecret)
+			}
+		}
+
 		hs.hello.selectedIdentityPresent = true
 		hs.hello.selectedIdentity = uint16(i)
 		hs.usingPSK = true
@@ -318,6 +378,24 @@ func (hs *serverHandshakeStateTLS13) checkForResumption() error {
 	return nil
 }
 
+// earlyDataParametersMatch reports whether the ALPN protocol and the QUIC
+// transport parameters of this connection match the ones remembered in
+// sessionState, as required to accept 0-RTT data.
+func (hs *serverHandshakeStateTLS13) earlyDataParametersMatch(sessionState *sessionStateTLS13) (bool, error) {
+	c := hs.c
+
+	selectedProto, err := negotiateALPN(c.config.NextProtos, hs.clientHello.alpnProtocols)
+	if err != nil || selectedProto != sessionState.alpnProtocol {
+		return false, nil
+	}
+	p, err := c.quicGetTransportParameters()
+	if err != nil {
+		return false, err
+	}
+	remembered, ok := quicRememberedTransportParameters(p)
+	return ok && bytes.Equal(remembered, sessionState.quicTransportParameters), nil
+}
+
 // cloneHash uses the encoding.BinaryMarshaler and encoding.BinaryUnmarshaler
 // interfaces implemented by standard library hashes to clone the state of in
 // to a new instance of h. It returns nil if the operation fails.
@@ -383,6 +461,9 @@ func (hs *serverHandshakeStateTLS13) pickCertificate() error {
 // sendDummyChangeCipherSpec sends a ChangeCipherSpec record for compatibility
 // with middleboxes that didn't implement TLS correctly. See RFC 8446, Appendix D.4.
 func (hs *serverHandshakeStateTLS13) sendDummyChangeCipherSpec() error {
+	if hs.c.quic != nil {
+		return nil
+	}
 	if hs.sentDummyCCS {
 		return nil
 	}
@@ -413,6 +494,22 @@ func (hs *serverHandshakeStateTLS13) doHelloRetryRequest(selectedGroup CurveID)
 		selectedGroup:     selectedGroup,
 	}
 
+	if hs.echContext != nil && hs.echContext.inner {
+		// Signal ECH acceptance with a confirmation computed over the HRR
+		// with the extension set to zeroes. See draft-ietf-tls-esni-18,
+		// Section 7.2.1.
+		helloRetryRequest.encryptedClientHello = make([]byte, 8)
+		confTranscript := cloneHash(hs.transcript, hs.suite.hash)
+		if confTranscript == nil {
+			c.sendAlert(alertInternalError)
+			return errors.New("tls: internal error: failed to clone hash")
+		}
+		confTranscript.Write(helloRetryRequest.marshal())
+		helloRetryRequest.encryptedClientHello = hs.suite.echAcceptConfirmation(
+			hs.clientHello.random, hrrECHAcceptConfirmationLabel, confTranscript)
+		helloRetryRequest.raw = nil
+	}
+
 	hs.transcript.Write(helloRetryRequest.marshal())
 	if _, err := c.writeRecord(recordTypeHandshake, helloRetryRequest.marshal()); err != nil {
 		return err
@@ -433,6 +530,13 @@ func (hs *serverHandshakeStateTLS13) doHelloRetryRequest(selectedGroup CurveID)
 		return unexpectedMessageError(clientHello, msg)
 	}
 
+	if hs.echContext != nil && hs.echContext.inner {
+		clientHello, err = c.processSecondECHClientHello(clientHello, hs.echContext)
+		if err != nil {
+			return err
+		}
+	}
+
 	if len(clientHello.keyShares) != 1 || clientHello.keyShares[0].group != selectedGroup {
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: client sent invalid key share in second ClientHello")
@@ -514,6 +618,24 @@ func (hs *serverHandshakeStateTLS13) sendServerParameters() error {
 	c := hs.c
 
 	hs.transcript.Write(hs.clientHello.marshal())
+
+	if hs.echContext != nil && hs.echContext.inner {
+		// Signal ECH acceptance by replacing the last eight bytes of the
+		// random with a confirmation computed over the ServerHello with
+		// those bytes set to zeroes. See draft-ietf-tls-esni-18, Section 7.2.
+		copy(hs.hello.random[24:], make([]byte, 8))
+		hs.hello.raw = nil
+		confTranscript := cloneHash(hs.transcript, hs.suite.hash)
+		if confTranscript == nil {
+			c.sendAlert(alertInternalError)
+			return errors.New("tls: internal error: failed to clone hash")
+		}
+		confTranscript.Write(hs.hello.marshal())
+		copy(hs.hello.random[24:], hs.suite.echAcceptConfirmation(
+			hs.clientHello.random, echAcceptConfirmationLabel, confTranscript))
+		hs.hello.raw = nil
+	}
+
 	hs.transcript.Write(hs.hello.marshal())
 	if _, err := c.writeRecord(recordTypeHandshake, hs.hello.marshal()); err != nil {
 		return err
@@ -532,10 +654,18 @@ func (hs *serverHandshakeStateTLS13) sendServerParameters() error {
 
 	clientSecret := hs.suite.deriveSecret(hs.handshakeSecret,
 		clientHandshakeTrafficLabel, hs.transcript)
-	c.in.setTrafficSecret(hs.suite, clientSecret)
+	c.in.setTrafficSecret(hs.suite, QUICEncryptionLevelHandshake, clientSecret)
 	serverSecret := hs.suite.deriveSecret(hs.handshakeSecret,
 		serverHandshakeTrafficLabel, hs.transcript)
-	c.out.setTrafficSecret(hs.suite, serverSecret)
+	c.out.setTrafficSecret(hs.suite, QUICEncryptionLevelHandshake, serverSecret)
+
+	if c.quic != nil {
+		if c.hand.Len() != 0 {
+			return c.sendAlert(alertUnexpectedMessage)
+		}
+		c.quicSetWriteSecret(QUICEncryptionLevelHandshake, hs.suite.id, serverSecret)
+		c.quicSetReadSecret(QUICEncryptionLevelHandshake, hs.suite.id, clientSecret)
+	}
 
 	err := c.config.writeKeyLog(keyLogLabelClientHandshake, hs.clientHello.random, clientSecret)
 	if err != nil {
@@ -557,6 +687,23 @@ func (hs *serverHandshakeStateTLS13) sendServerParameters() error {
 	}
 	encryptedExtensions.alpnProtocol = selectedProto
 	c.clientProtocol = selectedProto
+	encryptedExtensions.earlyData = hs.earlyData
+
+	if c.quic != nil {
+		p, err := c.quicGetTransportParameters()
+		if err != nil {
+			return err
+		}
+		encryptedExtensions.quicTransportParameters = p
+	}
+
+	if hs.echContext != nil && !hs.echContext.inner {
+		encryptedExtensions.echRetryConfigs, err = buildRetryConfigList(c.config.EncryptedClientHelloKeys)
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return err
+		}
+	}
 
 	hs.transcript.Write(encryptedExtensions.marshal())
 	if _, err := c.writeRecord(recordTypeHandshake, encryptedExtensions.marshal()); err != nil {
@@ -661,7 +808,11 @@ func (hs *serverHandshakeStateTLS13) sendServerFinished() error {
 		clientApplicationTrafficLabel, hs.transcript)
 	serverSecret := hs.suite.deriveSecret(hs.masterSecret,
 		serverApplicationTrafficLabel, hs.transcript)
-	c.out.setTrafficSecret(hs.suite, serverSecret)
+	c.out.setTrafficSecret(hs.suite, QUICEncryptionLevelApplication, serverSecret)
+
+	if c.quic != nil {
+		c.quicSetWriteSecret(QUICEncryptionLevelApplication, hs.suite.id, serverSecret)
+	}
 
 	err := c.config.writeKeyLog(keyLogLabelClientTraffic, hs.clientHello.random, hs.trafficSecret)
 	if err != nil {
@@ -718,6 +869,27 @@ func (hs *serverHandshakeStateTLS13) sendSessionTickets() error {
 	resumptionSecret := hs.suite.deriveSecret(hs.masterSecret,
 		resumptionLabel, hs.transcript)
 
+	// QUIC connections don't send session tickets automatically, the
+	// application sends them with QUICConn.SendSessionTicket instead,
+	// so keep the secret around for it.
+	if c.quic != nil {
+		c.resumptionSecret = resumptionSecret
+		return nil
+	}
+
+	return c.sendSessionTicket(false, resumptionSecret)
+}
+
+// sendSessionTicket sends a NewSessionTicket message for resumptionSecret,
+// the resumption secret of the completed handshake. If earlyData is true, the
+// ticket allows 0-RTT data on resumption, which is only supported for QUIC
+// connections.
+func (c *Conn) sendSessionTicket(earlyData bool, resumptionSecret []byte) error {
+	suite := cipherSuiteTLS13ByID(c.cipherSuite)
+	if suite == nil {
+		return errors.New("tls: internal error: unknown cipher suite")
+	}
+
 	m := new(newSessionTicketMsgTLS13)
 
 	var certsFromClient [][]byte
@@ -725,7 +897,7 @@ func (hs *serverHandshakeStateTLS13) sendSessionTickets() error {
 		certsFromClient = append(certsFromClient, cert.Raw)
 	}
 	state := sessionStateTLS13{
-		cipherSuite:      hs.suite.id,
+		cipherSuite:      suite.id,
 		createdAt:        uint64(c.config.time().Unix()),
 		resumptionSecret: resumptionSecret,
 		certificate: Certificate{
@@ -734,6 +906,19 @@ func (hs *serverHandshakeStateTLS13) sendSessionTickets() error {
 			SignedCertificateTimestamps: c.scts,
 		},
 	}
+	if earlyData {
+		params, ok := quicRememberedTransportParameters(c.quic.transportParams)
+		if !ok {
+			return errors.New("tls: malformed QUIC transport parameters")
+		}
+		state.earlyData = true
+		state.alpnProtocol = c.clientProtocol
+		state.quicTransportParameters = params
+		// QUIC requires max_early_data_size to be 0xffffffff if early data
+		// is allowed, as the amount of early data is limited by the QUIC
+		// transport instead. See RFC 9001, Section 4.6.1.
+		m.maxEarlyData = 0xffffffff
+	}
 	var err error
 	m.label, err = c.encryptTicket(state.marshal())
 	if err != nil {
@@ -852,7 +1037,7 @@ func (hs *serverHandshakeStateTLS13) readClientFinished() error {
 		return errors.New("tls: invalid client finished hash")
 	}
 
-	c.in.setTrafficSecret(hs.suite, hs.trafficSecret)
+	c.in.setTrafficSecret(hs.suite, QUICEncryptionLevelApplication, hs.trafficSecret)
 
 	return nil
 }
@@ -364,6 +364,9 @@ func runMain(m *testing.M) int {
 		Certificates:       make([]Certificate, 2),
 		InsecureSkipVerify: true,
 		CipherSuites:       allCipherSuites(),
+		// The recorded handshakes predate X25519MLKEM768, so they use the
+		// previous default curve preferences.
+		CurvePreferences: []CurveID{X25519, CurveP256, CurveP384, CurveP521},
 	}
 	testConfig.Certificates[0].Certificate = [][]byte{testRSACertificate}
 	testConfig.Certificates[0].PrivateKey = testRSAPrivateKey
@@ -5,15 +5,14 @@
 package tls
 
 import (
-	"crypto/elliptic"
+	"crypto/ecdh"
 	"crypto/hmac"
+	"crypto/internal/mlkem768"
 	"errors"
 	"hash"
 	"io"
-	"math/big"
 
 	"golang.org/x/crypto/cryptobyte"
-	"golang.org/x/crypto/curve25519"
 	"golang.org/x/crypto/hkdf"
 )
 
@@ -22,6 +21,7 @@ import (
 
 const (
 	resumptionBinderLabel         = "res binder"
+	clientEarlyTrafficLabel       = "c e traffic"
 	clientHandshakeTrafficLabel   = "c hs traffic"
 	serverHandshakeTrafficLabel   = "s hs traffic"
 	clientApplicationTrafficLabel = "c ap traffic"
@@ -110,16 +110,8 @@ type ecdheParameters interface {
 }
 
 func generateECDHEParameters(rand io.Reader, curveID CurveID) (ecdheParameters, error) {
-	if curveID == X25519 {
-		privateKey := make([]byte, curve25519.ScalarSize)
-		if _, err := io.ReadFull(rand, privateKey); err != nil {
-			return nil, err
-		}
-		publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
-		if err != nil {
-			return nil, err
-		}
-		return &x25519Parameters{privateKey: privateKey, publicKey: publicKey}, nil
+	if curveID == X25519MLKEM768 {
+		return generateX25519MLKEM768Parameters(rand)
 	}
 
 	curve, ok := curveForCurveID(curveID)
@@ -127,73 +119,133 @@ func generateECDHEParameters(rand io.Reader, curveID CurveID) (ecdheParameters,
 		return nil, errors.New("tls: internal error: unsupported curve")
 	}
 
-	p := &nistParameters{curveID: curveID}
-	var err error
-	p.privateKey, p.x, p.y, err = elliptic.GenerateKey(curve, rand)
+	key, err := curve.GenerateKey(rand)
 	if err != nil {
 		return nil, err
 	}
-	return p, nil
+	return &ecdhParameters{curveID: curveID, key: key}, nil
 }
 
-func curveForCurveID(id CurveID) (elliptic.Curve, bool) {
+func curveForCurveID(id CurveID) (ecdh.Curve, bool) {
 	switch id {
+	case X25519:
+		return ecdh.X25519(), true
 	case CurveP256:
-		return elliptic.P256(), true
+		return ecdh.P256(), true
 	case CurveP384:
-		return elliptic.P384(), true
+		return ecdh.P384(), true
 	case CurveP521:
-		return elliptic.P521(), true
+		return ecdh.P521(), true
 	default:
 		return nil, false
 	}
 }
 
-type nistParameters struct {
-	privateKey []byte
-	x, y       *big.Int // public key
-	curveID    CurveID
+type ecdhParameters struct {
+	curveID CurveID
+	key     *ecdh.PrivateKey
 }
 
-func (p *nistParameters) CurveID() CurveID {
+func (p *ecdhParameters) CurveID() CurveID {
 	return p.curveID
 }
 
-func (p *nistParameters) PublicKey() []byte {
-	curve, _ := curveForCurveID(p.curveID)
-	return elliptic.Marshal(curve, p.x, p.y)
+func (p *ecdhParameters) PublicKey() []byte {
+	return p.key.PublicKey().Bytes()
 }
 
-func (p *nistParameters) SharedKey(peerPublicKey []byte) []byte {
-	curve, _ := curveForCurveID(p.curveID)
-	// Unmarshal also checks whether the given point is on the curve.
-	x, y := elliptic.Unmarshal(curve, peerPublicKey)
-	if x == nil {
+func (p *ecdhParameters) SharedKey(peerPublicKey []byte) []byte {
+	// NewPublicKey also checks whether the given point is on the curve.
+	peerKey, err := p.key.Curve().NewPublicKey(peerPublicKey)
+	if err != nil {
+		return nil
+	}
+	sharedKey, err := p.key.ECDH(peerKey)
+	if err != nil {
 		return nil
 	}
+	return sharedKey
+}
+
+// isTLS13OnlyKeyExchange reports whether curveID identifies a key exchange
+// that can only be used in TLS 1.3, and has no TLS 1.2 ECDHE equivalent.
+func isTLS13OnlyKeyExchange(curveID CurveID) bool {
+	return curveID == X25519MLKEM768
+}
+
+// x25519PublicKeySize is the size of an X25519 public key, and of the X25519
+// component of the X25519MLKEM768 key shares.
+const x25519PublicKeySize = 32
 
-	xShared, _ := curve.ScalarMult(x, y, p.privateKey)
-	sharedKey := make([]byte, (curve.Params().BitSize+7)/8)
-	return xShared.FillBytes(sharedKey)
+// x25519MLKEM768Parameters implements the client side of the X25519MLKEM768
+// hybrid key exchange, according to draft-kwiatkowski-tls-ecdhe-mlkem-02.
+//
+// The client key share is the ML-KEM-768 encapsulation key followed by the
+// X25519 public key, the server key share is the ML-KEM-768 ciphertext
+// followed by the X25519 public key, and the shared key is the ML-KEM-768
+// shared key followed by the X25519 shared key.
+type x25519MLKEM768Parameters struct {
+	// x25519 is also used for a separate X25519 key share, if the client
+	// sends one, so that the server can pick either.
+	x25519 *ecdhParameters
+	mlkem  *mlkem768.DecapsulationKey
 }
 
-type x25519Parameters struct {
-	privateKey []byte
-	publicKey  []byte
+func generateX25519MLKEM768Parameters(rand io.Reader) (*x25519MLKEM768Parameters, error) {
+	x25519, err := generateECDHEParameters(rand, X25519)
+	if err != nil {
+		return nil, err
+	}
+	mlkem, err := mlkem768.GenerateKey(rand)
+	if err != nil {
+		return nil, err
+	}
+	return &x25519MLKEM768Parameters{x25519: x25519.(*ecdhParameters), mlkem: mlkem}, nil
 }
 
-func (p *x25519Parameters) CurveID() CurveID {
-	return X25519
+func (p *x25519MLKEM768Parameters) CurveID() CurveID {
+	return X25519MLKEM768
 }
 
-func (p *x25519Parameters) PublicKey() []byte {
-	return p.publicKey[:]
+func (p *x25519MLKEM768Parameters) PublicKey() []byte {
+	return append(p.mlkem.EncapsulationKey(), p.x25519.PublicKey()...)
 }
 
-func (p *x25519Parameters) SharedKey(peerPublicKey []byte) []byte {
-	sharedKey, err := curve25519.X25519(p.privateKey, peerPublicKey)
+func (p *x25519MLKEM768Parameters) SharedKey(serverShare []byte) []byte {
+	if len(serverShare) != mlkem768.CiphertextSize+x25519PublicKeySize {
+		return nil
+	}
+	mlkemShared, err := mlkem768.Decapsulate(p.mlkem, serverShare[:mlkem768.CiphertextSize])
 	if err != nil {
 		return nil
 	}
-	return sharedKey
+	x25519Shared := p.x25519.SharedKey(serverShare[mlkem768.CiphertextSize:])
+	if x25519Shared == nil {
+		return nil
+	}
+	return append(mlkemShared, x25519Shared...)
+}
+
+// x25519MLKEM768ServerShare implements the server side of the X25519MLKEM768
+// hybrid key exchange. It returns the server key share and the shared key, or
+// a nil shared key if the client key share is invalid.
+func x25519MLKEM768ServerShare(rand io.Reader, clientShare []byte) (serverShare, sharedKey []byte, err error) {
+	if len(clientShare) != mlkem768.EncapsulationKeySize+x25519PublicKeySize {
+		return nil, nil, nil
+	}
+	x25519, err := generateECDHEParameters(rand, X25519)
+	if err != nil {
+		return nil, nil, err
+	}
+	x25519Shared := x25519.SharedKey(clientShare[mlkem768.EncapsulationKeySize:])
+	if x25519Shared == nil {
+		return nil, nil, nil
+	}
+	ciphertext, mlkemShared, err := mlkem768.Encapsulate(rand, clientShare[:mlkem768.EncapsulationKeySize])
+	if err != nil {
+		return nil, nil, nil
+	}
+	serverShare = append(ciphertext, x25519.PublicKey()...)
+	sharedKey = append(mlkemShared, x25519Shared...)
+	return serverShare, sharedKey, nil
 }
@@ -0,0 +1,537 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testQUICConn struct {
+	t           *testing.T
+	conn        *QUICConn
+	readSecret  map[QUICEncryptionLevel]suiteSecret
+	writeSecret map[QUICEncryptionLevel]suiteSecret
+	gotParams   []byte
+	complete    bool
+
+	earlyDataRejected bool
+}
+
+func newTestQUICClient(t *testing.T, config *Config) *testQUICConn {
+	q := &testQUICConn{t: t}
+	q.conn = QUICClient(&QUICConfig{
+		TLSConfig: config,
+	})
+	t.Cleanup(func() {
+		q.conn.Close()
+	})
+	return q
+}
+
+func newTestQUICServer(t *testing.T, config *Config) *testQUICConn {
+	q := &testQUICConn{t: t}
+	q.conn = QUICServer(&QUICConfig{
+		TLSConfig: config,
+	})
+	t.Cleanup(func() {
+		q.conn.Close()
+	})
+	return q
+}
+
+type suiteSecret struct {
+	suite  uint16
+	secret []byte
+}
+
+func (q *testQUICConn) setReadSecret(level QUICEncryptionLevel, suite uint16, secret []byte) {
+	if _, ok := q.writeSecret[level]; !ok && level != QUICEncryptionLevelEarly {
+		q.t.Errorf("SetReadSecret for level %v called before SetWriteSecret", level)
+	}
+	if level == QUICEncryptionLevelApplication && !q.complete {
+		q.t.Errorf("SetReadSecret for level %v called before HandshakeComplete", level)
+	}
+	if _, ok := q.readSecret[level]; ok {
+		q.t.Errorf("SetReadSecret for level %v called twice", level)
+	}
+	if q.readSecret == nil {
+		q.readSecret = map[QUICEncryptionLevel]suiteSecret{}
+	}
+	switch level {
+	case QUICEncryptionLevelEarly, QUICEncryptionLevelHandshake, QUICEncryptionLevelApplication:
+		q.readSecret[level] = suiteSecret{suite, secret}
+	default:
+		q.t.Errorf("SetReadSecret for unexpected level %v", level)
+	}
+}
+
+func (q *testQUICConn) setWriteSecret(level QUICEncryptionLevel, suite uint16, secret []byte) {
+	if _, ok := q.writeSecret[level]; ok {
+		q.t.Errorf("SetWriteSecret for level %v called twice", level)
+	}
+	if q.writeSecret == nil {
+		q.writeSecret = map[QUICEncryptionLevel]suiteSecret{}
+	}
+	switch level {
+	case QUICEncryptionLevelEarly, QUICEncryptionLevelHandshake, QUICEncryptionLevelApplication:
+		q.writeSecret[level] = suiteSecret{suite, secret}
+	default:
+		q.t.Errorf("SetWriteSecret for unexpected level %v", level)
+	}
+}
+
+var errTransportParametersRequired = errors.New("transport parameters required")
+
+func runTestQUICConnection(ctx context.Context, cli, srv *testQUICConn) error {
+	a, b := cli, srv
+	for _, c := range []*testQUICConn{a, b} {
+		if !c.conn.conn.quic.started {
+			if err := c.conn.Start(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	idleCount := 0
+	for {
+		e := a.conn.NextEvent()
+		switch e.Kind {
+		case QUICNoEvent:
+			idleCount++
+			if idleCount == 2 {
+				if !a.complete || !b.complete {
+					return errors.New("handshake incomplete")
+				}
+				return nil
+			}
+			a, b = b, a
+		case QUICSetReadSecret:
+			a.setReadSecret(e.Level, e.Suite, e.Data)
+		case QUICSetWriteSecret:
+			a.setWriteSecret(e.Level, e.Suite, e.Data)
+		case QUICWriteData:
+			if err := b.conn.HandleData(e.Level, e.Data); err != nil {
+				return err
+			}
+		case QUICTransportParameters:
+			a.gotParams = e.Data
+			if a.gotParams == nil {
+				a.gotParams = []byte{}
+			}
+		case QUICTransportParametersRequired:
+			return errTransportParametersRequired
+		case QUICRejectedEarlyData:
+			a.earlyDataRejected = true
+		case QUICHandshakeDone:
+			a.complete = true
+		}
+		if e.Kind != QUICNoEvent {
+			idleCount = 0
+		}
+	}
+}
+
+func TestQUICConnection(t *testing.T) {
+	config := testConfig.Clone()
+	config.MinVersion = VersionTLS13
+
+	cli := newTestQUICClient(t, config)
+	cli.conn.SetTransportParameters(nil)
+
+	srv := newTestQUICServer(t, config)
+	srv.conn.SetTransportParameters(nil)
+
+	if err := runTestQUICConnection(context.Background(), cli, srv); err != nil {
+		t.Fatalf("error during connection handshake: %v", err)
+	}
+
+	for _, level := range []QUICEncryptionLevel{QUICEncryptionLevelHandshake, QUICEncryptionLevelApplication} {
+		if _, ok := cli.readSecret[level]; !ok {
+			t.Errorf("client has no %v read secret", level)
+		}
+		if _, ok := srv.readSecret[level]; !ok {
+			t.Errorf("server has no %v read secret", level)
+		}
+		if !reflect.DeepEqual(cli.readSecret[level], srv.writeSecret[level]) {
+			t.Errorf("client read secret does not match server write secret for level %v", level)
+		}
+		if !reflect.DeepEqual(cli.writeSecret[level], srv.readSecret[level]) {
+			t.Errorf("client write secret does not match server read secret for level %v", level)
+		}
+	}
+}
+
+func TestQUICHandshakeError(t *testing.T) {
+	clientConfig := testConfig.Clone()
+	clientConfig.MinVersion = VersionTLS13
+	clientConfig.InsecureSkipVerify = false
+	clientConfig.ServerName = "name"
+
+	serverConfig := testConfig.Clone()
+	serverConfig.MinVersion = VersionTLS13
+
+	cli := newTestQUICClient(t, clientConfig)
+	cli.conn.SetTransportParameters(nil)
+	srv := newTestQUICServer(t, serverConfig)
+	srv.conn.SetTransportParameters(nil)
+	err := runTestQUICConnection(context.Background(), cli, srv)
+	if !errors.Is(err, AlertError(alertBadCertificate)) {
+		t.Errorf("connection handshake terminated with error %q, want alertBadCertificate", err)
+	}
+}
+
+// Test that QUICConn.ConnectionState reports the negotiated application protocol.
+func TestQUICConnectionState(t *testing.T) {
+	config := testConfig.Clone()
+	config.MinVersion = VersionTLS13
+	config.NextProtos = []string{"h3"}
+	cli := newTestQUICClient(t, config)
+	cli.conn.SetTransportParameters(nil)
+	srv := newTestQUICServer(t, config)
+	srv.conn.SetTransportParameters(nil)
+	if err := runTestQUICConnection(context.Background(), cli, srv); err != nil {
+		t.Fatalf("error during connection handshake: %v", err)
+	}
+	for _, c := range []*testQUICConn{cli, srv} {
+		cs := c.conn.ConnectionState()
+		if !cs.HandshakeComplete {
+			t.Errorf("ConnectionState.HandshakeComplete = false, want true")
+		}
+		if got, want := cs.NegotiatedProtocol, "h3"; got != want {
+			t.Errorf("ConnectionState.NegotiatedProtocol = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestQUICStartContextPropagation(t *testing.T) {
+	const key = "key"
+	const value = "value"
+	ctx := context.WithValue(context.Background(), key, value)
+	config := testConfig.Clone()
+	config.MinVersion = VersionTLS13
+	calls := 0
+	config.GetConfigForClient = func(info *ClientHelloInfo) (*Config, error) {
+		calls++
+		got, _ := info.Context().Value(key).(string)
+		if got != value {
+			t.Errorf("GetConfigForClient context key %q has value %q, want %q", key, got, value)
+		}
+		return nil, nil
+	}
+	cli := newTestQUICClient(t, config)
+	cli.conn.SetTransportParameters(nil)
+	srv := newTestQUICServer(t, config)
+	srv.conn.SetTransportParameters(nil)
+	if err := runTestQUICConnection(ctx, cli, srv); err != nil {
+		t.Fatalf("error during connection handshake: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("GetConfigForClient called %v times, want 1", calls)
+	}
+}
+
+func TestQUICDelayedTransportParameters(t *testing.T) {
+	clientConfig := testConfig.Clone()
+	clientConfig.MinVersion = VersionTLS13
+	clientConfig.ServerName = "example.go.dev"
+
+	serverConfig := testConfig.Clone()
+	serverConfig.MinVersion = VersionTLS13
+
+	cliParams := "client params"
+	srvParams := "server params"
+
+	cli := newTestQUICClient(t, clientConfig)
+	srv := newTestQUICServer(t, serverConfig)
+	if err := runTestQUICConnection(context.Background(), cli, srv); err != errTransportParametersRequired {
+		t.Fatalf("handshake with no client parameters: %v; want errTransportParametersRequired", err)
+	}
+	cli.conn.SetTransportParameters([]byte(cliParams))
+	if err := runTestQUICConnection(context.Background(), cli, srv); err != errTransportParametersRequired {
+		t.Fatalf("handshake with no server parameters: %v; want errTransportParametersRequired", err)
+	}
+	srv.conn.SetTransportParameters([]byte(srvParams))
+	if err := runTestQUICConnection(context.Background(), cli, srv); err != nil {
+		t.Fatalf("error during connection handshake: %v", err)
+	}
+
+	if got, want := string(cli.gotParams), srvParams; got != want {
+		t.Errorf("client got transport params: %q, want %q", got, want)
+	}
+	if got, want := string(srv.gotParams), cliParams; got != want {
+		t.Errorf("server got transport params: %q, want %q", got, want)
+	}
+}
+
+func TestQUICEmptyTransportParameters(t *testing.T) {
+	config := testConfig.Clone()
+	config.MinVersion = VersionTLS13
+
+	cli := newTestQUICClient(t, config)
+	cli.conn.SetTransportParameters(nil)
+	srv := newTestQUICServer(t, config)
+	srv.conn.SetTransportParameters(nil)
+	if err := runTestQUICConnection(context.Background(), cli, srv); err != nil {
+		t.Fatalf("error during connection handshake: %v", err)
+	}
+
+	if cli.gotParams == nil {
+		t.Errorf("client did not get transport params")
+	}
+	if srv.gotParams == nil {
+		t.Errorf("server did not get transport params")
+	}
+	if len(cli.gotParams) != 0 {
+		t.Errorf("client got transport params: %v, want empty", cli.gotParams)
+	}
+	if len(srv.gotParams) != 0 {
+		t.Errorf("server got transport params: %v, want empty", srv.gotParams)
+	}
+}
+
+func TestQUICCanceledWaitingForData(t *testing.T) {
+	config := testConfig.Clone()
+	config.MinVersion = VersionTLS13
+	cli := newTestQUICClient(t, config)
+	cli.conn.SetTransportParameters(nil)
+	cli.conn.Start(context.Background())
+	for cli.conn.NextEvent().Kind != QUICNoEvent {
+	}
+	err := cli.conn.Close()
+	if !errors.Is(err, alertCloseNotify) {
+		t.Errorf("conn.Close() = %v, want alertCloseNotify", err)
+	}
+}
+
+func TestQUICCanceledWaitingForTransportParams(t *testing.T) {
+	config := testConfig.Clone()
+	config.MinVersion = VersionTLS13
+	cli := newTestQUICClient(t, config)
+	cli.conn.Start(context.Background())
+	for cli.conn.NextEvent().Kind != QUICTransportParametersRequired {
+	}
+	err := cli.conn.Close()
+	if !errors.Is(err, alertCloseNotify) {
+		t.Errorf("conn.Close() = %v, want alertCloseNotify", err)
+	}
+}
+
+func TestQUICRejectsTLS12(t *testing.T) {
+	config := testConfig.Clone()
+	config.MinVersion = VersionTLS12
+	cli := newTestQUICClient(t, config)
+	if err := cli.conn.Start(context.Background()); err == nil {
+		t.Errorf("Start with MinVersion TLS 1.2 succeeded, want error")
+	}
+}
+
+func TestQUICWriteDataConcatenated(t *testing.T) {
+	config := testConfig.Clone()
+	config.MinVersion = VersionTLS13
+	cli := newTestQUICClient(t, config)
+	cli.conn.SetTransportParameters(nil)
+	if err := cli.conn.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var data []byte
+	for {
+		e := cli.conn.NextEvent()
+		if e.Kind == QUICNoEvent {
+			break
+		}
+		if e.Kind == QUICWriteData {
+			if e.Level != QUICEncryptionLevelInitial {
+				t.Errorf("client wrote data at level %v, want Initial", e.Level)
+			}
+			data = append(data, e.Data...)
+		}
+	}
+	if len(data) == 0 || data[0] != typeClientHello {
+		t.Errorf("client wrote %x, want a ClientHello handshake message", data)
+	}
+}
+
+func TestQUICSessionResumption(t *testing.T) {
+	clientConfig := testConfig.Clone()
+	clientConfig.MinVersion = VersionTLS13
+	clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+	clientConfig.ServerName = "example.go.dev"
+
+	serverConfig := testConfig.Clone()
+	serverConfig.MinVersion = VersionTLS13
+
+	cli := newTestQUICClient(t, clientConfig)
+	cli.conn.SetTransportParameters(nil)
+	srv := newTestQUICServer(t, serverConfig)
+	srv.conn.SetTransportParameters(nil)
+	if err := runTestQUICConnection(context.Background(), cli, srv); err != nil {
+		t.Fatalf("error during first connection handshake: %v", err)
+	}
+	if cli.conn.ConnectionState().DidResume {
+		t.Errorf("first connection unexpectedly used session resumption")
+	}
+	if err := srv.conn.SendSessionTicket(QUICSessionTicketOptions{}); err != nil {
+		t.Fatalf("SendSessionTicket: %v", err)
+	}
+	if err := srv.conn.SendSessionTicket(QUICSessionTicketOptions{}); err == nil {
+		t.Errorf("second SendSessionTicket call succeeded, want error")
+	}
+	if err := runTestQUICConnection(context.Background(), cli, srv); err != nil {
+		t.Fatalf("error delivering session ticket: %v", err)
+	}
+
+	cli2 := newTestQUICClient(t, clientConfig)
+	cli2.conn.SetTransportParameters(nil)
+	srv2 := newTestQUICServer(t, serverConfig)
+	srv2.conn.SetTransportParameters(nil)
+	if err := runTestQUICConnection(context.Background(), cli2, srv2); err != nil {
+		t.Fatalf("error during second connection handshake: %v", err)
+	}
+	if !cli2.conn.ConnectionState().DidResume {
+		t.Errorf("second connection did not use session resumption")
+	}
+	if _, ok := cli2.writeSecret[QUICEncryptionLevelEarly]; ok {
+		t.Errorf("client sent 0-RTT data with a ticket that doesn't allow it")
+	}
+}
+
+func TestQUICSendSessionTicketClient(t *testing.T) {
+	config := testConfig.Clone()
+	config.MinVersion = VersionTLS13
+	cli := newTestQUICClient(t, config)
+	cli.conn.SetTransportParameters(nil)
+	srv := newTestQUICServer(t, config)
+	srv.conn.SetTransportParameters(nil)
+	if err := srv.conn.SendSessionTicket(QUICSessionTicketOptions{}); err == nil {
+		t.Errorf("SendSessionTicket before the handshake succeeded, want error")
+	}
+	if err := runTestQUICConnection(context.Background(), cli, srv); err != nil {
+		t.Fatalf("error during connection handshake: %v", err)
+	}
+	if err := cli.conn.SendSessionTicket(QUICSessionTicketOptions{}); err == nil {
+		t.Errorf("SendSessionTicket on the client succeeded, want error")
+	}
+}
+
+func TestQUICEarlyData(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		modifyServer func(*Config)
+		params       [2][]byte // server transport parameters of each connection
+		wantAccepted bool
+	}{{
+		name:         "accepted",
+		modifyServer: func(*Config) {},
+		wantAccepted: true,
+	}, {
+		name:         "connection-specific transport parameters changed",
+		modifyServer: func(*Config) {},
+		params: [2][]byte{
+			{0x04, 0x01, 0x10, 0x0f, 0x01, 0x01}, // initial_max_data, initial_source_connection_id
+			{0x0f, 0x01, 0x02, 0x04, 0x01, 0x10},
+		},
+		wantAccepted: true,
+	}, {
+		name:         "transport parameters changed",
+		modifyServer: func(*Config) {},
+		params: [2][]byte{
+			{0x04, 0x01, 0x10}, // initial_max_data
+			{0x04, 0x01, 0x08},
+		},
+	}, {
+		name:         "transport parameter removed",
+		modifyServer: func(*Config) {},
+		params: [2][]byte{
+			{0x04, 0x01, 0x10}, // initial_max_data
+			{},
+		},
+	}, {
+		name: "ALPN mismatch",
+		modifyServer: func(c *Config) {
+			c.NextProtos = []string{"h3-alt"}
+		},
+	}, {
+		name: "HelloRetryRequest",
+		modifyServer: func(c *Config) {
+			c.CurvePreferences = []CurveID{CurveP256}
+		},
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			testQUICEarlyData(t, test.modifyServer, test.params, test.wantAccepted)
+		})
+	}
+}
+
+func testQUICEarlyData(t *testing.T, modifyServer func(*Config), params [2][]byte, wantAccepted bool) {
+	clientConfig := testConfig.Clone()
+	clientConfig.MinVersion = VersionTLS13
+	clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+	clientConfig.ServerName = "example.go.dev"
+	clientConfig.NextProtos = []string{"h3", "h3-alt"}
+
+	serverConfig := testConfig.Clone()
+	serverConfig.MinVersion = VersionTLS13
+	serverConfig.NextProtos = []string{"h3"}
+
+	cli := newTestQUICClient(t, clientConfig)
+	cli.conn.SetTransportParameters(nil)
+	srv := newTestQUICServer(t, serverConfig)
+	srv.conn.SetTransportParameters(params[0])
+	if err := runTestQUICConnection(context.Background(), cli, srv); err != nil {
+		t.Fatalf("error during first connection handshake: %v", err)
+	}
+	if err := srv.conn.SendSessionTicket(QUICSessionTicketOptions{EarlyData: true}); err != nil {
+		t.Fatalf("SendSessionTicket: %v", err)
+	}
+	if err := runTestQUICConnection(context.Background(), cli, srv); err != nil {
+		t.Fatalf("error delivering session ticket: %v", err)
+	}
+
+	modifyServer(serverConfig)
+	cli2 := newTestQUICClient(t, clientConfig)
+	cli2.conn.SetTransportParameters(nil)
+	srv2 := newTestQUICServer(t, serverConfig)
+	srv2.conn.SetTransportParameters(params[1])
+	if err := runTestQUICConnection(context.Background(), cli2, srv2); err != nil {
+		t.Fatalf("error during second connection handshake: %v", err)
+	}
+
+	if _, ok := cli2.writeSecret[QUICEncryptionLevelEarly]; !ok {
+		t.Fatalf("client did not offer 0-RTT data")
+	}
+	if got := cli2.earlyDataRejected; got == wantAccepted {
+		t.Errorf("client got QUICRejectedEarlyData = %v, want %v", got, !wantAccepted)
+	}
+	_, ok := srv2.readSecret[QUICEncryptionLevelEarly]
+	if ok != wantAccepted {
+		t.Errorf("server has 0-RTT read secret = %v, want %v", ok, wantAccepted)
+	}
+	if ok && !reflect.DeepEqual(cli2.writeSecret[QUICEncryptionLevelEarly], srv2.readSecret[QUICEncryptionLevelEarly]) {
+		t.Errorf("client 0-RTT write secret does not match server 0-RTT read secret")
+	}
+}
+
+func TestQUICEarlyDataMalformedTransportParameters(t *testing.T) {
+	clientConfig := testConfig.Clone()
+	clientConfig.MinVersion = VersionTLS13
+	clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+	clientConfig.ServerName = "example.go.dev"
+
+	serverConfig := testConfig.Clone()
+	serverConfig.MinVersion = VersionTLS13
+
+	cli := newTestQUICClient(t, clientConfig)
+	cli.conn.SetTransportParameters(nil)
+	srv := newTestQUICServer(t, serverConfig)
+	srv.conn.SetTransportParameters([]byte{0x04, 0x05, 0x10}) // truncated value
+	if err := runTestQUICConnection(context.Background(), cli, srv); err != nil {
+		t.Fatalf("error during connection handshake: %v", err)
+	}
+	if err := srv.conn.SendSessionTicket(QUICSessionTicketOptions{EarlyData: true}); err == nil {
+		t.Errorf("SendSessionTicket with malformed transport parameters succeeded, want error")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"context"
 	"crypto"
+	"crypto/rand"
 	"crypto/x509"
 	"encoding/json"
 	"errors"
@@ -734,7 +735,7 @@ func TestWarningAlertFlood(t *testing.T) {
 }
 
 func TestCloneFuncFields(t *testing.T) {
-	const expectedCount = 6
+	const expectedCount = 8
 	called := 0
 
 	c1 := Config{
@@ -762,6 +763,14 @@ func TestCloneFuncFields(t *testing.T) {
 			called |= 1 << 5
 			return nil
 		},
+		EncryptedClientHelloRejectionVerify: func(ConnectionState) error {
+			called |= 1 << 6
+			return nil
+		},
+		CheckRevocation: func(cert, issuer *x509.Certificate, ocspResponse []byte) error {
+			called |= 1 << 7
+			return nil
+		},
 	}
 
 	c2 := c1.Clone()
@@ -772,6 +781,8 @@ func TestCloneFuncFields(t *testing.T) {
 	c2.GetConfigForClient(nil)
 	c2.VerifyPeerCertificate(nil, nil)
 	c2.VerifyConnection(ConnectionState{})
+	c2.EncryptedClientHelloRejectionVerify(ConnectionState{})
+	c2.CheckRevocation(nil, nil, nil)
 
 	if called != (1<<expectedCount)-1 {
 		t.Fatalf("expected %d calls but saw calls %b", expectedCount, called)
@@ -790,7 +801,8 @@ func TestCloneNonFuncFields(t *testing.T) {
 		switch fn := typ.Field(i).Name; fn {
 		case "Rand":
 			f.Set(reflect.ValueOf(io.Reader(os.Stdin)))
-		case "Time", "GetCertificate", "GetConfigForClient", "VerifyPeerCertificate", "VerifyConnection", "GetClientCertificate":
+		case "Time", "GetCertificate", "GetConfigForClient", "VerifyPeerCertificate", "VerifyConnection", "GetClientCertificate",
+			"EncryptedClientHelloRejectionVerify", "CheckRevocation":
 			// DeepEqual can't compare functions. If you add a
 			// function field to this list, you must also change
 			// TestCloneFuncFields to ensure that the func field is
@@ -825,6 +837,12 @@ func TestCloneNonFuncFields(t *testing.T) {
 			f.Set(reflect.ValueOf([]CurveID{CurveP256}))
 		case "Renegotiation":
 			f.Set(reflect.ValueOf(RenegotiateOnceAsClient))
+		case "EncryptedClientHelloConfigList":
+			f.Set(reflect.ValueOf([]byte{'x'}))
+		case "EncryptedClientHelloKeys":
+			f.Set(reflect.ValueOf([]EncryptedClientHelloKey{
+				{Config: []byte{1}, PrivateKey: []byte{1}, SendAsRetry: true},
+			}))
 		case "mutex", "autoSessionTicketKeys", "sessionTicketKeys":
 			continue // these are unexported fields that are handled separately
 		default:
@@ -1042,6 +1060,140 @@ func BenchmarkLatency(b *testing.B) {
 	}
 }
 
+func TestKeyExchangeGroups(t *testing.T) {
+	tests := []struct {
+		name                       string
+		clientCurves, serverCurves []CurveID
+		clientMaxVersion           uint16
+		serverMaxVersion           uint16
+		want                       CurveID
+	}{
+		{"defaults", nil, nil, 0, 0, X25519MLKEM768},
+		{"server without hybrid", nil, []CurveID{X25519}, 0, 0, X25519},
+		{"server prefers P-256", nil, []CurveID{CurveP256, X25519MLKEM768}, 0, 0, X25519MLKEM768},
+		{"client without hybrid", []CurveID{X25519, CurveP256}, nil, 0, 0, X25519},
+		{"retry for hybrid", []CurveID{X25519, X25519MLKEM768}, []CurveID{X25519MLKEM768}, 0, 0, X25519MLKEM768},
+		{"retry from hybrid", []CurveID{X25519MLKEM768, CurveP256}, []CurveID{CurveP256}, 0, 0, CurveP256},
+		{"client TLS 1.2", nil, nil, VersionTLS12, 0, X25519},
+		{"server TLS 1.2", nil, nil, 0, VersionTLS12, X25519},
+		{"server TLS 1.2 with only hybrid", []CurveID{X25519MLKEM768}, nil, 0, VersionTLS12, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConfig := testConfig.Clone()
+			clientConfig.CurvePreferences = tt.clientCurves
+			clientConfig.MaxVersion = tt.clientMaxVersion
+			serverConfig := testConfig.Clone()
+			serverConfig.CurvePreferences = tt.serverCurves
+			serverConfig.MaxVersion = tt.serverMaxVersion
+
+			serverState, clientState, err := testHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if serverState.CurveID != tt.want || clientState.CurveID != tt.want {
+				t.Errorf("CurveID = %v (server), %v (client), want %v", serverState.CurveID, clientState.CurveID, tt.want)
+			}
+		})
+	}
+}
+
+func TestX25519MLKEM768InvalidKeyShares(t *testing.T) {
+	params, err := generateECDHEParameters(rand.Reader, X25519MLKEM768)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientShare := params.PublicKey()
+	if len(clientShare) != 1184+32 {
+		t.Fatalf("client key share is %d bytes, want %d", len(clientShare), 1184+32)
+	}
+
+	serverShare, serverKey, err := x25519MLKEM768ServerShare(rand.Reader, clientShare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serverShare) != 1088+32 {
+		t.Fatalf("server key share is %d bytes, want %d", len(serverShare), 1088+32)
+	}
+	if clientKey := params.SharedKey(serverShare); len(serverKey) != 64 || !bytes.Equal(clientKey, serverKey) {
+		t.Fatalf("shared keys don't match: %x (client), %x (server)", clientKey, serverKey)
+	}
+
+	if _, key, err := x25519MLKEM768ServerShare(rand.Reader, clientShare[:len(clientShare)-1]); err != nil || key != nil {
+		t.Errorf("short client key share: got key %x, error %v; want nil key", key, err)
+	}
+	if key := params.SharedKey(serverShare[:len(serverShare)-1]); key != nil {
+		t.Errorf("short server key share: got key %x, want nil", key)
+	}
+	// An ML-KEM-768 encapsulation key with unreduced coefficients is invalid.
+	badShare := append([]byte(nil), clientShare...)
+	badShare[0], badShare[1] = 0xff, 0xff
+	if _, key, err := x25519MLKEM768ServerShare(rand.Reader, badShare); err != nil || key != nil {
+		t.Errorf("invalid client key share: got key %x, error %v; want nil key", key, err)
+	}
+}
+
+func TestX25519MLKEM768UsesConfigRand(t *testing.T) {
+	// Key shares generated from the same random source are identical.
+	var shares [2][]byte
+	for i := range shares {
+		params, err := generateECDHEParameters(zeroSource{}, X25519MLKEM768)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shares[i] = params.PublicKey()
+	}
+	if !bytes.Equal(shares[0], shares[1]) {
+		t.Error("X25519MLKEM768 key shares from the same random source differ")
+	}
+
+	var serverShares [2][]byte
+	for i := range serverShares {
+		serverShare, _, err := x25519MLKEM768ServerShare(zeroSource{}, shares[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		serverShares[i] = serverShare
+	}
+	if !bytes.Equal(serverShares[0], serverShares[1]) {
+		t.Error("X25519MLKEM768 server key shares from the same random source differ")
+	}
+}
+
+func TestKeyExchangeGroupsGODEBUG(t *testing.T) {
+	defer func(disable bool) { disableMLKEM = disable }(disableMLKEM)
+	disableMLKEM = true
+
+	for _, curve := range (*Config)(nil).curvePreferences(VersionTLS13) {
+		if curve == X25519MLKEM768 {
+			t.Errorf("default curve preferences include X25519MLKEM768 with tlsmlkem=0")
+		}
+	}
+
+	clientConfig := testConfig.Clone()
+	clientConfig.CurvePreferences = nil
+	serverConfig := testConfig.Clone()
+	serverConfig.CurvePreferences = nil
+	serverState, clientState, err := testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serverState.CurveID != X25519 || clientState.CurveID != X25519 {
+		t.Errorf("CurveID = %v (server), %v (client), want %v", serverState.CurveID, clientState.CurveID, X25519)
+	}
+
+	// An explicit CurvePreferences is not affected.
+	clientConfig.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+	serverConfig.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+	serverState, clientState, err = testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serverState.CurveID != X25519MLKEM768 || clientState.CurveID != X25519MLKEM768 {
+		t.Errorf("CurveID = %v (server), %v (client), want %v", serverState.CurveID, clientState.CurveID, X25519MLKEM768)
+	}
+}
+
 func TestConnectionStateMarshal(t *testing.T) {
 	cs := &ConnectionState{}
 	_, err := json.Marshal(cs)
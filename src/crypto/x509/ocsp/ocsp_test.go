@@ -0,0 +1,280 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ocsp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// newCert returns a certificate for a fresh ECDSA P-256 key, signed by parent
+// or self-signed if parent is nil.
+func newCert(t *testing.T, cn string, serial int64, isCA bool, eku []x509.ExtKeyUsage, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           eku,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, priv
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, priv.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, priv
+}
+
+func TestRequestRoundTrip(t *testing.T) {
+	issuer, issuerKey := newCert(t, "Issuer", 1, true, nil, nil, nil)
+	leaf, _ := newCert(t, "Leaf", 42, false, nil, issuer, issuerKey)
+
+	for _, h := range []crypto.Hash{0, crypto.SHA1, crypto.SHA256, crypto.SHA384, crypto.SHA512} {
+		der, err := CreateRequest(leaf, issuer, &RequestOptions{Hash: h})
+		if err != nil {
+			t.Fatalf("%v: CreateRequest failed: %s", h, err)
+		}
+		req, err := ParseRequest(der)
+		if err != nil {
+			t.Fatalf("%v: ParseRequest failed: %s", h, err)
+		}
+		want := h
+		if want == 0 {
+			want = crypto.SHA1
+		}
+		if req.HashAlgorithm != want {
+			t.Errorf("%v: HashAlgorithm = %v, want %v", h, req.HashAlgorithm, want)
+		}
+		if req.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+			t.Errorf("%v: SerialNumber = %v, want %v", h, req.SerialNumber, leaf.SerialNumber)
+		}
+		hash := want.New()
+		hash.Write(issuer.RawSubject)
+		if !bytes.Equal(req.IssuerNameHash, hash.Sum(nil)) {
+			t.Errorf("%v: wrong IssuerNameHash", h)
+		}
+		marshaled, err := req.Marshal()
+		if err != nil {
+			t.Fatalf("%v: Marshal failed: %s", h, err)
+		}
+		if !bytes.Equal(marshaled, der) {
+			t.Errorf("%v: Marshal doesn't round-trip", h)
+		}
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	issuer, issuerKey := newCert(t, "Issuer", 1, true, nil, nil, nil)
+	leaf, _ := newCert(t, "Leaf", 42, false, nil, issuer, issuerKey)
+
+	thisUpdate := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	nextUpdate := thisUpdate.Add(7 * 24 * time.Hour)
+	revokedAt := thisUpdate.Add(-24 * time.Hour)
+
+	for _, template := range []Response{
+		{Status: Good},
+		{Status: Unknown, IssuerHash: crypto.SHA256},
+		{Status: Revoked, RevokedAt: revokedAt, RevocationReason: KeyCompromise},
+	} {
+		template.SerialNumber = leaf.SerialNumber
+		template.ThisUpdate = thisUpdate
+		template.NextUpdate = nextUpdate
+
+		der, err := CreateResponse(rand.Reader, issuer, issuer, template, issuerKey)
+		if err != nil {
+			t.Fatalf("status %d: CreateResponse failed: %s", template.Status, err)
+		}
+		resp, err := ParseResponseForCert(der, leaf, issuer)
+		if err != nil {
+			t.Fatalf("status %d: ParseResponseForCert failed: %s", template.Status, err)
+		}
+		if resp.Status != template.Status {
+			t.Errorf("Status = %d, want %d", resp.Status, template.Status)
+		}
+		if resp.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+			t.Errorf("SerialNumber = %v, want %v", resp.SerialNumber, leaf.SerialNumber)
+		}
+		if !resp.ThisUpdate.Equal(thisUpdate) || !resp.NextUpdate.Equal(nextUpdate) {
+			t.Errorf("ThisUpdate, NextUpdate = %v, %v, want %v, %v", resp.ThisUpdate, resp.NextUpdate,
+				thisUpdate, nextUpdate)
+		}
+		if resp.ProducedAt.IsZero() {
+			t.Errorf("ProducedAt is zero")
+		}
+		if !bytes.Equal(resp.RawResponderName, issuer.RawSubject) {
+			t.Errorf("RawResponderName = %x, want %x", resp.RawResponderName, issuer.RawSubject)
+		}
+		if resp.Certificate != nil {
+			t.Errorf("unexpected embedded responder certificate")
+		}
+		if template.Status == Revoked {
+			if !resp.RevokedAt.Equal(revokedAt) {
+				t.Errorf("RevokedAt = %v, want %v", resp.RevokedAt, revokedAt)
+			}
+			if resp.RevocationReason != KeyCompromise {
+				t.Errorf("RevocationReason = %d, want %d", resp.RevocationReason, KeyCompromise)
+			}
+		}
+	}
+
+	// A response signed by a different key must not verify.
+	other, otherKey := newCert(t, "Other", 2, true, nil, nil, nil)
+	der, err := CreateResponse(rand.Reader, issuer, other, Response{
+		Status:       Good,
+		SerialNumber: leaf.SerialNumber,
+		ThisUpdate:   thisUpdate,
+	}, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseResponse(der, issuer); err == nil {
+		t.Errorf("ParseResponse accepted a response signed by the wrong key")
+	}
+
+	if _, err := CreateResponse(rand.Reader, issuer, issuer, Response{
+		Status:       42,
+		SerialNumber: leaf.SerialNumber,
+	}, issuerKey); err == nil {
+		t.Errorf("CreateResponse accepted an invalid status")
+	}
+}
+
+func TestResponseEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Ed25519 Issuer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	issuerDER, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := x509.ParseCertificate(issuerDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := CreateResponse(rand.Reader, issuer, issuer, Response{
+		Status:       Good,
+		SerialNumber: big.NewInt(42),
+		ThisUpdate:   time.Now().Truncate(time.Minute),
+	}, priv)
+	if err != nil {
+		t.Fatalf("CreateResponse failed: %s", err)
+	}
+	resp, err := ParseResponse(der, issuer)
+	if err != nil {
+		t.Fatalf("ParseResponse failed: %s", err)
+	}
+	if resp.SignatureAlgorithm != x509.PureEd25519 {
+		t.Errorf("SignatureAlgorithm = %v, want %v", resp.SignatureAlgorithm, x509.PureEd25519)
+	}
+}
+
+func TestResponseDelegatedResponder(t *testing.T) {
+	issuer, issuerKey := newCert(t, "Issuer", 1, true, nil, nil, nil)
+	leaf, _ := newCert(t, "Leaf", 42, false, nil, issuer, issuerKey)
+	responder, responderKey := newCert(t, "Responder", 2, false,
+		[]x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}, issuer, issuerKey)
+	notResponder, notResponderKey := newCert(t, "Not a responder", 3, false,
+		[]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, issuer, issuerKey)
+
+	template := Response{
+		Status:       Good,
+		SerialNumber: leaf.SerialNumber,
+		ThisUpdate:   time.Now().Truncate(time.Minute),
+	}
+
+	template.Certificate = responder
+	der, err := CreateResponse(rand.Reader, issuer, responder, template, responderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ParseResponse(der, issuer)
+	if err != nil {
+		t.Fatalf("ParseResponse failed with a delegated responder: %s", err)
+	}
+	if resp.Certificate == nil || !resp.Certificate.Equal(responder) {
+		t.Errorf("Certificate is not the delegated responder")
+	}
+
+	template.Certificate = notResponder
+	der, err = CreateResponse(rand.Reader, issuer, notResponder, template, notResponderKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseResponse(der, issuer); err == nil {
+		t.Errorf("ParseResponse accepted a responder without the OCSP signing EKU")
+	}
+}
+
+func TestResponseForCertMismatch(t *testing.T) {
+	issuer, issuerKey := newCert(t, "Issuer", 1, true, nil, nil, nil)
+	leaf, _ := newCert(t, "Leaf", 42, false, nil, issuer, issuerKey)
+	otherLeaf, _ := newCert(t, "Other Leaf", 43, false, nil, issuer, issuerKey)
+
+	der, err := CreateResponse(rand.Reader, issuer, issuer, Response{
+		Status:       Good,
+		SerialNumber: leaf.SerialNumber,
+		ThisUpdate:   time.Now().Truncate(time.Minute),
+	}, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseResponseForCert(der, otherLeaf, issuer); err == nil {
+		t.Errorf("ParseResponseForCert accepted a response for a different serial")
+	}
+	if _, err := ParseResponse(append(der, 0), issuer); err == nil {
+		t.Errorf("ParseResponse accepted trailing data")
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	// OCSPResponse { responseStatus: tryLater }
+	der := []byte{0x30, 0x03, 0x0A, 0x01, 0x03}
+	_, err := ParseResponse(der, nil)
+	var respErr ResponseError
+	if !errors.As(err, &respErr) {
+		t.Fatalf("ParseResponse returned %v, want a ResponseError", err)
+	}
+	if respErr.Status != TryLater {
+		t.Errorf("Status = %v, want %v", respErr.Status, TryLater)
+	}
+}
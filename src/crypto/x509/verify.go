@@ -166,6 +166,21 @@ func (se SystemRootsError) Error() string {
 
 func (se SystemRootsError) Unwrap() error { return se.Err }
 
+// RevocationError results when VerifyOptions.CheckRevocation rejects a
+// certificate in every otherwise valid chain.
+type RevocationError struct {
+	// Cert is the certificate that was rejected.
+	Cert *Certificate
+	// Err is the error returned by VerifyOptions.CheckRevocation.
+	Err error
+}
+
+func (e RevocationError) Error() string {
+	return "x509: certificate revocation check failed: " + e.Err.Error()
+}
+
+func (e RevocationError) Unwrap() error { return e.Err }
+
 // errNotParsed is returned when a certificate without ASN.1 contents is
 // verified. Platform-specific verification needs the ASN.1 contents.
 var errNotParsed = errors.New("x509: missing ASN.1 contents; use ParseCertificate")
@@ -199,6 +214,18 @@ type VerifyOptions struct {
 	// certificates from consuming excessive amounts of CPU time when
 	// validating. It does not apply to the platform verifier.
 	MaxConstraintComparisions int
+
+	// CheckRevocation, if not nil, is called for each certificate of an
+	// otherwise valid chain, except for the root, along with the certificate
+	// that issued it. If it returns an error, for example because the
+	// certificate was revoked according to a CRL (see ParseRevocationList) or
+	// an OCSP response, the chain is discarded. If no chain is left, Verify
+	// returns a RevocationError wrapping the first error.
+	//
+	// CheckRevocation may be called more than once for the same certificate
+	// if it is part of multiple chains. It also applies to the chains built
+	// by the platform verifier.
+	CheckRevocation func(cert, issuer *Certificate) error
 }
 
 const (
@@ -743,7 +770,11 @@ func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err e
 
 	// Use Windows's own verification and chain building.
 	if opts.Roots == nil && runtime.GOOS == "windows" {
-		return c.systemVerify(&opts)
+		chains, err = c.systemVerify(&opts)
+		if err != nil {
+			return nil, err
+		}
+		return checkChainsForRevocation(chains, &opts)
 	}
 
 	if opts.Roots == nil {
@@ -782,7 +813,7 @@ func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err e
 	// If any key usage is acceptable then we're done.
 	for _, usage := range keyUsages {
 		if usage == ExtKeyUsageAny {
-			return candidateChains, nil
+			return checkChainsForRevocation(candidateChains, &opts)
 		}
 	}
 
@@ -796,7 +827,43 @@ func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err e
 		return nil, CertificateInvalidError{c, IncompatibleUsage, ""}
 	}
 
-	return chains, nil
+	return checkChainsForRevocation(chains, &opts)
+}
+
+// checkChainsForRevocation returns the chains in which opts.CheckRevocation
+// accepts every certificate but the root. If it's nil, all chains are
+// returned.
+func checkChainsForRevocation(chains [][]*Certificate, opts *VerifyOptions) ([][]*Certificate, error) {
+	if opts.CheckRevocation == nil {
+		return chains, nil
+	}
+
+	var valid [][]*Certificate
+	var firstErr error
+	for _, chain := range chains {
+		err := checkChainForRevocation(chain, opts.CheckRevocation)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		valid = append(valid, chain)
+	}
+
+	if len(valid) == 0 {
+		return nil, firstErr
+	}
+	return valid, nil
+}
+
+func checkChainForRevocation(chain []*Certificate, check func(cert, issuer *Certificate) error) error {
+	for i := 0; i < len(chain)-1; i++ {
+		if err := check(chain[i], chain[i+1]); err != nil {
+			return RevocationError{Cert: chain[i], Err: err}
+		}
+	}
+	return nil
 }
 
 func appendToFreshChain(chain []*Certificate, cert *Certificate) []*Certificate {
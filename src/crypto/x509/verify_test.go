@@ -14,6 +14,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
@@ -1931,6 +1932,61 @@ func TestLongChain(t *testing.T) {
 	t.Logf("verification took %v", time.Since(start))
 }
 
+func TestVerifyCheckRevocation(t *testing.T) {
+	root, rootKey, err := generateCert("Root CA", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, intermediateKey, err := generateCert("Intermediate CA", true, root, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _, err := generateCert("Leaf", false, intermediate, intermediateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots, intermediates := NewCertPool(), NewCertPool()
+	roots.AddCert(root)
+	intermediates.AddCert(intermediate)
+
+	var checked []string
+	opts := VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CheckRevocation: func(cert, issuer *Certificate) error {
+			checked = append(checked, cert.Subject.CommonName+" by "+issuer.Subject.CommonName)
+			return nil
+		},
+	}
+	if _, err := leaf.Verify(opts); err != nil {
+		t.Fatalf("Verify failed: %s", err)
+	}
+	want := []string{"Leaf by Intermediate CA", "Intermediate CA by Root CA"}
+	if !reflect.DeepEqual(checked, want) {
+		t.Errorf("CheckRevocation called for %q, want %q", checked, want)
+	}
+
+	errRevoked := errors.New("revoked")
+	opts.CheckRevocation = func(cert, issuer *Certificate) error {
+		if cert.Equal(intermediate) {
+			return errRevoked
+		}
+		return nil
+	}
+	_, err = leaf.Verify(opts)
+	var revocationErr RevocationError
+	if !errors.As(err, &revocationErr) {
+		t.Fatalf("Verify returned %v, want a RevocationError", err)
+	}
+	if !revocationErr.Cert.Equal(intermediate) {
+		t.Errorf("RevocationError.Cert = %v, want the intermediate", revocationErr.Cert.Subject)
+	}
+	if !errors.Is(err, errRevoked) {
+		t.Errorf("Verify returned %v, want it to wrap %v", err, errRevoked)
+	}
+}
+
 func TestSystemRootsError(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("Windows does not use (or support) systemRoots")
@@ -951,6 +951,7 @@ var (
 	oidExtensionCRLDistributionPoints = []int{2, 5, 29, 31}
 	oidExtensionAuthorityInfoAccess   = []int{1, 3, 6, 1, 5, 5, 7, 1, 1}
 	oidExtensionCRLNumber             = []int{2, 5, 29, 20}
+	oidExtensionDeltaCRLIndicator     = []int{2, 5, 29, 27}
 )
 
 var (
@@ -2064,9 +2065,28 @@ func (c *CertificateRequest) CheckSignature() error {
 	return checkSignature(c.SignatureAlgorithm, c.RawTBSCertificateRequest, c.Signature, c.PublicKey)
 }
 
-// RevocationList contains the fields used to create an X.509 v2 Certificate
-// Revocation list with CreateRevocationList.
+// RevocationList represents a X.509 v2 Certificate Revocation List (CRL), as
+// specified in RFC 5280, Section 5. It is returned by ParseRevocationList, and
+// contains the fields used to create a CRL with CreateRevocationList.
 type RevocationList struct {
+	// Raw contains the complete ASN.1 DER content of the CRL (tbsCertList,
+	// signatureAlgorithm, and signatureValue.)
+	Raw []byte
+	// RawTBSRevocationList contains just the tbsCertList portion of the ASN.1
+	// DER.
+	RawTBSRevocationList []byte
+	// RawIssuer contains the DER encoded Issuer.
+	RawIssuer []byte
+
+	// Issuer contains the DN of the issuing certificate. It is ignored by
+	// CreateRevocationList, which uses the Subject of the issuer certificate.
+	Issuer pkix.Name
+	// AuthorityKeyId is used to identify the public key associated with the
+	// issuing certificate. It is ignored by CreateRevocationList, which uses
+	// the SubjectKeyId of the issuer certificate.
+	AuthorityKeyId []byte
+
+	Signature []byte
 	// SignatureAlgorithm is used to determine the signature algorithm to be
 	// used when signing the CRL. If 0 the default algorithm for the signing
 	// key will be used.
@@ -2081,6 +2101,11 @@ type RevocationList struct {
 	// which should be a monotonically increasing sequence number for a given
 	// CRL scope and CRL issuer.
 	Number *big.Int
+	// BaseCRLNumber, if not nil, marks the CRL as a delta CRL and is used to
+	// populate the critical deltaCRLIndicator extension, which holds the
+	// cRLNumber of the complete CRL the delta CRL updates. It must be less
+	// than Number. See RFC 5280, Section 5.2.4.
+	BaseCRLNumber *big.Int
 	// ThisUpdate is used to populate the thisUpdate field in the CRL, which
 	// indicates the issuance date of the CRL.
 	ThisUpdate time.Time
@@ -2088,6 +2113,11 @@ type RevocationList struct {
 	// indicates the date by which the next CRL will be issued. NextUpdate
 	// must be greater than ThisUpdate.
 	NextUpdate time.Time
+
+	// Extensions contains raw X.509 extensions. When creating a CRL,
+	// the Extensions field is ignored, see ExtraExtensions.
+	Extensions []pkix.Extension
+
 	// ExtraExtensions contains any additional extensions to add directly to
 	// the CRL.
 	ExtraExtensions []pkix.Extension
@@ -2124,6 +2154,9 @@ func CreateRevocationList(rand io.Reader, template *RevocationList, issuer *Cert
 	if template.Number == nil {
 		return nil, errors.New("x509: template contains nil Number field")
 	}
+	if template.BaseCRLNumber != nil && template.BaseCRLNumber.Cmp(template.Number) >= 0 {
+		return nil, errors.New("x509: template.BaseCRLNumber is not less than template.Number")
+	}
 
 	hashFunc, signatureAlgorithm, err := signingParamsForPublicKey(priv.Public(), template.SignatureAlgorithm)
 	if err != nil {
@@ -2163,6 +2196,17 @@ func CreateRevocationList(rand io.Reader, template *RevocationList, issuer *Cert
 			},
 		},
 	}
+	if template.BaseCRLNumber != nil {
+		baseCRLNum, err := asn1.Marshal(template.BaseCRLNumber)
+		if err != nil {
+			return nil, err
+		}
+		tbsCertList.Extensions = append(tbsCertList.Extensions, pkix.Extension{
+			Id:       oidExtensionDeltaCRLIndicator,
+			Critical: true,
+			Value:    baseCRLNum,
+		})
+	}
 	if len(revokedCertsUTC) > 0 {
 		tbsCertList.RevokedCertificates = revokedCertsUTC
 	}
@@ -2201,3 +2245,22 @@ func CreateRevocationList(rand io.Reader, template *RevocationList, issuer *Cert
 		SignatureValue:     asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
 	})
 }
+
+// CheckSignatureFrom verifies that the signature on rl is a valid signature
+// from issuer.
+func (rl *RevocationList) CheckSignatureFrom(issuer *Certificate) error {
+	if issuer.Version == 3 && !issuer.BasicConstraintsValid ||
+		issuer.BasicConstraintsValid && !issuer.IsCA {
+		return ConstraintViolationError{}
+	}
+
+	if issuer.KeyUsage != 0 && issuer.KeyUsage&KeyUsageCRLSign == 0 {
+		return ConstraintViolationError{}
+	}
+
+	if issuer.PublicKeyAlgorithm == UnknownPublicKeyAlgorithm {
+		return ErrUnsupportedAlgorithm
+	}
+
+	return issuer.CheckSignature(rl.SignatureAlgorithm, rl.RawTBSRevocationList, rl.Signature)
+}
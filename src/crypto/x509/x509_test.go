@@ -2454,6 +2454,24 @@ func TestCreateRevocationList(t *testing.T) {
 			},
 			expectedError: "x509: template contains nil Number field",
 		},
+		{
+			name: "BaseCRLNumber not less than Number",
+			key:  ec256Priv,
+			issuer: &Certificate{
+				KeyUsage: KeyUsageCRLSign,
+				Subject: pkix.Name{
+					CommonName: "testing",
+				},
+				SubjectKeyId: []byte{1, 2, 3},
+			},
+			template: &RevocationList{
+				Number:        big.NewInt(5),
+				BaseCRLNumber: big.NewInt(5),
+				ThisUpdate:    time.Time{}.Add(time.Hour * 24),
+				NextUpdate:    time.Time{}.Add(time.Hour * 48),
+			},
+			expectedError: "x509: template.BaseCRLNumber is not less than template.Number",
+		},
 		{
 			name: "invalid signature algorithm",
 			key:  ec256Priv,
@@ -2661,6 +2679,133 @@ func TestCreateRevocationList(t *testing.T) {
 	}
 }
 
+func TestParseRevocationList(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA P256 key: %s", err)
+	}
+	issuerTemplate := &Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "CRL issuer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              KeyUsageCertSign | KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SubjectKeyId:          []byte{1, 2, 3},
+	}
+	issuerDER, err := CreateCertificate(rand.Reader, issuerTemplate, issuerTemplate, priv.Public(), priv)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %s", err)
+	}
+	issuer, err := ParseCertificate(issuerDER)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %s", err)
+	}
+
+	reasonCode, _ := asn1.Marshal(asn1.Enumerated(1)) // keyCompromise
+	template := &RevocationList{
+		RevokedCertificates: []pkix.RevokedCertificate{
+			{
+				SerialNumber:   big.NewInt(2),
+				RevocationTime: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			{
+				SerialNumber:   big.NewInt(3),
+				RevocationTime: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+				Extensions: []pkix.Extension{
+					{Id: []int{2, 5, 29, 21}, Value: reasonCode},
+				},
+			},
+		},
+		Number:        big.NewInt(12),
+		BaseCRLNumber: big.NewInt(10),
+		ThisUpdate:    time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+		NextUpdate:    time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC),
+		ExtraExtensions: []pkix.Extension{
+			{
+				Id:    []int{2, 5, 29, 99},
+				Value: []byte{5, 0},
+			},
+		},
+	}
+	der, err := CreateRevocationList(rand.Reader, template, issuer, priv)
+	if err != nil {
+		t.Fatalf("CreateRevocationList failed: %s", err)
+	}
+
+	rl, err := ParseRevocationList(der)
+	if err != nil {
+		t.Fatalf("ParseRevocationList failed: %s", err)
+	}
+	if !bytes.Equal(rl.Raw, der) {
+		t.Errorf("Raw doesn't match the parsed CRL")
+	}
+	if !bytes.Equal(rl.RawIssuer, issuer.RawSubject) {
+		t.Errorf("RawIssuer = %x, want %x", rl.RawIssuer, issuer.RawSubject)
+	}
+	if rl.Issuer.CommonName != "CRL issuer" {
+		t.Errorf("Issuer = %v, want CN=CRL issuer", rl.Issuer)
+	}
+	if !bytes.Equal(rl.AuthorityKeyId, issuer.SubjectKeyId) {
+		t.Errorf("AuthorityKeyId = %x, want %x", rl.AuthorityKeyId, issuer.SubjectKeyId)
+	}
+	if rl.SignatureAlgorithm != ECDSAWithSHA256 {
+		t.Errorf("SignatureAlgorithm = %v, want %v", rl.SignatureAlgorithm, ECDSAWithSHA256)
+	}
+	if rl.Number.Cmp(template.Number) != 0 {
+		t.Errorf("Number = %v, want %v", rl.Number, template.Number)
+	}
+	if rl.BaseCRLNumber == nil || rl.BaseCRLNumber.Cmp(template.BaseCRLNumber) != 0 {
+		t.Errorf("BaseCRLNumber = %v, want %v", rl.BaseCRLNumber, template.BaseCRLNumber)
+	}
+	if !rl.ThisUpdate.Equal(template.ThisUpdate) || !rl.NextUpdate.Equal(template.NextUpdate) {
+		t.Errorf("ThisUpdate, NextUpdate = %v, %v, want %v, %v", rl.ThisUpdate, rl.NextUpdate,
+			template.ThisUpdate, template.NextUpdate)
+	}
+	if !reflect.DeepEqual(rl.RevokedCertificates, template.RevokedCertificates) {
+		t.Errorf("RevokedCertificates = %v, want %v", rl.RevokedCertificates, template.RevokedCertificates)
+	}
+	// AuthorityKeyId, CRLNumber, DeltaCRLIndicator, and the extra extension.
+	if len(rl.Extensions) != 4 {
+		t.Fatalf("got %d extensions, want 4", len(rl.Extensions))
+	}
+	if ext := rl.Extensions[2]; !ext.Id.Equal(oidExtensionDeltaCRLIndicator) || !ext.Critical {
+		t.Errorf("third extension = %v, want a critical delta CRL indicator", ext)
+	}
+	if !reflect.DeepEqual(rl.Extensions[3], template.ExtraExtensions[0]) {
+		t.Errorf("last extension = %v, want %v", rl.Extensions[3], template.ExtraExtensions[0])
+	}
+
+	if err := rl.CheckSignatureFrom(issuer); err != nil {
+		t.Errorf("CheckSignatureFrom failed: %s", err)
+	}
+	other, _, err := generateCert("Other CA", true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rl.CheckSignatureFrom(other); err == nil {
+		t.Errorf("CheckSignatureFrom succeeded with the wrong issuer")
+	}
+
+	if _, err := ParseRevocationList(append(der, 0)); err == nil {
+		t.Errorf("ParseRevocationList succeeded with trailing data")
+	}
+}
+
+func TestParseRevocationListLegacy(t *testing.T) {
+	rl, err := ParseRevocationList(fromBase64(derCRLBase64))
+	if err != nil {
+		t.Fatalf("ParseRevocationList failed: %s", err)
+	}
+	if got, want := len(rl.RevokedCertificates), 88; got != want {
+		t.Errorf("bad number of revoked certificates. got: %d want: %d", got, want)
+	}
+	if rl.BaseCRLNumber != nil {
+		t.Errorf("BaseCRLNumber = %v, want nil", rl.BaseCRLNumber)
+	}
+}
+
 func TestRSAPSAParameters(t *testing.T) {
 	generateParams := func(hashFunc crypto.Hash) []byte {
 		var hashOID asn1.ObjectIdentifier
@@ -346,6 +346,12 @@ var depsRules = `
 	sync
 	< internal/singleflight;
 
+	RUNTIME
+	< internal/intern;
+
+	internal/intern, strconv
+	< net/netip;
+
 	os
 	< golang.org/x/net/dns/dnsmessage,
 	  golang.org/x/net/lif,
@@ -363,6 +369,7 @@ var depsRules = `
 	internal/poll,
 	internal/singleflight,
 	internal/race,
+	net/netip,
 	os
 	< net;
 
@@ -378,10 +385,14 @@ var depsRules = `
 
 	# logging - most packages should not import; http and up is allowed
 	FMT
+	< log/internal
 	< log;
 
 	log !< crypto/tls, database/sql, go/importer, testing;
 
+	FMT, context, encoding/json, log, log/internal
+	< log/slog;
+
 	FMT, log, net
 	< log/syslog;
 
@@ -394,9 +405,11 @@ var depsRules = `
 	< crypto
 	< crypto/subtle
 	< crypto/internal/subtle
-	< crypto/elliptic/internal/fiat
-	< crypto/ed25519/internal/edwards25519/field
-	< crypto/ed25519/internal/edwards25519
+	< crypto/internal/nistec/fiat
+	< crypto/internal/nistec
+	< crypto/internal/edwards25519/field
+	< crypto/internal/edwards25519
+	< crypto/internal/sha3
 	< crypto/cipher
 	< crypto/aes, crypto/des, crypto/hmac, crypto/md5, crypto/rc4,
 	  crypto/sha1, crypto/sha256, crypto/sha512
@@ -408,6 +421,8 @@ var depsRules = `
 	CRYPTO, FMT, math/big
 	< crypto/rand
 	< crypto/internal/randutil
+	< crypto/internal/mlkem768
+	< crypto/ecdh
 	< crypto/ed25519
 	< encoding/asn1
 	< golang.org/x/crypto/cryptobyte/asn1
@@ -426,6 +441,7 @@ var depsRules = `
 	< golang.org/x/crypto/poly1305
 	< golang.org/x/crypto/chacha20poly1305
 	< golang.org/x/crypto/hkdf
+	< crypto/internal/hpke
 	< crypto/x509/internal/macos
 	< crypto/x509/pkix
 	< crypto/x509
@@ -433,12 +449,18 @@ var depsRules = `
 
 	# crypto-aware packages
 
+	crypto/x509
+	< crypto/x509/ocsp;
+
 	NET, crypto/rand, mime/quotedprintable
 	< mime/multipart;
 
 	crypto/tls
 	< net/smtp;
 
+	crypto/tls
+	< internal/quic;
+
 	# HTTP, King of Dependencies.
 
 	FMT
@@ -464,6 +486,7 @@ var depsRules = `
 	net/http/internal/ascii,
 	net/http/internal/testcert,
 	net/http/httptrace,
+	internal/quic,
 	mime/multipart,
 	log
 	< net/http;
@@ -474,7 +497,10 @@ var depsRules = `
 	< expvar;
 
 	net/http, net/http/internal/ascii
-	< net/http/cookiejar, net/http/httputil;
+	< net/http/httputil;
+
+	encoding/json, net/http, net/http/internal/ascii
+	< net/http/cookiejar;
 
 	net/http, flag
 	< net/http/httptest;
@@ -493,6 +519,11 @@ var depsRules = `
 	html, internal/profile, net/http, runtime/pprof, runtime/trace
 	< net/http/pprof;
 
+	# Coverage
+	FMT
+	< internal/coverage
+	< internal/coverage/cfile;
+
 	# RPC
 	encoding/gob, encoding/json, go/token, html/template, net/http
 	< net/rpc
@@ -513,7 +544,10 @@ var depsRules = `
 	FMT, flag, runtime/debug, runtime/trace, internal/sysinfo, math/rand
 	< testing;
 
-	internal/testlog, runtime/pprof, regexp
+	FMT, crypto/sha256, encoding/json, go/ast, go/parser, go/token
+	< internal/fuzz;
+
+	internal/fuzz, internal/testlog, runtime/pprof, regexp
 	< testing/internal/testdeps;
 
 	OS, flag, testing, internal/cfg
@@ -0,0 +1,98 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cfile collects the coverage counters of a program built with
+// "go build -cover" and writes them to a data file when the program
+// exits.
+//
+// The go command adds a call to RegisterFile for each instrumented
+// source file to the instrumented package's initialization. When the
+// program exits, either by returning from main.main or by calling
+// os.Exit, the counters are written to a new data file in the
+// directory named by the GOCOVERDIR environment variable. If
+// GOCOVERDIR is not set, a warning is printed instead.
+package cfile
+
+import (
+	"fmt"
+	"internal/coverage"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A file holds the coverage counters of one instrumented source file,
+// as laid out by cmd/cover.
+type file struct {
+	name     string
+	counts   []uint32
+	pos      []uint32 // start line, end line, packed columns for each block
+	numStmts []uint16
+}
+
+var (
+	mu    sync.Mutex
+	mode  string
+	files []file
+
+	hookOnce sync.Once
+)
+
+// runtime_addExitHook is implemented in the runtime.
+func runtime_addExitHook(f func(), runOnNonZeroExit bool)
+
+// RegisterFile registers the coverage counters for the source file
+// name, instrumented by cmd/cover in the given mode.
+func RegisterFile(covMode, name string, counts, pos []uint32, numStmts []uint16) {
+	mu.Lock()
+	mode = covMode
+	files = append(files, file{name: name, counts: counts, pos: pos, numStmts: numStmts})
+	mu.Unlock()
+
+	hookOnce.Do(func() {
+		runtime_addExitHook(emit, true)
+	})
+}
+
+// emit writes the coverage data at program exit.
+func emit() {
+	dir := os.Getenv("GOCOVERDIR")
+	if dir == "" {
+		fmt.Fprintf(os.Stderr, "warning: GOCOVERDIR not set, no coverage data emitted\n")
+		return
+	}
+	if err := writeDir(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "error: coverage data emit failed: %v\n", err)
+	}
+}
+
+// writeDir writes the current values of the registered coverage
+// counters to a new data file in dir.
+func writeDir(dir string) error {
+	suffix := fmt.Sprintf("%d.%d", os.Getpid(), time.Now().UnixNano())
+	return coverage.WriteFile(dir, suffix, snapshot())
+}
+
+// snapshot returns a profile holding the current values of the
+// registered coverage counters.
+func snapshot() *coverage.Profile {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := coverage.NewProfile(mode)
+	for _, f := range files {
+		for i := range f.counts {
+			id := coverage.BlockID{
+				File:      f.name,
+				StartLine: f.pos[3*i+0],
+				StartCol:  f.pos[3*i+2] & 0xFFFF,
+				EndLine:   f.pos[3*i+1],
+				EndCol:    f.pos[3*i+2] >> 16 & 0xFFFF,
+			}
+			p.Add(id, uint32(f.numStmts[i]), atomic.LoadUint32(&f.counts[i]))
+		}
+	}
+	return p
+}
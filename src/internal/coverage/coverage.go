@@ -0,0 +1,317 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package coverage implements the coverage data files written by
+// programs built with "go build -cover".
+//
+// Each run of an instrumented program writes one data file to the
+// directory named by the GOCOVERDIR environment variable. A data file
+// uses the same text format as the profiles written by "go test
+// -coverprofile": a mode line followed by one line per basic block,
+//
+//	mode: set
+//	example.com/pkg/file.go:10.2,12.16 2 1
+//
+// giving the file, the start and end positions of the block, the
+// number of statements in the block, and the block's execution count.
+// Data file names begin with CounterFilePrefix.
+package coverage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CounterFilePrefix is the prefix of the names of coverage data files.
+const CounterFilePrefix = "covcounters."
+
+// A BlockID identifies a basic block in a source file.
+type BlockID struct {
+	File      string
+	StartLine uint32
+	StartCol  uint32
+	EndLine   uint32
+	EndCol    uint32
+}
+
+// A Block holds the coverage data for a basic block.
+type Block struct {
+	NumStmt uint32
+	Count   uint32
+}
+
+// A Profile holds the coverage data from one or more program runs.
+type Profile struct {
+	Mode   string // "set", "count", or "atomic"
+	Blocks map[BlockID]Block
+}
+
+// NewProfile returns an empty profile with the given mode.
+func NewProfile(mode string) *Profile {
+	return &Profile{Mode: mode, Blocks: make(map[BlockID]Block)}
+}
+
+// Add adds count executions of the block id, which has numStmt
+// statements, to p.
+func (p *Profile) Add(id BlockID, numStmt, count uint32) {
+	b := p.Blocks[id]
+	b.NumStmt = numStmt
+	if p.Mode == "set" {
+		if count != 0 {
+			b.Count = 1
+		}
+	} else if b.Count+count < b.Count {
+		b.Count = ^uint32(0) // saturate
+	} else {
+		b.Count += count
+	}
+	p.Blocks[id] = b
+}
+
+// Merge adds the coverage data in q to p.
+// The profiles must have the same mode.
+func (p *Profile) Merge(q *Profile) error {
+	if p.Mode != q.Mode {
+		return fmt.Errorf("cannot merge profiles with modes %q and %q", p.Mode, q.Mode)
+	}
+	for id, b := range q.Blocks {
+		p.Add(id, b.NumStmt, b.Count)
+	}
+	return nil
+}
+
+// Subtract clears the counts of the blocks in p that were executed
+// according to q.
+func (p *Profile) Subtract(q *Profile) {
+	for id, b := range p.Blocks {
+		if qb, ok := q.Blocks[id]; ok && qb.Count != 0 {
+			b.Count = 0
+			p.Blocks[id] = b
+		}
+	}
+}
+
+// Intersect clears the counts of the blocks in p that were not
+// executed according to q.
+func (p *Profile) Intersect(q *Profile) {
+	for id, b := range p.Blocks {
+		if qb, ok := q.Blocks[id]; !ok || qb.Count == 0 {
+			b.Count = 0
+			p.Blocks[id] = b
+		}
+	}
+}
+
+// Packages returns the sorted import paths of the packages with
+// blocks in p.
+func (p *Profile) Packages() []string {
+	seen := make(map[string]bool)
+	var pkgs []string
+	for id := range p.Blocks {
+		pkg := path.Dir(id.File)
+		if !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// Percent returns the percentage of the statements in package pkg
+// that were executed, or of all statements in p if pkg is empty.
+// It returns 0 if there are no such statements.
+func (p *Profile) Percent(pkg string) float64 {
+	var total, covered uint64
+	for id, b := range p.Blocks {
+		if pkg != "" && path.Dir(id.File) != pkg {
+			continue
+		}
+		total += uint64(b.NumStmt)
+		if b.Count != 0 {
+			covered += uint64(b.NumStmt)
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+// Write writes p to w in the text profile format.
+// The blocks are sorted by file and position.
+func (p *Profile) Write(w io.Writer) error {
+	ids := make([]BlockID, 0, len(p.Blocks))
+	for id := range p.Blocks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := ids[i], ids[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.StartLine != b.StartLine {
+			return a.StartLine < b.StartLine
+		}
+		if a.StartCol != b.StartCol {
+			return a.StartCol < b.StartCol
+		}
+		if a.EndLine != b.EndLine {
+			return a.EndLine < b.EndLine
+		}
+		return a.EndCol < b.EndCol
+	})
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "mode: %s\n", p.Mode)
+	for _, id := range ids {
+		b := p.Blocks[id]
+		fmt.Fprintf(bw, "%s:%d.%d,%d.%d %d %d\n", id.File, id.StartLine, id.StartCol, id.EndLine, id.EndCol, b.NumStmt, b.Count)
+	}
+	return bw.Flush()
+}
+
+// Parse parses a profile in the text profile format.
+func Parse(r io.Reader) (*Profile, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	var p *Profile
+	line := 0
+	for s.Scan() {
+		line++
+		text := s.Text()
+		if p == nil {
+			mode := strings.TrimPrefix(text, "mode: ")
+			if mode == text {
+				return nil, fmt.Errorf("line %d: missing mode line", line)
+			}
+			switch mode {
+			case "set", "count", "atomic":
+			default:
+				return nil, fmt.Errorf("line %d: invalid mode %q", line, mode)
+			}
+			p = NewProfile(mode)
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		id, numStmt, count, err := parseLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		p.Add(id, numStmt, count)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, errors.New("empty profile")
+	}
+	return p, nil
+}
+
+var errBadLine = errors.New("malformed block")
+
+// parseLine parses a block line of the form
+//
+//	file:startLine.startCol,endLine.endCol numStmt count
+func parseLine(text string) (id BlockID, numStmt, count uint32, err error) {
+	i := strings.LastIndexByte(text, ':')
+	if i < 0 {
+		return id, 0, 0, errBadLine
+	}
+	id.File = text[:i]
+	f := strings.Fields(text[i+1:])
+	if len(f) != 3 {
+		return id, 0, 0, errBadLine
+	}
+	var n [6]uint64
+	pos := strings.FieldsFunc(f[0], func(r rune) bool { return r == '.' || r == ',' })
+	if len(pos) != 4 {
+		return id, 0, 0, errBadLine
+	}
+	for j, s := range append(pos, f[1], f[2]) {
+		n[j], err = strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return id, 0, 0, errBadLine
+		}
+	}
+	id.StartLine, id.StartCol, id.EndLine, id.EndCol = uint32(n[0]), uint32(n[1]), uint32(n[2]), uint32(n[3])
+	return id, uint32(n[4]), uint32(n[5]), nil
+}
+
+// ReadDir reads and merges all the coverage data files in dir.
+// It returns an error if dir contains no data files.
+func ReadDir(dir string) (*Profile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var p *Profile
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), CounterFilePrefix) {
+			continue
+		}
+		q, err := ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			p = q
+		} else if err := p.Merge(q); err != nil {
+			return nil, fmt.Errorf("%s: %v", filepath.Join(dir, e.Name()), err)
+		}
+	}
+	if p == nil {
+		return nil, fmt.Errorf("no coverage data files found in %s", dir)
+	}
+	return p, nil
+}
+
+// ReadFile reads the coverage data file or profile named by file.
+func ReadFile(file string) (*Profile, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return p, nil
+}
+
+// WriteFile writes p to a new data file in dir, giving it a name that
+// begins with CounterFilePrefix and ends with suffix. The file is
+// written under a temporary name and then renamed, so readers never
+// see a partially written data file.
+func WriteFile(dir, suffix string, p *Profile) (err error) {
+	f, err := os.CreateTemp(dir, ".tmp.covcounters.")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if err := p.Write(f); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(dir, CounterFilePrefix+suffix))
+}
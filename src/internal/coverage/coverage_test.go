@@ -0,0 +1,149 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coverage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const prof1 = `mode: count
+example.com/p/a.go:1.10,3.2 2 5
+example.com/p/a.go:3.2,4.3 1 0
+example.com/q/b.go:2.1,2.20 1 1
+`
+
+const prof2 = `mode: count
+example.com/p/a.go:1.10,3.2 2 1
+example.com/p/a.go:3.2,4.3 1 2
+`
+
+func mustParse(t *testing.T, s string) *Profile {
+	t.Helper()
+	p, err := Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func format(t *testing.T, p *Profile) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestRoundTrip(t *testing.T) {
+	if got := format(t, mustParse(t, prof1)); got != prof1 {
+		t.Errorf("round trip:\n%s\nwant:\n%s", got, prof1)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	p := mustParse(t, prof1)
+	if err := p.Merge(mustParse(t, prof2)); err != nil {
+		t.Fatal(err)
+	}
+	want := `mode: count
+example.com/p/a.go:1.10,3.2 2 6
+example.com/p/a.go:3.2,4.3 1 2
+example.com/q/b.go:2.1,2.20 1 1
+`
+	if got := format(t, p); got != want {
+		t.Errorf("merge:\n%s\nwant:\n%s", got, want)
+	}
+
+	if err := p.Merge(NewProfile("set")); err == nil {
+		t.Errorf("merging set profile into count profile succeeded")
+	}
+}
+
+func TestSubtractIntersect(t *testing.T) {
+	p := mustParse(t, prof1)
+	p.Subtract(mustParse(t, prof2))
+	want := `mode: count
+example.com/p/a.go:1.10,3.2 2 0
+example.com/p/a.go:3.2,4.3 1 0
+example.com/q/b.go:2.1,2.20 1 1
+`
+	if got := format(t, p); got != want {
+		t.Errorf("subtract:\n%s\nwant:\n%s", got, want)
+	}
+
+	p = mustParse(t, prof1)
+	p.Intersect(mustParse(t, prof2))
+	want = `mode: count
+example.com/p/a.go:1.10,3.2 2 5
+example.com/p/a.go:3.2,4.3 1 0
+example.com/q/b.go:2.1,2.20 1 0
+`
+	if got := format(t, p); got != want {
+		t.Errorf("intersect:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	p := mustParse(t, prof1)
+	for _, tt := range []struct {
+		pkg  string
+		want float64
+	}{
+		{"", 75},
+		{"example.com/p", 200.0 / 3},
+		{"example.com/q", 100},
+		{"example.com/r", 0},
+	} {
+		if got := p.Percent(tt.pkg); got != tt.want {
+			t.Errorf("Percent(%q) = %v, want %v", tt.pkg, got, tt.want)
+		}
+	}
+	if got, want := strings.Join(p.Packages(), " "), "example.com/p example.com/q"; got != want {
+		t.Errorf("Packages() = %q, want %q", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"example.com/p/a.go:1.10,3.2 2 5\n",
+		"mode: bogus\n",
+		"mode: set\nexample.com/p/a.go:1.10 2 5\n",
+		"mode: set\nexample.com/p/a.go:1.10,3.2 2\n",
+		"mode: set\nexample.com/p/a.go:1.10,3.x 2 5\n",
+	} {
+		if _, err := Parse(strings.NewReader(s)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestReadWriteDir(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := ReadDir(dir); err == nil {
+		t.Errorf("ReadDir of empty directory succeeded")
+	}
+	if err := WriteFile(dir, "1", mustParse(t, prof1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(dir, "2", mustParse(t, prof2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "unrelated"), []byte("junk"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	p, err := ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Blocks[BlockID{"example.com/p/a.go", 1, 10, 3, 2}].Count; got != 6 {
+		t.Errorf("merged count = %d, want 6", got)
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// TODO: expand the set of supported platforms, with testing. Nontrivial
+// platform-specific work is needed to enable coverage on other platforms.
+
+//go:build !((darwin || linux || windows || freebsd) && (amd64 || arm64))
+// +build !darwin,!linux,!windows,!freebsd !amd64,!arm64
+
+package fuzz
+
+// coverage on unsupported platforms returns an empty slice. Without coverage
+// data, the fuzzer falls back to purely random mutation.
+func coverage() []byte {
+	return []byte{}
+}
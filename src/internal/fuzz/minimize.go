@@ -0,0 +1,98 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"reflect"
+)
+
+// isMinimizable reports whether a value of type t can be made smaller by
+// minimizeBytes.
+func isMinimizable(t reflect.Type) bool {
+	return t == reflect.TypeOf("") || t == reflect.TypeOf([]byte(nil))
+}
+
+// minimizeBytes attempts to find a shorter input than v for which try still
+// reports true, and returns the smallest such input found. try may be called
+// with a candidate that shares memory with a scratch buffer, so it must copy
+// the candidate if it needs to retain it. shouldStop is polled between
+// attempts; once it reports true, minimizeBytes returns the best value found
+// so far.
+func minimizeBytes(v []byte, try func([]byte) bool, shouldStop func() bool) []byte {
+	v = append([]byte(nil), v...)
+	tmp := make([]byte, len(v))
+
+	// First, try to cut the tail.
+	for n := 1024; n != 0; n /= 2 {
+		for len(v) > n {
+			if shouldStop() {
+				return v
+			}
+			candidate := v[:len(v)-n]
+			if !try(candidate) {
+				break
+			}
+			v = candidate
+		}
+	}
+
+	// Then, try to remove each individual byte.
+	for i := 0; i < len(v)-1; i++ {
+		if shouldStop() {
+			return v
+		}
+		candidate := tmp[:len(v)-1]
+		copy(candidate[:i], v[:i])
+		copy(candidate[i:], v[i+1:])
+		if !try(candidate) {
+			continue
+		}
+		// Update v to delete the value at index i, then redo this
+		// iteration of the loop with the new value at v[i].
+		copy(v[i:], v[i+1:])
+		v = v[:len(candidate)]
+		i--
+	}
+
+	// Then, try to remove each possible contiguous range of bytes.
+	for i := 0; i < len(v)-1; i++ {
+		copy(tmp, v[:i])
+		for j := len(v); j > i+1; j-- {
+			if shouldStop() {
+				return v
+			}
+			candidate := tmp[:len(v)-j+i]
+			copy(candidate[i:], v[j:])
+			if !try(candidate) {
+				continue
+			}
+			// Update v and restart the inner loop with the new length.
+			copy(v[i:], v[j:])
+			v = v[:len(candidate)]
+			j = len(v)
+		}
+	}
+
+	// Finally, try to make the input more readable by replacing each byte
+	// with a printable character.
+	const printableChars = "012789ABCXYZabcxyz !\"#$%&'()*+,."
+	for i, b := range v {
+		if shouldStop() {
+			return v
+		}
+		for j := 0; j < len(printableChars); j++ {
+			pc := printableChars[j]
+			if pc == b {
+				break
+			}
+			v[i] = pc
+			if try(v) {
+				break
+			}
+			v[i] = b
+		}
+	}
+	return v
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMinimizeBytes(t *testing.T) {
+	never := func() bool { return false }
+	for _, tc := range []struct {
+		name string
+		in   []byte
+		try  func([]byte) bool
+		want []byte
+	}{
+		{
+			name: "ones",
+			in:   []byte{0, 0, 1, 0, 1, 1, 0, 1},
+			try: func(b []byte) bool {
+				return bytes.Count(b, []byte{1}) >= 3
+			},
+			want: []byte{1, 1, 1},
+		},
+		{
+			name: "single_byte",
+			in:   []byte{0, 0, 0, 9, 0, 0},
+			try: func(b []byte) bool {
+				return bytes.IndexByte(b, 9) >= 0
+			},
+			want: []byte{9},
+		},
+		{
+			name: "prefix",
+			in:   []byte("0123456789abcdefghijklmnopqrstuvwxyz"),
+			try: func(b []byte) bool {
+				return bytes.HasPrefix(b, []byte("01"))
+			},
+			want: []byte("01"),
+		},
+		{
+			name: "printable",
+			in:   []byte{0xff, 0xfe},
+			try: func(b []byte) bool {
+				return len(b) == 2
+			},
+			want: []byte("00"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := minimizeBytes(tc.in, tc.try, never)
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("minimizeBytes(%q) = %q; want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMinimizeBytesShouldStop(t *testing.T) {
+	in := []byte("abcdef")
+	calls := 0
+	got := minimizeBytes(in, func(b []byte) bool {
+		calls++
+		return true
+	}, func() bool { return true })
+	if calls != 0 {
+		t.Errorf("try called %d times after shouldStop reported true", calls)
+	}
+	if !bytes.Equal(got, in) {
+		t.Errorf("minimizeBytes returned %q; want unchanged input %q", got, in)
+	}
+}
+
+func TestIsMinimizable(t *testing.T) {
+	for _, tc := range []struct {
+		v    interface{}
+		want bool
+	}{
+		{[]byte(nil), true},
+		{"", true},
+		{int(0), false},
+		{float64(0), false},
+		{false, false},
+	} {
+		if got := isMinimizable(reflect.TypeOf(tc.v)); got != tc.want {
+			t.Errorf("isMinimizable(%T) = %v; want %v", tc.v, got, tc.want)
+		}
+	}
+}
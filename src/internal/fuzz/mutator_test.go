@@ -0,0 +1,89 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func BenchmarkMutatorBytes(b *testing.B) {
+	b.Setenv("GODEBUG", "fuzzseed=123")
+	m := newMutator()
+
+	for _, size := range []int{
+		1,
+		10,
+		100,
+		1000,
+		10000,
+		100000,
+	} {
+		size := size
+		b.Run(fmt.Sprintf("%d", size), func(b *testing.B) {
+			buf := make([]byte, size)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				// resize buffer to the correct shape and reset the PCG
+				buf = buf[0:size]
+				m.r = newPcgRand()
+				m.mutate([]interface{}{buf}, workerSharedMemSize)
+			}
+		})
+	}
+}
+
+func TestMutateDeterministic(t *testing.T) {
+	// The coordinator reconstructs crashing inputs by replaying the mutations
+	// performed by a worker, so mutations must depend only on the input and
+	// the state of the random number generator.
+	orig := []interface{}{[]byte("abcdef"), "ghi", int(5), []byte(""), float64(1.5)}
+	const maxBytes = 1 << 10
+
+	run := func(m *mutator, state, inc uint64) []interface{} {
+		m.r.restore(state, inc)
+		vals := make([]interface{}, len(orig))
+		copy(vals, orig)
+		for i := 0; i < chainedMutations; i++ {
+			m.mutate(vals, maxBytes)
+		}
+		return vals
+	}
+
+	m1 := newMutator()
+	var state, inc uint64
+	m1.r.save(&state, &inc)
+	for i := 0; i < 100; i++ {
+		m1.r.save(&state, &inc)
+		want := run(m1, state, inc)
+		m2 := newMutator()
+		// Give m2 a scratch buffer with a different capacity. The result must
+		// not depend on it.
+		m2.scratch = make([]byte, 0, 2*maxBytes)
+		got := run(m2, state, inc)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("replayed mutation produced %#v; want %#v", got, want)
+		}
+	}
+}
+
+func TestMutateBytesNoAlias(t *testing.T) {
+	// Mutating one []byte value must not change another.
+	m := newMutator()
+	a, b := []byte("aaaaaaaa"), []byte("bbbbbbbb")
+	vals := []interface{}{a, b}
+	for i := 0; i < 100; i++ {
+		m.mutate(vals, 1<<10)
+		if bytes.Equal(vals[0].([]byte), vals[1].([]byte)) && len(vals[0].([]byte)) > 0 {
+			t.Fatalf("after %d mutations, values alias each other: %q", i+1, vals[0])
+		}
+	}
+	if string(a) != "aaaaaaaa" || string(b) != "bbbbbbbb" {
+		t.Fatalf("mutate modified the original values: %q, %q", a, b)
+	}
+}
@@ -0,0 +1,639 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// A Conn is a QUIC connection.
+//
+// Each connection runs a goroutine which processes received
+// datagrams, sends packets, and handles timer events.
+// Stream operations and the exported methods of Conn
+// synchronize with it through mu.
+type Conn struct {
+	endpoint *Endpoint
+	config   *Config
+	client   bool
+	peerAddr net.Addr
+
+	msgc           chan []byte   // received datagrams
+	wakec          chan struct{} // wakes the connection goroutine
+	donec          chan struct{} // closed when the connection goroutine exits
+	handshakeDonec chan struct{} // closed when the handshake completes
+
+	mu sync.Mutex
+
+	// err is the error returned by operations on a closed connection.
+	err   error
+	state connState
+
+	tls *tls.QUICConn
+
+	localConnID   []byte // our connection ID
+	peerConnID    []byte // the peer's connection ID
+	origDstConnID []byte // the destination connection ID of the client's first Initial packet
+	peerConnIDSet bool   // the client has switched to the server's chosen connection ID
+
+	spaces [numberSpaceCount]spaceState
+	rec    recovery
+
+	localParams transportParameters
+	peerParams  transportParameters
+
+	handshakeComplete    bool
+	handshakeConfirmed   bool
+	handshakeDonePending bool // a HANDSHAKE_DONE frame must be sent
+	addrValidated        bool // the peer's address has been validated (RFC 9000, Section 8)
+	bytesRecv, bytesSent int64
+
+	// Streams.
+	streams               map[int64]*Stream
+	nextLocalStream       [2]int64 // indexed by streamIndex
+	localStreamLimit      [2]int64 // MAX_STREAMS from the peer
+	remoteStreamOpened    [2]int64 // number of streams opened by the peer
+	remoteStreamLimit     [2]int64 // MAX_STREAMS sent to the peer
+	remoteStreamLimitSent [2]bool  // remoteStreamLimit has been sent
+	acceptq               []*Stream
+	streamsChanged        chan struct{} // closed when acceptq or localStreamLimit changes
+
+	// Connection-level flow control.
+	inMax        int64 // MAX_DATA sent to the peer
+	inWin        int64
+	inReceived   int64 // sum of the highest offsets received on all streams
+	inConsumed   int64 // data read (or discarded) by the application
+	inMaxPending bool  // a MAX_DATA frame must be sent
+	outMax       int64 // MAX_DATA from the peer
+	outSent      int64 // new data sent on all streams
+
+	pathResponse []byte // data for a pending PATH_RESPONSE frame
+	pingPending  bool   // a keep-alive PING must be sent
+
+	// Closing.
+	closeFrame    closeFrame
+	closePending  bool // a CONNECTION_CLOSE frame must be sent
+	closeDeadline time.Time
+
+	idleTimeout       time.Duration
+	idleDeadline      time.Time
+	handshakeDeadline time.Time
+	lastActivity      time.Time // last time an ack-eliciting packet was sent
+
+	// undecryptable holds datagrams received before the keys to
+	// decrypt them, to be retried when new keys become available.
+	undecryptable [][]byte
+	newKeys       bool // read keys were installed while processing a datagram
+}
+
+type connState int
+
+const (
+	stateOpen     connState = iota
+	stateClosing            // we have sent CONNECTION_CLOSE
+	stateDraining           // the peer has sent CONNECTION_CLOSE
+	stateDone
+)
+
+// A closeFrame describes the CONNECTION_CLOSE frame sent when closing.
+type closeFrame struct {
+	app    bool
+	code   uint64
+	reason string
+}
+
+// maxUndecryptable is the maximum number of undecryptable
+// datagrams buffered while waiting for keys.
+const maxUndecryptable = 16
+
+// A spaceState holds the state of a packet number space.
+type spaceState struct {
+	rkeys, wkeys keys
+	discarded    bool
+
+	// Receiving.
+	recv            rangeset // received packet numbers
+	largestRecv     int64
+	largestRecvTime time.Time
+	ackPending      bool // an ack-eliciting packet has been received and not acknowledged
+
+	// Sending.
+	nextNum              int64
+	sent                 []*sentPacket // unacknowledged packets in order of number
+	largestAcked         int64
+	lossTime             time.Time
+	lastAckElicitingSent time.Time
+	probePending         bool // a PTO probe must be sent
+
+	// The crypto stream.
+	cryptoSend sendBuffer
+	cryptoRecv recvBuffer
+}
+
+func newConnID() []byte {
+	id := make([]byte, connIDLen)
+	if _, err := rand.Read(id); err != nil {
+		panic("quic: rand.Read failed: " + err.Error())
+	}
+	return id
+}
+
+// newConn creates a connection. For server connections, dstConnID and
+// srcConnID are taken from the client's first Initial packet.
+func newConn(e *Endpoint, client bool, config *Config, peerAddr net.Addr, dstConnID, srcConnID []byte) (*Conn, error) {
+	if config == nil || config.TLSConfig == nil {
+		return nil, errors.New("quic: Config.TLSConfig must be set")
+	}
+	now := time.Now()
+	c := &Conn{
+		endpoint:       e,
+		config:         config,
+		client:         client,
+		peerAddr:       peerAddr,
+		msgc:           make(chan []byte, 256),
+		wakec:          make(chan struct{}, 1),
+		donec:          make(chan struct{}),
+		handshakeDonec: make(chan struct{}),
+		localConnID:    newConnID(),
+		streams:        make(map[int64]*Stream),
+		streamsChanged: make(chan struct{}),
+		peerParams:     defaultTransportParameters(),
+	}
+	if client {
+		c.origDstConnID = newConnID()
+		c.peerConnID = c.origDstConnID
+	} else {
+		c.origDstConnID = append([]byte{}, dstConnID...)
+		c.peerConnID = append([]byte{}, srcConnID...)
+		c.peerConnIDSet = true
+	}
+	for i := range c.spaces {
+		c.spaces[i].largestRecv = -1
+		c.spaces[i].largestAcked = -1
+		c.spaces[i].cryptoRecv.finalSize = -1
+	}
+	c.spaces[initialSpace].rkeys, c.spaces[initialSpace].wkeys = initialKeys(c.origDstConnID, client)
+	c.rec.init()
+
+	c.remoteStreamLimit[streamIndex(bidiStream)] = config.maxBidiRemoteStreams()
+	c.remoteStreamLimit[streamIndex(uniStream)] = config.maxUniRemoteStreams()
+	c.remoteStreamLimitSent = [2]bool{true, true}
+	c.inWin = config.maxConnReadBufferSize()
+	c.inMax = c.inWin
+	c.idleTimeout = config.maxIdleTimeout()
+	c.handshakeDeadline = now.Add(config.handshakeTimeout())
+	c.resetIdleTimer(now)
+
+	c.localParams = defaultTransportParameters()
+	c.localParams.maxIdleTimeout = c.idleTimeout
+	c.localParams.maxUDPPayloadSize = maxUDPPayloadSize
+	c.localParams.initialMaxData = c.inMax
+	c.localParams.initialMaxStreamDataBidiLocal = config.maxStreamReadBufferSize()
+	c.localParams.initialMaxStreamDataBidiRemote = config.maxStreamReadBufferSize()
+	c.localParams.initialMaxStreamDataUni = config.maxStreamReadBufferSize()
+	c.localParams.initialMaxStreamsBidi = c.remoteStreamLimit[streamIndex(bidiStream)]
+	c.localParams.initialMaxStreamsUni = c.remoteStreamLimit[streamIndex(uniStream)]
+	c.localParams.disableActiveMigration = true
+	c.localParams.initialSrcConnID = c.localConnID
+	if !client {
+		c.localParams.originalDstConnID = c.origDstConnID
+	}
+
+	tlsConfig := config.TLSConfig.Clone()
+	if tlsConfig.MinVersion < tls.VersionTLS13 {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+	qconfig := &tls.QUICConfig{TLSConfig: tlsConfig}
+	if client {
+		c.tls = tls.QUICClient(qconfig)
+	} else {
+		c.tls = tls.QUICServer(qconfig)
+	}
+	c.tls.SetTransportParameters(c.localParams.marshal())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.tls.Start(context.Background()); err != nil {
+		return nil, err
+	}
+	if err := c.handleTLSEvents(now); err != nil {
+		c.tls.Close()
+		return nil, err
+	}
+	if err := e.addConn(c); err != nil {
+		c.tls.Close()
+		return nil, err
+	}
+	go c.loop()
+	return c, nil
+}
+
+func (c *Conn) isServer() bool {
+	return !c.client
+}
+
+// streamIndex returns the index of a stream type in per-type arrays.
+func streamIndex(typ streamType) int {
+	if typ == uniStream {
+		return 1
+	}
+	return 0
+}
+
+// LocalAddr returns the local network address.
+func (c *Conn) LocalAddr() net.Addr {
+	return c.endpoint.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.peerAddr
+}
+
+// ConnectionState returns basic TLS details about the connection.
+func (c *Conn) ConnectionState() tls.ConnectionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tls.ConnectionState()
+}
+
+// NewStream creates a bidirectional stream.
+// It blocks until the peer's stream limit permits a new stream.
+func (c *Conn) NewStream(ctx context.Context) (*Stream, error) {
+	return c.newLocalStream(ctx, bidiStream)
+}
+
+// NewSendOnlyStream creates a unidirectional, send-only stream.
+// It blocks until the peer's stream limit permits a new stream.
+func (c *Conn) NewSendOnlyStream(ctx context.Context) (*Stream, error) {
+	return c.newLocalStream(ctx, uniStream)
+}
+
+func (c *Conn) newLocalStream(ctx context.Context, typ streamType) (*Stream, error) {
+	i := streamIndex(typ)
+	for {
+		c.mu.Lock()
+		if c.err != nil {
+			err := c.err
+			c.mu.Unlock()
+			return nil, err
+		}
+		if c.nextLocalStream[i] < c.localStreamLimit[i] {
+			id := c.nextLocalStream[i]<<2 | int64(typ)
+			if c.isServer() {
+				id |= streamIDServerBit
+			}
+			c.nextLocalStream[i]++
+			s := newStream(c, id)
+			c.streams[id] = s
+			c.mu.Unlock()
+			return s, nil
+		}
+		ch := c.streamsChanged
+		c.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.donec:
+		}
+	}
+}
+
+// AcceptStream waits for and returns the next stream created by the peer.
+func (c *Conn) AcceptStream(ctx context.Context) (*Stream, error) {
+	for {
+		c.mu.Lock()
+		if len(c.acceptq) > 0 {
+			s := c.acceptq[0]
+			c.acceptq[0] = nil
+			c.acceptq = c.acceptq[1:]
+			c.mu.Unlock()
+			return s, nil
+		}
+		if c.err != nil {
+			err := c.err
+			c.mu.Unlock()
+			return nil, err
+		}
+		ch := c.streamsChanged
+		c.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.donec:
+		}
+	}
+}
+
+// waitHandshake waits for the handshake to complete.
+func (c *Conn) waitHandshake(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		if c.handshakeComplete {
+			c.mu.Unlock()
+			return nil
+		}
+		err := c.err
+		ch := c.streamsChanged
+		c.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ch:
+		case <-c.handshakeDonec:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Abort closes the connection with an application error code and reason.
+// It does not wait for the peer to acknowledge the closure.
+func (c *Conn) Abort(code uint64, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeLocked(time.Now(), errConnClosed, closeFrame{app: true, code: code, reason: reason})
+}
+
+// Close closes the connection with application error code 0.
+func (c *Conn) Close() error {
+	c.Abort(0, "")
+	return nil
+}
+
+// Done returns a channel that is closed when the connection
+// has been closed and its resources released.
+func (c *Conn) Done() <-chan struct{} {
+	return c.donec
+}
+
+// Err returns the error that caused the connection to close,
+// or nil if it is open.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// wake wakes the connection goroutine, which sends any pending data.
+func (c *Conn) wake() {
+	signal(c.wakec)
+}
+
+// deliver passes a received datagram to the connection.
+// Datagrams are dropped if the connection is not keeping up.
+func (c *Conn) deliver(b []byte) {
+	select {
+	case c.msgc <- b:
+	default:
+	}
+}
+
+// loop is the connection goroutine.
+func (c *Conn) loop() {
+	defer c.exit()
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		now := time.Now()
+		c.mu.Lock()
+		c.handleTimers(now)
+		dgrams := c.appendDatagrams(now)
+		next := c.nextDeadline()
+		done := c.state == stateDone ||
+			c.state == stateClosing && !c.closePending && c.endpoint.isClosed()
+		c.mu.Unlock()
+		for _, d := range dgrams {
+			c.endpoint.writeTo(d, c.peerAddr)
+		}
+		if done {
+			return
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if next.IsZero() {
+			timer.Reset(time.Hour)
+		} else {
+			timer.Reset(time.Until(next))
+		}
+		select {
+		case d := <-c.msgc:
+			c.mu.Lock()
+			now = time.Now()
+			c.handleDatagram(now, d)
+			for more := true; more; {
+				select {
+				case d := <-c.msgc:
+					c.handleDatagram(now, d)
+				default:
+					more = false
+				}
+			}
+			c.mu.Unlock()
+		case <-timer.C:
+		case <-c.wakec:
+		}
+	}
+}
+
+// exit releases the connection's resources.
+func (c *Conn) exit() {
+	c.mu.Lock()
+	c.state = stateDone
+	if c.err == nil {
+		c.err = errConnClosed
+	}
+	c.wakeStreamsLocked()
+	c.mu.Unlock()
+	c.endpoint.removeConn(c)
+	c.tls.Close()
+	close(c.donec)
+}
+
+// handleTimers handles expired timers.
+func (c *Conn) handleTimers(now time.Time) {
+	switch c.state {
+	case stateDone:
+		return
+	case stateClosing, stateDraining:
+		if !now.Before(c.closeDeadline) {
+			c.state = stateDone
+		}
+		return
+	}
+	if !c.idleDeadline.IsZero() && !now.Before(c.idleDeadline) {
+		c.setErrLocked(errIdleTimeout)
+		c.state = stateDone
+		return
+	}
+	if !c.handshakeComplete && !now.Before(c.handshakeDeadline) {
+		c.setErrLocked(errHandshakeTimer)
+		c.state = stateDone
+		return
+	}
+	if t := c.lossDetectionDeadline(); !t.IsZero() && !now.Before(t) {
+		c.onLossDetectionTimeout(now)
+	}
+	if t := c.keepAliveDeadline(); !t.IsZero() && !now.Before(t) {
+		c.pingPending = true
+	}
+}
+
+// nextDeadline returns the time of the next timer event.
+func (c *Conn) nextDeadline() time.Time {
+	var next time.Time
+	min := func(t time.Time) {
+		if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	switch c.state {
+	case stateDone:
+		return time.Time{}
+	case stateClosing, stateDraining:
+		return c.closeDeadline
+	}
+	min(c.idleDeadline)
+	if !c.handshakeComplete {
+		min(c.handshakeDeadline)
+	}
+	min(c.lossDetectionDeadline())
+	min(c.keepAliveDeadline())
+	return next
+}
+
+func (c *Conn) keepAliveDeadline() time.Time {
+	if c.config.KeepAlivePeriod <= 0 || !c.handshakeComplete || c.pingPending {
+		return time.Time{}
+	}
+	return c.lastActivity.Add(c.config.KeepAlivePeriod)
+}
+
+func (c *Conn) resetIdleTimer(now time.Time) {
+	if c.idleTimeout > 0 {
+		c.idleDeadline = now.Add(c.idleTimeout)
+	}
+}
+
+// setErrLocked records the error returned by operations on the connection.
+func (c *Conn) setErrLocked(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+	c.wakeStreamsLocked()
+}
+
+// wakeStreamsLocked unblocks all goroutines waiting on the connection.
+func (c *Conn) wakeStreamsLocked() {
+	for _, s := range c.streams {
+		signal(s.readc)
+		signal(s.writec)
+	}
+	c.streamsChangedLocked()
+}
+
+// streamsChangedLocked wakes goroutines waiting to create or accept streams.
+func (c *Conn) streamsChangedLocked() {
+	close(c.streamsChanged)
+	c.streamsChanged = make(chan struct{})
+}
+
+// closeLocked begins closing the connection, sending a CONNECTION_CLOSE
+// frame to the peer. Operations on the connection return err.
+func (c *Conn) closeLocked(now time.Time, err error, f closeFrame) {
+	if c.state != stateOpen {
+		return
+	}
+	c.setErrLocked(err)
+	c.state = stateClosing
+	c.closeFrame = f
+	c.closePending = true
+	c.closeDeadline = now.Add(3 * c.rec.pto(c.peerParams.maxAckDelay))
+	c.wake()
+}
+
+// closeWithErrorLocked closes the connection after a local error.
+func (c *Conn) closeWithErrorLocked(now time.Time, err error) {
+	f := closeFrame{code: uint64(errInternal)}
+	var te *localTransportError
+	var code TransportError
+	switch {
+	case errors.As(err, &te):
+		f = closeFrame{code: uint64(te.code), reason: te.reason}
+	case errors.As(err, &code):
+		f = closeFrame{code: uint64(code)}
+	}
+	c.closeLocked(now, err, f)
+}
+
+// enterDrainingLocked handles a CONNECTION_CLOSE frame from the peer.
+func (c *Conn) enterDrainingLocked(now time.Time, err error) {
+	if c.state == stateDraining || c.state == stateDone {
+		return
+	}
+	c.setErrLocked(err)
+	c.state = stateDraining
+	c.closeDeadline = now.Add(3 * c.rec.pto(c.peerParams.maxAckDelay))
+}
+
+// maybeRemoveStream removes a stream from the connection
+// when both its send and receive sides are complete.
+func (c *Conn) maybeRemoveStream(s *Stream) {
+	if s.removed {
+		return
+	}
+	sendDone := s.finAcked && s.out.ackedOff == s.out.end() || s.resetAcked
+	recvDone := s.inReset || s.in.eof() || s.inClosed && s.in.finalSize >= 0
+	if !sendDone || !recvDone {
+		return
+	}
+	s.removed = true
+	s.stopPending = false
+	s.inMaxPending = false
+	s.out.discard()
+	delete(c.streams, s.id)
+	if !s.isLocal() {
+		i := streamIndex(streamType(s.id & streamIDUniBit))
+		c.remoteStreamLimit[i]++
+		c.remoteStreamLimitSent[i] = false
+		c.wake()
+	}
+}
+
+// consumeStreamData records that the application has read n bytes
+// from s, and extends the flow control windows as needed.
+func (c *Conn) consumeStreamData(s *Stream, n int64) {
+	c.consumeConnData(n)
+	win := c.config.maxStreamReadBufferSize()
+	if s.in.finalSize < 0 && s.inMax-s.in.readOff < win/2 {
+		s.inMax = s.in.readOff + win
+		s.inMaxPending = true
+		c.wake()
+	}
+}
+
+// consumeConnData records that n bytes of stream data have been
+// read or discarded, and extends the connection flow control window.
+func (c *Conn) consumeConnData(n int64) {
+	c.inConsumed += n
+	if c.inMax-c.inConsumed < c.inWin/2 {
+		c.inMax = c.inConsumed + c.inWin
+		c.inMaxPending = true
+		c.wake()
+	}
+}
@@ -0,0 +1,467 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"time"
+)
+
+// handleDatagram processes a received datagram.
+func (c *Conn) handleDatagram(now time.Time, d []byte) {
+	if c.state == stateDone {
+		return
+	}
+	c.bytesRecv += int64(len(d))
+	c.handlePackets(now, d)
+	// Retry packets that arrived before the keys to decrypt them,
+	// for as long as processing them makes new keys available.
+	for c.newKeys && len(c.undecryptable) > 0 {
+		c.newKeys = false
+		pending := c.undecryptable
+		c.undecryptable = nil
+		for _, d := range pending {
+			c.handlePackets(now, d)
+		}
+	}
+	c.newKeys = false
+}
+
+// handlePackets processes the coalesced packets in a datagram.
+func (c *Conn) handlePackets(now time.Time, d []byte) {
+	for len(d) > 0 && c.state != stateDone {
+		var n int
+		if isLongHeader(d[0]) {
+			n = c.handleLongHeaderPacket(now, d)
+		} else {
+			n = c.handle1RTTPacket(now, d)
+		}
+		if n <= 0 {
+			return
+		}
+		d = d[n:]
+	}
+}
+
+// bufferUndecryptable saves a packet to be processed
+// once the keys to decrypt it are available.
+func (c *Conn) bufferUndecryptable(pkt []byte) {
+	if len(c.undecryptable) < maxUndecryptable {
+		c.undecryptable = append(c.undecryptable, append([]byte{}, pkt...))
+	}
+}
+
+// handleLongHeaderPacket processes the long header packet at the
+// start of d, and returns its size or -1 if the rest of the datagram
+// should be ignored.
+func (c *Conn) handleLongHeaderPacket(now time.Time, d []byte) int {
+	p, n := parseLongHeaderPacket(d)
+	if n < 0 {
+		return -1
+	}
+	if p.version != quicVersion1 {
+		return n
+	}
+	var space numberSpace
+	switch p.ptype {
+	case packetTypeInitial:
+		space = initialSpace
+	case packetTypeHandshake:
+		space = handshakeSpace
+	default:
+		return n
+	}
+	if !bytes.Equal(p.dstConnID, c.localConnID) &&
+		!(c.isServer() && space == initialSpace && bytes.Equal(p.dstConnID, c.origDstConnID)) {
+		return n
+	}
+	s := &c.spaces[space]
+	if s.discarded {
+		return n
+	}
+	if !s.rkeys.isSet() {
+		c.bufferUndecryptable(p.pkt)
+		return n
+	}
+	pnum, payload, err := s.rkeys.unprotect(p.pkt, p.pnumOff, s.largestRecv)
+	if err != nil {
+		return n
+	}
+	if p.pkt[0]&reservedLongBits != 0 {
+		c.closeWithErrorLocked(now, protocolError("reserved header bits are set"))
+		return -1
+	}
+	if c.client && space == initialSpace && !c.peerConnIDSet {
+		// The client uses the connection ID chosen by the server
+		// from its first Initial packet on. See RFC 9000, Section 7.2.
+		c.peerConnID = append([]byte{}, p.srcConnID...)
+		c.peerConnIDSet = true
+	}
+	if c.isServer() && space == handshakeSpace {
+		// Receiving a Handshake packet validates the client's address,
+		// and the server no longer needs its Initial keys.
+		// See RFC 9000, Section 8.1 and RFC 9001, Section 4.9.1.
+		c.addrValidated = true
+		c.discardKeys(initialSpace)
+	}
+	c.handlePacket(now, space, pnum, payload)
+	return n
+}
+
+// handle1RTTPacket processes a 1-RTT packet, which always
+// occupies the remainder of the datagram.
+func (c *Conn) handle1RTTPacket(now time.Time, d []byte) int {
+	if len(d) < 1+connIDLen || !bytes.Equal(d[1:1+connIDLen], c.localConnID) {
+		return -1
+	}
+	s := &c.spaces[appDataSpace]
+	if !s.rkeys.isSet() {
+		c.bufferUndecryptable(d)
+		return len(d)
+	}
+	pnum, payload, err := s.rkeys.unprotect(d, 1+connIDLen, s.largestRecv)
+	if err != nil {
+		// This may be a stateless reset, or a packet sent
+		// after a key update, neither of which is supported.
+		return len(d)
+	}
+	if d[0]&reservedShortBits != 0 {
+		c.closeWithErrorLocked(now, protocolError("reserved header bits are set"))
+		return -1
+	}
+	c.handlePacket(now, appDataSpace, pnum, payload)
+	return len(d)
+}
+
+// handlePacket processes the payload of a decrypted packet.
+func (c *Conn) handlePacket(now time.Time, space numberSpace, pnum int64, payload []byte) {
+	s := &c.spaces[space]
+	if s.recv.contains(pnum) {
+		return
+	}
+	if c.state == stateClosing {
+		// Respond to packets received while closing with
+		// another CONNECTION_CLOSE. See RFC 9000, Section 10.2.1.
+		c.closePending = true
+		return
+	}
+	ackEliciting, err := c.handleFrames(now, space, payload)
+	if err != nil {
+		c.closeWithErrorLocked(now, err)
+		return
+	}
+	s.recv.add(pnum, pnum+1)
+	for len(s.recv) > maxAckRanges {
+		s.recv = s.recv[1:]
+	}
+	if pnum > s.largestRecv {
+		s.largestRecv = pnum
+		s.largestRecvTime = now
+	}
+	if ackEliciting && !s.discarded {
+		s.ackPending = true
+	}
+	c.resetIdleTimer(now)
+}
+
+// handleFrames processes the frames in a packet payload.
+// It reports whether any of the frames are ack-eliciting.
+func (c *Conn) handleFrames(now time.Time, space numberSpace, payload []byte) (ackEliciting bool, err error) {
+	if len(payload) == 0 {
+		return false, protocolError("packet contains no frames")
+	}
+	for len(payload) > 0 && c.state == stateOpen {
+		typ := payload[0]
+		if isAckEliciting(uint64(typ)) {
+			ackEliciting = true
+		}
+		if space != appDataSpace {
+			switch typ {
+			case frameTypePadding, frameTypePing, frameTypeAck, frameTypeAckECN,
+				frameTypeCrypto, frameTypeConnectionCloseTransport:
+			default:
+				return false, protocolError("frame type 0x%x not allowed in %v packet", typ, space)
+			}
+		}
+		n := -1
+		switch {
+		case typ == frameTypePadding:
+			n = 1
+			for n < len(payload) && payload[n] == frameTypePadding {
+				n++
+			}
+		case typ == frameTypePing:
+			n = 1
+		case typ == frameTypeAck || typ == frameTypeAckECN:
+			n, err = c.handleAckFrame(now, space, payload)
+		case typ == frameTypeResetStream:
+			n, err = c.handleResetStreamFrame(payload)
+		case typ == frameTypeStopSending:
+			n, err = c.handleStopSendingFrame(payload)
+		case typ == frameTypeCrypto:
+			n, err = c.handleCryptoFrame(now, space, payload)
+		case typ == frameTypeNewToken:
+			if c.isServer() {
+				return false, protocolError("client sent NEW_TOKEN")
+			}
+			_, n = consumeVarintBytes(payload[1:])
+			if n >= 0 {
+				n++
+			}
+		case typ&^(streamOffBit|streamLenBit|streamFinBit) == frameTypeStreamBase:
+			n, err = c.handleStreamFrame(payload)
+		case typ == frameTypeMaxData:
+			var max uint64
+			if n = consumeVarints(payload, &max); n >= 0 && int64(max) > c.outMax {
+				c.outMax = int64(max)
+			}
+		case typ == frameTypeMaxStreamData:
+			n, err = c.handleMaxStreamDataFrame(payload)
+		case typ == frameTypeMaxStreamsBidi || typ == frameTypeMaxStreamsUni:
+			var max uint64
+			if n = consumeVarints(payload, &max); n >= 0 {
+				if max > 1<<60 {
+					return false, &localTransportError{errFrameEncoding, "MAX_STREAMS too large"}
+				}
+				i := streamIndex(bidiStream)
+				if typ == frameTypeMaxStreamsUni {
+					i = streamIndex(uniStream)
+				}
+				if int64(max) > c.localStreamLimit[i] {
+					c.localStreamLimit[i] = int64(max)
+					c.streamsChangedLocked()
+				}
+			}
+		case typ == frameTypeDataBlocked || typ == frameTypeStreamsBlockedBidi ||
+			typ == frameTypeStreamsBlockedUni || typ == frameTypeRetireConnectionID:
+			var v uint64
+			n = consumeVarints(payload, &v)
+		case typ == frameTypeStreamDataBlocked:
+			var id, v uint64
+			n = consumeVarints(payload, &id, &v)
+		case typ == frameTypeNewConnectionID:
+			n = consumeNewConnectionIDFrame(payload)
+		case typ == frameTypePathChallenge:
+			if len(payload) >= 9 {
+				c.pathResponse = append([]byte{}, payload[1:9]...)
+				n = 9
+			}
+		case typ == frameTypePathResponse:
+			if len(payload) >= 9 {
+				n = 9
+			}
+		case typ == frameTypeConnectionCloseTransport || typ == frameTypeConnectionCloseApplication:
+			n = c.handleConnectionCloseFrame(now, payload)
+		case typ == frameTypeHandshakeDone:
+			if c.isServer() {
+				return false, protocolError("client sent HANDSHAKE_DONE")
+			}
+			n = 1
+			c.confirmHandshake()
+		default:
+			return false, &localTransportError{errFrameEncoding, "unknown frame type"}
+		}
+		if err != nil {
+			return false, err
+		}
+		if n < 0 {
+			return false, &localTransportError{errFrameEncoding, "malformed frame"}
+		}
+		payload = payload[n:]
+	}
+	return ackEliciting, nil
+}
+
+func consumeNewConnectionIDFrame(b []byte) int {
+	var seq, retire uint64
+	n := consumeVarints(b, &seq, &retire)
+	if n < 0 {
+		return -1
+	}
+	id, m := consumeUint8Bytes(b[n:])
+	if m < 0 || len(id) < 1 || len(id) > maxConnIDLen || len(b[n+m:]) < 16 {
+		return -1
+	}
+	return n + m + 16 // stateless reset token
+}
+
+func (c *Conn) handleCryptoFrame(now time.Time, space numberSpace, b []byte) (int, error) {
+	off, data, n := consumeCryptoFrame(b)
+	if n < 0 {
+		return n, nil
+	}
+	s := &c.spaces[space]
+	if off+int64(len(data)) > maxVarint {
+		return n, &localTransportError{errFrameEncoding, "CRYPTO frame too large"}
+	}
+	if off+int64(len(data))-s.cryptoRecv.readOff > maxCryptoBufferSize {
+		return n, &localTransportError{errCryptoBufExceeded, ""}
+	}
+	if err := s.cryptoRecv.write(off, data, false); err != nil {
+		return n, err
+	}
+	if k := s.cryptoRecv.readable(); k > 0 {
+		buf := make([]byte, k)
+		s.cryptoRecv.read(buf)
+		if err := c.tls.HandleData(tlsLevel(space), buf); err != nil {
+			return n, tlsError(err)
+		}
+		if err := c.handleTLSEvents(now); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// streamForFrame returns the stream a frame refers to,
+// implicitly opening peer-initiated streams as needed.
+// It returns nil if the stream has already been closed.
+func (c *Conn) streamForFrame(id int64, recv bool) (*Stream, error) {
+	local := (id&streamIDServerBit != 0) == c.isServer()
+	typ := streamType(id & streamIDUniBit)
+	if typ == uniStream && local == recv {
+		return nil, &localTransportError{errStreamState, "invalid frame for unidirectional stream"}
+	}
+	i := streamIndex(typ)
+	num := id >> 2
+	if local {
+		if num >= c.nextLocalStream[i] {
+			return nil, &localTransportError{errStreamState, "frame for unopened stream"}
+		}
+		return c.streams[id], nil
+	}
+	if num >= c.remoteStreamLimit[i] {
+		return nil, &localTransportError{errStreamLimit, ""}
+	}
+	if c.remoteStreamOpened[i] <= num {
+		for c.remoteStreamOpened[i] <= num {
+			sid := c.remoteStreamOpened[i]<<2 | int64(typ)
+			if c.client {
+				sid |= streamIDServerBit
+			}
+			c.remoteStreamOpened[i]++
+			s := newStream(c, sid)
+			c.streams[sid] = s
+			c.acceptq = append(c.acceptq, s)
+		}
+		c.streamsChangedLocked()
+	}
+	return c.streams[id], nil
+}
+
+func (c *Conn) handleStreamFrame(b []byte) (int, error) {
+	id, off, fin, data, n := consumeStreamFrame(b)
+	if n < 0 {
+		return n, nil
+	}
+	s, err := c.streamForFrame(id, true)
+	if err != nil || s == nil {
+		return n, err
+	}
+	if s.inReset {
+		return n, nil
+	}
+	oldEnd := s.in.end
+	if err := s.in.write(off, data, fin); err != nil {
+		return n, err
+	}
+	if s.in.end > s.inMax {
+		return n, &localTransportError{errFlowControl, "stream flow control limit exceeded"}
+	}
+	if newData := s.in.end - oldEnd; newData > 0 {
+		c.inReceived += newData
+		if c.inReceived > c.inMax {
+			return n, &localTransportError{errFlowControl, "connection flow control limit exceeded"}
+		}
+		if s.in.discarding {
+			c.consumeConnData(newData)
+		}
+	}
+	if s.in.finalSize >= 0 {
+		s.inMaxPending = false
+	}
+	signal(s.readc)
+	c.maybeRemoveStream(s)
+	return n, nil
+}
+
+func (c *Conn) handleResetStreamFrame(b []byte) (int, error) {
+	var id, code, finalSize uint64
+	n := consumeVarints(b, &id, &code, &finalSize)
+	if n < 0 {
+		return n, nil
+	}
+	s, err := c.streamForFrame(int64(id), true)
+	if err != nil || s == nil || s.inReset {
+		return n, err
+	}
+	size := int64(finalSize)
+	if s.in.finalSize >= 0 && size != s.in.finalSize || size < s.in.end {
+		return n, &localTransportError{errFinalSize, "invalid final size in RESET_STREAM"}
+	}
+	if size > s.inMax {
+		return n, &localTransportError{errFlowControl, "stream flow control limit exceeded"}
+	}
+	c.inReceived += size - s.in.end
+	if c.inReceived > c.inMax {
+		return n, &localTransportError{errFlowControl, "connection flow control limit exceeded"}
+	}
+	// Data that will never be read no longer counts
+	// against the connection's flow control window.
+	s.in.end = size
+	s.in.finalSize = size
+	c.consumeConnData(size - s.in.readOff)
+	s.in.discard()
+	s.inReset = true
+	s.inResetCode = code
+	s.stopPending = false
+	s.inMaxPending = false
+	signal(s.readc)
+	c.maybeRemoveStream(s)
+	return n, nil
+}
+
+func (c *Conn) handleStopSendingFrame(b []byte) (int, error) {
+	var id, code uint64
+	n := consumeVarints(b, &id, &code)
+	if n < 0 {
+		return n, nil
+	}
+	s, err := c.streamForFrame(int64(id), false)
+	if err != nil || s == nil {
+		return n, err
+	}
+	s.resetLocked(code, StreamErrorCode(code))
+	return n, nil
+}
+
+func (c *Conn) handleMaxStreamDataFrame(b []byte) (int, error) {
+	var id, max uint64
+	n := consumeVarints(b, &id, &max)
+	if n < 0 {
+		return n, nil
+	}
+	s, err := c.streamForFrame(int64(id), false)
+	if err != nil || s == nil {
+		return n, err
+	}
+	if int64(max) > s.outMax {
+		s.outMax = int64(max)
+	}
+	return n, nil
+}
+
+func (c *Conn) handleConnectionCloseFrame(now time.Time, b []byte) int {
+	app, code, reason, n := consumeConnectionCloseFrame(b)
+	if n < 0 {
+		return n
+	}
+	if app {
+		c.enterDrainingLocked(now, &ApplicationError{Code: code, Reason: reason})
+	} else {
+		c.enterDrainingLocked(now, &PeerTransportError{Code: TransportError(code), Reason: reason})
+	}
+	return n
+}
@@ -0,0 +1,332 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import "time"
+
+// maxDatagramsPerSend is the maximum number of datagrams
+// built in one pass of the connection goroutine.
+const maxDatagramsPerSend = 64
+
+// appendDatagrams builds the datagrams to send.
+func (c *Conn) appendDatagrams(now time.Time) (dgrams [][]byte) {
+	switch c.state {
+	case stateClosing:
+		if c.closePending {
+			c.closePending = false
+			if d := c.closeDatagram(); d != nil {
+				dgrams = append(dgrams, d)
+			}
+		}
+		return dgrams
+	case stateDraining, stateDone:
+		return nil
+	}
+	for len(dgrams) < maxDatagramsPerSend {
+		d := c.appendDatagram(now)
+		if d == nil {
+			break
+		}
+		dgrams = append(dgrams, d)
+	}
+	return dgrams
+}
+
+// appendDatagram builds a datagram containing a packet for each
+// number space with something to send, or returns nil if there
+// is nothing to send.
+func (c *Conn) appendDatagram(now time.Time) []byte {
+	if !c.client && !c.addrValidated && c.bytesSent+maxUDPPayloadSize > 3*c.bytesRecv {
+		// Before the client's address is validated, the server may send
+		// at most three times as much data as it has received.
+		// See RFC 9000, Section 8.1.
+		return nil
+	}
+	w := newPacketWriter()
+	var (
+		written [numberSpaceCount]bool
+		sent    [numberSpaceCount]*sentPacket
+		pad     bool
+	)
+	for space := initialSpace; space < numberSpaceCount; space++ {
+		p, ok := c.appendPacket(now, w, space)
+		if !ok {
+			continue
+		}
+		written[space] = true
+		sent[space] = p
+		if space == initialSpace && (c.client || p != nil) {
+			// Datagrams containing client Initial packets and
+			// ack-eliciting server Initial packets must be padded.
+			// See RFC 9000, Section 14.1.
+			pad = true
+		}
+	}
+	if len(w.pkts) == 0 {
+		return nil
+	}
+	if pad {
+		w.padTo(maxUDPPayloadSize)
+	}
+	d := w.finish()
+	i := 0
+	for space := initialSpace; space < numberSpaceCount; space++ {
+		if !written[space] {
+			continue
+		}
+		pp := w.pkts[i]
+		i++
+		p := sent[space]
+		if p == nil {
+			continue
+		}
+		p.size = pp.end - pp.start
+		s := &c.spaces[space]
+		s.sent = append(s.sent, p)
+		s.lastAckElicitingSent = now
+		c.rec.onSent(p)
+		c.lastActivity = now
+	}
+	c.bytesSent += int64(len(d))
+	if c.client && written[handshakeSpace] {
+		// The client discards Initial keys when it first
+		// sends a Handshake packet. See RFC 9001, Section 4.9.1.
+		c.discardKeys(initialSpace)
+	}
+	return d
+}
+
+// appendPacket adds a packet in a number space to w.
+// It returns the packet if it is ack-eliciting, and reports
+// whether a packet was written.
+func (c *Conn) appendPacket(now time.Time, w *packetWriter, space numberSpace) (*sentPacket, bool) {
+	s := &c.spaces[space]
+	if !s.wkeys.isSet() {
+		return nil, false
+	}
+	var ok bool
+	switch space {
+	case initialSpace:
+		ok = w.startLongPacket(packetTypeInitial, &s.wkeys, s.nextNum, c.peerConnID, c.localConnID)
+	case handshakeSpace:
+		ok = w.startLongPacket(packetTypeHandshake, &s.wkeys, s.nextNum, c.peerConnID, c.localConnID)
+	case appDataSpace:
+		ok = w.start1RTTPacket(&s.wkeys, s.nextNum, c.peerConnID)
+	}
+	if !ok {
+		return nil, false
+	}
+	if s.ackPending {
+		delay := now.Sub(s.largestRecvTime) / time.Microsecond
+		if delay < 0 {
+			delay = 0
+		}
+		delay >>= uint(c.localParams.ackDelayExponent)
+		if b := appendAckFrame(w.b, s.recv, uint64(delay), w.avail()); len(b) > len(w.b) {
+			w.b = b
+			s.ackPending = false
+		}
+	}
+	p := &sentPacket{num: s.nextNum, time: now}
+	if s.probePending || c.rec.canSend() {
+		c.appendCryptoFrames(w, space, p)
+		if space == appDataSpace {
+			c.appendAppFrames(w, p)
+		}
+		if len(p.frames) == 0 && (s.probePending || space == appDataSpace && c.pingPending) {
+			if w.tryAppend(func(b []byte) []byte { return append(b, frameTypePing) }) {
+				p.frames = append(p.frames, sentFrame{typ: frameTypePing})
+			}
+		}
+	}
+	if w.payloadLen() == 0 {
+		w.abandonPacket()
+		return nil, false
+	}
+	s.nextNum++
+	if len(p.frames) == 0 {
+		return nil, true
+	}
+	s.probePending = false
+	if space == appDataSpace {
+		c.pingPending = false
+	}
+	return p, true
+}
+
+// appendCryptoFrames adds CRYPTO frames to the current packet.
+func (c *Conn) appendCryptoFrames(w *packetWriter, space numberSpace, p *sentPacket) {
+	buf := &c.spaces[space].cryptoSend
+	for {
+		max := w.avail() - cryptoFrameHeaderSize(buf.end(), w.avail())
+		off, n, ok := buf.next(maxVarint, max)
+		if !ok {
+			return
+		}
+		w.b = appendCryptoFrame(w.b, off, buf.data(off, n))
+		buf.markSent(off, n)
+		p.frames = append(p.frames, sentFrame{typ: frameTypeCrypto, off: off, n: n})
+	}
+}
+
+// appendAppFrames adds control and stream frames to the current 1-RTT packet.
+func (c *Conn) appendAppFrames(w *packetWriter, p *sentPacket) {
+	add := func(f sentFrame, frame func(b []byte) []byte) bool {
+		if !w.tryAppend(frame) {
+			return false
+		}
+		p.frames = append(p.frames, f)
+		return true
+	}
+	if c.handshakeDonePending {
+		if add(sentFrame{typ: frameTypeHandshakeDone}, func(b []byte) []byte {
+			return append(b, frameTypeHandshakeDone)
+		}) {
+			c.handshakeDonePending = false
+		}
+	}
+	if c.pathResponse != nil {
+		data := c.pathResponse
+		if add(sentFrame{typ: frameTypePathResponse}, func(b []byte) []byte {
+			return append(append(b, frameTypePathResponse), data...)
+		}) {
+			c.pathResponse = nil
+		}
+	}
+	if c.inMaxPending {
+		max := c.inMax
+		if add(sentFrame{typ: frameTypeMaxData}, func(b []byte) []byte {
+			return AppendVarint(append(b, frameTypeMaxData), uint64(max))
+		}) {
+			c.inMaxPending = false
+		}
+	}
+	for i, typ := range [2]byte{frameTypeMaxStreamsBidi, frameTypeMaxStreamsUni} {
+		if c.remoteStreamLimitSent[i] {
+			continue
+		}
+		limit := c.remoteStreamLimit[i]
+		if add(sentFrame{typ: typ}, func(b []byte) []byte {
+			return AppendVarint(append(b, typ), uint64(limit))
+		}) {
+			c.remoteStreamLimitSent[i] = true
+		}
+	}
+	for _, st := range c.streams {
+		if w.avail() < 16 {
+			return
+		}
+		id := st.id
+		if st.stopPending {
+			code := st.stopCode
+			if add(sentFrame{typ: frameTypeStopSending, id: id}, func(b []byte) []byte {
+				b = AppendVarint(append(b, frameTypeStopSending), uint64(id))
+				return AppendVarint(b, code)
+			}) {
+				st.stopPending = false
+			}
+		}
+		if st.inMaxPending {
+			max := st.inMax
+			if add(sentFrame{typ: frameTypeMaxStreamData, id: id}, func(b []byte) []byte {
+				b = AppendVarint(append(b, frameTypeMaxStreamData), uint64(id))
+				return AppendVarint(b, uint64(max))
+			}) {
+				st.inMaxPending = false
+			}
+		}
+		if st.outReset {
+			if !st.resetPending {
+				continue
+			}
+			code, size := st.resetCode, st.out.sentOff
+			if add(sentFrame{typ: frameTypeResetStream, id: id}, func(b []byte) []byte {
+				b = AppendVarint(append(b, frameTypeResetStream), uint64(id))
+				b = AppendVarint(b, code)
+				return AppendVarint(b, uint64(size))
+			}) {
+				st.resetPending = false
+			}
+			continue
+		}
+		c.appendStreamFrames(w, st, p)
+	}
+}
+
+// appendStreamFrames adds STREAM frames for st to the current packet.
+func (c *Conn) appendStreamFrames(w *packetWriter, st *Stream, p *sentPacket) {
+	for {
+		limit := st.outMax
+		if connLimit := st.out.sentOff + (c.outMax - c.outSent); connLimit < limit {
+			limit = connLimit
+		}
+		end := st.out.end()
+		max := w.avail() - streamFrameHeaderSize(st.id, end, w.avail())
+		off, n, ok := st.out.next(limit, max)
+		if !ok {
+			if st.outClosed && !st.finSent && !st.finAcked && st.out.sentOff == end && !st.out.hasLost() {
+				// All data has been sent; send the FIN on its own.
+				if w.tryAppend(func(b []byte) []byte {
+					return appendStreamFrame(b, st.id, end, nil, true)
+				}) {
+					st.finSent = true
+					p.frames = append(p.frames, sentFrame{typ: frameTypeStreamBase, id: st.id, off: end, fin: true})
+				}
+			}
+			return
+		}
+		fin := st.outClosed && off+int64(n) == end
+		if newData := off + int64(n) - st.out.sentOff; newData > 0 {
+			c.outSent += newData
+		}
+		w.b = appendStreamFrame(w.b, st.id, off, st.out.data(off, n), fin)
+		st.out.markSent(off, n)
+		if fin {
+			st.finSent = true
+		}
+		p.frames = append(p.frames, sentFrame{typ: frameTypeStreamBase, id: st.id, off: off, n: n, fin: fin})
+	}
+}
+
+// closeDatagram builds a datagram containing a CONNECTION_CLOSE frame
+// in each number space for which keys are available.
+func (c *Conn) closeDatagram() []byte {
+	w := newPacketWriter()
+	pad := false
+	for space := initialSpace; space < numberSpaceCount; space++ {
+		s := &c.spaces[space]
+		if !s.wkeys.isSet() {
+			continue
+		}
+		var ok bool
+		switch space {
+		case initialSpace:
+			ok = w.startLongPacket(packetTypeInitial, &s.wkeys, s.nextNum, c.peerConnID, c.localConnID)
+			pad = c.client
+		case handshakeSpace:
+			ok = w.startLongPacket(packetTypeHandshake, &s.wkeys, s.nextNum, c.peerConnID, c.localConnID)
+		case appDataSpace:
+			ok = w.start1RTTPacket(&s.wkeys, s.nextNum, c.peerConnID)
+		}
+		if !ok {
+			break
+		}
+		f := c.closeFrame
+		if f.app && space != appDataSpace {
+			// Application errors must not be revealed before the
+			// handshake completes. See RFC 9000, Section 10.2.3.
+			f = closeFrame{code: uint64(errApplication)}
+		}
+		w.b = appendConnectionCloseFrame(w.b, f.app, f.code, f.reason, w.avail())
+		s.nextNum++
+	}
+	if len(w.pkts) == 0 {
+		return nil
+	}
+	if pad {
+		w.padTo(maxUDPPayloadSize)
+	}
+	return w.finish()
+}
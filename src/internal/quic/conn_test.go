@@ -0,0 +1,515 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	testCertOnce sync.Once
+	testCert     tls.Certificate
+	testRoots    *x509.CertPool
+)
+
+func testTLSConfigs(t *testing.T) (client, server *tls.Config) {
+	testCertOnce.Do(func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "example.com"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			DNSNames:     []string{"example.com"},
+			IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			panic(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			panic(err)
+		}
+		testCert = tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+		testRoots = x509.NewCertPool()
+		testRoots.AddCert(cert)
+	})
+	client = &tls.Config{
+		ServerName: "example.com",
+		RootCAs:    testRoots,
+		NextProtos: []string{"test"},
+	}
+	server = &tls.Config{
+		Certificates: []tls.Certificate{testCert},
+		NextProtos:   []string{"test"},
+	}
+	return client, server
+}
+
+// newTestPair returns a connected client and server.
+func newTestPair(t *testing.T, clientConfig, serverConfig *Config) (client, server *Conn) {
+	t.Helper()
+	clientTLS, serverTLS := testTLSConfigs(t)
+	if clientConfig == nil {
+		clientConfig = &Config{}
+	}
+	if serverConfig == nil {
+		serverConfig = &Config{}
+	}
+	clientConfig.TLSConfig = clientTLS
+	serverConfig.TLSConfig = serverTLS
+
+	srv, err := Listen("udp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := Listen("udp", "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		cli.Close(ctx)
+		srv.Close(ctx)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err = cli.Dial(ctx, "udp", srv.LocalAddr().String(), clientConfig)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	server, err = srv.Accept(ctx)
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	return client, server
+}
+
+func TestConnHandshake(t *testing.T) {
+	client, server := newTestPair(t, nil, nil)
+	for _, c := range []*Conn{client, server} {
+		cs := c.ConnectionState()
+		if cs.Version != tls.VersionTLS13 {
+			t.Errorf("TLS version = %x, want TLS 1.3", cs.Version)
+		}
+		if cs.NegotiatedProtocol != "test" {
+			t.Errorf("NegotiatedProtocol = %q, want %q", cs.NegotiatedProtocol, "test")
+		}
+	}
+}
+
+func TestConnStreamEcho(t *testing.T) {
+	client, server := newTestPair(t, nil, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	go func() {
+		for {
+			s, err := server.AcceptStream(ctx)
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(s, s)
+				s.CloseWrite()
+			}()
+		}
+	}()
+
+	for _, size := range []int{0, 1, 1000, 100000, 3 << 20} {
+		s, err := client.NewStream(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := make([]byte, size)
+		rand.Read(want)
+		go func() {
+			s.Write(want)
+			s.CloseWrite()
+		}()
+		s.SetReadContext(ctx)
+		got, err := io.ReadAll(s)
+		if err != nil {
+			t.Fatalf("size %v: ReadAll: %v", size, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("size %v: echoed %v bytes, mismatched", size, len(got))
+		}
+	}
+}
+
+func TestConnManyStreams(t *testing.T) {
+	client, server := newTestPair(t, nil, &Config{MaxBidiRemoteStreams: 5})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	go func() {
+		for {
+			s, err := server.AcceptStream(ctx)
+			if err != nil {
+				return
+			}
+			go func() {
+				b, _ := io.ReadAll(s)
+				s.Write(b)
+				s.CloseWrite()
+			}()
+		}
+	}()
+
+	// Open more streams than the server's stream limit,
+	// which the server must raise as streams complete.
+	const n = 50
+	var wg sync.WaitGroup
+	errc := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s, err := client.NewStream(ctx)
+			if err != nil {
+				errc <- err
+				return
+			}
+			s.SetReadContext(ctx)
+			want := []byte{byte(i)}
+			s.Write(want)
+			s.CloseWrite()
+			got, err := io.ReadAll(s)
+			if err != nil {
+				errc <- err
+				return
+			}
+			if !bytes.Equal(got, want) {
+				errc <- errors.New("mismatched data")
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		t.Error(err)
+	}
+}
+
+func TestConnUniStreams(t *testing.T) {
+	client, server := newTestPair(t, nil, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s, err := client.NewSendOnlyStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsWriteOnly() {
+		t.Errorf("IsWriteOnly() = false, want true")
+	}
+	s.Write([]byte("hello"))
+	s.CloseWrite()
+
+	rs, err := server.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rs.IsReadOnly() {
+		t.Errorf("IsReadOnly() = false, want true")
+	}
+	rs.SetReadContext(ctx)
+	got, err := io.ReadAll(rs)
+	if err != nil || string(got) != "hello" {
+		t.Errorf("ReadAll = %q, %v; want %q, nil", got, err, "hello")
+	}
+}
+
+func TestConnStreamReset(t *testing.T) {
+	client, server := newTestPair(t, nil, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s, err := client.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Write([]byte("data"))
+	rs, err := server.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Reset(42)
+	rs.SetReadContext(ctx)
+	_, err = io.ReadAll(rs)
+	var code StreamErrorCode
+	if !errors.As(err, &code) || code != 42 {
+		t.Errorf("Read after peer reset: %v, want StreamErrorCode(42)", err)
+	}
+
+	// STOP_SENDING causes the peer's writes to fail.
+	rs.CloseRead(7)
+	s2, err := server.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2.Write([]byte("x"))
+	cs, err := client.AcceptStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.CloseRead(9)
+	s2.SetWriteContext(ctx)
+	for {
+		_, err := s2.Write(make([]byte, 1000))
+		if err != nil {
+			if !errors.As(err, &code) || code != 9 {
+				t.Errorf("Write after STOP_SENDING: %v, want StreamErrorCode(9)", err)
+			}
+			break
+		}
+	}
+}
+
+func TestConnClose(t *testing.T) {
+	client, server := newTestPair(t, nil, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client.Abort(3, "goodbye")
+	_, err := server.AcceptStream(ctx)
+	var appErr *ApplicationError
+	if !errors.As(err, &appErr) || appErr.Code != 3 || appErr.Reason != "goodbye" {
+		t.Errorf("AcceptStream after peer close: %v, want application error 3", err)
+	}
+	if _, err := client.NewStream(ctx); err == nil {
+		t.Errorf("NewStream after close: nil error")
+	}
+	select {
+	case <-server.Done():
+	case <-ctx.Done():
+		t.Fatal("server connection did not finish draining")
+	}
+}
+
+func TestConnIdleTimeout(t *testing.T) {
+	client, server := newTestPair(t, &Config{MaxIdleTimeout: 100 * time.Millisecond}, nil)
+	for _, c := range []*Conn{client, server} {
+		select {
+		case <-c.Done():
+		case <-time.After(10 * time.Second):
+			t.Fatal("connection did not time out")
+		}
+		if err := c.Err(); err != errIdleTimeout {
+			t.Errorf("Err() = %v, want %v", err, errIdleTimeout)
+		}
+	}
+}
+
+func TestConnKeepAlive(t *testing.T) {
+	config := &Config{
+		MaxIdleTimeout:  200 * time.Millisecond,
+		KeepAlivePeriod: 50 * time.Millisecond,
+	}
+	client, _ := newTestPair(t, config, nil)
+	select {
+	case <-client.Done():
+		t.Fatalf("connection closed despite keep-alives: %v", client.Err())
+	case <-time.After(600 * time.Millisecond):
+	}
+}
+
+func TestConnHandshakeFailure(t *testing.T) {
+	clientTLS, serverTLS := testTLSConfigs(t)
+	clientTLS.ServerName = "wrong.example.com"
+	srv, err := Listen("udp", "127.0.0.1:0", &Config{TLSConfig: serverTLS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := Listen("udp", "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	defer srv.Close(ctx)
+	defer cli.Close(ctx)
+	_, err = cli.Dial(ctx, "udp", srv.LocalAddr().String(), &Config{TLSConfig: clientTLS})
+	if err == nil {
+		t.Fatal("Dial succeeded with wrong server name")
+	}
+	var te *localTransportError
+	if !errors.As(err, &te) || te.code != errTLSBase+42 {
+		t.Errorf("Dial: %v, want TLS bad_certificate alert", err)
+	}
+}
+
+// lossyPacketConn drops a fraction of the datagrams it sends.
+type lossyPacketConn struct {
+	net.PacketConn
+	mu   sync.Mutex
+	n    int
+	drop int // drop every drop'th datagram
+}
+
+func (c *lossyPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.mu.Lock()
+	c.n++
+	drop := c.n%c.drop == 0
+	c.mu.Unlock()
+	if drop {
+		return len(b), nil
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+func TestConnPacketLoss(t *testing.T) {
+	clientTLS, serverTLS := testTLSConfigs(t)
+	listen := func(config *Config, drop int) *Endpoint {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewEndpoint(&lossyPacketConn{PacketConn: pc, drop: drop}, config)
+	}
+	srv := listen(&Config{TLSConfig: serverTLS}, 5)
+	cli := listen(nil, 7)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	defer srv.Close(ctx)
+	defer cli.Close(ctx)
+
+	go func() {
+		c, err := srv.Accept(ctx)
+		if err != nil {
+			return
+		}
+		s, err := c.AcceptStream(ctx)
+		if err != nil {
+			return
+		}
+		io.Copy(s, s)
+		s.CloseWrite()
+	}()
+	c, err := cli.Dial(ctx, "udp", srv.LocalAddr().String(), &Config{TLSConfig: clientTLS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := c.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, 200000)
+	rand.Read(want)
+	go func() {
+		s.Write(want)
+		s.CloseWrite()
+	}()
+	s.SetReadContext(ctx)
+	got, err := io.ReadAll(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("echoed %v bytes, mismatched", len(got))
+	}
+}
+
+// flakyPacketConn fails its first ReadFrom with a transient error.
+type flakyPacketConn struct {
+	net.PacketConn
+	once sync.Once
+}
+
+func (c *flakyPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	var failed bool
+	c.once.Do(func() { failed = true })
+	if failed {
+		return 0, nil, errors.New("transient read error")
+	}
+	return c.PacketConn.ReadFrom(b)
+}
+
+func TestEndpointReadErrorIsTransient(t *testing.T) {
+	clientTLS, serverTLS := testTLSConfigs(t)
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewEndpoint(&flakyPacketConn{PacketConn: pc}, &Config{TLSConfig: serverTLS})
+	cli, err := Listen("udp", "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	defer srv.Close(ctx)
+	defer cli.Close(ctx)
+
+	go srv.Accept(ctx)
+	if _, err := cli.Dial(ctx, "udp", srv.LocalAddr().String(), &Config{TLSConfig: clientTLS}); err != nil {
+		t.Fatalf("Dial after server read error: %v", err)
+	}
+}
+
+func TestEndpointMaxPendingConns(t *testing.T) {
+	clientTLS, serverTLS := testTLSConfigs(t)
+	srv, err := Listen("udp", "127.0.0.1:0", &Config{
+		TLSConfig:       serverTLS,
+		MaxPendingConns: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := Listen("udp", "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	defer srv.Close(ctx)
+	defer cli.Close(ctx)
+
+	dial := func(ctx context.Context) error {
+		_, err := cli.Dial(ctx, "udp", srv.LocalAddr().String(), &Config{TLSConfig: clientTLS})
+		return err
+	}
+	if err := dial(ctx); err != nil {
+		t.Fatalf("first Dial: %v", err)
+	}
+
+	// The first connection has not been accepted,
+	// so the server ignores the second.
+	shortCtx, shortCancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer shortCancel()
+	if err := dial(shortCtx); err == nil {
+		t.Fatal("second Dial succeeded with a full accept queue")
+	}
+
+	if _, err := srv.Accept(ctx); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	if err := dial(ctx); err != nil {
+		t.Fatalf("Dial after Accept: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"time"
+)
+
+// tlsLevel returns the TLS encryption level of a packet number space.
+func tlsLevel(space numberSpace) tls.QUICEncryptionLevel {
+	switch space {
+	case initialSpace:
+		return tls.QUICEncryptionLevelInitial
+	case handshakeSpace:
+		return tls.QUICEncryptionLevelHandshake
+	}
+	return tls.QUICEncryptionLevelApplication
+}
+
+// spaceForLevel returns the packet number space of a TLS encryption level.
+// It reports false for 0-RTT, which is not supported.
+func spaceForLevel(level tls.QUICEncryptionLevel) (numberSpace, bool) {
+	switch level {
+	case tls.QUICEncryptionLevelInitial:
+		return initialSpace, true
+	case tls.QUICEncryptionLevelHandshake:
+		return handshakeSpace, true
+	case tls.QUICEncryptionLevelApplication:
+		return appDataSpace, true
+	}
+	return 0, false
+}
+
+// tlsError converts a TLS handshake error into a transport error
+// carrying the TLS alert. See RFC 9001, Section 4.8.
+func tlsError(err error) error {
+	var alert tls.AlertError
+	if errors.As(err, &alert) {
+		return &localTransportError{errTLSBase + TransportError(alert), err.Error()}
+	}
+	return &localTransportError{errInternal, err.Error()}
+}
+
+// handleTLSEvents processes the events produced by the TLS handshake.
+func (c *Conn) handleTLSEvents(now time.Time) error {
+	for {
+		e := c.tls.NextEvent()
+		switch e.Kind {
+		case tls.QUICNoEvent:
+			return nil
+		case tls.QUICSetReadSecret, tls.QUICSetWriteSecret:
+			space, ok := spaceForLevel(e.Level)
+			if !ok {
+				continue
+			}
+			k, err := newKeys(e.Suite, e.Data)
+			if err != nil {
+				return &localTransportError{errInternal, err.Error()}
+			}
+			if e.Kind == tls.QUICSetReadSecret {
+				c.spaces[space].rkeys = k
+				c.newKeys = true
+			} else {
+				c.spaces[space].wkeys = k
+			}
+		case tls.QUICWriteData:
+			space, ok := spaceForLevel(e.Level)
+			if !ok {
+				continue
+			}
+			c.spaces[space].cryptoSend.write(e.Data)
+		case tls.QUICTransportParameters:
+			if err := c.receiveTransportParameters(e.Data); err != nil {
+				return err
+			}
+		case tls.QUICHandshakeDone:
+			c.onHandshakeComplete()
+		}
+	}
+}
+
+// receiveTransportParameters validates and applies the peer's transport parameters.
+func (c *Conn) receiveTransportParameters(b []byte) error {
+	p, err := parseTransportParameters(b, c.client)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(p.initialSrcConnID, c.peerConnID) {
+		return transportParameterError("initial_source_connection_id does not match")
+	}
+	if c.client && !bytes.Equal(p.originalDstConnID, c.origDstConnID) {
+		return transportParameterError("original_destination_connection_id does not match")
+	}
+	c.peerParams = p
+	c.localStreamLimit[streamIndex(bidiStream)] = p.initialMaxStreamsBidi
+	c.localStreamLimit[streamIndex(uniStream)] = p.initialMaxStreamsUni
+	c.outMax = p.initialMaxData
+	if p.maxIdleTimeout > 0 && (c.idleTimeout == 0 || p.maxIdleTimeout < c.idleTimeout) {
+		c.idleTimeout = p.maxIdleTimeout
+	}
+	c.streamsChangedLocked()
+	return nil
+}
+
+// onHandshakeComplete is called when the TLS handshake completes.
+func (c *Conn) onHandshakeComplete() {
+	c.handshakeComplete = true
+	if c.isServer() {
+		// The handshake is confirmed at the server when it completes.
+		// The server tells the client with a HANDSHAKE_DONE frame.
+		// See RFC 9001, Section 4.1.2.
+		c.handshakeDonePending = true
+		c.confirmHandshake()
+		c.endpoint.queueAccept(c)
+	}
+	close(c.handshakeDonec)
+}
+
+// confirmHandshake is called when the handshake is confirmed.
+func (c *Conn) confirmHandshake() {
+	if c.handshakeConfirmed {
+		return
+	}
+	c.handshakeConfirmed = true
+	c.discardKeys(handshakeSpace)
+}
@@ -0,0 +1,302 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// An Endpoint handles QUIC traffic on a network address.
+// It can accept inbound connections or create outbound ones.
+//
+// Multiple goroutines may invoke methods on an Endpoint simultaneously.
+type Endpoint struct {
+	pc       net.PacketConn
+	config   *Config // nil if the endpoint does not accept connections
+	readDone chan struct{}
+
+	mu            sync.Mutex
+	conns         map[string]*Conn // by local connection ID
+	connSet       map[*Conn]struct{}
+	pending       map[*Conn]struct{} // inbound conns not yet returned by Accept
+	acceptq       []*Conn
+	acceptChanged chan struct{}
+	closed        bool
+	closec        chan struct{}
+}
+
+// Listen listens on a local network address.
+// If config is not nil, the endpoint accepts inbound connections.
+func Listen(network, address string, config *Config) (*Endpoint, error) {
+	pc, err := net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewEndpoint(pc, config), nil
+}
+
+// NewEndpoint returns an endpoint handling QUIC traffic on pc.
+// If config is not nil, the endpoint accepts inbound connections.
+// The endpoint takes ownership of pc, and closes it when the endpoint is closed.
+func NewEndpoint(pc net.PacketConn, config *Config) *Endpoint {
+	e := &Endpoint{
+		pc:            pc,
+		config:        config,
+		readDone:      make(chan struct{}),
+		conns:         make(map[string]*Conn),
+		connSet:       make(map[*Conn]struct{}),
+		pending:       make(map[*Conn]struct{}),
+		acceptChanged: make(chan struct{}),
+		closec:        make(chan struct{}),
+	}
+	go e.readLoop()
+	return e
+}
+
+// LocalAddr returns the local network address.
+func (e *Endpoint) LocalAddr() net.Addr {
+	return e.pc.LocalAddr()
+}
+
+// Close closes the endpoint. Open connections are closed with
+// transport error NO_ERROR, without waiting for the peer to
+// acknowledge the closure.
+//
+// Close waits for the endpoint's connections to finish sending
+// their final packets, or for ctx to be done.
+func (e *Endpoint) Close(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.closec)
+	}
+	conns := make([]*Conn, 0, len(e.connSet))
+	for c := range e.connSet {
+		conns = append(conns, c)
+	}
+	e.mu.Unlock()
+	now := time.Now()
+	for _, c := range conns {
+		c.mu.Lock()
+		c.closeLocked(now, errEndpointClosed, closeFrame{code: uint64(errNo)})
+		c.mu.Unlock()
+		c.wake()
+	}
+	var err error
+	for _, c := range conns {
+		select {
+		case <-c.donec:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+	}
+	if cerr := e.pc.Close(); err == nil {
+		err = cerr
+	}
+	<-e.readDone
+	return err
+}
+
+func (e *Endpoint) isClosed() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.closed
+}
+
+// Accept waits for and returns the next inbound connection.
+// The connection's handshake has completed.
+func (e *Endpoint) Accept(ctx context.Context) (*Conn, error) {
+	for {
+		e.mu.Lock()
+		if len(e.acceptq) > 0 {
+			c := e.acceptq[0]
+			e.acceptq[0] = nil
+			e.acceptq = e.acceptq[1:]
+			delete(e.pending, c)
+			e.mu.Unlock()
+			return c, nil
+		}
+		if e.closed {
+			e.mu.Unlock()
+			return nil, errEndpointClosed
+		}
+		ch := e.acceptChanged
+		e.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-e.closec:
+		}
+	}
+}
+
+// Dial creates an outbound connection to a remote address
+// and waits for its handshake to complete.
+func (e *Endpoint) Dial(ctx context.Context, network, address string, config *Config) (*Conn, error) {
+	addr, err := resolveUDPAddr(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if e.isClosed() {
+		return nil, errEndpointClosed
+	}
+	c, err := newConn(e, true, config, addr, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.waitHandshake(ctx); err != nil {
+		c.Abort(0, "")
+		return nil, err
+	}
+	return c, nil
+}
+
+func resolveUDPAddr(ctx context.Context, network, address string) (*net.UDPAddr, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := net.DefaultResolver.LookupPort(ctx, network, portStr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return &net.UDPAddr{IP: ip, Port: port}, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	want4 := network == "udp4"
+	for _, a := range addrs {
+		if is4 := a.IP.To4() != nil; network == "udp" || is4 == want4 {
+			return &net.UDPAddr{IP: a.IP, Port: port, Zone: a.Zone}, nil
+		}
+	}
+	return nil, &net.AddrError{Err: "no suitable address", Addr: net.JoinHostPort(host, strconv.Itoa(port))}
+}
+
+// addConn registers a new connection with the endpoint.
+func (e *Endpoint) addConn(c *Conn) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return errEndpointClosed
+	}
+	e.conns[string(c.localConnID)] = c
+	if c.isServer() {
+		e.conns[string(c.origDstConnID)] = c
+		e.pending[c] = struct{}{}
+	}
+	e.connSet[c] = struct{}{}
+	return nil
+}
+
+// removeConn removes a closed connection from the endpoint.
+func (e *Endpoint) removeConn(c *Conn) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.conns, string(c.localConnID))
+	if c.isServer() && e.conns[string(c.origDstConnID)] == c {
+		delete(e.conns, string(c.origDstConnID))
+	}
+	delete(e.connSet, c)
+	delete(e.pending, c)
+}
+
+// queueAccept adds a connection to the accept queue.
+func (e *Endpoint) queueAccept(c *Conn) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.acceptq = append(e.acceptq, c)
+	close(e.acceptChanged)
+	e.acceptChanged = make(chan struct{})
+}
+
+func (e *Endpoint) writeTo(b []byte, addr net.Addr) {
+	// Errors are ignored: sending a datagram is best effort,
+	// and lost datagrams are handled by loss recovery.
+	e.pc.WriteTo(b, addr)
+}
+
+func (e *Endpoint) readLoop() {
+	defer close(e.readDone)
+	buf := make([]byte, 65536)
+	var tempDelay time.Duration // how long to sleep on read errors
+	for {
+		n, addr, err := e.pc.ReadFrom(buf)
+		if err != nil {
+			if e.isClosed() || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// Other errors, such as an ICMP port unreachable
+			// reported for an earlier datagram, are transient.
+			// Back off so a persistent error does not spin.
+			if tempDelay == 0 {
+				tempDelay = 5 * time.Millisecond
+			} else {
+				tempDelay *= 2
+			}
+			if max := 1 * time.Second; tempDelay > max {
+				tempDelay = max
+			}
+			t := time.NewTimer(tempDelay)
+			select {
+			case <-t.C:
+			case <-e.closec:
+				t.Stop()
+				return
+			}
+			continue
+		}
+		tempDelay = 0
+		e.handleDatagram(append([]byte{}, buf[:n]...), addr)
+	}
+}
+
+// handleDatagram delivers a datagram to its connection,
+// creating a new connection if it is a client's first Initial packet.
+func (e *Endpoint) handleDatagram(b []byte, addr net.Addr) {
+	id := dstConnIDForDatagram(b)
+	if id == nil {
+		return
+	}
+	e.mu.Lock()
+	c := e.conns[string(id)]
+	accept := e.config != nil && !e.closed
+	if accept && len(e.pending) >= e.config.maxPendingConns() {
+		// Too many connections are handshaking or waiting
+		// for Accept. Drop the Initial; the client will retry.
+		accept = false
+	}
+	e.mu.Unlock()
+	if c != nil {
+		c.deliver(b)
+		return
+	}
+	if !accept || len(b) < maxUDPPayloadSize {
+		// Datagrams containing a client's Initial packet
+		// are padded to at least 1200 bytes.
+		return
+	}
+	p, n := parseLongHeaderPacket(b)
+	if n < 0 || p.version != quicVersion1 || p.ptype != packetTypeInitial || len(p.dstConnID) < 8 {
+		return
+	}
+	c, err := newConn(e, false, e.config, addr, p.dstConnID, p.srcConnID)
+	if err != nil {
+		return
+	}
+	c.deliver(b)
+}
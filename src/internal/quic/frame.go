@@ -0,0 +1,298 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+// Frame types. See RFC 9000, Section 19.
+const (
+	frameTypePadding                    = 0x00
+	frameTypePing                       = 0x01
+	frameTypeAck                        = 0x02
+	frameTypeAckECN                     = 0x03
+	frameTypeResetStream                = 0x04
+	frameTypeStopSending                = 0x05
+	frameTypeCrypto                     = 0x06
+	frameTypeNewToken                   = 0x07
+	frameTypeStreamBase                 = 0x08 // low three bits carry stream flags
+	frameTypeMaxData                    = 0x10
+	frameTypeMaxStreamData              = 0x11
+	frameTypeMaxStreamsBidi             = 0x12
+	frameTypeMaxStreamsUni              = 0x13
+	frameTypeDataBlocked                = 0x14
+	frameTypeStreamDataBlocked          = 0x15
+	frameTypeStreamsBlockedBidi         = 0x16
+	frameTypeStreamsBlockedUni          = 0x17
+	frameTypeNewConnectionID            = 0x18
+	frameTypeRetireConnectionID         = 0x19
+	frameTypePathChallenge              = 0x1a
+	frameTypePathResponse               = 0x1b
+	frameTypeConnectionCloseTransport   = 0x1c
+	frameTypeConnectionCloseApplication = 0x1d
+	frameTypeHandshakeDone              = 0x1e
+)
+
+// Flags in the low bits of STREAM frame types.
+const (
+	streamOffBit = 0x04
+	streamLenBit = 0x02
+	streamFinBit = 0x01
+)
+
+// maxAckRanges is the maximum number of ranges sent in an ACK frame.
+const maxAckRanges = 32
+
+// appendAckFrame appends an ACK frame acknowledging the packet numbers
+// in seen, as long as it fits within avail bytes. It returns b unchanged
+// if no ACK frame fits.
+func appendAckFrame(b []byte, seen rangeset, delay uint64, avail int) []byte {
+	if len(seen) == 0 {
+		return b
+	}
+	i := len(seen) - 1
+	largest := uint64(seen[i].end - 1)
+	first := uint64(seen[i].end - 1 - seen[i].start)
+	count := len(seen) - 1
+	if count > maxAckRanges-1 {
+		count = maxAckRanges - 1
+	}
+	// Count the ranges that fit before writing the frame.
+	// Sizing the range count field by the maximum count
+	// can only overestimate the frame size.
+	size := 1 + SizeVarint(largest) + SizeVarint(delay) + SizeVarint(uint64(count)) + SizeVarint(first)
+	if size > avail {
+		return b
+	}
+	n := 0
+	for j := i - 1; j >= 0 && n < count; j-- {
+		gap := uint64(seen[j+1].start - seen[j].end - 1)
+		length := uint64(seen[j].end - 1 - seen[j].start)
+		s := SizeVarint(gap) + SizeVarint(length)
+		if size+s > avail {
+			break
+		}
+		size += s
+		n++
+	}
+	b = append(b, frameTypeAck)
+	b = AppendVarint(b, largest)
+	b = AppendVarint(b, delay)
+	b = AppendVarint(b, uint64(n))
+	b = AppendVarint(b, first)
+	for j := i - 1; j >= i-n; j-- {
+		b = AppendVarint(b, uint64(seen[j+1].start-seen[j].end-1))
+		b = AppendVarint(b, uint64(seen[j].end-1-seen[j].start))
+	}
+	return b
+}
+
+// consumeAckFrame parses an ACK frame, calling f for each acknowledged
+// range of packet numbers [start, end) from largest to smallest.
+// It returns the ACK delay field and the number of bytes consumed,
+// or n < 0 if the frame is malformed.
+func consumeAckFrame(b []byte, f func(start, end int64)) (largest int64, delay uint64, n int) {
+	typ := b[0]
+	n = 1
+	l, m := consumeVarintInt64(b[n:])
+	if m < 0 {
+		return 0, 0, -1
+	}
+	n += m
+	delay, m = ConsumeVarint(b[n:])
+	if m < 0 {
+		return 0, 0, -1
+	}
+	n += m
+	count, m := ConsumeVarint(b[n:])
+	if m < 0 {
+		return 0, 0, -1
+	}
+	n += m
+	first, m := consumeVarintInt64(b[n:])
+	if m < 0 || first > l {
+		return 0, 0, -1
+	}
+	n += m
+	smallest := l - first
+	f(smallest, l+1)
+	for i := uint64(0); i < count; i++ {
+		gap, m := consumeVarintInt64(b[n:])
+		if m < 0 {
+			return 0, 0, -1
+		}
+		n += m
+		length, m := consumeVarintInt64(b[n:])
+		if m < 0 {
+			return 0, 0, -1
+		}
+		n += m
+		hi := smallest - gap - 2
+		if hi < 0 || length > hi {
+			return 0, 0, -1
+		}
+		smallest = hi - length
+		f(smallest, hi+1)
+	}
+	if typ == frameTypeAckECN {
+		for i := 0; i < 3; i++ {
+			_, m := ConsumeVarint(b[n:])
+			if m < 0 {
+				return 0, 0, -1
+			}
+			n += m
+		}
+	}
+	return l, delay, n
+}
+
+// consumeVarints parses len(v) consecutive varints following a one-byte
+// frame type. It returns the number of bytes consumed, or -1.
+func consumeVarints(b []byte, v ...*uint64) int {
+	n := 1
+	for _, p := range v {
+		x, m := ConsumeVarint(b[n:])
+		if m < 0 {
+			return -1
+		}
+		*p = x
+		n += m
+	}
+	return n
+}
+
+// streamFrameHeaderSize returns the size of a STREAM frame header.
+func streamFrameHeaderSize(id, off int64, size int) int {
+	n := 1 + SizeVarint(uint64(id)) + SizeVarint(uint64(size))
+	if off != 0 {
+		n += SizeVarint(uint64(off))
+	}
+	return n
+}
+
+func appendStreamFrame(b []byte, id, off int64, data []byte, fin bool) []byte {
+	typ := byte(frameTypeStreamBase | streamLenBit)
+	if off != 0 {
+		typ |= streamOffBit
+	}
+	if fin {
+		typ |= streamFinBit
+	}
+	b = append(b, typ)
+	b = AppendVarint(b, uint64(id))
+	if off != 0 {
+		b = AppendVarint(b, uint64(off))
+	}
+	b = AppendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// consumeStreamFrame parses a STREAM frame.
+func consumeStreamFrame(b []byte) (id, off int64, fin bool, data []byte, n int) {
+	typ := b[0]
+	n = 1
+	id, m := consumeVarintInt64(b[n:])
+	if m < 0 {
+		return 0, 0, false, nil, -1
+	}
+	n += m
+	if typ&streamOffBit != 0 {
+		off, m = consumeVarintInt64(b[n:])
+		if m < 0 {
+			return 0, 0, false, nil, -1
+		}
+		n += m
+	}
+	if typ&streamLenBit != 0 {
+		data, m = consumeVarintBytes(b[n:])
+		if m < 0 {
+			return 0, 0, false, nil, -1
+		}
+		n += m
+	} else {
+		data = b[n:]
+		n = len(b)
+	}
+	if off+int64(len(data)) > maxVarint {
+		return 0, 0, false, nil, -1
+	}
+	return id, off, typ&streamFinBit != 0, data, n
+}
+
+func cryptoFrameHeaderSize(off int64, size int) int {
+	return 1 + SizeVarint(uint64(off)) + SizeVarint(uint64(size))
+}
+
+func appendCryptoFrame(b []byte, off int64, data []byte) []byte {
+	b = append(b, frameTypeCrypto)
+	b = AppendVarint(b, uint64(off))
+	return appendVarintBytes(b, data)
+}
+
+func consumeCryptoFrame(b []byte) (off int64, data []byte, n int) {
+	n = 1
+	off, m := consumeVarintInt64(b[n:])
+	if m < 0 {
+		return 0, nil, -1
+	}
+	n += m
+	data, m = consumeVarintBytes(b[n:])
+	if m < 0 {
+		return 0, nil, -1
+	}
+	n += m
+	return off, data, n
+}
+
+func appendConnectionCloseFrame(b []byte, app bool, code uint64, reason string, avail int) []byte {
+	// Truncate the reason to fit in the packet.
+	max := avail - 1 - SizeVarint(code) - 1 - 2
+	if max < 0 {
+		max = 0
+	}
+	if len(reason) > max {
+		reason = reason[:max]
+	}
+	if app {
+		b = append(b, frameTypeConnectionCloseApplication)
+		b = AppendVarint(b, code)
+	} else {
+		b = append(b, frameTypeConnectionCloseTransport)
+		b = AppendVarint(b, code)
+		b = AppendVarint(b, 0) // frame type
+	}
+	return appendVarintBytes(b, []byte(reason))
+}
+
+func consumeConnectionCloseFrame(b []byte) (app bool, code uint64, reason string, n int) {
+	app = b[0] == frameTypeConnectionCloseApplication
+	n = 1
+	code, m := ConsumeVarint(b[n:])
+	if m < 0 {
+		return false, 0, "", -1
+	}
+	n += m
+	if !app {
+		_, m = ConsumeVarint(b[n:]) // frame type
+		if m < 0 {
+			return false, 0, "", -1
+		}
+		n += m
+	}
+	r, m := consumeVarintBytes(b[n:])
+	if m < 0 {
+		return false, 0, "", -1
+	}
+	n += m
+	return app, code, string(r), n
+}
+
+// isAckEliciting reports whether a frame type elicits an acknowledgement.
+// See RFC 9002, Section 2.
+func isAckEliciting(typ uint64) bool {
+	switch typ {
+	case frameTypePadding, frameTypeAck, frameTypeAckECN,
+		frameTypeConnectionCloseTransport, frameTypeConnectionCloseApplication:
+		return false
+	}
+	return true
+}
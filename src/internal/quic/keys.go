@@ -0,0 +1,261 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// initialSalt is the salt used to derive Initial packet protection keys.
+// See RFC 9001, Section 5.2.
+var initialSalt = []byte{0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17, 0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a}
+
+// aeadTagLen is the size of the authentication tag of all QUIC AEADs.
+const aeadTagLen = 16
+
+// headerProtectionSampleLen is the size of the ciphertext sample
+// used to compute the header protection mask.
+const headerProtectionSampleLen = 16
+
+// errDecrypt is returned when a packet fails authentication.
+var errDecrypt = errors.New("quic: packet decryption failed")
+
+// keys holds the packet protection keys for one direction
+// at one encryption level.
+type keys struct {
+	aead cipher.AEAD
+	iv   []byte
+	hp   headerProtection
+}
+
+// isSet reports whether k has been initialized.
+func (k *keys) isSet() bool {
+	return k.aead != nil
+}
+
+// discard zeroes k, making it unusable.
+func (k *keys) discard() {
+	*k = keys{}
+}
+
+// A headerProtection computes the header protection mask
+// from a ciphertext sample (RFC 9001, Section 5.4).
+type headerProtection interface {
+	mask(sample []byte) [5]byte
+}
+
+type aesHeaderProtection struct {
+	block cipher.Block
+}
+
+func (hp aesHeaderProtection) mask(sample []byte) (m [5]byte) {
+	var out [aes.BlockSize]byte
+	hp.block.Encrypt(out[:], sample)
+	copy(m[:], out[:])
+	return m
+}
+
+type chachaHeaderProtection struct {
+	key []byte
+}
+
+func (hp chachaHeaderProtection) mask(sample []byte) (m [5]byte) {
+	c, err := chacha20.NewUnauthenticatedCipher(hp.key, sample[4:16])
+	if err != nil {
+		panic(err)
+	}
+	c.SetCounter(binary.LittleEndian.Uint32(sample[:4]))
+	c.XORKeyStream(m[:], m[:])
+	return m
+}
+
+// suiteHash returns the hash function used by a TLS 1.3 cipher suite.
+func suiteHash(suite uint16) crypto.Hash {
+	if suite == tls.TLS_AES_256_GCM_SHA384 {
+		return crypto.SHA384
+	}
+	return crypto.SHA256
+}
+
+func hashFunc(h crypto.Hash) func() hash.Hash {
+	if h == crypto.SHA384 {
+		return sha512.New384
+	}
+	return sha256.New
+}
+
+// newKeys derives packet protection keys from a TLS traffic secret.
+// See RFC 9001, Section 5.1.
+func newKeys(suite uint16, secret []byte) (keys, error) {
+	h := hashFunc(suiteHash(suite))
+	switch suite {
+	case tls.TLS_AES_128_GCM_SHA256, tls.TLS_AES_256_GCM_SHA384:
+		keyLen := 16
+		if suite == tls.TLS_AES_256_GCM_SHA384 {
+			keyLen = 32
+		}
+		key := hkdfExpandLabel(h, secret, "quic key", keyLen)
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return keys{}, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return keys{}, err
+		}
+		hpBlock, err := aes.NewCipher(hkdfExpandLabel(h, secret, "quic hp", keyLen))
+		if err != nil {
+			return keys{}, err
+		}
+		return keys{
+			aead: aead,
+			iv:   hkdfExpandLabel(h, secret, "quic iv", aead.NonceSize()),
+			hp:   aesHeaderProtection{hpBlock},
+		}, nil
+	case tls.TLS_CHACHA20_POLY1305_SHA256:
+		key := hkdfExpandLabel(h, secret, "quic key", chacha20poly1305.KeySize)
+		aead, err := chacha20poly1305.New(key)
+		if err != nil {
+			return keys{}, err
+		}
+		return keys{
+			aead: aead,
+			iv:   hkdfExpandLabel(h, secret, "quic iv", aead.NonceSize()),
+			hp:   chachaHeaderProtection{hkdfExpandLabel(h, secret, "quic hp", chacha20.KeySize)},
+		}, nil
+	}
+	return keys{}, fmt.Errorf("quic: unsupported cipher suite %v", tls.CipherSuiteName(suite))
+}
+
+// initialKeys returns the Initial packet protection keys
+// derived from the client's first destination connection ID.
+// See RFC 9001, Section 5.2.
+func initialKeys(cid []byte, isClient bool) (read, write keys) {
+	initialSecret := hkdf.Extract(sha256.New, cid, initialSalt)
+	clientSecret := hkdfExpandLabel(sha256.New, initialSecret, "client in", sha256.Size)
+	serverSecret := hkdfExpandLabel(sha256.New, initialSecret, "server in", sha256.Size)
+	clientKeys, err := newKeys(tls.TLS_AES_128_GCM_SHA256, clientSecret)
+	if err != nil {
+		panic(err)
+	}
+	serverKeys, err := newKeys(tls.TLS_AES_128_GCM_SHA256, serverSecret)
+	if err != nil {
+		panic(err)
+	}
+	if isClient {
+		return serverKeys, clientKeys
+	}
+	return clientKeys, serverKeys
+}
+
+// hkdfExpandLabel implements HKDF-Expand-Label from RFC 8446, Section 7.1,
+// with an empty context.
+func hkdfExpandLabel(h func() hash.Hash, secret []byte, label string, length int) []byte {
+	const prefix = "tls13 "
+	info := make([]byte, 0, 2+1+len(prefix)+len(label)+1)
+	info = append(info, byte(length>>8), byte(length))
+	info = append(info, byte(len(prefix)+len(label)))
+	info = append(info, prefix...)
+	info = append(info, label...)
+	info = append(info, 0) // context length
+	out := make([]byte, length)
+	if _, err := hkdf.Expand(h, secret, info).Read(out); err != nil {
+		panic("quic: HKDF-Expand-Label invocation failed unexpectedly")
+	}
+	return out
+}
+
+func (k *keys) nonce(pnum int64) []byte {
+	nonce := make([]byte, len(k.iv))
+	copy(nonce, k.iv)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(pnum >> (8 * i))
+	}
+	return nonce
+}
+
+// protect applies packet protection to pkt, which holds a complete
+// plaintext packet with a packet number of pnumLen bytes at pnumOff,
+// followed by aeadTagLen bytes of space for the AEAD tag.
+// The packet is protected in place.
+func (k *keys) protect(pkt []byte, pnumOff, pnumLen int, pnum int64) {
+	hdr := pkt[:pnumOff+pnumLen]
+	payload := pkt[len(hdr) : len(pkt)-aeadTagLen]
+	k.aead.Seal(payload[:0], k.nonce(pnum), payload, hdr)
+	k.applyHeaderProtection(pkt, pnumOff, pnumLen)
+}
+
+func (k *keys) applyHeaderProtection(pkt []byte, pnumOff, pnumLen int) {
+	sampleOff := pnumOff + 4
+	m := k.hp.mask(pkt[sampleOff:][:headerProtectionSampleLen])
+	if isLongHeader(pkt[0]) {
+		pkt[0] ^= m[0] & 0x0f
+	} else {
+		pkt[0] ^= m[0] & 0x1f
+	}
+	for i := 0; i < pnumLen; i++ {
+		pkt[pnumOff+i] ^= m[1+i]
+	}
+}
+
+// unprotect removes packet protection from pkt in place.
+// The packet number starts at pnumOff; largest is the largest
+// packet number received so far in the packet number space.
+// It returns the decoded packet number and the plaintext payload.
+func (k *keys) unprotect(pkt []byte, pnumOff int, largest int64) (pnum int64, payload []byte, err error) {
+	sampleOff := pnumOff + 4
+	if len(pkt) < sampleOff+headerProtectionSampleLen {
+		return 0, nil, errDecrypt
+	}
+	m := k.hp.mask(pkt[sampleOff:][:headerProtectionSampleLen])
+	if isLongHeader(pkt[0]) {
+		pkt[0] ^= m[0] & 0x0f
+	} else {
+		pkt[0] ^= m[0] & 0x1f
+	}
+	pnumLen := int(pkt[0]&0x03) + 1
+	var truncated int64
+	for i := 0; i < pnumLen; i++ {
+		pkt[pnumOff+i] ^= m[1+i]
+		truncated = truncated<<8 | int64(pkt[pnumOff+i])
+	}
+	pnum = decodePacketNumber(largest, truncated, pnumLen)
+	hdr := pkt[:pnumOff+pnumLen]
+	payload, err = k.aead.Open(pkt[len(hdr):len(hdr)], k.nonce(pnum), pkt[len(hdr):], hdr)
+	if err != nil {
+		return 0, nil, errDecrypt
+	}
+	return pnum, payload, nil
+}
+
+// decodePacketNumber decodes a truncated packet number.
+// See RFC 9000, Appendix A.3.
+func decodePacketNumber(largest, truncated int64, pnumLen int) int64 {
+	expected := largest + 1
+	win := int64(1) << (pnumLen * 8)
+	hwin := win / 2
+	mask := win - 1
+	candidate := (expected &^ mask) | truncated
+	if candidate <= expected-hwin && candidate < (1<<62)-win {
+		return candidate + win
+	}
+	if candidate > expected+hwin && candidate >= win {
+		return candidate - win
+	}
+	return candidate
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"testing"
+)
+
+// Test vectors from RFC 9001, Appendix A.1.
+func TestInitialKeys(t *testing.T) {
+	dcid := unhex("8394c8f03e515708")
+	clientSecret := unhex("c00cf151ca5be075ed0ebfb5c80323c42d6b7db67881289af4008f1f6c357aea")
+	if got := hkdfExpandLabel(sha256.New, clientSecret, "quic key", 16); !bytes.Equal(got, unhex("1f369613dd76d5467730efcbe3b1a22d")) {
+		t.Errorf("client key = %x", got)
+	}
+
+	server, client := initialKeys(dcid, true)
+	if want := unhex("fa044b2f42a3fd3b46fb255c"); !bytes.Equal(client.iv, want) {
+		t.Errorf("client iv = %x, want %x", client.iv, want)
+	}
+	if want := unhex("0ac1493ca1905853b0bba03e"); !bytes.Equal(server.iv, want) {
+		t.Errorf("server iv = %x, want %x", server.iv, want)
+	}
+	// Header protection sample and mask from RFC 9001, Appendix A.2.
+	m := client.hp.mask(unhex("d1b1c98dd7689fb8ec11d242b123dc9b"))
+	if want := unhex("437b9aec36"); !bytes.Equal(m[:], want) {
+		t.Errorf("client header protection mask = %x, want %x", m, want)
+	}
+
+	// The server's keys are the client's, reversed.
+	sread, swrite := initialKeys(dcid, false)
+	if !bytes.Equal(sread.iv, client.iv) || !bytes.Equal(swrite.iv, server.iv) {
+		t.Errorf("server and client initial keys do not match")
+	}
+}
+
+// Test vector from RFC 9001, Appendix A.5.
+func TestChaCha20ShortHeaderPacket(t *testing.T) {
+	secret := unhex("9ac312a7f877468ebe69422748ad00a15443f18203a07d6060f688f30f21632b")
+	k, err := newKeys(tls.TLS_CHACHA20_POLY1305_SHA256, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := unhex("e0459b3474bdd0e44a41c144"); !bytes.Equal(k.iv, want) {
+		t.Errorf("iv = %x, want %x", k.iv, want)
+	}
+	pkt := unhex("4cfe4189655e5cd55c41f69080575d7999c25a5bfb")
+	pnum, payload, err := k.unprotect(pkt, 1, 654360563)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pnum != 654360564 {
+		t.Errorf("packet number = %v, want 654360564", pnum)
+	}
+	if !bytes.Equal(payload, []byte{frameTypePing}) {
+		t.Errorf("payload = %x, want 01", payload)
+	}
+	if want := unhex("4200bff4"); !bytes.Equal(pkt[:4], want) {
+		t.Errorf("unprotected header = %x, want %x", pkt[:4], want)
+	}
+}
+
+func TestProtectRoundTrip(t *testing.T) {
+	_, write := initialKeys(unhex("0001020304050607"), false)
+	cread, _ := initialKeys(unhex("0001020304050607"), true)
+	w := newPacketWriter()
+	dcid := unhex("1112131415161718")
+	if !w.start1RTTPacket(&write, 1000, dcid) {
+		t.Fatal("start1RTTPacket failed")
+	}
+	w.b = append(w.b, "payload"...)
+	b := w.finish()
+	pnum, payload, err := cread.unprotect(b, 1+len(dcid), 998)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pnum != 1000 || string(payload) != "payload" {
+		t.Errorf("unprotect = %v, %q; want 1000, %q", pnum, payload, "payload")
+	}
+}
+
+func TestDecodePacketNumber(t *testing.T) {
+	// Example from RFC 9000, Appendix A.3.
+	if got := decodePacketNumber(0xa82f30ea, 0x9b32, 2); got != 0xa82f9b32 {
+		t.Errorf("decodePacketNumber(0xa82f30ea, 0x9b32, 2) = %x, want a82f9b32", got)
+	}
+	if got := decodePacketNumber(-1, 0, 4); got != 0 {
+		t.Errorf("decodePacketNumber(-1, 0, 4) = %v, want 0", got)
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import "encoding/binary"
+
+// A packetType is a QUIC packet type.
+type packetType byte
+
+const (
+	packetTypeInvalid packetType = iota
+	packetTypeInitial
+	packetType0RTT
+	packetTypeHandshake
+	packetTypeRetry
+	packetType1RTT
+	packetTypeVersionNegotiation
+)
+
+// Bits in the first byte of a packet.
+const (
+	headerFormLong    = 0x80
+	fixedBit          = 0x40
+	reservedLongBits  = 0x0c
+	reservedShortBits = 0x18
+)
+
+// Long packet type bits. See RFC 9000, Section 17.2.
+const (
+	longPacketTypeInitial   = 0 << 4
+	longPacketType0RTT      = 1 << 4
+	longPacketTypeHandshake = 2 << 4
+	longPacketTypeRetry     = 3 << 4
+)
+
+// packetNumberLen is the length of the packet numbers this package sends.
+const packetNumberLen = 4
+
+// packetLengthLen is the length of the Length field of the long header
+// packets this package sends. Two bytes is enough for any datagram.
+const packetLengthLen = 2
+
+func isLongHeader(b byte) bool {
+	return b&headerFormLong != 0
+}
+
+// A numberSpace is a packet number space. See RFC 9000, Section 12.3.
+type numberSpace int
+
+const (
+	initialSpace numberSpace = iota
+	handshakeSpace
+	appDataSpace
+	numberSpaceCount
+)
+
+func (s numberSpace) String() string {
+	switch s {
+	case initialSpace:
+		return "Initial"
+	case handshakeSpace:
+		return "Handshake"
+	case appDataSpace:
+		return "AppData"
+	}
+	return "unknown"
+}
+
+// A longPacket is a parsed long header packet,
+// prior to removal of header protection.
+type longPacket struct {
+	ptype     packetType
+	version   uint32
+	dstConnID []byte
+	srcConnID []byte
+	token     []byte // Initial packets only
+
+	// pkt is the complete packet, and pnumOff is the offset
+	// of the (protected) packet number within it.
+	pkt     []byte
+	pnumOff int
+}
+
+// parseLongHeaderPacket parses the long header packet at the start of b.
+// It returns the packet and the number of bytes it occupies,
+// or n < 0 if the packet is malformed.
+func parseLongHeaderPacket(b []byte) (p longPacket, n int) {
+	if len(b) < 7 || !isLongHeader(b[0]) {
+		return p, -1
+	}
+	p.version = binary.BigEndian.Uint32(b[1:5])
+	n = 5
+	var m int
+	p.dstConnID, m = consumeUint8Bytes(b[n:])
+	if m < 0 || len(p.dstConnID) > maxConnIDLen {
+		return p, -1
+	}
+	n += m
+	p.srcConnID, m = consumeUint8Bytes(b[n:])
+	if m < 0 || len(p.srcConnID) > maxConnIDLen {
+		return p, -1
+	}
+	n += m
+	if p.version == 0 {
+		p.ptype = packetTypeVersionNegotiation
+		p.pkt = b
+		return p, len(b)
+	}
+	switch b[0] & 0x30 {
+	case longPacketTypeInitial:
+		p.ptype = packetTypeInitial
+		p.token, m = consumeVarintBytes(b[n:])
+		if m < 0 {
+			return p, -1
+		}
+		n += m
+	case longPacketType0RTT:
+		p.ptype = packetType0RTT
+	case longPacketTypeHandshake:
+		p.ptype = packetTypeHandshake
+	case longPacketTypeRetry:
+		p.ptype = packetTypeRetry
+		p.pkt = b
+		return p, len(b)
+	}
+	length, m := ConsumeVarint(b[n:])
+	if m < 0 || length > uint64(len(b)-n-m) {
+		return p, -1
+	}
+	n += m
+	p.pnumOff = n
+	n += int(length)
+	p.pkt = b[:n]
+	return p, n
+}
+
+// dstConnIDForDatagram returns the destination connection ID of the
+// first packet in a datagram, assuming short header packets carry
+// connection IDs of length connIDLen.
+func dstConnIDForDatagram(b []byte) []byte {
+	if len(b) < 1 {
+		return nil
+	}
+	if isLongHeader(b[0]) {
+		if len(b) < 6 {
+			return nil
+		}
+		id, n := consumeUint8Bytes(b[5:])
+		if n < 0 {
+			return nil
+		}
+		return id
+	}
+	if len(b) < 1+connIDLen {
+		return nil
+	}
+	return b[1 : 1+connIDLen]
+}
+
+// A packetWriter assembles the packets in a datagram.
+//
+// Packets are written in plaintext, leaving room for the AEAD tag
+// after each packet's payload, and are protected by finish.
+// The last packet in the datagram remains open, so that padding
+// may be added to it.
+type packetWriter struct {
+	b    []byte
+	pkts []pendingPacket
+	open bool // the last packet in pkts may still be extended
+}
+
+// A pendingPacket is a packet in a packetWriter awaiting protection.
+type pendingPacket struct {
+	start      int
+	lenOff     int // offset of the Length field, or -1 for 1-RTT packets
+	pnumOff    int
+	payloadOff int
+	end        int // end of the packet, including the AEAD tag
+	pnum       int64
+	k          *keys
+}
+
+func newPacketWriter() *packetWriter {
+	return &packetWriter{
+		b: make([]byte, 0, maxUDPPayloadSize),
+	}
+}
+
+// avail returns the number of payload bytes remaining
+// in the current packet.
+func (w *packetWriter) avail() int {
+	n := maxUDPPayloadSize - len(w.b) - aeadTagLen
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// payloadLen returns the size of the payload written to the current packet.
+func (w *packetWriter) payloadLen() int {
+	if !w.open {
+		return 0
+	}
+	return len(w.b) - w.pkts[len(w.pkts)-1].payloadOff
+}
+
+// startLongPacket begins a new Initial or Handshake packet.
+// It reports false if there is no room for a packet.
+func (w *packetWriter) startLongPacket(ptype packetType, k *keys, pnum int64, dstConnID, srcConnID []byte) bool {
+	w.closePacket()
+	hdrLen := 1 + 4 + 1 + len(dstConnID) + 1 + len(srcConnID) + packetLengthLen + packetNumberLen
+	if ptype == packetTypeInitial {
+		hdrLen++ // empty token
+	}
+	if len(w.b)+hdrLen+aeadTagLen+headerProtectionSampleLen > maxUDPPayloadSize {
+		return false
+	}
+	p := pendingPacket{start: len(w.b), pnum: pnum, k: k}
+	first := byte(headerFormLong | fixedBit | (packetNumberLen - 1))
+	if ptype == packetTypeHandshake {
+		first |= longPacketTypeHandshake
+	}
+	w.b = append(w.b, first)
+	w.b = append(w.b, 0, 0, 0, quicVersion1)
+	w.b = append(w.b, byte(len(dstConnID)))
+	w.b = append(w.b, dstConnID...)
+	w.b = append(w.b, byte(len(srcConnID)))
+	w.b = append(w.b, srcConnID...)
+	if ptype == packetTypeInitial {
+		w.b = append(w.b, 0) // token length
+	}
+	p.lenOff = len(w.b)
+	w.b = append(w.b, 0, 0)
+	p.pnumOff = len(w.b)
+	w.b = append(w.b, byte(pnum>>24), byte(pnum>>16), byte(pnum>>8), byte(pnum))
+	p.payloadOff = len(w.b)
+	w.pkts = append(w.pkts, p)
+	w.open = true
+	return true
+}
+
+// start1RTTPacket begins a new 1-RTT packet.
+// It reports false if there is no room for a packet.
+func (w *packetWriter) start1RTTPacket(k *keys, pnum int64, dstConnID []byte) bool {
+	w.closePacket()
+	hdrLen := 1 + len(dstConnID) + packetNumberLen
+	if len(w.b)+hdrLen+aeadTagLen+headerProtectionSampleLen > maxUDPPayloadSize {
+		return false
+	}
+	p := pendingPacket{start: len(w.b), lenOff: -1, pnum: pnum, k: k}
+	w.b = append(w.b, fixedBit|(packetNumberLen-1))
+	w.b = append(w.b, dstConnID...)
+	p.pnumOff = len(w.b)
+	w.b = append(w.b, byte(pnum>>24), byte(pnum>>16), byte(pnum>>8), byte(pnum))
+	p.payloadOff = len(w.b)
+	w.pkts = append(w.pkts, p)
+	w.open = true
+	return true
+}
+
+// abandonPacket discards the current packet.
+// The previous packet in the datagram, if any, is reopened.
+func (w *packetWriter) abandonPacket() {
+	if !w.open {
+		return
+	}
+	p := w.pkts[len(w.pkts)-1]
+	w.b = w.b[:p.start]
+	w.pkts = w.pkts[:len(w.pkts)-1]
+	w.open = false
+	if len(w.pkts) > 0 {
+		// Remove the space reserved for the previous packet's tag.
+		w.b = w.b[:len(w.b)-aeadTagLen]
+		w.open = true
+	}
+}
+
+// tryAppend calls f to append a frame to the current packet,
+// and removes the frame if it does not fit.
+func (w *packetWriter) tryAppend(f func(b []byte) []byte) bool {
+	avail := w.avail()
+	n := len(w.b)
+	w.b = f(w.b)
+	if len(w.b)-n > avail {
+		w.b = w.b[:n]
+		return false
+	}
+	return true
+}
+
+// closePacket reserves space for the AEAD tag of the current packet
+// and fills in its Length field. The packet may no longer be extended.
+func (w *packetWriter) closePacket() {
+	if !w.open {
+		return
+	}
+	w.open = false
+	p := &w.pkts[len(w.pkts)-1]
+	w.b = append(w.b, make([]byte, aeadTagLen)...)
+	p.end = len(w.b)
+	if p.lenOff >= 0 {
+		length := p.end - p.pnumOff
+		w.b[p.lenOff] = 0x40 | byte(length>>8)
+		w.b[p.lenOff+1] = byte(length)
+	}
+}
+
+// padTo adds PADDING frames to the current packet until the
+// datagram is at least n bytes long.
+func (w *packetWriter) padTo(n int) {
+	if !w.open {
+		return
+	}
+	for len(w.b)+aeadTagLen < n {
+		w.b = append(w.b, frameTypePadding)
+	}
+}
+
+// finish protects all packets and returns the datagram.
+func (w *packetWriter) finish() []byte {
+	w.closePacket()
+	for _, p := range w.pkts {
+		pkt := w.b[p.start:p.end]
+		p.k.protect(pkt, p.pnumOff-p.start, packetNumberLen, p.pnum)
+	}
+	return w.b
+}
@@ -0,0 +1,253 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package quic implements the QUIC transport protocol (RFC 9000)
+// on top of a net.PacketConn, using crypto/tls for the handshake
+// (RFC 9001). It is used by net/http to implement HTTP/3 and has
+// no stable API exposed to end users.
+//
+// Only QUIC version 1 is supported. The implementation does not
+// support 0-RTT, Retry, connection migration, or key updates.
+package quic
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// quicVersion1 is the only QUIC version this package supports.
+const quicVersion1 = 1
+
+// connIDLen is the length of the connection IDs chosen by this package.
+// Peers may choose connection IDs of any length up to maxConnIDLen.
+const (
+	connIDLen    = 8
+	maxConnIDLen = 20
+)
+
+// maxUDPPayloadSize is the largest datagram this package sends.
+// RFC 9000, Section 14 requires every network path to support
+// datagrams of at least this size, so no path MTU discovery is done.
+const maxUDPPayloadSize = 1200
+
+// Default values for Config fields.
+const (
+	defaultMaxIdleTimeout          = 30 * time.Second
+	defaultMaxRemoteStreams        = 100
+	defaultMaxStreamReadBufferSize = 1 << 20
+	defaultMaxConnReadBufferSize   = 4 << 20
+	defaultHandshakeTimeout        = 10 * time.Second
+	defaultMaxPendingConns         = 256
+)
+
+// A Config configures a QUIC endpoint or connection.
+// A Config must not be modified after it has been passed to a QUIC function.
+type Config struct {
+	// TLSConfig is the TLS configuration used for connections.
+	// It must be non-nil and should set NextProtos.
+	// The MinVersion is always raised to TLS 1.3.
+	TLSConfig *tls.Config
+
+	// MaxBidiRemoteStreams and MaxUniRemoteStreams limit the number of
+	// concurrent bidirectional and unidirectional streams a peer may open.
+	// If zero, a default of 100 is used.
+	MaxBidiRemoteStreams int64
+	MaxUniRemoteStreams  int64
+
+	// MaxStreamReadBufferSize is the maximum amount of data received
+	// on a stream and not yet read by the application.
+	// If zero, a default of 1 MiB is used.
+	MaxStreamReadBufferSize int64
+
+	// MaxConnReadBufferSize is the maximum amount of data received
+	// on all streams of a connection and not yet read by the application.
+	// If zero, a default of 4 MiB is used.
+	MaxConnReadBufferSize int64
+
+	// MaxIdleTimeout is the time after which an idle connection is closed.
+	// If zero, a default of 30 seconds is used.
+	// If negative, connections are never closed for being idle.
+	MaxIdleTimeout time.Duration
+
+	// KeepAlivePeriod is how often to send keep-alive packets on an
+	// otherwise idle connection. If zero, no keep-alives are sent.
+	KeepAlivePeriod time.Duration
+
+	// HandshakeTimeout bounds the time taken by the handshake.
+	// If zero, a default of 10 seconds is used.
+	HandshakeTimeout time.Duration
+
+	// MaxPendingConns limits the number of inbound connections
+	// that are handshaking or waiting to be accepted.
+	// Initial packets for new connections past this limit are dropped.
+	// If zero, a default of 256 is used.
+	MaxPendingConns int
+}
+
+func (c *Config) maxBidiRemoteStreams() int64 {
+	if c.MaxBidiRemoteStreams > 0 {
+		return c.MaxBidiRemoteStreams
+	}
+	return defaultMaxRemoteStreams
+}
+
+func (c *Config) maxUniRemoteStreams() int64 {
+	if c.MaxUniRemoteStreams > 0 {
+		return c.MaxUniRemoteStreams
+	}
+	return defaultMaxRemoteStreams
+}
+
+func (c *Config) maxStreamReadBufferSize() int64 {
+	if c.MaxStreamReadBufferSize > 0 {
+		return c.MaxStreamReadBufferSize
+	}
+	return defaultMaxStreamReadBufferSize
+}
+
+func (c *Config) maxConnReadBufferSize() int64 {
+	if c.MaxConnReadBufferSize > 0 {
+		return c.MaxConnReadBufferSize
+	}
+	return defaultMaxConnReadBufferSize
+}
+
+func (c *Config) maxIdleTimeout() time.Duration {
+	switch {
+	case c.MaxIdleTimeout < 0:
+		return 0
+	case c.MaxIdleTimeout == 0:
+		return defaultMaxIdleTimeout
+	}
+	return c.MaxIdleTimeout
+}
+
+func (c *Config) handshakeTimeout() time.Duration {
+	if c.HandshakeTimeout > 0 {
+		return c.HandshakeTimeout
+	}
+	return defaultHandshakeTimeout
+}
+
+func (c *Config) maxPendingConns() int {
+	if c.MaxPendingConns > 0 {
+		return c.MaxPendingConns
+	}
+	return defaultMaxPendingConns
+}
+
+// A TransportError is a transport error code from RFC 9000, Section 20.1.
+type TransportError uint64
+
+const (
+	errNo                 = TransportError(0x00)
+	errInternal           = TransportError(0x01)
+	errConnectionRefused  = TransportError(0x02)
+	errFlowControl        = TransportError(0x03)
+	errStreamLimit        = TransportError(0x04)
+	errStreamState        = TransportError(0x05)
+	errFinalSize          = TransportError(0x06)
+	errFrameEncoding      = TransportError(0x07)
+	errTransportParameter = TransportError(0x08)
+	errConnectionIDLimit  = TransportError(0x09)
+	errProtocolViolation  = TransportError(0x0a)
+	errInvalidToken       = TransportError(0x0b)
+	errApplication        = TransportError(0x0c)
+	errCryptoBufExceeded  = TransportError(0x0d)
+	errKeyUpdate          = TransportError(0x0e)
+	errAEADLimit          = TransportError(0x0f)
+	errNoViablePath       = TransportError(0x10)
+	errTLSBase            = TransportError(0x0100) // 0x0100-0x01ff; base + TLS alert code
+)
+
+var transportErrorNames = [...]string{
+	errNo:                 "NO_ERROR",
+	errInternal:           "INTERNAL_ERROR",
+	errConnectionRefused:  "CONNECTION_REFUSED",
+	errFlowControl:        "FLOW_CONTROL_ERROR",
+	errStreamLimit:        "STREAM_LIMIT_ERROR",
+	errStreamState:        "STREAM_STATE_ERROR",
+	errFinalSize:          "FINAL_SIZE_ERROR",
+	errFrameEncoding:      "FRAME_ENCODING_ERROR",
+	errTransportParameter: "TRANSPORT_PARAMETER_ERROR",
+	errConnectionIDLimit:  "CONNECTION_ID_LIMIT_ERROR",
+	errProtocolViolation:  "PROTOCOL_VIOLATION",
+	errInvalidToken:       "INVALID_TOKEN",
+	errApplication:        "APPLICATION_ERROR",
+	errCryptoBufExceeded:  "CRYPTO_BUFFER_EXCEEDED",
+	errKeyUpdate:          "KEY_UPDATE_ERROR",
+	errAEADLimit:          "AEAD_LIMIT_REACHED",
+	errNoViablePath:       "NO_VIABLE_PATH",
+}
+
+func (e TransportError) Error() string {
+	if e < TransportError(len(transportErrorNames)) {
+		return "quic: " + transportErrorNames[e]
+	}
+	if e >= errTLSBase && e <= errTLSBase+0xff {
+		return fmt.Sprintf("quic: CRYPTO_ERROR(%v)", tls.AlertError(e-errTLSBase))
+	}
+	return fmt.Sprintf("quic: TransportError(%d)", uint64(e))
+}
+
+// A localTransportError is a transport error detected locally,
+// with a reason to send to the peer.
+type localTransportError struct {
+	code   TransportError
+	reason string
+}
+
+func (e *localTransportError) Error() string {
+	if e.reason == "" {
+		return e.code.Error()
+	}
+	return e.code.Error() + ": " + e.reason
+}
+
+func (e *localTransportError) Unwrap() error { return e.code }
+
+func protocolError(format string, args ...interface{}) error {
+	return &localTransportError{errProtocolViolation, fmt.Sprintf(format, args...)}
+}
+
+// A PeerTransportError is a transport error sent by the peer.
+type PeerTransportError struct {
+	Code   TransportError
+	Reason string
+}
+
+func (e *PeerTransportError) Error() string {
+	return fmt.Sprintf("peer closed connection: %v: %q", e.Code, e.Reason)
+}
+
+// An ApplicationError is an application protocol error code and reason,
+// sent by the peer or by the local application when closing a connection.
+type ApplicationError struct {
+	Code   uint64
+	Reason string
+}
+
+func (e *ApplicationError) Error() string {
+	return fmt.Sprintf("quic: application error 0x%x: %q", e.Code, e.Reason)
+}
+
+// A StreamErrorCode is an application protocol error code
+// indicating why a stream is being closed.
+// It is returned by stream operations after the peer resets
+// the stream or asks the local endpoint to stop sending.
+type StreamErrorCode uint64
+
+func (e StreamErrorCode) Error() string {
+	return fmt.Sprintf("quic: stream error code 0x%x", uint64(e))
+}
+
+var (
+	errConnClosed     = errors.New("quic: connection closed")
+	errIdleTimeout    = errors.New("quic: connection closed after idle timeout")
+	errHandshakeTimer = errors.New("quic: handshake timed out")
+	errEndpointClosed = errors.New("quic: endpoint closed")
+	errStreamClosed   = errors.New("quic: use of closed stream")
+)
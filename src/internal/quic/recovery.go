@@ -0,0 +1,415 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"math"
+	"time"
+)
+
+// Loss detection and congestion control constants.
+// See RFC 9002, Sections 6.1, 6.2, and 7.2.
+const (
+	packetThreshold  = 3
+	timerGranularity = 1 * time.Millisecond
+	initialRTT       = 333 * time.Millisecond
+	initialWindow    = 10 * maxUDPPayloadSize
+	minimumWindow    = 2 * maxUDPPayloadSize
+	maxPTOBackoff    = 16
+)
+
+// A sentPacket is an ack-eliciting packet awaiting acknowledgement.
+type sentPacket struct {
+	num    int64
+	time   time.Time
+	size   int
+	frames []sentFrame
+
+	// retransmitted is set when the packet's frames have been
+	// queued for retransmission after a probe timeout.
+	retransmitted bool
+}
+
+// A sentFrame records a frame sent in a packet,
+// with enough information to retransmit it.
+type sentFrame struct {
+	typ byte
+	id  int64 // stream ID
+	off int64 // STREAM and CRYPTO frames
+	n   int   // STREAM and CRYPTO frames
+	fin bool  // STREAM frames
+}
+
+// recovery holds the RTT estimate and congestion controller state.
+// The congestion controller is NewReno, as described in RFC 9002, Section 7.
+type recovery struct {
+	latestRTT   time.Duration
+	smoothedRTT time.Duration
+	rttVar      time.Duration
+	minRTT      time.Duration
+	hasSample   bool
+	ptoCount    int
+
+	cwnd          int
+	ssthresh      int
+	bytesInFlight int
+	recoveryStart time.Time
+}
+
+func (r *recovery) init() {
+	r.smoothedRTT = initialRTT
+	r.rttVar = initialRTT / 2
+	r.cwnd = initialWindow
+	r.ssthresh = math.MaxInt
+}
+
+// updateRTT updates the RTT estimate from a sample.
+// See RFC 9002, Section 5.3.
+func (r *recovery) updateRTT(latest, ackDelay time.Duration) {
+	r.latestRTT = latest
+	if !r.hasSample {
+		r.hasSample = true
+		r.minRTT = latest
+		r.smoothedRTT = latest
+		r.rttVar = latest / 2
+		return
+	}
+	if latest < r.minRTT {
+		r.minRTT = latest
+	}
+	adjusted := latest
+	if latest >= r.minRTT+ackDelay {
+		adjusted = latest - ackDelay
+	}
+	diff := r.smoothedRTT - adjusted
+	if diff < 0 {
+		diff = -diff
+	}
+	r.rttVar = (3*r.rttVar + diff) / 4
+	r.smoothedRTT = (7*r.smoothedRTT + adjusted) / 8
+}
+
+// pto returns the probe timeout period, without backoff.
+func (r *recovery) pto(maxAckDelay time.Duration) time.Duration {
+	v := 4 * r.rttVar
+	if v < timerGranularity {
+		v = timerGranularity
+	}
+	return r.smoothedRTT + v + maxAckDelay
+}
+
+// lossDelay returns the time threshold for declaring a packet lost.
+func (r *recovery) lossDelay() time.Duration {
+	d := r.smoothedRTT
+	if r.latestRTT > d {
+		d = r.latestRTT
+	}
+	d = d * 9 / 8
+	if d < timerGranularity {
+		d = timerGranularity
+	}
+	return d
+}
+
+func (r *recovery) canSend() bool {
+	return r.bytesInFlight+maxUDPPayloadSize <= r.cwnd
+}
+
+func (r *recovery) onSent(p *sentPacket) {
+	r.bytesInFlight += p.size
+}
+
+func (r *recovery) onAcked(p *sentPacket) {
+	r.bytesInFlight -= p.size
+	if !p.time.After(r.recoveryStart) {
+		return
+	}
+	if r.cwnd < r.ssthresh {
+		r.cwnd += p.size
+	} else {
+		r.cwnd += maxUDPPayloadSize * p.size / r.cwnd
+	}
+}
+
+func (r *recovery) onLost(p *sentPacket) {
+	r.bytesInFlight -= p.size
+}
+
+// onCongestionEvent reduces the congestion window after a loss
+// of a packet sent at sentTime.
+func (r *recovery) onCongestionEvent(now, sentTime time.Time) {
+	if !sentTime.After(r.recoveryStart) {
+		return
+	}
+	r.recoveryStart = now
+	r.ssthresh = r.cwnd / 2
+	if r.ssthresh < minimumWindow {
+		r.ssthresh = minimumWindow
+	}
+	r.cwnd = r.ssthresh
+}
+
+// handleAckFrame processes an ACK frame received in a packet number space.
+func (c *Conn) handleAckFrame(now time.Time, space numberSpace, b []byte) (int, error) {
+	s := &c.spaces[space]
+	var ranges []span
+	largest, delay, n := consumeAckFrame(b, func(start, end int64) {
+		ranges = append(ranges, span{start, end})
+	})
+	if n < 0 {
+		return n, nil
+	}
+	if largest >= s.nextNum {
+		return n, protocolError("acknowledgement of unsent packet %v", largest)
+	}
+	acked := func(num int64) bool {
+		for _, r := range ranges {
+			if num >= r.start && num < r.end {
+				return true
+			}
+		}
+		return false
+	}
+	var newlyAcked []*sentPacket
+	keep := s.sent[:0]
+	for _, p := range s.sent {
+		if acked(p.num) {
+			newlyAcked = append(newlyAcked, p)
+		} else {
+			keep = append(keep, p)
+		}
+	}
+	for i := len(keep); i < len(s.sent); i++ {
+		s.sent[i] = nil
+	}
+	s.sent = keep
+	if largest > s.largestAcked {
+		s.largestAcked = largest
+	}
+	if len(newlyAcked) == 0 {
+		return n, nil
+	}
+	if last := newlyAcked[len(newlyAcked)-1]; last.num == largest {
+		var ackDelay time.Duration
+		if space == appDataSpace {
+			if delay < 1<<32 {
+				ackDelay = time.Duration(delay<<uint(c.peerParams.ackDelayExponent)) * time.Microsecond
+			}
+			if c.handshakeConfirmed && (ackDelay > c.peerParams.maxAckDelay || ackDelay < 0) {
+				ackDelay = c.peerParams.maxAckDelay
+			}
+		}
+		c.rec.updateRTT(now.Sub(last.time), ackDelay)
+	}
+	for _, p := range newlyAcked {
+		c.rec.onAcked(p)
+		c.onPacketAcked(space, p)
+	}
+	c.detectLostPackets(now, space)
+	c.rec.ptoCount = 0
+	return n, nil
+}
+
+// onPacketAcked handles the acknowledgement of the frames in a packet.
+func (c *Conn) onPacketAcked(space numberSpace, p *sentPacket) {
+	for _, f := range p.frames {
+		switch f.typ {
+		case frameTypeCrypto:
+			c.spaces[space].cryptoSend.onAck(f.off, f.n)
+		case frameTypeStreamBase:
+			st := c.streams[f.id]
+			if st == nil {
+				continue
+			}
+			if f.n > 0 && st.out.onAck(f.off, f.n) {
+				signal(st.writec)
+			}
+			if f.fin {
+				st.finAcked = true
+			}
+			c.maybeRemoveStream(st)
+		case frameTypeResetStream:
+			if st := c.streams[f.id]; st != nil {
+				st.resetAcked = true
+				c.maybeRemoveStream(st)
+			}
+		}
+	}
+}
+
+// requeueFrames queues the frames in a lost packet for retransmission.
+func (c *Conn) requeueFrames(space numberSpace, p *sentPacket) {
+	for _, f := range p.frames {
+		switch f.typ {
+		case frameTypeCrypto:
+			c.spaces[space].cryptoSend.onLost(f.off, f.n)
+		case frameTypeStreamBase:
+			st := c.streams[f.id]
+			if st == nil || st.outReset {
+				continue
+			}
+			st.out.onLost(f.off, f.n)
+			if f.fin && !st.finAcked {
+				st.finSent = false
+			}
+		case frameTypeResetStream:
+			if st := c.streams[f.id]; st != nil && !st.resetAcked {
+				st.resetPending = true
+			}
+		case frameTypeStopSending:
+			if st := c.streams[f.id]; st != nil && st.in.finalSize < 0 && !st.inReset {
+				st.stopPending = true
+			}
+		case frameTypeMaxStreamData:
+			if st := c.streams[f.id]; st != nil && st.in.finalSize < 0 && !st.inClosed {
+				st.inMaxPending = true
+			}
+		case frameTypeMaxData:
+			c.inMaxPending = true
+		case frameTypeMaxStreamsBidi:
+			c.remoteStreamLimitSent[streamIndex(bidiStream)] = false
+		case frameTypeMaxStreamsUni:
+			c.remoteStreamLimitSent[streamIndex(uniStream)] = false
+		case frameTypeHandshakeDone:
+			c.handshakeDonePending = true
+		}
+	}
+}
+
+// detectLostPackets declares packets lost according to the
+// packet and time thresholds. See RFC 9002, Section 6.1.
+func (c *Conn) detectLostPackets(now time.Time, space numberSpace) {
+	s := &c.spaces[space]
+	s.lossTime = time.Time{}
+	lossDelay := c.rec.lossDelay()
+	var lost []*sentPacket
+	keep := s.sent[:0]
+	for _, p := range s.sent {
+		if p.num > s.largestAcked {
+			keep = append(keep, p)
+			continue
+		}
+		if !now.Before(p.time.Add(lossDelay)) || s.largestAcked >= p.num+packetThreshold {
+			lost = append(lost, p)
+			continue
+		}
+		if t := p.time.Add(lossDelay); s.lossTime.IsZero() || t.Before(s.lossTime) {
+			s.lossTime = t
+		}
+		keep = append(keep, p)
+	}
+	for i := len(keep); i < len(s.sent); i++ {
+		s.sent[i] = nil
+	}
+	s.sent = keep
+	if len(lost) == 0 {
+		return
+	}
+	for _, p := range lost {
+		c.rec.onLost(p)
+		if !p.retransmitted {
+			c.requeueFrames(space, p)
+		}
+	}
+	c.rec.onCongestionEvent(now, lost[len(lost)-1].time)
+}
+
+// lossDetectionDeadline returns the time at which the loss detection
+// timer expires, or the zero time if it is not set.
+func (c *Conn) lossDetectionDeadline() time.Time {
+	var t time.Time
+	for i := range c.spaces {
+		if lt := c.spaces[i].lossTime; !lt.IsZero() && (t.IsZero() || lt.Before(t)) {
+			t = lt
+		}
+	}
+	if !t.IsZero() {
+		return t
+	}
+	_, t = c.ptoDeadline()
+	return t
+}
+
+// ptoDeadline returns the number space and time of the next probe timeout.
+// See RFC 9002, Section 6.2.1.
+func (c *Conn) ptoDeadline() (numberSpace, time.Time) {
+	backoff := c.rec.ptoCount
+	if backoff > maxPTOBackoff {
+		backoff = maxPTOBackoff
+	}
+	var (
+		space numberSpace
+		t     time.Time
+	)
+	for i := range c.spaces {
+		s := &c.spaces[i]
+		if len(s.sent) == 0 {
+			continue
+		}
+		var maxAckDelay time.Duration
+		if numberSpace(i) == appDataSpace && c.handshakeConfirmed {
+			maxAckDelay = c.peerParams.maxAckDelay
+		}
+		pt := s.lastAckElicitingSent.Add(c.rec.pto(maxAckDelay) << backoff)
+		if t.IsZero() || pt.Before(t) {
+			space, t = numberSpace(i), pt
+		}
+	}
+	if t.IsZero() && c.client && !c.handshakeComplete && !c.lastActivity.IsZero() {
+		// The client must keep sending until the handshake completes,
+		// to avoid a deadlock when the server is limited by the
+		// anti-amplification limit. See RFC 9002, Section 6.2.2.1.
+		space = initialSpace
+		if c.spaces[handshakeSpace].wkeys.isSet() {
+			space = handshakeSpace
+		}
+		t = c.lastActivity.Add(c.rec.pto(0) << backoff)
+	}
+	return space, t
+}
+
+// onLossDetectionTimeout handles the expiry of the loss detection timer.
+func (c *Conn) onLossDetectionTimeout(now time.Time) {
+	for i := range c.spaces {
+		if lt := c.spaces[i].lossTime; !lt.IsZero() && !now.Before(lt) {
+			c.detectLostPackets(now, numberSpace(i))
+			return
+		}
+	}
+	space, t := c.ptoDeadline()
+	if t.IsZero() || now.Before(t) {
+		return
+	}
+	c.rec.ptoCount++
+	s := &c.spaces[space]
+	for _, p := range s.sent {
+		if !p.retransmitted {
+			p.retransmitted = true
+			c.requeueFrames(space, p)
+		}
+	}
+	s.probePending = true
+}
+
+// discardKeys discards the keys and state of a packet number space.
+// See RFC 9001, Section 4.9.
+func (c *Conn) discardKeys(space numberSpace) {
+	s := &c.spaces[space]
+	if s.discarded {
+		return
+	}
+	s.discarded = true
+	s.rkeys.discard()
+	s.wkeys.discard()
+	for _, p := range s.sent {
+		c.rec.bytesInFlight -= p.size
+	}
+	s.sent = nil
+	s.lossTime = time.Time{}
+	s.ackPending = false
+	s.probePending = false
+	s.cryptoSend.discard()
+	c.rec.ptoCount = 0
+}
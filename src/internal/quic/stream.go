@@ -0,0 +1,520 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"context"
+	"io"
+)
+
+// streamSendBufferSize is the maximum amount of unacknowledged data
+// buffered by a stream. Writes block when the buffer is full.
+const streamSendBufferSize = 1 << 20
+
+// maxCryptoBufferSize is the maximum amount of out-of-order
+// handshake data buffered in one packet number space.
+const maxCryptoBufferSize = 64 << 10
+
+// A streamType is one of the four types of stream,
+// as encoded in the low two bits of the stream ID.
+type streamType int
+
+const (
+	bidiStream streamType = 0
+	uniStream  streamType = 2
+)
+
+// Stream ID bits.
+const (
+	streamIDServerBit = 0x01
+	streamIDUniBit    = 0x02
+)
+
+// A Stream is an ordered byte stream within a QUIC connection.
+//
+// Reads and writes on a stream may proceed concurrently,
+// but a stream does not support concurrent calls to Read
+// or concurrent calls to Write.
+type Stream struct {
+	id   int64
+	conn *Conn
+
+	// readCtx and writeCtx are used to cancel blocking reads and writes.
+	// They are not protected by conn.mu, and must be set by the
+	// goroutine performing the read or write.
+	readCtx  context.Context
+	writeCtx context.Context
+
+	// readc and writec are signaled when blocked reads and writes
+	// may be able to make progress.
+	readc  chan struct{}
+	writec chan struct{}
+
+	// The remaining fields are protected by conn.mu.
+
+	// Receive side.
+	in           recvBuffer
+	inMax        int64 // flow control limit sent to the peer
+	inMaxPending bool  // a MAX_STREAM_DATA frame must be sent
+	inReset      bool  // the peer reset the stream
+	inResetCode  uint64
+	inClosed     bool // the application called CloseRead
+	stopPending  bool // a STOP_SENDING frame must be sent
+	stopCode     uint64
+
+	// Send side.
+	out          sendBuffer
+	outMax       int64 // flow control limit from the peer
+	outClosed    bool  // the application called CloseWrite; FIN follows the buffered data
+	finSent      bool  // a FIN has been sent for out.end
+	finAcked     bool
+	outReset     bool  // the stream was reset
+	outErr       error // error returned by Write after a reset
+	resetCode    uint64
+	resetPending bool // a RESET_STREAM frame must be sent
+	resetAcked   bool
+
+	removed bool // the stream has been removed from the connection
+}
+
+func newStream(c *Conn, id int64) *Stream {
+	s := &Stream{
+		id:       id,
+		conn:     c,
+		readCtx:  context.Background(),
+		writeCtx: context.Background(),
+		readc:    make(chan struct{}, 1),
+		writec:   make(chan struct{}, 1),
+	}
+	s.in.finalSize = -1
+	s.inMax = c.config.maxStreamReadBufferSize()
+	local := (id&streamIDServerBit != 0) == c.isServer()
+	if id&streamIDUniBit != 0 {
+		if local {
+			// Send-only stream; the receive side is already complete.
+			s.in.finalSize = 0
+			s.inClosed = true
+		} else {
+			// Receive-only stream; the send side is already complete.
+			s.outClosed = true
+			s.finSent = true
+			s.finAcked = true
+		}
+	}
+	switch {
+	case id&streamIDUniBit != 0:
+		s.outMax = c.peerParams.initialMaxStreamDataUni
+	case local:
+		s.outMax = c.peerParams.initialMaxStreamDataBidiRemote
+	default:
+		s.outMax = c.peerParams.initialMaxStreamDataBidiLocal
+	}
+	return s
+}
+
+// ID returns the QUIC stream ID of s.
+func (s *Stream) ID() int64 {
+	return s.id
+}
+
+// IsReadOnly reports whether s is a receive-only stream.
+func (s *Stream) IsReadOnly() bool {
+	return s.id&streamIDUniBit != 0 && !s.isLocal()
+}
+
+// IsWriteOnly reports whether s is a send-only stream.
+func (s *Stream) IsWriteOnly() bool {
+	return s.id&streamIDUniBit != 0 && s.isLocal()
+}
+
+func (s *Stream) isLocal() bool {
+	return (s.id&streamIDServerBit != 0) == s.conn.isServer()
+}
+
+// SetReadContext sets the context used by reads from the stream.
+// Reads are canceled when the context is done.
+func (s *Stream) SetReadContext(ctx context.Context) {
+	s.readCtx = ctx
+}
+
+// SetWriteContext sets the context used by writes to the stream.
+// Writes are canceled when the context is done.
+func (s *Stream) SetWriteContext(ctx context.Context) {
+	s.writeCtx = ctx
+}
+
+// Read reads data from the stream.
+// It returns io.EOF after the peer closes its side of the stream,
+// and a StreamErrorCode if the peer resets the stream.
+func (s *Stream) Read(b []byte) (int, error) {
+	c := s.conn
+	for {
+		c.mu.Lock()
+		if s.inReset {
+			c.mu.Unlock()
+			return 0, StreamErrorCode(s.inResetCode)
+		}
+		if s.inClosed {
+			c.mu.Unlock()
+			return 0, errStreamClosed
+		}
+		if n := s.in.read(b); n > 0 {
+			c.consumeStreamData(s, int64(n))
+			c.mu.Unlock()
+			return n, nil
+		}
+		if s.in.eof() {
+			c.maybeRemoveStream(s)
+			c.mu.Unlock()
+			return 0, io.EOF
+		}
+		err := c.err
+		c.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		if len(b) == 0 {
+			return 0, nil
+		}
+		select {
+		case <-s.readc:
+		case <-s.readCtx.Done():
+			return 0, s.readCtx.Err()
+		case <-c.donec:
+		}
+	}
+}
+
+// Write writes data to the stream.
+// Writes block when the stream's send buffer is full.
+func (s *Stream) Write(b []byte) (n int, err error) {
+	c := s.conn
+	for {
+		c.mu.Lock()
+		if err := s.writeErrLocked(); err != nil {
+			c.mu.Unlock()
+			return n, err
+		}
+		avail := streamSendBufferSize - len(s.out.buf)
+		m := len(b)
+		if m > avail {
+			m = avail
+		}
+		if m > 0 {
+			s.out.write(b[:m])
+			b = b[m:]
+			n += m
+		}
+		c.mu.Unlock()
+		if m > 0 {
+			c.wake()
+		}
+		if len(b) == 0 {
+			return n, nil
+		}
+		select {
+		case <-s.writec:
+		case <-s.writeCtx.Done():
+			return n, s.writeCtx.Err()
+		case <-c.donec:
+		}
+	}
+}
+
+func (s *Stream) writeErrLocked() error {
+	switch {
+	case s.outReset:
+		return s.outErr
+	case s.outClosed:
+		return errStreamClosed
+	case s.conn.err != nil:
+		return s.conn.err
+	}
+	return nil
+}
+
+// CloseRead aborts reads on the stream.
+// If the peer has not finished sending data, it is asked to
+// stop sending with the given application error code.
+// Any unread data is discarded.
+func (s *Stream) CloseRead(code uint64) {
+	if s.IsWriteOnly() {
+		return
+	}
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s.inClosed {
+		return
+	}
+	s.inClosed = true
+	if s.in.finalSize < 0 && !s.inReset {
+		s.stopPending = true
+		s.stopCode = code
+	}
+	// Discarded data no longer counts against the connection's
+	// flow control window.
+	unread := s.in.end - s.in.readOff
+	s.in.discard()
+	c.consumeConnData(unread)
+	c.maybeRemoveStream(s)
+	signal(s.readc)
+	c.wake()
+}
+
+// CloseWrite closes the write side of the stream.
+// Data written before CloseWrite is delivered to the peer
+// before the end of the stream.
+func (s *Stream) CloseWrite() {
+	if s.IsReadOnly() {
+		return
+	}
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s.outClosed || s.outReset {
+		return
+	}
+	s.outClosed = true
+	signal(s.writec)
+	c.wake()
+}
+
+// Reset aborts writes on the stream and notifies the peer
+// that the stream was terminated with the given application error code.
+// Data written but not yet delivered is discarded.
+func (s *Stream) Reset(code uint64) {
+	if s.IsReadOnly() {
+		return
+	}
+	c := s.conn
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s.resetLocked(code, errStreamClosed)
+	c.wake()
+}
+
+func (s *Stream) resetLocked(code uint64, err error) {
+	if s.outReset || s.finAcked {
+		return
+	}
+	s.outReset = true
+	s.outErr = err
+	s.resetCode = code
+	s.resetPending = true
+	s.out.lost = nil
+	signal(s.writec)
+}
+
+// Close closes both sides of the stream.
+// Unread data is discarded, and buffered writes are flushed.
+func (s *Stream) Close() error {
+	s.CloseWrite()
+	s.CloseRead(0)
+	return nil
+}
+
+// signal sends a value on a channel with a buffer of one, without blocking.
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// A sendBuffer holds data written to a stream or crypto stream
+// that has not yet been acknowledged.
+type sendBuffer struct {
+	buf      []byte   // data from ackedOff through end
+	ackedOff int64    // all data before this offset has been acknowledged
+	sentOff  int64    // data before this offset has been sent at least once
+	lost     rangeset // ranges that must be retransmitted
+	acked    rangeset // acknowledged ranges above ackedOff
+}
+
+func (b *sendBuffer) end() int64 {
+	return b.ackedOff + int64(len(b.buf))
+}
+
+func (b *sendBuffer) write(p []byte) {
+	b.buf = append(b.buf, p...)
+}
+
+func (b *sendBuffer) data(off int64, n int) []byte {
+	return b.buf[off-b.ackedOff:][:n]
+}
+
+// next returns the next range of data to send: first data
+// that must be retransmitted, then new data before limit.
+// It reports false if there is nothing to send.
+func (b *sendBuffer) next(limit int64, max int) (off int64, n int, ok bool) {
+	if max <= 0 {
+		return 0, 0, false
+	}
+	if len(b.lost) > 0 {
+		r := b.lost[0]
+		n := r.end - r.start
+		if n > int64(max) {
+			n = int64(max)
+		}
+		return r.start, int(n), true
+	}
+	end := b.end()
+	if end > limit {
+		end = limit
+	}
+	if b.sentOff >= end {
+		return 0, 0, false
+	}
+	n64 := end - b.sentOff
+	if n64 > int64(max) {
+		n64 = int64(max)
+	}
+	return b.sentOff, int(n64), true
+}
+
+// hasLost reports whether there is data to retransmit.
+func (b *sendBuffer) hasLost() bool {
+	return len(b.lost) > 0
+}
+
+// markSent records that [off, off+n) has been sent.
+func (b *sendBuffer) markSent(off int64, n int) {
+	end := off + int64(n)
+	b.lost.sub(off, end)
+	if end > b.sentOff {
+		b.sentOff = end
+	}
+}
+
+// onLost records that a packet containing [off, off+n) was lost.
+func (b *sendBuffer) onLost(off int64, n int) {
+	start, end := off, off+int64(n)
+	if start < b.ackedOff {
+		start = b.ackedOff
+	}
+	if start >= end {
+		return
+	}
+	b.lost.add(start, end)
+	for _, r := range b.acked {
+		if r.start >= end {
+			break
+		}
+		b.lost.sub(r.start, r.end)
+	}
+}
+
+// onAck records that [off, off+n) has been acknowledged.
+// It reports whether data was removed from the buffer.
+func (b *sendBuffer) onAck(off int64, n int) bool {
+	start, end := off, off+int64(n)
+	if start < b.ackedOff {
+		start = b.ackedOff
+	}
+	if start >= end {
+		return false
+	}
+	b.acked.add(start, end)
+	b.lost.sub(start, end)
+	if b.acked[0].start != b.ackedOff {
+		return false
+	}
+	newOff := b.acked[0].end
+	b.buf = b.buf[newOff-b.ackedOff:]
+	b.ackedOff = newOff
+	b.acked = b.acked[1:]
+	return true
+}
+
+// discard drops all buffered data.
+func (b *sendBuffer) discard() {
+	b.buf = nil
+	b.lost = nil
+	b.acked = nil
+}
+
+// A recvBuffer reassembles data received on a stream or crypto stream.
+type recvBuffer struct {
+	buf       []byte   // data from readOff
+	readOff   int64    // data before this offset has been read
+	recvd     rangeset // received ranges
+	end       int64    // the largest offset received
+	finalSize int64    // the final size of the stream, or -1 if unknown
+
+	discarding bool // received data is discarded rather than buffered
+}
+
+// write stores data received at offset off.
+// Data beyond the final size of the stream is an error.
+func (b *recvBuffer) write(off int64, data []byte, fin bool) error {
+	end := off + int64(len(data))
+	if b.finalSize >= 0 && (end > b.finalSize || fin && end != b.finalSize) {
+		return &localTransportError{errFinalSize, "data beyond final size"}
+	}
+	if fin {
+		if end < b.end {
+			return &localTransportError{errFinalSize, "final size smaller than received data"}
+		}
+		b.finalSize = end
+	}
+	if end > b.end {
+		b.end = end
+	}
+	if b.discarding {
+		b.readOff = b.end
+		return nil
+	}
+	if end <= b.readOff {
+		return nil
+	}
+	if off < b.readOff {
+		data = data[b.readOff-off:]
+		off = b.readOff
+	}
+	if need := int(end - b.readOff); len(b.buf) < need {
+		b.buf = append(b.buf, make([]byte, need-len(b.buf))...)
+	}
+	copy(b.buf[off-b.readOff:], data)
+	b.recvd.add(off, end)
+	return nil
+}
+
+// readable returns the number of bytes available to read.
+func (b *recvBuffer) readable() int {
+	if len(b.recvd) == 0 || b.recvd[0].start > b.readOff {
+		return 0
+	}
+	return int(b.recvd[0].end - b.readOff)
+}
+
+// read reads available data into p.
+func (b *recvBuffer) read(p []byte) int {
+	n := b.readable()
+	if n > len(p) {
+		n = len(p)
+	}
+	if n == 0 {
+		return 0
+	}
+	copy(p, b.buf[:n])
+	b.buf = b.buf[n:]
+	b.readOff += int64(n)
+	b.recvd.sub(b.recvd.min(), b.readOff)
+	return n
+}
+
+// eof reports whether all data up to the final size has been read.
+func (b *recvBuffer) eof() bool {
+	return b.finalSize >= 0 && b.readOff == b.finalSize
+}
+
+// discard drops all buffered and future data, treating it as read.
+func (b *recvBuffer) discard() {
+	b.discarding = true
+	b.buf = nil
+	b.recvd = nil
+	b.readOff = b.end
+}
@@ -0,0 +1,247 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"time"
+)
+
+// transportParameters are the QUIC transport parameters.
+// See RFC 9000, Section 18.
+type transportParameters struct {
+	originalDstConnID              []byte
+	maxIdleTimeout                 time.Duration
+	statelessResetToken            []byte
+	maxUDPPayloadSize              int64
+	initialMaxData                 int64
+	initialMaxStreamDataBidiLocal  int64
+	initialMaxStreamDataBidiRemote int64
+	initialMaxStreamDataUni        int64
+	initialMaxStreamsBidi          int64
+	initialMaxStreamsUni           int64
+	ackDelayExponent               int8
+	maxAckDelay                    time.Duration
+	disableActiveMigration         bool
+	preferredAddress               []byte
+	activeConnIDLimit              int64
+	initialSrcConnID               []byte
+	retrySrcConnID                 []byte
+}
+
+// Transport parameter IDs.
+const (
+	paramOriginalDestinationConnectionID = 0x00
+	paramMaxIdleTimeout                  = 0x01
+	paramStatelessResetToken             = 0x02
+	paramMaxUDPPayloadSize               = 0x03
+	paramInitialMaxData                  = 0x04
+	paramInitialMaxStreamDataBidiLocal   = 0x05
+	paramInitialMaxStreamDataBidiRemote  = 0x06
+	paramInitialMaxStreamDataUni         = 0x07
+	paramInitialMaxStreamsBidi           = 0x08
+	paramInitialMaxStreamsUni            = 0x09
+	paramAckDelayExponent                = 0x0a
+	paramMaxAckDelay                     = 0x0b
+	paramDisableActiveMigration          = 0x0c
+	paramPreferredAddress                = 0x0d
+	paramActiveConnectionIDLimit         = 0x0e
+	paramInitialSourceConnectionID       = 0x0f
+	paramRetrySourceConnectionID         = 0x10
+)
+
+// defaultTransportParameters returns the default values
+// of parameters that are not sent.
+func defaultTransportParameters() transportParameters {
+	return transportParameters{
+		maxUDPPayloadSize: 65527,
+		ackDelayExponent:  3,
+		maxAckDelay:       25 * time.Millisecond,
+		activeConnIDLimit: 2,
+	}
+}
+
+func (p *transportParameters) marshal() []byte {
+	var b []byte
+	appendInt := func(id uint64, v int64) {
+		b = AppendVarint(b, id)
+		b = AppendVarint(b, uint64(SizeVarint(uint64(v))))
+		b = AppendVarint(b, uint64(v))
+	}
+	appendBytes := func(id uint64, v []byte) {
+		b = AppendVarint(b, id)
+		b = appendVarintBytes(b, v)
+	}
+	if p.originalDstConnID != nil {
+		appendBytes(paramOriginalDestinationConnectionID, p.originalDstConnID)
+	}
+	if p.maxIdleTimeout > 0 {
+		appendInt(paramMaxIdleTimeout, int64(p.maxIdleTimeout/time.Millisecond))
+	}
+	if p.statelessResetToken != nil {
+		appendBytes(paramStatelessResetToken, p.statelessResetToken)
+	}
+	if p.maxUDPPayloadSize != 65527 {
+		appendInt(paramMaxUDPPayloadSize, p.maxUDPPayloadSize)
+	}
+	if p.initialMaxData != 0 {
+		appendInt(paramInitialMaxData, p.initialMaxData)
+	}
+	if p.initialMaxStreamDataBidiLocal != 0 {
+		appendInt(paramInitialMaxStreamDataBidiLocal, p.initialMaxStreamDataBidiLocal)
+	}
+	if p.initialMaxStreamDataBidiRemote != 0 {
+		appendInt(paramInitialMaxStreamDataBidiRemote, p.initialMaxStreamDataBidiRemote)
+	}
+	if p.initialMaxStreamDataUni != 0 {
+		appendInt(paramInitialMaxStreamDataUni, p.initialMaxStreamDataUni)
+	}
+	if p.initialMaxStreamsBidi != 0 {
+		appendInt(paramInitialMaxStreamsBidi, p.initialMaxStreamsBidi)
+	}
+	if p.initialMaxStreamsUni != 0 {
+		appendInt(paramInitialMaxStreamsUni, p.initialMaxStreamsUni)
+	}
+	if p.ackDelayExponent != 3 {
+		appendInt(paramAckDelayExponent, int64(p.ackDelayExponent))
+	}
+	if p.maxAckDelay != 25*time.Millisecond {
+		appendInt(paramMaxAckDelay, int64(p.maxAckDelay/time.Millisecond))
+	}
+	if p.disableActiveMigration {
+		appendBytes(paramDisableActiveMigration, nil)
+	}
+	if p.preferredAddress != nil {
+		appendBytes(paramPreferredAddress, p.preferredAddress)
+	}
+	if p.activeConnIDLimit != 2 {
+		appendInt(paramActiveConnectionIDLimit, p.activeConnIDLimit)
+	}
+	if p.initialSrcConnID != nil {
+		appendBytes(paramInitialSourceConnectionID, p.initialSrcConnID)
+	}
+	if p.retrySrcConnID != nil {
+		appendBytes(paramRetrySourceConnectionID, p.retrySrcConnID)
+	}
+	return b
+}
+
+func transportParameterError(reason string) error {
+	return &localTransportError{errTransportParameter, reason}
+}
+
+// parseTransportParameters parses the transport parameters in b.
+// Parameters that may only be sent by a server are rejected
+// unless fromServer is set.
+func parseTransportParameters(b []byte, fromServer bool) (transportParameters, error) {
+	p := defaultTransportParameters()
+	seen := map[uint64]bool{}
+	for len(b) > 0 {
+		id, n := ConsumeVarint(b)
+		if n < 0 {
+			return p, transportParameterError("malformed parameter ID")
+		}
+		b = b[n:]
+		val, n := consumeVarintBytes(b)
+		if n < 0 {
+			return p, transportParameterError("malformed parameter value")
+		}
+		b = b[n:]
+		if seen[id] {
+			return p, transportParameterError("duplicate parameter")
+		}
+		seen[id] = true
+		intVal := func() (int64, error) {
+			v, n := ConsumeVarint(val)
+			if n != len(val) {
+				return 0, transportParameterError("malformed integer parameter")
+			}
+			return int64(v), nil
+		}
+		var err error
+		switch id {
+		case paramOriginalDestinationConnectionID:
+			p.originalDstConnID = append([]byte{}, val...)
+		case paramMaxIdleTimeout:
+			var v int64
+			v, err = intVal()
+			// Cap the timeout to avoid overflow; no one needs more than a day.
+			if v > int64(24*time.Hour/time.Millisecond) {
+				v = int64(24 * time.Hour / time.Millisecond)
+			}
+			p.maxIdleTimeout = time.Duration(v) * time.Millisecond
+		case paramStatelessResetToken:
+			if len(val) != 16 {
+				return p, transportParameterError("invalid stateless_reset_token")
+			}
+			p.statelessResetToken = append([]byte{}, val...)
+		case paramMaxUDPPayloadSize:
+			p.maxUDPPayloadSize, err = intVal()
+			if err == nil && p.maxUDPPayloadSize < 1200 {
+				err = transportParameterError("invalid max_udp_payload_size")
+			}
+		case paramInitialMaxData:
+			p.initialMaxData, err = intVal()
+		case paramInitialMaxStreamDataBidiLocal:
+			p.initialMaxStreamDataBidiLocal, err = intVal()
+		case paramInitialMaxStreamDataBidiRemote:
+			p.initialMaxStreamDataBidiRemote, err = intVal()
+		case paramInitialMaxStreamDataUni:
+			p.initialMaxStreamDataUni, err = intVal()
+		case paramInitialMaxStreamsBidi:
+			p.initialMaxStreamsBidi, err = intVal()
+			if err == nil && p.initialMaxStreamsBidi > 1<<60 {
+				err = transportParameterError("invalid initial_max_streams_bidi")
+			}
+		case paramInitialMaxStreamsUni:
+			p.initialMaxStreamsUni, err = intVal()
+			if err == nil && p.initialMaxStreamsUni > 1<<60 {
+				err = transportParameterError("invalid initial_max_streams_uni")
+			}
+		case paramAckDelayExponent:
+			var v int64
+			v, err = intVal()
+			if err == nil && v > 20 {
+				err = transportParameterError("invalid ack_delay_exponent")
+			}
+			p.ackDelayExponent = int8(v)
+		case paramMaxAckDelay:
+			var v int64
+			v, err = intVal()
+			if err == nil && v >= 1<<14 {
+				err = transportParameterError("invalid max_ack_delay")
+			}
+			p.maxAckDelay = time.Duration(v) * time.Millisecond
+		case paramDisableActiveMigration:
+			if len(val) != 0 {
+				err = transportParameterError("invalid disable_active_migration")
+			}
+			p.disableActiveMigration = true
+		case paramPreferredAddress:
+			p.preferredAddress = append([]byte{}, val...)
+		case paramActiveConnectionIDLimit:
+			p.activeConnIDLimit, err = intVal()
+			if err == nil && p.activeConnIDLimit < 2 {
+				err = transportParameterError("invalid active_connection_id_limit")
+			}
+		case paramInitialSourceConnectionID:
+			p.initialSrcConnID = append([]byte{}, val...)
+		case paramRetrySourceConnectionID:
+			p.retrySrcConnID = append([]byte{}, val...)
+		default:
+			// Unknown parameters are ignored.
+		}
+		if err != nil {
+			return p, err
+		}
+		if !fromServer {
+			switch id {
+			case paramOriginalDestinationConnectionID, paramStatelessResetToken,
+				paramPreferredAddress, paramRetrySourceConnectionID:
+				return p, transportParameterError("client sent server-only parameter")
+			}
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+// maxVarint is the largest value representable as a variable-length integer.
+const maxVarint = (1 << 62) - 1
+
+// ConsumeVarint parses a variable-length integer (RFC 9000, Section 16)
+// from the start of b. It returns the value and the number of bytes
+// consumed, or n < 0 if b does not begin with a complete integer.
+func ConsumeVarint(b []byte) (v uint64, n int) {
+	if len(b) < 1 {
+		return 0, -1
+	}
+	n = 1 << (b[0] >> 6)
+	if len(b) < n {
+		return 0, -1
+	}
+	v = uint64(b[0] & 0x3f)
+	for i := 1; i < n; i++ {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, n
+}
+
+// consumeVarintInt64 is like ConsumeVarint, but returns an int64.
+func consumeVarintInt64(b []byte) (v int64, n int) {
+	u, n := ConsumeVarint(b)
+	return int64(u), n
+}
+
+// AppendVarint appends the variable-length encoding of v to b.
+// It panics if v is larger than 2^62-1.
+func AppendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v <= 63:
+		return append(b, byte(v))
+	case v <= 16383:
+		return append(b, (1<<6)|byte(v>>8), byte(v))
+	case v <= 1073741823:
+		return append(b, (2<<6)|byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	case v <= maxVarint:
+		return append(b, (3<<6)|byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+	panic("quic: varint too large")
+}
+
+// SizeVarint returns the number of bytes needed to encode v.
+func SizeVarint(v uint64) int {
+	switch {
+	case v <= 63:
+		return 1
+	case v <= 16383:
+		return 2
+	case v <= 1073741823:
+		return 4
+	case v <= maxVarint:
+		return 8
+	}
+	panic("quic: varint too large")
+}
+
+// consumeVarintBytes parses a varint length followed by that many bytes.
+func consumeVarintBytes(b []byte) ([]byte, int) {
+	size, n := ConsumeVarint(b)
+	if n < 0 || size > uint64(len(b)-n) {
+		return nil, -1
+	}
+	return b[n:][:size], n + int(size)
+}
+
+func appendVarintBytes(b, v []byte) []byte {
+	b = AppendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// consumeUint8Bytes parses a one-byte length followed by that many bytes.
+func consumeUint8Bytes(b []byte) ([]byte, int) {
+	if len(b) < 1 || int(b[0]) > len(b)-1 {
+		return nil, -1
+	}
+	return b[1:][:b[0]], 1 + int(b[0])
+}
+
+// A span is the half-open interval [start, end).
+type span struct {
+	start, end int64
+}
+
+// A rangeset is a set of int64s, stored as an ordered list of
+// non-overlapping, non-adjacent spans.
+type rangeset []span
+
+// add adds [start, end) to the set.
+func (s *rangeset) add(start, end int64) {
+	if start >= end {
+		return
+	}
+	rs := *s
+	// Find the first span that ends at or after start.
+	i := 0
+	for i < len(rs) && rs[i].end < start {
+		i++
+	}
+	// Find the first span that starts after end.
+	j := i
+	for j < len(rs) && rs[j].start <= end {
+		j++
+	}
+	if i == j {
+		// No overlap; insert.
+		rs = append(rs, span{})
+		copy(rs[i+1:], rs[i:])
+		rs[i] = span{start, end}
+		*s = rs
+		return
+	}
+	if rs[i].start < start {
+		start = rs[i].start
+	}
+	if rs[j-1].end > end {
+		end = rs[j-1].end
+	}
+	rs[i] = span{start, end}
+	rs = append(rs[:i+1], rs[j:]...)
+	*s = rs
+}
+
+// sub removes [start, end) from the set.
+func (s *rangeset) sub(start, end int64) {
+	rs := *s
+	i := 0
+	for i < len(rs) && rs[i].end <= start {
+		i++
+	}
+	if start >= end || i == len(rs) || rs[i].start >= end {
+		return
+	}
+	j := i
+	for j < len(rs) && rs[j].start < end {
+		j++
+	}
+	// The spans rs[i:j] overlap [start, end).
+	out := make(rangeset, 0, len(rs)+1)
+	out = append(out, rs[:i]...)
+	if rs[i].start < start {
+		out = append(out, span{rs[i].start, start})
+	}
+	if rs[j-1].end > end {
+		out = append(out, span{end, rs[j-1].end})
+	}
+	out = append(out, rs[j:]...)
+	*s = out
+}
+
+// contains reports whether v is in the set.
+func (s rangeset) contains(v int64) bool {
+	for _, r := range s {
+		if v < r.start {
+			return false
+		}
+		if v < r.end {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmpty reports whether the set contains no values.
+func (s rangeset) isEmpty() bool {
+	return len(s) == 0
+}
+
+// min returns the smallest value in the set, or 0 if the set is empty.
+func (s rangeset) min() int64 {
+	if len(s) == 0 {
+		return 0
+	}
+	return s[0].start
+}
+
+// max returns the largest value in the set, or -1 if the set is empty.
+func (s rangeset) max() int64 {
+	if len(s) == 0 {
+		return -1
+	}
+	return s[len(s)-1].end - 1
+}
+
+// rangeContaining returns the span containing v, or an empty span.
+func (s rangeset) rangeContaining(v int64) span {
+	for _, r := range s {
+		if v >= r.start && v < r.end {
+			return r
+		}
+	}
+	return span{}
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Examples from RFC 9000, Appendix A.1.
+var varintTests = []struct {
+	b []byte
+	v uint64
+}{
+	{unhex("c2197c5eff14e88c"), 151288809941952652},
+	{unhex("9d7f3e7d"), 494878333},
+	{unhex("7bbd"), 15293},
+	{unhex("25"), 37},
+}
+
+func TestConsumeVarint(t *testing.T) {
+	for _, test := range varintTests {
+		v, n := ConsumeVarint(test.b)
+		if v != test.v || n != len(test.b) {
+			t.Errorf("ConsumeVarint(%x) = %v, %v; want %v, %v", test.b, v, n, test.v, len(test.b))
+		}
+		if _, n := ConsumeVarint(test.b[:len(test.b)-1]); n >= 0 {
+			t.Errorf("ConsumeVarint(%x) = _, %v; want error", test.b[:len(test.b)-1], n)
+		}
+	}
+	// A non-minimal encoding is valid.
+	if v, n := ConsumeVarint(unhex("4025")); v != 37 || n != 2 {
+		t.Errorf("ConsumeVarint(4025) = %v, %v; want 37, 2", v, n)
+	}
+}
+
+func TestAppendVarint(t *testing.T) {
+	for _, test := range varintTests {
+		if got := AppendVarint(nil, test.v); !bytes.Equal(got, test.b) {
+			t.Errorf("AppendVarint(nil, %v) = %x, want %x", test.v, got, test.b)
+		}
+		if got := SizeVarint(test.v); got != len(test.b) {
+			t.Errorf("SizeVarint(%v) = %v, want %v", test.v, got, len(test.b))
+		}
+	}
+}
+
+func TestRangeset(t *testing.T) {
+	var s rangeset
+	s.add(10, 20)
+	s.add(30, 40)
+	s.add(20, 25)
+	s.add(0, 5)
+	if want := (rangeset{{0, 5}, {10, 25}, {30, 40}}); !reflect.DeepEqual(s, want) {
+		t.Fatalf("after adds: %v, want %v", s, want)
+	}
+	s.add(4, 31)
+	if want := (rangeset{{0, 40}}); !reflect.DeepEqual(s, want) {
+		t.Fatalf("after merging add: %v, want %v", s, want)
+	}
+	s.sub(10, 20)
+	s.sub(35, 50)
+	if want := (rangeset{{0, 10}, {20, 35}}); !reflect.DeepEqual(s, want) {
+		t.Fatalf("after subs: %v, want %v", s, want)
+	}
+	for _, test := range []struct {
+		v    int64
+		want bool
+	}{{0, true}, {9, true}, {10, false}, {20, true}, {34, true}, {35, false}, {-1, false}} {
+		if got := s.contains(test.v); got != test.want {
+			t.Errorf("contains(%v) = %v, want %v", test.v, got, test.want)
+		}
+	}
+	if s.min() != 0 || s.max() != 34 {
+		t.Errorf("min, max = %v, %v; want 0, 34", s.min(), s.max())
+	}
+	s.sub(0, 100)
+	if !s.isEmpty() || s.max() != -1 {
+		t.Errorf("after removing everything: %v, max %v", s, s.max())
+	}
+}
+
+func TestAckFrameRoundTrip(t *testing.T) {
+	seen := rangeset{{0, 3}, {5, 6}, {10, 20}}
+	b := appendAckFrame(nil, seen, 7, 100)
+	var got rangeset
+	largest, delay, n := consumeAckFrame(b, func(start, end int64) {
+		got.add(start, end)
+	})
+	if n != len(b) || largest != 19 || delay != 7 {
+		t.Fatalf("consumeAckFrame = %v, %v, %v; want 19, 7, %v", largest, delay, n, len(b))
+	}
+	if !reflect.DeepEqual(got, seen) {
+		t.Errorf("acknowledged ranges = %v, want %v", got, seen)
+	}
+	// A frame that does not fit is omitted, and one that fits
+	// partially acknowledges the most recent ranges.
+	if b := appendAckFrame(nil, seen, 7, 3); len(b) != 0 {
+		t.Errorf("appendAckFrame with 3 bytes available = %x, want nothing", b)
+	}
+	b = appendAckFrame(nil, seen, 7, 7)
+	got = nil
+	consumeAckFrame(b, func(start, end int64) {
+		got.add(start, end)
+	})
+	if want := (rangeset{{5, 6}, {10, 20}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("truncated ACK frame acknowledged %v, want %v", got, want)
+	}
+}
+
+func TestStreamFrameRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		id, off int64
+		data    string
+		fin     bool
+	}{
+		{0, 0, "hello", false},
+		{4, 1000, "world", true},
+		{1 << 20, 0, "", true},
+	} {
+		b := appendStreamFrame(nil, test.id, test.off, []byte(test.data), test.fin)
+		if size := streamFrameHeaderSize(test.id, test.off, len(test.data)); size+len(test.data) != len(b) {
+			t.Errorf("streamFrameHeaderSize = %v, frame header is %v bytes", size, len(b)-len(test.data))
+		}
+		id, off, fin, data, n := consumeStreamFrame(b)
+		if id != test.id || off != test.off || fin != test.fin || string(data) != test.data || n != len(b) {
+			t.Errorf("consumeStreamFrame(appendStreamFrame(%v, %v, %q, %v)) = %v, %v, %v, %q, %v",
+				test.id, test.off, test.data, test.fin, id, off, fin, data, n)
+		}
+	}
+}
+
+func TestTransportParametersRoundTrip(t *testing.T) {
+	p := defaultTransportParameters()
+	p.originalDstConnID = []byte{1, 2, 3, 4}
+	p.initialSrcConnID = []byte{5, 6, 7, 8}
+	p.initialMaxData = 1 << 20
+	p.initialMaxStreamsBidi = 100
+	p.disableActiveMigration = true
+	got, err := parseTransportParameters(p.marshal(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("parseTransportParameters(marshal(p)) = %+v, want %+v", got, p)
+	}
+	if _, err := parseTransportParameters(p.marshal(), false); err == nil {
+		t.Errorf("server-only parameter from client: no error")
+	}
+}
@@ -7,4 +7,6 @@ package unix
 const (
 	getrandomTrap     uintptr = 355
 	copyFileRangeTrap uintptr = 377
+	recvmmsgTrap      uintptr = 337
+	sendmmsgTrap      uintptr = 345
 )
@@ -7,4 +7,6 @@ package unix
 const (
 	getrandomTrap     uintptr = 318
 	copyFileRangeTrap uintptr = 326
+	recvmmsgTrap      uintptr = 299
+	sendmmsgTrap      uintptr = 307
 )
@@ -7,4 +7,6 @@ package unix
 const (
 	getrandomTrap     uintptr = 384
 	copyFileRangeTrap uintptr = 391
+	recvmmsgTrap      uintptr = 365
+	sendmmsgTrap      uintptr = 374
 )
@@ -15,4 +15,6 @@ package unix
 const (
 	getrandomTrap     uintptr = 278
 	copyFileRangeTrap uintptr = 285
+	recvmmsgTrap      uintptr = 243
+	sendmmsgTrap      uintptr = 269
 )
@@ -10,4 +10,6 @@ package unix
 const (
 	getrandomTrap     uintptr = 5313
 	copyFileRangeTrap uintptr = 5320
+	recvmmsgTrap      uintptr = 5294
+	sendmmsgTrap      uintptr = 5302
 )
@@ -10,4 +10,6 @@ package unix
 const (
 	getrandomTrap     uintptr = 4353
 	copyFileRangeTrap uintptr = 4360
+	recvmmsgTrap      uintptr = 4335
+	sendmmsgTrap      uintptr = 4343
 )
@@ -10,4 +10,6 @@ package unix
 const (
 	getrandomTrap     uintptr = 359
 	copyFileRangeTrap uintptr = 379
+	recvmmsgTrap      uintptr = 343
+	sendmmsgTrap      uintptr = 349
 )
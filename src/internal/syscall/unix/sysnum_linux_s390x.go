@@ -7,4 +7,6 @@ package unix
 const (
 	getrandomTrap     uintptr = 349
 	copyFileRangeTrap uintptr = 375
+	recvmmsgTrap      uintptr = 357
+	sendmmsgTrap      uintptr = 358
 )
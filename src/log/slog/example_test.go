@@ -0,0 +1,70 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slog_test
+
+import (
+	"log/slog"
+	"os"
+)
+
+func ExampleGroup() {
+	// Remove the time for a reproducible example.
+	removeTime := func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey && len(groups) == 0 {
+			return slog.Attr{}
+		}
+		return a
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{ReplaceAttr: removeTime}))
+	logger.Info("finished",
+		slog.Group("req",
+			slog.String("method", "GET"),
+			slog.String("url", "/index.html")),
+		slog.Int("status", 200))
+
+	// Output:
+	// level=INFO msg=finished req.method=GET req.url=/index.html status=200
+}
+
+func ExampleHandlerOptions_customLevels() {
+	// Exported constants from a custom logging package.
+	const (
+		LevelTrace     = slog.Level(-8)
+		LevelEmergency = slog.Level(12)
+	)
+
+	th := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		// Set a custom level to show all log output. The default value is
+		// LevelInfo, which would drop Debug and Trace logs.
+		Level: LevelTrace,
+
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// Remove time from the output for predictable test output.
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			// Customize the name of the level key and the output string,
+			// including custom level values.
+			if a.Key == slog.LevelKey {
+				a.Key = "sev"
+				level := a.Value.Any().(slog.Level)
+				switch {
+				case level < slog.LevelDebug:
+					a.Value = slog.StringValue("TRACE")
+				case level >= LevelEmergency:
+					a.Value = slog.StringValue("EMERGENCY")
+				}
+			}
+			return a
+		},
+	})
+	logger := slog.New(th)
+	logger.Log(nil, LevelTrace, "trace")
+	logger.Log(nil, LevelEmergency, "emergency")
+
+	// Output:
+	// {"sev":"TRACE","msg":"trace"}
+	// {"sev":"EMERGENCY","msg":"emergency"}
+}
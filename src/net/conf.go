@@ -23,6 +23,9 @@ type conf struct {
 	netGo  bool // go DNS resolution forced
 	netCgo bool // cgo DNS resolution forced
 
+	// dnsOverTLS forces Go's DNS resolver to use DNS over TLS.
+	dnsOverTLS bool
+
 	// machine has an /etc/mdns.allow file
 	hasMDNSAllow bool
 
@@ -47,7 +50,8 @@ func systemConf() *conf {
 func initConfVal() {
 	dnsMode, debugLevel := goDebugNetDNS()
 	confVal.dnsDebugLevel = debugLevel
-	confVal.netGo = netGo || dnsMode == "go"
+	confVal.netGo = netGo || dnsMode == "go" || dnsMode == "dot"
+	confVal.dnsOverTLS = dnsMode == "dot"
 	confVal.netCgo = netCgo || dnsMode == "cgo"
 
 	if confVal.dnsDebugLevel > 0 {
@@ -276,37 +280,6 @@ func (c *conf) hostLookupOrder(r *Resolver, hostname string) (ret hostLookupOrde
 	return fallbackOrder
 }
 
-// goDebugNetDNS parses the value of the GODEBUG "netdns" value.
-// The netdns value can be of the form:
-//    1       // debug level 1
-//    2       // debug level 2
-//    cgo     // use cgo for DNS lookups
-//    go      // use go for DNS lookups
-//    cgo+1   // use cgo for DNS lookups + debug level 1
-//    1+cgo   // same
-//    cgo+2   // same, but debug level 2
-// etc.
-func goDebugNetDNS() (dnsMode string, debugLevel int) {
-	goDebug := goDebugString("netdns")
-	parsePart := func(s string) {
-		if s == "" {
-			return
-		}
-		if '0' <= s[0] && s[0] <= '9' {
-			debugLevel, _, _ = dtoi(s)
-		} else {
-			dnsMode = s
-		}
-	}
-	if i := bytealg.IndexByteString(goDebug, '+'); i != -1 {
-		parsePart(goDebug[:i])
-		parsePart(goDebug[i+1:])
-		return
-	}
-	parsePart(goDebug)
-	return
-}
-
 // isLocalhost reports whether h should be considered a "localhost"
 // name for the myhostname NSS module.
 func isLocalhost(h string) bool {
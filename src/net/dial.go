@@ -60,16 +60,37 @@ type Dialer struct {
 	// disable, set FallbackDelay to a negative value.
 	DualStack bool
 
-	// FallbackDelay specifies the length of time to wait before
-	// spawning a RFC 6555 Fast Fallback connection. That is, this
-	// is the amount of time to wait for IPv6 to succeed before
-	// assuming that IPv6 is misconfigured and falling back to
-	// IPv4.
+	// FallbackDelay specifies the length of time to wait for a
+	// connection attempt to succeed before starting a concurrent
+	// attempt to the next address, as in the RFC 8305 Happy
+	// Eyeballs algorithm. When dialing TCP to a host with multiple
+	// IP addresses, the addresses are ordered by recent connection
+	// results and interleaved by address family, so an attempt to
+	// the other family starts after at most this delay if the first
+	// family appears to be misconfigured and hanging. A failed
+	// attempt starts the next one immediately, and the attempts
+	// that lose the race are canceled.
 	//
 	// If zero, a default delay of 300ms is used.
-	// A negative value disables Fast Fallback support.
+	// A negative value disables Fast Fallback support: addresses
+	// are then tried one at a time, in the order returned by the
+	// resolver.
 	FallbackDelay time.Duration
 
+	// DisableDialHistory, if true, prevents the Dialer from using
+	// or updating the history of recent connection results.
+	//
+	// By default, when Fast Fallback is enabled, the outcome of each
+	// TCP connection attempt is remembered for up to 10 minutes in a
+	// history that is shared by all Dialers in the process, and
+	// addresses that recently succeeded are tried first and those
+	// that recently failed are tried last, as suggested by RFC 8305.
+	// A Dialer with DisableDialHistory set still interleaves the
+	// address families, but otherwise tries the addresses in the
+	// order returned by the resolver, and its own results don't
+	// affect other Dialers.
+	DisableDialHistory bool
+
 	// KeepAlive specifies the interval between keep-alive
 	// probes for an active network connection.
 	// If zero, keep-alive probes are sent with a default value
@@ -82,6 +103,21 @@ type Dialer struct {
 	// Resolver optionally specifies an alternate resolver to use.
 	Resolver *Resolver
 
+	// Resolve optionally observes or overrides the list of
+	// addresses a dial connects to.
+	//
+	// If non-nil, Resolve is called for TCP, UDP and IP networks
+	// with the network and address passed to Dial and a lookup
+	// function. Lookup resolves the address as the Dialer would
+	// otherwise, using Resolver, and returns the addresses in the
+	// order in which they would be tried. Resolve may return that
+	// list as is, filter or reorder it, or return addresses from
+	// another source, such as a service discovery system, without
+	// calling lookup at all. The Dialer connects to the returned
+	// addresses in the order given, which must be *TCPAddr, *UDPAddr
+	// or *IPAddr values matching network.
+	Resolve func(ctx context.Context, network, address string, lookup func(context.Context) ([]Addr, error)) ([]Addr, error)
+
 	// Cancel is an optional channel whose closure indicates that
 	// the dial should be canceled. Not all types of dials support
 	// cancellation.
@@ -96,6 +132,16 @@ type Dialer struct {
 	// necessarily the ones passed to Dial. For example, passing "tcp" to Dial
 	// will cause the Control function to be called with "tcp4" or "tcp6".
 	Control func(network, address string, c syscall.RawConn) error
+
+	// MultipathTCP specifies whether TCP connections use Multipath
+	// TCP (RFC 8684), if supported by the operating system. If the
+	// operating system does not support Multipath TCP, connections
+	// use plain TCP instead; if the peer does not support it, the
+	// connection falls back to plain TCP during the handshake. The
+	// MultipathTCP method of TCPConn reports which was used.
+	//
+	// Multipath TCP is currently supported only on Linux.
+	MultipathTCP bool
 }
 
 func (d *Dialer) dualStack() bool { return d.FallbackDelay >= 0 }
@@ -222,6 +268,14 @@ func (r *Resolver) resolveAddrList(ctx context.Context, op, network, addr string
 	if err != nil || op != "dial" || hint == nil {
 		return addrs, err
 	}
+	return filterLocalAddrFamily(addrs, hint)
+}
+
+// filterLocalAddrFamily returns the addresses in addrs that can be
+// dialed from the local address hint, reusing the storage of addrs.
+// It returns an error if an address is of a different type than hint
+// or if no address remains.
+func filterLocalAddrFamily(addrs addrList, hint Addr) (addrList, error) {
 	var (
 		tcp      *TCPAddr
 		udp      *UDPAddr
@@ -359,8 +413,10 @@ func (d *Dialer) Dial(network, address string) (Conn, error) {
 // connected, any expiration of the context will not affect the
 // connection.
 //
-// When using TCP, and the host in the address parameter resolves to multiple
-// network addresses, any dial timeout (from d.Timeout or ctx) is spread
+// When using TCP, and the host in the address parameter resolves to
+// multiple network addresses, connection attempts to the addresses are
+// raced as described for the FallbackDelay field. If Fast Fallback is
+// disabled, any dial timeout (from d.Timeout or ctx) is instead spread
 // over each consecutive dial, such that each is given an appropriate
 // fraction of the time to connect.
 // For example, if a host has 4 IP addresses and the timeout is 1 minute,
@@ -394,16 +450,7 @@ func (d *Dialer) DialContext(ctx context.Context, network, address string) (Conn
 		ctx = subCtx
 	}
 
-	// Shadow the nettrace (if any) during resolve so Connect events don't fire for DNS lookups.
-	resolveCtx := ctx
-	if trace, _ := ctx.Value(nettrace.TraceKey{}).(*nettrace.Trace); trace != nil {
-		shadow := *trace
-		shadow.ConnectStart = nil
-		shadow.ConnectDone = nil
-		resolveCtx = context.WithValue(resolveCtx, nettrace.TraceKey{}, &shadow)
-	}
-
-	addrs, err := d.resolver().resolveAddrList(resolveCtx, "dial", network, address, d.LocalAddr)
+	addrs, err := d.resolveDialAddrs(ctx, network, address)
 	if err != nil {
 		return nil, &OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: err}
 	}
@@ -414,18 +461,11 @@ func (d *Dialer) DialContext(ctx context.Context, network, address string) (Conn
 		address: address,
 	}
 
-	var primaries, fallbacks addrList
-	if d.dualStack() && network == "tcp" {
-		primaries, fallbacks = addrs.partition(isIPv4)
-	} else {
-		primaries = addrs
-	}
-
 	var c Conn
-	if len(fallbacks) > 0 {
-		c, err = sd.dialParallel(ctx, primaries, fallbacks)
+	if len(addrs) > 1 && d.racing(network) {
+		c, err = sd.dialRace(ctx, addrs)
 	} else {
-		c, err = sd.dialSerial(ctx, primaries)
+		c, err = sd.dialSerial(ctx, addrs)
 	}
 	if err != nil {
 		return nil, err
@@ -443,34 +483,111 @@ func (d *Dialer) DialContext(ctx context.Context, network, address string) (Conn
 	return c, nil
 }
 
-// dialParallel races two copies of dialSerial, giving the first a
-// head start. It returns the first established connection and
-// closes the others. Otherwise it returns an error from the first
-// primary address.
-func (sd *sysDialer) dialParallel(ctx context.Context, primaries, fallbacks addrList) (Conn, error) {
-	if len(fallbacks) == 0 {
-		return sd.dialSerial(ctx, primaries)
+// resolveDialAddrs returns the list of addresses to dial for network
+// and address, in the order in which they are to be tried.
+func (d *Dialer) resolveDialAddrs(ctx context.Context, network, address string) (addrList, error) {
+	lookup := func(ctx context.Context) ([]Addr, error) {
+		// Shadow the nettrace (if any) during resolve so Connect events don't fire for DNS lookups.
+		if trace, _ := ctx.Value(nettrace.TraceKey{}).(*nettrace.Trace); trace != nil {
+			shadow := *trace
+			shadow.ConnectStart = nil
+			shadow.ConnectDone = nil
+			ctx = context.WithValue(ctx, nettrace.TraceKey{}, &shadow)
+		}
+		addrs, err := d.resolver().resolveAddrList(ctx, "dial", network, address, d.LocalAddr)
+		if err != nil {
+			return nil, err
+		}
+		if d.racing(network) {
+			addrs = sortForRacing(addrs, !d.DisableDialHistory)
+		}
+		return addrs, nil
+	}
+	afnet, _, err := parseNetwork(ctx, network, true)
+	if err != nil {
+		return nil, err
+	}
+	if d.Resolve == nil || afnet == "unix" || afnet == "unixgram" || afnet == "unixpacket" {
+		return lookup(ctx)
 	}
 
+	addrs, err := d.Resolve(ctx, network, address, lookup)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &AddrError{Err: errNoSuitableAddress.Error(), Addr: address}
+	}
+	// The address family suffix ("tcp4") does not appear in
+	// Addr.Network, so check the family of the IP address instead.
+	family := afnet[len(afnet)-1]
+	if family == '4' || family == '6' {
+		afnet = afnet[:len(afnet)-1]
+	}
+	for _, addr := range addrs {
+		if addr == nil || addr.Network() != afnet ||
+			family == '4' && !isIPv4(addr) || family == '6' && isIPv4(addr) {
+			return nil, &AddrError{Err: "mismatched resolved address type", Addr: address}
+		}
+	}
+	if d.LocalAddr == nil {
+		return addrList(addrs), nil
+	}
+	// Copy addrs, which belongs to Resolve, before filtering it.
+	return filterLocalAddrFamily(append(addrList(nil), addrs...), d.LocalAddr)
+}
+
+// racing reports whether TCP connection attempts to multiple
+// addresses should be raced against each other, rather than made
+// one at a time.
+func (d *Dialer) racing(network string) bool {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return d.dualStack()
+	}
+	return false
+}
+
+// dialRace connects to the addresses in ras using the connection
+// racing algorithm of RFC 8305, section 5. Attempts are started in
+// order, each one after the previous attempt fails or after the
+// fallback delay has passed, whichever comes first. It returns the
+// first established connection, canceling and closing the others.
+// Otherwise it returns the error from the first address.
+func (sd *sysDialer) dialRace(ctx context.Context, ras addrList) (Conn, error) {
+	if len(ras) < 2 {
+		return sd.dialSerial(ctx, ras)
+	}
+
+	// Canceled when we return, to stop the attempts that lost.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	returned := make(chan struct{})
 	defer close(returned)
 
 	type dialResult struct {
 		Conn
 		error
-		primary bool
-		done    bool
+		index int
 	}
 	results := make(chan dialResult) // unbuffered
 
-	startRacer := func(ctx context.Context, primary bool) {
-		ras := primaries
-		if !primary {
-			ras = fallbacks
+	startAttempt := func(i int) {
+		ra := ras[i]
+		start := time.Now()
+		c, err := sd.dialSingle(ctx, ra)
+		if !sd.DisableDialHistory && (err == nil || ctx.Err() == nil) {
+			// Don't blame the address for attempts we canceled.
+			recordDialResult(ra, time.Since(start), err)
+		}
+		if err == nil {
+			// We have a winner; stop the other attempts and
+			// don't start any more.
+			cancel()
 		}
-		c, err := sd.dialSerial(ctx, ras)
 		select {
-		case results <- dialResult{Conn: c, error: err, primary: primary, done: true}:
+		case results <- dialResult{Conn: c, error: err, index: i}:
 		case <-returned:
 			if c != nil {
 				c.Close()
@@ -478,42 +595,54 @@ func (sd *sysDialer) dialParallel(ctx context.Context, primaries, fallbacks addr
 		}
 	}
 
-	var primary, fallback dialResult
-
-	// Start the main racer.
-	primaryCtx, primaryCancel := context.WithCancel(ctx)
-	defer primaryCancel()
-	go startRacer(primaryCtx, true)
+	var (
+		next     = 0 // index of next address to try
+		pending  = 0 // number of attempts in progress
+		firstErr dialResult
+	)
+	firstErr.index = len(ras)
+	start := func() {
+		go startAttempt(next)
+		next++
+		pending++
+	}
 
-	// Start the timer for the fallback racer.
-	fallbackTimer := time.NewTimer(sd.fallbackDelay())
-	defer fallbackTimer.Stop()
+	start()
+	delay := sd.fallbackDelay()
+	timer := time.NewTimer(delay)
+	defer func() { timer.Stop() }()
 
 	for {
+		var timerC <-chan time.Time
+		if next < len(ras) {
+			timerC = timer.C
+		}
 		select {
-		case <-fallbackTimer.C:
-			fallbackCtx, fallbackCancel := context.WithCancel(ctx)
-			defer fallbackCancel()
-			go startRacer(fallbackCtx, false)
+		case <-timerC:
+			if ctx.Err() != nil {
+				// Canceled, or an attempt has already
+				// succeeded. Wait for the pending attempts.
+				break
+			}
+			start()
+			timer = time.NewTimer(delay)
 
 		case res := <-results:
+			pending--
 			if res.error == nil {
 				return res.Conn, nil
 			}
-			if res.primary {
-				primary = res
-			} else {
-				fallback = res
+			if res.index < firstErr.index {
+				firstErr = res
 			}
-			if primary.done && fallback.done {
-				return nil, primary.error
-			}
-			if res.primary && fallbackTimer.Stop() {
-				// If we were able to stop the timer, that means it
-				// was running (hadn't yet started the fallback), but
-				// we just got an error on the primary path, so start
-				// the fallback immediately (in 0 nanoseconds).
-				fallbackTimer.Reset(0)
+			if next < len(ras) && ctx.Err() == nil {
+				// Start the next attempt immediately
+				// (RFC 8305, section 5).
+				timer.Stop()
+				start()
+				timer = time.NewTimer(delay)
+			} else if pending == 0 {
+				return nil, firstErr.error
 			}
 		}
 	}
@@ -580,7 +709,11 @@ func (sd *sysDialer) dialSingle(ctx context.Context, ra Addr) (c Conn, err error
 	switch ra := ra.(type) {
 	case *TCPAddr:
 		la, _ := la.(*TCPAddr)
-		c, err = sd.dialTCP(ctx, la, ra)
+		if sd.MultipathTCP {
+			c, err = sd.dialMPTCP(ctx, la, ra)
+		} else {
+			c, err = sd.dialTCP(ctx, la, ra)
+		}
 	case *UDPAddr:
 		la, _ := la.(*UDPAddr)
 		c, err = sd.dialUDP(ctx, la, ra)
@@ -616,6 +749,15 @@ type ListenConfig struct {
 	// that do not support keep-alives ignore this field.
 	// If negative, keep-alives are disabled.
 	KeepAlive time.Duration
+
+	// MultipathTCP specifies whether TCP listeners accept Multipath
+	// TCP (RFC 8684) connections, if supported by the operating
+	// system. Such listeners still accept plain TCP connections.
+	// If the operating system does not support Multipath TCP, the
+	// listener uses plain TCP instead.
+	//
+	// Multipath TCP is currently supported only on Linux.
+	MultipathTCP bool
 }
 
 // Listen announces on the local network address.
@@ -636,7 +778,11 @@ func (lc *ListenConfig) Listen(ctx context.Context, network, address string) (Li
 	la := addrs.first(isIPv4)
 	switch la := la.(type) {
 	case *TCPAddr:
-		l, err = sl.listenTCP(ctx, la)
+		if sl.MultipathTCP {
+			l, err = sl.listenMPTCP(ctx, la)
+		} else {
+			l, err = sl.listenTCP(ctx, la)
+		}
 	case *UnixAddr:
 		l, err = sl.listenUnix(ctx, la)
 	default:
@@ -10,12 +10,14 @@ package net
 import (
 	"bufio"
 	"context"
+	"errors"
 	"internal/testenv"
 	"io"
 	"os"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -178,7 +180,8 @@ func dialClosedPort(t *testing.T) (dialLatency time.Duration) {
 	return elapsed
 }
 
-func TestDialParallel(t *testing.T) {
+func TestDialRace(t *testing.T) {
+	defer resetDialHistory()
 	testenv.MustHaveExternalNetwork(t)
 
 	if !supportsIPv4() || !supportsIPv6() {
@@ -213,33 +216,29 @@ func TestDialParallel(t *testing.T) {
 	}
 
 	var testCases = []struct {
-		primaries       []string
-		fallbacks       []string
+		addrs           []string
 		teardownNetwork string
 		expectOk        bool
 		expectElapsed   time.Duration
 	}{
 		// These should just work on the first try.
-		{[]string{"127.0.0.1"}, []string{}, "", true, instant},
-		{[]string{"::1"}, []string{}, "", true, instant},
-		{[]string{"127.0.0.1", "::1"}, []string{slowDst6}, "tcp6", true, instant},
-		{[]string{"::1", "127.0.0.1"}, []string{slowDst4}, "tcp4", true, instant},
-		// Primary is slow; fallback should kick in.
-		{[]string{slowDst4}, []string{"::1"}, "", true, fallbackDelay},
-		// Skip a "connection refused" in the primary thread.
-		{[]string{"127.0.0.1", "::1"}, []string{}, "tcp4", true, closedPortDelay},
-		{[]string{"::1", "127.0.0.1"}, []string{}, "tcp6", true, closedPortDelay},
-		// Skip a "connection refused" in the fallback thread.
-		{[]string{slowDst4, slowDst6}, []string{"::1", "127.0.0.1"}, "tcp6", true, fallbackDelay + closedPortDelay},
-		// Primary refused, fallback without delay.
-		{[]string{"127.0.0.1"}, []string{"::1"}, "tcp4", true, closedPortOrFallbackDelay},
-		{[]string{"::1"}, []string{"127.0.0.1"}, "tcp6", true, closedPortOrFallbackDelay},
+		{[]string{"127.0.0.1"}, "", true, instant},
+		{[]string{"::1"}, "", true, instant},
+		{[]string{"127.0.0.1", slowDst6}, "tcp6", true, instant},
+		{[]string{"::1", slowDst4}, "tcp4", true, instant},
+		// First address is slow; the next attempt should kick in.
+		{[]string{slowDst4, "::1"}, "", true, fallbackDelay},
+		// A "connection refused" starts the next attempt without
+		// waiting for the fallback delay.
+		{[]string{"127.0.0.1", "::1"}, "tcp4", true, closedPortOrFallbackDelay},
+		{[]string{"::1", "127.0.0.1"}, "tcp6", true, closedPortOrFallbackDelay},
+		{[]string{slowDst4, "::1", slowDst6, "127.0.0.1"}, "tcp6", true, fallbackDelay + closedPortOrFallbackDelay + fallbackDelay},
 		// Everything is refused.
-		{[]string{"127.0.0.1"}, []string{}, "tcp4", false, closedPortDelay},
+		{[]string{"127.0.0.1"}, "tcp4", false, closedPortDelay},
 		// Nothing to do; fail instantly.
-		{[]string{}, []string{}, "", false, instant},
+		{[]string{}, "", false, instant},
 		// Connecting to tons of addresses should not trip the deadline.
-		{nCopies("::1", 1000), []string{}, "", true, instant},
+		{nCopies("::1", 1000), "", true, instant},
 	}
 
 	handler := func(dss *dualStackServer, ln Listener) {
@@ -279,8 +278,7 @@ func TestDialParallel(t *testing.T) {
 			dss.teardownNetwork(tt.teardownNetwork)
 		}
 
-		primaries := makeAddrs(tt.primaries, dss.port)
-		fallbacks := makeAddrs(tt.fallbacks, dss.port)
+		addrs := makeAddrs(tt.addrs, dss.port)
 		d := Dialer{
 			FallbackDelay: fallbackDelay,
 		}
@@ -290,7 +288,7 @@ func TestDialParallel(t *testing.T) {
 			network: "tcp",
 			address: "?",
 		}
-		c, err := sd.dialParallel(context.Background(), primaries, fallbacks)
+		c, err := sd.dialRace(context.Background(), addrs)
 		elapsed := time.Since(startTime)
 
 		if c != nil {
@@ -327,7 +325,7 @@ func TestDialParallel(t *testing.T) {
 			wg.Done()
 		}()
 		startTime = time.Now()
-		c, err = sd.dialParallel(ctx, primaries, fallbacks)
+		c, err = sd.dialRace(ctx, addrs)
 		if c != nil {
 			c.Close()
 		}
@@ -353,6 +351,7 @@ func lookupSlowFast(ctx context.Context, fn func(context.Context, string, string
 }
 
 func TestDialerFallbackDelay(t *testing.T) {
+	defer resetDialHistory()
 	testenv.MustHaveExternalNetwork(t)
 
 	if !supportsIPv4() || !supportsIPv6() {
@@ -399,6 +398,7 @@ func TestDialerFallbackDelay(t *testing.T) {
 	}
 
 	for i, tt := range testCases {
+		resetDialHistory()
 		d := &Dialer{DualStack: tt.dualstack, FallbackDelay: tt.delay}
 
 		startTime := time.Now()
@@ -420,7 +420,8 @@ func TestDialerFallbackDelay(t *testing.T) {
 	}
 }
 
-func TestDialParallelSpuriousConnection(t *testing.T) {
+func TestDialRaceSpuriousConnection(t *testing.T) {
+	defer resetDialHistory()
 	if !supportsIPv4() || !supportsIPv6() {
 		t.Skip("both IPv4 and IPv6 are required")
 	}
@@ -465,7 +466,7 @@ func TestDialParallelSpuriousConnection(t *testing.T) {
 	defer func() { testHookDialTCP = origTestHookDialTCP }()
 	testHookDialTCP = func(ctx context.Context, net string, laddr, raddr *TCPAddr) (*TCPConn, error) {
 		// Sleep long enough for Happy Eyeballs to kick in, and inhibit cancellation.
-		// This forces dialParallel to juggle two successful connections.
+		// This forces dialRace to juggle two successful connections.
 		time.Sleep(fallbackDelay * 2)
 
 		// Now ignore the provided context (which will be canceled) and use a
@@ -484,16 +485,16 @@ func TestDialParallelSpuriousConnection(t *testing.T) {
 		address: "?",
 	}
 
-	makeAddr := func(ip string) addrList {
+	makeAddr := func(ip string) Addr {
 		addr, err := ResolveTCPAddr("tcp", JoinHostPort(ip, dss.port))
 		if err != nil {
 			t.Fatal(err)
 		}
-		return addrList{addr}
+		return addr
 	}
 
-	// dialParallel returns one connection (and closes the other.)
-	c, err := sd.dialParallel(context.Background(), makeAddr("127.0.0.1"), makeAddr("::1"))
+	// dialRace returns one connection (and closes the other.)
+	c, err := sd.dialRace(context.Background(), addrList{makeAddr("127.0.0.1"), makeAddr("::1")})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -503,6 +504,41 @@ func TestDialParallelSpuriousConnection(t *testing.T) {
 	wg.Wait()
 }
 
+func TestDialRaceNoAttemptAfterCancel(t *testing.T) {
+	const fallbackDelay = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var attempts int32
+	origTestHookDialTCP := testHookDialTCP
+	defer func() { testHookDialTCP = origTestHookDialTCP }()
+	testHookDialTCP = func(dialCtx context.Context, net string, laddr, raddr *TCPAddr) (*TCPConn, error) {
+		// Cancel the dial and outlast the fallback delay,
+		// so that the fallback timer fires after the cancellation.
+		atomic.AddInt32(&attempts, 1)
+		cancel()
+		time.Sleep(fallbackDelay * 5)
+		return nil, dialCtx.Err()
+	}
+
+	sd := &sysDialer{
+		Dialer:  Dialer{FallbackDelay: fallbackDelay},
+		network: "tcp",
+		address: "?",
+	}
+	addrs := addrList{
+		&TCPAddr{IP: ParseIP("192.0.2.1"), Port: 80},
+		&TCPAddr{IP: ParseIP("2001:db8::1"), Port: 80},
+	}
+	if c, err := sd.dialRace(ctx, addrs); err == nil {
+		c.Close()
+		t.Fatal("dialRace succeeded; want error")
+	}
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Errorf("dialRace made %d connection attempts; want 1", n)
+	}
+}
+
 func TestDialerPartialDeadline(t *testing.T) {
 	now := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
 	var testCases = []struct {
@@ -653,6 +689,7 @@ func TestDialerLocalAddr(t *testing.T) {
 }
 
 func TestDialerDualStack(t *testing.T) {
+	defer resetDialHistory()
 	testenv.SkipFlaky(t, 13324)
 
 	if !supportsIPv4() || !supportsIPv6() {
@@ -909,6 +946,167 @@ func TestDialListenerAddr(t *testing.T) {
 	c.Close()
 }
 
+func TestDialerResolve(t *testing.T) {
+	ln, err := newLocalListener("tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	defer func() {
+		ln.Close()
+		<-done
+	}()
+	go func() {
+		defer close(done)
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	lnAddr := ln.Addr().(*TCPAddr)
+
+	t.Run("ServiceDiscovery", func(t *testing.T) {
+		d := Dialer{
+			Resolve: func(ctx context.Context, network, address string, lookup func(context.Context) ([]Addr, error)) ([]Addr, error) {
+				if network != "tcp" || address != "backend.service.invalid:http" {
+					t.Errorf("Resolve called with %q, %q", network, address)
+				}
+				return []Addr{lnAddr}, nil
+			},
+		}
+		c, err := d.Dial("tcp", "backend.service.invalid:http")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if got := c.RemoteAddr().String(); got != lnAddr.String() {
+			t.Errorf("RemoteAddr = %v; want %v", got, lnAddr)
+		}
+	})
+
+	t.Run("Observe", func(t *testing.T) {
+		var seen []Addr
+		d := Dialer{
+			Resolve: func(ctx context.Context, network, address string, lookup func(context.Context) ([]Addr, error)) ([]Addr, error) {
+				addrs, err := lookup(ctx)
+				seen = addrs
+				return addrs, err
+			},
+		}
+		c, err := d.Dial("tcp", lnAddr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+		if len(seen) != 1 || seen[0].String() != lnAddr.String() {
+			t.Errorf("lookup returned %v; want [%v]", seen, lnAddr)
+		}
+	})
+
+	t.Run("LocalAddr", func(t *testing.T) {
+		v6 := &TCPAddr{IP: ParseIP("2001:db8::1"), Port: lnAddr.Port}
+		resolved := []Addr{v6, lnAddr}
+		d := Dialer{
+			LocalAddr: &TCPAddr{IP: lnAddr.IP},
+			Resolve: func(context.Context, string, string, func(context.Context) ([]Addr, error)) ([]Addr, error) {
+				return resolved, nil
+			},
+		}
+		c, err := d.Dial("tcp", "backend.service.invalid:http")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+		if resolved[0] != v6 || resolved[1] != lnAddr {
+			t.Errorf("Dial modified the addresses returned by Resolve: %v", resolved)
+		}
+
+		resolved = []Addr{v6}
+		if c, err := d.Dial("tcp", "backend.service.invalid:http"); err == nil {
+			c.Close()
+			t.Errorf("Dial to only IPv6 addresses from IPv4 %v succeeded; want error", d.LocalAddr)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		errLookup := errors.New("lookup failed")
+		for _, tt := range []struct {
+			name    string
+			network string
+			addrs   []Addr
+			err     error
+			wantErr error
+		}{
+			{"error", "tcp", nil, errLookup, errLookup},
+			{"empty", "tcp", nil, nil, nil},
+			{"mismatched", "tcp", []Addr{&UDPAddr{IP: lnAddr.IP, Port: lnAddr.Port}}, nil, nil},
+			{"IPv6 for tcp4", "tcp4", []Addr{&TCPAddr{IP: ParseIP("::1"), Port: lnAddr.Port}}, nil, nil},
+			{"IPv4 for tcp6", "tcp6", []Addr{&TCPAddr{IP: ParseIP("127.0.0.1"), Port: lnAddr.Port}}, nil, nil},
+			{"IPv6 for ip4", "ip4:icmp", []Addr{&IPAddr{IP: ParseIP("::1")}}, nil, nil},
+		} {
+			d := Dialer{
+				Resolve: func(context.Context, string, string, func(context.Context) ([]Addr, error)) ([]Addr, error) {
+					return tt.addrs, tt.err
+				},
+			}
+			address := lnAddr.String()
+			if strings.HasPrefix(tt.network, "ip") {
+				address = lnAddr.IP.String()
+			}
+			c, err := d.Dial(tt.network, address)
+			if err == nil {
+				c.Close()
+				t.Errorf("%s: Dial succeeded; want error", tt.name)
+				continue
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: Dial error = %v; want %v", tt.name, err, tt.wantErr)
+			}
+		}
+	})
+}
+
+func TestDialerDisableDialHistory(t *testing.T) {
+	ln, err := newLocalListener("tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	lnAddr := ln.Addr().(*TCPAddr)
+
+	defer resetDialHistory()
+	for _, disable := range []bool{true, false} {
+		resetDialHistory()
+		d := Dialer{
+			DisableDialHistory: disable,
+			Resolve: func(context.Context, string, string, func(context.Context) ([]Addr, error)) ([]Addr, error) {
+				// Two addresses, so that the connection attempts are raced.
+				return []Addr{lnAddr, lnAddr}, nil
+			},
+		}
+		c, err := d.Dial("tcp", lnAddr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+		if _, ok := lookupDialHistory(lnAddr, time.Now()); ok == disable {
+			t.Errorf("DisableDialHistory = %v: dial history has entry for %v = %v", disable, lnAddr, ok)
+		}
+	}
+}
+
 func TestDialerControl(t *testing.T) {
 	switch runtime.GOOS {
 	case "plan9":
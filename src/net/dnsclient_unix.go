@@ -40,6 +40,7 @@ var (
 	errServerMisbehaving         = errors.New("server misbehaving")
 	errInvalidDNSResponse        = errors.New("invalid DNS response")
 	errNoAnswerFromDNSServer     = errors.New("no answer from DNS server")
+	errNoDNSOverTLS              = errors.New("GODEBUG=netdns=dot requires Resolver.DialTLS to be set")
 
 	// errServerTemporarilyMisbehaving is like errServerMisbehaving, except
 	// that when it gets translated to a DNSError, the IsTemporary field
@@ -105,7 +106,7 @@ func dnsPacketRoundTrip(c Conn, id uint16, query dnsmessage.Question, b []byte)
 	}
 }
 
-func dnsStreamRoundTrip(c Conn, id uint16, query dnsmessage.Question, b []byte) (dnsmessage.Parser, dnsmessage.Header, error) {
+func dnsStreamRoundTrip(c io.ReadWriter, id uint16, query dnsmessage.Question, b []byte) (dnsmessage.Parser, dnsmessage.Header, error) {
 	if _, err := c.Write(b); err != nil {
 		return dnsmessage.Parser{}, dnsmessage.Header{}, err
 	}
@@ -144,6 +145,12 @@ func (r *Resolver) exchange(ctx context.Context, server string, q dnsmessage.Que
 	if err != nil {
 		return dnsmessage.Parser{}, dnsmessage.Header{}, errCannotMarshalDNSMessage
 	}
+	if r != nil && r.ExchangeHTTPS != nil {
+		return r.exchangeHTTPS(ctx, q, udpReq, timeout)
+	}
+	if r.dnsOverTLS() {
+		return r.exchangeTLS(ctx, server, id, q, tcpReq, timeout)
+	}
 	var networks []string
 	if useTCP {
 		networks = []string{"tcp"}
@@ -183,6 +190,82 @@ func (r *Resolver) exchange(ctx context.Context, server string, q dnsmessage.Que
 	return dnsmessage.Parser{}, dnsmessage.Header{}, errNoAnswerFromDNSServer
 }
 
+// exchangeTLS sends a query to server using DNS over TLS. It reuses an
+// idle connection to server if there is one, and keeps the connection
+// for later queries once the response has been read.
+func (r *Resolver) exchangeTLS(ctx context.Context, server string, id uint16, q dnsmessage.Question, req []byte, timeout time.Duration) (dnsmessage.Parser, dnsmessage.Header, error) {
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(timeout))
+	defer cancel()
+
+	conns := r.getTLSConns()
+	for {
+		c := conns.get(server)
+		reused := c != nil
+		if !reused {
+			var err error
+			c, err = r.dialTLS(ctx, server)
+			if err != nil {
+				return dnsmessage.Parser{}, dnsmessage.Header{}, err
+			}
+		}
+		if d, ok := ctx.Deadline(); ok && !d.IsZero() {
+			c.SetDeadline(d)
+		}
+		p, h, err := dnsStreamRoundTrip(c, id, q, req)
+		if err != nil {
+			c.Close()
+			if reused && ctx.Err() == nil {
+				// The server may have closed the idle
+				// connection. Try another one.
+				continue
+			}
+			return dnsmessage.Parser{}, dnsmessage.Header{}, mapErr(err)
+		}
+		if err := p.SkipQuestion(); err != dnsmessage.ErrSectionDone {
+			c.Close()
+			return dnsmessage.Parser{}, dnsmessage.Header{}, errInvalidDNSResponse
+		}
+		conns.put(server, c)
+		if h.Truncated {
+			// There is no larger transport to retry over.
+			return dnsmessage.Parser{}, dnsmessage.Header{}, errNoAnswerFromDNSServer
+		}
+		return p, h, nil
+	}
+}
+
+// exchangeHTTPS sends a query using the Resolver's ExchangeHTTPS
+// function. The query ID is 0, as recommended by RFC 8484, section 4.1.
+func (r *Resolver) exchangeHTTPS(ctx context.Context, q dnsmessage.Question, req []byte, timeout time.Duration) (dnsmessage.Parser, dnsmessage.Header, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req[0], req[1] = 0, 0
+	resp, err := r.ExchangeHTTPS(ctx, req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = errTimeout
+		}
+		return dnsmessage.Parser{}, dnsmessage.Header{}, mapErr(err)
+	}
+	var p dnsmessage.Parser
+	h, err := p.Start(resp)
+	if err != nil {
+		return dnsmessage.Parser{}, dnsmessage.Header{}, errCannotUnmarshalDNSMessage
+	}
+	respQ, err := p.Question()
+	if err != nil {
+		return dnsmessage.Parser{}, dnsmessage.Header{}, errCannotUnmarshalDNSMessage
+	}
+	if !checkResponse(0, q, h, respQ) {
+		return dnsmessage.Parser{}, dnsmessage.Header{}, errInvalidDNSResponse
+	}
+	if err := p.SkipQuestion(); err != dnsmessage.ErrSectionDone {
+		return dnsmessage.Parser{}, dnsmessage.Header{}, errInvalidDNSResponse
+	}
+	return p, h, nil
+}
+
 // checkHeader performs basic sanity checks on the header.
 func checkHeader(p *dnsmessage.Parser, h dnsmessage.Header) error {
 	if h.RCode == dnsmessage.RCodeNameError {
@@ -237,8 +320,13 @@ func skipToAnswer(p *dnsmessage.Parser, qtype dnsmessage.Type) error {
 // (otherwise answer will not find the answers).
 func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string, qtype dnsmessage.Type) (dnsmessage.Parser, string, error) {
 	var lastErr error
+	servers := cfg.servers
+	if r != nil && r.ExchangeHTTPS != nil {
+		// The ExchangeHTTPS function chooses the server.
+		servers = []string{""}
+	}
 	serverOffset := cfg.serverOffset()
-	sLen := uint32(len(cfg.servers))
+	sLen := uint32(len(servers))
 
 	n, err := dnsmessage.NewName(name)
 	if err != nil {
@@ -252,7 +340,7 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 
 	for i := 0; i < cfg.attempts; i++ {
 		for j := uint32(0); j < sLen; j++ {
-			server := cfg.servers[(serverOffset+j)%sLen]
+			server := servers[(serverOffset+j)%sLen]
 
 			p, h, err := r.exchange(ctx, server, q, cfg.timeout, cfg.useTCP)
 			if err != nil {
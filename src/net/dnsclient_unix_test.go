@@ -2121,3 +2121,246 @@ func TestNullMX(t *testing.T) {
 		t.Errorf("records = [%v]; want [%v]", strings.Join(records, " "), want[0])
 	}
 }
+
+func TestDNSOverTLS(t *testing.T) {
+	fake := fakeDNSServer{
+		rh: func(n, s string, q dnsmessage.Message, _ time.Time) (dnsmessage.Message, error) {
+			if n != "tcp" || s != "192.0.2.1:853" {
+				t.Errorf("DialTLS called with %s %s; want tcp 192.0.2.1:853", n, s)
+			}
+			r := dnsmessage.Message{
+				Header: dnsmessage.Header{
+					ID:       q.Header.ID,
+					Response: true,
+					RCode:    dnsmessage.RCodeSuccess,
+				},
+				Questions: q.Questions,
+			}
+			return r, nil
+		},
+	}
+	r := Resolver{
+		DialTLS: fake.DialContext,
+		Dial: func(_ context.Context, n, s string) (Conn, error) {
+			t.Errorf("Dial called with %s %s; want DialTLS", n, s)
+			return nil, errors.New("unexpected Dial")
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, _, err := r.exchange(ctx, "192.0.2.1:53", mustQuestion("com.", dnsmessage.TypeALL, dnsmessage.ClassINET), time.Second, useUDPOrTCP)
+	if err != nil {
+		t.Fatal("exchange failed:", err)
+	}
+}
+
+// closeTrackingConn is a Conn that fails once it has been closed.
+type closeTrackingConn struct {
+	Conn
+	closed bool
+}
+
+func (c *closeTrackingConn) Write(b []byte) (int, error) {
+	if c.closed {
+		return 0, errors.New("write on closed connection")
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *closeTrackingConn) Close() error {
+	c.closed = true
+	return c.Conn.Close()
+}
+
+func TestDNSOverTLSReusesConn(t *testing.T) {
+	fake := fakeDNSServer{
+		rh: func(n, s string, q dnsmessage.Message, _ time.Time) (dnsmessage.Message, error) {
+			r := dnsmessage.Message{
+				Header: dnsmessage.Header{
+					ID:       q.Header.ID,
+					Response: true,
+					RCode:    dnsmessage.RCodeSuccess,
+				},
+				Questions: q.Questions,
+			}
+			return r, nil
+		},
+	}
+	var conns []*closeTrackingConn
+	r := Resolver{
+		DialTLS: func(ctx context.Context, n, s string) (Conn, error) {
+			c, err := fake.DialContext(ctx, n, s)
+			if err != nil {
+				return nil, err
+			}
+			tc := &closeTrackingConn{Conn: c}
+			conns = append(conns, tc)
+			return tc, nil
+		},
+	}
+	exchange := func() {
+		t.Helper()
+		_, _, err := r.exchange(context.Background(), "192.0.2.1:53", mustQuestion("com.", dnsmessage.TypeALL, dnsmessage.ClassINET), time.Second, useUDPOrTCP)
+		if err != nil {
+			t.Fatal("exchange failed:", err)
+		}
+	}
+
+	exchange()
+	exchange()
+	if len(conns) != 1 {
+		t.Fatalf("DialTLS called %d times for two queries; want 1", len(conns))
+	}
+	if conns[0].closed {
+		t.Fatal("idle connection was closed")
+	}
+
+	// A connection closed while idle is replaced.
+	conns[0].closed = true
+	exchange()
+	if len(conns) != 2 {
+		t.Errorf("DialTLS called %d times after idle connection closed; want 2", len(conns))
+	}
+}
+
+// closeNotifyConn is a Conn that closes its done channel when closed.
+type closeNotifyConn struct {
+	Conn
+	done chan struct{}
+}
+
+func (c *closeNotifyConn) Close() error {
+	close(c.done)
+	return c.Conn.Close()
+}
+
+func TestDNSOverTLSIdleTimeout(t *testing.T) {
+	defer func(d time.Duration) { dnsConnIdleTimeout = d }(dnsConnIdleTimeout)
+	dnsConnIdleTimeout = time.Millisecond
+
+	fake := fakeDNSServer{
+		rh: func(n, s string, q dnsmessage.Message, _ time.Time) (dnsmessage.Message, error) {
+			r := dnsmessage.Message{
+				Header: dnsmessage.Header{
+					ID:       q.Header.ID,
+					Response: true,
+					RCode:    dnsmessage.RCodeSuccess,
+				},
+				Questions: q.Questions,
+			}
+			return r, nil
+		},
+	}
+	done := make(chan struct{})
+	r := Resolver{
+		DialTLS: func(ctx context.Context, n, s string) (Conn, error) {
+			c, err := fake.DialContext(ctx, n, s)
+			if err != nil {
+				return nil, err
+			}
+			return &closeNotifyConn{Conn: c, done: done}, nil
+		},
+	}
+	_, _, err := r.exchange(context.Background(), "192.0.2.1:53", mustQuestion("com.", dnsmessage.TypeALL, dnsmessage.ClassINET), time.Second, useUDPOrTCP)
+	if err != nil {
+		t.Fatal("exchange failed:", err)
+	}
+
+	// The idle connection is closed without another lookup.
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("idle connection not closed")
+	}
+	if c := r.tlsConns.get("192.0.2.1:53"); c != nil {
+		t.Errorf("tlsConns.get returned %v after idle timeout; want nil", c)
+	}
+}
+
+func TestDNSOverTLSUnavailable(t *testing.T) {
+	conf := systemConf()
+	defer func(dot bool) { conf.dnsOverTLS = dot }(conf.dnsOverTLS)
+	conf.dnsOverTLS = true
+
+	r := Resolver{
+		Dial: func(_ context.Context, n, s string) (Conn, error) {
+			t.Errorf("Dial called with %s %s; want no connection", n, s)
+			return nil, errors.New("unexpected Dial")
+		},
+	}
+	_, _, err := r.exchange(context.Background(), "192.0.2.1:53", mustQuestion("com.", dnsmessage.TypeALL, dnsmessage.ClassINET), time.Second, useUDPOrTCP)
+	if err != errNoDNSOverTLS {
+		t.Errorf("exchange = %v; want %v", err, errNoDNSOverTLS)
+	}
+}
+
+func TestDNSOverHTTPS(t *testing.T) {
+	defer dnsWaitGroup.Wait()
+
+	conf, err := newResolvConfTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conf.teardown()
+	if err := conf.writeAndUpdate([]string{"nameserver 192.0.2.1", "search example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var queried []string
+	r := Resolver{
+		ExchangeHTTPS: func(_ context.Context, query []byte) ([]byte, error) {
+			var q dnsmessage.Message
+			if err := q.Unpack(query); err != nil {
+				return nil, err
+			}
+			if q.ID != 0 {
+				t.Errorf("query ID = %d; want 0", q.ID)
+			}
+			name := q.Questions[0].Name.String()
+			mu.Lock()
+			queried = append(queried, name)
+			mu.Unlock()
+
+			resp := dnsmessage.Message{
+				Header: dnsmessage.Header{
+					Response: true,
+					RCode:    dnsmessage.RCodeNameError,
+				},
+				Questions: q.Questions,
+			}
+			if name == "www.example.com." {
+				resp.RCode = dnsmessage.RCodeSuccess
+				if q.Questions[0].Type == dnsmessage.TypeA {
+					resp.Answers = []dnsmessage.Resource{{
+						Header: dnsmessage.ResourceHeader{
+							Name:  q.Questions[0].Name,
+							Type:  dnsmessage.TypeA,
+							Class: dnsmessage.ClassINET,
+						},
+						Body: &dnsmessage.AResource{A: TestAddr},
+					}}
+				}
+			}
+			return resp.Pack()
+		},
+		Dial: func(_ context.Context, n, s string) (Conn, error) {
+			t.Errorf("Dial called with %s %s; want ExchangeHTTPS", n, s)
+			return nil, errors.New("unexpected Dial")
+		},
+	}
+	addrs, err := r.LookupHost(context.Background(), "www")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"192.0.2.1"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("LookupHost = %v; want %v", addrs, want)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range queried {
+		if name != "www.example.com." {
+			t.Errorf("queried %s; want only www.example.com.", name)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"sync"
+	"time"
+)
+
+// maxIdleDNSConnsPerServer bounds the number of idle DNS over TLS
+// connections kept for each name server. It is two because A and
+// AAAA queries for a host are sent concurrently.
+const maxIdleDNSConnsPerServer = 2
+
+// dnsConnIdleTimeout is how long an idle DNS over TLS connection is
+// kept for reuse before it is closed. Servers close idle connections
+// on their own schedule, and RFC 7766, section 6.2.3 suggests they
+// wait for at least a few seconds.
+// It is a variable for testing.
+var dnsConnIdleTimeout = 10 * time.Second
+
+// A dnsConnCache holds idle DNS over TLS connections, keyed by the
+// name server's address, so that later queries to the same server
+// avoid a TLS handshake (RFC 7858, section 3.4). Each idle
+// connection is closed once it has been idle for dnsConnIdleTimeout,
+// whether or not the cache is used again.
+// The zero value is an empty cache.
+type dnsConnCache struct {
+	mu   sync.Mutex
+	idle map[string][]*idleDNSConn
+}
+
+type idleDNSConn struct {
+	c     Conn
+	timer *time.Timer // closes c after dnsConnIdleTimeout
+}
+
+// get returns an idle connection to server, or nil if there is none.
+func (cc *dnsConnCache) get(server string) Conn {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for conns := cc.idle[server]; len(conns) > 0; conns = cc.idle[server] {
+		ic := conns[len(conns)-1]
+		cc.remove(server, len(conns)-1)
+		if ic.timer.Stop() {
+			return ic.c
+		}
+		// The idle timer fired, but its closeIdle call is waiting
+		// for cc.mu. It won't find ic any more, so close ic here.
+		ic.c.Close()
+	}
+	return nil
+}
+
+// put returns c, a connection to server that is no longer in use,
+// to the cache. It closes c if the cache is full.
+func (cc *dnsConnCache) put(server string, c Conn) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if len(cc.idle[server]) >= maxIdleDNSConnsPerServer {
+		c.Close()
+		return
+	}
+	if cc.idle == nil {
+		cc.idle = make(map[string][]*idleDNSConn)
+	}
+	ic := &idleDNSConn{c: c}
+	ic.timer = time.AfterFunc(dnsConnIdleTimeout, func() { cc.closeIdle(server, ic) })
+	cc.idle[server] = append(cc.idle[server], ic)
+}
+
+// closeIdle removes ic, an idle connection to server whose idle
+// timer has fired, from the cache and closes it. It does nothing if
+// ic was handed out by get in the meantime.
+func (cc *dnsConnCache) closeIdle(server string, ic *idleDNSConn) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for i, c := range cc.idle[server] {
+		if c == ic {
+			cc.remove(server, i)
+			ic.c.Close()
+			return
+		}
+	}
+}
+
+// remove deletes the i'th idle connection to server from the cache.
+// cc.mu must be held.
+func (cc *dnsConnCache) remove(server string, i int) {
+	conns := cc.idle[server]
+	if len(conns) == 1 {
+		delete(cc.idle, server)
+		return
+	}
+	cc.idle[server] = append(conns[:i:i], conns[i+1:]...)
+}
@@ -0,0 +1,181 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// This file implements the address sorting half of Happy Eyeballs
+// Version 2 (RFC 8305). The connection racing half is in
+// sysDialer.dialRace.
+
+const (
+	// dialHistoryTTL is how long the outcome of a connection
+	// attempt influences the order of later attempts.
+	dialHistoryTTL = 10 * time.Minute
+
+	// maxDialHistory bounds the number of destination addresses
+	// whose outcomes are remembered.
+	maxDialHistory = 1024
+)
+
+// A dialRecord is the outcome of the most recent connection
+// attempt to a destination address.
+type dialRecord struct {
+	rtt    time.Duration // time to establish the connection; zero if failed
+	failed bool
+	when   time.Time
+}
+
+// dialHistory remembers recent connection outcomes, keyed by the
+// destination IP address. It is used to prefer addresses that have
+// recently been fast and to avoid addresses that have recently failed,
+// as suggested by RFC 8305, section 4. It is shared by all Dialers
+// that don't set DisableDialHistory.
+//
+// It holds at most maxDialHistory entries. Entries older than
+// dialHistoryTTL are ignored, and are dropped when looked up or when
+// room is needed for a new entry.
+var dialHistory struct {
+	sync.Mutex
+	m map[string]dialRecord
+}
+
+// dialHistoryKey returns the key under which outcomes for a are
+// recorded, or "" if a is not an IP-based address.
+func dialHistoryKey(a Addr) string {
+	switch a := a.(type) {
+	case *TCPAddr:
+		return ipEmptyString(a.IP) + "%" + a.Zone
+	case *UDPAddr:
+		return ipEmptyString(a.IP) + "%" + a.Zone
+	case *IPAddr:
+		return ipEmptyString(a.IP) + "%" + a.Zone
+	}
+	return ""
+}
+
+// recordDialResult records the outcome of a connection attempt to a
+// that took rtt and completed with err.
+func recordDialResult(a Addr, rtt time.Duration, err error) {
+	key := dialHistoryKey(a)
+	if key == "" {
+		return
+	}
+	now := time.Now()
+	r := dialRecord{rtt: rtt, failed: err != nil, when: now}
+	if r.failed {
+		r.rtt = 0
+	}
+
+	dialHistory.Lock()
+	defer dialHistory.Unlock()
+	if dialHistory.m == nil {
+		dialHistory.m = make(map[string]dialRecord)
+	}
+	if _, ok := dialHistory.m[key]; !ok && len(dialHistory.m) >= maxDialHistory {
+		for k, v := range dialHistory.m {
+			if now.Sub(v.when) > dialHistoryTTL {
+				delete(dialHistory.m, k)
+			}
+		}
+		if len(dialHistory.m) >= maxDialHistory {
+			// Still full; forget an arbitrary entry.
+			for k := range dialHistory.m {
+				delete(dialHistory.m, k)
+				break
+			}
+		}
+	}
+	dialHistory.m[key] = r
+}
+
+// lookupDialHistory returns the unexpired record for a, if any.
+func lookupDialHistory(a Addr, now time.Time) (dialRecord, bool) {
+	key := dialHistoryKey(a)
+	if key == "" {
+		return dialRecord{}, false
+	}
+	dialHistory.Lock()
+	defer dialHistory.Unlock()
+	r, ok := dialHistory.m[key]
+	if !ok {
+		return dialRecord{}, false
+	}
+	if now.Sub(r.when) > dialHistoryTTL {
+		delete(dialHistory.m, key)
+		return dialRecord{}, false
+	}
+	return r, true
+}
+
+// sortForRacing orders addrs for connection racing as described
+// by RFC 8305, section 4. If useHistory is true, addresses that
+// recently succeeded are moved to the front, fastest first; addresses
+// that recently failed are moved to the back; all others keep their
+// relative order. The address families are then interleaved, starting
+// with the family of the first address, so that a broken family delays
+// a connection by at most one attempt.
+func sortForRacing(addrs addrList, useHistory bool) addrList {
+	if len(addrs) < 2 {
+		return addrs
+	}
+	sorted := addrs
+	if useHistory {
+		sorted = sortByDialHistory(addrs)
+	}
+
+	primaries, fallbacks := sorted.partition(isIPv4)
+	out := make(addrList, 0, len(sorted))
+	for len(primaries) > 0 || len(fallbacks) > 0 {
+		if len(primaries) > 0 {
+			out = append(out, primaries[0])
+			primaries = primaries[1:]
+		}
+		if len(fallbacks) > 0 {
+			out = append(out, fallbacks[0])
+			fallbacks = fallbacks[1:]
+		}
+	}
+	return out
+}
+
+// sortByDialHistory returns a copy of addrs in which addresses that
+// recently succeeded come first, fastest first, and addresses that
+// recently failed come last.
+func sortByDialHistory(addrs addrList) addrList {
+	now := time.Now()
+	type rankedAddr struct {
+		addr Addr
+		rank int // 0: recently succeeded, 1: unknown, 2: recently failed
+		rtt  time.Duration
+	}
+	ranked := make([]rankedAddr, len(addrs))
+	for i, a := range addrs {
+		ranked[i] = rankedAddr{addr: a, rank: 1}
+		if r, ok := lookupDialHistory(a, now); ok {
+			if r.failed {
+				ranked[i].rank = 2
+			} else {
+				ranked[i].rank = 0
+				ranked[i].rtt = r.rtt
+			}
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].rank != ranked[j].rank {
+			return ranked[i].rank < ranked[j].rank
+		}
+		return ranked[i].rank == 0 && ranked[i].rtt < ranked[j].rtt
+	})
+	sorted := make(addrList, len(ranked))
+	for i, r := range ranked {
+		sorted[i] = r.addr
+	}
+	return sorted
+}
@@ -0,0 +1,144 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func resetDialHistory() {
+	dialHistory.Lock()
+	dialHistory.m = nil
+	dialHistory.Unlock()
+}
+
+func TestSortForRacing(t *testing.T) {
+	defer resetDialHistory()
+
+	tcp := func(ips ...string) addrList {
+		var addrs addrList
+		for _, ip := range ips {
+			addrs = append(addrs, &TCPAddr{IP: ParseIP(ip), Port: 80})
+		}
+		return addrs
+	}
+	errRefused := errors.New("refused")
+
+	tests := []struct {
+		name      string
+		history   func()
+		noHistory bool
+		in        addrList
+		want      addrList
+	}{
+		{
+			name: "interleave families",
+			in:   tcp("2001:db8::1", "2001:db8::2", "2001:db8::3", "192.0.2.1", "192.0.2.2"),
+			want: tcp("2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2", "2001:db8::3"),
+		},
+		{
+			name: "interleave starting with IPv4",
+			in:   tcp("192.0.2.1", "192.0.2.2", "2001:db8::1"),
+			want: tcp("192.0.2.1", "2001:db8::1", "192.0.2.2"),
+		},
+		{
+			name: "single family",
+			in:   tcp("192.0.2.1", "192.0.2.2", "192.0.2.3"),
+			want: tcp("192.0.2.1", "192.0.2.2", "192.0.2.3"),
+		},
+		{
+			name: "recent failure moves to back",
+			history: func() {
+				recordDialResult(&TCPAddr{IP: ParseIP("2001:db8::1")}, 0, errRefused)
+			},
+			in:   tcp("2001:db8::1", "2001:db8::2", "192.0.2.1"),
+			want: tcp("2001:db8::2", "192.0.2.1", "2001:db8::1"),
+		},
+		{
+			name: "recent successes move to front by RTT",
+			history: func() {
+				recordDialResult(&TCPAddr{IP: ParseIP("192.0.2.2")}, 20*time.Millisecond, nil)
+				recordDialResult(&TCPAddr{IP: ParseIP("192.0.2.3")}, 10*time.Millisecond, nil)
+			},
+			in:   tcp("2001:db8::1", "192.0.2.1", "192.0.2.2", "192.0.2.3"),
+			want: tcp("192.0.2.3", "2001:db8::1", "192.0.2.2", "192.0.2.1"),
+		},
+		{
+			name: "history disabled",
+			history: func() {
+				recordDialResult(&TCPAddr{IP: ParseIP("2001:db8::1")}, 0, errRefused)
+				recordDialResult(&TCPAddr{IP: ParseIP("192.0.2.2")}, 10*time.Millisecond, nil)
+			},
+			noHistory: true,
+			in:        tcp("2001:db8::1", "192.0.2.1", "192.0.2.2"),
+			want:      tcp("2001:db8::1", "192.0.2.1", "192.0.2.2"),
+		},
+	}
+	for _, tt := range tests {
+		resetDialHistory()
+		if tt.history != nil {
+			tt.history()
+		}
+		if got := sortForRacing(tt.in, !tt.noHistory); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: sortForRacing(%v) = %v; want %v", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDialHistoryBounded(t *testing.T) {
+	defer resetDialHistory()
+	resetDialHistory()
+	for i := 0; i < maxDialHistory+10; i++ {
+		ip := IPv4(10, byte(i>>16), byte(i>>8), byte(i))
+		recordDialResult(&TCPAddr{IP: ip}, time.Millisecond, nil)
+	}
+	dialHistory.Lock()
+	n := len(dialHistory.m)
+	dialHistory.Unlock()
+	if n > maxDialHistory {
+		t.Errorf("dial history has %d entries; want at most %d", n, maxDialHistory)
+	}
+}
+
+func TestDialHistoryExpires(t *testing.T) {
+	defer resetDialHistory()
+	resetDialHistory()
+	a := &TCPAddr{IP: ParseIP("192.0.2.1")}
+	recordDialResult(a, time.Millisecond, nil)
+	if _, ok := lookupDialHistory(a, time.Now()); !ok {
+		t.Fatalf("no dial history for %v", a)
+	}
+	if _, ok := lookupDialHistory(a, time.Now().Add(dialHistoryTTL+time.Second)); ok {
+		t.Errorf("expired dial history for %v was used", a)
+	}
+	dialHistory.Lock()
+	n := len(dialHistory.m)
+	dialHistory.Unlock()
+	if n != 0 {
+		t.Errorf("dial history has %d entries after expiry; want 0", n)
+	}
+}
+
+func TestSortForRacingWithoutHistoryDoesNotLock(t *testing.T) {
+	addrs := addrList{
+		&TCPAddr{IP: ParseIP("192.0.2.1")},
+		&TCPAddr{IP: ParseIP("2001:db8::1")},
+	}
+	dialHistory.Lock()
+	defer dialHistory.Unlock()
+	done := make(chan struct{})
+	go func() {
+		sortForRacing(addrs, false)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("sortForRacing without history waited for the dial history lock")
+	}
+}
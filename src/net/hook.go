@@ -6,6 +6,7 @@ package net
 
 import (
 	"context"
+	"runtime"
 	"time"
 )
 
@@ -23,4 +24,8 @@ var (
 		return fn(ctx, network, host)
 	}
 	testHookSetKeepAlive = func(time.Duration) {}
+
+	// testHookSystemResolverOnly reports whether lookups always go
+	// through the system resolver rather than Go's built-in one.
+	testHookSystemResolverOnly = runtime.GOOS == "windows" || runtime.GOOS == "plan9"
 )
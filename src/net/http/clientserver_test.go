@@ -9,6 +9,7 @@ package http_test
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/rand"
 	"crypto/sha1"
 	"crypto/tls"
@@ -19,7 +20,9 @@ import (
 	"net"
 	. "net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
 	"net/http/httputil"
+	"net/textproto"
 	"net/url"
 	"os"
 	"reflect"
@@ -453,6 +456,66 @@ func TestH12_AutoGzip_Disabled(t *testing.T) {
 	}.run(t)
 }
 
+func TestEarlyHints_h1(t *testing.T) { testEarlyHints(t, h1Mode) }
+func TestEarlyHints_h2(t *testing.T) { testEarlyHints(t, h2Mode) }
+
+func testEarlyHints(t *testing.T, h2 bool) {
+	defer afterTest(t)
+	cst := newClientServerTest(t, h2, HandlerFunc(func(w ResponseWriter, r *Request) {
+		h := w.Header()
+		h.Add("Link", "</style.css>; rel=preload; as=style")
+		h.Set("Content-Length", "5") // not sent with 1xx
+		w.WriteHeader(StatusEarlyHints)
+
+		h.Add("Link", "</script.js>; rel=preload; as=script")
+		w.WriteHeader(StatusEarlyHints)
+
+		w.Write([]byte("Hello"))
+	}))
+	defer cst.close()
+
+	var got [][]string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code != StatusEarlyHints {
+				t.Errorf("Got1xxResponse code = %d; want %d", code, StatusEarlyHints)
+			}
+			if cl := header.Get("Content-Length"); cl != "" {
+				t.Errorf("1xx response has Content-Length %q", cl)
+			}
+			got = append(got, header["Link"])
+			return nil
+		},
+	}
+	req, _ := NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), "GET", cst.ts.URL, nil)
+	res, err := cst.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	want := [][]string{
+		{"</style.css>; rel=preload; as=style"},
+		{"</style.css>; rel=preload; as=style", "</script.js>; rel=preload; as=script"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("1xx Link headers = %q; want %q", got, want)
+	}
+	if got, want := res.Header["Link"], want[1]; !reflect.DeepEqual(got, want) {
+		t.Errorf("final Link headers = %q; want %q", got, want)
+	}
+	if res.StatusCode != StatusOK {
+		t.Errorf("StatusCode = %d; want %d", res.StatusCode, StatusOK)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "Hello" {
+		t.Errorf("body = %q; want %q", body, "Hello")
+	}
+}
+
 // Test304Responses verifies that 304s don't declare that they're
 // chunking in their response headers and aren't allowed to produce
 // output.
@@ -0,0 +1,111 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build ignore
+// +build ignore
+
+// This program generates publicsuffix_table.go from the public suffix
+// list at https://publicsuffix.org/list/public_suffix_list.dat.
+//
+// Usage:
+//
+//	go run gen_publicsuffix.go [-file public_suffix_list.dat] [-version YYYY-MM-DD]
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+const listURL = "https://publicsuffix.org/list/public_suffix_list.dat"
+
+var (
+	file    = flag.String("file", "", "read the list from `file` instead of "+listURL)
+	version = flag.String("version", "", "`version` of the list; defaults to today's date")
+)
+
+func main() {
+	flag.Parse()
+
+	var r io.Reader
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	} else {
+		res, err := http.Get(listURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			log.Fatalf("fetching %s: %s", listURL, res.Status)
+		}
+		r = res.Body
+	}
+	if *version == "" {
+		*version = time.Now().UTC().Format("2006-01-02")
+	}
+
+	var rules []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := textproto.TrimString(s.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		// A rule ends at the first whitespace.
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			line = line[:i]
+		}
+		if strings.Contains(line, "`") {
+			log.Fatalf("invalid rule %q", line)
+		}
+		rules = append(rules, line)
+	}
+	if err := s.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `// Code generated by go run gen_publicsuffix.go. DO NOT EDIT.
+
+// The rules below are from the Public Suffix List (%s).
+// They are subject to the terms of the Mozilla Public License, v. 2.0.
+// If a copy of the MPL was not distributed with this file, You can
+// obtain one at https://mozilla.org/MPL/2.0/.
+
+package cookiejar
+
+// publicSuffixVersion is the version of the built-in public suffix list.
+const publicSuffixVersion = %q
+
+// publicSuffixRules holds the rules of the built-in public suffix list,
+// one per line.
+const publicSuffixRules = `+"`", listURL, *version)
+	buf.WriteString(strings.Join(rules, "\n"))
+	buf.WriteString("`\n")
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("publicsuffix_table.go", out, 0666); err != nil {
+		log.Fatal(err)
+	}
+}
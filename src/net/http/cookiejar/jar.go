@@ -3,6 +3,9 @@
 // license that can be found in the LICENSE file.
 
 // Package cookiejar implements an in-memory RFC 6265-compliant http.CookieJar.
+//
+// The contents of a Jar can be saved and restored using its Save and Load
+// methods, or its Entries and SetEntries methods for other storage formats.
 package cookiejar
 
 import (
@@ -30,8 +33,9 @@ import (
 // testing but it is not secure: it means that the HTTP server for foo.com can
 // set a cookie for bar.com.
 //
-// A public suffix list implementation is in the package
-// golang.org/x/net/publicsuffix.
+// DefaultPublicSuffixList returns an implementation built into this
+// package, and ParsePublicSuffixList creates one from a newer copy of
+// the list at https://publicsuffix.org/list/.
 type PublicSuffixList interface {
 	// PublicSuffix returns the public suffix of domain.
 	//
@@ -53,7 +57,7 @@ type Options struct {
 	//
 	// A nil value is valid and may be useful for testing but it is not
 	// secure: it means that the HTTP server for foo.co.uk can set a cookie
-	// for bar.co.uk.
+	// for bar.co.uk. Most programs should use DefaultPublicSuffixList.
 	PublicSuffixList PublicSuffixList
 }
 
@@ -424,15 +428,7 @@ func (j *Jar) newEntry(c *http.Cookie, now time.Time, defPath, host string) (e e
 	e.Value = c.Value
 	e.Secure = c.Secure
 	e.HttpOnly = c.HttpOnly
-
-	switch c.SameSite {
-	case http.SameSiteDefaultMode:
-		e.SameSite = "SameSite"
-	case http.SameSiteStrictMode:
-		e.SameSite = "SameSite=Strict"
-	case http.SameSiteLaxMode:
-		e.SameSite = "SameSite=Lax"
-	}
+	e.SameSite = sameSiteAttr(c.SameSite)
 
 	return e, false, nil
 }
@@ -0,0 +1,223 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cookiejar
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// An Entry is a cookie stored in a Jar, together with the attributes
+// the Jar keeps for it. Entries are used to save the contents of a Jar
+// and to restore them later, possibly in another process.
+type Entry struct {
+	Name  string
+	Value string
+
+	// Domain is the canonical host name to which the cookie is
+	// sent. If HostOnly is false, the cookie is also sent to the
+	// subdomains of Domain.
+	Domain   string
+	HostOnly bool
+
+	Path     string
+	SameSite http.SameSite
+	Secure   bool
+	HttpOnly bool
+
+	// Persistent reports whether the cookie has an expiry time.
+	// Cookies that are not persistent are session cookies, and
+	// their Expires field is the zero time.
+	Persistent bool
+	Expires    time.Time
+
+	Creation   time.Time
+	LastAccess time.Time
+}
+
+var errMalformedEntry = errors.New("cookiejar: malformed entry")
+
+// Entries returns the cookies in j that have not expired, ordered by
+// creation time.
+func (j *Jar) Entries() []Entry {
+	return j.entriesAt(time.Now())
+}
+
+// entriesAt is like Entries but takes the current time as a parameter.
+func (j *Jar) entriesAt(now time.Time) []Entry {
+	j.mu.Lock()
+	var all []entry
+	for _, submap := range j.entries {
+		for _, e := range submap {
+			if e.Persistent && !e.Expires.After(now) {
+				continue
+			}
+			all = append(all, e)
+		}
+	}
+	j.mu.Unlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].Creation.Equal(all[j].Creation) {
+			return all[i].Creation.Before(all[j].Creation)
+		}
+		return all[i].seqNum < all[j].seqNum
+	})
+	entries := make([]Entry, len(all))
+	for i, e := range all {
+		entries[i] = Entry{
+			Name:       e.Name,
+			Value:      e.Value,
+			Domain:     e.Domain,
+			HostOnly:   e.HostOnly,
+			Path:       e.Path,
+			SameSite:   sameSiteMode(e.SameSite),
+			Secure:     e.Secure,
+			HttpOnly:   e.HttpOnly,
+			Persistent: e.Persistent,
+			Creation:   e.Creation,
+			LastAccess: e.LastAccess,
+		}
+		if e.Persistent {
+			entries[i].Expires = e.Expires
+		}
+	}
+	return entries
+}
+
+// SetEntries adds entries, as returned by Entries, to j. They replace
+// cookies in j with the same name, domain and path, and expired
+// entries remove such cookies. If an entry is malformed or is not
+// permitted by j's public suffix list, SetEntries returns an error
+// and leaves j unchanged.
+func (j *Jar) SetEntries(entries []Entry) error {
+	return j.setEntries(entries, time.Now())
+}
+
+// setEntries is like SetEntries but takes the current time as a parameter.
+func (j *Jar) setEntries(entries []Entry, now time.Time) error {
+	es := make([]entry, len(entries))
+	for i, in := range entries {
+		e, err := j.entryFrom(in, now)
+		if err != nil {
+			return err
+		}
+		es[i] = e
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, e := range es {
+		key := jarKey(e.Domain, j.psList)
+		submap := j.entries[key]
+		id := e.id()
+		if e.Persistent && !e.Expires.After(now) {
+			if submap != nil {
+				delete(submap, id)
+				if len(submap) == 0 {
+					delete(j.entries, key)
+				}
+			}
+			continue
+		}
+		if submap == nil {
+			submap = make(map[string]entry)
+			j.entries[key] = submap
+		}
+		e.seqNum = j.nextSeqNum
+		j.nextSeqNum++
+		submap[id] = e
+	}
+	return nil
+}
+
+// entryFrom validates in and converts it to an entry.
+func (j *Jar) entryFrom(in Entry, now time.Time) (entry, error) {
+	domain, err := canonicalHost(in.Domain)
+	if err != nil || domain == "" || domain[0] == '.' || domain != in.Domain || in.Path == "" || in.Path[0] != '/' {
+		return entry{}, errMalformedEntry
+	}
+	if !in.HostOnly {
+		if isIP(domain) {
+			return entry{}, errNoHostname
+		}
+		if j.psList != nil {
+			if ps := j.psList.PublicSuffix(domain); ps != "" && !hasDotSuffix(domain, ps) {
+				return entry{}, errIllegalDomain
+			}
+		}
+	}
+	e := entry{
+		Name:       in.Name,
+		Value:      in.Value,
+		Domain:     domain,
+		Path:       in.Path,
+		SameSite:   sameSiteAttr(in.SameSite),
+		Secure:     in.Secure,
+		HttpOnly:   in.HttpOnly,
+		Persistent: in.Persistent,
+		HostOnly:   in.HostOnly,
+		Expires:    in.Expires,
+		Creation:   in.Creation,
+		LastAccess: in.LastAccess,
+	}
+	if !e.Persistent {
+		e.Expires = endOfTime
+	}
+	if e.Creation.IsZero() {
+		e.Creation = now
+	}
+	if e.LastAccess.IsZero() {
+		e.LastAccess = e.Creation
+	}
+	return e, nil
+}
+
+// sameSiteAttr converts m to the form stored in an entry.
+func sameSiteAttr(m http.SameSite) string {
+	switch m {
+	case http.SameSiteDefaultMode:
+		return "SameSite"
+	case http.SameSiteStrictMode:
+		return "SameSite=Strict"
+	case http.SameSiteLaxMode:
+		return "SameSite=Lax"
+	}
+	return ""
+}
+
+// sameSiteMode is the inverse of sameSiteAttr.
+func sameSiteMode(attr string) http.SameSite {
+	switch attr {
+	case "SameSite":
+		return http.SameSiteDefaultMode
+	case "SameSite=Strict":
+		return http.SameSiteStrictMode
+	case "SameSite=Lax":
+		return http.SameSiteLaxMode
+	}
+	return 0
+}
+
+// Save writes the entries in j, including session cookies, to w in
+// JSON format. Programs that should not keep session cookies across
+// runs can instead save a filtered result of Entries.
+func (j *Jar) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(j.Entries())
+}
+
+// Load reads entries written by Save from r and adds them to j, as
+// SetEntries does.
+func (j *Jar) Load(r io.Reader) error {
+	var entries []Entry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+	return j.SetEntries(entries)
+}
@@ -0,0 +1,157 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cookiejar
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// cookieHeader returns the Cookie header jar sends to rawURL at now.
+func cookieHeader(jar *Jar, rawURL string, now time.Time) string {
+	var b bytes.Buffer
+	for i, c := range jar.cookies(mustParseURL(rawURL), now) {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(c.Name + "=" + c.Value)
+	}
+	return b.String()
+}
+
+func TestEntriesRoundTrip(t *testing.T) {
+	jar := newTestJar()
+	jar.setCookies(mustParseURL("https://www.example.co.uk/a/b"), []*http.Cookie{
+		{Name: "session", Value: "s"},
+		{Name: "host", Value: "h", Path: "/", MaxAge: 3600, Secure: true, HttpOnly: true, SameSite: http.SameSiteLaxMode},
+		{Name: "domain", Value: "d", Domain: "example.co.uk", Path: "/", Expires: tNow.Add(24 * time.Hour)},
+		{Name: "short", Value: "x", MaxAge: 60},
+	}, tNow)
+
+	later := tNow.Add(2 * time.Minute) // "short" has expired
+	entries := jar.entriesAt(later)
+	want := []Entry{
+		{Name: "session", Value: "s", Domain: "www.example.co.uk", HostOnly: true, Path: "/a", Creation: tNow, LastAccess: tNow},
+		{Name: "host", Value: "h", Domain: "www.example.co.uk", HostOnly: true, Path: "/", SameSite: http.SameSiteLaxMode, Secure: true, HttpOnly: true, Persistent: true, Expires: tNow.Add(time.Hour), Creation: tNow, LastAccess: tNow},
+		{Name: "domain", Value: "d", Domain: "example.co.uk", Path: "/", Persistent: true, Expires: tNow.Add(24 * time.Hour), Creation: tNow, LastAccess: tNow},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("entries:\n got %+v\nwant %+v", entries, want)
+	}
+
+	restored := newTestJar()
+	if err := restored.setEntries(entries, later); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		url, want string
+	}{
+		{"https://www.example.co.uk/a/c", "session=s; host=h; domain=d"},
+		{"http://www.example.co.uk/", "domain=d"},
+		{"https://other.example.co.uk/", "domain=d"},
+		{"https://sub.www.example.co.uk/", "domain=d"},
+	} {
+		if got := cookieHeader(restored, tt.url, later); got != tt.want {
+			t.Errorf("restored jar sends %q to %s; want %q", got, tt.url, tt.want)
+		}
+	}
+	if got := cookieHeader(restored, "https://www.example.co.uk/a/c", tNow.Add(2*time.Hour)); got != "session=s; domain=d" {
+		t.Errorf("restored jar sends %q after persistent cookie expired; want %q", got, "session=s; domain=d")
+	}
+}
+
+func TestSetEntries(t *testing.T) {
+	jar := newTestJar()
+	jar.setCookies(mustParseURL("http://www.host.test/"), []*http.Cookie{
+		{Name: "a", Value: "old"},
+		{Name: "b", Value: "old"},
+	}, tNow)
+
+	err := jar.setEntries([]Entry{
+		{Name: "a", Value: "new", Domain: "www.host.test", HostOnly: true, Path: "/"},
+		{Name: "b", Domain: "www.host.test", HostOnly: true, Path: "/", Persistent: true, Expires: tNow.Add(-time.Second)},
+		{Name: "c", Value: "new", Domain: "host.test", Path: "/"},
+	}, tNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cookieHeader(jar, "http://www.host.test/", tNow), "a=new; c=new"; got != want {
+		t.Errorf("jar sends %q; want %q", got, want)
+	}
+
+	for _, bad := range []Entry{
+		{Name: "x", Domain: "", HostOnly: true, Path: "/"},
+		{Name: "x", Domain: "Host.Test", HostOnly: true, Path: "/"},
+		{Name: "x", Domain: ".host.test", Path: "/"},
+		{Name: "x", Domain: "host.test", Path: "relative"},
+		{Name: "x", Domain: "co.uk", Path: "/"},
+		{Name: "x", Domain: "127.0.0.1", Path: "/"},
+	} {
+		if err := jar.setEntries([]Entry{{Name: "ok", Domain: "host.test", Path: "/"}, bad}, tNow); err == nil {
+			t.Errorf("SetEntries(%+v) succeeded; want error", bad)
+		}
+	}
+	if got, want := cookieHeader(jar, "http://www.host.test/", tNow), "a=new; c=new"; got != want {
+		t.Errorf("after failed SetEntries, jar sends %q; want %q", got, want)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	jar := newTestJar()
+	u := mustParseURL("https://www.host.test/")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "s"},
+		{Name: "persistent", Value: "p", MaxAge: 3600},
+	})
+	var buf bytes.Buffer
+	if err := jar.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var saved []Entry
+	if err := json.Unmarshal(buf.Bytes(), &saved); err != nil {
+		t.Fatalf("Save wrote invalid JSON: %v", err)
+	}
+	if len(saved) != 2 {
+		t.Fatalf("Save wrote %d entries; want 2", len(saved))
+	}
+
+	restored := newTestJar()
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := restored.Entries(), jar.Entries(); !entriesEqual(got, want) {
+		t.Errorf("restored entries:\n got %+v\nwant %+v", got, want)
+	}
+	if got := restored.Cookies(u); len(got) != 2 {
+		t.Errorf("restored jar sends %d cookies; want 2", len(got))
+	}
+
+	if err := restored.Load(bytes.NewReader([]byte("{"))); err == nil {
+		t.Error("Load of malformed JSON succeeded; want error")
+	}
+}
+
+// entriesEqual reports whether a and b are equal, comparing times
+// with time.Time.Equal.
+func entriesEqual(a, b []Entry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		if !x.Expires.Equal(y.Expires) || !x.Creation.Equal(y.Creation) || !x.LastAccess.Equal(y.LastAccess) {
+			return false
+		}
+		x.Expires, x.Creation, x.LastAccess = y.Expires, y.Creation, y.LastAccess
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
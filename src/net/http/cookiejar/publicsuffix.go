@@ -0,0 +1,154 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cookiejar
+
+//go:generate go run gen_publicsuffix.go
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/internal/ascii"
+	"strings"
+	"sync"
+)
+
+// Kinds of public suffix rules. A name may have rules of several kinds:
+// the list has both "ck" and "*.ck", for example.
+const (
+	ruleNormal    = 1 << iota // "example"
+	ruleWildcard              // "*.example", stored as "example"
+	ruleException             // "!www.example", stored as "www.example"
+)
+
+// suffixList is a PublicSuffixList backed by the rules of a list in
+// the format maintained at https://publicsuffix.org/list/.
+type suffixList struct {
+	rules       map[string]uint8
+	description string
+}
+
+// PublicSuffix returns the public suffix of domain, which must be in
+// canonical form: lower case, with internationalized labels in
+// Punycode, and without leading or trailing dots. Domains that no rule
+// matches have their last label as the public suffix.
+func (l *suffixList) PublicSuffix(domain string) string {
+	// An exception rule takes precedence over all other rules;
+	// the public suffix is the rule without its first label.
+	for s := domain; ; {
+		i := strings.IndexByte(s, '.')
+		if i < 0 {
+			break
+		}
+		if l.rules[s]&ruleException != 0 {
+			return s[i+1:]
+		}
+		s = s[i+1:]
+	}
+	// Otherwise, the longest matching rule wins.
+	for s := domain; ; {
+		if l.rules[s]&ruleNormal != 0 {
+			return s
+		}
+		i := strings.IndexByte(s, '.')
+		if i < 0 {
+			return s
+		}
+		if l.rules[s[i+1:]]&ruleWildcard != 0 {
+			return s
+		}
+		s = s[i+1:]
+	}
+}
+
+func (l *suffixList) String() string {
+	return l.description
+}
+
+var defaultPSL struct {
+	once sync.Once
+	list *suffixList
+}
+
+// DefaultPublicSuffixList returns a PublicSuffixList built from a copy
+// of the list at https://publicsuffix.org/list/ that is embedded in
+// this package, including both its ICANN and private domains. The copy
+// is updated from time to time; programs that need more recent data
+// can use ParsePublicSuffixList instead.
+func DefaultPublicSuffixList() PublicSuffixList {
+	defaultPSL.once.Do(func() {
+		description := "publicsuffix.org's public_suffix_list.dat, " + publicSuffixVersion
+		l, err := parsePublicSuffixList(strings.NewReader(publicSuffixRules), description)
+		if err != nil {
+			panic("cookiejar: invalid built-in public suffix list: " + err.Error())
+		}
+		defaultPSL.list = l
+	})
+	return defaultPSL.list
+}
+
+// ParsePublicSuffixList reads a public suffix list from r, in the
+// format of https://publicsuffix.org/list/public_suffix_list.dat, and
+// returns a PublicSuffixList that applies its rules. Its String method
+// returns description, which should identify the source and version of
+// the list.
+func ParsePublicSuffixList(r io.Reader, description string) (PublicSuffixList, error) {
+	l, err := parsePublicSuffixList(r, description)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func parsePublicSuffixList(r io.Reader, description string) (*suffixList, error) {
+	l := &suffixList{
+		rules:       make(map[string]uint8),
+		description: description,
+	}
+	s := bufio.NewScanner(r)
+	for lineno := 1; s.Scan(); lineno++ {
+		// A rule extends up to the first whitespace.
+		line := s.Text()
+		if i := strings.IndexAny(line, " \t\r"); i >= 0 {
+			line = line[:i]
+		}
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		name, kind := line, uint8(ruleNormal)
+		if strings.HasPrefix(name, "!") {
+			name, kind = name[1:], ruleException
+		} else if strings.HasPrefix(name, "*.") {
+			name, kind = name[2:], ruleWildcard
+		}
+		name, err := canonicalRule(name)
+		if err != nil || kind == ruleException && !strings.Contains(name, ".") {
+			return nil, fmt.Errorf("cookiejar: invalid public suffix rule %q on line %d", line, lineno)
+		}
+		l.rules[name] |= kind
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(l.rules) == 0 {
+		return nil, errors.New("cookiejar: empty public suffix list")
+	}
+	return l, nil
+}
+
+// canonicalRule converts the name of a rule to lower case Punycode.
+func canonicalRule(name string) (string, error) {
+	if name == "" || name[0] == '.' || name[len(name)-1] == '.' ||
+		strings.Contains(name, "..") || strings.Contains(name, "*") || strings.Contains(name, "!") {
+		return "", errMalformedDomain
+	}
+	name, err := toASCII(name)
+	if err != nil {
+		return "", err
+	}
+	name, _ = ascii.ToLower(name)
+	return name, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cookiejar
+
+import (
+	"strings"
+	"testing"
+)
+
+var defaultPublicSuffixTests = []struct {
+	domain, want string
+}{
+	{"com", "com"},
+	{"example.com", "com"},
+	{"www.example.com", "com"},
+	{"example.co.uk", "co.uk"},
+	{"www.example.co.uk", "co.uk"},
+	{"unlisted", "unlisted"},
+	{"example.unlisted", "unlisted"},
+
+	// Wildcard and exception rules: "*.ck" and "!www.ck".
+	{"ck", "ck"},
+	{"example.ck", "example.ck"},
+	{"www.example.ck", "example.ck"},
+	{"www.ck", "ck"},
+	{"foo.www.ck", "ck"},
+	{"kawasaki.jp", "jp"},
+	{"example.kawasaki.jp", "example.kawasaki.jp"},
+	{"city.kawasaki.jp", "kawasaki.jp"},
+	{"www.city.kawasaki.jp", "kawasaki.jp"},
+
+	// Internationalized rules are matched in Punycode: "公司.cn".
+	{"xn--55qx5d.cn", "xn--55qx5d.cn"},
+	{"xn--85x722f.xn--55qx5d.cn", "xn--55qx5d.cn"},
+
+	// Private domains.
+	{"blogspot.com", "blogspot.com"},
+	{"example.blogspot.com", "blogspot.com"},
+}
+
+func TestDefaultPublicSuffixList(t *testing.T) {
+	psl := DefaultPublicSuffixList()
+	for _, tt := range defaultPublicSuffixTests {
+		if got := psl.PublicSuffix(tt.domain); got != tt.want {
+			t.Errorf("PublicSuffix(%q) = %q; want %q", tt.domain, got, tt.want)
+		}
+	}
+	if got, want := psl.String(), publicSuffixVersion; !strings.Contains(got, want) {
+		t.Errorf("String() = %q; want it to contain %q", got, want)
+	}
+}
+
+func TestParsePublicSuffixList(t *testing.T) {
+	const list = `// A comment.
+com
+co.uk   trailing text is ignored
+*.example
+!www.example
+
+// ===BEGIN PRIVATE DOMAINS===
+SITES.Example.COM
+`
+	psl, err := ParsePublicSuffixList(strings.NewReader(list), "test list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		domain, want string
+	}{
+		{"foo.com", "com"},
+		{"foo.co.uk", "co.uk"},
+		{"foo.bar.example", "bar.example"},
+		{"foo.www.example", "example"},
+		{"a.sites.example.com", "sites.example.com"},
+		{"foo.org", "org"},
+	} {
+		if got := psl.PublicSuffix(tt.domain); got != tt.want {
+			t.Errorf("PublicSuffix(%q) = %q; want %q", tt.domain, got, tt.want)
+		}
+	}
+	if got := psl.String(); got != "test list" {
+		t.Errorf("String() = %q; want %q", got, "test list")
+	}
+
+	for _, bad := range []string{
+		"",
+		"// only a comment",
+		"foo..com",
+		".com",
+		"com.",
+		"foo.*.com",
+		"!com",
+	} {
+		if _, err := ParsePublicSuffixList(strings.NewReader(bad), "bad"); err == nil {
+			t.Errorf("ParsePublicSuffixList(%q) succeeded; want error", bad)
+		}
+	}
+}
+
+func TestDefaultPublicSuffixListJar(t *testing.T) {
+	jar, err := New(&Options{PublicSuffixList: DefaultPublicSuffixList()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		host, domain string
+		ok           bool
+	}{
+		{"www.example.co.uk", "example.co.uk", true},
+		{"www.example.co.uk", "co.uk", false},
+		{"foo.blogspot.com", "blogspot.com", false},
+		{"foo.example.ck", "example.ck", false},
+	} {
+		_, _, err := jar.domainAndType(tt.host, tt.domain)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("domainAndType(%q, %q) error = %v; want ok = %v", tt.host, tt.domain, err, tt.ok)
+		}
+	}
+}
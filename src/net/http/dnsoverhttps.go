@@ -0,0 +1,61 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+)
+
+// maxDNSMessageSize is the largest DNS message that fits
+// in the two-byte length prefix used by DNS over TCP.
+const maxDNSMessageSize = 65535
+
+// DNSOverHTTPS returns a function that sends DNS queries to the
+// DNS over HTTPS (RFC 8484) server at url using rt, suitable for use
+// as the ExchangeHTTPS field of a net.Resolver. Queries are sent using
+// the POST method. If rt is nil, DefaultTransport is used.
+//
+// The host in url is resolved by rt, so a Transport used for DNS over
+// HTTPS should not itself dial using a Resolver that sends queries to
+// the same server. Typically its DialContext resolves names using the
+// system resolver, or url names the server by IP address.
+func DNSOverHTTPS(rt RoundTripper, url string) func(ctx context.Context, query []byte) ([]byte, error) {
+	if rt == nil {
+		rt = DefaultTransport
+	}
+	return func(ctx context.Context, query []byte) ([]byte, error) {
+		req, err := NewRequestWithContext(ctx, "POST", url, bytes.NewReader(query))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/dns-message")
+		req.Header.Set("Accept", "application/dns-message")
+		res, err := rt.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != StatusOK {
+			return nil, fmt.Errorf("net/http: DNS over HTTPS server returned %s", res.Status)
+		}
+		ct := res.Header.Get("Content-Type")
+		if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != "application/dns-message" {
+			return nil, fmt.Errorf("net/http: DNS over HTTPS server returned Content-Type %q", ct)
+		}
+		msg, err := io.ReadAll(io.LimitReader(res.Body, maxDNSMessageSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(msg) > maxDNSMessageSize {
+			return nil, errors.New("net/http: DNS over HTTPS response too large")
+		}
+		return msg, nil
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	. "net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDNSOverHTTPS(t *testing.T) {
+	setParallel(t)
+	defer afterTest(t)
+	query := []byte("query")
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.Method != "POST" {
+			t.Errorf("Method = %q; want POST", r.Method)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/dns-message" {
+			t.Errorf("Content-Type = %q; want application/dns-message", got)
+		}
+		if got := r.Header.Get("Accept"); got != "application/dns-message" {
+			t.Errorf("Accept = %q; want application/dns-message", got)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !bytes.Equal(body, query) {
+			t.Errorf("body = %q; want %q", body, query)
+		}
+		switch r.URL.Path {
+		case "/dns-query":
+			w.Header().Set("Content-Type", "application/dns-message")
+			w.Write([]byte("response"))
+		case "/wrong-type":
+			w.Write([]byte("response"))
+		default:
+			NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	for _, tt := range []struct {
+		path    string
+		want    string
+		wantErr string
+	}{
+		{path: "/dns-query", want: "response"},
+		{path: "/wrong-type", wantErr: "Content-Type"},
+		{path: "/missing", wantErr: "404"},
+	} {
+		exchange := DNSOverHTTPS(ts.Client().Transport, ts.URL+tt.path)
+		got, err := exchange(context.Background(), query)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err = %v; want error containing %q", tt.path, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: response = %q; want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestDNSOverHTTPSResolver(t *testing.T) {
+	switch runtime.GOOS {
+	case "js", "plan9", "windows":
+		t.Skipf("Go DNS resolver not used on %s", runtime.GOOS)
+	}
+	setParallel(t)
+	defer afterTest(t)
+	ts := httptest.NewTLSServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		body, _ := io.ReadAll(r.Body)
+		var q dnsmessage.Message
+		if err := q.Unpack(body); err != nil {
+			Error(w, err.Error(), StatusBadRequest)
+			return
+		}
+		resp := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: q.ID, Response: true},
+			Questions: q.Questions,
+		}
+		if q.Questions[0].Type == dnsmessage.TypeA {
+			resp.Answers = []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{
+					Name:  q.Questions[0].Name,
+					Type:  dnsmessage.TypeA,
+					Class: dnsmessage.ClassINET,
+				},
+				Body: &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+			}}
+		}
+		msg, err := resp.Pack()
+		if err != nil {
+			Error(w, err.Error(), StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(msg)
+	}))
+	defer ts.Close()
+
+	r := &net.Resolver{
+		ExchangeHTTPS: DNSOverHTTPS(ts.Client().Transport, ts.URL+"/dns-query"),
+	}
+	addrs, err := r.LookupHost(context.Background(), "doh.example.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"192.0.2.1"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("LookupHost = %v; want %v", addrs, want)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !nethttpomithttp2
+// +build !nethttpomithttp2
+
+package http
+
+// h2InformationalWriter adds support for informational (1xx)
+// responses, such as 103 Early Hints, to the bundled HTTP/2 server's
+// ResponseWriter. It is kept out of h2_bundle.go, which is generated.
+//
+// All other methods, including the optional interfaces implemented by
+// http2responseWriter, are promoted from the embedded writer.
+type h2InformationalWriter struct {
+	*http2responseWriter
+}
+
+// wrapH2ResponseWriter returns rw wrapped in an h2InformationalWriter
+// if it was created by the bundled HTTP/2 server.
+func wrapH2ResponseWriter(rw ResponseWriter) ResponseWriter {
+	if w, ok := rw.(*http2responseWriter); ok {
+		return h2InformationalWriter{w}
+	}
+	return rw
+}
+
+func (w h2InformationalWriter) WriteHeader(code int) {
+	// 101 Switching Protocols is a final response.
+	if code < 100 || code > 199 || code == StatusSwitchingProtocols {
+		w.http2responseWriter.WriteHeader(code)
+		return
+	}
+	rws := w.rws
+	if rws == nil {
+		panic("WriteHeader called after Handler finished")
+	}
+	if rws.wroteHeader {
+		return
+	}
+
+	// Informational headers are sent immediately and may be
+	// followed by any number of further 1xx headers and then the
+	// final response header. Per RFC 8297, the handler's header map
+	// is not cleared after sending them.
+	h := rws.handlerHeader
+	_, cl := h["Content-Length"]
+	_, te := h["Transfer-Encoding"]
+	if cl || te {
+		h = h.Clone()
+		h.Del("Content-Length")
+		h.Del("Transfer-Encoding")
+	}
+	if rws.conn.writeHeaders(rws.stream, &http2writeResHeaders{
+		streamID:    rws.stream.id,
+		httpResCode: code,
+		h:           h,
+		endStream:   false,
+	}) != nil {
+		rws.dirty = true
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !nethttpomithttp2
+// +build !nethttpomithttp2
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// This file implements the optional ResponseWriter methods used by
+// ResponseController for the bundled HTTP/2 server. It is kept out of
+// h2_bundle.go, which is generated.
+
+// FlushError is like Flush, but returns any error encountered
+// writing the buffered data.
+func (w *http2responseWriter) FlushError() error {
+	rws := w.rws
+	if rws == nil {
+		panic("Header called after Handler finished")
+	}
+	if rws.bw.Buffered() > 0 {
+		return rws.bw.Flush()
+	}
+	// The bufio.Writer won't call chunkWriter.Write
+	// (writeChunk with zero bytes), so we have to do it
+	// ourselves to force the HTTP response header and/or
+	// final DATA frame (with END_STREAM) to be sent.
+	_, err := rws.writeChunk(nil)
+	return err
+}
+
+// EnableFullDuplex is a no-op: HTTP/2 handlers may always
+// interleave reading the request body and writing the response.
+func (w *http2responseWriter) EnableFullDuplex() error {
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for writing the response.
+// When it passes, the stream is reset.
+func (w *http2responseWriter) SetWriteDeadline(deadline time.Time) error {
+	st := w.rws.stream
+	if !deadline.IsZero() && deadline.Before(time.Now()) {
+		// Reset the stream now, so that writes
+		// after SetWriteDeadline returns fail.
+		st.onWriteTimeout()
+		return nil
+	}
+	// The stream's writeDeadline timer belongs to the serve goroutine,
+	// which runs func(int) messages.
+	w.rws.conn.sendServeMsg(func(int) {
+		if st.state == http2stateClosed {
+			return
+		}
+		if st.writeDeadline != nil && !st.writeDeadline.Stop() {
+			// The deadline has already been exceeded.
+			return
+		}
+		if deadline.IsZero() {
+			st.writeDeadline = nil
+		} else if st.writeDeadline == nil {
+			st.writeDeadline = time.AfterFunc(deadline.Sub(time.Now()), st.onWriteTimeout)
+		} else {
+			st.writeDeadline.Reset(deadline.Sub(time.Now()))
+		}
+	})
+	return nil
+}
+
+// http2readDeadlines holds the read deadline timers of HTTP/2 server
+// streams. The bundled http2stream only has a writeDeadline timer, so
+// the read deadline timers are kept here, keyed by stream. A stream's
+// entry is removed when its context is canceled, which happens when the
+// stream is reset or its handler returns.
+var http2readDeadlines struct {
+	sync.Mutex
+	m map[*http2stream]*http2readDeadline
+}
+
+type http2readDeadline struct {
+	timer *time.Timer // nil if unused
+}
+
+// SetReadDeadline sets the deadline for reading the request body.
+// When it passes, reads from the body fail with an error wrapping
+// os.ErrDeadlineExceeded.
+func (w *http2responseWriter) SetReadDeadline(deadline time.Time) error {
+	st := w.rws.stream
+	if !deadline.IsZero() && deadline.Before(time.Now()) {
+		// Fail the body now, so that reads
+		// after SetReadDeadline returns fail.
+		st.onReadTimeout()
+		return nil
+	}
+	http2readDeadlines.Lock()
+	defer http2readDeadlines.Unlock()
+	rd := http2readDeadlines.m[st]
+	if rd == nil {
+		if deadline.IsZero() {
+			return nil
+		}
+		if http2readDeadlines.m == nil {
+			http2readDeadlines.m = make(map[*http2stream]*http2readDeadline)
+		}
+		rd = new(http2readDeadline)
+		http2readDeadlines.m[st] = rd
+		context.AfterFunc(st.ctx, func() {
+			http2readDeadlines.Lock()
+			defer http2readDeadlines.Unlock()
+			if rd.timer != nil {
+				rd.timer.Stop()
+			}
+			delete(http2readDeadlines.m, st)
+		})
+	}
+	if rd.timer != nil && !rd.timer.Stop() {
+		// The deadline has already been exceeded.
+		return nil
+	}
+	if deadline.IsZero() {
+		rd.timer = nil
+	} else if rd.timer == nil {
+		rd.timer = time.AfterFunc(deadline.Sub(time.Now()), st.onReadTimeout)
+	} else {
+		rd.timer.Reset(deadline.Sub(time.Now()))
+	}
+	return nil
+}
+
+// onReadTimeout is run on its own goroutine (from time.AfterFunc)
+// when the stream's read deadline has passed.
+func (st *http2stream) onReadTimeout() {
+	if st.body != nil {
+		// Wrap the ErrDeadlineExceeded to avoid callers depending on us
+		// returning the bare error.
+		st.body.CloseWithError(fmt.Errorf("%w", os.ErrDeadlineExceeded))
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !nethttpomithttp2
+// +build !nethttpomithttp2
+
+package http
+
+// This file reports connection statistics for the bundled HTTP/2
+// transport. It is kept out of h2_bundle.go, which is generated.
+
+// connStats reports the number of pooled connections and the number
+// of active streams on them. Only the bundle's own connection pools
+// are counted.
+func (t *http2Transport) connStats() (conns, streams int) {
+	var p *http2clientConnPool
+	switch cp := t.connPool().(type) {
+	case *http2clientConnPool:
+		p = cp
+	case http2noDialClientConnPool:
+		p = cp.http2clientConnPool
+	default:
+		return 0, 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// A conn is listed under each of its keys; count it once.
+	seen := make(map[*http2ClientConn]bool)
+	for _, vv := range p.conns {
+		for _, cc := range vv {
+			if seen[cc] {
+				continue
+			}
+			seen[cc] = true
+			conns++
+			cc.mu.Lock()
+			streams += len(cc.streams)
+			cc.mu.Unlock()
+		}
+	}
+	return conns, streams
+}
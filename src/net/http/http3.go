@@ -0,0 +1,545 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/3 framing, shared by the client and server.
+// See RFC 9114.
+
+package http
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"internal/quic"
+	"io"
+	"net/http/internal/ascii"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// http3NextProto is the ALPN protocol ID of HTTP/3.
+const http3NextProto = "h3"
+
+// HTTP/3 frame types. See RFC 9114, Section 7.2.
+const (
+	http3FrameData        = 0x00
+	http3FrameHeaders     = 0x01
+	http3FrameCancelPush  = 0x03
+	http3FrameSettings    = 0x04
+	http3FramePushPromise = 0x05
+	http3FrameGoaway      = 0x07
+	http3FrameMaxPushID   = 0x0d
+)
+
+// HTTP/3 unidirectional stream types. See RFC 9114, Section 6.2.
+const (
+	http3StreamControl      = 0x00
+	http3StreamPush         = 0x01
+	http3StreamQPACKEncoder = 0x02
+	http3StreamQPACKDecoder = 0x03
+)
+
+// HTTP/3 settings. See RFC 9114, Section 7.2.4.1, and RFC 9204, Section 5.
+const (
+	http3SettingQPACKMaxTableCapacity = 0x01
+	http3SettingMaxFieldSectionSize   = 0x06
+	http3SettingQPACKBlockedStreams   = 0x07
+)
+
+// An http3ErrCode is an HTTP/3 error code. See RFC 9114, Section 8.1.
+type http3ErrCode uint64
+
+const (
+	http3ErrNoError              http3ErrCode = 0x100
+	http3ErrGeneralProtocolError http3ErrCode = 0x101
+	http3ErrInternalError        http3ErrCode = 0x102
+	http3ErrStreamCreationError  http3ErrCode = 0x103
+	http3ErrClosedCriticalStream http3ErrCode = 0x104
+	http3ErrFrameUnexpected      http3ErrCode = 0x105
+	http3ErrFrameError           http3ErrCode = 0x106
+	http3ErrExcessiveLoad        http3ErrCode = 0x107
+	http3ErrIDError              http3ErrCode = 0x108
+	http3ErrSettingsError        http3ErrCode = 0x109
+	http3ErrMissingSettings      http3ErrCode = 0x10a
+	http3ErrRequestRejected      http3ErrCode = 0x10b
+	http3ErrRequestCancelled     http3ErrCode = 0x10c
+	http3ErrRequestIncomplete    http3ErrCode = 0x10d
+	http3ErrMessageError         http3ErrCode = 0x10e
+	http3ErrConnectError         http3ErrCode = 0x10f
+	http3ErrVersionFallback      http3ErrCode = 0x110
+)
+
+var http3ErrCodeName = map[http3ErrCode]string{
+	http3ErrNoError:              "H3_NO_ERROR",
+	http3ErrGeneralProtocolError: "H3_GENERAL_PROTOCOL_ERROR",
+	http3ErrInternalError:        "H3_INTERNAL_ERROR",
+	http3ErrStreamCreationError:  "H3_STREAM_CREATION_ERROR",
+	http3ErrClosedCriticalStream: "H3_CLOSED_CRITICAL_STREAM",
+	http3ErrFrameUnexpected:      "H3_FRAME_UNEXPECTED",
+	http3ErrFrameError:           "H3_FRAME_ERROR",
+	http3ErrExcessiveLoad:        "H3_EXCESSIVE_LOAD",
+	http3ErrIDError:              "H3_ID_ERROR",
+	http3ErrSettingsError:        "H3_SETTINGS_ERROR",
+	http3ErrMissingSettings:      "H3_MISSING_SETTINGS",
+	http3ErrRequestRejected:      "H3_REQUEST_REJECTED",
+	http3ErrRequestCancelled:     "H3_REQUEST_CANCELLED",
+	http3ErrRequestIncomplete:    "H3_REQUEST_INCOMPLETE",
+	http3ErrMessageError:         "H3_MESSAGE_ERROR",
+	http3ErrConnectError:         "H3_CONNECT_ERROR",
+	http3ErrVersionFallback:      "H3_VERSION_FALLBACK",
+}
+
+func (e http3ErrCode) String() string {
+	if s, ok := http3ErrCodeName[e]; ok {
+		return s
+	}
+	return fmt.Sprintf("H3_ERR_0x%x", uint64(e))
+}
+
+// An http3ConnError is a connection error, which causes the
+// connection to be closed with the given code.
+type http3ConnError struct {
+	code   http3ErrCode
+	reason string
+}
+
+func (e *http3ConnError) Error() string {
+	return fmt.Sprintf("http3: connection error: %v: %v", e.code, e.reason)
+}
+
+// An http3StreamError is a stream error, which causes the
+// stream to be reset with the given code.
+type http3StreamError struct {
+	code   http3ErrCode
+	reason string
+}
+
+func (e *http3StreamError) Error() string {
+	return fmt.Sprintf("http3: stream error: %v: %v", e.code, e.reason)
+}
+
+// errHTTP3FrameTooLarge is returned when a frame exceeds the size
+// the reader is willing to buffer.
+var errHTTP3FrameTooLarge = errors.New("http3: frame too large")
+
+// http3ReadVarint reads a QUIC variable-length integer.
+func http3ReadVarint(r io.ByteReader) (uint64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	n := 1 << (b >> 6)
+	v := uint64(b & 0x3f)
+	for i := 1; i < n; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+// http3ReadFrameHeader reads the type and length of a frame.
+// It returns io.EOF only if the stream ends before the frame begins.
+func http3ReadFrameHeader(r io.ByteReader) (typ, length uint64, err error) {
+	typ, err = http3ReadVarint(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	length, err = http3ReadVarint(r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return typ, length, err
+}
+
+// http3ReadFramePayload reads a frame payload of up to max bytes.
+func http3ReadFramePayload(r io.Reader, length uint64, max int64) ([]byte, error) {
+	if length > uint64(max) {
+		return nil, errHTTP3FrameTooLarge
+	}
+	b := make([]byte, length)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+func http3AppendFrameHeader(b []byte, typ, length uint64) []byte {
+	b = quic.AppendVarint(b, typ)
+	return quic.AppendVarint(b, length)
+}
+
+// http3AppendSettings appends a SETTINGS frame.
+func http3AppendSettings(b []byte, maxFieldSectionSize int64) []byte {
+	var p []byte
+	p = quic.AppendVarint(p, http3SettingMaxFieldSectionSize)
+	p = quic.AppendVarint(p, uint64(maxFieldSectionSize))
+	b = http3AppendFrameHeader(b, http3FrameSettings, uint64(len(p)))
+	return append(b, p...)
+}
+
+// http3IsReservedFrame reports whether a frame type is an unknown or
+// reserved type, which must be ignored. See RFC 9114, Section 9.
+func http3IsReservedFrame(typ uint64) bool {
+	switch typ {
+	case http3FrameData, http3FrameHeaders, http3FrameCancelPush, http3FrameSettings,
+		http3FramePushPromise, http3FrameGoaway, http3FrameMaxPushID,
+		0x02, 0x06, 0x08, 0x09: // HTTP/2 frame types, reserved in HTTP/3
+		return false
+	}
+	return true
+}
+
+// http3ConnCloser is the subset of a QUIC connection used
+// to report connection errors.
+type http3ConnCloser interface {
+	Abort(code uint64, reason string)
+}
+
+// http3AbortConn closes a connection after a connection error.
+func http3AbortConn(qc http3ConnCloser, err error) {
+	var ce *http3ConnError
+	if errors.As(err, &ce) {
+		qc.Abort(uint64(ce.code), ce.reason)
+		return
+	}
+	qc.Abort(uint64(http3ErrGeneralProtocolError), "")
+}
+
+// http3ControlStream reads the peer's unidirectional streams:
+// its control stream, and the QPACK streams, whose contents are
+// discarded since the dynamic table is not used.
+type http3ControlStream struct {
+	isServer bool
+
+	mu                      sync.Mutex
+	sawControl              bool
+	sawEncoder              bool
+	sawDecoder              bool
+	peerMaxFieldSectionSize int64 // 0 if unlimited
+	onGoaway                func(id uint64)
+}
+
+// handleUniStream handles a unidirectional stream opened by the peer.
+// It returns a connection error, or nil.
+func (cs *http3ControlStream) handleUniStream(st *quic.Stream) error {
+	br := bufio.NewReader(st)
+	typ, err := http3ReadVarint(br)
+	if err != nil {
+		st.CloseRead(uint64(http3ErrStreamCreationError))
+		return nil
+	}
+	cs.mu.Lock()
+	var dup bool
+	switch typ {
+	case http3StreamControl:
+		dup, cs.sawControl = cs.sawControl, true
+	case http3StreamQPACKEncoder:
+		dup, cs.sawEncoder = cs.sawEncoder, true
+	case http3StreamQPACKDecoder:
+		dup, cs.sawDecoder = cs.sawDecoder, true
+	}
+	cs.mu.Unlock()
+	if dup {
+		return &http3ConnError{http3ErrStreamCreationError, "duplicate critical stream"}
+	}
+	switch typ {
+	case http3StreamControl:
+		return cs.readControlStream(br)
+	case http3StreamPush:
+		if cs.isServer {
+			return &http3ConnError{http3ErrStreamCreationError, "client opened push stream"}
+		}
+		// Server push is never enabled, since we never send MAX_PUSH_ID.
+		return &http3ConnError{http3ErrIDError, "push stream without MAX_PUSH_ID"}
+	case http3StreamQPACKEncoder, http3StreamQPACKDecoder:
+		// With a dynamic table capacity of zero, the peer sends no
+		// instructions we need to act on. Drain the stream, which
+		// must remain open for the life of the connection.
+		if _, err := io.Copy(io.Discard, br); err == nil {
+			return &http3ConnError{http3ErrClosedCriticalStream, "QPACK stream closed"}
+		}
+		return nil
+	default:
+		st.CloseRead(uint64(http3ErrStreamCreationError))
+		return nil
+	}
+}
+
+func (cs *http3ControlStream) readControlStream(br *bufio.Reader) error {
+	first := true
+	for {
+		typ, length, err := http3ReadFrameHeader(br)
+		if err == io.EOF {
+			return &http3ConnError{http3ErrClosedCriticalStream, "control stream closed"}
+		}
+		if err != nil {
+			if _, ok := err.(quic.StreamErrorCode); ok || err == io.ErrUnexpectedEOF {
+				return &http3ConnError{http3ErrClosedCriticalStream, "control stream closed"}
+			}
+			// The connection is closed.
+			return nil
+		}
+		if first && typ != http3FrameSettings {
+			return &http3ConnError{http3ErrMissingSettings, ""}
+		}
+		switch {
+		case typ == http3FrameSettings:
+			if !first {
+				return &http3ConnError{http3ErrFrameUnexpected, "duplicate SETTINGS"}
+			}
+			first = false
+			p, err := http3ReadFramePayload(br, length, 1<<16)
+			if err != nil {
+				return &http3ConnError{http3ErrFrameError, "invalid SETTINGS"}
+			}
+			if err := cs.handleSettings(p); err != nil {
+				return err
+			}
+		case typ == http3FrameGoaway:
+			p, err := http3ReadFramePayload(br, length, 8)
+			if err != nil {
+				return &http3ConnError{http3ErrFrameError, "invalid GOAWAY"}
+			}
+			id, n := quic.ConsumeVarint(p)
+			if n != len(p) {
+				return &http3ConnError{http3ErrFrameError, "invalid GOAWAY"}
+			}
+			if !cs.isServer && id%4 != 0 {
+				return &http3ConnError{http3ErrIDError, "GOAWAY with invalid stream ID"}
+			}
+			if cs.onGoaway != nil {
+				cs.onGoaway(id)
+			}
+		case typ == http3FrameCancelPush || typ == http3FrameMaxPushID:
+			if typ == http3FrameMaxPushID && !cs.isServer {
+				return &http3ConnError{http3ErrFrameUnexpected, "MAX_PUSH_ID from server"}
+			}
+			if _, err := http3ReadFramePayload(br, length, 8); err != nil {
+				return &http3ConnError{http3ErrFrameError, ""}
+			}
+		case http3IsReservedFrame(typ):
+			if _, err := io.CopyN(io.Discard, br, int64(length)); err != nil {
+				return nil
+			}
+		default:
+			return &http3ConnError{http3ErrFrameUnexpected, fmt.Sprintf("frame type 0x%x on control stream", typ)}
+		}
+	}
+}
+
+func (cs *http3ControlStream) handleSettings(p []byte) error {
+	seen := map[uint64]bool{}
+	for len(p) > 0 {
+		id, n := quic.ConsumeVarint(p)
+		if n < 0 {
+			return &http3ConnError{http3ErrFrameError, "invalid SETTINGS"}
+		}
+		p = p[n:]
+		v, n := quic.ConsumeVarint(p)
+		if n < 0 {
+			return &http3ConnError{http3ErrFrameError, "invalid SETTINGS"}
+		}
+		p = p[n:]
+		if seen[id] {
+			return &http3ConnError{http3ErrSettingsError, "duplicate setting"}
+		}
+		seen[id] = true
+		switch id {
+		case 0x02, 0x03, 0x04, 0x05:
+			// HTTP/2 settings, which are forbidden in HTTP/3.
+			return &http3ConnError{http3ErrSettingsError, "HTTP/2 setting"}
+		case http3SettingMaxFieldSectionSize:
+			cs.mu.Lock()
+			if v > 1<<62 {
+				v = 1 << 62
+			}
+			cs.peerMaxFieldSectionSize = int64(v)
+			cs.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+// http3BodyReader reads the DATA frames of a request or response
+// stream, and the trailers which follow them.
+type http3BodyReader struct {
+	st     *quic.Stream
+	br     *bufio.Reader
+	remain int64 // bytes remaining in the current DATA frame
+
+	// maxTrailerBytes limits the size of the trailer section.
+	maxTrailerBytes int64
+	// onTrailers is called with the decoded trailers.
+	onTrailers func(Header)
+
+	mu  sync.Mutex
+	err error // sticky error
+}
+
+func (b *http3BodyReader) Read(p []byte) (n int, err error) {
+	b.mu.Lock()
+	if b.err != nil {
+		err = b.err
+		b.mu.Unlock()
+		return 0, err
+	}
+	b.mu.Unlock()
+	n, err = b.read(p)
+	if err != nil {
+		b.mu.Lock()
+		if b.err == nil {
+			b.err = err
+		}
+		b.mu.Unlock()
+	}
+	return n, err
+}
+
+// abort stops reading the body. Future reads return err,
+// and the peer is asked to stop sending with the given code.
+func (b *http3BodyReader) abort(code http3ErrCode, err error) {
+	b.mu.Lock()
+	if b.err == nil {
+		b.err = err
+	}
+	b.mu.Unlock()
+	b.st.CloseRead(uint64(code))
+}
+
+func (b *http3BodyReader) read(p []byte) (int, error) {
+	for b.remain == 0 {
+		typ, length, err := http3ReadFrameHeader(b.br)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case typ == http3FrameData:
+			b.remain = int64(length)
+		case typ == http3FrameHeaders:
+			payload, err := http3ReadFramePayload(b.br, length, b.maxTrailerBytes)
+			if err != nil {
+				return 0, &http3StreamError{http3ErrMessageError, "invalid trailers"}
+			}
+			trailer := make(Header)
+			err = qpackDecode(payload, func(name, value string) error {
+				if strings.HasPrefix(name, ":") {
+					return errors.New("pseudo-header in trailers")
+				}
+				if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+					return errors.New("invalid trailer field")
+				}
+				trailer.Add(CanonicalHeaderKey(name), value)
+				return nil
+			})
+			if err != nil {
+				return 0, &http3StreamError{http3ErrMessageError, err.Error()}
+			}
+			// No frames may follow the trailers.
+			if _, _, err := http3ReadFrameHeader(b.br); err != io.EOF {
+				return 0, &http3StreamError{http3ErrFrameUnexpected, "frame after trailers"}
+			}
+			if b.onTrailers != nil {
+				b.onTrailers(trailer)
+			}
+			return 0, io.EOF
+		case http3IsReservedFrame(typ):
+			if _, err := io.CopyN(io.Discard, b.br, int64(length)); err != nil {
+				return 0, io.ErrUnexpectedEOF
+			}
+		default:
+			return 0, &http3ConnError{http3ErrFrameUnexpected, fmt.Sprintf("frame type 0x%x on request stream", typ)}
+		}
+	}
+	if int64(len(p)) > b.remain {
+		p = p[:b.remain]
+	}
+	n, err := b.br.Read(p)
+	b.remain -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// http3DataWriter writes DATA frames to a stream.
+type http3DataWriter struct {
+	st  *quic.Stream
+	buf []byte
+}
+
+func (w *http3DataWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	w.buf = http3AppendFrameHeader(w.buf[:0], http3FrameData, uint64(len(p)))
+	w.buf = append(w.buf, p...)
+	if _, err := w.st.Write(w.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// http3WriteHeaders writes a HEADERS frame containing an encoded field section.
+func http3WriteHeaders(st *quic.Stream, fields []byte) error {
+	b := http3AppendFrameHeader(nil, http3FrameHeaders, uint64(len(fields)))
+	b = append(b, fields...)
+	_, err := st.Write(b)
+	return err
+}
+
+// http3ConnectionSpecificHeaders are the header fields which may not
+// appear in HTTP/3 messages. See RFC 9114, Section 4.2.
+var http3ConnectionSpecificHeaders = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// http3IsLowerFieldName reports whether name contains no
+// uppercase characters, as HTTP/3 requires of field names.
+func http3IsLowerFieldName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if 'A' <= name[i] && name[i] <= 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// http3AppendHeaderFields appends the QPACK encoding of the header
+// fields in h, omitting fields that are not valid in HTTP/3.
+func http3AppendHeaderFields(b []byte, h Header, skip func(name string) bool) []byte {
+	for k, vv := range h {
+		if !httpguts.ValidHeaderFieldName(k) {
+			continue
+		}
+		name, _ := ascii.ToLower(k)
+		if http3ConnectionSpecificHeaders[name] || skip != nil && skip(name) {
+			continue
+		}
+		for _, v := range vv {
+			if !httpguts.ValidHeaderFieldValue(v) {
+				continue
+			}
+			if name == "te" && v != "trailers" {
+				continue
+			}
+			b = qpackAppendField(b, name, v)
+		}
+	}
+	return b
+}
@@ -0,0 +1,795 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/3 client.
+
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"internal/quic"
+	"io"
+	"net"
+	"net/http/httptrace"
+	"net/http/internal/ascii"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// errHTTP3Unavailable is returned by roundTripHTTP3 when a request
+// cannot be sent over HTTP/3 and should use HTTP/1 or HTTP/2 instead.
+var errHTTP3Unavailable = errors.New("http3: no HTTP/3 alternative available")
+
+// http3BrokenDuration is how long an HTTP/3 alternative is avoided
+// after failing to connect to it.
+const http3BrokenDuration = 5 * time.Minute
+
+// http3DefaultUserAgent is the User-Agent sent over HTTP/3
+// when the request does not set one.
+const http3DefaultUserAgent = "Go-http-client/3"
+
+// http3Transport is the HTTP/3 state of a Transport.
+type http3Transport struct {
+	t *Transport
+
+	mu       sync.Mutex
+	endpoint *quic.Endpoint
+	alts     map[string]*http3Alt // keyed by origin host:port
+	conns    map[string]*http3ClientConn
+	dials    map[string]*http3DialCall
+}
+
+// An http3Alt is an HTTP/3 alternative service for an origin,
+// learned from an Alt-Svc header.
+type http3Alt struct {
+	addr        string // host:port to connect to
+	expires     time.Time
+	brokenUntil time.Time
+}
+
+// An http3DialCall is an in-flight connection attempt.
+type http3DialCall struct {
+	done chan struct{}
+	cc   *http3ClientConn
+	err  error
+}
+
+func (t *Transport) maxHeaderResponseSize() int64 {
+	if v := t.MaxResponseHeaderBytes; v != 0 {
+		return v
+	}
+	return 10 << 20 // conservative default; same as HTTP/1 and HTTP/2
+}
+
+func (t *Transport) http3() *http3Transport {
+	t.h3mu.Lock()
+	defer t.h3mu.Unlock()
+	if t.h3transport == nil {
+		t.h3transport = &http3Transport{t: t}
+	}
+	return t.h3transport
+}
+
+// recordAltSvc records the HTTP/3 alternative advertised
+// by a response's Alt-Svc header.
+func (t *Transport) recordAltSvc(cm connectMethod, resp *Response) {
+	if cm.proxyURL != nil || cm.targetScheme != "https" || resp.ProtoMajor >= 3 {
+		return
+	}
+	v := resp.Header.Get("Alt-Svc")
+	if v == "" {
+		return
+	}
+	h := t.http3()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if textproto.TrimString(v) == "clear" {
+		delete(h.alts, cm.targetAddr)
+		return
+	}
+	addr, maxAge, ok := parseHTTP3AltSvc(v, cm.tlsHost())
+	if !ok {
+		return
+	}
+	if h.alts == nil {
+		h.alts = make(map[string]*http3Alt)
+	}
+	alt := h.alts[cm.targetAddr]
+	if alt == nil || alt.addr != addr {
+		alt = &http3Alt{addr: addr}
+		h.alts[cm.targetAddr] = alt
+	}
+	alt.expires = time.Now().Add(maxAge)
+}
+
+// parseHTTP3AltSvc returns the address and lifetime of the first
+// HTTP/3 alternative in an Alt-Svc header value (RFC 7838).
+// An alternative with an empty host refers to originHost.
+func parseHTTP3AltSvc(v, originHost string) (addr string, maxAge time.Duration, ok bool) {
+	for _, alt := range strings.Split(v, ",") {
+		params := strings.Split(alt, ";")
+		proto := textproto.TrimString(params[0])
+		i := strings.IndexByte(proto, '=')
+		if i < 0 || proto[:i] != http3NextProto {
+			continue
+		}
+		authority, err := strconv.Unquote(proto[i+1:])
+		if err != nil {
+			continue
+		}
+		host, port, err := net.SplitHostPort(authority)
+		if err != nil || port == "" {
+			continue
+		}
+		if host == "" {
+			host = originHost
+		}
+		maxAge = 24 * time.Hour
+		for _, p := range params[1:] {
+			p = textproto.TrimString(p)
+			if !strings.HasPrefix(p, "ma=") {
+				continue
+			}
+			if secs, err := strconv.ParseUint(strings.Trim(p[len("ma="):], `"`), 10, 32); err == nil {
+				maxAge = time.Duration(secs) * time.Second
+			}
+		}
+		return net.JoinHostPort(host, port), maxAge, true
+	}
+	return "", 0, false
+}
+
+// roundTripHTTP3 sends a request over HTTP/3 if the origin
+// has advertised an HTTP/3 alternative. It returns errHTTP3Unavailable
+// if the request should be sent using HTTP/1 or HTTP/2.
+func (t *Transport) roundTripHTTP3(req *Request, cm connectMethod) (*Response, error) {
+	if cm.proxyURL != nil || cm.targetScheme != "https" {
+		return nil, errHTTP3Unavailable
+	}
+	h := t.http3()
+	h.mu.Lock()
+	alt := h.alts[cm.targetAddr]
+	var addr string
+	if alt != nil {
+		now := time.Now()
+		if now.After(alt.expires) {
+			delete(h.alts, cm.targetAddr)
+		} else if now.After(alt.brokenUntil) {
+			addr = alt.addr
+		}
+	}
+	h.mu.Unlock()
+	if addr == "" {
+		return nil, errHTTP3Unavailable
+	}
+
+	cc, err := h.getConn(req.Context(), cm, addr)
+	if err != nil {
+		if ctxErr := req.Context().Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		h.markBroken(cm.targetAddr, addr)
+		return nil, errHTTP3Unavailable
+	}
+	resp, err := cc.roundTrip(req)
+	if err == errHTTP3Unavailable {
+		h.removeConn(cc)
+	}
+	return resp, err
+}
+
+func (h *http3Transport) markBroken(origin, addr string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if alt := h.alts[origin]; alt != nil && alt.addr == addr {
+		alt.brokenUntil = time.Now().Add(http3BrokenDuration)
+	}
+}
+
+// getConn returns a connection to an origin's HTTP/3 alternative,
+// dialing a new one if no existing connection can be used.
+func (h *http3Transport) getConn(ctx context.Context, cm connectMethod, addr string) (*http3ClientConn, error) {
+	key := cm.targetAddr + "|" + addr
+	h.mu.Lock()
+	if cc := h.conns[key]; cc != nil {
+		if cc.canTakeNewRequest() {
+			h.mu.Unlock()
+			return cc, nil
+		}
+		delete(h.conns, key)
+	}
+	call := h.dials[key]
+	if call == nil {
+		call = &http3DialCall{done: make(chan struct{})}
+		if h.dials == nil {
+			h.dials = make(map[string]*http3DialCall)
+		}
+		h.dials[key] = call
+		go h.dial(key, cm, addr, call)
+	}
+	h.mu.Unlock()
+	select {
+	case <-call.done:
+		return call.cc, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// dial connects to an HTTP/3 alternative.
+// It runs independently of the request which started it,
+// so other requests may use the connection.
+func (h *http3Transport) dial(key string, cm connectMethod, addr string, call *http3DialCall) {
+	call.cc, call.err = h.dialConn(cm, addr)
+	h.mu.Lock()
+	delete(h.dials, key)
+	if call.err == nil {
+		if h.conns == nil {
+			h.conns = make(map[string]*http3ClientConn)
+		}
+		call.cc.key = key
+		h.conns[key] = call.cc
+	}
+	h.mu.Unlock()
+	close(call.done)
+}
+
+func (h *http3Transport) dialConn(cm connectMethod, addr string) (*http3ClientConn, error) {
+	h.mu.Lock()
+	e := h.endpoint
+	h.mu.Unlock()
+	if e == nil {
+		var err error
+		e, err = quic.Listen("udp", ":0", nil)
+		if err != nil {
+			return nil, err
+		}
+		h.mu.Lock()
+		if h.endpoint == nil {
+			h.endpoint = e
+		} else {
+			go e.Close(context.Background())
+			e = h.endpoint
+		}
+		h.mu.Unlock()
+	}
+
+	cfg := cloneTLSConfig(h.t.TLSClientConfig)
+	if cfg.ServerName == "" {
+		cfg.ServerName = cm.tlsHost()
+	}
+	cfg.NextProtos = []string{http3NextProto}
+	cfg.MinVersion = tls.VersionTLS13
+	qconfig := &quic.Config{
+		TLSConfig:        cfg,
+		HandshakeTimeout: h.t.TLSHandshakeTimeout,
+		MaxIdleTimeout:   h.t.IdleConnTimeout,
+	}
+	// The handshake is bounded by qconfig.HandshakeTimeout.
+	qc, err := e.Dial(context.Background(), "udp", addr, qconfig)
+	if err != nil {
+		return nil, err
+	}
+	if p := qc.ConnectionState().NegotiatedProtocol; p != http3NextProto {
+		qc.Abort(uint64(http3ErrVersionFallback), "")
+		return nil, fmt.Errorf("http3: server negotiated protocol %q", p)
+	}
+	cc := &http3ClientConn{
+		h:  h,
+		t:  h.t,
+		qc: qc,
+	}
+	cc.cs.onGoaway = cc.goaway
+	if err := cc.start(); err != nil {
+		qc.Abort(uint64(http3ErrInternalError), "")
+		return nil, err
+	}
+	return cc, nil
+}
+
+func (h *http3Transport) removeConn(cc *http3ClientConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conns[cc.key] == cc {
+		delete(h.conns, cc.key)
+	}
+}
+
+// closeIdleConnections closes connections with no requests in flight.
+// Once no connections remain, the endpoint is closed.
+func (h *http3Transport) closeIdleConnections() {
+	h.mu.Lock()
+	for key, cc := range h.conns {
+		if cc.closeIfIdle() {
+			delete(h.conns, key)
+		}
+	}
+	var e *quic.Endpoint
+	if len(h.conns) == 0 && len(h.dials) == 0 {
+		e = h.endpoint
+		h.endpoint = nil
+	}
+	h.mu.Unlock()
+	if e != nil {
+		e.Close(context.Background())
+	}
+}
+
+// http3ClientConn is a client's HTTP/3 connection.
+type http3ClientConn struct {
+	h   *http3Transport
+	t   *Transport
+	qc  *quic.Conn
+	cs  http3ControlStream
+	key string // key in http3Transport.conns
+
+	mu        sync.Mutex
+	active    int // requests in flight
+	goingAway bool
+	closed    bool
+}
+
+// start opens the control stream and begins handling
+// the server's unidirectional streams.
+func (cc *http3ClientConn) start() error {
+	control, err := cc.qc.NewSendOnlyStream(context.Background())
+	if err != nil {
+		return err
+	}
+	b := quic.AppendVarint(nil, http3StreamControl)
+	b = http3AppendSettings(b, cc.t.maxHeaderResponseSize())
+	if _, err := control.Write(b); err != nil {
+		return err
+	}
+	go func() {
+		defer cc.h.removeConn(cc)
+		for {
+			st, err := cc.qc.AcceptStream(context.Background())
+			if err != nil {
+				return
+			}
+			if !st.IsReadOnly() {
+				cc.qc.Abort(uint64(http3ErrStreamCreationError), "server-initiated bidirectional stream")
+				return
+			}
+			go func() {
+				if err := cc.cs.handleUniStream(st); err != nil {
+					http3AbortConn(cc.qc, err)
+				}
+			}()
+		}
+	}()
+	return nil
+}
+
+func (cc *http3ClientConn) goaway(id uint64) {
+	cc.mu.Lock()
+	cc.goingAway = true
+	cc.mu.Unlock()
+	cc.h.removeConn(cc)
+}
+
+func (cc *http3ClientConn) canTakeNewRequest() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return !cc.goingAway && !cc.closed && cc.qc.Err() == nil
+}
+
+func (cc *http3ClientConn) closeIfIdle() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.active > 0 {
+		return false
+	}
+	cc.closed = true
+	cc.qc.Abort(uint64(http3ErrNoError), "")
+	return true
+}
+
+func (cc *http3ClientConn) requestDone() {
+	cc.mu.Lock()
+	cc.active--
+	cc.mu.Unlock()
+}
+
+// roundTrip sends a request on a new stream and reads its response.
+func (cc *http3ClientConn) roundTrip(req *Request) (*Response, error) {
+	ctx := req.Context()
+	cc.mu.Lock()
+	if cc.closed || cc.goingAway {
+		cc.mu.Unlock()
+		return nil, errHTTP3Unavailable
+	}
+	cc.active++
+	cc.mu.Unlock()
+	done := false
+	defer func() {
+		if !done {
+			cc.requestDone()
+		}
+	}()
+
+	st, err := cc.qc.NewStream(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errHTTP3Unavailable
+	}
+	st.SetReadContext(ctx)
+	st.SetWriteContext(ctx)
+	cancelStream := func() {
+		st.Reset(uint64(http3ErrRequestCancelled))
+		st.CloseRead(uint64(http3ErrRequestCancelled))
+	}
+
+	contentLength := req.outgoingLength()
+	hasBody := contentLength != 0
+	var trailers []string
+	for k := range req.Trailer {
+		trailers = append(trailers, CanonicalHeaderKey(k))
+	}
+	requestedGzip := false
+	if !cc.t.DisableCompression &&
+		req.Header.Get("Accept-Encoding") == "" &&
+		req.Header.Get("Range") == "" &&
+		req.Method != "HEAD" {
+		// Request gzip only, not deflate. See the HTTP/1
+		// and HTTP/2 transports for why.
+		requestedGzip = true
+	}
+	fields, err := cc.encodeHeaders(req, requestedGzip, strings.Join(trailers, ","), contentLength)
+	if err != nil {
+		cancelStream()
+		return nil, err
+	}
+	if err := http3WriteHeaders(st, fields); err != nil {
+		cancelStream()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if hasBody {
+		go cc.writeBody(st, req)
+	} else {
+		st.CloseWrite()
+	}
+
+	resp, err := cc.readResponse(st, req, requestedGzip)
+	if err != nil {
+		cancelStream()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var se *http3StreamError
+		var ce *http3ConnError
+		switch {
+		case errors.As(err, &ce):
+			http3AbortConn(cc.qc, err)
+		case errors.As(err, &se):
+		default:
+			var code quic.StreamErrorCode
+			if errors.As(err, &code) && http3ErrCode(code) == http3ErrRequestRejected {
+				// The server did not process the request,
+				// so it may be retried on another connection.
+				return nil, errHTTP3Unavailable
+			}
+		}
+		return nil, err
+	}
+	done = true
+	return resp, nil
+}
+
+// writeBody writes a request body and trailers to a stream.
+func (cc *http3ClientConn) writeBody(st *quic.Stream, req *Request) {
+	dw := &http3DataWriter{st: st}
+	_, err := io.Copy(dw, req.Body)
+	req.closeBody()
+	if err != nil {
+		st.Reset(uint64(http3ErrRequestCancelled))
+		return
+	}
+	if len(req.Trailer) > 0 {
+		b := http3AppendHeaderFields(qpackAppendPrefix(nil), req.Trailer, nil)
+		if err := http3WriteHeaders(st, b); err != nil {
+			return
+		}
+	}
+	st.CloseWrite()
+}
+
+// encodeHeaders encodes the request header field section.
+func (cc *http3ClientConn) encodeHeaders(req *Request, addGzipHeader bool, trailers string, contentLength int64) ([]byte, error) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	host, err := httpguts.PunycodeHostPort(host)
+	if err != nil {
+		return nil, err
+	}
+	var path string
+	if req.Method != "CONNECT" {
+		path = req.URL.RequestURI()
+		if !strings.HasPrefix(path, "/") && path != "*" {
+			orig := path
+			path = strings.TrimPrefix(path, req.URL.Scheme+"://"+host)
+			if !strings.HasPrefix(path, "/") {
+				return nil, fmt.Errorf("invalid request :path %q", orig)
+			}
+		}
+	}
+
+	b := qpackAppendPrefix(nil)
+	b = qpackAppendField(b, ":authority", host)
+	m := req.Method
+	if m == "" {
+		m = MethodGet
+	}
+	b = qpackAppendField(b, ":method", m)
+	if req.Method != "CONNECT" {
+		b = qpackAppendField(b, ":path", path)
+		b = qpackAppendField(b, ":scheme", req.URL.Scheme)
+	}
+	if trailers != "" {
+		b = qpackAppendField(b, "trailer", trailers)
+	}
+	_, didUA := req.Header["User-Agent"]
+	b = http3AppendHeaderFields(b, req.Header, func(name string) bool {
+		return name == "host" || name == "content-length" || name == "user-agent"
+	})
+	if ua := req.Header.Get("User-Agent"); ua != "" && httpguts.ValidHeaderFieldValue(ua) {
+		// At most one User-Agent, as in HTTP/1.
+		b = qpackAppendField(b, "user-agent", ua)
+	}
+	if contentLength > 0 || contentLength == 0 && (m == "POST" || m == "PUT" || m == "PATCH") {
+		b = qpackAppendField(b, "content-length", strconv.FormatInt(contentLength, 10))
+	}
+	if addGzipHeader {
+		b = qpackAppendField(b, "accept-encoding", "gzip")
+	}
+	if !didUA {
+		b = qpackAppendField(b, "user-agent", http3DefaultUserAgent)
+	}
+
+	cc.cs.mu.Lock()
+	max := cc.cs.peerMaxFieldSectionSize
+	cc.cs.mu.Unlock()
+	if max > 0 && int64(len(b)) > max {
+		return nil, errors.New("http3: request header list larger than peer's advertised limit")
+	}
+	return b, nil
+}
+
+// readResponse reads the response header from a request stream.
+func (cc *http3ClientConn) readResponse(st *quic.Stream, req *Request, requestedGzip bool) (*Response, error) {
+	br := bufio.NewReader(st)
+	maxHeaderBytes := cc.t.maxHeaderResponseSize()
+	trace := httptrace.ContextClientTrace(req.Context())
+	num1xx := 0               // number of informational 1xx headers received
+	const max1xxResponses = 5 // arbitrary bound on number of informational responses, same as HTTP/1 and HTTP/2
+	var status string
+	var header Header
+	for header == nil {
+		typ, length, err := http3ReadFrameHeader(br)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case typ == http3FrameHeaders:
+		case http3IsReservedFrame(typ):
+			if _, err := io.CopyN(io.Discard, br, int64(length)); err != nil {
+				return nil, err
+			}
+			continue
+		default:
+			return nil, &http3ConnError{http3ErrFrameUnexpected, "response did not begin with HEADERS"}
+		}
+		payload, err := http3ReadFramePayload(br, length, maxHeaderBytes)
+		if err == errHTTP3FrameTooLarge {
+			return nil, fmt.Errorf("http3: response header exceeded %d bytes", maxHeaderBytes)
+		}
+		if err != nil {
+			return nil, err
+		}
+		status = ""
+		h := make(Header)
+		sawRegular := false
+		err = qpackDecode(payload, func(name, value string) error {
+			if !strings.HasPrefix(name, ":") {
+				if !httpguts.ValidHeaderFieldName(name) || !http3IsLowerFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+					return fmt.Errorf("invalid header field %q", name)
+				}
+				sawRegular = true
+				h.Add(CanonicalHeaderKey(name), value)
+				return nil
+			}
+			if name != ":status" || status != "" || sawRegular {
+				return fmt.Errorf("invalid pseudo-header %q", name)
+			}
+			status = value
+			return nil
+		})
+		if err != nil {
+			return nil, &http3StreamError{http3ErrMessageError, err.Error()}
+		}
+		if len(status) != 3 {
+			return nil, &http3StreamError{http3ErrMessageError, "invalid :status"}
+		}
+		code, err := strconv.Atoi(status)
+		if err != nil || code < 100 {
+			return nil, &http3StreamError{http3ErrMessageError, "invalid :status"}
+		}
+		if code >= 200 {
+			header = h
+			break
+		}
+		num1xx++
+		if num1xx > max1xxResponses {
+			return nil, errors.New("http3: too many 1xx informational responses")
+		}
+		if trace != nil && trace.Got1xxResponse != nil {
+			if err := trace.Got1xxResponse(code, textproto.MIMEHeader(h)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	code, _ := strconv.Atoi(status)
+	resp := &Response{
+		Status:     status + " " + StatusText(code),
+		StatusCode: code,
+		Proto:      "HTTP/3.0",
+		ProtoMajor: 3,
+		Header:     header,
+		Request:    req,
+	}
+	cs := cc.qc.ConnectionState()
+	resp.TLS = &cs
+
+	resp.ContentLength = -1
+	if clens := header["Content-Length"]; len(clens) == 1 {
+		if cl, err := strconv.ParseUint(clens[0], 10, 63); err == nil {
+			resp.ContentLength = int64(cl)
+		}
+	}
+	if !bodyAllowedForStatus(code) {
+		resp.ContentLength = 0
+	}
+
+	for _, v := range header["Trailer"] {
+		for _, key := range strings.Split(v, ",") {
+			key = CanonicalHeaderKey(textproto.TrimString(key))
+			if key == "" {
+				continue
+			}
+			if resp.Trailer == nil {
+				resp.Trailer = make(Header)
+			}
+			resp.Trailer[key] = nil
+		}
+	}
+
+	if req.Method == "HEAD" || code == StatusNoContent || code == StatusNotModified {
+		st.CloseRead(uint64(http3ErrNoError))
+		resp.Body = NoBody
+		cc.requestDone()
+		return resp, nil
+	}
+
+	body := &http3ResponseBody{
+		cc: cc,
+		st: st,
+		r: &http3BodyReader{
+			st:              st,
+			br:              br,
+			maxTrailerBytes: maxHeaderBytes,
+			onTrailers: func(t Header) {
+				if resp.Trailer == nil {
+					resp.Trailer = make(Header)
+				}
+				for k, vv := range t {
+					resp.Trailer[k] = vv
+				}
+			},
+		},
+		contentLength: resp.ContentLength,
+	}
+	resp.Body = body
+	if requestedGzip && ascii.EqualFold(header.Get("Content-Encoding"), "gzip") {
+		header.Del("Content-Encoding")
+		header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Body = &http3GzipReader{body: body}
+		resp.Uncompressed = true
+	}
+	return resp, nil
+}
+
+// http3ResponseBody is the Body of a response received over HTTP/3.
+type http3ResponseBody struct {
+	cc            *http3ClientConn
+	st            *quic.Stream
+	r             *http3BodyReader
+	contentLength int64 // or -1 if unknown
+
+	mu   sync.Mutex
+	n    int64
+	done bool // request is no longer in flight
+}
+
+func (b *http3ResponseBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.n += int64(n)
+	if b.contentLength >= 0 && (b.n > b.contentLength || err == io.EOF && b.n != b.contentLength) {
+		b.r.abort(http3ErrMessageError, errors.New("http3: response body does not match Content-Length"))
+		b.st.Reset(uint64(http3ErrMessageError))
+		err = b.r.err
+	}
+	if err != nil {
+		b.finishLocked()
+	}
+	return n, err
+}
+
+func (b *http3ResponseBody) Close() error {
+	b.r.abort(http3ErrRequestCancelled, errReadOnClosedResBody)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.finishLocked()
+	return nil
+}
+
+func (b *http3ResponseBody) finishLocked() {
+	if !b.done {
+		b.done = true
+		b.cc.requestDone()
+	}
+}
+
+// http3GzipReader wraps a response body, decompressing it
+// on the first call to Read.
+type http3GzipReader struct {
+	body *http3ResponseBody
+	zr   *gzip.Reader
+	zerr error
+}
+
+func (gz *http3GzipReader) Read(p []byte) (int, error) {
+	if gz.zerr != nil {
+		return 0, gz.zerr
+	}
+	if gz.zr == nil {
+		gz.zr, gz.zerr = gzip.NewReader(gz.body)
+		if gz.zerr != nil {
+			return 0, gz.zerr
+		}
+	}
+	return gz.zr.Read(p)
+}
+
+func (gz *http3GzipReader) Close() error {
+	if err := gz.body.Close(); err != nil {
+		return err
+	}
+	gz.zerr = errReadOnClosedResBody
+	return nil
+}
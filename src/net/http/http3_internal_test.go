@@ -0,0 +1,112 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// White-box tests for HTTP/3 framing and QPACK.
+
+package http
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQPACKRoundTrip(t *testing.T) {
+	fields := []qpackField{
+		{":method", "GET"},                        // static table entry
+		{":path", "/index.html"},                  // static name reference
+		{"content-type", "text/plain"},            // static table entry
+		{"x-custom", "some value"},                // literal name
+		{"x-empty", ""},                           // empty value
+		{"user-agent", string(make([]byte, 300))}, // multi-byte length
+	}
+	b := qpackAppendPrefix(nil)
+	for _, f := range fields {
+		b = qpackAppendField(b, f.name, f.value)
+	}
+	var got []qpackField
+	err := qpackDecode(b, func(name, value string) error {
+		got = append(got, qpackField{name, value})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, fields) {
+		t.Errorf("decoded %q, want %q", got, fields)
+	}
+}
+
+func TestQPACKDecodeRFCExample(t *testing.T) {
+	// RFC 9204, Appendix B.1: a literal field line with a
+	// static name reference to :path.
+	b, _ := hex.DecodeString("0000510b2f696e6465782e68746d6c")
+	var got []qpackField
+	err := qpackDecode(b, func(name, value string) error {
+		got = append(got, qpackField{name, value})
+		return nil
+	})
+	if want := []qpackField{{":path", "/index.html"}}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("qpackDecode = %q, %v; want %q", got, err, want)
+	}
+	if enc := qpackAppendField(qpackAppendPrefix(nil), ":path", "/index.html"); len(enc) > len(b) {
+		t.Errorf("encoding of %x is %v bytes, want at most %v", b, len(enc), len(b))
+	}
+}
+
+func TestQPACKDecodeInvalid(t *testing.T) {
+	for _, s := range []string{
+		"0100c0",   // nonzero Required Insert Count
+		"000080",   // indexed field line, dynamic table
+		"000010",   // indexed field line, post-base
+		"0000ff",   // truncated index
+		"0000ff7f", // static index out of range
+		"000051",   // missing value
+	} {
+		b, _ := hex.DecodeString(s)
+		if err := qpackDecode(b, func(name, value string) error { return nil }); err == nil {
+			t.Errorf("qpackDecode(%v) succeeded, want error", s)
+		}
+	}
+}
+
+func TestHTTP3ReadFrame(t *testing.T) {
+	b := http3AppendFrameHeader(nil, http3FrameHeaders, 3)
+	b = append(b, "abc"...)
+	r := bytes.NewReader(b)
+	typ, length, err := http3ReadFrameHeader(r)
+	if err != nil || typ != http3FrameHeaders || length != 3 {
+		t.Fatalf("http3ReadFrameHeader = %v, %v, %v; want 1, 3, nil", typ, length, err)
+	}
+	if _, err := http3ReadFramePayload(r, length, 2); err != errHTTP3FrameTooLarge {
+		t.Errorf("payload exceeding limit: %v, want errHTTP3FrameTooLarge", err)
+	}
+	if p, err := http3ReadFramePayload(r, length, 3); err != nil || string(p) != "abc" {
+		t.Errorf("http3ReadFramePayload = %q, %v; want %q, nil", p, err, "abc")
+	}
+}
+
+func TestParseHTTP3AltSvc(t *testing.T) {
+	for _, test := range []struct {
+		v      string
+		addr   string
+		maxAge time.Duration
+		ok     bool
+	}{
+		{`h3=":443"`, "example.com:443", 24 * time.Hour, true},
+		{`h3=":8443"; ma=60`, "example.com:8443", 60 * time.Second, true},
+		{`h2=":443", h3="alt.example.com:443"; ma=3600; persist=1`, "alt.example.com:443", time.Hour, true},
+		{`h3-29=":443"`, "", 0, false},
+		{`h3=443`, "", 0, false},
+		{`clear`, "", 0, false},
+	} {
+		addr, maxAge, ok := parseHTTP3AltSvc(test.v, "example.com")
+		if addr != test.addr || maxAge != test.maxAge || ok != test.ok {
+			t.Errorf("parseHTTP3AltSvc(%q) = %q, %v, %v; want %q, %v, %v",
+				test.v, addr, maxAge, ok, test.addr, test.maxAge, test.ok)
+		}
+	}
+}
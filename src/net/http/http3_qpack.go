@@ -0,0 +1,313 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// QPACK field compression for HTTP/3. See RFC 9204.
+//
+// Only the static table is used: the encoder never inserts entries
+// into the dynamic table, and the decoder advertises a dynamic table
+// capacity of zero, so peers cannot use it either.
+
+package http
+
+import (
+	"errors"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+var errQPACKInvalid = errors.New("http3: invalid QPACK field section")
+
+type qpackField struct {
+	name, value string
+}
+
+// qpackStaticTable is the QPACK static table from RFC 9204, Appendix A.
+var qpackStaticTable = [...]qpackField{
+	{":authority", ""},
+	{":path", "/"},
+	{"age", "0"},
+	{"content-disposition", ""},
+	{"content-length", "0"},
+	{"cookie", ""},
+	{"date", ""},
+	{"etag", ""},
+	{"if-modified-since", ""},
+	{"if-none-match", ""},
+	{"last-modified", ""},
+	{"link", ""},
+	{"location", ""},
+	{"referer", ""},
+	{"set-cookie", ""},
+	{":method", "CONNECT"},
+	{":method", "DELETE"},
+	{":method", "GET"},
+	{":method", "HEAD"},
+	{":method", "OPTIONS"},
+	{":method", "POST"},
+	{":method", "PUT"},
+	{":scheme", "http"},
+	{":scheme", "https"},
+	{":status", "103"},
+	{":status", "200"},
+	{":status", "304"},
+	{":status", "404"},
+	{":status", "503"},
+	{"accept", "*/*"},
+	{"accept", "application/dns-message"},
+	{"accept-encoding", "gzip, deflate, br"},
+	{"accept-ranges", "bytes"},
+	{"access-control-allow-headers", "cache-control"},
+	{"access-control-allow-headers", "content-type"},
+	{"access-control-allow-origin", "*"},
+	{"cache-control", "max-age=0"},
+	{"cache-control", "max-age=2592000"},
+	{"cache-control", "max-age=604800"},
+	{"cache-control", "no-cache"},
+	{"cache-control", "no-store"},
+	{"cache-control", "public, max-age=31536000"},
+	{"content-encoding", "br"},
+	{"content-encoding", "gzip"},
+	{"content-type", "application/dns-message"},
+	{"content-type", "application/javascript"},
+	{"content-type", "application/json"},
+	{"content-type", "application/x-www-form-urlencoded"},
+	{"content-type", "image/gif"},
+	{"content-type", "image/jpeg"},
+	{"content-type", "image/png"},
+	{"content-type", "text/css"},
+	{"content-type", "text/html; charset=utf-8"},
+	{"content-type", "text/plain"},
+	{"content-type", "text/plain;charset=utf-8"},
+	{"range", "bytes=0-"},
+	{"strict-transport-security", "max-age=31536000"},
+	{"strict-transport-security", "max-age=31536000; includesubdomains"},
+	{"strict-transport-security", "max-age=31536000; includesubdomains; preload"},
+	{"vary", "accept-encoding"},
+	{"vary", "origin"},
+	{"x-content-type-options", "nosniff"},
+	{"x-xss-protection", "1; mode=block"},
+	{":status", "100"},
+	{":status", "204"},
+	{":status", "206"},
+	{":status", "302"},
+	{":status", "400"},
+	{":status", "403"},
+	{":status", "421"},
+	{":status", "425"},
+	{":status", "500"},
+	{"accept-language", ""},
+	{"access-control-allow-credentials", "FALSE"},
+	{"access-control-allow-credentials", "TRUE"},
+	{"access-control-allow-headers", "*"},
+	{"access-control-allow-methods", "get"},
+	{"access-control-allow-methods", "get, post, options"},
+	{"access-control-allow-methods", "options"},
+	{"access-control-expose-headers", "content-length"},
+	{"access-control-request-headers", "content-type"},
+	{"access-control-request-method", "get"},
+	{"access-control-request-method", "post"},
+	{"alt-svc", "clear"},
+	{"authorization", ""},
+	{"content-security-policy", "script-src 'none'; object-src 'none'; base-uri 'none'"},
+	{"early-data", "1"},
+	{"expect-ct", ""},
+	{"forwarded", ""},
+	{"if-range", ""},
+	{"origin", ""},
+	{"purpose", "prefetch"},
+	{"server", ""},
+	{"timing-allow-origin", "*"},
+	{"upgrade-insecure-requests", "1"},
+	{"user-agent", ""},
+	{"x-forwarded-for", ""},
+	{"x-frame-options", "deny"},
+	{"x-frame-options", "sameorigin"},
+}
+
+var (
+	// qpackStaticByField maps a static table entry to its index.
+	qpackStaticByField = make(map[qpackField]int, len(qpackStaticTable))
+	// qpackStaticByName maps a field name to the index
+	// of the first static table entry with that name.
+	qpackStaticByName = make(map[string]int)
+)
+
+func init() {
+	for i, f := range qpackStaticTable {
+		qpackStaticByField[f] = i
+		if _, ok := qpackStaticByName[f.name]; !ok {
+			qpackStaticByName[f.name] = i
+		}
+	}
+}
+
+// qpackAppendPrefix appends the encoded field section prefix.
+// Since the dynamic table is never used, the Required Insert Count
+// and Delta Base are both zero.
+func qpackAppendPrefix(b []byte) []byte {
+	return append(b, 0, 0)
+}
+
+// qpackAppendField appends the encoding of a single field line.
+// The name must be lowercase.
+func qpackAppendField(b []byte, name, value string) []byte {
+	if i, ok := qpackStaticByField[qpackField{name, value}]; ok {
+		// Indexed field line, static table.
+		return qpackAppendInt(b, 6, 0xc0, uint64(i))
+	}
+	if i, ok := qpackStaticByName[name]; ok {
+		// Literal field line with static name reference.
+		b = qpackAppendInt(b, 4, 0x50, uint64(i))
+		return qpackAppendString(b, 7, 0x00, value)
+	}
+	// Literal field line with literal name.
+	b = qpackAppendString(b, 3, 0x20, name)
+	return qpackAppendString(b, 7, 0x00, value)
+}
+
+// qpackAppendInt appends an integer with an n-bit prefix.
+// The high bits of the first byte are taken from first.
+// See RFC 7541, Section 5.1.
+func qpackAppendInt(b []byte, n uint, first byte, v uint64) []byte {
+	max := uint64(1)<<n - 1
+	if v < max {
+		return append(b, first|byte(v))
+	}
+	b = append(b, first|byte(max))
+	v -= max
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// qpackAppendString appends a string literal with an n-bit length prefix.
+// The Huffman flag is the bit above the prefix.
+func qpackAppendString(b []byte, n uint, first byte, s string) []byte {
+	if l := hpack.HuffmanEncodeLength(s); l < uint64(len(s)) {
+		b = qpackAppendInt(b, n, first|1<<n, l)
+		return hpack.AppendHuffmanString(b, s)
+	}
+	b = qpackAppendInt(b, n, first, uint64(len(s)))
+	return append(b, s...)
+}
+
+// qpackReadInt reads an integer with an n-bit prefix.
+func qpackReadInt(b []byte, n uint) (v uint64, rest []byte, err error) {
+	if len(b) == 0 {
+		return 0, nil, errQPACKInvalid
+	}
+	max := uint64(1)<<n - 1
+	v = uint64(b[0]) & max
+	b = b[1:]
+	if v < max {
+		return v, b, nil
+	}
+	var shift uint
+	for {
+		if len(b) == 0 || shift > 56 {
+			return 0, nil, errQPACKInvalid
+		}
+		c := b[0]
+		b = b[1:]
+		v += uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return v, b, nil
+		}
+		shift += 7
+	}
+}
+
+// qpackReadString reads a string literal with an n-bit length prefix.
+func qpackReadString(b []byte, n uint) (s string, rest []byte, err error) {
+	if len(b) == 0 {
+		return "", nil, errQPACKInvalid
+	}
+	huffman := b[0]&(1<<n) != 0
+	l, b, err := qpackReadInt(b, n)
+	if err != nil {
+		return "", nil, err
+	}
+	if uint64(len(b)) < l {
+		return "", nil, errQPACKInvalid
+	}
+	p := b[:l]
+	b = b[l:]
+	if !huffman {
+		return string(p), b, nil
+	}
+	s, err = hpack.HuffmanDecodeToString(p)
+	if err != nil {
+		return "", nil, errQPACKInvalid
+	}
+	return s, b, nil
+}
+
+// qpackDecode decodes an encoded field section, calling f for each field line.
+// References to the dynamic table are errors, since its capacity is zero.
+func qpackDecode(b []byte, f func(name, value string) error) error {
+	ric, b, err := qpackReadInt(b, 8)
+	if err != nil {
+		return err
+	}
+	if ric != 0 {
+		return errQPACKInvalid
+	}
+	if _, b, err = qpackReadInt(b, 7); err != nil {
+		return err
+	}
+	for len(b) > 0 {
+		var name, value string
+		c := b[0]
+		switch {
+		case c&0x80 != 0:
+			// Indexed field line.
+			if c&0x40 == 0 {
+				return errQPACKInvalid
+			}
+			var i uint64
+			i, b, err = qpackReadInt(b, 6)
+			if err != nil {
+				return err
+			}
+			if i >= uint64(len(qpackStaticTable)) {
+				return errQPACKInvalid
+			}
+			name, value = qpackStaticTable[i].name, qpackStaticTable[i].value
+		case c&0x40 != 0:
+			// Literal field line with name reference.
+			if c&0x10 == 0 {
+				return errQPACKInvalid
+			}
+			var i uint64
+			i, b, err = qpackReadInt(b, 4)
+			if err != nil {
+				return err
+			}
+			if i >= uint64(len(qpackStaticTable)) {
+				return errQPACKInvalid
+			}
+			name = qpackStaticTable[i].name
+			if value, b, err = qpackReadString(b, 7); err != nil {
+				return err
+			}
+		case c&0x20 != 0:
+			// Literal field line with literal name.
+			if name, b, err = qpackReadString(b, 3); err != nil {
+				return err
+			}
+			if value, b, err = qpackReadString(b, 7); err != nil {
+				return err
+			}
+		default:
+			// Post-base references to the dynamic table.
+			return errQPACKInvalid
+		}
+		if err := f(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,908 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// HTTP/3 server.
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"internal/quic"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// http3AltSvcMaxAge is the lifetime, in seconds, of the
+// Alt-Svc header advertising HTTP/3 to HTTP/1 and HTTP/2 clients.
+const http3AltSvcMaxAge = 30 * 24 * 60 * 60
+
+// ServeQUIC accepts incoming HTTP/3 connections on the PacketConn pc,
+// creating a new service goroutine for each request.
+// The service goroutines read requests and then call srv.Handler to
+// reply to them.
+//
+// Files containing a certificate and matching private key for the
+// server must be provided if neither the Server's
+// TLSConfig.Certificates nor TLSConfig.GetCertificate are populated,
+// as for ServeTLS.
+//
+// While ServeQUIC is running, responses sent over TLS by HTTP/1 and
+// HTTP/2 include an Alt-Svc header advertising HTTP/3 on the port of pc,
+// unless the handler sets its own Alt-Svc header.
+//
+// ServeQUIC always returns a non-nil error and closes pc.
+// After Shutdown or Close, the returned error is ErrServerClosed.
+func (srv *Server) ServeQUIC(pc net.PacketConn, certFile, keyFile string) error {
+	// Set up HTTP/2 first, since it may modify srv.TLSConfig.
+	if err := srv.setupHTTP2_ServeTLS(); err != nil {
+		pc.Close()
+		return err
+	}
+
+	config := cloneTLSConfig(srv.TLSConfig)
+	config.NextProtos = []string{http3NextProto}
+	config.MinVersion = tls.VersionTLS13
+
+	configHasCert := len(config.Certificates) > 0 || config.GetCertificate != nil
+	if !configHasCert || certFile != "" || keyFile != "" {
+		var err error
+		config.Certificates = make([]tls.Certificate, 1)
+		config.Certificates[0], err = tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			pc.Close()
+			return err
+		}
+	}
+
+	qconfig := &quic.Config{
+		TLSConfig:      config,
+		MaxIdleTimeout: srv.idleTimeout(),
+	}
+	e := quic.NewEndpoint(pc, qconfig)
+	if !srv.trackQUICEndpoint(e, true) {
+		e.Close(context.Background())
+		return ErrServerClosed
+	}
+	if addr, ok := pc.LocalAddr().(*net.UDPAddr); ok {
+		srv.altSvc.Store(fmt.Sprintf(`h3=":%d"; ma=%d`, addr.Port, http3AltSvcMaxAge))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-srv.getDoneChan():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for {
+		qc, err := e.Accept(ctx)
+		if err != nil {
+			if srv.shuttingDown() {
+				// The endpoint is closed by Close, or by Shutdown
+				// once its connections are finished.
+				return ErrServerClosed
+			}
+			srv.trackQUICEndpoint(e, false)
+			e.Close(context.Background())
+			return err
+		}
+		c := &http3ServerConn{
+			srv: srv,
+			qc:  qc,
+		}
+		c.cs.isServer = true
+		if !srv.trackHTTP3Conn(c, true) {
+			qc.Abort(uint64(http3ErrNoError), "")
+			continue
+		}
+		go c.serve()
+	}
+}
+
+// ListenAndServeQUIC listens on the UDP network address srv.Addr and
+// then calls ServeQUIC to handle HTTP/3 requests on incoming connections.
+//
+// Certificates are provided as for ListenAndServeTLS.
+// To serve HTTP/3 alongside HTTP/1 and HTTP/2, call ListenAndServeTLS
+// and ListenAndServeQUIC with the same address from separate goroutines.
+//
+// If srv.Addr is blank, ":https" is used.
+//
+// ListenAndServeQUIC always returns a non-nil error. After Shutdown or
+// Close, the returned error is ErrServerClosed.
+func (srv *Server) ListenAndServeQUIC(certFile, keyFile string) error {
+	if srv.shuttingDown() {
+		return ErrServerClosed
+	}
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":https"
+	}
+
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	return srv.ServeQUIC(pc, certFile, keyFile)
+}
+
+// trackQUICEndpoint adds or removes a QUIC endpoint from the set of
+// tracked endpoints. It reports whether the server is still up.
+func (s *Server) trackQUICEndpoint(e *quic.Endpoint, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.quicEndpoints == nil {
+		s.quicEndpoints = make(map[*quic.Endpoint]struct{})
+	}
+	if add {
+		if s.shuttingDown() {
+			return false
+		}
+		s.quicEndpoints[e] = struct{}{}
+	} else {
+		delete(s.quicEndpoints, e)
+	}
+	return true
+}
+
+// trackHTTP3Conn adds or removes an HTTP/3 connection from the set of
+// tracked connections. It reports whether the server is still up.
+func (s *Server) trackHTTP3Conn(c *http3ServerConn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.http3Conns == nil {
+		s.http3Conns = make(map[*http3ServerConn]struct{})
+	}
+	if add {
+		if s.shuttingDown() {
+			return false
+		}
+		s.http3Conns[c] = struct{}{}
+		atomic.AddInt64(&s.getStats().http3Conns, 1)
+	} else {
+		s.removeHTTP3ConnLocked(c)
+	}
+	return true
+}
+
+// removeHTTP3ConnLocked stops tracking c, if it is tracked.
+// s.mu must be held.
+func (s *Server) removeHTTP3ConnLocked(c *http3ServerConn) {
+	if _, ok := s.http3Conns[c]; ok {
+		delete(s.http3Conns, c)
+		atomic.AddInt64(&s.getStats().http3Conns, -1)
+	}
+}
+
+// closeQUICEndpointsLocked removes the server's QUIC endpoints
+// and returns them, to be closed without holding s.mu.
+func (s *Server) closeQUICEndpointsLocked() []*quic.Endpoint {
+	s.altSvc.Store("")
+	var endpoints []*quic.Endpoint
+	for e := range s.quicEndpoints {
+		endpoints = append(endpoints, e)
+		delete(s.quicEndpoints, e)
+	}
+	for c := range s.http3Conns {
+		s.removeHTTP3ConnLocked(c)
+	}
+	return endpoints
+}
+
+// shutdownHTTP3Locked sends a GOAWAY on every HTTP/3 connection.
+func (s *Server) shutdownHTTP3Locked() {
+	s.altSvc.Store("")
+	for c := range s.http3Conns {
+		go c.goaway()
+	}
+}
+
+// closeIdleHTTP3ConnsLocked closes idle HTTP/3 connections and reports
+// whether none remain. Once none remain, the QUIC endpoints are closed.
+func (s *Server) closeIdleHTTP3ConnsLocked() bool {
+	for c := range s.http3Conns {
+		if !c.isIdle() {
+			continue
+		}
+		c.qc.Abort(uint64(http3ErrNoError), "")
+		s.removeHTTP3ConnLocked(c)
+	}
+	if len(s.http3Conns) > 0 {
+		return false
+	}
+	if s.shuttingDown() {
+		for _, e := range s.closeQUICEndpointsLocked() {
+			go e.Close(context.Background())
+		}
+	}
+	return true
+}
+
+// http3ServerConn is a server's HTTP/3 connection.
+type http3ServerConn struct {
+	srv *Server
+	qc  *quic.Conn
+	cs  http3ControlStream
+
+	controlMu sync.Mutex
+	control   *quic.Stream // our control stream
+
+	mu         sync.Mutex
+	active     int   // requests being handled
+	nextID     int64 // ID after the highest request stream ID accepted
+	goawaySent bool
+	goawayID   int64
+}
+
+func (c *http3ServerConn) serve() {
+	defer c.srv.trackHTTP3Conn(c, false)
+
+	ctx := context.WithValue(context.Background(), ServerContextKey, c.srv)
+	ctx = context.WithValue(ctx, LocalAddrContextKey, c.qc.LocalAddr())
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.qc.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	control, err := c.qc.NewSendOnlyStream(ctx)
+	if err != nil {
+		return
+	}
+	b := quic.AppendVarint(nil, http3StreamControl)
+	b = http3AppendSettings(b, int64(c.srv.maxHeaderBytes()))
+	c.controlMu.Lock()
+	c.control = control
+	_, err = control.Write(b)
+	c.controlMu.Unlock()
+	if err != nil {
+		return
+	}
+
+	for {
+		st, err := c.qc.AcceptStream(ctx)
+		if err != nil {
+			return
+		}
+		if st.IsReadOnly() {
+			go func() {
+				if err := c.cs.handleUniStream(st); err != nil {
+					http3AbortConn(c.qc, err)
+				}
+			}()
+			continue
+		}
+		c.mu.Lock()
+		reject := c.goawaySent && st.ID() >= c.goawayID
+		if !reject {
+			c.active++
+			if id := st.ID() + 4; id > c.nextID {
+				c.nextID = id
+			}
+		}
+		c.mu.Unlock()
+		if reject {
+			st.Reset(uint64(http3ErrRequestRejected))
+			st.CloseRead(uint64(http3ErrRequestRejected))
+			continue
+		}
+		go c.serveRequest(ctx, st)
+	}
+}
+
+// isIdle reports whether no requests are being handled.
+func (c *http3ServerConn) isIdle() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active == 0
+}
+
+// goaway starts a graceful shutdown of the connection,
+// notifying the client that no further requests will be processed.
+func (c *http3ServerConn) goaway() {
+	c.mu.Lock()
+	if c.goawaySent {
+		c.mu.Unlock()
+		return
+	}
+	c.goawaySent = true
+	c.goawayID = c.nextID
+	id := c.goawayID
+	c.mu.Unlock()
+
+	c.controlMu.Lock()
+	defer c.controlMu.Unlock()
+	if c.control == nil {
+		return
+	}
+	p := quic.AppendVarint(nil, uint64(id))
+	b := http3AppendFrameHeader(nil, http3FrameGoaway, uint64(len(p)))
+	c.control.Write(append(b, p...))
+}
+
+func (c *http3ServerConn) requestDone() {
+	c.mu.Lock()
+	c.active--
+	c.mu.Unlock()
+}
+
+func (c *http3ServerConn) serveRequest(ctx context.Context, st *quic.Stream) {
+	defer c.requestDone()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rw, req, err := c.readRequest(ctx, st)
+	if err != nil {
+		var se *http3StreamError
+		if errors.As(err, &se) {
+			st.Reset(uint64(se.code))
+			st.CloseRead(uint64(se.code))
+			return
+		}
+		var ce *http3ConnError
+		if errors.As(err, &ce) {
+			http3AbortConn(c.qc, err)
+			return
+		}
+		// The stream was reset or the connection closed.
+		st.Reset(uint64(http3ErrRequestIncomplete))
+		st.CloseRead(uint64(http3ErrRequestIncomplete))
+		return
+	}
+
+	didPanic := true
+	defer func() {
+		rw.handlerDone = true
+		if didPanic {
+			e := recover()
+			if e != ErrAbortHandler {
+				const size = 64 << 10
+				buf := make([]byte, size)
+				buf = buf[:runtime.Stack(buf, false)]
+				c.srv.logf("http3: panic serving %v: %v\n%s", c.qc.RemoteAddr(), e, buf)
+			}
+			st.Reset(uint64(http3ErrInternalError))
+			st.CloseRead(uint64(http3ErrInternalError))
+			return
+		}
+		rw.finishRequest()
+	}()
+	serverHandler{c.srv}.ServeHTTP(rw, req)
+	didPanic = false
+}
+
+// readRequest reads the request header from a request stream.
+func (c *http3ServerConn) readRequest(ctx context.Context, st *quic.Stream) (*http3ResponseWriter, *Request, error) {
+	br := bufio.NewReader(st)
+	var payload []byte
+	for payload == nil {
+		typ, length, err := http3ReadFrameHeader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case typ == http3FrameHeaders:
+			payload, err = http3ReadFramePayload(br, length, int64(c.srv.maxHeaderBytes()))
+			if err == errHTTP3FrameTooLarge {
+				return nil, nil, &http3StreamError{http3ErrExcessiveLoad, "request header too large"}
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+		case http3IsReservedFrame(typ):
+			if _, err := io.CopyN(io.Discard, br, int64(length)); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, &http3ConnError{http3ErrFrameUnexpected, "request did not begin with HEADERS"}
+		}
+	}
+
+	var method, scheme, authority, path string
+	header := make(Header)
+	sawRegular := false
+	err := qpackDecode(payload, func(name, value string) error {
+		if !strings.HasPrefix(name, ":") {
+			if !httpguts.ValidHeaderFieldName(name) || !http3IsLowerFieldName(name) ||
+				!httpguts.ValidHeaderFieldValue(value) || http3ConnectionSpecificHeaders[name] {
+				return fmt.Errorf("invalid header field %q", name)
+			}
+			if name == "te" && value != "trailers" {
+				return errors.New("invalid TE header")
+			}
+			sawRegular = true
+			header.Add(CanonicalHeaderKey(name), value)
+			return nil
+		}
+		if sawRegular {
+			return errors.New("pseudo-header after regular header")
+		}
+		var p *string
+		switch name {
+		case ":method":
+			p = &method
+		case ":scheme":
+			p = &scheme
+		case ":authority":
+			p = &authority
+		case ":path":
+			p = &path
+		default:
+			return fmt.Errorf("invalid pseudo-header %q", name)
+		}
+		if *p != "" {
+			return fmt.Errorf("duplicate pseudo-header %q", name)
+		}
+		*p = value
+		return nil
+	})
+	if err != nil {
+		return nil, nil, &http3StreamError{http3ErrMessageError, err.Error()}
+	}
+	if method == "CONNECT" {
+		if path != "" || scheme != "" || authority == "" {
+			return nil, nil, &http3StreamError{http3ErrMessageError, "invalid CONNECT request"}
+		}
+	} else if method == "" || path == "" || (scheme != "https" && scheme != "http") {
+		return nil, nil, &http3StreamError{http3ErrMessageError, "missing pseudo-header"}
+	}
+	if authority == "" {
+		authority = header.Get("Host")
+	}
+
+	needsContinue := header.Get("Expect") == "100-continue"
+	if needsContinue {
+		header.Del("Expect")
+	}
+	// Merge Cookie headers into one "; "-delimited value.
+	if cookies := header["Cookie"]; len(cookies) > 1 {
+		header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+
+	var trailer Header
+	for _, v := range header["Trailer"] {
+		for _, key := range strings.Split(v, ",") {
+			key = CanonicalHeaderKey(textproto.TrimString(key))
+			switch key {
+			case "Transfer-Encoding", "Trailer", "Content-Length":
+				// Bogus. (copy of http1 rules)
+				// Ignore.
+			default:
+				if trailer == nil {
+					trailer = make(Header)
+				}
+				trailer[key] = nil
+			}
+		}
+	}
+	delete(header, "Trailer")
+
+	var u *url.URL
+	var requestURI string
+	if method == "CONNECT" {
+		u = &url.URL{Host: authority}
+		requestURI = authority // mimic HTTP/1 server behavior
+	} else {
+		u, err = url.ParseRequestURI(path)
+		if err != nil {
+			return nil, nil, &http3StreamError{http3ErrMessageError, "invalid :path"}
+		}
+		requestURI = path
+	}
+
+	contentLength := int64(-1)
+	if vv, ok := header["Content-Length"]; ok {
+		cl, err := strconv.ParseUint(vv[0], 10, 63)
+		if err != nil || len(vv) > 1 {
+			return nil, nil, &http3StreamError{http3ErrMessageError, "invalid Content-Length"}
+		}
+		contentLength = int64(cl)
+	}
+
+	cs := c.qc.ConnectionState()
+	req := &Request{
+		Method:        method,
+		URL:           u,
+		RemoteAddr:    c.qc.RemoteAddr().String(),
+		Header:        header,
+		RequestURI:    requestURI,
+		Proto:         "HTTP/3.0",
+		ProtoMajor:    3,
+		ProtoMinor:    0,
+		TLS:           &cs,
+		Host:          authority,
+		ContentLength: contentLength,
+		Trailer:       trailer,
+	}
+	rw := &http3ResponseWriter{
+		conn:          c,
+		st:            st,
+		req:           req,
+		handlerHeader: make(Header),
+	}
+	rw.bw = bufio.NewWriterSize(http3ChunkWriter{rw}, bufferBeforeChunkingSize)
+	body := &http3RequestBody{
+		r: &http3BodyReader{
+			st:              st,
+			br:              br,
+			maxTrailerBytes: int64(c.srv.maxHeaderBytes()),
+			onTrailers: func(t Header) {
+				if req.Trailer == nil {
+					req.Trailer = make(Header)
+				}
+				for k, vv := range t {
+					req.Trailer[k] = vv
+				}
+			},
+		},
+		rw:            rw,
+		contentLength: contentLength,
+		needsContinue: needsContinue,
+	}
+	if contentLength == 0 {
+		req.Body = NoBody
+	} else {
+		req.Body = body
+	}
+	rw.body = body
+	req = req.WithContext(ctx)
+	rw.req = req
+	return rw, req, nil
+}
+
+// http3RequestBody is the Body of a request received over HTTP/3.
+type http3RequestBody struct {
+	r             *http3BodyReader
+	rw            *http3ResponseWriter
+	contentLength int64 // or -1 if unknown
+
+	mu            sync.Mutex
+	needsContinue bool // need to send a 100-continue
+	n             int64
+	sawEOF        bool
+	closed        bool
+}
+
+func (b *http3RequestBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return 0, ErrBodyReadAfterClose
+	}
+	if b.needsContinue {
+		b.needsContinue = false
+		b.rw.writeContinue()
+	}
+	b.mu.Unlock()
+	n, err := b.r.Read(p)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.n += int64(n)
+	if b.contentLength >= 0 && (b.n > b.contentLength || err == io.EOF && b.n != b.contentLength) {
+		b.r.abort(http3ErrMessageError, errors.New("http3: request body does not match Content-Length"))
+		b.rw.st.Reset(uint64(http3ErrMessageError))
+		return n, b.r.err
+	}
+	if err == io.EOF {
+		b.sawEOF = true
+	}
+	return n, err
+}
+
+func (b *http3RequestBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.needsContinue = false
+	if !b.sawEOF {
+		// Ask the client to stop sending the rest of the body.
+		b.r.abort(http3ErrNoError, ErrBodyReadAfterClose)
+	}
+	return nil
+}
+
+// http3ResponseWriter is the ResponseWriter of an HTTP/3 request.
+type http3ResponseWriter struct {
+	conn *http3ServerConn
+	st   *quic.Stream
+	req  *Request
+	body *http3RequestBody
+	bw   *bufio.Writer // writing to an http3ChunkWriter
+
+	handlerHeader Header // mutable until WriteHeader
+	snapHeader    Header // immutable snapshot of handlerHeader at WriteHeader time
+	trailers      []string
+
+	status         int
+	wroteHeader    bool // WriteHeader called
+	sentHeader     bool // HEADERS frame written
+	handlerDone    bool
+	sentContentLen int64
+	wroteBytes     int64
+
+	mu           sync.Mutex // guards writes of HEADERS before the final response
+	sentContinue bool
+}
+
+type http3ChunkWriter struct{ rw *http3ResponseWriter }
+
+func (cw http3ChunkWriter) Write(p []byte) (n int, err error) {
+	return cw.rw.writeChunk(p)
+}
+
+func (w *http3ResponseWriter) Header() Header {
+	if w.handlerDone {
+		panic("Header called after Handler finished")
+	}
+	return w.handlerHeader
+}
+
+func (w *http3ResponseWriter) WriteHeader(code int) {
+	if w.handlerDone {
+		panic("WriteHeader called after Handler finished")
+	}
+	w.writeHeader(code)
+}
+
+func (w *http3ResponseWriter) writeHeader(code int) {
+	checkWriteHeaderCode(code)
+	if w.wroteHeader {
+		return
+	}
+	if code >= 100 && code <= 199 {
+		w.writeInformational(code)
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	if len(w.handlerHeader) > 0 {
+		w.snapHeader = w.handlerHeader.Clone()
+	}
+}
+
+func (w *http3ResponseWriter) Write(p []byte) (n int, err error) {
+	return w.write(len(p), p, "")
+}
+
+func (w *http3ResponseWriter) WriteString(s string) (n int, err error) {
+	return w.write(len(s), nil, s)
+}
+
+// write writes either dataB or dataS to the body.
+func (w *http3ResponseWriter) write(lenData int, dataB []byte, dataS string) (n int, err error) {
+	if w.handlerDone {
+		panic("Write called after Handler finished")
+	}
+	if !w.wroteHeader {
+		w.writeHeader(StatusOK)
+	}
+	if !bodyAllowedForStatus(w.status) {
+		return 0, ErrBodyNotAllowed
+	}
+	w.wroteBytes += int64(lenData)
+	if w.sentContentLen != 0 && w.wroteBytes > w.sentContentLen {
+		return 0, ErrContentLength
+	}
+	if dataB != nil {
+		return w.bw.Write(dataB)
+	}
+	return w.bw.WriteString(dataS)
+}
+
+func (w *http3ResponseWriter) Flush() {
+	w.FlushError()
+}
+
+func (w *http3ResponseWriter) FlushError() error {
+	if w.bw.Buffered() > 0 {
+		return w.bw.Flush()
+	}
+	_, err := w.writeChunk(nil)
+	return err
+}
+
+func (w *http3ResponseWriter) EnableFullDuplex() error {
+	// Request and response bodies are carried on independent
+	// halves of the stream, so this is a no-op.
+	return nil
+}
+
+// writeContinue sends a 100 Continue response,
+// unless the final response has already been sent.
+func (w *http3ResponseWriter) writeContinue() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.sentHeader || w.sentContinue {
+		return
+	}
+	w.sentContinue = true
+	b := qpackAppendPrefix(nil)
+	b = qpackAppendField(b, ":status", "100")
+	http3WriteHeaders(w.st, b)
+}
+
+// writeInformational sends an informational (1xx) response
+// carrying the current handler headers. Per RFC 8297, the
+// handler's header map is not cleared afterwards.
+func (w *http3ResponseWriter) writeInformational(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.sentHeader {
+		return
+	}
+	if code == StatusContinue {
+		w.sentContinue = true
+	}
+	b := qpackAppendPrefix(nil)
+	b = qpackAppendField(b, ":status", strconv.Itoa(code))
+	b = http3AppendHeaderFields(b, w.handlerHeader, func(name string) bool {
+		return name == "content-length"
+	})
+	http3WriteHeaders(w.st, b)
+}
+
+// declareTrailer notes that a header will need to be written
+// in the trailers at the end of the response.
+func (w *http3ResponseWriter) declareTrailer(k string) {
+	k = CanonicalHeaderKey(k)
+	if !httpguts.ValidTrailerHeader(k) {
+		// Forbidden by RFC 7230, section 4.1.2.
+		w.conn.srv.logf("http3: ignoring invalid trailer %q", k)
+		return
+	}
+	if !strSliceContains(w.trailers, k) {
+		w.trailers = append(w.trailers, k)
+	}
+}
+
+// writeChunk writes chunks from the bufio.Writer,
+// sending the response HEADERS before the first chunk.
+func (w *http3ResponseWriter) writeChunk(p []byte) (n int, err error) {
+	if !w.wroteHeader {
+		w.writeHeader(StatusOK)
+	}
+	isHeadResp := w.req.Method == "HEAD"
+	if !w.sentHeader {
+		h := w.snapHeader
+		if h == nil {
+			h = make(Header)
+		}
+		var ctype, clen string
+		if clen = h.Get("Content-Length"); clen != "" {
+			h.Del("Content-Length")
+			if cl, err := strconv.ParseUint(clen, 10, 63); err == nil {
+				w.sentContentLen = int64(cl)
+			} else {
+				clen = ""
+			}
+		}
+		if clen == "" && w.handlerDone && bodyAllowedForStatus(w.status) && (len(p) > 0 || !isHeadResp) {
+			clen = strconv.Itoa(len(p))
+		}
+		_, hasContentType := h["Content-Type"]
+		// If the Content-Encoding is non-blank, we shouldn't
+		// sniff the body. See Issue golang.org/issue/31753.
+		hasCE := h.Get("Content-Encoding") != ""
+		if !hasCE && !hasContentType && bodyAllowedForStatus(w.status) && len(p) > 0 {
+			ctype = DetectContentType(p)
+		}
+		var date string
+		if _, ok := h["Date"]; !ok {
+			date = time.Now().UTC().Format(TimeFormat)
+		}
+		for _, v := range h["Trailer"] {
+			foreachHeaderElement(v, w.declareTrailer)
+		}
+		// Connection headers aren't allowed in HTTP/3, but respect
+		// "Connection: close" by sending a GOAWAY, as for HTTP/2.
+		if v := h.Get("Connection"); v == "close" {
+			go w.conn.goaway()
+		}
+
+		b := qpackAppendPrefix(nil)
+		b = qpackAppendField(b, ":status", strconv.Itoa(w.status))
+		b = http3AppendHeaderFields(b, h, func(name string) bool {
+			return name == "content-length" || name == "content-type" && ctype != "" || name == "date" && date != ""
+		})
+		if ctype != "" {
+			b = qpackAppendField(b, "content-type", ctype)
+		}
+		if clen != "" {
+			b = qpackAppendField(b, "content-length", clen)
+		}
+		if date != "" {
+			b = qpackAppendField(b, "date", date)
+		}
+		w.mu.Lock()
+		w.sentHeader = true
+		err := http3WriteHeaders(w.st, b)
+		w.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if isHeadResp || len(p) == 0 {
+		return len(p), nil
+	}
+	dw := http3DataWriter{st: w.st}
+	return dw.Write(p)
+}
+
+// finishRequest completes the response after the handler returns.
+func (w *http3ResponseWriter) finishRequest() {
+	defer w.body.Close()
+	if !w.wroteHeader {
+		w.writeHeader(StatusOK)
+	}
+	if w.bw.Buffered() > 0 {
+		if err := w.bw.Flush(); err != nil {
+			w.st.Reset(uint64(http3ErrInternalError))
+			return
+		}
+	} else if !w.sentHeader {
+		w.writeChunk(nil)
+	}
+	if w.sentContentLen != 0 && w.wroteBytes < w.sentContentLen && w.req.Method != "HEAD" {
+		// The handler wrote less than it declared.
+		w.st.Reset(uint64(http3ErrInternalError))
+		return
+	}
+	if w.req.Method != "HEAD" {
+		w.writeTrailers()
+	}
+	w.st.CloseWrite()
+}
+
+// writeTrailers writes the trailers declared by the handler,
+// including undeclared trailers prefixed with TrailerPrefix.
+func (w *http3ResponseWriter) writeTrailers() {
+	for k, vv := range w.handlerHeader {
+		if !strings.HasPrefix(k, TrailerPrefix) {
+			continue
+		}
+		trailerKey := strings.TrimPrefix(k, TrailerPrefix)
+		w.declareTrailer(trailerKey)
+		w.handlerHeader[CanonicalHeaderKey(trailerKey)] = vv
+	}
+	sort.Strings(w.trailers)
+	var b []byte
+	for _, k := range w.trailers {
+		vv, ok := w.handlerHeader[k]
+		if !ok {
+			continue
+		}
+		if b == nil {
+			b = qpackAppendPrefix(nil)
+		}
+		b = http3AppendHeaderFields(b, Header{k: vv}, nil)
+	}
+	if b != nil {
+		http3WriteHeaders(w.st, b)
+	}
+}
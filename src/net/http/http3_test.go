@@ -0,0 +1,425 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	. "net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/http/internal/testcert"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type http3Test struct {
+	t    *testing.T
+	ts   *httptest.Server
+	tr   *Transport
+	c    *Client
+	errc chan error // result of ServeQUIC
+}
+
+// newHTTP3Test starts a server handling h over HTTP/1 on TCP
+// and HTTP/3 on UDP, and returns a client with HTTP/3 enabled.
+func newHTTP3Test(t *testing.T, h Handler) *http3Test {
+	cert, err := tls.X509KeyPair(testcert.LocalhostCert, testcert.LocalhostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(h)
+	ts.Config.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	ts.StartTLS()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		ts.Close()
+		t.Fatal(err)
+	}
+	st := &http3Test{
+		t:    t,
+		ts:   ts,
+		errc: make(chan error, 1),
+	}
+	go func() {
+		st.errc <- ts.Config.ServeQUIC(pc, "", "")
+	}()
+	st.tr = &Transport{
+		TLSClientConfig: ts.Client().Transport.(*Transport).TLSClientConfig,
+		EnableHTTP3:     true,
+	}
+	st.c = &Client{Transport: st.tr}
+	return st
+}
+
+func (st *http3Test) close() {
+	st.tr.CloseIdleConnections()
+	st.ts.Close()
+	st.ts.Config.Close()
+	select {
+	case err := <-st.errc:
+		if err != ErrServerClosed {
+			st.t.Errorf("ServeQUIC = %v, want ErrServerClosed", err)
+		}
+	case <-time.After(10 * time.Second):
+		st.t.Errorf("ServeQUIC did not return after Close")
+	}
+}
+
+// upgrade sends requests until one uses HTTP/3.
+func (st *http3Test) upgrade() {
+	st.t.Helper()
+	for i := 0; i < 2; i++ {
+		res, err := st.c.Get(st.ts.URL)
+		if err != nil {
+			st.t.Fatal(err)
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		if res.ProtoMajor == 3 {
+			return
+		}
+	}
+	st.t.Fatal("second request did not use HTTP/3")
+}
+
+func TestHTTP3AltSvcUpgrade(t *testing.T) {
+	defer afterTest(t)
+	st := newHTTP3Test(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "%v %v", r.Proto, r.Host)
+	}))
+	defer st.close()
+
+	res, err := st.c.Get(st.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.ProtoMajor != 1 {
+		t.Errorf("first request Proto = %v, want HTTP/1.1", res.Proto)
+	}
+	if got := res.Header.Get("Alt-Svc"); !strings.HasPrefix(got, `h3=":`) {
+		t.Errorf("Alt-Svc = %q, want h3 alternative", got)
+	}
+
+	res, err = st.c.Get(st.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ProtoMajor != 3 || res.Proto != "HTTP/3.0" {
+		t.Errorf("second request Proto = %v, want HTTP/3.0", res.Proto)
+	}
+	if res.TLS == nil || res.TLS.Version != tls.VersionTLS13 {
+		t.Errorf("second request TLS state = %+v, want TLS 1.3", res.TLS)
+	}
+	if got := res.Header.Get("Alt-Svc"); got != "" {
+		t.Errorf("HTTP/3 response has Alt-Svc header %q", got)
+	}
+	if want := "HTTP/3.0 " + strings.TrimPrefix(st.ts.URL, "https://"); string(body) != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestHTTP3RequestBodyAndTrailers(t *testing.T) {
+	defer afterTest(t)
+	st := newHTTP3Test(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("Trailer", "Declared")
+		n, err := io.Copy(w, r.Body)
+		if err != nil {
+			t.Errorf("server: reading body: %v", err)
+		}
+		w.Header().Set("Declared", fmt.Sprint(n))
+		w.Header().Set(TrailerPrefix+"Undeclared", r.Trailer.Get("Client-Trailer"))
+	}))
+	defer st.close()
+	st.upgrade()
+
+	for _, size := range []int{0, 1, 1 << 20} {
+		want := make([]byte, size)
+		rand.Read(want)
+		req, _ := NewRequest("POST", st.ts.URL, io.NopCloser(bytes.NewReader(want)))
+		req.Trailer = Header{"Client-Trailer": {"v"}}
+		res, err := st.c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatalf("size %v: reading body: %v", size, err)
+		}
+		if res.ProtoMajor != 3 {
+			t.Errorf("size %v: Proto = %v, want HTTP/3.0", size, res.Proto)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("size %v: echoed %v bytes, mismatched", size, len(got))
+		}
+		wantTrailer := Header{
+			"Declared":   {fmt.Sprint(size)},
+			"Undeclared": {"v"},
+		}
+		if fmt.Sprint(res.Trailer) != fmt.Sprint(wantTrailer) {
+			t.Errorf("size %v: Trailer = %v, want %v", size, res.Trailer, wantTrailer)
+		}
+	}
+}
+
+func TestHTTP3ResponseHeaders(t *testing.T) {
+	defer afterTest(t)
+	st := newHTTP3Test(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		switch r.URL.Path {
+		case "/html":
+			io.WriteString(w, "<html><body>hi</body></html>")
+		case "/notfound":
+			NotFound(w, r)
+		case "/nocontent":
+			w.WriteHeader(StatusNoContent)
+		}
+	}))
+	defer st.close()
+	st.upgrade()
+
+	for _, test := range []struct {
+		method, path string
+		code         int
+		ctype        string
+		clen         int64
+	}{
+		{"GET", "/html", 200, "text/html; charset=utf-8", 28},
+		{"HEAD", "/html", 200, "text/html; charset=utf-8", 28},
+		{"GET", "/notfound", 404, "text/plain; charset=utf-8", 19},
+		{"GET", "/nocontent", 204, "", 0},
+	} {
+		req, _ := NewRequest(test.method, st.ts.URL+test.path, nil)
+		res, err := st.c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		if res.StatusCode != test.code || res.Header.Get("Content-Type") != test.ctype || res.ContentLength != test.clen {
+			t.Errorf("%v %v: code %v, type %q, length %v; want %v, %q, %v",
+				test.method, test.path, res.StatusCode, res.Header.Get("Content-Type"), res.ContentLength,
+				test.code, test.ctype, test.clen)
+		}
+		if res.Header.Get("Date") == "" {
+			t.Errorf("%v %v: no Date header", test.method, test.path)
+		}
+	}
+}
+
+func TestHTTP3EarlyHints(t *testing.T) {
+	defer afterTest(t)
+	st := newHTTP3Test(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/hints" {
+			h := w.Header()
+			h.Add("Link", "</style.css>; rel=preload; as=style")
+			w.WriteHeader(StatusEarlyHints)
+			h.Add("Link", "</script.js>; rel=preload; as=script")
+			w.WriteHeader(StatusEarlyHints)
+		}
+		io.WriteString(w, "Hello")
+	}))
+	defer st.close()
+	st.upgrade()
+
+	var got [][]string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code != StatusEarlyHints {
+				t.Errorf("Got1xxResponse code = %d; want %d", code, StatusEarlyHints)
+			}
+			got = append(got, header["Link"])
+			return nil
+		},
+	}
+	req, _ := NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), "GET", st.ts.URL+"/hints", nil)
+	res, err := st.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.ProtoMajor != 3 {
+		t.Fatalf("Proto = %q; want HTTP/3.0", res.Proto)
+	}
+	want := [][]string{
+		{"</style.css>; rel=preload; as=style"},
+		{"</style.css>; rel=preload; as=style", "</script.js>; rel=preload; as=script"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("1xx Link headers = %q; want %q", got, want)
+	}
+	if res.StatusCode != StatusOK || string(body) != "Hello" {
+		t.Errorf("response = %v %q; want 200 %q", res.StatusCode, body, "Hello")
+	}
+}
+
+func TestHTTP3ConcurrentRequests(t *testing.T) {
+	defer afterTest(t)
+	st := newHTTP3Test(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		io.WriteString(w, r.URL.Query().Get("n"))
+	}))
+	defer st.close()
+	st.upgrade()
+
+	const n = 50
+	var wg sync.WaitGroup
+	errc := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := st.c.Get(fmt.Sprintf("%v/?n=%v", st.ts.URL, i))
+			if err != nil {
+				errc <- err
+				return
+			}
+			defer res.Body.Close()
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				errc <- err
+				return
+			}
+			if res.ProtoMajor != 3 || string(b) != fmt.Sprint(i) {
+				errc <- fmt.Errorf("request %v: got %v response %q", i, res.Proto, b)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		t.Error(err)
+	}
+}
+
+func TestHTTP3Cancel(t *testing.T) {
+	defer afterTest(t)
+	unblock := make(chan struct{})
+	st := newHTTP3Test(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/block" {
+			w.Header().Set("Content-Length", "10")
+			w.Write([]byte("a"))
+			w.(Flusher).Flush()
+			<-unblock
+		}
+	}))
+	defer st.close()
+	defer close(unblock)
+	st.upgrade()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := NewRequestWithContext(ctx, "GET", st.ts.URL+"/block", nil)
+	res, err := st.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ProtoMajor != 3 {
+		t.Fatalf("Proto = %v, want HTTP/3.0", res.Proto)
+	}
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := io.ReadAll(res.Body); err != context.Canceled {
+		t.Errorf("reading body after cancel: %v, want context.Canceled", err)
+	}
+	res.Body.Close()
+}
+
+func TestHTTP3Fallback(t *testing.T) {
+	defer afterTest(t)
+	// Find a UDP port with nothing listening on it.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadPort := pc.LocalAddr().(*net.UDPAddr).Port
+	pc.Close()
+
+	ts := httptest.NewTLSServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("Alt-Svc", fmt.Sprintf(`h3=":%v"`, deadPort))
+		io.WriteString(w, r.Proto)
+	}))
+	defer ts.Close()
+	tr := &Transport{
+		TLSClientConfig:     ts.Client().Transport.(*Transport).TLSClientConfig,
+		TLSHandshakeTimeout: 100 * time.Millisecond,
+		EnableHTTP3:         true,
+	}
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+	for i := 0; i < 3; i++ {
+		res, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("request %v: %v", i, err)
+		}
+		res.Body.Close()
+		if res.ProtoMajor != 1 {
+			t.Errorf("request %v: Proto = %v, want HTTP/1.1", i, res.Proto)
+		}
+	}
+}
+
+func TestHTTP3Shutdown(t *testing.T) {
+	defer afterTest(t)
+	inHandler := make(chan struct{}, 1)
+	unblock := make(chan struct{})
+	st := newHTTP3Test(t, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/slow" {
+			inHandler <- struct{}{}
+			<-unblock
+		}
+		io.WriteString(w, "done")
+	}))
+	defer st.tr.CloseIdleConnections()
+	defer st.ts.Close()
+	st.upgrade()
+
+	resc := make(chan string, 1)
+	go func() {
+		res, err := st.c.Get(st.ts.URL + "/slow")
+		if err != nil {
+			resc <- err.Error()
+			return
+		}
+		b, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		resc <- res.Proto + " " + string(b)
+	}()
+	<-inHandler
+
+	shutdownc := make(chan error, 1)
+	go func() {
+		shutdownc <- st.ts.Config.Shutdown(context.Background())
+	}()
+	if err := <-st.errc; err != ErrServerClosed {
+		t.Errorf("ServeQUIC = %v, want ErrServerClosed", err)
+	}
+	select {
+	case err := <-shutdownc:
+		t.Fatalf("Shutdown returned %v with a request in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(unblock)
+	if got, want := <-resc, "HTTP/3.0 done"; got != want {
+		t.Errorf("in-flight request during shutdown: %q, want %q", got, want)
+	}
+	if err := <-shutdownc; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+}
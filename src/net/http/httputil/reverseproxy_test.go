@@ -10,13 +10,16 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
 	"net/http/internal/ascii"
+	"net/textproto"
 	"net/url"
 	"os"
 	"reflect"
@@ -1517,3 +1520,473 @@ func TestJoinURLPath(t *testing.T) {
 		}
 	}
 }
+
+func TestReverseProxyRewriteStripsForwarded(t *testing.T) {
+	headers := []string{
+		"Forwarded",
+		"X-Forwarded-For",
+		"X-Forwarded-Host",
+		"X-Forwarded-Proto",
+	}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, h := range headers {
+			if v := r.Header.Get(h); v != "" {
+				t.Errorf("got %v header: %q", h, v)
+			}
+		}
+	}))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHandler := &ReverseProxy{
+		Rewrite: func(r *ProxyRequest) {
+			r.SetURL(backendURL)
+		},
+	}
+	frontend := httptest.NewServer(proxyHandler)
+	defer frontend.Close()
+
+	getReq, _ := http.NewRequest("GET", frontend.URL, nil)
+	getReq.Host = "some-name"
+	getReq.Close = true
+	for _, h := range headers {
+		getReq.Header.Set(h, "x")
+	}
+	res, err := frontend.Client().Do(getReq)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	res.Body.Close()
+}
+
+// Headers set by Rewrite must not be removable by hop-by-hop
+// header names in the client's Connection header.
+func TestReverseProxyRewriteHeadersSurviveConnection(t *testing.T) {
+	const fakeConnectionToken = "X-Fake-Connection-Token"
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get(fakeConnectionToken), "set by rewrite"; got != want {
+			t.Errorf("backend got %v header %q, want %q", fakeConnectionToken, got, want)
+		}
+		if got := r.Header.Get("X-Forwarded-For"); got == "" {
+			t.Errorf("backend got no X-Forwarded-For header")
+		}
+		if got, want := r.Header.Get("X-Forwarded-Host"), "some-name"; got != want {
+			t.Errorf("backend got X-Forwarded-Host %q, want %q", got, want)
+		}
+	}))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHandler := &ReverseProxy{
+		Rewrite: func(r *ProxyRequest) {
+			r.SetURL(backendURL)
+			r.SetXForwarded()
+			r.Out.Header.Set(fakeConnectionToken, "set by rewrite")
+		},
+	}
+	frontend := httptest.NewServer(proxyHandler)
+	defer frontend.Close()
+
+	getReq, _ := http.NewRequest("GET", frontend.URL, nil)
+	getReq.Host = "some-name"
+	getReq.Header.Set("Connection", fakeConnectionToken+", X-Forwarded-For, X-Forwarded-Host")
+	getReq.Header.Set(fakeConnectionToken, "set by client")
+	res, err := frontend.Client().Do(getReq)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	res.Body.Close()
+}
+
+func TestReverseProxyRewriteReplacesOut(t *testing.T) {
+	const content = "response_content"
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer backend.Close()
+	proxyHandler := &ReverseProxy{
+		Rewrite: func(r *ProxyRequest) {
+			r.Out, _ = http.NewRequest("GET", backend.URL, nil)
+		},
+	}
+	frontend := httptest.NewServer(proxyHandler)
+	defer frontend.Close()
+
+	res, err := frontend.Client().Get(frontend.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if got, want := string(body), content; got != want {
+		t.Errorf("got response %q, want %q", got, want)
+	}
+}
+
+func TestReverseProxyDirectorAndRewrite(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		director func(*http.Request)
+		rewrite  func(*ProxyRequest)
+	}{
+		{"neither", nil, nil},
+		{"both", func(*http.Request) {}, func(*ProxyRequest) {}},
+	} {
+		proxyHandler := &ReverseProxy{
+			Director:  test.director,
+			Rewrite:   test.rewrite,
+			Transport: failingRoundTripper{},
+			ErrorLog:  log.New(io.Discard, "", 0),
+		}
+		rw := httptest.NewRecorder()
+		proxyHandler.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+		if got, want := rw.Code, http.StatusBadGateway; got != want {
+			t.Errorf("%v: ServeHTTP status = %v, want %v", test.name, got, want)
+		}
+	}
+}
+
+func TestSetURL(t *testing.T) {
+	for _, test := range []struct {
+		target, in, want string
+	}{
+		{"http://backend.example.com/base", "http://example.com/dir?a=1", "http://backend.example.com/base/dir?a=1"},
+		{"http://backend.example.com/base/", "http://example.com/dir", "http://backend.example.com/base/dir"},
+		{"https://backend.example.com/?t=1", "http://example.com/dir?a=1", "https://backend.example.com/dir?t=1&a=1"},
+		{"http://backend.example.com/a%2Fb", "http://example.com/c%2Fd", "http://backend.example.com/a%2Fb/c%2Fd"},
+	} {
+		target, err := url.Parse(test.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in := httptest.NewRequest("GET", test.in, nil)
+		pr := &ProxyRequest{In: in, Out: in.Clone(context.Background())}
+		pr.SetURL(target)
+		if got := pr.Out.URL.String(); got != test.want {
+			t.Errorf("SetURL(%q) on %q: URL = %q, want %q", test.target, test.in, got, test.want)
+		}
+		if pr.Out.Host != "" {
+			t.Errorf("SetURL(%q) on %q: Host = %q, want empty", test.target, test.in, pr.Out.Host)
+		}
+	}
+}
+
+func TestSetXForwarded(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		prior  []string
+		tls    bool
+		xff    string
+		xproto string
+	}{
+		{"no prior", nil, false, "192.0.2.1", "http"},
+		{"prior", []string{"198.51.100.1", "198.51.100.2"}, true, "198.51.100.1, 198.51.100.2, 192.0.2.1", "https"},
+	} {
+		in := httptest.NewRequest("GET", "http://example.com/", nil)
+		in.RemoteAddr = "192.0.2.1:1234"
+		if test.tls {
+			in.TLS = new(tls.ConnectionState)
+		}
+		out := in.Clone(context.Background())
+		out.Header["X-Forwarded-For"] = test.prior
+		pr := &ProxyRequest{In: in, Out: out}
+		pr.SetXForwarded()
+		if got := out.Header.Get("X-Forwarded-For"); got != test.xff {
+			t.Errorf("%v: X-Forwarded-For = %q, want %q", test.name, got, test.xff)
+		}
+		if got, want := out.Header.Get("X-Forwarded-Host"), "example.com"; got != want {
+			t.Errorf("%v: X-Forwarded-Host = %q, want %q", test.name, got, want)
+		}
+		if got := out.Header.Get("X-Forwarded-Proto"); got != test.xproto {
+			t.Errorf("%v: X-Forwarded-Proto = %q, want %q", test.name, got, test.xproto)
+		}
+	}
+}
+
+func TestSetForwarded(t *testing.T) {
+	for _, test := range []struct {
+		remoteAddr string
+		host       string
+		prior      []string
+		want       string
+	}{
+		{"192.0.2.1:1234", "example.com", nil, `for=192.0.2.1;host=example.com;proto=http`},
+		{"[2001:db8::1]:1234", "example.com:8080", nil, `for="[2001:db8::1]";host="example.com:8080";proto=http`},
+		{"192.0.2.1:1234", "example.com", []string{"for=198.51.100.1"}, `for=198.51.100.1, for=192.0.2.1;host=example.com;proto=http`},
+		{"unknown", `bad"host`, nil, `host="bad\"host";proto=http`},
+	} {
+		in := httptest.NewRequest("GET", "http://example.com/", nil)
+		in.RemoteAddr = test.remoteAddr
+		in.Host = test.host
+		out := in.Clone(context.Background())
+		out.Header["Forwarded"] = test.prior
+		pr := &ProxyRequest{In: in, Out: out}
+		pr.SetForwarded()
+		if got := out.Header.Get("Forwarded"); got != test.want {
+			t.Errorf("SetForwarded for %v, %q: Forwarded = %q, want %q", test.remoteAddr, test.host, got, test.want)
+		}
+	}
+}
+
+func Test1xxResponses(t *testing.T)      { test1xxResponses(t, false) }
+func Test1xxResponsesHTTP2(t *testing.T) { test1xxResponses(t, true) }
+
+func test1xxResponses(t *testing.T, h2 bool) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Add("Link", "</style.css>; rel=preload; as=style")
+		h.Add("Link", "</script.js>; rel=preload; as=script")
+		w.WriteHeader(http.StatusEarlyHints)
+
+		h.Add("Link", "</foo.js>; rel=preload; as=script")
+		w.WriteHeader(http.StatusProcessing)
+
+		w.Write([]byte("Hello"))
+	}))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHandler := NewSingleHostReverseProxy(backendURL)
+	proxyHandler.ErrorLog = log.New(io.Discard, "", 0) // quiet for tests
+	frontend := httptest.NewUnstartedServer(proxyHandler)
+	if h2 {
+		frontend.EnableHTTP2 = true
+		frontend.StartTLS()
+	} else {
+		frontend.Start()
+	}
+	defer frontend.Close()
+	frontendClient := frontend.Client()
+
+	checkLinkHeaders := func(t *testing.T, expected, got []string) {
+		t.Helper()
+
+		if len(expected) != len(got) {
+			t.Errorf("Expected %d link headers; got %d", len(expected), len(got))
+		}
+
+		for i := range expected {
+			if i >= len(got) {
+				t.Errorf("Expected %q link header; got nothing", expected[i])
+
+				continue
+			}
+
+			if expected[i] != got[i] {
+				t.Errorf("Expected %q link header; got %q", expected[i], got[i])
+			}
+		}
+	}
+
+	var respCounter uint8
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			switch code {
+			case http.StatusEarlyHints:
+				checkLinkHeaders(t, []string{"</style.css>; rel=preload; as=style", "</script.js>; rel=preload; as=script"}, header["Link"])
+			case http.StatusProcessing:
+				checkLinkHeaders(t, []string{"</style.css>; rel=preload; as=style", "</script.js>; rel=preload; as=script", "</foo.js>; rel=preload; as=script"}, header["Link"])
+			default:
+				t.Error("Unexpected 1xx response")
+			}
+
+			respCounter++
+			return nil
+		},
+	}
+	req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), "GET", frontend.URL, nil)
+
+	res, err := frontendClient.Do(req)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	defer res.Body.Close()
+
+	if h2 && res.ProtoMajor != 2 {
+		t.Fatalf("Proto = %q; want HTTP/2.0", res.Proto)
+	}
+	if respCounter != 2 {
+		t.Errorf("Expected 2 1xx responses; got %d", respCounter)
+	}
+	checkLinkHeaders(t, []string{"</style.css>; rel=preload; as=style", "</script.js>; rel=preload; as=script", "</foo.js>; rel=preload; as=script"}, res.Header["Link"])
+
+	body, _ := io.ReadAll(res.Body)
+	if string(body) != "Hello" {
+		t.Errorf("Read body %q; want Hello", body)
+	}
+}
+
+func TestReverseProxyEarlyHintsHTTP2(t *testing.T) {
+	const link = "</style.css>; rel=preload; as=style"
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", link)
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Write([]byte("body"))
+	}))
+	backend.EnableHTTP2 = true
+	backend.StartTLS()
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHandler := &ReverseProxy{
+		Rewrite: func(r *ProxyRequest) {
+			r.SetURL(backendURL)
+		},
+		Transport: backend.Client().Transport,
+		ErrorLog:  log.New(io.Discard, "", 0), // quiet for tests
+	}
+	frontend := httptest.NewUnstartedServer(proxyHandler)
+	frontend.EnableHTTP2 = true
+	frontend.StartTLS()
+	defer frontend.Close()
+
+	var got []int
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			got = append(got, code)
+			if h := header.Get("Link"); h != link {
+				t.Errorf("%d response Link header = %q, want %q", code, h, link)
+			}
+			return nil
+		},
+	}
+	req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), "GET", frontend.URL, nil)
+	res, err := frontend.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer res.Body.Close()
+	if res.ProtoMajor != 2 {
+		t.Errorf("frontend response Proto = %v, want HTTP/2", res.Proto)
+	}
+	if len(got) != 1 || got[0] != http.StatusEarlyHints {
+		t.Errorf("1xx responses = %v, want [%d]", got, http.StatusEarlyHints)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil || string(body) != "body" {
+		t.Errorf("ReadAll = %q, %v; want %q, nil", body, err, "body")
+	}
+}
+
+func TestReverseProxyHTTP2Trailers(t *testing.T) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("backend got request with Proto %v, want HTTP/2", r.Proto)
+		}
+		if got, want := r.Header.Get("Te"), "trailers"; got != want {
+			t.Errorf("backend got Te header %q, want %q", got, want)
+		}
+		w.Header().Set("Trailer", "X-Announced-Trailer")
+		w.Write([]byte("body"))
+		w.Header().Set("X-Announced-Trailer", "announced")
+		w.Header().Set(http.TrailerPrefix+"X-Unannounced-Trailer", "unannounced")
+	}))
+	backend.EnableHTTP2 = true
+	backend.StartTLS()
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHandler := &ReverseProxy{
+		Rewrite: func(r *ProxyRequest) {
+			r.SetURL(backendURL)
+		},
+		Transport: backend.Client().Transport,
+		ErrorLog:  log.New(io.Discard, "", 0), // quiet for tests
+	}
+	frontend := httptest.NewUnstartedServer(proxyHandler)
+	frontend.EnableHTTP2 = true
+	frontend.StartTLS()
+	defer frontend.Close()
+
+	req, _ := http.NewRequest("GET", frontend.URL, nil)
+	req.Header.Set("Te", "trailers")
+	res, err := frontend.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer res.Body.Close()
+	if res.ProtoMajor != 2 {
+		t.Errorf("frontend response Proto = %v, want HTTP/2", res.Proto)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil || string(body) != "body" {
+		t.Errorf("ReadAll = %q, %v; want %q, nil", body, err, "body")
+	}
+	if got, want := res.Trailer.Get("X-Announced-Trailer"), "announced"; got != want {
+		t.Errorf("Trailer(X-Announced-Trailer) = %q, want %q", got, want)
+	}
+	if got, want := res.Trailer.Get("X-Unannounced-Trailer"), "unannounced"; got != want {
+		t.Errorf("Trailer(X-Unannounced-Trailer) = %q, want %q", got, want)
+	}
+}
+
+func TestReverseProxyRequestTrailers(t *testing.T) {
+	for _, h2 := range []bool{false, true} {
+		t.Run(fmt.Sprintf("h2=%v", h2), func(t *testing.T) {
+			testReverseProxyRequestTrailers(t, h2)
+		})
+	}
+}
+
+func testReverseProxyRequestTrailers(t *testing.T, h2 bool) {
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h2 && r.ProtoMajor != 2 {
+			t.Errorf("backend got request with Proto %v, want HTTP/2", r.Proto)
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil || string(body) != "body" {
+			t.Errorf("backend ReadAll = %q, %v; want %q, nil", body, err, "body")
+		}
+		if got, want := r.Trailer.Get("X-Request-Trailer"), "trailer_value"; got != want {
+			t.Errorf("backend got Trailer(X-Request-Trailer) = %q, want %q", got, want)
+		}
+	}))
+	backend.EnableHTTP2 = h2
+	backend.StartTLS()
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHandler := &ReverseProxy{
+		Rewrite: func(r *ProxyRequest) {
+			r.SetURL(backendURL)
+		},
+		Transport: backend.Client().Transport,
+		ErrorLog:  log.New(io.Discard, "", 0), // quiet for tests
+	}
+	frontend := httptest.NewUnstartedServer(proxyHandler)
+	frontend.EnableHTTP2 = h2
+	frontend.StartTLS()
+	defer frontend.Close()
+
+	pr, pw := io.Pipe()
+	req, _ := http.NewRequest("POST", frontend.URL, pr)
+	req.Trailer = http.Header{"X-Request-Trailer": nil}
+	go func() {
+		pw.Write([]byte("body"))
+		// The trailer value is set only after the body has been written,
+		// as a streaming client would.
+		req.Trailer.Set("X-Request-Trailer", "trailer_value")
+		pw.Close()
+	}()
+	res, err := frontend.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %v, want %v", res.StatusCode, http.StatusOK)
+	}
+}
@@ -63,6 +63,8 @@ func http2NewPriorityWriteScheduler(interface{}) http2WriteScheduler { panic(noH
 
 func http2ConfigureServer(s *Server, conf *http2Server) error { panic(noHTTP2) }
 
+func wrapH2ResponseWriter(rw ResponseWriter) ResponseWriter { return rw }
+
 var http2ErrNoCachedConn = http2noCachedConnError{}
 
 type http2noCachedConnError struct{}
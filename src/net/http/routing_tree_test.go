@@ -0,0 +1,210 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRoutingFirstSegment(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []string
+	}{
+		{"/a/b/c", []string{"a", "b", "c"}},
+		{"/a/b/", []string{"a", "b", "/"}},
+		{"/", []string{"/"}},
+		{"/a/%62/c", []string{"a", "b", "c"}},
+		{"/a%2Fb%2fc", []string{"a/b/c"}},
+	} {
+		var got []string
+		rest := test.in
+		for len(rest) > 0 {
+			var seg string
+			seg, rest = firstSegment(rest)
+			got = append(got, seg)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.in, got, test.want)
+		}
+	}
+}
+
+func getTestTree() *routingNode {
+	var root routingNode
+	var pats []*pattern
+	for _, p := range []string{
+		"/a",
+		"/a/b",
+		"/a/{x}",
+		"/g/h/i",
+		"/g/{x}/j",
+		"/a/b/{x...}",
+		"/a/b/{y}",
+		"/a/b/{$}",
+		"GET /a",
+		"POST /a",
+		"HEAD /g",
+		"/foo/{x}",
+		"GET example.com/{$}",
+		"example.com/a",
+		"example.com/a/b",
+		"example.com/a/{x}",
+		"GET example.com/a/c",
+		"example.com/x/{y...}",
+	} {
+		pat, err := parsePattern(p)
+		if err != nil {
+			panic(err)
+		}
+		for _, p2 := range pats {
+			if pat.conflictsWith(p2) {
+				panic(describeConflict(pat, p2))
+			}
+		}
+		pats = append(pats, pat)
+		root.addPattern(pat, nil)
+	}
+	return &root
+}
+
+type testCase struct {
+	method, host, path string
+	wantPat            string // "" for nil (no match)
+	wantMatches        []string
+}
+
+func TestRoutingNodeMatch(t *testing.T) {
+	test := func(tree *routingNode, tests []testCase) {
+		t.Helper()
+		for _, test := range tests {
+			gotNode, gotMatches := tree.match(test.host, test.method, test.path)
+			got := ""
+			if gotNode != nil {
+				got = gotNode.pattern.String()
+			}
+			if got != test.wantPat {
+				t.Errorf("%s, %s, %s: got %q, want %q", test.host, test.method, test.path, got, test.wantPat)
+			}
+			if !reflect.DeepEqual(gotMatches, test.wantMatches) {
+				t.Errorf("%s, %s, %s: got matches %v, want %v", test.host, test.method, test.path, gotMatches, test.wantMatches)
+			}
+		}
+	}
+
+	test(getTestTree(), []testCase{
+		{"GET", "", "/a", "GET /a", nil},
+		{"Get", "", "/a", "/a", nil},
+		{"HEAD", "", "/a", "GET /a", nil},
+		{"POST", "", "/a", "POST /a", nil},
+		{"DELETE", "", "/a", "/a", nil},
+		{"GET", "", "/a/b", "/a/b", nil},
+		{"GET", "", "/a/c", "/a/{x}", []string{"c"}},
+		{"GET", "", "/a/b/", "/a/b/{$}", nil},
+		{"GET", "", "/a/b/c", "/a/b/{y}", []string{"c"}},
+		{"GET", "", "/a/b/c/d", "/a/b/{x...}", []string{"c/d"}},
+		{"GET", "", "/g/h/i", "/g/h/i", nil},
+		{"GET", "", "/g/h/j", "/g/{x}/j", []string{"h"}},
+		{"GET", "", "/foo/%2fbar", "/foo/{x}", []string{"/bar"}},
+		{"GET", "", "/foo/a%2Fb", "/foo/{x}", []string{"a/b"}},
+
+		{"GET", "example.com", "/a", "example.com/a", nil},
+		{"GET", "example.com", "/a/b", "example.com/a/b", nil},
+		{"GET", "example.com", "/a/c", "GET example.com/a/c", nil},
+		{"POST", "example.com", "/a/c", "example.com/a/{x}", []string{"c"}},
+		{"GET", "example.com", "/x/y/z", "example.com/x/{y...}", []string{"y/z"}},
+		{"GET", "example.com", "/", "GET example.com/{$}", nil},
+		{"GET", "example.org", "/a/b", "/a/b", nil},
+		// A host pattern that does not match falls back to patterns without a host.
+		{"GET", "example.com", "/g/h/i", "/g/h/i", nil},
+
+		{"GET", "", "/b", "", nil},
+		{"GET", "example.com", "/b", "", nil},
+		{"POST", "", "/g", "", nil},
+	})
+
+	// A single wildcard does not match a trailing slash.
+	var tree routingNode
+	for _, p := range []string{"/{x}", "/{x}/{$}"} {
+		tree.addPattern(mustParsePattern(t, p), nil)
+	}
+	test(&tree, []testCase{
+		{"GET", "", "/a", "/{x}", []string{"a"}},
+		{"GET", "", "/a/", "/{x}/{$}", []string{"a"}},
+		{"GET", "", "/", "", nil},
+	})
+}
+
+func TestMatchingMethods(t *testing.T) {
+	hostTree := &routingNode{}
+	for _, p := range []string{
+		"GET a.com/",
+		"PUT b.com/",
+		"POST /foo/{x}",
+	} {
+		hostTree.addPattern(mustParsePattern(t, p), nil)
+	}
+
+	for _, test := range []struct {
+		name       string
+		tree       *routingNode
+		host, path string
+		want       string
+	}{
+		{
+			"post",
+			getTestTree(),
+			"", "/a",
+			"GET,HEAD,POST",
+		},
+		{
+			"host only GET",
+			getTestTree(),
+			"example.com", "/a/c",
+			"GET,HEAD",
+		},
+		{
+			"HEAD only",
+			getTestTree(),
+			"", "/g",
+			"HEAD",
+		},
+		{
+			"host",
+			hostTree,
+			"a.com", "/",
+			"GET,HEAD",
+		},
+		{
+			"host and wildcard",
+			hostTree,
+			"", "/foo/bar",
+			"POST",
+		},
+		{
+			"no host",
+			hostTree,
+			"", "/",
+			"",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ms := map[string]bool{}
+			test.tree.matchingMethods(test.host, test.path, ms)
+			var keys []string
+			for k := range ms {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			got := strings.Join(keys, ",")
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -610,6 +610,98 @@ func TestShouldRedirectConcurrency(t *testing.T) {
 	mux.HandleFunc("/", func(w ResponseWriter, r *Request) {})
 }
 
+func TestServeMuxPatterns(t *testing.T) {
+	mux := NewServeMux()
+	handle := func(pattern string) {
+		mux.HandleFunc(pattern, func(w ResponseWriter, r *Request) {
+			fmt.Fprintf(w, "%s|%s|%s", pattern, r.PathValue("id"), r.PathValue("path"))
+		})
+	}
+	handle("GET /items/{id}")
+	handle("DELETE /items/{id}")
+	handle("GET /items/new")
+	handle("/files/{path...}")
+	handle("GET /{$}")
+	handle("example.com/items/{id}")
+
+	for _, test := range []struct {
+		method, host, path string
+		wantCode           int
+		wantBody           string
+		wantAllow          string
+	}{
+		{"GET", "", "/items/12", 200, "GET /items/{id}|12|", ""},
+		{"HEAD", "", "/items/12", 200, "", ""},
+		{"DELETE", "", "/items/12", 200, "DELETE /items/{id}|12|", ""},
+		{"GET", "", "/items/new", 200, "GET /items/new||", ""},
+		{"GET", "", "/items/a%2Fb", 200, "GET /items/{id}|a/b|", ""},
+		{"POST", "", "/files/a/b/c.txt", 200, "/files/{path...}||a/b/c.txt", ""},
+		{"GET", "", "/", 200, "GET /{$}||", ""},
+		{"GET", "example.com", "/items/3", 200, "example.com/items/{id}|3|", ""},
+		{"PUT", "", "/items/12", 405, "Method Not Allowed\n", "DELETE, GET, HEAD"},
+		{"POST", "", "/", 405, "Method Not Allowed\n", "GET, HEAD"},
+		{"GET", "", "/other", 404, "404 page not found\n", ""},
+		{"GET", "", "/files", 301, "", ""},
+	} {
+		r := &Request{
+			Method: test.method,
+			Host:   test.host,
+			URL:    &url.URL{Path: test.path},
+		}
+		if u, err := url.Parse(test.path); err == nil {
+			r.URL = u
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, r)
+		if rr.Code != test.wantCode {
+			t.Errorf("%s %s%s: got code %d, want %d", test.method, test.host, test.path, rr.Code, test.wantCode)
+			continue
+		}
+		if test.method != "HEAD" && test.wantCode != 301 {
+			if got := rr.Body.String(); got != test.wantBody {
+				t.Errorf("%s %s%s: got body %q, want %q", test.method, test.host, test.path, got, test.wantBody)
+			}
+		}
+		if got := rr.Header().Get("Allow"); got != test.wantAllow {
+			t.Errorf("%s %s%s: got Allow %q, want %q", test.method, test.host, test.path, got, test.wantAllow)
+		}
+	}
+}
+
+func TestServeMuxRegisterPanics(t *testing.T) {
+	h := HandlerFunc(func(ResponseWriter, *Request) {})
+	for _, test := range []struct {
+		registered []string
+		pattern    string
+		want       string
+	}{
+		{nil, "", "invalid pattern"},
+		{nil, "/{x", "bad wildcard segment"},
+		{nil, "A=B /", "invalid method"},
+		{[]string{"/a"}, "/a", "matches the same requests as"},
+		{[]string{"/a/{x}"}, "/{y}/b", "both match some paths"},
+		{[]string{"GET /"}, "/index.html", "matches more methods than"},
+	} {
+		func() {
+			mux := NewServeMux()
+			for _, p := range test.registered {
+				mux.Handle(p, h)
+			}
+			defer func() {
+				r := recover()
+				if r == nil {
+					t.Errorf("%q after %q: no panic", test.pattern, test.registered)
+					return
+				}
+				if got := fmt.Sprint(r); !strings.Contains(got, test.want) {
+					t.Errorf("%q after %q: got panic %q, want it to contain %q", test.pattern, test.registered, got, test.want)
+				}
+			}()
+			mux.Handle(test.pattern, h)
+		}()
+	}
+}
+
 func BenchmarkServeMux(b *testing.B)           { benchmarkServeMux(b, true) }
 func BenchmarkServeMux_SkipServe(b *testing.B) { benchmarkServeMux(b, false) }
 func benchmarkServeMux(b *testing.B, runHandler bool) {
@@ -971,6 +1063,48 @@ func TestOnlyWriteTimeout(t *testing.T) {
 	}
 }
 
+// A write deadline set by one handler through ResponseController
+// must not carry over to the next request on a keep-alive connection
+// when Server.WriteTimeout is zero.
+func TestResponseControllerWriteDeadlineResetBetweenRequests(t *testing.T) {
+	setParallel(t)
+	defer afterTest(t)
+	ts := httptest.NewServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		if r.URL.Path == "/short" {
+			if err := NewResponseController(w).SetWriteDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+				t.Errorf("SetWriteDeadline = %v", err)
+			}
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	do := func(req string) {
+		t.Helper()
+		if _, err := io.WriteString(conn, req); err != nil {
+			t.Fatal(err)
+		}
+		res, err := ReadResponse(br, nil)
+		if err != nil {
+			t.Fatalf("ReadResponse: %v", err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil || string(body) != "ok" {
+			t.Fatalf("body = %q, %v; want %q, nil", body, err, "ok")
+		}
+	}
+	do("GET /short HTTP/1.1\r\nHost: foo\r\n\r\n")
+	time.Sleep(100 * time.Millisecond) // let the handler's deadline pass
+	do("POST /long HTTP/1.1\r\nHost: foo\r\nContent-Length: 0\r\n\r\n")
+}
+
 // trackLastConnListener tracks the last net.Conn that was accepted.
 type trackLastConnListener struct {
 	net.Listener
@@ -3816,7 +3950,7 @@ func testServerReaderFromOrder(t *testing.T, h2 bool) {
 
 // Issue 6157, Issue 6685
 func TestCodesPreventingContentTypeAndBody(t *testing.T) {
-	for _, code := range []int{StatusNotModified, StatusNoContent, StatusContinue} {
+	for _, code := range []int{StatusNotModified, StatusNoContent} {
 		ht := newHandlerTest(HandlerFunc(func(w ResponseWriter, r *Request) {
 			if r.URL.Path == "/header" {
 				w.Header().Set("Content-Length", "123")
@@ -3847,6 +3981,34 @@ func TestCodesPreventingContentTypeAndBody(t *testing.T) {
 	}
 }
 
+func TestWriteHeaderInformational(t *testing.T) {
+	ht := newHandlerTest(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.Header().Set("Content-Length", "123") // not sent with 1xx
+		w.WriteHeader(StatusEarlyHints)
+		w.Header().Add("Link", "</script.js>; rel=preload; as=script")
+		w.WriteHeader(StatusEarlyHints)
+		w.Header().Del("Content-Length")
+		w.WriteHeader(StatusOK)
+		w.Write([]byte("stuff"))
+	}))
+	got := ht.rawResponse("GET / HTTP/1.1\nHost: foo")
+	want := "HTTP/1.1 103 Early Hints\r\n" +
+		"Link: </style.css>; rel=preload; as=style\r\n" +
+		"\r\n" +
+		"HTTP/1.1 103 Early Hints\r\n" +
+		"Link: </style.css>; rel=preload; as=style\r\n" +
+		"Link: </script.js>; rel=preload; as=script\r\n" +
+		"\r\n" +
+		"HTTP/1.1 200 OK\r\n"
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("response = %q; want prefix %q", got, want)
+	}
+	if !strings.HasSuffix(got, "\r\n\r\nstuff") {
+		t.Errorf("response = %q; want body %q", got, "stuff")
+	}
+}
+
 func TestContentTypeOkayOn204(t *testing.T) {
 	ht := newHandlerTest(HandlerFunc(func(w ResponseWriter, r *Request) {
 		w.Header().Set("Content-Length", "123") // suppressed
@@ -0,0 +1,273 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// A LatencyHistogram is a distribution of latencies.
+type LatencyHistogram struct {
+	// Counts contains the number of samples in each bucket.
+	// Counts[i] is the number of samples at least Buckets[i]
+	// and, unless i is the last bucket, less than Buckets[i+1].
+	Counts []uint64
+
+	// Buckets contains the inclusive lower bounds of the histogram
+	// buckets, in increasing order. The last bucket has no upper bound.
+	Buckets []time.Duration
+}
+
+// latencyBuckets are the bucket boundaries used by all latency histograms.
+var latencyBuckets = [...]time.Duration{
+	0,
+	1 * time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// latencyHistogram accumulates latency samples.
+// It is safe for concurrent use.
+type latencyHistogram struct {
+	counts [len(latencyBuckets)]uint64 // accessed atomically
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	i := sort.Search(len(latencyBuckets), func(i int) bool {
+		return latencyBuckets[i] > d
+	}) - 1
+	if i < 0 {
+		i = 0
+	}
+	atomic.AddUint64(&h.counts[i], 1)
+}
+
+func (h *latencyHistogram) snapshot() LatencyHistogram {
+	s := LatencyHistogram{
+		Counts:  make([]uint64, len(h.counts)),
+		Buckets: make([]time.Duration, len(latencyBuckets)),
+	}
+	for i := range h.counts {
+		s.Counts[i] = atomic.LoadUint64(&h.counts[i])
+	}
+	copy(s.Buckets, latencyBuckets[:])
+	return s
+}
+
+// ServerStats contains server statistics.
+//
+// ServerStats can be published with package expvar, for example:
+//
+//	expvar.Publish("httpserver", expvar.Func(func() interface{} { return srv.Stats() }))
+type ServerStats struct {
+	// The number of open HTTP/1 and HTTP/2 connections by state,
+	// as reported to Server.ConnState. HTTP/2 connections are
+	// reported as active for as long as they are open.
+	NewConns    int
+	ActiveConns int
+	IdleConns   int
+
+	HTTP2Conns int // The number of open HTTP/2 connections.
+	HTTP3Conns int // The number of open HTTP/3 connections.
+
+	AcceptedConns int64 // The total number of connections accepted.
+	HijackedConns int64 // The total number of connections hijacked.
+
+	ActiveRequests int   // The number of requests being handled, over all protocols.
+	HTTP2Streams   int   // The number of HTTP/2 requests being handled.
+	TotalRequests  int64 // The total number of requests handled.
+
+	TLSHandshakes       int64            // The total number of TLS handshakes attempted.
+	TLSHandshakeErrors  int64            // The total number of failed TLS handshakes.
+	TLSHandshakeLatency LatencyHistogram // The duration of successful TLS handshakes.
+}
+
+// serverStats holds the counters behind Server.Stats.
+// It contains only 64-bit words so that all fields are aligned
+// for atomic access.
+type serverStats struct {
+	newConns            int64
+	activeConns         int64
+	idleConns           int64
+	accepted            int64
+	hijacked            int64
+	http2Conns          int64
+	http3Conns          int64
+	activeRequests      int64
+	http2Streams        int64
+	totalRequests       int64
+	tlsHandshakes       int64
+	tlsHandshakeErrors  int64
+	tlsHandshakeLatency latencyHistogram
+}
+
+// recordTLSHandshake records the outcome of a TLS handshake begun at start.
+func (st *serverStats) recordTLSHandshake(start time.Time, err error) {
+	atomic.AddInt64(&st.tlsHandshakes, 1)
+	if err != nil {
+		atomic.AddInt64(&st.tlsHandshakeErrors, 1)
+		return
+	}
+	st.tlsHandshakeLatency.record(time.Since(start))
+}
+
+// addStateConns adds delta to the number of connections in state.
+// Only the StateNew, StateActive and StateIdle counts are kept.
+func (st *serverStats) addStateConns(state ConnState, delta int64) {
+	switch state {
+	case StateNew:
+		atomic.AddInt64(&st.newConns, delta)
+	case StateActive:
+		atomic.AddInt64(&st.activeConns, delta)
+	case StateIdle:
+		atomic.AddInt64(&st.idleConns, delta)
+	}
+}
+
+func (srv *Server) getStats() *serverStats {
+	srv.statsOnce.Do(func() {
+		srv.stats = new(serverStats)
+	})
+	return srv.stats
+}
+
+// Stats returns server statistics.
+func (srv *Server) Stats() ServerStats {
+	st := srv.getStats()
+	return ServerStats{
+		NewConns:            int(atomic.LoadInt64(&st.newConns)),
+		ActiveConns:         int(atomic.LoadInt64(&st.activeConns)),
+		IdleConns:           int(atomic.LoadInt64(&st.idleConns)),
+		HTTP2Conns:          int(atomic.LoadInt64(&st.http2Conns)),
+		HTTP3Conns:          int(atomic.LoadInt64(&st.http3Conns)),
+		AcceptedConns:       atomic.LoadInt64(&st.accepted),
+		HijackedConns:       atomic.LoadInt64(&st.hijacked),
+		ActiveRequests:      int(atomic.LoadInt64(&st.activeRequests)),
+		HTTP2Streams:        int(atomic.LoadInt64(&st.http2Streams)),
+		TotalRequests:       atomic.LoadInt64(&st.totalRequests),
+		TLSHandshakes:       atomic.LoadInt64(&st.tlsHandshakes),
+		TLSHandshakeErrors:  atomic.LoadInt64(&st.tlsHandshakeErrors),
+		TLSHandshakeLatency: st.tlsHandshakeLatency.snapshot(),
+	}
+}
+
+// TransportStats contains transport statistics.
+//
+// TransportStats can be published with package expvar, for example:
+//
+//	expvar.Publish("httpclient", expvar.Func(func() interface{} { return tr.Stats() }))
+type TransportStats struct {
+	OpenConns int // The number of open HTTP/1 connections, including idle ones.
+	IdleConns int // The number of idle HTTP/1 connections.
+
+	// IdleConnsPerHost is the number of idle HTTP/1 connections
+	// by "host:port".
+	IdleConnsPerHost map[string]int
+
+	HTTP2Conns   int // The number of open HTTP/2 connections.
+	HTTP2Streams int // The number of active HTTP/2 streams.
+
+	ActiveRequests  int // The number of RoundTrip calls in progress.
+	WaitingRequests int // The number of requests waiting for a connection.
+
+	Dials       int64            // The total number of connections dialed.
+	DialErrors  int64            // The total number of failed dials.
+	DialLatency LatencyHistogram // The duration of successful dials.
+
+	TLSHandshakes       int64            // The total number of TLS handshakes attempted.
+	TLSHandshakeErrors  int64            // The total number of failed TLS handshakes.
+	TLSHandshakeLatency LatencyHistogram // The duration of successful TLS handshakes.
+}
+
+// transportStats holds the counters behind Transport.Stats.
+// It contains only 64-bit words so that all fields are aligned
+// for atomic access.
+type transportStats struct {
+	openConns           int64
+	activeRequests      int64
+	waitingRequests     int64
+	dials               int64
+	dialErrors          int64
+	dialLatency         latencyHistogram
+	tlsHandshakes       int64
+	tlsHandshakeErrors  int64
+	tlsHandshakeLatency latencyHistogram
+}
+
+// recordDial records the outcome of a dial begun at start.
+func (st *transportStats) recordDial(start time.Time, err error) {
+	atomic.AddInt64(&st.dials, 1)
+	if err != nil {
+		atomic.AddInt64(&st.dialErrors, 1)
+		return
+	}
+	st.dialLatency.record(time.Since(start))
+}
+
+// recordTLSHandshake records the outcome of a TLS handshake begun at start.
+func (st *transportStats) recordTLSHandshake(start time.Time, err error) {
+	atomic.AddInt64(&st.tlsHandshakes, 1)
+	if err != nil {
+		atomic.AddInt64(&st.tlsHandshakeErrors, 1)
+		return
+	}
+	st.tlsHandshakeLatency.record(time.Since(start))
+}
+
+func (t *Transport) getStats() *transportStats {
+	t.statsOnce.Do(func() {
+		t.stats = new(transportStats)
+	})
+	return t.stats
+}
+
+// Stats returns transport statistics.
+// HTTP/3 connections are not included.
+func (t *Transport) Stats() TransportStats {
+	t.nextProtoOnce.Do(t.onceSetNextProtoDefaults)
+	st := t.getStats()
+	s := TransportStats{
+		OpenConns:           int(atomic.LoadInt64(&st.openConns)),
+		IdleConnsPerHost:    make(map[string]int),
+		ActiveRequests:      int(atomic.LoadInt64(&st.activeRequests)),
+		WaitingRequests:     int(atomic.LoadInt64(&st.waitingRequests)),
+		Dials:               atomic.LoadInt64(&st.dials),
+		DialErrors:          atomic.LoadInt64(&st.dialErrors),
+		DialLatency:         st.dialLatency.snapshot(),
+		TLSHandshakes:       atomic.LoadInt64(&st.tlsHandshakes),
+		TLSHandshakeErrors:  atomic.LoadInt64(&st.tlsHandshakeErrors),
+		TLSHandshakeLatency: st.tlsHandshakeLatency.snapshot(),
+	}
+
+	t.idleMu.Lock()
+	for key, conns := range t.idleConn {
+		for _, pc := range conns {
+			// HTTP/2 connections are shared rather than idle,
+			// and are counted below.
+			if pc.alt == nil {
+				s.IdleConns++
+				s.IdleConnsPerHost[key.addr]++
+			}
+		}
+	}
+	t.idleMu.Unlock()
+
+	if h2, ok := t.h2transport.(interface{ connStats() (int, int) }); ok {
+		s.HTTP2Conns, s.HTTP2Streams = h2.connStats()
+	}
+	return s
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"encoding/json"
+	"io"
+	. "net/http"
+	"testing"
+	"time"
+)
+
+func TestStats_h1(t *testing.T) { testStats(t, h1Mode) }
+func TestStats_h2(t *testing.T) { testStats(t, h2Mode) }
+
+func testStats(t *testing.T, h2 bool) {
+	defer afterTest(t)
+	inHandler := make(chan bool)
+	release := make(chan bool)
+	cst := newClientServerTest(t, h2, HandlerFunc(func(w ResponseWriter, r *Request) {
+		inHandler <- true
+		<-release
+		io.WriteString(w, "hello")
+	}))
+	defer cst.close()
+	srv := cst.ts.Config
+
+	resc := make(chan error, 1)
+	go func() {
+		res, err := cst.c.Get(cst.ts.URL)
+		if err == nil {
+			_, err = io.ReadAll(res.Body)
+			res.Body.Close()
+		}
+		resc <- err
+	}()
+	<-inHandler
+
+	ss := srv.Stats()
+	if ss.ActiveRequests != 1 || ss.ActiveConns != 1 || ss.AcceptedConns != 1 {
+		t.Errorf("in handler: ActiveRequests, ActiveConns, AcceptedConns = %d, %d, %d; want 1, 1, 1",
+			ss.ActiveRequests, ss.ActiveConns, ss.AcceptedConns)
+	}
+	ts := cst.tr.Stats()
+	if ts.ActiveRequests != 1 || ts.Dials != 1 || ts.DialErrors != 0 {
+		t.Errorf("in handler: transport ActiveRequests, Dials, DialErrors = %d, %d, %d; want 1, 1, 0",
+			ts.ActiveRequests, ts.Dials, ts.DialErrors)
+	}
+	if h2 {
+		if ss.HTTP2Conns != 1 || ss.HTTP2Streams != 1 {
+			t.Errorf("in handler: HTTP2Conns, HTTP2Streams = %d, %d; want 1, 1", ss.HTTP2Conns, ss.HTTP2Streams)
+		}
+		if ts.HTTP2Conns != 1 || ts.HTTP2Streams != 1 {
+			t.Errorf("in handler: transport HTTP2Conns, HTTP2Streams = %d, %d; want 1, 1", ts.HTTP2Conns, ts.HTTP2Streams)
+		}
+		if ss.TLSHandshakes != 1 || ts.TLSHandshakes != 1 {
+			t.Errorf("in handler: TLSHandshakes = %d (server), %d (transport); want 1", ss.TLSHandshakes, ts.TLSHandshakes)
+		}
+		if got := sumCounts(ts.TLSHandshakeLatency); got != 1 {
+			t.Errorf("transport TLSHandshakeLatency has %d samples; want 1", got)
+		}
+	} else {
+		if ts.OpenConns != 1 || ts.IdleConns != 0 {
+			t.Errorf("in handler: transport OpenConns, IdleConns = %d, %d; want 1, 0", ts.OpenConns, ts.IdleConns)
+		}
+	}
+
+	close(release)
+	if err := <-resc; err != nil {
+		t.Fatal(err)
+	}
+
+	if !waitCondition(5*time.Second, 10*time.Millisecond, func() bool {
+		ss = srv.Stats()
+		ts = cst.tr.Stats()
+		if ss.ActiveRequests != 0 || ss.TotalRequests != 1 || ts.ActiveRequests != 0 {
+			return false
+		}
+		if h2 {
+			return ss.HTTP2Streams == 0 && ts.HTTP2Streams == 0
+		}
+		return ss.IdleConns == 1 && ts.IdleConns == 1 && ts.IdleConnsPerHost[cst.ts.Listener.Addr().String()] == 1
+	}) {
+		t.Errorf("after request: server stats = %+v; transport stats = %+v", ss, ts)
+	}
+	if got := sumCounts(ts.DialLatency); got != 1 {
+		t.Errorf("transport DialLatency has %d samples; want 1", got)
+	}
+
+	if _, err := json.Marshal(ss); err != nil {
+		t.Errorf("json.Marshal(ServerStats) = %v", err)
+	}
+	if _, err := json.Marshal(ts); err != nil {
+		t.Errorf("json.Marshal(TransportStats) = %v", err)
+	}
+}
+
+func sumCounts(h LatencyHistogram) uint64 {
+	var n uint64
+	for _, c := range h.Counts {
+		n += c
+	}
+	return n
+}
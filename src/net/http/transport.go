@@ -281,6 +281,21 @@ type Transport struct {
 	// To use a custom dialer or TLS config and still attempt HTTP/2
 	// upgrades, set this to true.
 	ForceAttemptHTTP2 bool
+
+	// EnableHTTP3 controls whether HTTP/3 is used for https requests
+	// to servers which advertise HTTP/3 support with an Alt-Svc header
+	// (RFC 7838). The first request to a server always uses HTTP/1 or
+	// HTTP/2; later requests use HTTP/3 once it has been advertised.
+	// If an HTTP/3 connection cannot be established, requests fall
+	// back to HTTP/1 or HTTP/2.
+	// HTTP/3 is never used for requests sent through a proxy.
+	EnableHTTP3 bool
+
+	h3mu        sync.Mutex
+	h3transport *http3Transport // created on first use of HTTP/3
+
+	statsOnce sync.Once
+	stats     *transportStats // see Stats
 }
 
 // A cancelKey is the key of the reqCanceler map.
@@ -326,6 +341,7 @@ func (t *Transport) Clone() *Transport {
 		GetProxyConnectHeader:  t.GetProxyConnectHeader,
 		MaxResponseHeaderBytes: t.MaxResponseHeaderBytes,
 		ForceAttemptHTTP2:      t.ForceAttemptHTTP2,
+		EnableHTTP3:            t.EnableHTTP3,
 		WriteBufferSize:        t.WriteBufferSize,
 		ReadBufferSize:         t.ReadBufferSize,
 	}
@@ -503,6 +519,9 @@ func (t *Transport) alternateRoundTripper(req *Request) RoundTripper {
 // roundTrip implements a RoundTripper over HTTP.
 func (t *Transport) roundTrip(req *Request) (*Response, error) {
 	t.nextProtoOnce.Do(t.onceSetNextProtoDefaults)
+	st := t.getStats()
+	atomic.AddInt64(&st.activeRequests, 1)
+	defer atomic.AddInt64(&st.activeRequests, -1)
 	ctx := req.Context()
 	trace := httptrace.ContextClientTrace(ctx)
 
@@ -574,6 +593,24 @@ func (t *Transport) roundTrip(req *Request) (*Response, error) {
 			return nil, err
 		}
 
+		if t.EnableHTTP3 {
+			resp, err := t.roundTripHTTP3(req, cm)
+			if err == nil {
+				resp.Request = origReq
+				return resp, nil
+			}
+			if err != errHTTP3Unavailable {
+				req.closeBody()
+				return nil, err
+			}
+			// Fall back to HTTP/1 or HTTP/2.
+			req, err = rewindBody(req)
+			if err != nil {
+				return nil, err
+			}
+			treq.Request = req
+		}
+
 		// Get the cached or newly-created connection to either the
 		// host (for http or https), the http proxy, or the http proxy
 		// pre-CONNECTed to https server. In any case, we'll be ready
@@ -594,6 +631,9 @@ func (t *Transport) roundTrip(req *Request) (*Response, error) {
 			resp, err = pconn.roundTrip(treq)
 		}
 		if err == nil {
+			if t.EnableHTTP3 {
+				t.recordAltSvc(cm, resp)
+			}
 			resp.Request = origReq
 			return resp, nil
 		}
@@ -773,6 +813,12 @@ func (t *Transport) CloseIdleConnections() {
 	if t2 := t.h2transport; t2 != nil {
 		t2.CloseIdleConnections()
 	}
+	t.h3mu.Lock()
+	t3 := t.h3transport
+	t.h3mu.Unlock()
+	if t3 != nil {
+		t3.closeIdleConnections()
+	}
 }
 
 // CancelRequest cancels an in-flight request by closing its connection.
@@ -1362,6 +1408,10 @@ func (t *Transport) getConn(treq *transportRequest, cm connectMethod) (pc *persi
 		return pc, nil
 	}
 
+	st := t.getStats()
+	atomic.AddInt64(&st.waitingRequests, 1)
+	defer atomic.AddInt64(&st.waitingRequests, -1)
+
 	cancelc := make(chan error, 1)
 	t.setReqCanceler(treq.cancelKey, func(err error) { cancelc <- err })
 
@@ -1527,6 +1577,7 @@ func (pconn *persistConn) addTLS(ctx context.Context, name string, trace *httptr
 			errc <- tlsHandshakeTimeoutError{}
 		})
 	}
+	start := time.Now()
 	go func() {
 		if trace != nil && trace.TLSHandshakeStart != nil {
 			trace.TLSHandshakeStart()
@@ -1537,7 +1588,9 @@ func (pconn *persistConn) addTLS(ctx context.Context, name string, trace *httptr
 		}
 		errc <- err
 	}()
-	if err := <-errc; err != nil {
+	err := <-errc
+	pconn.t.getStats().recordTLSHandshake(start, err)
+	if err != nil {
 		plainConn.Close()
 		if trace != nil && trace.TLSHandshakeDone != nil {
 			trace.TLSHandshakeDone(tls.ConnectionState{}, err)
@@ -1577,7 +1630,9 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 	}
 	if cm.scheme() == "https" && t.hasCustomTLSDialer() {
 		var err error
+		start := time.Now()
 		pconn.conn, err = t.customDialTLS(ctx, "tcp", cm.addr())
+		t.getStats().recordDial(start, err)
 		if err != nil {
 			return nil, wrapErr(err)
 		}
@@ -1587,7 +1642,10 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 			if trace != nil && trace.TLSHandshakeStart != nil {
 				trace.TLSHandshakeStart()
 			}
-			if err := tc.HandshakeContext(ctx); err != nil {
+			start := time.Now()
+			err := tc.HandshakeContext(ctx)
+			t.getStats().recordTLSHandshake(start, err)
+			if err != nil {
 				go pconn.conn.Close()
 				if trace != nil && trace.TLSHandshakeDone != nil {
 					trace.TLSHandshakeDone(tls.ConnectionState{}, err)
@@ -1601,7 +1659,9 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 			pconn.tlsState = &cs
 		}
 	} else {
+		start := time.Now()
 		conn, err := t.dial(ctx, "tcp", cm.addr())
+		t.getStats().recordDial(start, err)
 		if err != nil {
 			return nil, wrapErr(err)
 		}
@@ -1744,6 +1804,7 @@ func (t *Transport) dialConn(ctx context.Context, cm connectMethod) (pconn *pers
 	pconn.br = bufio.NewReaderSize(pconn, t.readBufferSize())
 	pconn.bw = bufio.NewWriterSize(persistConnWriter{pconn}, t.writeBufferSize())
 
+	atomic.AddInt64(&t.getStats().openConns, 1)
 	go pconn.readLoop()
 	go pconn.writeLoop()
 	return pconn, nil
@@ -2708,6 +2769,7 @@ func (pc *persistConn) closeLocked(err error) {
 				pc.conn.Close()
 			}
 			close(pc.closech)
+			atomic.AddInt64(&pc.t.getStats().openConns, -1)
 		}
 	}
 	pc.mutateHeaderFunc = nil
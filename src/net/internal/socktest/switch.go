@@ -63,10 +63,10 @@ func (c Cookie) Family() int { return int(c >> 48) }
 func (c Cookie) Type() int { return int(c << 16 >> 32) }
 
 // Protocol returns a protocol number.
-func (c Cookie) Protocol() int { return int(c & 0xff) }
+func (c Cookie) Protocol() int { return int(c & 0xffff) }
 
 func cookie(family, sotype, proto int) Cookie {
-	return Cookie(family)<<48 | Cookie(sotype)&0xffffffff<<16 | Cookie(proto)&0xff
+	return Cookie(family)<<48 | Cookie(sotype)&0xffffffff<<16 | Cookie(proto)&0xffff
 }
 
 // A Status represents the status of a socket.
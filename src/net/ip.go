@@ -15,6 +15,7 @@ package net
 import (
 	"internal/bytealg"
 	"internal/itoa"
+	"net/netip"
 )
 
 // IP address lengths (bytes).
@@ -556,6 +557,41 @@ func (n *IPNet) String() string {
 	return nn.String() + "/" + itoa.Uitoa(uint(l))
 }
 
+// Prefix returns n as a netip.Prefix, with the host bits of the
+// address masked off. It returns the zero Prefix if n is nil or
+// if n's mask is not in the canonical form.
+func (n *IPNet) Prefix() netip.Prefix {
+	if n == nil {
+		return netip.Prefix{}
+	}
+	nn, m := networkNumberAndMask(n)
+	if nn == nil || m == nil {
+		return netip.Prefix{}
+	}
+	l := simpleMaskLength(m)
+	if l == -1 {
+		return netip.Prefix{}
+	}
+	addr, ok := netip.AddrFromSlice(nn.Mask(m))
+	if !ok {
+		return netip.Prefix{}
+	}
+	return netip.PrefixFrom(addr, l)
+}
+
+// IPNetFromPrefix returns p as an IPNet, with the host bits of the
+// address masked off. It returns nil if p is not valid.
+func IPNetFromPrefix(p netip.Prefix) *IPNet {
+	if !p.IsValid() {
+		return nil
+	}
+	p = p.Masked()
+	return &IPNet{
+		IP:   p.Addr().AsSlice(),
+		Mask: CIDRMask(p.Bits(), p.Addr().BitLen()),
+	}
+}
+
 // Parse IPv4 address (d.d.d.d).
 func parseIPv4(s string) IP {
 	var p [IPv4len]byte
@@ -10,6 +10,7 @@ package net
 import (
 	"bytes"
 	"math/rand"
+	"net/netip"
 	"reflect"
 	"runtime"
 	"testing"
@@ -488,6 +489,41 @@ func TestNetworkNumberAndMask(t *testing.T) {
 	}
 }
 
+var ipNetPrefixTests = []struct {
+	in  *IPNet
+	out netip.Prefix
+}{
+	{&IPNet{IP: IPv4(192, 168, 1, 7), Mask: IPv4Mask(255, 255, 255, 0)}, netip.MustParsePrefix("192.168.1.0/24")},
+	{&IPNet{IP: IP{10, 0, 0, 1}, Mask: CIDRMask(8, 32)}, netip.MustParsePrefix("10.0.0.0/8")},
+	{&IPNet{IP: ParseIP("2001:db8::1"), Mask: CIDRMask(32, 128)}, netip.MustParsePrefix("2001:db8::/32")},
+	{&IPNet{IP: ParseIP("::1"), Mask: CIDRMask(128, 128)}, netip.MustParsePrefix("::1/128")},
+	{&IPNet{IP: IPv4(192, 168, 1, 7), Mask: IPv4Mask(255, 0, 255, 0)}, netip.Prefix{}},
+	{&IPNet{IP: ParseIP("2001:db8::1"), Mask: IPv4Mask(255, 255, 255, 0)}, netip.Prefix{}},
+	{nil, netip.Prefix{}},
+}
+
+func TestIPNetPrefix(t *testing.T) {
+	for _, tt := range ipNetPrefixTests {
+		out := tt.in.Prefix()
+		if out != tt.out {
+			t.Errorf("(%v).Prefix() = %v, want %v", tt.in, out, tt.out)
+		}
+		if !tt.out.IsValid() {
+			continue
+		}
+		back := IPNetFromPrefix(out)
+		if back.String() != out.String() {
+			t.Errorf("IPNetFromPrefix(%v) = %v, want %v", out, back, out)
+		}
+		if !back.Contains(tt.in.IP) {
+			t.Errorf("IPNetFromPrefix(%v) = %v does not contain %v", out, back, tt.in.IP)
+		}
+	}
+	if n := IPNetFromPrefix(netip.Prefix{}); n != nil {
+		t.Errorf("IPNetFromPrefix(netip.Prefix{}) = %v, want nil", n)
+	}
+}
+
 func TestSplitHostPort(t *testing.T) {
 	for _, tt := range []struct {
 		hostPort string
@@ -6,8 +6,10 @@ package net
 
 import (
 	"context"
+	"errors"
 	"internal/nettrace"
 	"internal/singleflight"
+	"runtime"
 	"sync"
 )
 
@@ -120,6 +122,7 @@ type Resolver struct {
 	// PreferGo controls whether Go's built-in DNS resolver is preferred
 	// on platforms where it's available. It is equivalent to setting
 	// GODEBUG=netdns=go, but scoped to just this resolver.
+	// Setting DialTLS or ExchangeHTTPS implies PreferGo.
 	PreferGo bool
 
 	// StrictErrors controls the behavior of temporary errors
@@ -145,16 +148,84 @@ type Resolver struct {
 	// If nil, the default dialer is used.
 	Dial func(ctx context.Context, network, address string) (Conn, error)
 
+	// DialTLS optionally specifies a function that Go's built-in
+	// DNS resolver uses to send queries using DNS over TLS, as
+	// described in RFC 7858. If set, the resolver connects to port
+	// 853 of the name servers it would otherwise query over UDP or
+	// TCP, and never sends unencrypted queries. Package net has no
+	// TLS client of its own, so GODEBUG=netdns=dot also uses
+	// DialTLS; under that setting, lookups by a Resolver without
+	// DialTLS fail. DialTLS is called with network "tcp" and an address
+	// whose host is the name server's literal IP address. It must
+	// return a connection on which the TLS handshake has completed,
+	// such as one returned by the DialContext method of
+	// crypto/tls.Dialer; DNS messages sent over it use the framing
+	// of RFC 7766, section 8. The resolver keeps a few idle
+	// connections to each server and reuses them for later queries.
+	//
+	// The system configuration gives only the name servers' IP
+	// addresses. To authenticate a server by name, as described in
+	// RFC 8310, DialTLS should set the ServerName of its tls.Config
+	// to the server's authentication domain name. Otherwise the
+	// server's certificate must contain its IP address as a subject
+	// alternative name.
+	//
+	// On systems where Go's built-in resolver is not used, such as
+	// Windows and Plan 9, lookups by a Resolver with DialTLS set
+	// fail with an error instead of sending unencrypted queries.
+	DialTLS func(ctx context.Context, network, address string) (Conn, error)
+
+	// ExchangeHTTPS optionally specifies a function that Go's
+	// built-in DNS resolver uses to send queries using DNS over
+	// HTTPS, as described in RFC 8484. If set, it takes precedence
+	// over DialTLS and the name servers in the system configuration:
+	// the resolver passes each query to ExchangeHTTPS as a DNS
+	// message in wire format and expects the response in the same
+	// form. The search list and ndots option of the system
+	// configuration still apply. The net/http package's DNSOverHTTPS
+	// function returns a suitable implementation.
+	//
+	// Setting this field is the only way to enable DNS over HTTPS.
+	// As with DialTLS, lookups fail on Windows and Plan 9, where the
+	// system resolver is always used.
+	ExchangeHTTPS func(ctx context.Context, query []byte) ([]byte, error)
+
 	// lookupGroup merges LookupIPAddr calls together for lookups for the same
 	// host. The lookupGroup key is the LookupIPAddr.host argument.
 	// The return values are ([]IPAddr, error).
 	lookupGroup singleflight.Group
 
+	// tlsConns holds idle DNS over TLS connections for reuse.
+	tlsConns dnsConnCache
+
 	// TODO(bradfitz): optional interface impl override hook
 	// TODO(bradfitz): Timeout time.Duration?
 }
 
-func (r *Resolver) preferGo() bool     { return r != nil && r.PreferGo }
+func (r *Resolver) preferGo() bool {
+	return r != nil && (r.PreferGo || r.DialTLS != nil || r.ExchangeHTTPS != nil)
+}
+
+var errNoEncryptedDNS = errors.New("DNS over TLS and DNS over HTTPS are not supported on " + runtime.GOOS)
+
+// checkEncryptedDNS returns an error for a lookup of name if r or
+// GODEBUG=netdns=dot asks for DNS over TLS or DNS over HTTPS on a
+// system where Go's built-in resolver is not used. Such lookups fail
+// rather than go through the system resolver unencrypted.
+func (r *Resolver) checkEncryptedDNS(name string) error {
+	if !testHookSystemResolverOnly {
+		return nil
+	}
+	encrypted := r != nil && (r.DialTLS != nil || r.ExchangeHTTPS != nil)
+	if dnsMode, _ := goDebugNetDNS(); dnsMode == "dot" {
+		encrypted = true
+	}
+	if !encrypted {
+		return nil
+	}
+	return &DNSError{Err: errNoEncryptedDNS.Error(), Name: name}
+}
+
 func (r *Resolver) strictErrors() bool { return r != nil && r.StrictErrors }
 
 func (r *Resolver) getLookupGroup() *singleflight.Group {
@@ -164,6 +235,13 @@ func (r *Resolver) getLookupGroup() *singleflight.Group {
 	return &r.lookupGroup
 }
 
+func (r *Resolver) getTLSConns() *dnsConnCache {
+	if r == nil {
+		return &DefaultResolver.tlsConns
+	}
+	return &r.tlsConns
+}
+
 // LookupHost looks up the given host using the local resolver.
 // It returns a slice of that host's addresses.
 //
@@ -184,6 +262,9 @@ func (r *Resolver) LookupHost(ctx context.Context, host string) (addrs []string,
 	if ip, _ := parseIPZone(host); ip != nil {
 		return []string{host}, nil
 	}
+	if err := r.checkEncryptedDNS(host); err != nil {
+		return nil, err
+	}
 	return r.lookupHost(ctx, host)
 }
 
@@ -270,6 +351,9 @@ func (r *Resolver) lookupIPAddr(ctx context.Context, network, host string) ([]IP
 	if ip, zone := parseIPZone(host); ip != nil {
 		return []IPAddr{{IP: ip, Zone: zone}}, nil
 	}
+	if err := r.checkEncryptedDNS(host); err != nil {
+		return nil, err
+	}
 	trace, _ := ctx.Value(nettrace.TraceKey{}).(*nettrace.Trace)
 	if trace != nil && trace.DNSStart != nil {
 		trace.DNSStart(host)
@@ -419,6 +503,9 @@ func LookupCNAME(host string) (cname string, err error) {
 // The returned canonical name is validated to be a properly
 // formatted presentation-format domain name.
 func (r *Resolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	if err := r.checkEncryptedDNS(host); err != nil {
+		return "", err
+	}
 	cname, err := r.lookupCNAME(ctx, host)
 	if err != nil {
 		return "", err
@@ -462,6 +549,9 @@ func LookupSRV(service, proto, name string) (cname string, addrs []*SRV, err err
 // invalid names, those records are filtered out and an error
 // will be returned alongside the the remaining results, if any.
 func (r *Resolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*SRV, error) {
+	if err := r.checkEncryptedDNS(name); err != nil {
+		return "", nil, err
+	}
 	cname, addrs, err := r.lookupSRV(ctx, service, proto, name)
 	if err != nil {
 		return "", nil, err
@@ -505,6 +595,9 @@ func LookupMX(name string) ([]*MX, error) {
 // invalid names, those records are filtered out and an error
 // will be returned alongside the the remaining results, if any.
 func (r *Resolver) LookupMX(ctx context.Context, name string) ([]*MX, error) {
+	if err := r.checkEncryptedDNS(name); err != nil {
+		return nil, err
+	}
 	records, err := r.lookupMX(ctx, name)
 	if err != nil {
 		return nil, err
@@ -547,6 +640,9 @@ func LookupNS(name string) ([]*NS, error) {
 // invalid names, those records are filtered out and an error
 // will be returned alongside the the remaining results, if any.
 func (r *Resolver) LookupNS(ctx context.Context, name string) ([]*NS, error) {
+	if err := r.checkEncryptedDNS(name); err != nil {
+		return nil, err
+	}
 	records, err := r.lookupNS(ctx, name)
 	if err != nil {
 		return nil, err
@@ -572,11 +668,14 @@ func (r *Resolver) LookupNS(ctx context.Context, name string) ([]*NS, error) {
 // LookupTXT uses context.Background internally; to specify the context, use
 // Resolver.LookupTXT.
 func LookupTXT(name string) ([]string, error) {
-	return DefaultResolver.lookupTXT(context.Background(), name)
+	return DefaultResolver.LookupTXT(context.Background(), name)
 }
 
 // LookupTXT returns the DNS TXT records for the given domain name.
 func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if err := r.checkEncryptedDNS(name); err != nil {
+		return nil, err
+	}
 	return r.lookupTXT(ctx, name)
 }
 
@@ -603,6 +702,9 @@ func LookupAddr(addr string) (names []string, err error) {
 // domain names. If the response contains invalid names, those records are filtered
 // out and an error will be returned alongside the the remaining results, if any.
 func (r *Resolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	if err := r.checkEncryptedDNS(addr); err != nil {
+		return nil, err
+	}
 	names, err := r.lookupAddr(ctx, addr)
 	if err != nil {
 		return nil, err
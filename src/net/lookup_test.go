@@ -10,6 +10,7 @@ package net
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"internal/testenv"
 	"reflect"
@@ -922,6 +923,60 @@ func TestNilResolverLookup(t *testing.T) {
 	r.LookupTXT(ctx, "gmail.com")
 }
 
+// Lookups that ask for DNS over TLS or HTTPS must fail, rather than
+// go out unencrypted, on systems where Go's resolver is not used.
+func TestLookupEncryptedDNSWithSystemResolver(t *testing.T) {
+	defer func(orig bool) { testHookSystemResolverOnly = orig }(testHookSystemResolverOnly)
+	testHookSystemResolverOnly = true
+
+	resolvers := map[string]*Resolver{
+		"DialTLS": {
+			DialTLS: func(_ context.Context, n, s string) (Conn, error) {
+				t.Errorf("DialTLS called with %s %s", n, s)
+				return nil, errors.New("unexpected DialTLS")
+			},
+		},
+		"ExchangeHTTPS": {
+			ExchangeHTTPS: func(context.Context, []byte) ([]byte, error) {
+				t.Error("ExchangeHTTPS called")
+				return nil, errors.New("unexpected ExchangeHTTPS")
+			},
+		},
+	}
+	for name, r := range resolvers {
+		ctx := context.Background()
+		var errs []error
+		_, err := r.LookupAddr(ctx, "192.0.2.1")
+		errs = append(errs, err)
+		_, err = r.LookupCNAME(ctx, "example.com")
+		errs = append(errs, err)
+		_, err = r.LookupHost(ctx, "example.com")
+		errs = append(errs, err)
+		_, err = r.LookupIPAddr(ctx, "example.com")
+		errs = append(errs, err)
+		_, err = r.LookupIP(ctx, "ip4", "example.com")
+		errs = append(errs, err)
+		_, err = r.LookupMX(ctx, "example.com")
+		errs = append(errs, err)
+		_, err = r.LookupNS(ctx, "example.com")
+		errs = append(errs, err)
+		_, _, err = r.LookupSRV(ctx, "service", "proto", "example.com")
+		errs = append(errs, err)
+		_, err = r.LookupTXT(ctx, "example.com")
+		errs = append(errs, err)
+		d := Dialer{Resolver: r}
+		_, err = d.DialContext(ctx, "tcp", "example.com:80")
+		errs = append(errs, err)
+
+		for i, err := range errs {
+			var dnsErr *DNSError
+			if !errors.As(err, &dnsErr) || dnsErr.Err != errNoEncryptedDNS.Error() {
+				t.Errorf("%s: lookup %d: got %v; want %q", name, i, err, errNoEncryptedDNS)
+			}
+		}
+	}
+}
+
 // TestLookupHostCancel verifies that lookup works even after many
 // canceled lookups (see golang.org/issue/24178 for details).
 func TestLookupHostCancel(t *testing.T) {
@@ -76,6 +76,29 @@ func (r *Resolver) dial(ctx context.Context, network, server string) (Conn, erro
 	return c, nil
 }
 
+// dnsOverTLS reports whether Go's DNS resolver sends queries
+// using DNS over TLS.
+func (r *Resolver) dnsOverTLS() bool {
+	return r != nil && r.DialTLS != nil || systemConf().dnsOverTLS
+}
+
+// dialTLS connects to the DNS over TLS port of server using the
+// Resolver's DialTLS function.
+func (r *Resolver) dialTLS(ctx context.Context, server string) (Conn, error) {
+	if r == nil || r.DialTLS == nil {
+		return nil, errNoDNSOverTLS
+	}
+	host, _, err := SplitHostPort(server)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.DialTLS(ctx, "tcp", JoinHostPort(host, "853"))
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return c, nil
+}
+
 func (r *Resolver) lookupHost(ctx context.Context, host string) (addrs []string, err error) {
 	order := systemConf().hostLookupOrder(r, host)
 	if !r.preferGo() && order == hostLookupCgo {
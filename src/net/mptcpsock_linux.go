@@ -0,0 +1,97 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"errors"
+	"internal/poll"
+	"os"
+	"sync"
+	"syscall"
+)
+
+const (
+	_IPPROTO_MPTCP = 0x106
+	_SOL_MPTCP     = 0x11c
+	_MPTCP_INFO    = 0x1
+)
+
+var (
+	mptcpOnce      sync.Once
+	mptcpAvailable bool
+	hasSOLMPTCP    bool
+)
+
+// supportsMultipathTCP reports whether the kernel supports
+// Multipath TCP sockets.
+func supportsMultipathTCP() bool {
+	mptcpOnce.Do(initMPTCPAvailable)
+	return mptcpAvailable
+}
+
+func initMPTCPAvailable() {
+	s, err := sysSocket(syscall.AF_INET, syscall.SOCK_STREAM, _IPPROTO_MPTCP)
+	if err == nil {
+		poll.CloseFunc(s)
+		mptcpAvailable = true
+	}
+
+	// The SOL_MPTCP socket option level, used to detect fallbacks
+	// to plain TCP, was added in Linux 5.16.
+	major, minor := kernelVersion()
+	hasSOLMPTCP = major > 5 || major == 5 && minor >= 16
+}
+
+// isSocketError reports whether err was returned when creating the
+// socket rather than when connecting or listening with it.
+func isSocketError(err error) bool {
+	var se *os.SyscallError
+	return errors.As(err, &se) && se.Syscall == "socket"
+}
+
+func (sd *sysDialer) dialMPTCP(ctx context.Context, laddr, raddr *TCPAddr) (*TCPConn, error) {
+	if testHookDialTCP != nil {
+		return testHookDialTCP(ctx, sd.network, laddr, raddr)
+	}
+	if supportsMultipathTCP() {
+		c, err := sd.doDialTCPProto(ctx, laddr, raddr, _IPPROTO_MPTCP)
+		if err == nil || !isSocketError(err) {
+			return c, err
+		}
+	}
+	// Multipath TCP is not available, for example because a
+	// security policy forbids it; use plain TCP.
+	return sd.dialTCP(ctx, laddr, raddr)
+}
+
+func (sl *sysListener) listenMPTCP(ctx context.Context, laddr *TCPAddr) (*TCPListener, error) {
+	if supportsMultipathTCP() {
+		ln, err := sl.listenTCPProto(ctx, laddr, _IPPROTO_MPTCP)
+		if err == nil || !isSocketError(err) {
+			return ln, err
+		}
+	}
+	return sl.listenTCP(ctx, laddr)
+}
+
+func isUsingMultipathTCP(fd *netFD) bool {
+	var using bool
+	fd.pfd.RawControl(func(s uintptr) {
+		proto, err := getsockoptIntFunc(int(s), syscall.SOL_SOCKET, syscall.SO_PROTOCOL)
+		if err != nil || proto != _IPPROTO_MPTCP {
+			return
+		}
+		using = true
+		if hasSOLMPTCP {
+			// MPTCP_INFO fails with EOPNOTSUPP or ENOPROTOOPT,
+			// depending on the address family, if the connection
+			// fell back to plain TCP.
+			_, err := getsockoptIntFunc(int(s), _SOL_MPTCP, _MPTCP_INFO)
+			using = err != syscall.EOPNOTSUPP && err != syscall.ENOPROTOOPT
+		}
+	})
+	return using
+}
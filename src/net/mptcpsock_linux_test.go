@@ -0,0 +1,163 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/internal/socktest"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestMultipathTCP(t *testing.T) {
+	if !supportsMultipathTCP() {
+		t.Skip("Multipath TCP is not supported")
+	}
+	for _, tt := range []struct {
+		name             string
+		listen, dial     bool
+		wantServer, want bool
+	}{
+		{name: "both", listen: true, dial: true, wantServer: true, want: true},
+		{name: "listener", listen: true},
+		{name: "dialer", dial: true, want: !hasSOLMPTCP},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			testMultipathTCP(t, tt.listen, tt.dial, tt.wantServer, tt.want)
+		})
+	}
+}
+
+func testMultipathTCP(t *testing.T, listen, dial, wantServer, want bool) {
+	lc := ListenConfig{MultipathTCP: listen}
+	ln, err := lc.Listen(context.Background(), "tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// Echo using io.Copy, which uses splice on TCP connections.
+	type result struct {
+		using bool
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer c.Close()
+		using, err := c.(*TCPConn).MultipathTCP()
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		_, err = io.Copy(c, c)
+		done <- result{using, err}
+	}()
+
+	d := Dialer{MultipathTCP: dial}
+	c, err := d.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	tc := c.(*TCPConn)
+	if using, err := tc.MultipathTCP(); err != nil || using != want {
+		t.Errorf("client MultipathTCP = %v, %v; want %v, nil", using, err, want)
+	}
+
+	// Send a file using io.Copy, which uses sendfile.
+	f, err := os.CreateTemp(t.TempDir(), "mptcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	msg := bytes.Repeat([]byte("multipath "), 1<<12)
+	if _, err := f.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(tc, f); err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(tc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("echoed %d bytes; want %d", len(got), len(msg))
+	}
+
+	r := <-done
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if r.using != wantServer {
+		t.Errorf("server MultipathTCP = %v; want %v", r.using, wantServer)
+	}
+}
+
+func TestMultipathTCPFallback(t *testing.T) {
+	if !supportsMultipathTCP() {
+		t.Skip("Multipath TCP is not supported")
+	}
+	// Simulate a security policy that forbids Multipath TCP sockets.
+	sw.Set(socktest.FilterSocket, func(so *socktest.Status) (socktest.AfterFilter, error) {
+		if so.Cookie.Protocol() == _IPPROTO_MPTCP {
+			return nil, syscall.EPERM
+		}
+		return nil, nil
+	})
+	defer sw.Set(socktest.FilterSocket, nil)
+
+	lc := ListenConfig{MultipathTCP: true}
+	ln, err := lc.Listen(context.Background(), "tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if isUsingMultipathTCP(ln.(*TCPListener).fd) {
+		t.Error("listener uses Multipath TCP; want fallback to TCP")
+	}
+
+	d := Dialer{MultipathTCP: true}
+	c, err := d.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if using, err := c.(*TCPConn).MultipathTCP(); err != nil || using {
+		t.Errorf("MultipathTCP = %v, %v; want false, nil", using, err)
+	}
+}
+
+func TestMultipathTCPDialHook(t *testing.T) {
+	errHook := errors.New("hooked")
+	origTestHookDialTCP := testHookDialTCP
+	defer func() { testHookDialTCP = origTestHookDialTCP }()
+	testHookDialTCP = func(ctx context.Context, net string, laddr, raddr *TCPAddr) (*TCPConn, error) {
+		return nil, errHook
+	}
+
+	d := Dialer{MultipathTCP: true}
+	if c, err := d.Dial("tcp4", "127.0.0.1:1"); !errors.Is(err, errHook) {
+		if err == nil {
+			c.Close()
+		}
+		t.Errorf("Dial = %v; want %v from testHookDialTCP", err, errHook)
+	}
+}
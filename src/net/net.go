@@ -66,6 +66,16 @@ GODEBUG environment variable (see package runtime) to go or cgo, as in:
 The decision can also be forced while building the Go source tree
 by setting the netgo or netcgo build tag.
 
+A Resolver can set its DialTLS or ExchangeHTTPS field to use
+DNS over TLS (RFC 7858) or DNS over HTTPS (RFC 8484) for its own lookups.
+Setting GODEBUG=netdns=dot forces the pure Go resolver and makes it send
+all queries using DNS over TLS. Package net has no TLS client, so the
+connections are made by the Resolver's DialTLS function; for the
+package-level lookup functions, that is DefaultResolver.DialTLS.
+Lookups by a Resolver without DialTLS fail under netdns=dot rather than
+fall back to unencrypted DNS, as do all lookups on Windows and Plan 9,
+where the pure Go resolver is not used.
+
 A numeric netdns setting, as in GODEBUG=netdns=1, causes the resolver
 to print debugging information about its decisions.
 To force a particular resolver while also printing debugging information,
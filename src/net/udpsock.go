@@ -7,6 +7,7 @@ package net
 import (
 	"context"
 	"internal/itoa"
+	"net/netip"
 	"syscall"
 )
 
@@ -26,6 +27,20 @@ type UDPAddr struct {
 	Zone string // IPv6 scoped addressing zone
 }
 
+// AddrPort returns the UDPAddr a as a netip.AddrPort.
+//
+// If a.Port does not fit in a uint16, it's silently truncated.
+//
+// If a is nil, a zero value is returned.
+func (a *UDPAddr) AddrPort() netip.AddrPort {
+	if a == nil {
+		return netip.AddrPort{}
+	}
+	na, _ := netip.AddrFromSlice(a.IP)
+	na = na.WithZone(a.Zone)
+	return netip.AddrPortFrom(na, uint16(a.Port))
+}
+
 // Network returns the address's network name, "udp".
 func (a *UDPAddr) Network() string { return "udp" }
 
@@ -84,10 +99,33 @@ func ResolveUDPAddr(network, address string) (*UDPAddr, error) {
 	return addrs.forResolve(network, address).(*UDPAddr), nil
 }
 
+// UDPAddrFromAddrPort returns addr as a UDPAddr. If addr.IsValid() is false,
+// then the returned UDPAddr will contain a nil IP field, indicating an
+// address family-agnostic unspecified address.
+func UDPAddrFromAddrPort(addr netip.AddrPort) *UDPAddr {
+	return &UDPAddr{
+		IP:   addr.Addr().AsSlice(),
+		Zone: addr.Addr().Zone(),
+		Port: int(addr.Port()),
+	}
+}
+
+// An addrPortUDPAddr is a netip.AddrPort-based UDP address that satisfies the Addr interface.
+type addrPortUDPAddr struct {
+	netip.AddrPort
+}
+
+func (addrPortUDPAddr) Network() string { return "udp" }
+
 // UDPConn is the implementation of the Conn and PacketConn interfaces
 // for UDP network connections.
 type UDPConn struct {
 	conn
+
+	// gso records whether UDP generic segmentation offload works
+	// on this connection. It is accessed atomically and is only
+	// used on Linux; see writeBatch.
+	gso int32
 }
 
 // SyscallConn returns a raw network connection.
@@ -130,6 +168,22 @@ func (c *UDPConn) ReadFrom(b []byte) (int, Addr, error) {
 	return n, addr, err
 }
 
+// ReadFromUDPAddrPort acts like ReadFrom but returns a netip.AddrPort.
+//
+// If c is bound to an unspecified address, the returned
+// netip.AddrPort's address might be an IPv4-mapped IPv6 address.
+// Use netip.Addr.Unmap to get the address without the IPv6 prefix.
+func (c *UDPConn) ReadFromUDPAddrPort(b []byte) (n int, addr netip.AddrPort, err error) {
+	if !c.ok() {
+		return 0, netip.AddrPort{}, syscall.EINVAL
+	}
+	n, addr, err = c.readFromAddrPort(b)
+	if err != nil {
+		err = &OpError{Op: "read", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return n, addr, err
+}
+
 // ReadMsgUDP reads a message from c, copying the payload into b and
 // the associated out-of-band data into oob. It returns the number of
 // bytes copied into b, the number of bytes copied into oob, the flags
@@ -148,6 +202,128 @@ func (c *UDPConn) ReadMsgUDP(b, oob []byte) (n, oobn, flags int, addr *UDPAddr,
 	return
 }
 
+// ReadMsgUDPAddrPort is like ReadMsgUDP but returns an netip.AddrPort instead of a UDPAddr.
+func (c *UDPConn) ReadMsgUDPAddrPort(b, oob []byte) (n, oobn, flags int, addr netip.AddrPort, err error) {
+	if !c.ok() {
+		return 0, 0, 0, netip.AddrPort{}, syscall.EINVAL
+	}
+	n, oobn, flags, addr, err = c.readMsgAddrPort(b, oob)
+	if err != nil {
+		err = &OpError{Op: "read", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return
+}
+
+// A UDPMessage is a datagram read by ReadBatch or written by WriteBatch.
+type UDPMessage struct {
+	// Buffer holds the payload. ReadBatch stores the payload of a
+	// received message in Buffer[:N]; WriteBatch sends all of Buffer.
+	Buffer []byte
+
+	// OOB holds ancillary data, as in ReadMsgUDP and WriteMsgUDP.
+	// ReadBatch stores received ancillary data in OOB[:NOOB].
+	OOB []byte
+
+	// Addr is the source address of a received message. When
+	// writing on a connection that is not connected, it is the
+	// destination address; otherwise it must be the zero value.
+	Addr netip.AddrPort
+
+	// SegmentSize, if positive, indicates that Buffer holds several
+	// datagrams of SegmentSize bytes each, except for the last,
+	// which may be shorter.
+	//
+	// WriteBatch sends each segment as a separate datagram, passing
+	// the whole buffer to the kernel at once using UDP generic
+	// segmentation offload (GSO) where available. ReadBatch sets
+	// SegmentSize when generic receive offload, enabled by
+	// SetReceiveOffload, has merged datagrams from the same source;
+	// otherwise it sets SegmentSize to zero.
+	SegmentSize int
+
+	// N is the number of bytes of Buffer read or written.
+	N int
+
+	// NOOB is the number of bytes of OOB read.
+	NOOB int
+
+	// Flags holds the flags set on a received message.
+	Flags int
+}
+
+// ReadBatch reads up to len(ms) messages from c, storing them in ms.
+// It blocks until at least one message is available and returns the
+// number of messages read. On Linux, it reads the messages using a
+// single system call; on other systems, it reads one message.
+func (c *UDPConn) ReadBatch(ms []UDPMessage) (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	n, err := c.readBatch(ms)
+	if err != nil {
+		err = &OpError{Op: "read", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return n, err
+}
+
+// WriteBatch writes the messages in ms to c. It returns the number of
+// messages written, which is less than len(ms) only if an error
+// occurred. On Linux, it writes the messages using as few system calls
+// as possible; on other systems, it writes them one at a time.
+func (c *UDPConn) WriteBatch(ms []UDPMessage) (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	n, err := c.writeBatch(ms)
+	if err != nil {
+		var addr Addr = c.fd.raddr
+		if n < len(ms) && ms[n].Addr.IsValid() {
+			addr = addrPortUDPAddr{ms[n].Addr}
+		}
+		err = &OpError{Op: "write", Net: c.fd.net, Source: c.fd.laddr, Addr: addr, Err: err}
+	}
+	return n, err
+}
+
+// SetReceiveOffload sets whether the operating system may merge
+// datagrams received on c from the same source into a single message,
+// a mechanism known as generic receive offload (GRO). Only ReadBatch
+// reports the size of the merged datagrams, so other read methods
+// should not be used on c while receive offload is enabled.
+//
+// Receive offload is currently supported only on Linux.
+func (c *UDPConn) SetReceiveOffload(enable bool) error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	if err := c.setReceiveOffload(enable); err != nil {
+		return &OpError{Op: "set", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return nil
+}
+
+// writeSegments writes m using a separate call for each segment
+// of m.Buffer.
+func (c *UDPConn) writeSegments(m *UDPMessage) error {
+	b := m.Buffer
+	m.N = 0
+	for {
+		seg := b
+		if m.SegmentSize > 0 && len(seg) > m.SegmentSize {
+			seg = seg[:m.SegmentSize]
+		}
+		n, _, err := c.writeMsgAddrPort(seg, m.OOB, m.Addr)
+		m.N += n
+		if err != nil {
+			return err
+		}
+		b = b[len(seg):]
+		if len(b) == 0 {
+			return nil
+		}
+	}
+}
+
 // WriteToUDP acts like WriteTo but takes a UDPAddr.
 func (c *UDPConn) WriteToUDP(b []byte, addr *UDPAddr) (int, error) {
 	if !c.ok() {
@@ -160,6 +336,18 @@ func (c *UDPConn) WriteToUDP(b []byte, addr *UDPAddr) (int, error) {
 	return n, err
 }
 
+// WriteToUDPAddrPort acts like WriteTo but takes a netip.AddrPort.
+func (c *UDPConn) WriteToUDPAddrPort(b []byte, addr netip.AddrPort) (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	n, err := c.writeToAddrPort(b, addr)
+	if err != nil {
+		err = &OpError{Op: "write", Net: c.fd.net, Source: c.fd.laddr, Addr: addrPortUDPAddr{addr}, Err: err}
+	}
+	return n, err
+}
+
 // WriteTo implements the PacketConn WriteTo method.
 func (c *UDPConn) WriteTo(b []byte, addr Addr) (int, error) {
 	if !c.ok() {
@@ -195,7 +383,19 @@ func (c *UDPConn) WriteMsgUDP(b, oob []byte, addr *UDPAddr) (n, oobn int, err er
 	return
 }
 
-func newUDPConn(fd *netFD) *UDPConn { return &UDPConn{conn{fd}} }
+// WriteMsgUDPAddrPort is like WriteMsgUDP but takes a netip.AddrPort instead of a UDPAddr.
+func (c *UDPConn) WriteMsgUDPAddrPort(b, oob []byte, addr netip.AddrPort) (n, oobn int, err error) {
+	if !c.ok() {
+		return 0, 0, syscall.EINVAL
+	}
+	n, oobn, err = c.writeMsgAddrPort(b, oob, addr)
+	if err != nil {
+		err = &OpError{Op: "write", Net: c.fd.net, Source: c.fd.laddr, Addr: addrPortUDPAddr{addr}, Err: err}
+	}
+	return
+}
+
+func newUDPConn(fd *netFD) *UDPConn { return &UDPConn{conn: conn{fd}} }
 
 // DialUDP acts like Dial for UDP networks.
 //
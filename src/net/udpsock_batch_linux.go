@@ -0,0 +1,326 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"errors"
+	"internal/syscall/unix"
+	"net/netip"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	_SOL_UDP     = 0x11
+	_UDP_SEGMENT = 0x67
+	_UDP_GRO     = 0x68
+
+	// maxGSOSegmentSize is the largest segment size that fits in
+	// a UDP_SEGMENT control message.
+	maxGSOSegmentSize = 1<<16 - 1
+)
+
+func (fd *netFD) readMmsg(hs []unix.Mmsghdr) (int, error) {
+	n, err := fd.pfd.RecvMmsg(hs, 0)
+	runtime.KeepAlive(fd)
+	return n, wrapSyscallError("recvmmsg", err)
+}
+
+func (fd *netFD) writeMmsg(hs []unix.Mmsghdr) (int, error) {
+	n, err := fd.pfd.SendMmsg(hs, 0)
+	runtime.KeepAlive(fd)
+	return n, wrapSyscallError("sendmmsg", err)
+}
+
+func (c *UDPConn) readBatch(ms []UDPMessage) (int, error) {
+	if len(ms) == 0 {
+		return 0, nil
+	}
+	// Each message gets room for a UDP_GRO control message in
+	// addition to the caller's OOB buffer.
+	groSpace := syscall.CmsgSpace(4)
+	ooblen := 0
+	for i := range ms {
+		ooblen += len(ms[i].OOB) + groSpace
+	}
+	oob := make([]byte, ooblen)
+	hs := make([]unix.Mmsghdr, len(ms))
+	iovs := make([]syscall.Iovec, len(ms))
+	rsas := make([]syscall.RawSockaddrAny, len(ms))
+	for i := range ms {
+		h := &hs[i].Hdr
+		h.Name = (*byte)(unsafe.Pointer(&rsas[i]))
+		h.Namelen = syscall.SizeofSockaddrAny
+		setIovec(&iovs[i], ms[i].Buffer)
+		h.Iov = &iovs[i]
+		h.Iovlen = 1
+		n := len(ms[i].OOB) + groSpace
+		h.Control = &oob[0]
+		h.SetControllen(n)
+		oob = oob[n:]
+	}
+
+	n, err := c.fd.readMmsg(hs)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < n; i++ {
+		m, h := &ms[i], &hs[i]
+		m.N = int(h.Len)
+		m.Flags = int(h.Hdr.Flags)
+		m.Addr = rawSockaddrToAddrPort(&rsas[i])
+		control := unsafe.Slice(h.Hdr.Control, int(h.Hdr.Controllen))
+		var truncated bool
+		m.NOOB, m.SegmentSize, truncated = extractGRO(m.OOB, control)
+		if truncated {
+			m.Flags |= syscall.MSG_CTRUNC
+		}
+	}
+	return n, nil
+}
+
+// extractGRO copies the control messages in control other than
+// UDP_GRO to oob. It returns the number of bytes copied, the segment
+// size reported by a UDP_GRO control message, or zero if there was
+// none, and whether any control messages did not fit in oob.
+func extractGRO(oob, control []byte) (n, segmentSize int, truncated bool) {
+	for len(control) >= syscall.SizeofCmsghdr {
+		h := (*syscall.Cmsghdr)(unsafe.Pointer(&control[0]))
+		l := int(h.Len)
+		if l < syscall.SizeofCmsghdr || l > len(control) {
+			break
+		}
+		space := syscall.CmsgSpace(l - syscall.CmsgLen(0))
+		if space > len(control) {
+			space = len(control)
+		}
+		if h.Level == _SOL_UDP && h.Type == _UDP_GRO && l >= syscall.CmsgLen(4) {
+			segmentSize = int(*(*int32)(unsafe.Pointer(&control[syscall.CmsgLen(0)])))
+		} else if n+space <= len(oob) {
+			n += copy(oob[n:], control[:space])
+		} else {
+			truncated = true
+		}
+		control = control[space:]
+	}
+	return n, segmentSize, truncated
+}
+
+// Values of UDPConn.gso.
+const (
+	gsoUnknown = iota
+	gsoOn
+	gsoOff
+)
+
+func (c *UDPConn) writeBatch(ms []UDPMessage) (int, error) {
+	if !c.gsoAvailable() {
+		for i := range ms {
+			if !wantGSO(&ms[i]) {
+				continue
+			}
+			// Send the messages before ms[i] in one batch,
+			// then segment ms[i] here.
+			n, err := c.sendBatch(ms[:i], false)
+			if err != nil {
+				return n, err
+			}
+			if err := c.writeSegments(&ms[i]); err != nil {
+				return i, err
+			}
+			n, err = c.writeBatch(ms[i+1:])
+			return i + 1 + n, err
+		}
+	}
+	return c.sendBatch(ms, true)
+}
+
+// sendBatch sends ms using a single sendmmsg call. If gso is true,
+// messages that want segmentation are segmented by the kernel.
+func (c *UDPConn) sendBatch(ms []UDPMessage, gso bool) (int, error) {
+	if len(ms) == 0 {
+		return 0, nil
+	}
+	useGSO := func(m *UDPMessage) bool { return gso && wantGSO(m) }
+	segSpace := syscall.CmsgSpace(2)
+	ooblen := 0
+	for i := range ms {
+		ooblen += len(ms[i].OOB)
+		if useGSO(&ms[i]) {
+			ooblen += segSpace
+		}
+	}
+	oob := make([]byte, ooblen)
+	hs := make([]unix.Mmsghdr, len(ms))
+	iovs := make([]syscall.Iovec, len(ms))
+	rsas := make([]syscall.RawSockaddrInet6, len(ms))
+	var addrErr error
+	for i := range ms {
+		m, h := &ms[i], &hs[i].Hdr
+		namelen, err := c.rawSockaddr(&rsas[i], m.Addr)
+		if err != nil {
+			// Send the messages before the bad one.
+			hs, addrErr = hs[:i], err
+			break
+		}
+		if namelen > 0 {
+			h.Name = (*byte)(unsafe.Pointer(&rsas[i]))
+			h.Namelen = uint32(namelen)
+		}
+		setIovec(&iovs[i], m.Buffer)
+		h.Iov = &iovs[i]
+		h.Iovlen = 1
+		n := copy(oob, m.OOB)
+		if useGSO(m) {
+			ch := (*syscall.Cmsghdr)(unsafe.Pointer(&oob[n]))
+			ch.Level = _SOL_UDP
+			ch.Type = _UDP_SEGMENT
+			ch.SetLen(syscall.CmsgLen(2))
+			*(*uint16)(unsafe.Pointer(&oob[n+syscall.CmsgLen(0)])) = uint16(m.SegmentSize)
+			n += segSpace
+		}
+		if n > 0 {
+			h.Control = &oob[0]
+			h.SetControllen(n)
+		}
+		oob = oob[n:]
+	}
+
+	n, err := c.fd.writeMmsg(hs)
+	for i := 0; i < n; i++ {
+		ms[i].N = int(hs[i].Len)
+	}
+	if err != nil && useGSO(&ms[n]) && gsoUnsupported(err) {
+		// The network device cannot segment this message;
+		// segment it and later ones here instead.
+		atomic.StoreInt32(&c.gso, gsoOff)
+		if err := c.writeSegments(&ms[n]); err != nil {
+			return n, err
+		}
+		m, err := c.writeBatch(ms[n+1:])
+		return n + 1 + m, err
+	}
+	if err == nil {
+		err = addrErr
+	}
+	return n, err
+}
+
+// wantGSO reports whether m should be sent using UDP generic
+// segmentation offload, if the connection supports it.
+func wantGSO(m *UDPMessage) bool {
+	return m.SegmentSize > 0 && m.SegmentSize <= maxGSOSegmentSize && len(m.Buffer) > m.SegmentSize
+}
+
+// gsoAvailable reports whether c can send messages using UDP generic
+// segmentation offload. Kernels before Linux 4.18 ignore UDP_SEGMENT
+// control messages rather than reject them, so the first call asks
+// whether the socket option exists.
+func (c *UDPConn) gsoAvailable() bool {
+	switch atomic.LoadInt32(&c.gso) {
+	case gsoOn:
+		return true
+	case gsoOff:
+		return false
+	}
+	var err error
+	c.fd.pfd.RawControl(func(s uintptr) {
+		_, err = getsockoptIntFunc(int(s), _SOL_UDP, _UDP_SEGMENT)
+	})
+	switch err {
+	case nil:
+		atomic.StoreInt32(&c.gso, gsoOn)
+		return true
+	case syscall.ENOPROTOOPT:
+		atomic.StoreInt32(&c.gso, gsoOff)
+	}
+	return false
+}
+
+// gsoUnsupported reports whether err, returned by sendmmsg for a
+// message with a UDP_SEGMENT control message, indicates that the
+// route cannot use segmentation offload: the network device does not
+// compute checksums, or the route uses IPsec. Other errors, such as
+// EINVAL for a bad segment size, are returned to the caller.
+func gsoUnsupported(err error) bool {
+	return errors.Is(err, syscall.EIO)
+}
+
+func (c *UDPConn) setReceiveOffload(enable bool) error {
+	err := c.fd.pfd.SetsockoptInt(_SOL_UDP, _UDP_GRO, boolint(enable))
+	runtime.KeepAlive(c.fd)
+	return wrapSyscallError("setsockopt", err)
+}
+
+func setIovec(iov *syscall.Iovec, b []byte) {
+	if len(b) > 0 {
+		iov.Base = &b[0]
+		iov.SetLen(len(b))
+	}
+}
+
+// rawSockaddr stores the socket address for sending to addr on c in
+// rsa and returns its length, which is zero if c is connected.
+func (c *UDPConn) rawSockaddr(rsa *syscall.RawSockaddrInet6, addr netip.AddrPort) (int, error) {
+	if c.fd.isConnected && addr.IsValid() {
+		return 0, ErrWriteToConnected
+	}
+	if !c.fd.isConnected && !addr.IsValid() {
+		return 0, errMissingAddress
+	}
+	if !addr.IsValid() {
+		return 0, nil
+	}
+	switch c.fd.family {
+	case syscall.AF_INET:
+		sa, err := addrPortToSockaddrInet4(addr)
+		if err != nil {
+			return 0, err
+		}
+		rsa4 := (*syscall.RawSockaddrInet4)(unsafe.Pointer(rsa))
+		rsa4.Family = syscall.AF_INET
+		putPort(&rsa4.Port, sa.Port)
+		rsa4.Addr = sa.Addr
+		return syscall.SizeofSockaddrInet4, nil
+	case syscall.AF_INET6:
+		sa, err := addrPortToSockaddrInet6(addr)
+		if err != nil {
+			return 0, err
+		}
+		rsa.Family = syscall.AF_INET6
+		putPort(&rsa.Port, sa.Port)
+		rsa.Addr = sa.Addr
+		rsa.Scope_id = sa.ZoneId
+		return syscall.SizeofSockaddrInet6, nil
+	default:
+		return 0, &AddrError{Err: "invalid address family", Addr: addr.Addr().String()}
+	}
+}
+
+// putPort stores port in network byte order in p.
+func putPort(p *uint16, port int) {
+	b := (*[2]byte)(unsafe.Pointer(p))
+	b[0] = byte(port >> 8)
+	b[1] = byte(port)
+}
+
+// rawSockaddrToAddrPort converts a received socket address to an
+// AddrPort, returning the zero AddrPort for other address families.
+func rawSockaddrToAddrPort(rsa *syscall.RawSockaddrAny) netip.AddrPort {
+	switch rsa.Addr.Family {
+	case syscall.AF_INET:
+		rsa4 := (*syscall.RawSockaddrInet4)(unsafe.Pointer(rsa))
+		b := (*[2]byte)(unsafe.Pointer(&rsa4.Port))
+		return netip.AddrPortFrom(netip.AddrFrom4(rsa4.Addr), uint16(b[0])<<8|uint16(b[1]))
+	case syscall.AF_INET6:
+		rsa6 := (*syscall.RawSockaddrInet6)(unsafe.Pointer(rsa))
+		b := (*[2]byte)(unsafe.Pointer(&rsa6.Port))
+		ip := netip.AddrFrom16(rsa6.Addr).WithZone(zoneCache.name(int(rsa6.Scope_id)))
+		return netip.AddrPortFrom(ip, uint16(b[0])<<8|uint16(b[1]))
+	}
+	return netip.AddrPort{}
+}
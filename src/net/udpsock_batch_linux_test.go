@@ -0,0 +1,150 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"bytes"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestUDPReceiveOffload(t *testing.T) {
+	r, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if err := r.SetReceiveOffload(true); err != nil {
+		t.Skipf("receive offload not supported: %v", err)
+	}
+	w, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	const segmentSize = 1000
+	msg := bytes.Repeat([]byte("abcdefghij"), 4*segmentSize/10)
+	out := []UDPMessage{{
+		Buffer:      msg,
+		SegmentSize: segmentSize,
+		Addr:        r.LocalAddr().(*UDPAddr).AddrPort(),
+	}}
+	if _, err := w.WriteBatch(out); err != nil {
+		t.Fatal(err)
+	}
+
+	// The datagrams may or may not be merged, but the payload
+	// must arrive intact and the segment size must be reported
+	// whenever they are.
+	var got []byte
+	r.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for len(got) < len(msg) {
+		in := []UDPMessage{{Buffer: make([]byte, 1<<16), OOB: make([]byte, 64)}}
+		if _, err := r.ReadBatch(in); err != nil {
+			t.Fatal(err)
+		}
+		m := in[0]
+		if m.N > segmentSize && m.SegmentSize != segmentSize {
+			t.Errorf("read %d bytes with SegmentSize %d; want %d", m.N, m.SegmentSize, segmentSize)
+		}
+		if m.NOOB != 0 {
+			t.Errorf("NOOB = %d; want 0", m.NOOB)
+		}
+		got = append(got, m.Buffer[:m.N]...)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("received %d bytes that differ from the %d bytes sent", len(got), len(msg))
+	}
+}
+
+func TestUDPGSOUnsupported(t *testing.T) {
+	origGetsockoptInt := getsockoptIntFunc
+	defer func() { getsockoptIntFunc = origGetsockoptInt }()
+	getsockoptIntFunc = func(s, level, opt int) (int, error) {
+		if level == _SOL_UDP && opt == _UDP_SEGMENT {
+			// As on kernels before Linux 4.18.
+			return 0, syscall.ENOPROTOOPT
+		}
+		return origGetsockoptInt(s, level, opt)
+	}
+
+	r, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	dst := r.LocalAddr().(*UDPAddr).AddrPort()
+
+	segmented := bytes.Repeat([]byte("0123456789"), 250)
+	out := []UDPMessage{
+		{Buffer: []byte("first"), Addr: dst},
+		{Buffer: segmented, SegmentSize: 1000, Addr: dst},
+		{Buffer: []byte("last"), Addr: dst},
+	}
+	for i := 0; i < 2; i++ {
+		if n, err := w.WriteBatch(out); err != nil || n != len(out) {
+			t.Fatalf("WriteBatch = %d, %v; want %d, nil", n, err, len(out))
+		}
+		if got := atomic.LoadInt32(&w.gso); got != gsoOff {
+			t.Fatalf("gso = %d after WriteBatch; want %d", got, gsoOff)
+		}
+	}
+
+	want := [][]byte{
+		[]byte("first"),
+		segmented[:1000],
+		segmented[1000:2000],
+		segmented[2000:],
+		[]byte("last"),
+	}
+	r.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 1<<16)
+	for i := 0; i < 2*len(want); i++ {
+		n, _, err := r.ReadFromUDPAddrPort(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wb := want[i%len(want)]; !bytes.Equal(b[:n], wb) {
+			t.Errorf("datagram %d = %d bytes; want %d bytes", i, n, len(wb))
+		}
+	}
+}
+
+func TestUDPGSOInvalidSegmentSize(t *testing.T) {
+	r, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if !w.gsoAvailable() {
+		t.Skip("UDP segmentation offload not supported")
+	}
+
+	// Far more segments than the kernel accepts in one message.
+	out := []UDPMessage{{
+		Buffer:      make([]byte, 1000),
+		SegmentSize: 1,
+		Addr:        r.LocalAddr().(*UDPAddr).AddrPort(),
+	}}
+	if n, err := w.WriteBatch(out); err == nil {
+		t.Errorf("WriteBatch = %d, nil; want error", n)
+	}
+	if got := atomic.LoadInt32(&w.gso); got != gsoOn {
+		t.Errorf("gso = %d after failed WriteBatch; want %d", got, gsoOn)
+	}
+}
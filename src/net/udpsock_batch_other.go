@@ -0,0 +1,39 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package net
+
+import "errors"
+
+var errNoReceiveOffload = errors.New("UDP receive offload not supported")
+
+func (c *UDPConn) readBatch(ms []UDPMessage) (int, error) {
+	if len(ms) == 0 {
+		return 0, nil
+	}
+	m := &ms[0]
+	var err error
+	m.N, m.NOOB, m.Flags, m.Addr, err = c.readMsgAddrPort(m.Buffer, m.OOB)
+	m.SegmentSize = 0
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (c *UDPConn) writeBatch(ms []UDPMessage) (int, error) {
+	for i := range ms {
+		if err := c.writeSegments(&ms[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(ms), nil
+}
+
+func (c *UDPConn) setReceiveOffload(enable bool) error {
+	return errNoReceiveOffload
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !js
+// +build !js
+
+package net
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestUDPBatch(t *testing.T) {
+	switch runtime.GOOS {
+	case "plan9", "windows":
+		t.Skipf("not supported on %s", runtime.GOOS)
+	}
+	if !testableNetwork("udp4") {
+		t.Skip("udp4 is not supported")
+	}
+	r, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	dst := r.LocalAddr().(*UDPAddr).AddrPort()
+	src := w.LocalAddr().(*UDPAddr).AddrPort()
+
+	segmented := bytes.Repeat([]byte("0123456789"), 250)
+	out := []UDPMessage{
+		{Buffer: []byte("first"), Addr: dst},
+		{Buffer: segmented, SegmentSize: 1000, Addr: dst},
+		{Buffer: []byte("last"), Addr: dst},
+	}
+	n, err := w.WriteBatch(out)
+	if err != nil || n != len(out) {
+		t.Fatalf("WriteBatch = %d, %v; want %d, nil", n, err, len(out))
+	}
+	for i, m := range out {
+		if m.N != len(m.Buffer) {
+			t.Errorf("message %d: N = %d; want %d", i, m.N, len(m.Buffer))
+		}
+	}
+
+	want := [][]byte{
+		[]byte("first"),
+		segmented[:1000],
+		segmented[1000:2000],
+		segmented[2000:],
+		[]byte("last"),
+	}
+	var got [][]byte
+	r.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for len(got) < len(want) {
+		in := make([]UDPMessage, 4)
+		for i := range in {
+			in[i].Buffer = make([]byte, 1500)
+		}
+		n, err := r.ReadBatch(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n < 1 || n > len(in) {
+			t.Fatalf("ReadBatch = %d; want between 1 and %d", n, len(in))
+		}
+		for _, m := range in[:n] {
+			if m.Addr != src {
+				t.Errorf("message from %v; want %v", m.Addr, src)
+			}
+			if m.SegmentSize != 0 {
+				t.Errorf("SegmentSize = %d without receive offload", m.SegmentSize)
+			}
+			got = append(got, m.Buffer[:m.N])
+		}
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("datagram %d = %q; want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestUDPBatchAddressErrors(t *testing.T) {
+	switch runtime.GOOS {
+	case "plan9", "windows":
+		t.Skipf("not supported on %s", runtime.GOOS)
+	}
+	if !testableNetwork("udp4") {
+		t.Skip("udp4 is not supported")
+	}
+	ln, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	dst := ln.LocalAddr().(*UDPAddr).AddrPort()
+
+	n, err := ln.WriteBatch([]UDPMessage{{Buffer: []byte("a"), Addr: dst}, {Buffer: []byte("b")}})
+	if n != 1 || !errors.Is(err, errMissingAddress) {
+		t.Errorf("unconnected WriteBatch without address = %d, %v; want 1, %v", n, err, errMissingAddress)
+	}
+
+	c, err := DialUDP("udp4", nil, ln.LocalAddr().(*UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	n, err = c.WriteBatch([]UDPMessage{{Buffer: []byte("a")}, {Buffer: []byte("b"), Addr: netip.MustParseAddrPort("127.0.0.1:1")}})
+	if n != 1 || !errors.Is(err, ErrWriteToConnected) {
+		t.Errorf("connected WriteBatch with address = %d, %v; want 1, %v", n, err, ErrWriteToConnected)
+	}
+}
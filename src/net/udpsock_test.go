@@ -10,6 +10,7 @@ package net
 import (
 	"errors"
 	"internal/testenv"
+	"net/netip"
 	"os"
 	"reflect"
 	"runtime"
@@ -495,3 +496,116 @@ func BenchmarkWriteToReadFromUDP(b *testing.B) {
 		}
 	}
 }
+
+func TestUDPAddrPort(t *testing.T) {
+	for _, tt := range []struct {
+		in  *UDPAddr
+		out netip.AddrPort
+	}{
+		{&UDPAddr{IP: IP{127, 0, 0, 1}, Port: 53}, netip.MustParseAddrPort("127.0.0.1:53")},
+		{&UDPAddr{IP: ParseIP("fe80::1"), Port: 853, Zone: "eth0"}, netip.MustParseAddrPort("[fe80::1%eth0]:853")},
+		{&UDPAddr{Port: 80}, netip.AddrPortFrom(netip.Addr{}, 80)},
+		{nil, netip.AddrPort{}},
+	} {
+		if got := tt.in.AddrPort(); got != tt.out {
+			t.Errorf("(%v).AddrPort() = %v, want %v", tt.in, got, tt.out)
+		}
+		if !tt.out.IsValid() {
+			continue
+		}
+		back := UDPAddrFromAddrPort(tt.out)
+		if !back.IP.Equal(tt.in.IP) || back.Port != tt.in.Port || back.Zone != tt.in.Zone {
+			t.Errorf("UDPAddrFromAddrPort(%v) = %v, want %v", tt.out, back, tt.in)
+		}
+	}
+}
+
+func TestUDPReadWriteAddrPort(t *testing.T) {
+	c, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	addr := c.LocalAddr().(*UDPAddr).AddrPort()
+
+	b1 := []byte("UDP ADDRPORT TEST")
+	b2 := make([]byte, len(b1))
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.WriteToUDPAddrPort(b1, addr); err != nil {
+		t.Fatal(err)
+	}
+	n, from, err := c.ReadFromUDPAddrPort(b2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b2[:n]) != string(b1) || from != addr {
+		t.Errorf("ReadFromUDPAddrPort = %q, %v; want %q, %v", b2[:n], from, b1, addr)
+	}
+
+	if _, err := c.WriteToUDPAddrPort(b1, netip.AddrPort{}); err == nil {
+		t.Error("WriteToUDPAddrPort to zero AddrPort succeeded")
+	}
+
+	switch runtime.GOOS {
+	case "plan9", "windows":
+		return // ReadMsgUDP and WriteMsgUDP are not implemented
+	}
+	if _, _, err := c.WriteMsgUDPAddrPort(b1, nil, addr); err != nil {
+		t.Fatal(err)
+	}
+	n, _, _, from, err = c.ReadMsgUDPAddrPort(b2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b2[:n]) != string(b1) || from != addr {
+		t.Errorf("ReadMsgUDPAddrPort = %q, %v; want %q, %v", b2[:n], from, b1, addr)
+	}
+}
+
+func TestUDPAddrPortAllocs(t *testing.T) {
+	switch runtime.GOOS {
+	case "plan9":
+		t.Skipf("not supported on %s", runtime.GOOS)
+	}
+
+	c, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	addr := c.LocalAddr().(*UDPAddr).AddrPort()
+	buf := make([]byte, 8)
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		if _, err := c.WriteToUDPAddrPort(buf, addr); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := c.ReadFromUDPAddrPort(buf); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if got := int(allocs); got != 0 {
+		t.Errorf("WriteToUDPAddrPort/ReadFromUDPAddrPort allocated %d objects", got)
+	}
+}
+
+func BenchmarkWriteToReadFromUDPAddrPort(b *testing.B) {
+	conn, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	addr := conn.LocalAddr().(*UDPAddr).AddrPort()
+	buf := make([]byte, 8)
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := conn.WriteToUDPAddrPort(buf, addr)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, _, err = conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
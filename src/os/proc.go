@@ -68,11 +68,16 @@ func Exit(code int) {
 			panic("unexpected call to os.Exit(0) during test")
 		}
 
-		// Give race detector a chance to fail the program.
-		// Racy programs do not have the right to finish successfully.
-		runtime_beforeExit()
 	}
+
+	// Inform the runtime that os.Exit is being called. If -race is
+	// enabled, this will give race detector a chance to fail the
+	// program (racy programs do not have the right to finish
+	// successfully). If coverage is enabled, then this call will
+	// enable us to write out a coverage data file.
+	runtime_beforeExit(code)
+
 	syscall.Exit(code)
 }
 
-func runtime_beforeExit() // implemented in runtime
+func runtime_beforeExit(exitCode int) // implemented in runtime
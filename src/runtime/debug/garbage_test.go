@@ -6,6 +6,7 @@ package debug_test
 
 import (
 	"internal/testenv"
+	"math"
 	"runtime"
 	. "runtime/debug"
 	"testing"
@@ -172,6 +173,24 @@ func TestSetGCPercent(t *testing.T) {
 	}
 }
 
+func TestSetMemoryLimit(t *testing.T) {
+	// The initial limit is math.MaxInt64 unless GOMEMLIMIT is set.
+	old := SetMemoryLimit(-1)
+	defer SetMemoryLimit(old)
+
+	const limit = 1 << 30
+	if got := SetMemoryLimit(limit); got != old {
+		t.Errorf("SetMemoryLimit(%d) = %d, want %d", int64(limit), got, old)
+	}
+	// A negative limit only reads back the current one.
+	if got := SetMemoryLimit(-1); got != limit {
+		t.Errorf("SetMemoryLimit(-1) = %d, want %d", got, int64(limit))
+	}
+	if got := SetMemoryLimit(math.MaxInt64); got != limit {
+		t.Errorf("SetMemoryLimit(math.MaxInt64) = %d, want %d", got, int64(limit))
+	}
+}
+
 func abs64(a int64) int64 {
 	if a < 0 {
 		return -a
@@ -203,6 +203,17 @@ func TestGcZombieReporting(t *testing.T) {
 	}
 }
 
+func TestGCMemoryLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	got := runTestProg(t, "testprog", "GCMemoryLimit")
+	want := "OK\n"
+	if got != want {
+		t.Fatalf("expected %q, but got %q", want, got)
+	}
+}
+
 func TestGCTestMoveStackOnNextCall(t *testing.T) {
 	t.Parallel()
 	var onStack int
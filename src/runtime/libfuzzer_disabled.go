@@ -0,0 +1,21 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !libfuzzer
+// +build !libfuzzer
+
+package runtime
+
+// When the libfuzzer build tag is not set, code compiled with -d=libfuzzer
+// (for example, by 'go test -fuzz') only needs the 8-bit coverage counters.
+// The comparison hooks are no-ops.
+
+func libfuzzerTraceCmp1(arg0, arg1 uint8)       {}
+func libfuzzerTraceCmp2(arg0, arg1 uint16)      {}
+func libfuzzerTraceCmp4(arg0, arg1 uint32)      {}
+func libfuzzerTraceCmp8(arg0, arg1 uint64)      {}
+func libfuzzerTraceConstCmp1(arg0, arg1 uint8)  {}
+func libfuzzerTraceConstCmp2(arg0, arg1 uint16) {}
+func libfuzzerTraceConstCmp4(arg0, arg1 uint32) {}
+func libfuzzerTraceConstCmp8(arg0, arg1 uint64) {}
@@ -157,8 +157,9 @@ func gcinit() {
 	mheap_.sweepDrained = 1
 
 	// Initialize GC pacer state.
-	// Use the environment variable GOGC for the initial gcPercent value.
-	gcController.init(readGOGC())
+	// Use the environment variable GOGC for the initial gcPercent value
+	// and GOMEMLIMIT for the initial memoryLimit value.
+	gcController.init(readGOGC(), readGOMEMLIMIT())
 
 	work.startSema = 1
 	work.markDoneSema = 1
@@ -716,6 +717,7 @@ func gcStart(trigger gcTrigger) {
 	systemstack(func() {
 		now = startTheWorldWithSema(trace.enabled)
 		work.pauseNS += now - work.pauseStart
+		gcCPULimiter.addGCTime((now - work.pauseStart) * int64(gomaxprocs))
 		work.tMark = now
 		memstats.gcPauseDist.record(now - work.pauseStart)
 	})
@@ -865,6 +867,7 @@ top:
 		systemstack(func() {
 			now := startTheWorldWithSema(true)
 			work.pauseNS += now - work.pauseStart
+			gcCPULimiter.addGCTime((now - work.pauseStart) * int64(gomaxprocs))
 			memstats.gcPauseDist.record(now - work.pauseStart)
 		})
 		semrelease(&worldsema)
@@ -977,6 +980,7 @@ func gcMarkTermination(nextTriggerRatio float64) {
 	sec, nsec, _ := time_now()
 	unixNow := sec*1e9 + int64(nsec)
 	work.pauseNS += now - work.pauseStart
+	gcCPULimiter.addGCTime((now - work.pauseStart) * int64(gomaxprocs))
 	work.tEnd = now
 	memstats.gcPauseDist.record(now - work.pauseStart)
 	atomic.Store64(&memstats.last_gc_unix, uint64(unixNow)) // must be Unix time to make sense to user
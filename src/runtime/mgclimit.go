@@ -0,0 +1,192 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import "runtime/internal/atomic"
+
+// gcCPULimiter is a mechanism to limit GC CPU utilization in situations
+// where it might become excessive and inhibit application progress (e.g.
+// a death spiral).
+//
+// A memory limit can ask for a heap goal at or below the live heap. The
+// GC would then run back to back, and mutator assists would take over
+// every goroutine that allocates. The limiter keeps that from happening
+// by letting the heap overrun its goal, and the memory limit with it,
+// once the GC has used too much CPU for too long.
+//
+// The core of the limiter is a leaky bucket that fills with GC CPU time
+// and drains with mutator time. Because the bucket fills and drains at
+// the same rate, this effectively caps GC CPU utilization at 50% over
+// any window longer than the bucket's capacity. The capacity lets short
+// bursts of GC work through, such as a single expensive cycle.
+//
+// When the bucket is full, the limiter disables mutator assists, which
+// are the only GC work that isn't already bounded by the pacer. The
+// dedicated and fractional mark workers keep running at their usual
+// 25% utilization, so the GC still makes progress.
+var gcCPULimiter gcCPULimiterState
+
+type gcCPULimiterState struct {
+	// gcTimePool is GC CPU time in nanoseconds that has accumulated
+	// since the last update, e.g. from mutator assists and stop-the-world
+	// pauses. Background mark worker time is not added here; update
+	// accounts for it directly.
+	//
+	// Updated atomically.
+	gcTimePool int64
+
+	// lastUpdate is the nanotime timestamp of the last update.
+	//
+	// Updated atomically.
+	lastUpdate int64
+
+	// bucket is the leaky bucket itself. It is in units of CPU
+	// nanoseconds.
+	//
+	// Protected by lock.
+	bucket struct {
+		fill     uint64
+		capacity uint64
+	}
+
+	// overflow is the cumulative amount of GC CPU time that the
+	// limiter tried to add to a full bucket.
+	//
+	// Protected by lock.
+	overflow uint64
+
+	// lock is set while the bucket is being updated. Updates are
+	// best-effort, so a caller that finds it set just skips its update.
+	lock uint32
+
+	// enabled is non-zero when the bucket is full and the limiter is
+	// turning off mutator assists.
+	//
+	// Updated atomically.
+	enabled uint32
+
+	// lastEnabledCycle is the GC cycle that last had the limiter
+	// enabled.
+	//
+	// Updated atomically.
+	lastEnabledCycle uint32
+}
+
+const (
+	// gcCPULimiterUpdatePeriod is the minimum time in nanoseconds
+	// between updates that sysmon drives.
+	gcCPULimiterUpdatePeriod = 10e6 // 10ms
+
+	// capacityPerProc is the limiter's bucket capacity for each P
+	// in GOMAXPROCS, in CPU nanoseconds.
+	capacityPerProc = 1e9 // 1 second
+)
+
+// limiting reports whether the limiter is currently enabled.
+//
+//go:nosplit
+func (l *gcCPULimiterState) limiting() bool {
+	return atomic.Load(&l.enabled) != 0
+}
+
+// addGCTime adds GC CPU time in nanoseconds to the limiter's pool. It
+// is accounted for at the next update.
+func (l *gcCPULimiterState) addGCTime(t int64) {
+	atomic.Xaddint64(&l.gcTimePool, t)
+}
+
+// needUpdate reports whether the limiter's bucket hasn't been updated
+// in a while.
+func (l *gcCPULimiterState) needUpdate(now int64) bool {
+	return now-atomic.Loadint64(&l.lastUpdate) > gcCPULimiterUpdatePeriod
+}
+
+// update drains the GC time pool and updates the bucket with the CPU
+// time the GC and the mutator used since the last update.
+func (l *gcCPULimiterState) update(now int64) {
+	if !atomic.Cas(&l.lock, 0, 1) {
+		// Someone else is updating. Our data will be picked up
+		// by the next update anyway.
+		return
+	}
+	lastUpdate := atomic.Loadint64(&l.lastUpdate)
+	if now < lastUpdate {
+		// Defensively avoid overflow. This isn't even the latest
+		// update anyway.
+		atomic.Store(&l.lock, 0)
+		return
+	}
+	windowTotalTime := (now - lastUpdate) * int64(gomaxprocs)
+	atomic.Storeint64(&l.lastUpdate, now)
+
+	gcTime := atomic.Xchgint64(&l.gcTimePool, 0)
+	if gcBlackenEnabled != 0 {
+		// Assume the background mark workers hit their
+		// utilization goal over the window.
+		gcTime += int64(float64(windowTotalTime) * gcBackgroundUtilization)
+	}
+	if gcTime > windowTotalTime {
+		gcTime = windowTotalTime
+	}
+	l.accumulate(windowTotalTime-gcTime, gcTime)
+	atomic.Store(&l.lock, 0)
+}
+
+// accumulate adds GC time to the bucket and drains it by mutator time,
+// enabling or disabling the limiter as the bucket fills and empties.
+//
+// l.lock must be held.
+func (l *gcCPULimiterState) accumulate(mutatorTime, gcTime int64) {
+	change := gcTime - mutatorTime
+	switch {
+	case change < 0:
+		if uint64(-change) >= l.bucket.fill {
+			l.bucket.fill = 0
+		} else {
+			l.bucket.fill -= uint64(-change)
+		}
+	case change > 0:
+		headroom := l.bucket.capacity - l.bucket.fill
+		if uint64(change) > headroom {
+			l.overflow += uint64(change) - headroom
+			l.bucket.fill = l.bucket.capacity
+		} else {
+			l.bucket.fill += uint64(change)
+		}
+	}
+
+	if l.bucket.fill == l.bucket.capacity {
+		if atomic.Load(&l.enabled) == 0 {
+			atomic.Store(&l.enabled, 1)
+		}
+		atomic.Store(&l.lastEnabledCycle, memstats.numgc+1)
+	} else if atomic.Load(&l.enabled) != 0 {
+		atomic.Store(&l.enabled, 0)
+	}
+}
+
+// resetCapacity updates the capacity of the bucket based on
+// GOMAXPROCS. It must be called any time GOMAXPROCS changes, before
+// gomaxprocs takes on the new value.
+//
+// The world must be stopped.
+func (l *gcCPULimiterState) resetCapacity(now int64, nprocs int32) {
+	assertWorldStopped()
+
+	if atomic.Loadint64(&l.lastUpdate) != 0 {
+		// Flush everything accumulated under the old GOMAXPROCS.
+		l.update(now)
+	} else {
+		atomic.Storeint64(&l.lastUpdate, now)
+	}
+	l.bucket.capacity = uint64(nprocs) * capacityPerProc
+	if l.bucket.fill > l.bucket.capacity {
+		l.bucket.fill = l.bucket.capacity
+		atomic.Store(&l.enabled, 1)
+		atomic.Store(&l.lastEnabledCycle, memstats.numgc+1)
+	} else if l.bucket.fill < l.bucket.capacity {
+		atomic.Store(&l.enabled, 0)
+	}
+}
@@ -393,6 +393,13 @@ func gcAssistAlloc(gp *g) {
 		return
 	}
 
+	// If the CPU limiter is enabled, intentionally don't
+	// assist to reduce the amount of CPU time spent in the GC.
+	// The heap may overshoot its goal as a result.
+	if gcCPULimiter.limiting() {
+		return
+	}
+
 	traced := false
 retry:
 	// Compute the amount of scan work we need to do to make the
@@ -568,6 +575,7 @@ func gcAssistAlloc1(gp *g, scanWork int64) {
 	_p_.gcAssistTime += duration
 	if _p_.gcAssistTime > gcAssistTimeSlack {
 		atomic.Xaddint64(&gcController.assistTime, _p_.gcAssistTime)
+		gcCPULimiter.addGCTime(_p_.gcAssistTime)
 		_p_.gcAssistTime = 0
 	}
 }
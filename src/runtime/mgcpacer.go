@@ -47,6 +47,20 @@ const (
 
 	// defaultHeapMinimum is the value of heapMinimum for GOGC==100.
 	defaultHeapMinimum = 4 << 20
+
+	// maxMemoryLimit is the value of memoryLimit when no limit is set.
+	maxMemoryLimit = 1<<63 - 1
+
+	// memoryLimitHeadroomPercent is the portion of the memory limit, in
+	// percent, that the heap goal leaves unused. The headroom absorbs
+	// fragmentation and memory the runtime maps between GC cycles,
+	// neither of which the goal can see when it is computed.
+	memoryLimitHeadroomPercent = 5
+
+	// memoryLimitTriggerRunway is the fraction of the distance between
+	// the marked heap and a heap goal set by the memory limit that
+	// may be allocated before the next GC cycle starts.
+	memoryLimitTriggerRunway = 0.7
 )
 
 func init() {
@@ -54,6 +68,10 @@ func init() {
 		println(offset)
 		throw("gcController.heapLive not aligned to 8 bytes")
 	}
+	if offset := unsafe.Offsetof(gcController.memoryLimit); offset%8 != 0 {
+		println(offset)
+		throw("gcController.memoryLimit not aligned to 8 bytes")
+	}
 }
 
 // gcController implements the GC pacing controller that determines
@@ -77,6 +95,14 @@ type gcControllerState struct {
 
 	_ uint32 // padding so following 64-bit values are 8-byte aligned
 
+	// memoryLimit is the soft memory limit in bytes.
+	//
+	// Initialized from $GOMEMLIMIT. maxMemoryLimit means no limit.
+	//
+	// Read atomically; written with mheap_.lock held or with the
+	// world stopped.
+	memoryLimit int64
+
 	// heapMinimum is the minimum heap size at which to trigger GC.
 	// For small heaps, this overrides the usual GOGC*live set rule.
 	//
@@ -253,11 +279,18 @@ type gcControllerState struct {
 	// If this is zero, no fractional workers are needed.
 	fractionalUtilizationGoal float64
 
+	// memoryLimitBinding is set when the heap goal for the current
+	// cycle comes from the memory limit rather than from gcPercent.
+	//
+	// Protected by mheap_.lock or a STW.
+	memoryLimitBinding bool
+
 	_ cpu.CacheLinePad
 }
 
-func (c *gcControllerState) init(gcPercent int32) {
+func (c *gcControllerState) init(gcPercent int32, memoryLimit int64) {
 	c.heapMinimum = defaultHeapMinimum
+	c.memoryLimit = memoryLimit
 
 	// Set a reasonable initial GC trigger.
 	c.triggerRatio = 7 / 8.0
@@ -447,11 +480,14 @@ func (c *gcControllerState) revise() {
 // userForced indicates whether the current GC cycle was forced
 // by the application.
 func (c *gcControllerState) endCycle(userForced bool) float64 {
-	if userForced {
+	if userForced || c.memoryLimitBinding {
 		// Forced GC means this cycle didn't start at the
 		// trigger, so where it finished isn't good
 		// information about how to adjust the trigger.
 		// Just leave it where it is.
+		//
+		// The same goes for a cycle paced against the memory
+		// limit: its trigger wasn't derived from triggerRatio.
 		return c.triggerRatio
 	}
 
@@ -643,7 +679,7 @@ func (c *gcControllerState) findRunnableGCWorker(_p_ *p) *g {
 // This can be called any time. If GC is the in the middle of a
 // concurrent phase, it will adjust the pacing of that phase.
 //
-// This depends on gcPercent, gcController.heapMarked, and
+// This depends on gcPercent, memoryLimit, gcController.heapMarked, and
 // gcController.heapLive. These must be up to date.
 //
 // mheap_.lock must be held or the world must be stopped.
@@ -658,6 +694,14 @@ func (c *gcControllerState) commit(triggerRatio float64) {
 		goal = c.heapMarked + c.heapMarked*uint64(c.gcPercent)/100
 	}
 
+	// The memory limit may call for a smaller goal than GOGC does.
+	// That goal is reached sooner, so it wins.
+	c.memoryLimitBinding = false
+	if limitGoal := c.memoryLimitHeapGoal(); limitGoal < goal {
+		goal = limitGoal
+		c.memoryLimitBinding = true
+	}
+
 	// Set the trigger ratio, capped to reasonable bounds.
 	if c.gcPercent >= 0 {
 		scalingFactor := float64(c.gcPercent) / 100
@@ -700,10 +744,19 @@ func (c *gcControllerState) commit(triggerRatio float64) {
 	// We trigger the next GC cycle when the allocated heap has
 	// grown by the trigger ratio over the marked heap size.
 	trigger := ^uint64(0)
-	if c.gcPercent >= 0 {
-		trigger = uint64(float64(c.heapMarked) * (1 + triggerRatio))
-		// Don't trigger below the minimum heap size.
-		minTrigger := c.heapMinimum
+	if c.gcPercent >= 0 || c.memoryLimitBinding {
+		var minTrigger uint64
+		if c.memoryLimitBinding {
+			// The trigger ratio is relative to the GOGC goal,
+			// which is further out than the goal we have. Start
+			// the cycle a fixed fraction of the way to the goal
+			// instead, and let the limit override heapMinimum.
+			trigger = c.heapMarked + uint64(float64(goal-c.heapMarked)*memoryLimitTriggerRunway)
+		} else {
+			trigger = uint64(float64(c.heapMarked) * (1 + triggerRatio))
+			// Don't trigger below the minimum heap size.
+			minTrigger = c.heapMinimum
+		}
 		if !isSweepDone() {
 			// Concurrent sweep happens in the heap growth
 			// from gcController.heapLive to trigger, so ensure
@@ -799,6 +852,28 @@ func (c *gcControllerState) effectiveGrowthRatio() float64 {
 	return egogc
 }
 
+// memoryLimitHeapGoal returns the heap goal implied by the memory
+// limit, or ^uint64(0) if there is no limit.
+//
+// Everything the runtime has mapped outside the heap counts against
+// the limit, as does a small headroom, and the heap gets what is left
+// (see memoryLimitRetainedGoal). The limit is soft, so the goal never
+// drops below the heap marked by the previous cycle: if the limit is
+// that tight, the GC runs back to back and gcCPULimiter keeps it from
+// starving the application.
+//
+// mheap_.lock must be held or the world must be stopped.
+func (c *gcControllerState) memoryLimitHeapGoal() uint64 {
+	goal := memoryLimitRetainedGoal()
+	if goal == ^uint64(0) {
+		return goal
+	}
+	if goal < c.heapMarked {
+		goal = c.heapMarked
+	}
+	return goal
+}
+
 // setGCPercent updates gcPercent and all related pacer state.
 // Returns the old value of gcPercent.
 //
@@ -836,6 +911,35 @@ func setGCPercent(in int32) (out int32) {
 	return out
 }
 
+// setMemoryLimit updates memoryLimit and all related pacer state.
+// Returns the old value of memoryLimit. A negative limit leaves the
+// current value in place.
+//
+// The world must be stopped, or mheap_.lock must be held.
+func (c *gcControllerState) setMemoryLimit(in int64) int64 {
+	assertWorldStoppedOrLockHeld(&mheap_.lock)
+
+	out := c.memoryLimit
+	if in >= 0 {
+		atomic.Storeint64(&c.memoryLimit, in)
+	}
+	// Update pacing in response to the memoryLimit change.
+	c.commit(c.triggerRatio)
+
+	return out
+}
+
+//go:linkname setMemoryLimit runtime/debug.setMemoryLimit
+func setMemoryLimit(in int64) (out int64) {
+	// Run on the system stack since we grab the heap lock.
+	systemstack(func() {
+		lock(&mheap_.lock)
+		out = gcController.setMemoryLimit(in)
+		unlock(&mheap_.lock)
+	})
+	return out
+}
+
 func readGOGC() int32 {
 	p := gogetenv("GOGC")
 	if p == "off" {
@@ -846,3 +950,18 @@ func readGOGC() int32 {
 	}
 	return 100
 }
+
+// readGOMEMLIMIT reads the initial memory limit from $GOMEMLIMIT.
+// An unset variable or "off" means no limit.
+func readGOMEMLIMIT() int64 {
+	p := gogetenv("GOMEMLIMIT")
+	if p == "" || p == "off" {
+		return maxMemoryLimit
+	}
+	n, ok := parseByteCount(p)
+	if !ok {
+		print("GOMEMLIMIT=", p, "\n")
+		throw("malformed GOMEMLIMIT; see `go doc runtime/debug.SetMemoryLimit`")
+	}
+	return n
+}
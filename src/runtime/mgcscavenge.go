@@ -20,6 +20,11 @@
 // That goal is defined as:
 //   (retainExtraPercent+100) / 100 * (heapGoal / lastHeapGoal) * last_heap_inuse
 //
+// If a memory limit is set, the goal is further capped so that the heap's
+// estimated RSS plus all the other memory the runtime has mapped stays just
+// below the limit. This lets the scavenger return free memory that the GC
+// alone cannot, since the GC only bounds the size of the live heap.
+//
 // Essentially, we wish to have the application's RSS track the heap goal, but
 // the heap goal is defined in terms of bytes of objects, rather than pages like
 // RSS. As a result, we need to take into account for fragmentation internal to
@@ -108,29 +113,38 @@ func heapRetained() uint64 {
 // its rate and RSS goal.
 //
 // The RSS goal is based on the current heap goal with a small overhead
-// to accommodate non-determinism in the allocator.
+// to accommodate non-determinism in the allocator. If a memory limit is
+// set, the goal is also capped so that the heap's RSS, together with
+// everything else the runtime has mapped, stays just below the limit.
 //
 // The pacing is based on scavengePageRate, which applies to both regular and
 // huge pages. See that constant for more information.
 //
 // mheap_.lock must be held or the world must be stopped.
 func gcPaceScavenger() {
-	// If we're called before the first GC completed, disable scavenging.
-	// We never scavenge before the 2nd GC cycle anyway (we don't have enough
-	// information about the heap yet) so this is fine, and avoids a fault
-	// or garbage data later.
-	if gcController.lastHeapGoal == 0 {
+	retainedGoal := memoryLimitRetainedGoal()
+
+	// If we're called before the first GC completed, don't scavenge
+	// for the heap goal. We never scavenge for it before the 2nd GC
+	// cycle anyway (we don't have enough information about the heap
+	// yet) so this is fine, and avoids a fault or garbage data later.
+	if gcController.lastHeapGoal != 0 {
+		// Compute our scavenging goal.
+		goalRatio := float64(atomic.Load64(&gcController.heapGoal)) / float64(gcController.lastHeapGoal)
+		heapGoal := uint64(float64(memstats.last_heap_inuse) * goalRatio)
+		// Add retainExtraPercent overhead to heapGoal. This calculation
+		// looks strange but the purpose is to arrive at an integer division
+		// (e.g. if retainExtraPercent = 12.5, then we get a divisor of 8)
+		// that also avoids the overflow from a multiplication.
+		heapGoal += heapGoal / (1.0 / (retainExtraPercent / 100.0))
+		if heapGoal < retainedGoal {
+			retainedGoal = heapGoal
+		}
+	}
+	if retainedGoal == ^uint64(0) {
 		mheap_.scavengeGoal = ^uint64(0)
 		return
 	}
-	// Compute our scavenging goal.
-	goalRatio := float64(atomic.Load64(&gcController.heapGoal)) / float64(gcController.lastHeapGoal)
-	retainedGoal := uint64(float64(memstats.last_heap_inuse) * goalRatio)
-	// Add retainExtraPercent overhead to retainedGoal. This calculation
-	// looks strange but the purpose is to arrive at an integer division
-	// (e.g. if retainExtraPercent = 12.5, then we get a divisor of 8)
-	// that also avoids the overflow from a multiplication.
-	retainedGoal += retainedGoal / (1.0 / (retainExtraPercent / 100.0))
 	// Align it to a physical page boundary to make the following calculations
 	// a bit more exact.
 	retainedGoal = (retainedGoal + uint64(physPageSize) - 1) &^ (uint64(physPageSize) - 1)
@@ -158,6 +172,25 @@ func gcPaceScavenger() {
 	mheap_.scavengeGoal = retainedGoal
 }
 
+// memoryLimitRetainedGoal returns the heap RSS that keeps the total
+// memory mapped by the runtime just below the memory limit, or
+// ^uint64(0) if there is no limit.
+//
+// The goal leaves the same headroom under the limit as the heap goal,
+// so the GC and the scavenger work toward the same point.
+func memoryLimitRetainedGoal() uint64 {
+	limit := atomic.Loadint64(&gcController.memoryLimit)
+	if limit == maxMemoryLimit {
+		return ^uint64(0)
+	}
+	goal := uint64(limit) / 100 * (100 - memoryLimitHeadroomPercent)
+	nonHeap := mappedReady() - heapRetained()
+	if goal <= nonHeap {
+		return 0
+	}
+	return goal - nonHeap
+}
+
 // Sleep/wait state of the background scavenger.
 var scavenge struct {
 	lock       mutex
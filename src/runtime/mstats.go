@@ -36,6 +36,7 @@ type mstats struct {
 	heap_sys      sysMemStat // virtual address space obtained from system for GC'd heap
 	heap_inuse    uint64     // bytes in mSpanInUse spans
 	heap_released uint64     // bytes released to the os
+	heap_manual   sysMemStat // bytes of heap memory in manually-managed spans
 
 	// heap_objects is not used by the runtime directly and instead
 	// computed on the fly by updatememstats.
@@ -662,6 +663,15 @@ func flushallmcaches() {
 	}
 }
 
+// mappedReady returns an estimate of the memory the runtime has mapped
+// and not released back to the OS. This is the memory that counts
+// against the memory limit.
+func mappedReady() uint64 {
+	return memstats.heap_sys.load() + memstats.heap_manual.load() + memstats.stacks_sys.load() +
+		memstats.mspan_sys.load() + memstats.mcache_sys.load() + memstats.buckhash_sys.load() +
+		memstats.gcMiscSys.load() + memstats.other_sys.load() - atomic.Load64(&memstats.heap_released)
+}
+
 // sysMemStat represents a global system statistic that is managed atomically.
 //
 // This type must structurally be a uint64 so that mstats aligns with MemStats.
@@ -253,6 +253,7 @@ func main() {
 	}
 	fn := main_main // make an indirect call, as the linker doesn't know the address of the main package when laying down the runtime
 	fn()
+	runExitHooks(0)
 	if raceenabled {
 		racefini()
 	}
@@ -281,10 +282,11 @@ func main() {
 	}
 }
 
-// os_beforeExit is called from os.Exit(0).
+// os_beforeExit is called from os.Exit.
 //go:linkname os_beforeExit os.runtime_beforeExit
-func os_beforeExit() {
-	if raceenabled {
+func os_beforeExit(exitCode int) {
+	runExitHooks(exitCode)
+	if exitCode == 0 && raceenabled {
 		racefini()
 	}
 }
@@ -4931,6 +4933,9 @@ func procresize(nprocs int32) *p {
 	}
 	sched.procresizetime = now
 
+	// Update the GC CPU limiter's capacity before gomaxprocs changes.
+	gcCPULimiter.resetCapacity(now, nprocs)
+
 	maskWords := (nprocs + 31) / 32
 
 	// Grow allp if necessary.
@@ -5365,6 +5370,10 @@ func sysmon() {
 			// Kick the scavenger awake if someone requested it.
 			wakeScavenger()
 		}
+		// Keep the GC CPU limiter's view of GC CPU usage fresh.
+		if gcCPULimiter.needUpdate(now) {
+			gcCPULimiter.update(now)
+		}
 		// retake P's blocked in syscalls
 		// and preempt long running G's
 		if retake(now) != 0 {
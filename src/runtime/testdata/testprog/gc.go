@@ -21,6 +21,7 @@ func init() {
 	register("GCPhys", GCPhys)
 	register("DeferLiveness", DeferLiveness)
 	register("GCZombie", GCZombie)
+	register("GCMemoryLimit", GCMemoryLimit)
 }
 
 func GCSys() {
@@ -300,3 +301,41 @@ func GCZombie() {
 	runtime.KeepAlive(keep)
 	runtime.KeepAlive(zombies)
 }
+
+// Test that the GC respects the memory limit with GC otherwise
+// disabled: the total memory mapped by the runtime should stay close
+// to the limit even though far more than the limit gets allocated.
+func GCMemoryLimit() {
+	const (
+		limit     = 64 << 20
+		chunk     = 64 << 10
+		liveBytes = 8 << 20
+		total     = 1 << 30
+	)
+	debug.SetGCPercent(-1)
+	debug.SetMemoryLimit(limit)
+
+	// Keep a fixed amount of memory live while allocating
+	// many times more than the limit.
+	live := make([][]byte, liveBytes/chunk)
+	var ms runtime.MemStats
+	var peak uint64
+	for i := 0; i < total/chunk; i++ {
+		live[i%len(live)] = make([]byte, chunk)
+		if i%64 == 0 {
+			runtime.ReadMemStats(&ms)
+			if mapped := ms.Sys - ms.HeapReleased; mapped > peak {
+				peak = mapped
+			}
+		}
+	}
+	runtime.KeepAlive(live)
+
+	// Allow some slack for memory the runtime maps faster than the
+	// GC can react, but nowhere near what was allocated.
+	if peak > limit+limit/4 {
+		fmt.Printf("peak mapped memory %d bytes exceeds limit %d bytes\n", peak, limit)
+		return
+	}
+	fmt.Println("OK")
+}